@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"helm-portal/config"
+	"helm-portal/pkg/models"
+	service "helm-portal/pkg/services"
+	"helm-portal/pkg/utils"
+)
+
+// runExport implémente la sous-commande `export` : écrit un dépôt Helm statique
+// (index.yaml à URLs relatives, charts et fichiers .prov) dans une archive tar.gz
+func runExport(cfg *config.Config, log *utils.Logger, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	output := flags.String("output", "helm-repository.tgz", "archive to write")
+	pattern := flags.String("pattern", "", "glob on chart names, e.g. 'nginx-*' (default: all charts)")
+	latest := flags.Int("latest", 0, "export only the N newest versions of each chart (default: all versions)")
+	repository := flags.String("repository", "", "export an isolated repository instead of the default one")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var indexService *service.IndexService
+	if *repository != "" {
+		registry, err := service.NewRepositoryRegistry(cfg, log)
+		if err != nil {
+			return err
+		}
+		repo, err := registry.Get(*repository)
+		if err != nil {
+			return err
+		}
+		indexService = repo.Index
+	} else {
+		chartService := service.NewChartService(cfg, log, nil)
+		indexService = service.NewIndexService(cfg, log, chartService)
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	index, err := indexService.Export(f, models.ExportOptions{Pattern: *pattern, Latest: *latest})
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(*output)
+		return err
+	}
+
+	fmt.Printf("Exported %d charts (%d versions) to %s\n", len(index.Entries), index.CountVersions(), *output)
+	return nil
+}
@@ -8,6 +8,10 @@ import (
 	middleware "helm-portal/pkg/middlewares"
 	service "helm-portal/pkg/services"
 	"helm-portal/pkg/utils"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/template/html/v2"
@@ -15,7 +19,7 @@ import (
 )
 
 // setupServices initialise et configure tous les services
-func setupServices(cfg *config.Config, log *utils.Logger) (interfaces.ChartServiceInterface, interfaces.ImageServiceInterface, interfaces.IndexServiceInterface, *service.BackupService) {
+func setupServices(cfg *config.Config, log *utils.Logger) (interfaces.ChartServiceInterface, interfaces.ImageServiceInterface, *service.IndexService, *service.BackupService, *service.SearchService, *service.StatsService, *service.ScanService, *service.SBOMService, *service.SignatureService, *service.ReplicationService) {
 
 	tmpChartService := service.NewChartService(cfg, log, nil)
 	indexService := service.NewIndexService(cfg, log, tmpChartService)
@@ -25,7 +29,47 @@ func setupServices(cfg *config.Config, log *utils.Logger) (interfaces.ChartServi
 	if err != nil {
 		log.WithFunc().WithError(err).Fatal("Failed to initialize backup service")
 	}
-	return finalChartService, imageService, indexService, backupService
+
+	// Index de recherche, tenu à jour par les push et suppressions
+	searchService := service.NewSearchService(finalChartService, imageService, log)
+	if err := searchService.Rebuild(); err != nil {
+		log.WithFunc().WithError(err).Error("Failed to build search index")
+	}
+	finalChartService.Subscribe(searchService)
+	imageService.Subscribe(searchService)
+
+	// Statistiques de téléchargement, oubliées à la suppression d'une version ou d'un tag
+	statsService := service.NewStatsService(finalChartService.GetPathManager(), finalChartService, imageService, log)
+	finalChartService.Subscribe(statsService)
+	imageService.Subscribe(statsService)
+	statsService.StartFlusher(30 * time.Second)
+
+	// Analyse de vulnérabilités des images poussées, à partir de la base OSV importée
+	scanService := service.NewScanService(cfg, finalChartService.GetPathManager(), finalChartService, imageService, log)
+	imageService.Subscribe(scanService)
+	scanService.Start()
+
+	// SBOM des images et des charts poussés, stockés comme artefacts OCI liés à leur manifest
+	sbomService := service.NewSBOMService(cfg, finalChartService.GetPathManager(), finalChartService, imageService, log)
+	finalChartService.Subscribe(sbomService)
+	imageService.Subscribe(sbomService)
+	sbomService.Start()
+
+	// Vérification des signatures cosign et Notation, recalculée quand un dépôt change
+	signatureService := service.NewSignatureService(cfg, finalChartService.GetPathManager(), finalChartService, imageService, log)
+	finalChartService.Subscribe(signatureService)
+	imageService.Subscribe(signatureService)
+
+	// Réplication des push et suppressions vers d'autres registres
+	replicationService, err := service.NewReplicationService(cfg, finalChartService.GetPathManager(), finalChartService, imageService, log)
+	if err != nil {
+		log.WithFunc().WithError(err).Fatal("Failed to initialize replication")
+	}
+	finalChartService.Subscribe(replicationService)
+	imageService.Subscribe(replicationService)
+	replicationService.Start()
+
+	return finalChartService, imageService, indexService, backupService, searchService, statsService, scanService, sbomService, signatureService, replicationService
 }
 
 // setupHandlers initialise tous les handlers
@@ -36,26 +80,61 @@ func setupHandlers(
 	pathManager *utils.PathManager,
 	cfg *config.Config,
 	backupService *service.BackupService,
+	searchService *service.SearchService,
+	statsService *service.StatsService,
+	scanService *service.ScanService,
+	sbomService *service.SBOMService,
+	signatureService *service.SignatureService,
 	log *utils.Logger,
 
-) (*handlers.HelmHandler, *handlers.ImageHandler, *handlers.OCIHandler, *handlers.ConfigHandler, *handlers.IndexHandler, *handlers.BackupHandler) {
-	helmHandler := handlers.NewHelmHandler(chartService, pathManager, log)
-	imageHandler := handlers.NewImageHandler(imageService, pathManager, log)
-	ociHandler := handlers.NewOCIHandler(chartService, imageService, log)
+) (*handlers.HelmHandler, *handlers.ImageHandler, *handlers.OCIHandler, *handlers.ConfigHandler, *handlers.IndexHandler, *handlers.BackupHandler, *handlers.SearchHandler, *handlers.StatsHandler, *handlers.ScanHandler, *handlers.SBOMHandler, *handlers.SignatureHandler) {
+	helmHandler := handlers.NewHelmHandler(chartService, pathManager, log).WithStats(statsService).WithSignatures(signatureService)
+	imageHandler := handlers.NewImageHandler(imageService, pathManager, log).WithScanner(scanService).WithSignatures(signatureService)
+	ociHandler := handlers.NewOCIHandler(chartService, imageService, cfg, log).WithStats(statsService).WithSignatures(signatureService)
 	configHandler := handlers.NewConfigHandler(cfg, log)
-	indexHandler := handlers.NewIndexHandler(chartService, pathManager, log)
+	indexHandler := handlers.NewIndexHandler(chartService, pathManager, log).WithStats(statsService).WithSignatures(signatureService)
 	backupHandler := handlers.NewBackupHandler(backupService, log, cfg)
+	searchHandler := handlers.NewSearchHandler(searchService, log)
+	statsHandler := handlers.NewStatsHandler(statsService, log)
+	scanHandler := handlers.NewScanHandler(scanService, log)
+	sbomHandler := handlers.NewSBOMHandler(sbomService, log)
+	signatureHandler := handlers.NewSignatureHandler(signatureService, log)
 
-	return helmHandler, imageHandler, ociHandler, configHandler, indexHandler, backupHandler
+	return helmHandler, imageHandler, ociHandler, configHandler, indexHandler, backupHandler, searchHandler, statsHandler, scanHandler, sbomHandler, signatureHandler
 }
 
-func setupHTTPServer(app *fiber.App, log *utils.Logger) {
+// setupHTTPServer serves until SIGINT or SIGTERM, then shuts down gracefully and runs onShutdown
+func setupHTTPServer(app *fiber.App, log *utils.Logger, onShutdown ...func()) {
 
 	log.WithFunc().Info("🚀 Application starting")
 
+	// Arrêt propre : les requêtes en cours se terminent avant les derniers enregistrements
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-stop
+		log.WithFunc().WithField("signal", sig.String()).Info("Shutting down")
+		if err := app.Shutdown(); err != nil {
+			log.WithFunc().WithError(err).Error("HTTP Server shutdown failed")
+		}
+	}()
+
 	if err := app.Listen(":3030"); err != nil {
 		log.WithFunc().Fatal("HTTP Server failed")
 	}
+	for _, fn := range onShutdown {
+		fn()
+	}
+	log.WithFunc().Info("Application stopped")
+}
+
+// flushStats writes the pull statistics kept in memory since the last periodic flush
+func flushStats(log *utils.Logger, stats ...*service.StatsService) {
+	for _, s := range stats {
+		if err := s.Flush(); err != nil {
+			log.WithError(err).Error("Failed to save pull statistics")
+		}
+	}
 }
 
 func main() {
@@ -79,22 +158,77 @@ func main() {
 		log.WithError(err).Fatal("Failed to load auth configuration")
 	}
 
+	// Sous-commandes (ex. `server export --output repo.tgz --latest 3`)
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := runExport(cfg, log, os.Args[2:]); err != nil {
+			log.WithError(err).Fatal("Export failed")
+		}
+		return
+	}
+
 	// PathManager
 	pathManager := utils.NewPathManager(cfg.Storage.Path, log)
 
 	// Services
-	chartService, imageService, indexService, backupService := setupServices(cfg, log)
+	chartService, imageService, indexService, backupService, searchService, statsService, scanService, sbomService, signatureService, replicationService := setupServices(cfg, log)
+
+	// Dépôts isolés (/r/<name>/, oci://<host>/<name>/)
+	repositories, err := service.NewRepositoryRegistry(cfg, log)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to initialize repositories")
+	}
+	for _, repo := range repositories.List() {
+		repo.Stats.StartFlusher(30 * time.Second)
+	}
+	repositoryHandler := handlers.NewRepositoryHandler(repositories, cfg, log)
+
+	// Cache pull-through des registres (Docker Hub, ghcr.io...) et dépôts Helm amont
+	proxyService, err := service.NewProxyService(cfg, pathManager, imageService, log)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to initialize pull-through cache")
+	}
+	proxyHandler := handlers.NewProxyHandler(proxyService, log)
+	replicationHandler := handlers.NewReplicationHandler(replicationService, log)
+
+	// Rétention : suppression planifiée des anciens tags et versions, dépôts isolés compris
+	retentionService, err := service.NewRetentionService(cfg, pathManager, chartService, imageService, statsService, log)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to initialize retention policies")
+	}
+	retentionService.WithRepositories(repositories).Start()
+	retentionHandler := handlers.NewRetentionHandler(retentionService, log)
+
+	// Promotion : copie d'un artefact vers un autre dépôt ou tag, sans dupliquer les blobs
+	promotionService, err := service.NewPromotionService(cfg, chartService, imageService, repositories, signatureService, scanService, log)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to initialize promotion")
+	}
+	promotionHandler := handlers.NewPromotionHandler(promotionService, log)
+
+	// Import de dépôts existants (index.yaml distant ou dossier local)
+	importHandler := handlers.NewImportHandler(service.NewImportService(chartService, log).WithImportRoot(cfg.Charts.Import.Root), log)
+	exportHandler := handlers.NewExportHandler(indexService, log)
 
 	// Handlers
-	helmHandler, imageHandler, ociHandler, configHandler, indexHandler, backupHandler := setupHandlers(
+	helmHandler, imageHandler, ociHandler, configHandler, indexHandler, backupHandler, searchHandler, statsHandler, scanHandler, sbomHandler, signatureHandler := setupHandlers(
 		chartService,
 		imageService,
 		indexService,
 		pathManager,
 		cfg,
 		backupService,
+		searchService,
+		statsService,
+		scanService,
+		sbomService,
+		signatureService,
 		log,
 	)
+	ociHandler.WithProxy(proxyService)
+
+	// Templates, avec les helpers de formatage (tailles, digests)
+	views := html.New("./views", ".html")
+	views.AddFuncMap(utils.TemplateFuncs())
 
 	// Fiber app configuration
 	app := fiber.New(fiber.Config{
@@ -103,7 +237,7 @@ func main() {
 		CaseSensitive: true,
 		StrictRouting: true,
 		ServerHeader:  "Helm Portal",
-		Views:         html.New("./views", ".html"),
+		Views:         views,
 
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			log.WithFields(logrus.Fields{
@@ -142,35 +276,102 @@ func main() {
 	// Créer le middleware d'authentification
 	authMiddleware := middleware.NewAuthMiddleware(cfg, log)
 
-	// Appliquer le middleware aux routes OCI qui nécessitent une authentification
+	// Appliquer le middleware aux routes OCI qui nécessitent une authentification.
+	// Les espaces de noms des dépôts isolés appliquent leurs propres règles (lecture anonyme des dépôts publics).
 	ociGroup := app.Group("/v2")
-	ociGroup.Use(authMiddleware.Authenticate())
+	ociGroup.Use(authMiddleware.AuthenticateExcept(repositoryHandler.OCIPrefixes()...))
 	// log.WithField("config", *cfg).Info("Configuration loaded")
 	log.WithField("backup", cfg.Backup).Info("Backup configuration")
 	// Routes Portal Interface
 	app.Get("/", helmHandler.DisplayHome)
 	app.Get("/backup/status", backupHandler.GetBackupStatus)
+	app.Get("/search", searchHandler.Search)
+	app.Get("/api/stats", statsHandler.GetStats)
 
 	// Helm Chart routes
 	app.Get("/chart/:name/:version/details", helmHandler.DisplayChartDetails)
+	app.Post("/chart/:name/:version/render", helmHandler.RenderChart)
+	app.Get("/chart/:name/:version/files", helmHandler.ListChartFiles)
+	app.Get("/chart/:name/:version/files/*", helmHandler.GetChartFile)
+	app.Get("/chart/:name/:version/dependencies", helmHandler.CheckDependencies)
+	app.Get("/chart/:name/:version/schema", helmHandler.GetValuesSchema)
+	app.Post("/chart/:name/:version/values/validate", helmHandler.ValidateValues)
+	app.Get("/chart/:name/:version/status", helmHandler.GetChartStatus)
+	app.Get("/chart/:name/:version/vulnerabilities", scanHandler.GetChartVulnerabilities)
+	app.Get("/chart/:name/:version/sbom", sbomHandler.GetChartSBOM)
+	app.Post("/chart/:name/:version/sbom", authMiddleware.Authenticate(), sbomHandler.GenerateChartSBOM)
+	app.Get("/chart/:name/:version/signature", signatureHandler.GetChartSignature)
 	app.Delete("/chart/:name/:version", helmHandler.DeleteChart)
 	app.Post("/chart", helmHandler.UploadChart)
+	app.Post("/api/package", authMiddleware.Authenticate(), helmHandler.PackageChart)
 	app.Get("/config", configHandler.GetConfig)
+	app.Get("/chart/:name/versions", helmHandler.GetChartVersions)
+	app.Get("/chart/:name/resolve", helmHandler.ResolveChartVersion)
+	app.Get("/chart/:name/diff", helmHandler.DiffChartVersions)
+	app.Get("/chart/:name/dependents", helmHandler.GetDependents)
 	app.Get("/chart/:name/:version", helmHandler.DownloadChart)
 	app.Get("/index.yaml", indexHandler.GetIndex)
+	app.Get("/charts/:file", indexHandler.GetChartArchive)
 	app.Get("/charts", helmHandler.ListCharts)
-	app.Get("/chart/:name/versions", helmHandler.GetChartVersions)
+
+	// Chart lifecycle routes (the authenticated user is recorded as the actor)
+	app.Post("/chart/:name/:version/deprecate", authMiddleware.Authenticate(), helmHandler.DeprecateChart)
+	app.Post("/chart/:name/:version/yank", authMiddleware.Authenticate(), helmHandler.YankChart)
+	app.Post("/chart/:name/:version/reinstate", authMiddleware.Authenticate(), helmHandler.ReinstateChart)
+
+	// Static repository export (air-gapped environments)
+	app.Get("/api/export", exportHandler.ExportRepository)
+
+	// Bulk import routes
+	app.Post("/api/import", authMiddleware.Authenticate(), importHandler.StartImport)
+	app.Get("/api/import", authMiddleware.Authenticate(), importHandler.ListImports)
+	app.Get("/api/import/:id", authMiddleware.Authenticate(), importHandler.GetImport)
 
 	// Docker Image routes
+	// Le joker porte le nom de l'image, à plusieurs segments (library/nginx), suivi du tag ou du digest
 	app.Get("/images", imageHandler.ListImages)
-	app.Get("/image/:name", imageHandler.GetImageTags)
-	app.Get("/image/:name/:tag/details", imageHandler.DisplayImageDetails)
-	app.Delete("/image/:name/:tag", imageHandler.DeleteImage)
+	app.Get("/image/*/layers/:digest/files/*", imageHandler.DownloadLayerFile)
+	app.Get("/image/*/layers/:digest", imageHandler.ListLayerFiles)
+	app.Get("/image/*/details", imageHandler.DisplayImageDetails)
+	app.Get("/image/*/filesystem", imageHandler.GetFilesystem)
+	app.Get("/image/*/vulnerabilities", scanHandler.GetImageVulnerabilities)
+	app.Post("/image/*/scan", authMiddleware.Authenticate(), scanHandler.ScanImage)
+	app.Get("/image/*/sbom", sbomHandler.GetImageSBOM)
+	app.Post("/image/*/sbom", authMiddleware.Authenticate(), sbomHandler.GenerateImageSBOM)
+	app.Get("/image/*/signature", signatureHandler.GetImageSignature)
+	app.Delete("/image/*", imageHandler.DeleteImage)
+	app.Get("/image/*", imageHandler.GetImageTags)
+
+	// Vulnerability database (OSV dumps imported from local files)
+	app.Get("/api/vulnerabilities/database", scanHandler.GetDatabase)
+	app.Post("/api/vulnerabilities/database", authMiddleware.Authenticate(), scanHandler.ImportDatabase)
+
+	// Cache pull-through : dépôts Helm amont et état des amonts
+	app.Get("/api/proxy", proxyHandler.GetStatus)
+	app.Get("/proxy/:name/index.yaml", proxyHandler.GetHelmIndex)
+	app.Get("/proxy/:name/charts/:file", proxyHandler.GetHelmChart)
+
+	// Réplication vers d'autres registres
+	app.Get("/replication", replicationHandler.GetStatus)
+	app.Post("/api/replication/:rule/run", authMiddleware.Authenticate(), replicationHandler.ReplicateNow)
+
+	// Politiques de rétention : aperçu (?dryRun=true), passe immédiate et journal des suppressions
+	app.Get("/api/retention", retentionHandler.GetRetention)
+	app.Post("/api/retention/run", authMiddleware.Authenticate(), retentionHandler.RunRetention)
+	app.Get("/api/retention/audit", retentionHandler.GetAudit)
+
+	// Promotion d'un chart ou d'une image vers un autre dépôt ou tag (ex. dev → prod)
+	app.Post("/api/promote", authMiddleware.Authenticate(), promotionHandler.Promote)
 
 	// Routes Backup
 	app.Post("/backup", backupHandler.HandleBackup)
 	app.Post("/restore", backupHandler.HandleRestore)
 
+	// Routes des dépôts isolés (chacun avec son stockage et ses droits), avant les routes OCI
+	// du dépôt par défaut : leurs règles d'accès s'appliquent à tout /v2/<dépôt>/
+	app.Get("/repositories", repositoryHandler.ListRepositories)
+	repositoryHandler.RegisterRoutes(app, ociGroup, authMiddleware)
+
 	// Routes OCI
 	ociGroup.Get("/", ociHandler.HandleOCIAPI)
 	ociGroup.Get("/_catalog", ociHandler.HandleCatalog)
@@ -178,16 +379,27 @@ func main() {
 	ociGroup.Head("/:name/manifests/:reference", ociHandler.HandleManifest)
 	ociGroup.Get("/:name/manifests/:reference", ociHandler.HandleManifest)
 	ociGroup.Put("/:name/manifests/:reference", ociHandler.PutManifest)
+	ociGroup.Delete("/:name/manifests/:reference", ociHandler.DeleteManifest)
 	ociGroup.Put("/:name/blobs/:digest", ociHandler.PutBlob)
 	ociGroup.Post("/:name/blobs/uploads/", ociHandler.PostUpload)
 	ociGroup.Patch("/:name/blobs/uploads/:uuid", ociHandler.PatchBlob)
 	ociGroup.Put("/:name/blobs/uploads/:uuid", ociHandler.CompleteUpload)
 	ociGroup.Head("/:name/blobs/:digest", ociHandler.HeadBlob)
 	ociGroup.Get("/:name/blobs/:digest", ociHandler.GetBlob)
+	ociGroup.Get("/:name/referrers/:digest", ociHandler.GetReferrers)
+
+	// Noms à plusieurs segments du cache pull-through (dockerhub/library/nginx), après toutes les routes OCI
+	ociGroup.Head("/*", ociHandler.HandleProxy)
+	ociGroup.Get("/*", ociHandler.HandleProxy)
 
 	// Démarrage du serveur
 	port := ":3030"
 	log.WithField("port", port).Info("Starting server")
 
-	setupHTTPServer(app, log)
+	// Les statistiques des dépôts isolés sont enregistrées avec celles du dépôt par défaut
+	allStats := []*service.StatsService{statsService}
+	for _, repo := range repositories.List() {
+		allStats = append(allStats, repo.Stats)
+	}
+	setupHTTPServer(app, log, func() { flushStats(log, allStats...) })
 }
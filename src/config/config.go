@@ -36,6 +36,211 @@ type Backup struct {
 	} `yaml:"azure"`
 }
 
+// ChartsConfig regroupe les options liées aux charts Helm
+type ChartsConfig struct {
+	Validation struct {
+		// MaxArchiveSize est la taille maximale (en octets) d'une archive .tgz
+		MaxArchiveSize int64 `yaml:"maxArchiveSize"`
+	} `yaml:"validation"`
+	Browser struct {
+		// MaxFileSize est la taille maximale (en octets) d'un fichier affiché dans le navigateur de fichiers
+		MaxFileSize int64 `yaml:"maxFileSize"`
+	} `yaml:"browser"`
+	Dependencies struct {
+		// HostedRepositories liste les URLs (https:// ou oci://) qui désignent ce portail.
+		// Vide : aucune dépendance distante n'est résolue localement.
+		HostedRepositories []string `yaml:"hostedRepositories"`
+	} `yaml:"dependencies"`
+	Import struct {
+		// Root est le seul répertoire du serveur dont les charts peuvent être importés (vide : import par URL uniquement)
+		Root string `yaml:"root"`
+	} `yaml:"import"`
+}
+
+// ScanningConfig regroupe les options de l'analyse de vulnérabilités des images
+type ScanningConfig struct {
+	// Database est un export OSV local (zip ou JSON) importé au démarrage s'il a changé
+	Database string `yaml:"database"`
+	// DatabaseDir est le seul répertoire du serveur dont l'API peut importer un export OSV par chemin
+	DatabaseDir string `yaml:"databaseDir"`
+	// DisableOnPush désactive l'analyse automatique des images poussées
+	DisableOnPush bool `yaml:"disableOnPush"`
+	// MaxFileSize est la taille maximale (en octets) d'un fichier lu pour inventorier les paquets
+	MaxFileSize int64 `yaml:"maxFileSize"`
+}
+
+// SBOMConfig regroupe les options de génération des SBOM des images et des charts
+type SBOMConfig struct {
+	// Format est le format généré au push : "spdx" (défaut) ou "cyclonedx"
+	Format string `yaml:"format"`
+	// DisableOnPush désactive la génération au push, les SBOM sont alors générés au premier téléchargement
+	DisableOnPush bool `yaml:"disableOnPush"`
+}
+
+// SigningKey est une clé publique cosign (PEM : ECDSA, RSA ou Ed25519)
+type SigningKey struct {
+	Name string `yaml:"name"`
+	Path string `yaml:"path"`
+}
+
+// SignaturesConfig regroupe les options de vérification des signatures cosign et Notation
+type SignaturesConfig struct {
+	// Keys liste les clés publiques acceptées pour les signatures cosign
+	Keys []SigningKey `yaml:"keys"`
+	// NotationCertificates liste les certificats racines (fichiers PEM) acceptés pour les signatures Notation
+	NotationCertificates []string `yaml:"notationCertificates"`
+	// Enforce liste les dépôts (glob) dont les manifests sans signature vérifiée ne peuvent pas être tirés
+	Enforce []string `yaml:"enforce"`
+}
+
+// UpstreamRegistry est un registre OCI amont (Docker Hub, ghcr.io...) mis en cache sous <name>/<dépôt amont>
+type UpstreamRegistry struct {
+	// Name est le préfixe local des dépôts en cache, ex. "dockerhub" pour dockerhub/library/nginx
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"` // ex. "https://registry-1.docker.io"
+	// Namespace est le registre désigné par un miroir containerd (?ns=docker.io). Défaut : l'hôte de l'URL
+	Namespace string `yaml:"namespace"`
+	Username  string `yaml:"username"`
+	Password  string `yaml:"password"` // ou PROXY_<NAME>_PASSWORD
+	// TagTTL est la durée pendant laquelle un tag en cache est servi sans être revalidé (défaut : 10m)
+	TagTTL string `yaml:"tagTTL"`
+}
+
+// UpstreamHelmRepository est un dépôt Helm HTTP amont mis en cache sous /proxy/<name>/
+type UpstreamHelmRepository struct {
+	Name     string `yaml:"name"`
+	URL      string `yaml:"url"` // ex. "https://charts.bitnami.com/bitnami"
+	Username string `yaml:"username"`
+	Password string `yaml:"password"` // ou PROXY_<NAME>_PASSWORD
+	// IndexTTL est la durée pendant laquelle l'index.yaml en cache est servi sans être rechargé (défaut : 10m)
+	IndexTTL string `yaml:"indexTTL"`
+}
+
+// ProxyConfig regroupe les registres et dépôts amont servis en cache (pull-through)
+type ProxyConfig struct {
+	Registries       []UpstreamRegistry       `yaml:"registries"`
+	HelmRepositories []UpstreamHelmRepository `yaml:"helmRepositories"`
+	// Timeout borne l'attente de la réponse d'un amont (défaut : 30s), pas la durée de téléchargement d'un blob
+	Timeout string `yaml:"timeout"`
+}
+
+// ReplicationRule réplique les charts et images poussés vers un registre OCI distant (autre portail, Harbor, ECR...)
+type ReplicationRule struct {
+	Name string `yaml:"name"`
+	// Repositories liste les dépôts (glob) répliqués. Vide : tous
+	Repositories []string `yaml:"repositories"`
+	// Tags liste les tags d'images et versions de charts (glob) répliqués. Vide : tous
+	Tags []string `yaml:"tags"`
+	// Target est l'URL du registre cible, ex. "https://registry.eu.example.com"
+	Target string `yaml:"target"`
+	// Namespace préfixe les dépôts sur la cible, ex. "mirror" pour mirror/<dépôt> (projet Harbor)
+	Namespace string `yaml:"namespace"`
+	Username  string `yaml:"username"`
+	Password  string `yaml:"password"` // ou REPLICATION_<NAME>_PASSWORD
+	// SkipDeletions ne réplique pas les suppressions de tags et de versions
+	SkipDeletions bool `yaml:"skipDeletions"`
+}
+
+// ReplicationConfig regroupe les règles de réplication et leur politique de nouvelle tentative
+type ReplicationConfig struct {
+	Rules []ReplicationRule `yaml:"rules"`
+	// MaxAttempts est le nombre de tentatives d'une réplication avant abandon (défaut : 8)
+	MaxAttempts int `yaml:"maxAttempts"`
+	// RetryDelay est le délai avant la deuxième tentative, doublé à chaque échec jusqu'à MaxRetryDelay (défauts : 10s, 10m)
+	RetryDelay    string `yaml:"retryDelay"`
+	MaxRetryDelay string `yaml:"maxRetryDelay"`
+}
+
+// OverwriteRule applique un mode d'écrasement aux dépôts dont le nom correspond au pattern (glob)
+type OverwriteRule struct {
+	Pattern string `yaml:"pattern"`
+	Mode    string `yaml:"mode"` // "allow", "immutable" ou "prerelease"
+}
+
+// RetentionRule supprime les anciens tags d'images et versions de charts des dépôts dont le nom correspond au pattern (glob).
+// Un tag est supprimé s'il n'est protégé par aucun des critères Keep* et qu'il est plus ancien que OlderThanDays.
+type RetentionRule struct {
+	Pattern string `yaml:"pattern"`
+	// KeepLast conserve les N tags poussés le plus récemment
+	KeepLast int `yaml:"keepLast"`
+	// KeepTags conserve les tags correspondant à l'une de ces expressions régulières, ex. "^v[0-9.]+$"
+	KeepTags []string `yaml:"keepTags"`
+	// OlderThanDays ne supprime que les tags poussés il y a plus de N jours (0 : quel que soit leur âge)
+	OlderThanDays int `yaml:"olderThanDays"`
+	// KeepPulledWithinDays conserve les tags téléchargés dans les N derniers jours
+	KeepPulledWithinDays int `yaml:"keepPulledWithinDays"`
+}
+
+// RetentionConfig regroupe les règles de rétention et leur planification
+type RetentionConfig struct {
+	Rules []RetentionRule `yaml:"rules"`
+	// Schedule est l'intervalle entre deux passes, ex. "24h" (vide : passes manuelles uniquement)
+	Schedule string `yaml:"schedule"`
+	// DryRun limite les passes planifiées à un aperçu, sans suppression
+	DryRun bool `yaml:"dryRun"`
+}
+
+// PromotionRule impose des contrôles aux promotions vers les dépôts dont le nom correspond au pattern (glob,
+// "" pour le dépôt par défaut), en plus de ceux demandés dans la requête
+type PromotionRule struct {
+	Repository string `yaml:"repository"`
+	// RequireSignature exige une signature vérifiée de l'artefact source
+	RequireSignature bool `yaml:"requireSignature"`
+	// RequireScan exige une analyse sans vulnérabilité plus grave que MaxSeverity
+	RequireScan bool `yaml:"requireScan"`
+	// MaxSeverity est la sévérité la plus grave tolérée (CRITICAL, HIGH, MEDIUM ou LOW ; MEDIUM par défaut)
+	MaxSeverity string `yaml:"maxSeverity"`
+}
+
+// PromotionConfig regroupe les règles de promotion entre dépôts
+type PromotionConfig struct {
+	Rules []PromotionRule `yaml:"rules"`
+}
+
+// Policies regroupe les règles appliquées aux publications
+type Policies struct {
+	Overwrite struct {
+		Default     string          `yaml:"default"`
+		MutableTags []string        `yaml:"mutableTags"`
+		Rules       []OverwriteRule `yaml:"rules"`
+	} `yaml:"overwrite"`
+	Retention RetentionConfig `yaml:"retention"`
+	Promotion PromotionConfig `yaml:"promotion"`
+}
+
+// RepositoryConfig décrit un dépôt Helm isolé, servi sous /r/<name>/ et oci://<host>/<name>/
+type RepositoryConfig struct {
+	Name string `yaml:"name"`
+	// Readers liste les utilisateurs autorisés à lire le dépôt. Vide : lecture anonyme
+	Readers []string `yaml:"readers"`
+	// Writers liste les utilisateurs autorisés à publier et supprimer. Vide : tout utilisateur authentifié
+	Writers []string `yaml:"writers"`
+}
+
+// IsPublic indique si le dépôt est lisible sans authentification
+func (r RepositoryConfig) IsPublic() bool {
+	return len(r.Readers) == 0
+}
+
+// CanRead indique si un utilisateur authentifié peut lire le dépôt (les writers peuvent aussi lire)
+func (r RepositoryConfig) CanRead(username string) bool {
+	return r.IsPublic() || containsUser(r.Readers, username) || containsUser(r.Writers, username)
+}
+
+// CanWrite indique si un utilisateur authentifié peut publier dans le dépôt
+func (r RepositoryConfig) CanWrite(username string) bool {
+	return len(r.Writers) == 0 || containsUser(r.Writers, username)
+}
+
+func containsUser(users []string, username string) bool {
+	for _, user := range users {
+		if user == username {
+			return true
+		}
+	}
+	return false
+}
+
 type Config struct {
 	Server struct {
 		Port int `yaml:"port"`
@@ -49,8 +254,17 @@ type Config struct {
 		Level  string `yaml:"level"`
 		Format string `yaml:"format"`
 	} `yaml:"logging"`
-	Auth   AuthConfig `yaml:"auth"`
-	Backup Backup     `yaml:"backup"`
+	Auth        AuthConfig        `yaml:"auth"`
+	Backup      Backup            `yaml:"backup"`
+	Charts      ChartsConfig      `yaml:"charts"`
+	Policies    Policies          `yaml:"policies"`
+	Scanning    ScanningConfig    `yaml:"scanning"`
+	SBOM        SBOMConfig        `yaml:"sbom"`
+	Signatures  SignaturesConfig  `yaml:"signatures"`
+	Proxy       ProxyConfig       `yaml:"proxy"`
+	Replication ReplicationConfig `yaml:"replication"`
+	// Repositories liste les dépôts isolés servis en plus du dépôt par défaut
+	Repositories []RepositoryConfig `yaml:"repositories"`
 }
 
 type Secrets struct {
@@ -139,10 +353,37 @@ func loadConfigFromEnv(config *Config) {
 		config.Backup.Azure.Container = azureContainer
 	}
 
+	// Identifiants des amonts du cache (PROXY_DOCKERHUB_USERNAME, PROXY_DOCKERHUB_PASSWORD...)
+	for i := range config.Proxy.Registries {
+		registry := &config.Proxy.Registries[i]
+		loadCredentialsFromEnv("PROXY_", registry.Name, &registry.Username, &registry.Password)
+	}
+	for i := range config.Proxy.HelmRepositories {
+		repository := &config.Proxy.HelmRepositories[i]
+		loadCredentialsFromEnv("PROXY_", repository.Name, &repository.Username, &repository.Password)
+	}
+
+	// Identifiants des cibles de réplication (REPLICATION_EU_WEST_USERNAME, REPLICATION_EU_WEST_PASSWORD...)
+	for i := range config.Replication.Rules {
+		rule := &config.Replication.Rules[i]
+		loadCredentialsFromEnv("REPLICATION_", rule.Name, &rule.Username, &rule.Password)
+	}
+
 	// Load auth users from environment variables
 	loadAuthFromEnv(config)
 }
 
+// loadCredentialsFromEnv remplace des identifiants par <PREFIX><NAME>_USERNAME et <PREFIX><NAME>_PASSWORD
+func loadCredentialsFromEnv(prefix, name string, username, password *string) {
+	prefix += strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name)) + "_"
+	if value := os.Getenv(prefix + "USERNAME"); value != "" {
+		*username = value
+	}
+	if value := os.Getenv(prefix + "PASSWORD"); value != "" {
+		*password = value
+	}
+}
+
 // loadAuthFromEnv charge les utilisateurs depuis les variables d'environnement
 func loadAuthFromEnv(config *Config) {
 	// Option 1: Support pour utilisateurs multiples via HELM_USERS (format: "user1:pass1,user2:pass2")
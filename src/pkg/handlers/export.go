@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"bufio"
+	"errors"
+
+	"helm-portal/pkg/models"
+	services "helm-portal/pkg/services"
+	utils "helm-portal/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// ExportHandler serves static Helm repository exports
+type ExportHandler struct {
+	log     *utils.Logger
+	service *services.IndexService
+}
+
+// NewExportHandler creates a new export handler
+func NewExportHandler(service *services.IndexService, log *utils.Logger) *ExportHandler {
+	return &ExportHandler{
+		service: service,
+		log:     log,
+	}
+}
+
+// ExportRepository streams a static Helm repository (index.yaml with relative URLs,
+// charts and provenance files) as a tar.gz, filtered by ?pattern= and ?latest=
+func (h *ExportHandler) ExportRepository(c *fiber.Ctx) error {
+	opts := models.ExportOptions{
+		Pattern: c.Query("pattern"),
+		Latest:  c.QueryInt("latest"),
+	}
+
+	index, err := h.service.SelectExport(opts)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidExportOptions) {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		h.log.WithFunc().WithError(err).Error("Failed to select charts to export")
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to export repository"})
+	}
+
+	h.log.WithFunc().WithFields(logrus.Fields{
+		"pattern":  opts.Pattern,
+		"latest":   opts.Latest,
+		"versions": index.CountVersions(),
+	}).Info("Exporting static repository")
+
+	c.Set("Content-Type", "application/gzip")
+	c.Set("Content-Disposition", `attachment; filename="helm-repository.tgz"`)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := h.service.WriteExport(w, index); err != nil {
+			h.log.WithError(err).Error("Static repository export interrupted")
+		}
+		w.Flush()
+	})
+	return nil
+}
@@ -1,10 +1,16 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"helm-portal/pkg/interfaces"
+	middleware "helm-portal/pkg/middlewares"
+	"helm-portal/pkg/models"
+	services "helm-portal/pkg/services"
 	utils "helm-portal/pkg/utils"
 	"io"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
@@ -15,12 +21,16 @@ type HelmHandler struct {
 	service     interfaces.ChartServiceInterface
 	log         *utils.Logger
 	pathManager *utils.PathManager
+	stats       *services.StatsService
+	signatures  *services.SignatureService
 }
 
 type IndexHandler struct {
 	service     interfaces.ChartServiceInterface
 	log         *utils.Logger
 	pathManager *utils.PathManager
+	stats       *services.StatsService
+	signatures  *services.SignatureService
 }
 
 type ErrorResponse struct {
@@ -47,59 +57,174 @@ func NewIndexHandler(service interfaces.ChartServiceInterface, pathManager *util
 	}
 }
 
+// WithStats counts the chart downloads in stats
+func (h *HelmHandler) WithStats(stats *services.StatsService) *HelmHandler {
+	h.stats = stats
+	return h
+}
+
+// WithSignatures shows the signature status of the chart versions in the web interface,
+// and refuses the downloads of unsigned versions from the enforced repositories
+func (h *HelmHandler) WithSignatures(signatures *services.SignatureService) *HelmHandler {
+	h.signatures = signatures
+	return h
+}
+
+// WithStats counts the chart archive downloads in stats
+func (h *IndexHandler) WithStats(stats *services.StatsService) *IndexHandler {
+	h.stats = stats
+	return h
+}
+
+// WithSignatures refuses the downloads of unsigned chart archives from the enforced repositories
+func (h *IndexHandler) WithSignatures(signatures *services.SignatureService) *IndexHandler {
+	h.signatures = signatures
+	return h
+}
+
 func (h *HelmHandler) GetChartVersions(c *fiber.Ctx) error {
 	name := c.Params("name")
 	h.log.WithFunc().WithField("chart", name).Debug("Fetching chart versions")
 
-	chartGroups, err := h.service.ListCharts()
+	versions, err := h.service.GetChartVersions(name)
 	if err != nil {
+		if errors.Is(err, services.ErrChartNotFound) {
+			h.log.WithFunc().WithField("chart", name).Debug("Chart not found")
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Chart not found",
+			})
+		}
 		h.log.WithFunc().WithError(err).Error("Failed to fetch chart versions")
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to fetch chart versions",
 		})
 	}
 
-	h.log.WithFunc().WithField("chartGroupsCount", len(chartGroups)).Debug("Chart groups count")
+	if len(versions) == 0 {
+		h.log.WithFunc().Debug("Chart found but no versions available")
+		return c.Status(404).JSON(fiber.Map{
+			"error": "No versions found for this chart",
+		})
+	}
 
-	foundChart := false
-	for _, group := range chartGroups {
-		h.log.WithFunc().WithFields(logrus.Fields{
-			"groupName":     group.Name,
-			"requestedName": name,
-			"versionsCount": len(group.Versions),
-		}).Debug("Checking chart group")
-
-		if group.Name == name {
-			foundChart = true
-			if len(group.Versions) == 0 {
-				h.log.WithFunc().Debug("Chart found but no versions available")
-				return c.Status(404).JSON(fiber.Map{
-					"error": "No versions found for this chart",
-				})
-			}
-			return c.JSON(group.Versions)
-		}
+	return c.JSON(versions)
+}
+
+// ResolveChartVersion returns the best version matching the "constraint" query parameter
+func (h *HelmHandler) ResolveChartVersion(c *fiber.Ctx) error {
+	name := c.Params("name")
+	constraint := c.Query("constraint")
+
+	h.log.WithFunc().WithFields(logrus.Fields{
+		"chart":      name,
+		"constraint": constraint,
+	}).Debug("Resolving chart version")
+
+	if constraint == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Missing constraint query parameter"})
 	}
 
-	if !foundChart {
-		h.log.WithFunc().WithField("chart", name).Debug("Chart not found")
-		return c.Status(404).JSON(fiber.Map{
-			"error": "Chart not found",
-		})
+	metadata, err := h.service.ResolveChartVersion(name, constraint)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidConstraint):
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, services.ErrChartNotFound), errors.Is(err, services.ErrNoMatchingVersion):
+			return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+		}
+		h.log.WithFunc().WithError(err).Error("Failed to resolve chart version")
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to resolve chart version"})
 	}
 
-	// Ne devrait jamais arriver ici mais par sécurité
-	return c.Status(500).JSON(fiber.Map{
-		"error": "Unknown error occurred",
+	return c.JSON(fiber.Map{
+		"name":       name,
+		"constraint": constraint,
+		"version":    metadata.Version,
+		"chart":      metadata,
 	})
 }
 
+// DiffChartVersions compares two versions of a chart given by the "from" and "to" query parameters
+func (h *HelmHandler) DiffChartVersions(c *fiber.Ctx) error {
+	name := c.Params("name")
+	from := c.Query("from")
+	to := c.Query("to")
+
+	h.log.WithFunc().WithFields(logrus.Fields{
+		"chart": name,
+		"from":  from,
+		"to":    to,
+	}).Debug("Processing chart diff request")
+
+	if from == "" || to == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Missing from or to query parameter"})
+	}
+
+	for _, version := range []string{from, to} {
+		if !h.service.ChartExists(name, version) {
+			return c.Status(404).JSON(fiber.Map{"error": fmt.Sprintf("Chart %s version %s not found", name, version)})
+		}
+	}
+
+	diff, err := h.service.DiffChartVersions(name, from, to)
+	if err != nil {
+		h.log.WithFunc().WithError(err).Error("Failed to compare chart versions")
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to compare chart versions"})
+	}
+
+	return c.JSON(diff)
+}
+
 func (h *IndexHandler) GetIndex(c *fiber.Ctx) error {
 	indexPath := h.pathManager.GetIndexPath()
 	h.log.WithFunc().WithField("path", indexPath).Debug("Processing index.yaml request")
 	return c.SendFile(indexPath)
 }
 
+// GetChartArchive serves a chart archive by file name, as referenced by the URLs of index.yaml,
+// and its provenance file (<archive>.prov) when one was stored
+func (h *IndexHandler) GetChartArchive(c *fiber.Ctx) error {
+	fileName := filepath.Base(c.Params("file"))
+	provenance := strings.HasSuffix(fileName, ".tgz.prov")
+	if !strings.HasSuffix(fileName, ".tgz") && !provenance {
+		return c.Status(404).JSON(fiber.Map{"error": "Chart not found"})
+	}
+
+	chartPath := filepath.Join(h.pathManager.GetChartsPath(), fileName)
+	if _, err := os.Stat(chartPath); err != nil {
+		h.log.WithFunc().WithField("file", fileName).Debug("Chart archive not found")
+		return c.Status(404).JSON(fiber.Map{"error": "Chart not found"})
+	}
+
+	if provenance {
+		c.Set("Content-Type", "application/pgp-signature")
+		return c.SendFile(chartPath)
+	}
+
+	if h.stats != nil || h.signatures != nil {
+		data, err := os.ReadFile(chartPath)
+		if err != nil {
+			h.log.WithFunc().WithError(err).Error("Failed to read chart archive")
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to read chart"})
+		}
+		metadata, err := h.service.ExtractChartMetadata(data)
+		if err != nil {
+			h.log.WithFunc().WithError(err).Error("Failed to read chart metadata")
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to read chart"})
+		}
+		if h.signatures != nil {
+			if err := h.signatures.CheckChartDownload(metadata.Name, metadata.Version); err != nil {
+				return sendUnsignedChart(c, h.log, err)
+			}
+		}
+		if h.stats != nil {
+			h.stats.RecordPull(models.ArtifactTypeHelmChart, metadata.Name, metadata.Version, pullClient(c))
+		}
+	}
+	c.Set("Content-Type", "application/gzip")
+	return c.SendFile(chartPath)
+}
+
 func (h *HelmHandler) GetChart(c *fiber.Ctx) error {
 	chartName := c.Params("name")
 	version := c.Params("version")
@@ -159,6 +284,19 @@ func (h *HelmHandler) UploadChart(c *fiber.Ctx) error {
 	}
 
 	if err := h.service.SaveChart(chartData, file.Filename); err != nil {
+		var conflictErr *services.VersionConflictError
+		if errors.As(err, &conflictErr) {
+			h.log.WithFunc().WithError(err).Warn("Chart rejected by overwrite policy")
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": conflictErr.Error()})
+		}
+		var validationErr *services.ChartValidationError
+		if errors.As(err, &validationErr) {
+			h.log.WithFunc().WithError(err).Warn("Chart rejected by validation")
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error":    "Chart validation failed",
+				"findings": validationErr.Report.Findings,
+			})
+		}
 		h.log.WithFunc().WithError(err).Error("Failed to save chart")
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to save chart"})
 	}
@@ -182,6 +320,19 @@ func (h *HelmHandler) DownloadChart(c *fiber.Ctx) error {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to get chart"})
 	}
 
+	// Téléchargement par digest : le nom du fichier utilise la version réelle
+	if strings.HasPrefix(version, "sha256:") {
+		if metadata, err := h.service.ExtractChartMetadata(chart); err == nil {
+			version = metadata.Version
+		}
+	}
+	if h.signatures != nil {
+		if err := h.signatures.CheckChartDownload(name, version); err != nil {
+			return sendUnsignedChart(c, h.log, err)
+		}
+	}
+	h.stats.RecordPull(models.ArtifactTypeHelmChart, name, version, pullClient(c))
+
 	fileName := fmt.Sprintf("%s-%s.tgz", name, version)
 	c.Set("Content-Type", "application/gzip")
 	c.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
@@ -190,6 +341,16 @@ func (h *HelmHandler) DownloadChart(c *fiber.Ctx) error {
 	return c.Send(chart)
 }
 
+// sendUnsignedChart answers 403 to the download of a chart version refused by the signature policy
+func sendUnsignedChart(c *fiber.Ctx, log *utils.Logger, err error) error {
+	if errors.Is(err, services.ErrUnsignedManifest) {
+		log.WithFunc().WithError(err).Warn("Refusing download of unsigned chart")
+		return c.Status(403).JSON(fiber.Map{"error": "signature required", "detail": err.Error()})
+	}
+	log.WithFunc().WithError(err).Error("Failed to verify chart signature")
+	return c.Status(500).JSON(fiber.Map{"error": "Failed to verify chart signature"})
+}
+
 func (h *HelmHandler) DeleteChart(c *fiber.Ctx) error {
 	name := c.Params("name")
 	version := c.Params("version")
@@ -211,6 +372,299 @@ func (h *HelmHandler) DeleteChart(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"message": "Chart deleted successfully"})
 }
 
+// RenderChart renders the templates of a chart with its default values or the values sent in the body
+func (h *HelmHandler) RenderChart(c *fiber.Ctx) error {
+	name := c.Params("name")
+	version := c.Params("version")
+
+	h.log.WithFunc().WithFields(logrus.Fields{
+		"name":    name,
+		"version": version,
+	}).Debug("Processing chart render request")
+
+	var opts models.RenderOptions
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&opts); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid render request"})
+		}
+	}
+
+	if !h.service.ChartExists(name, version) {
+		return c.Status(404).JSON(fiber.Map{"error": "Chart not found"})
+	}
+
+	result, err := h.service.RenderChart(name, version, opts)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidValues) {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		h.log.WithFunc().WithError(err).Error("Failed to render chart")
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to render chart"})
+	}
+
+	return c.JSON(result)
+}
+
+// ListChartFiles returns the file tree of a chart archive
+func (h *HelmHandler) ListChartFiles(c *fiber.Ctx) error {
+	name := c.Params("name")
+	version := c.Params("version")
+
+	files, err := h.service.ListChartFiles(name, version)
+	if err != nil {
+		if errors.Is(err, services.ErrChartNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "Chart not found"})
+		}
+		h.log.WithFunc().WithError(err).Error("Failed to list chart files")
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to list chart files"})
+	}
+
+	return c.JSON(files)
+}
+
+// GetChartFile returns the content of a single file of a chart archive
+func (h *HelmHandler) GetChartFile(c *fiber.Ctx) error {
+	name := c.Params("name")
+	version := c.Params("version")
+	filePath := c.Params("*")
+
+	h.log.WithFunc().WithFields(logrus.Fields{
+		"name":    name,
+		"version": version,
+		"file":    filePath,
+	}).Debug("Processing chart file request")
+
+	file, err := h.service.GetChartFile(name, version, filePath)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrChartNotFound):
+			return c.Status(404).JSON(fiber.Map{"error": "Chart not found"})
+		case errors.Is(err, services.ErrFileNotFound):
+			return c.Status(404).JSON(fiber.Map{"error": "File not found"})
+		case errors.Is(err, services.ErrFileTooLarge):
+			return c.Status(413).JSON(fiber.Map{"error": err.Error()})
+		}
+		h.log.WithFunc().WithError(err).Error("Failed to read chart file")
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to read chart file"})
+	}
+
+	return c.JSON(file)
+}
+
+// CheckDependencies resolves the dependencies of a chart version
+func (h *HelmHandler) CheckDependencies(c *fiber.Ctx) error {
+	name := c.Params("name")
+	version := c.Params("version")
+
+	report, err := h.service.CheckDependencies(name, version)
+	if err != nil {
+		if errors.Is(err, services.ErrChartNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "Chart not found"})
+		}
+		h.log.WithFunc().WithError(err).Error("Failed to check chart dependencies")
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to check chart dependencies"})
+	}
+
+	return c.JSON(report)
+}
+
+// GetDependents lists the charts depending on a chart, optionally filtered by the "constraint" query parameter
+func (h *HelmHandler) GetDependents(c *fiber.Ctx) error {
+	name := c.Params("name")
+	constraint := c.Query("constraint")
+
+	dependents, err := h.service.GetDependents(name, constraint)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidConstraint) {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		h.log.WithFunc().WithError(err).Error("Failed to list chart dependents")
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to list chart dependents"})
+	}
+
+	return c.JSON(dependents)
+}
+
+// GetValuesSchema returns the values.schema.json of a chart
+func (h *HelmHandler) GetValuesSchema(c *fiber.Ctx) error {
+	name := c.Params("name")
+	version := c.Params("version")
+
+	schema, err := h.service.GetValuesSchema(name, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrChartNotFound):
+			return c.Status(404).JSON(fiber.Map{"error": "Chart not found"})
+		case errors.Is(err, services.ErrSchemaNotFound):
+			return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+		}
+		h.log.WithFunc().WithError(err).Error("Failed to read values schema")
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to read values schema"})
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return c.Send(schema)
+}
+
+// ValidateValues validates the values sent in the body against the chart schema
+func (h *HelmHandler) ValidateValues(c *fiber.Ctx) error {
+	name := c.Params("name")
+	version := c.Params("version")
+
+	h.log.WithFunc().WithFields(logrus.Fields{
+		"name":    name,
+		"version": version,
+	}).Debug("Processing values validation request")
+
+	var request struct {
+		Values string `json:"values"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&request); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid validation request"})
+		}
+	}
+
+	result, err := h.service.ValidateValues(name, version, request.Values)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrChartNotFound):
+			return c.Status(404).JSON(fiber.Map{"error": "Chart not found"})
+		case errors.Is(err, services.ErrInvalidValues):
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		h.log.WithFunc().WithError(err).Error("Failed to validate values")
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to validate values"})
+	}
+
+	if !result.Valid {
+		return c.Status(422).JSON(result)
+	}
+	return c.JSON(result)
+}
+
+// GetChartStatus returns the lifecycle state and history of a chart version
+func (h *HelmHandler) GetChartStatus(c *fiber.Ctx) error {
+	name := c.Params("name")
+	version := c.Params("version")
+
+	if !h.service.ChartExists(name, version) {
+		return c.Status(404).JSON(fiber.Map{"error": "Chart not found"})
+	}
+	status, err := h.service.GetChartStatus(name, version)
+	if err != nil {
+		h.log.WithFunc().WithError(err).Error("Failed to read chart status")
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to read chart status"})
+	}
+	return c.JSON(status)
+}
+
+// DeprecateChart marks a chart version as deprecated
+func (h *HelmHandler) DeprecateChart(c *fiber.Ctx) error {
+	return h.changeChartStatus(c, h.service.DeprecateChart)
+}
+
+// YankChart hides a chart version from the index and tag listings
+func (h *HelmHandler) YankChart(c *fiber.Ctx) error {
+	return h.changeChartStatus(c, h.service.YankChart)
+}
+
+// ReinstateChart clears the deprecated and yanked flags of a chart version
+func (h *HelmHandler) ReinstateChart(c *fiber.Ctx) error {
+	return h.changeChartStatus(c, h.service.ReinstateChart)
+}
+
+func (h *HelmHandler) changeChartStatus(c *fiber.Ctx, change func(name, version, reason, actor string) (*models.ChartStatus, error)) error {
+	name := c.Params("name")
+	version := c.Params("version")
+
+	var request struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	actor, _ := c.Locals(middleware.LocalUsername).(string)
+	if actor == "" {
+		actor = "anonymous"
+	}
+
+	h.log.WithFunc().WithFields(logrus.Fields{
+		"name":    name,
+		"version": version,
+		"actor":   actor,
+		"route":   c.Route().Path,
+	}).Debug("Processing chart lifecycle request")
+
+	status, err := change(name, version, request.Reason, actor)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrReasonRequired):
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, services.ErrChartNotFound):
+			return c.Status(404).JSON(fiber.Map{"error": "Chart not found"})
+		}
+		h.log.WithFunc().WithError(err).Error("Failed to update chart status")
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update chart status"})
+	}
+
+	return c.JSON(status)
+}
+
+// PackageChart packages an unpackaged chart directory sent as a tar, tar.gz or zip archive
+// and stores the result like an upload. The response carries the .tgz and the validation findings.
+func (h *HelmHandler) PackageChart(c *fiber.Ctx) error {
+	h.log.WithFunc().Debug("Processing chart packaging request")
+
+	file, err := c.FormFile("source")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "No chart source archive provided"})
+	}
+
+	fileContent, err := file.Open()
+	if err != nil {
+		h.log.WithFunc().WithError(err).Error("Failed to open uploaded file")
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to process file"})
+	}
+	defer fileContent.Close()
+
+	source, err := io.ReadAll(fileContent)
+	if err != nil {
+		h.log.WithFunc().WithError(err).Error("Failed to read file content")
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to read file"})
+	}
+
+	result, err := h.service.PackageChart(source, c.FormValue("version"))
+	if err != nil {
+		var conflictErr *services.VersionConflictError
+		var validationErr *services.ChartValidationError
+		switch {
+		case errors.Is(err, services.ErrInvalidPackageSource):
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, services.ErrDependencyUnresolved):
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		case errors.As(err, &conflictErr):
+			h.log.WithFunc().WithError(err).Warn("Packaged chart rejected by overwrite policy")
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": conflictErr.Error()})
+		case errors.As(err, &validationErr):
+			h.log.WithFunc().WithError(err).Warn("Packaged chart rejected by validation")
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error":    "Chart validation failed",
+				"findings": validationErr.Report.Findings,
+			})
+		}
+		h.log.WithFunc().WithError(err).Error("Failed to package chart")
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to package chart"})
+	}
+
+	h.log.WithFunc().WithFields(logrus.Fields{
+		"name":    result.Name,
+		"version": result.Version,
+	}).Info("Chart packaged successfully")
+	return c.Status(fiber.StatusCreated).JSON(result)
+}
+
 func (h *HelmHandler) DisplayHome(c *fiber.Ctx) error {
 	h.log.WithFunc().Debug("Processing home page request")
 
@@ -259,6 +713,45 @@ func (h *HelmHandler) DisplayChartDetails(c *fiber.Ctx) error {
 		"Type":         chart.Type,
 		"Dependencies": chart.Dependencies,
 		"Values":       valuesContent,
+		"Deprecated":   chart.Deprecated,
+		"Yanked":       chart.Yanked,
+	}
+
+	// Other versions are offered in the "Compare with" selector
+	var otherVersions []string
+	if versions, err := h.service.GetChartVersions(name); err == nil {
+		for _, v := range versions {
+			if v.Version != version {
+				otherVersions = append(otherVersions, v.Version)
+			}
+		}
+	}
+	chartDetails["OtherVersions"] = otherVersions
+
+	// Résolution des dépendances et charts qui dépendent de cette version
+	if report, err := h.service.CheckDependencies(name, version); err == nil {
+		chartDetails["DependencyChecks"] = report.Dependencies
+	} else {
+		h.log.WithFunc().WithError(err).Warn("Failed to check dependencies")
+	}
+	if dependents, err := h.service.GetDependents(name, version); err == nil {
+		chartDetails["Dependents"] = dependents
+	}
+	if _, err := h.service.GetValuesSchema(name, version); err == nil {
+		chartDetails["HasSchema"] = true
+	}
+	if status, err := h.service.GetChartStatus(name, version); err == nil {
+		chartDetails["Status"] = status
+		chartDetails["Deprecation"] = status.Last(models.LifecycleDeprecate)
+		chartDetails["Yank"] = status.Last(models.LifecycleYank)
+	}
+	if h.stats != nil {
+		chartDetails["Pulls"] = h.stats.Get(models.ArtifactTypeHelmChart, name, version)
+	}
+	if h.signatures != nil {
+		if signature, err := h.signatures.ChartStatus(name, version); err == nil {
+			chartDetails["Signature"] = signature
+		}
 	}
 
 	return c.Render("details", fiber.Map{
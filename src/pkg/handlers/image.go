@@ -2,8 +2,14 @@
 package handlers
 
 import (
+	"errors"
+	"net/url"
+	"path"
+	"strings"
+
 	"helm-portal/pkg/interfaces"
 	"helm-portal/pkg/models"
+	services "helm-portal/pkg/services"
 	"helm-portal/pkg/utils"
 
 	"github.com/gofiber/fiber/v2"
@@ -15,6 +21,8 @@ type ImageHandler struct {
 	log         *utils.Logger
 	service     interfaces.ImageServiceInterface
 	pathManager *utils.PathManager
+	scans       *services.ScanService
+	signatures  *services.SignatureService
 }
 
 // NewImageHandler creates a new image handler
@@ -26,8 +34,23 @@ func NewImageHandler(service interfaces.ImageServiceInterface, pathManager *util
 	}
 }
 
-// ListImages returns all Docker images as JSON
+// WithScanner shows the vulnerability scans of the images in the web interface
+func (h *ImageHandler) WithScanner(scans *services.ScanService) *ImageHandler {
+	h.scans = scans
+	return h
+}
+
+// WithSignatures shows the signature status of the images in the web interface
+func (h *ImageHandler) WithSignatures(signatures *services.SignatureService) *ImageHandler {
+	h.signatures = signatures
+	return h
+}
+
+// ListImages returns all Docker images as JSON, or the images page to browsers
 func (h *ImageHandler) ListImages(c *fiber.Ctx) error {
+	if !wantsJSON(c) {
+		return h.DisplayImagesHome(c)
+	}
 	h.log.WithFunc().Debug("Listing Docker images")
 
 	images, err := h.service.ListImages()
@@ -41,9 +64,42 @@ func (h *ImageHandler) ListImages(c *fiber.Ctx) error {
 	})
 }
 
+// imageName returns the image name held by the wildcard of a route: it may have several segments
+// (library/nginx) and comes encoded from the links of the web interface (library%2Fnginx)
+func imageName(c *fiber.Ctx) (string, bool) {
+	name, err := url.PathUnescape(c.Params("*1"))
+	if err != nil || name == "" {
+		return "", false
+	}
+	for _, segment := range strings.Split(name, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return "", false
+		}
+	}
+	return name, true
+}
+
+// imageReference splits the wildcard of a route on its last segment: the image name, then the tag or digest
+func imageReference(c *fiber.Ctx) (string, string, bool) {
+	reference, ok := imageName(c)
+	i := strings.LastIndex(reference, "/")
+	if !ok || i < 0 {
+		return "", "", false
+	}
+	return reference[:i], reference[i+1:], true
+}
+
+// sendImageNotFound answers the routes whose image name or reference is malformed
+func sendImageNotFound(c *fiber.Ctx) error {
+	return c.Status(404).JSON(fiber.Map{"error": "Image not found"})
+}
+
 // GetImageTags returns all tags for a specific image
 func (h *ImageHandler) GetImageTags(c *fiber.Ctx) error {
-	name := c.Params("name")
+	name, ok := imageName(c)
+	if !ok {
+		return sendImageNotFound(c)
+	}
 
 	h.log.WithFunc().WithField("name", name).Debug("Getting image tags")
 
@@ -76,8 +132,10 @@ func (h *ImageHandler) GetImageTags(c *fiber.Ctx) error {
 
 // DisplayImageDetails displays details for a specific image tag
 func (h *ImageHandler) DisplayImageDetails(c *fiber.Ctx) error {
-	name := c.Params("name")
-	tag := c.Params("tag")
+	name, tag, ok := imageReference(c)
+	if !ok {
+		return sendImageNotFound(c)
+	}
 
 	h.log.WithFunc().WithFields(logrus.Fields{
 		"name": name,
@@ -97,23 +155,124 @@ func (h *ImageHandler) DisplayImageDetails(c *fiber.Ctx) error {
 	}
 
 	// Check Accept header for JSON vs HTML
-	if c.Accepts("application/json") != "" {
+	if wantsJSON(c) {
 		return c.JSON(metadata)
 	}
 
 	// Return HTML view
+	separator := ":"
+	if strings.HasPrefix(tag, "sha256:") {
+		separator = "@"
+	}
+	image := c.Hostname() + "/" + name
+	pullByDigest := ""
+	if metadata.Digest != "" {
+		pullByDigest = "docker pull " + image + "@" + metadata.Digest
+	}
+	var scan *models.ScanReport
+	if h.scans != nil {
+		scan, _ = h.scans.GetReport(name, tag)
+	}
+	var signature *models.SignatureStatus
+	if h.signatures != nil {
+		signature, _ = h.signatures.ImageStatus(name, tag)
+	}
 	return c.Render("image_details", fiber.Map{
-		"Title": name + ":" + tag,
-		"Image": metadata,
-		"Name":  name,
-		"Tag":   tag,
+		"Title":        name + separator + tag,
+		"Image":        metadata,
+		"Name":         name,
+		"Tag":          tag,
+		"PullCommand":  "docker pull " + image + separator + tag,
+		"PullByDigest": pullByDigest,
+		"Scanning":     h.scans != nil,
+		"Scan":         scan,
+		"Signature":    signature,
 	})
 }
 
+// ListLayerFiles lists the files of a layer blob (tar, tar+gzip or tar+zstd) of the named image
+func (h *ImageHandler) ListLayerFiles(c *fiber.Ctx) error {
+	name, ok := imageName(c)
+	digest := c.Params("digest")
+	if !ok || !h.service.HasLayer(name, digest) {
+		return c.Status(404).JSON(fiber.Map{"error": services.ErrLayerNotFound.Error()})
+	}
+
+	listing, err := h.service.ListLayerFiles(digest)
+	if err != nil {
+		return h.layerError(c, err)
+	}
+	return c.JSON(listing)
+}
+
+// DownloadLayerFile streams a single regular file of a layer blob of the named image
+func (h *ImageHandler) DownloadLayerFile(c *fiber.Ctx) error {
+	name, ok := imageName(c)
+	digest := c.Params("digest")
+	filePath := c.Params("*2")
+
+	h.log.WithFunc().WithFields(logrus.Fields{
+		"name":   name,
+		"digest": digest,
+		"file":   filePath,
+	}).Debug("Downloading layer file")
+
+	if !ok || !h.service.HasLayer(name, digest) {
+		return c.Status(404).JSON(fiber.Map{"error": services.ErrLayerNotFound.Error()})
+	}
+
+	entry, content, err := h.service.OpenLayerFile(digest, filePath)
+	if err != nil {
+		return h.layerError(c, err)
+	}
+
+	c.Set("Content-Type", "application/octet-stream")
+	c.Attachment(path.Base(entry.Path))
+	return c.SendStream(content, int(entry.Size))
+}
+
+// GetFilesystem returns the filesystem of an image tag with all its layers applied
+func (h *ImageHandler) GetFilesystem(c *fiber.Ctx) error {
+	name, tag, ok := imageReference(c)
+	if !ok {
+		return sendImageNotFound(c)
+	}
+
+	if !h.service.ImageExists(name, tag) {
+		return c.Status(404).JSON(fiber.Map{"error": "Image not found"})
+	}
+	fs, err := h.service.MergedFilesystem(name, tag)
+	if err != nil {
+		return h.layerError(c, err)
+	}
+	return c.JSON(fs)
+}
+
+// layerError maps the layer browser errors to HTTP statuses
+func (h *ImageHandler) layerError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrLayerNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrLayerFileNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "File not found"})
+	case errors.Is(err, services.ErrInvalidLayer), errors.Is(err, services.ErrNotRegularFile), errors.Is(err, services.ErrImageIsIndex):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	h.log.WithFunc().WithError(err).Error("Failed to read layer")
+	return c.Status(500).JSON(fiber.Map{"error": "Failed to read layer"})
+}
+
+// wantsJSON reports whether the client prefers JSON over HTML (API clients, fetch)
+func wantsJSON(c *fiber.Ctx) bool {
+	return c.Accepts("application/json", "text/html") == "application/json"
+}
+
 // DeleteImage deletes a Docker image by name and tag
 func (h *ImageHandler) DeleteImage(c *fiber.Ctx) error {
-	name := c.Params("name")
-	tag := c.Params("tag")
+	name, tag, ok := imageReference(c)
+	if !ok {
+		return sendImageNotFound(c)
+	}
 
 	h.log.WithFunc().WithFields(logrus.Fields{
 		"name": name,
@@ -145,8 +304,37 @@ func (h *ImageHandler) DisplayImagesHome(c *fiber.Ctx) error {
 		})
 	}
 
+	// Résumé des vulnérabilités par "name:tag", absent si le tag n'a pas été analysé
+	scans := make(map[string]*models.VulnerabilitySummary)
+	if h.scans != nil {
+		for _, image := range images {
+			for _, tag := range image.Tags {
+				if summary := h.scans.Summary(image.Name, tag.Tag); summary != nil {
+					scans[image.Name+":"+tag.Tag] = summary
+				}
+			}
+		}
+	}
+
+	// Statut des signatures par "name:tag"
+	signatures := make(map[string]string)
+	if h.signatures != nil {
+		for _, image := range images {
+			for _, tag := range image.Tags {
+				if tag.Digest != "" {
+					signatures[image.Name+":"+tag.Tag] = h.signatures.Verify(image.Name, tag.Digest).Status
+				}
+			}
+		}
+	}
+
 	return c.Render("images", fiber.Map{
-		"Title":  "Docker Images",
-		"Images": images,
+		"Title":      "Docker Images",
+		"Images":     images,
+		"Registry":   c.Hostname(),
+		"Scanning":   h.scans != nil,
+		"Scans":      scans,
+		"Signing":    h.signatures != nil,
+		"Signatures": signatures,
 	})
 }
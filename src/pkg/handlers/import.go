@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"errors"
+
+	"helm-portal/pkg/models"
+	services "helm-portal/pkg/services"
+	utils "helm-portal/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// ImportHandler handles bulk imports from existing Helm repositories
+type ImportHandler struct {
+	log     *utils.Logger
+	service *services.ImportService
+}
+
+// NewImportHandler creates a new import handler
+func NewImportHandler(service *services.ImportService, log *utils.Logger) *ImportHandler {
+	return &ImportHandler{
+		service: service,
+		log:     log,
+	}
+}
+
+// StartImport starts an import job in the background; its progress is served by GetImport
+func (h *ImportHandler) StartImport(c *fiber.Ctx) error {
+	var request models.ImportRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid import request"})
+	}
+
+	job, err := h.service.StartImport(request)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidImportRequest):
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, services.ErrImportDirectoryForbidden):
+			return c.Status(403).JSON(fiber.Map{"error": services.ErrImportDirectoryForbidden.Error()})
+		case errors.Is(err, services.ErrImportRunning):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		}
+		h.log.WithFunc().WithError(err).Error("Failed to start import")
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to start import"})
+	}
+
+	h.log.WithFunc().WithFields(logrus.Fields{
+		"job":    job.ID,
+		"source": job.Source,
+		"dryRun": job.DryRun,
+	}).Info("Import started")
+	c.Location("/api/import/" + job.ID)
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
+
+// GetImport returns the progress of an import job
+func (h *ImportHandler) GetImport(c *fiber.Ctx) error {
+	job, err := h.service.GetJob(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Import job not found"})
+	}
+	return c.JSON(job)
+}
+
+// ListImports returns the import jobs, most recent first
+func (h *ImportHandler) ListImports(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"imports": h.service.ListJobs()})
+}
@@ -57,3 +57,83 @@ func (m *MockChartService) ExtractChartMetadata(chartData []byte) (*models.Chart
 	args := m.Called(chartData)
 	return args.Get(0).(*models.ChartMetadata), args.Error(1)
 }
+
+func (m *MockChartService) GetChartVersions(name string) ([]models.ChartMetadata, error) {
+	args := m.Called(name)
+	return args.Get(0).([]models.ChartMetadata), args.Error(1)
+}
+
+func (m *MockChartService) ResolveChartVersion(name, constraint string) (*models.ChartMetadata, error) {
+	args := m.Called(name, constraint)
+	return args.Get(0).(*models.ChartMetadata), args.Error(1)
+}
+
+func (m *MockChartService) RenderChart(name, version string, opts models.RenderOptions) (*models.RenderResult, error) {
+	args := m.Called(name, version, opts)
+	return args.Get(0).(*models.RenderResult), args.Error(1)
+}
+
+func (m *MockChartService) DiffChartVersions(name, from, to string) (*models.ChartDiff, error) {
+	args := m.Called(name, from, to)
+	return args.Get(0).(*models.ChartDiff), args.Error(1)
+}
+
+func (m *MockChartService) ListChartFiles(name, version string) ([]models.ChartFile, error) {
+	args := m.Called(name, version)
+	return args.Get(0).([]models.ChartFile), args.Error(1)
+}
+
+func (m *MockChartService) GetChartFile(name, version, filePath string) (*models.ChartFileContent, error) {
+	args := m.Called(name, version, filePath)
+	return args.Get(0).(*models.ChartFileContent), args.Error(1)
+}
+
+func (m *MockChartService) CheckDependencies(name, version string) (*models.DependencyReport, error) {
+	args := m.Called(name, version)
+	return args.Get(0).(*models.DependencyReport), args.Error(1)
+}
+
+func (m *MockChartService) GetDependents(name, constraint string) ([]models.Dependent, error) {
+	args := m.Called(name, constraint)
+	return args.Get(0).([]models.Dependent), args.Error(1)
+}
+
+func (m *MockChartService) GetValuesSchema(name, version string) ([]byte, error) {
+	args := m.Called(name, version)
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (m *MockChartService) ValidateValues(name, version, values string) (*models.ValuesValidationResult, error) {
+	args := m.Called(name, version, values)
+	return args.Get(0).(*models.ValuesValidationResult), args.Error(1)
+}
+
+func (m *MockChartService) GetChartStatus(name, version string) (*models.ChartStatus, error) {
+	args := m.Called(name, version)
+	return args.Get(0).(*models.ChartStatus), args.Error(1)
+}
+
+func (m *MockChartService) DeprecateChart(name, version, reason, actor string) (*models.ChartStatus, error) {
+	args := m.Called(name, version, reason, actor)
+	return args.Get(0).(*models.ChartStatus), args.Error(1)
+}
+
+func (m *MockChartService) YankChart(name, version, reason, actor string) (*models.ChartStatus, error) {
+	args := m.Called(name, version, reason, actor)
+	return args.Get(0).(*models.ChartStatus), args.Error(1)
+}
+
+func (m *MockChartService) ReinstateChart(name, version, reason, actor string) (*models.ChartStatus, error) {
+	args := m.Called(name, version, reason, actor)
+	return args.Get(0).(*models.ChartStatus), args.Error(1)
+}
+
+func (m *MockChartService) PackageChart(source []byte, version string) (*models.PackageResult, error) {
+	args := m.Called(source, version)
+	return args.Get(0).(*models.PackageResult), args.Error(1)
+}
+
+func (m *MockChartService) SaveProvenance(filename string, data []byte) error {
+	args := m.Called(filename, data)
+	return args.Error(0)
+}
@@ -1,14 +1,19 @@
 package handlers
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
+	cfg "helm-portal/config"
 	interfaces "helm-portal/pkg/interfaces"
 	"helm-portal/pkg/models"
+	services "helm-portal/pkg/services"
 	utils "helm-portal/pkg/utils"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
@@ -21,15 +26,84 @@ type OCIHandler struct {
 	chartService interfaces.ChartServiceInterface
 	imageService interfaces.ImageServiceInterface
 	pathManager  *utils.PathManager
+	policy       *services.OverwritePolicy
+	// namespace préfixe les noms OCI des dépôts isolés (oci://<host>/<namespace>/<name>)
+	namespace  string
+	stats      *services.StatsService
+	signatures *services.SignatureService
+	proxy      *services.ProxyService
 }
 
-func NewOCIHandler(chartService interfaces.ChartServiceInterface, imageService interfaces.ImageServiceInterface, log *utils.Logger) *OCIHandler {
+func NewOCIHandler(chartService interfaces.ChartServiceInterface, imageService interfaces.ImageServiceInterface, config *cfg.Config, log *utils.Logger) *OCIHandler {
+	return NewRepositoryOCIHandler("", chartService, imageService, config, log)
+}
+
+// NewRepositoryOCIHandler creates an OCI handler serving the artifacts of a repository under /v2/<namespace>/
+func NewRepositoryOCIHandler(namespace string, chartService interfaces.ChartServiceInterface, imageService interfaces.ImageServiceInterface, config *cfg.Config, log *utils.Logger) *OCIHandler {
 	return &OCIHandler{
 		chartService: chartService,
 		imageService: imageService,
 		log:          log,
 		pathManager:  chartService.GetPathManager(),
+		policy:       services.NewOverwritePolicy(config, log),
+		namespace:    namespace,
+	}
+}
+
+// WithStats counts the manifest and blob pulls in stats
+func (h *OCIHandler) WithStats(stats *services.StatsService) *OCIHandler {
+	h.stats = stats
+	return h
+}
+
+// WithSignatures refuses the pulls of manifests without verified signature in the enforced repositories
+func (h *OCIHandler) WithSignatures(signatures *services.SignatureService) *OCIHandler {
+	h.signatures = signatures
+	return h
+}
+
+// WithProxy fetches the manifests and blobs of proxied repositories missing from the cache from their upstream
+func (h *OCIHandler) WithProxy(proxy *services.ProxyService) *OCIHandler {
+	h.proxy = proxy
+	return h
+}
+
+// localName maps the repository requested by a containerd registry mirror (?ns=docker.io) to its cached name
+func (h *OCIHandler) localName(c *fiber.Ctx, name string) string {
+	if h.proxy == nil || c.Query("ns") == "" {
+		return name
+	}
+	if local, ok := h.proxy.MirrorName(c.Query("ns"), name); ok {
+		return local
 	}
+	return name
+}
+
+// sendUpstreamError answers a request whose content could not be fetched from the upstream of the pull-through cache
+func (h *OCIHandler) sendUpstreamError(c *fiber.Ctx, err error) error {
+	h.log.WithFunc().WithError(err).Warn("Failed to fetch from upstream")
+	return sendOCIError(c, fiber.StatusServiceUnavailable, "UNAVAILABLE", "upstream registry unavailable", err.Error())
+}
+
+// repositoryName returns the full OCI name of an artifact, including the namespace of the repository
+func (h *OCIHandler) repositoryName(name string) string {
+	if h.namespace == "" {
+		return name
+	}
+	return h.namespace + "/" + name
+}
+
+// sendOCIError writes an error response using the OCI distribution spec format
+func sendOCIError(c *fiber.Ctx, status int, code, message string, detail interface{}) error {
+	return c.Status(status).JSON(fiber.Map{
+		"errors": []fiber.Map{
+			{
+				"code":    code,
+				"message": message,
+				"detail":  detail,
+			},
+		},
+	})
 }
 
 func (h *OCIHandler) HandleOCIAPI(c *fiber.Ctx) error {
@@ -42,17 +116,24 @@ func (h *OCIHandler) HandleOCIAPI(c *fiber.Ctx) error {
 }
 
 func (h *OCIHandler) GetBlob(c *fiber.Ctx) error {
-	digest := c.Params("digest")
-	name := c.Params("name")
+	return h.serveBlob(c, h.localName(c, c.Params("name")), c.Params("digest"))
+}
 
+func (h *OCIHandler) serveBlob(c *fiber.Ctx, name, digest string) error {
 	h.log.WithFunc().WithFields(logrus.Fields{
 		"chart":  name,
 		"digest": digest,
 	}).Debug("Processing blob download request")
 
+	if h.proxy != nil {
+		if err := h.proxy.EnsureBlob(name, digest); err != nil && !errors.Is(err, services.ErrUpstreamNotFound) {
+			return h.sendUpstreamError(c, err)
+		}
+	}
+
 	blobData, err := h.getBlobByDigest(digest)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if errors.Is(err, os.ErrNotExist) {
 			h.log.WithFunc().WithError(err).Debug("Blob not found")
 			return c.SendStatus(404)
 		}
@@ -60,6 +141,7 @@ func (h *OCIHandler) GetBlob(c *fiber.Ctx) error {
 		return c.SendStatus(500)
 	}
 
+	h.stats.RecordBlobPull(name, int64(len(blobData)))
 	c.Set("Docker-Content-Digest", digest)
 	c.Set("Content-Type", "application/octet-stream")
 	return c.Send(blobData)
@@ -76,7 +158,7 @@ func (h *OCIHandler) HandleCatalog(c *fiber.Ctx) error {
 		h.log.WithFunc().WithError(err).Warn("Failed to list charts")
 	} else {
 		for _, chart := range charts {
-			repositories = append(repositories, chart.Name)
+			repositories = append(repositories, h.repositoryName(chart.Name))
 		}
 	}
 
@@ -90,13 +172,13 @@ func (h *OCIHandler) HandleCatalog(c *fiber.Ctx) error {
 				// Avoid duplicates
 				found := false
 				for _, r := range repositories {
-					if r == image.Name {
+					if r == h.repositoryName(image.Name) {
 						found = true
 						break
 					}
 				}
 				if !found {
-					repositories = append(repositories, image.Name)
+					repositories = append(repositories, h.repositoryName(image.Name))
 				}
 			}
 		}
@@ -109,10 +191,21 @@ func (h *OCIHandler) HandleCatalog(c *fiber.Ctx) error {
 
 // HandleListTags returns all tags for a repository (OCI Distribution Spec)
 func (h *OCIHandler) HandleListTags(c *fiber.Ctx) error {
-	name := c.Params("name")
+	return h.listTags(c, h.localName(c, c.Params("name")))
+}
 
+func (h *OCIHandler) listTags(c *fiber.Ctx, name string) error {
 	h.log.WithFunc().WithField("name", name).Debug("Processing tags list request")
 
+	// Dépôt en cache : les tags de l'amont, ou ceux en cache s'il est injoignable
+	if h.proxy != nil && h.proxy.IsProxied(name) {
+		tags, err := h.proxy.ListTags(name)
+		if err == nil {
+			return c.JSON(fiber.Map{"name": name, "tags": tags})
+		}
+		h.log.WithFunc().WithError(err).Warn("Failed to list upstream tags, listing cached tags")
+	}
+
 	tags := make([]string, 0)
 
 	// Try to get tags from image service first
@@ -123,39 +216,69 @@ func (h *OCIHandler) HandleListTags(c *fiber.Ctx) error {
 		}
 	}
 
-	// Also check for chart versions as tags
-	charts, err := h.chartService.ListCharts()
+	// Also check for chart versions as tags, in ascending semver order
+	versions, err := h.chartService.GetChartVersions(name)
 	if err == nil {
-		for _, chart := range charts {
-			if chart.Name == name {
-				for _, version := range chart.Versions {
-					tags = append(tags, version.Version)
-				}
-				break
-			}
+		for i := len(versions) - 1; i >= 0; i-- {
+			tags = append(tags, versions[i].Version)
 		}
 	}
 
 	return c.JSON(fiber.Map{
-		"name": name,
+		"name": h.repositoryName(name),
 		"tags": tags,
 	})
 }
 
+// proxyPathPattern splits the path of a request on a multi-segment repository name (dockerhub/library/nginx)
+var proxyPathPattern = regexp.MustCompile(`^(.+)/(manifests|blobs)/([^/]+)$|^(.+)/tags/list$`)
+
+// HandleProxy serves the pulls of multi-segment repository names, those of the pull-through cache
+// (/v2/dockerhub/library/nginx/manifests/1.25) or of a containerd mirror (/v2/library/nginx/...?ns=docker.io)
+func (h *OCIHandler) HandleProxy(c *fiber.Ctx) error {
+	match := proxyPathPattern.FindStringSubmatch(c.Params("*"))
+	if match == nil || h.proxy == nil {
+		return sendOCIError(c, fiber.StatusNotFound, "NAME_UNKNOWN", "repository name not known to registry", c.Params("*"))
+	}
+
+	if match[4] != "" {
+		return h.listTags(c, h.localName(c, match[4]))
+	}
+	name := h.localName(c, match[1])
+	if !h.proxy.IsProxied(name) {
+		return sendOCIError(c, fiber.StatusNotFound, "NAME_UNKNOWN", "repository name not known to registry", name)
+	}
+	switch {
+	case match[2] == "manifests":
+		return h.serveManifest(c, name, match[3])
+	case c.Method() == fiber.MethodHead:
+		return h.headBlob(c, name, match[3])
+	default:
+		return h.serveBlob(c, name, match[3])
+	}
+}
+
 func fileExists(path string) bool {
 	_, err := os.Stat(path)
 	return err == nil
 }
 
 func (h *OCIHandler) HandleManifest(c *fiber.Ctx) error {
-	name := c.Params("name")
-	reference := c.Params("reference")
+	return h.serveManifest(c, h.localName(c, c.Params("name")), c.Params("reference"))
+}
 
+func (h *OCIHandler) serveManifest(c *fiber.Ctx, name, reference string) error {
 	h.log.WithFunc().WithFields(logrus.Fields{
 		"name":      name,
 		"reference": reference,
 	}).Debug("Processing manifest request")
 
+	if h.proxy != nil {
+		if err := h.proxy.EnsureManifest(name, reference); err != nil && !errors.Is(err, services.ErrUpstreamNotFound) {
+			return h.sendUpstreamError(c, err)
+		}
+	}
+
 	// Try to find manifest in multiple locations
 	manifestData, manifestPath, err := h.findManifest(name, reference)
 	if err != nil {
@@ -167,10 +290,17 @@ func (h *OCIHandler) HandleManifest(c *fiber.Ctx) error {
 		"manifestPath": manifestPath,
 	}).Debug("Found manifest")
 
+	if h.signatures != nil && h.signatures.Enforced(h.repositoryName(name)) {
+		if err := h.signatures.CheckPull(name, reference, manifestData); err != nil {
+			h.log.WithFunc().WithError(err).Warn("Refusing pull of unsigned manifest")
+			return sendOCIError(c, fiber.StatusForbidden, "DENIED", "signature required", err.Error())
+		}
+	}
+
 	// For HEAD requests, just verify existence
 	if c.Method() == "HEAD" {
 		digest := fmt.Sprintf("sha256:%x", sha256.Sum256(manifestData))
-		c.Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+		c.Set("Content-Type", manifestMediaType(manifestPath, manifestData))
 		c.Set("Docker-Content-Digest", digest)
 		c.Set("Content-Length", fmt.Sprintf("%d", len(manifestData)))
 		return c.SendStatus(200)
@@ -188,13 +318,14 @@ func (h *OCIHandler) HandleManifest(c *fiber.Ctx) error {
 		}
 	}
 
-	// Determine content type from manifest
-	var manifest models.OCIManifest
-	if err := json.Unmarshal(manifestData, &manifest); err == nil && manifest.MediaType != "" {
-		c.Set("Content-Type", manifest.MediaType)
-	} else {
-		c.Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+	c.Set("Content-Type", manifestMediaType(manifestPath, manifestData))
+
+	// Les manifests de charts sont sous manifests/, ceux des images sous images/
+	artifactType := models.ArtifactTypeDockerImage
+	if strings.HasPrefix(manifestPath, filepath.Join(h.pathManager.GetBasePath(), "manifests")+string(filepath.Separator)) {
+		artifactType = models.ArtifactTypeHelmChart
 	}
+	h.stats.RecordPull(artifactType, name, reference, pullClient(c))
 
 	c.Set("Docker-Content-Digest", fmt.Sprintf("sha256:%x", sha256.Sum256(manifestData)))
 	return c.Send(manifestData)
@@ -323,7 +454,7 @@ func (h *OCIHandler) PostUpload(c *fiber.Ctx) error {
 		"uuid": uuid,
 	}).Debug("Initializing upload")
 
-	location := fmt.Sprintf("/v2/%s/blobs/uploads/%s", name, uuid)
+	location := fmt.Sprintf("/v2/%s/blobs/uploads/%s", h.repositoryName(name), uuid)
 	c.Set("Location", location)
 	c.Set("Docker-Upload-UUID", uuid)
 	return c.SendStatus(202)
@@ -376,6 +507,11 @@ func (h *OCIHandler) CompleteUpload(c *fiber.Ctx) error {
 	}).Debug("Completing upload")
 
 	if len(c.Body()) > 0 {
+		// Un upload monolithique (POST puis PUT) n'est pas passé par PATCH
+		if err := os.MkdirAll(filepath.Dir(tempPath), 0755); err != nil {
+			h.log.WithFunc().WithError(err).Error("Failed to create temp directory")
+			return c.SendStatus(500)
+		}
 		if err := os.WriteFile(tempPath, c.Body(), 0644); err != nil {
 			h.log.WithFunc().WithError(err).Error("Failed to write final data")
 			return c.SendStatus(500)
@@ -393,8 +529,15 @@ func (h *OCIHandler) CompleteUpload(c *fiber.Ctx) error {
 }
 
 func (h *OCIHandler) HeadBlob(c *fiber.Ctx) error {
-	digest := c.Params("digest")
-	name := c.Params("name")
+	return h.headBlob(c, h.localName(c, c.Params("name")), c.Params("digest"))
+}
+
+func (h *OCIHandler) headBlob(c *fiber.Ctx, name, digest string) error {
+	if h.proxy != nil {
+		if err := h.proxy.EnsureBlob(name, digest); err != nil && !errors.Is(err, services.ErrUpstreamNotFound) {
+			return h.sendUpstreamError(c, err)
+		}
+	}
 	blobPath := h.pathManager.GetBlobPath(digest)
 
 	h.log.WithFunc().WithFields(logrus.Fields{
@@ -440,8 +583,12 @@ func (h *OCIHandler) PutManifest(c *fiber.Ctx) error {
 		return c.SendStatus(500)
 	}
 
-	// Detect artifact type
+	// Detect artifact type; multi-platform indexes are stored with the images
+	mediaType := models.ManifestMediaType(c.Body(), c.Get("Content-Type"))
 	artifactType := models.DetectArtifactType(&manifest)
+	if models.IsIndexMediaType(mediaType) {
+		artifactType = models.ArtifactTypeDockerImage
+	}
 
 	h.log.WithFunc().WithFields(logrus.Fields{
 		"name":         name,
@@ -456,21 +603,41 @@ func (h *OCIHandler) PutManifest(c *fiber.Ctx) error {
 
 	switch artifactType {
 	case models.ArtifactTypeHelmChart:
+		// Le manifest est écrit avant le chart : les abonnés au push (SBOM) le trouvent déjà
+		manifestPath := h.pathManager.GetManifestPath(name, reference)
+		restore, err := h.replaceManifestFile(manifestPath, manifestData, mediaType)
+		if err != nil {
+			return c.SendStatus(500)
+		}
 		// Handle Helm chart
 		if err := h.handleHelmChartManifest(name, reference, &manifest); err != nil {
+			restore()
+			var conflictErr *services.VersionConflictError
+			if errors.As(err, &conflictErr) {
+				h.log.WithFunc().WithError(err).Warn("Chart push rejected by overwrite policy")
+				return sendOCIError(c, fiber.StatusConflict, "DENIED", conflictErr.Error(), nil)
+			}
+			var validationErr *services.ChartValidationError
+			if errors.As(err, &validationErr) {
+				h.log.WithFunc().WithError(err).Warn("Helm chart rejected by validation")
+				return sendOCIError(c, fiber.StatusBadRequest, "MANIFEST_INVALID", "chart validation failed", validationErr.Report.Findings)
+			}
 			h.log.WithFunc().WithError(err).Error("Failed to handle Helm chart")
 			return c.SendStatus(500)
 		}
-		// Save manifest to Helm manifests directory
-		manifestPath := h.pathManager.GetManifestPath(name, reference)
-		if err := h.saveManifestFile(manifestPath, manifestData); err != nil {
-			return c.SendStatus(500)
-		}
 
 	case models.ArtifactTypeDockerImage:
 		// Handle Docker image
 		if h.imageService != nil {
-			if err := h.imageService.SaveImage(name, reference, &manifest); err != nil {
+			if err := h.imageService.SaveImage(name, reference, manifestData, mediaType); err != nil {
+				var conflictErr *services.VersionConflictError
+				if errors.As(err, &conflictErr) {
+					h.log.WithFunc().WithError(err).Warn("Image push rejected by overwrite policy")
+					return sendOCIError(c, fiber.StatusConflict, "DENIED", conflictErr.Error(), nil)
+				}
+				if errors.Is(err, services.ErrManifestDigestMismatch) {
+					return sendOCIError(c, fiber.StatusBadRequest, "DIGEST_INVALID", err.Error(), nil)
+				}
 				h.log.WithFunc().WithError(err).Error("Failed to save Docker image")
 				return c.SendStatus(500)
 			}
@@ -478,7 +645,7 @@ func (h *OCIHandler) PutManifest(c *fiber.Ctx) error {
 			h.log.WithFunc().Warn("Image service not configured, saving manifest only")
 			// Fall back to saving manifest in images directory
 			manifestPath := h.pathManager.GetImageManifestPath(name, reference)
-			if err := h.saveManifestFile(manifestPath, manifestData); err != nil {
+			if err := h.saveManifestFile(manifestPath, manifestData, mediaType); err != nil {
 				return c.SendStatus(500)
 			}
 		}
@@ -489,13 +656,20 @@ func (h *OCIHandler) PutManifest(c *fiber.Ctx) error {
 			"configMediaType": manifest.Config.MediaType,
 		}).Warn("Unknown artifact type, saving as generic manifest")
 		manifestPath := h.pathManager.GetManifestPath(name, reference)
-		if err := h.saveManifestFile(manifestPath, manifestData); err != nil {
+		if err := h.checkManifestOverwrite(manifestPath, name, reference, manifestData); err != nil {
+			return sendOCIError(c, fiber.StatusConflict, "DENIED", err.Error(), nil)
+		}
+		if err := h.saveManifestFile(manifestPath, manifestData, mediaType); err != nil {
 			return c.SendStatus(500)
 		}
 	}
 
+	// Le client sait ainsi que le registre gère l'API referrers (OCI 1.1)
+	if manifest.Subject != nil {
+		c.Set("OCI-Subject", manifest.Subject.Digest)
+	}
 	c.Set("Docker-Content-Digest", digestStr)
-	c.Set("Location", fmt.Sprintf("/v2/%s/manifests/%s", name, digestStr))
+	c.Set("Location", fmt.Sprintf("/v2/%s/manifests/%s", h.repositoryName(name), digestStr))
 
 	h.log.WithFunc().WithFields(logrus.Fields{
 		"name":         name,
@@ -528,8 +702,12 @@ func (h *OCIHandler) handleHelmChartManifest(name, reference string, manifest *m
 		return fmt.Errorf("failed to read chart data: %w", err)
 	}
 
-	// Save the chart
+	// Save the chart under the name and version of its Chart.yaml: the OCI tag has "_" instead of
+	// the "+" of SemVer build metadata. An unreadable archive is rejected by the validation.
 	fileName := fmt.Sprintf("%s-%s.tgz", name, reference)
+	if metadata, err := h.chartService.ExtractChartMetadata(chartData); err == nil {
+		fileName = fmt.Sprintf("%s-%s.tgz", metadata.Name, metadata.Version)
+	}
 	if err := h.chartService.SaveChart(chartData, fileName); err != nil {
 		return fmt.Errorf("failed to save chart: %w", err)
 	}
@@ -537,8 +715,20 @@ func (h *OCIHandler) handleHelmChartManifest(name, reference string, manifest *m
 	return nil
 }
 
-// saveManifestFile saves a manifest to the specified path
-func (h *OCIHandler) saveManifestFile(manifestPath string, data []byte) error {
+// checkManifestOverwrite applies the overwrite policy when a tag already points to a different manifest
+func (h *OCIHandler) checkManifestOverwrite(manifestPath, name, reference string, data []byte) error {
+	if strings.HasPrefix(reference, "sha256:") {
+		return nil
+	}
+	existing, err := os.ReadFile(manifestPath)
+	if err != nil || bytes.Equal(existing, data) {
+		return nil
+	}
+	return h.policy.Check(h.repositoryName(name), reference)
+}
+
+// saveManifestFile writes a manifest as pushed, along with its media type
+func (h *OCIHandler) saveManifestFile(manifestPath string, data []byte, mediaType string) error {
 	manifestDir := filepath.Dir(manifestPath)
 	if err := os.MkdirAll(manifestDir, 0755); err != nil {
 		h.log.WithFunc().WithError(err).Error("Failed to create manifest directory")
@@ -549,6 +739,141 @@ func (h *OCIHandler) saveManifestFile(manifestPath string, data []byte) error {
 		h.log.WithFunc().WithError(err).Error("Failed to save manifest")
 		return err
 	}
+	if err := os.WriteFile(utils.MediaTypePath(manifestPath), []byte(mediaType), 0644); err != nil {
+		h.log.WithFunc().WithError(err).Error("Failed to save manifest media type")
+		return err
+	}
 
 	return nil
 }
+
+// replaceManifestFile writes a manifest and returns a function restoring the previous one, if any
+func (h *OCIHandler) replaceManifestFile(manifestPath string, data []byte, mediaType string) (func(), error) {
+	previous, previousErr := os.ReadFile(manifestPath)
+	previousType, _ := os.ReadFile(utils.MediaTypePath(manifestPath))
+	if err := h.saveManifestFile(manifestPath, data, mediaType); err != nil {
+		return nil, err
+	}
+	return func() {
+		if previousErr != nil {
+			os.Remove(manifestPath)
+			os.Remove(utils.MediaTypePath(manifestPath))
+			return
+		}
+		if err := h.saveManifestFile(manifestPath, previous, string(previousType)); err != nil {
+			h.log.WithFunc().WithError(err).Error("Failed to restore previous manifest")
+		}
+	}, nil
+}
+
+// DeleteManifest deletes a chart version or image tag (OCI distribution spec).
+// A digest deletes every tag pointing to it.
+func (h *OCIHandler) DeleteManifest(c *fiber.Ctx) error {
+	name := c.Params("name")
+	reference := c.Params("reference")
+
+	tags := []string{reference}
+	if strings.HasPrefix(reference, "sha256:") {
+		tags = h.tagsOfDigest(name, reference)
+	}
+
+	deleted := 0
+	for _, tag := range tags {
+		found, err := h.deleteTag(name, tag)
+		if err != nil {
+			h.log.WithFunc().WithError(err).Error("Failed to delete manifest")
+			return c.SendStatus(500)
+		}
+		if found {
+			deleted++
+		}
+	}
+	if deleted == 0 {
+		return sendOCIError(c, fiber.StatusNotFound, "MANIFEST_UNKNOWN", "manifest unknown", reference)
+	}
+
+	h.log.WithFunc().WithFields(logrus.Fields{
+		"name":      name,
+		"reference": reference,
+		"tags":      deleted,
+	}).Info("Manifest deleted")
+	return c.SendStatus(202)
+}
+
+// deleteTag deletes a chart version or image tag, telling whether it existed
+func (h *OCIHandler) deleteTag(name, tag string) (bool, error) {
+	// Helm pousse la version 1.0.0+build sous le tag 1.0.0_build
+	for _, version := range []string{tag, strings.ReplaceAll(tag, "_", "+")} {
+		if !h.chartService.ChartExists(name, version) {
+			continue
+		}
+		return true, h.chartService.DeleteChart(name, version)
+	}
+	if h.imageService != nil && h.imageService.ImageExists(name, tag) {
+		return true, h.imageService.DeleteImage(name, tag)
+	}
+	return false, nil
+}
+
+// tagsOfDigest returns the chart and image tags of a repository whose manifest has the given digest
+func (h *OCIHandler) tagsOfDigest(name, digest string) []string {
+	var tags []string
+	dirs := []string{
+		filepath.Dir(h.pathManager.GetManifestPath(name, "_")),
+		filepath.Dir(h.pathManager.GetImageManifestPath(name, "_")),
+	}
+	for _, dir := range dirs {
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			file := entry.Name()
+			if entry.IsDir() || !strings.HasSuffix(file, ".json") || strings.HasPrefix(file, "sha256_") {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(dir, file))
+			if err == nil && fmt.Sprintf("sha256:%x", sha256.Sum256(data)) == digest {
+				tags = append(tags, strings.TrimSuffix(file, ".json"))
+			}
+		}
+	}
+	return tags
+}
+
+// GetReferrers lists the manifests whose subject is the given digest (OCI referrers API),
+// filtered by ?artifactType= when set
+func (h *OCIHandler) GetReferrers(c *fiber.Ctx) error {
+	name := c.Params("name")
+	digest := c.Params("digest")
+	artifactType := c.Query("artifactType")
+
+	if !strings.HasPrefix(digest, "sha256:") || len(digest) != len("sha256:")+64 {
+		return sendOCIError(c, fiber.StatusBadRequest, "DIGEST_INVALID", "invalid digest", digest)
+	}
+
+	referrers, err := services.ListReferrers(h.pathManager, name, digest, artifactType)
+	if err != nil {
+		h.log.WithFunc().WithError(err).Error("Failed to list referrers")
+		return c.SendStatus(500)
+	}
+
+	data, err := json.Marshal(models.OCIIndex{
+		SchemaVersion: 2,
+		MediaType:     models.MediaTypeOCIManifestList,
+		Manifests:     referrers,
+	})
+	if err != nil {
+		return c.SendStatus(500)
+	}
+	if artifactType != "" {
+		c.Set("OCI-Filters-Applied", "artifactType")
+	}
+	c.Set("Content-Type", models.MediaTypeOCIManifestList)
+	return c.Send(data)
+}
+
+// manifestMediaType returns the media type a stored manifest was pushed with
+func manifestMediaType(manifestPath string, data []byte) string {
+	if mediaType, err := os.ReadFile(utils.MediaTypePath(manifestPath)); err == nil && len(mediaType) > 0 {
+		return string(mediaType)
+	}
+	return models.ManifestMediaType(data, "")
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"errors"
+
+	middleware "helm-portal/pkg/middlewares"
+	"helm-portal/pkg/models"
+	services "helm-portal/pkg/services"
+	utils "helm-portal/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// PromotionHandler copies chart versions and images between repositories
+type PromotionHandler struct {
+	log     *utils.Logger
+	service *services.PromotionService
+}
+
+// NewPromotionHandler creates a new promotion handler
+func NewPromotionHandler(service *services.PromotionService, log *utils.Logger) *PromotionHandler {
+	return &PromotionHandler{
+		service: service,
+		log:     log,
+	}
+}
+
+// Promote copies the source of the request to its target, as the authenticated user.
+// A failed signature or scan gate is answered with 412 and the result of each gate.
+func (h *PromotionHandler) Promote(c *fiber.Ctx) error {
+	var req models.PromotionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	actor, _ := c.Locals(middleware.LocalUsername).(string)
+	if actor == "" {
+		actor = "anonymous"
+	}
+
+	result, err := h.service.Promote(actor, req)
+	if err != nil {
+		var conflictErr *services.VersionConflictError
+		var validationErr *services.ChartValidationError
+		switch {
+		case errors.Is(err, services.ErrPromotionGateFailed):
+			return c.Status(412).JSON(fiber.Map{"error": err.Error(), "gates": result.Gates})
+		case errors.Is(err, services.ErrInvalidPromotion):
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, services.ErrPromotionForbidden):
+			return c.Status(403).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, services.ErrRepositoryNotFound), errors.Is(err, services.ErrPromotionSourceNotFound):
+			return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+		case errors.As(err, &conflictErr):
+			return c.Status(409).JSON(fiber.Map{"error": conflictErr.Error()})
+		case errors.As(err, &validationErr):
+			return c.Status(400).JSON(fiber.Map{"error": "chart validation failed", "findings": validationErr.Report.Findings})
+		}
+		h.log.WithFunc().WithError(err).Error("Promotion failed")
+		return c.Status(500).JSON(fiber.Map{"error": "Promotion failed"})
+	}
+	return c.Status(201).JSON(result)
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+
+	services "helm-portal/pkg/services"
+	utils "helm-portal/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ProxyHandler serves the Helm repositories of the pull-through cache and the state of its upstreams
+type ProxyHandler struct {
+	log     *utils.Logger
+	service *services.ProxyService
+}
+
+// NewProxyHandler creates a new pull-through cache handler
+func NewProxyHandler(service *services.ProxyService, log *utils.Logger) *ProxyHandler {
+	return &ProxyHandler{
+		service: service,
+		log:     log,
+	}
+}
+
+// GetStatus returns the state of the upstream registries and Helm repositories
+func (h *ProxyHandler) GetStatus(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"upstreams": h.service.Status()})
+}
+
+// GetHelmIndex serves the index.yaml of a proxied Helm repository, its charts being downloaded through the cache
+func (h *ProxyHandler) GetHelmIndex(c *fiber.Ctx) error {
+	data, err := h.service.HelmIndex(c.Params("name"))
+	if err != nil {
+		return h.sendError(c, err, "Repository not found")
+	}
+	c.Set("Content-Type", "application/x-yaml")
+	return c.Send(data)
+}
+
+// GetHelmChart serves a chart archive of a proxied Helm repository, fetched from the upstream on first download
+func (h *ProxyHandler) GetHelmChart(c *fiber.Ctx) error {
+	chartPath, err := h.service.HelmChart(c.Params("name"), c.Params("file"))
+	if err != nil {
+		return h.sendError(c, err, "Chart not found")
+	}
+	c.Set("Content-Type", "application/gzip")
+	return c.SendFile(chartPath)
+}
+
+func (h *ProxyHandler) sendError(c *fiber.Ctx, err error, notFound string) error {
+	switch {
+	case errors.Is(err, services.ErrProxyRepositoryNotFound), errors.Is(err, services.ErrUpstreamNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": notFound})
+	case errors.Is(err, services.ErrUpstreamUnavailable):
+		h.log.WithFunc().WithError(err).Warn("Upstream Helm repository unavailable")
+		return c.Status(503).JSON(fiber.Map{"error": "Upstream repository unavailable"})
+	}
+	h.log.WithFunc().WithError(err).Error("Failed to serve proxied Helm repository")
+	return c.Status(500).JSON(fiber.Map{"error": "Failed to serve proxied repository"})
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"errors"
+
+	services "helm-portal/pkg/services"
+	utils "helm-portal/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReplicationHandler shows the replication rules with their tasks and triggers a full replication
+type ReplicationHandler struct {
+	log     *utils.Logger
+	service *services.ReplicationService
+}
+
+// NewReplicationHandler creates a new replication handler
+func NewReplicationHandler(service *services.ReplicationService, log *utils.Logger) *ReplicationHandler {
+	return &ReplicationHandler{
+		service: service,
+		log:     log,
+	}
+}
+
+// GetStatus renders the replication status page, or returns the rules and their tasks as JSON
+func (h *ReplicationHandler) GetStatus(c *fiber.Ctx) error {
+	rules := h.service.Status()
+	if wantsJSON(c) {
+		return c.JSON(fiber.Map{"rules": rules})
+	}
+	return c.Render("replication", fiber.Map{
+		"Title": "Replication",
+		"Rules": rules,
+	})
+}
+
+// ReplicateNow queues the replication of every chart version and image tag matching a rule
+func (h *ReplicationHandler) ReplicateNow(c *fiber.Ctx) error {
+	rule := c.Params("rule")
+	queued, err := h.service.ReplicateNow(rule)
+	if errors.Is(err, services.ErrReplicationRuleNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Replication rule not found"})
+	}
+	if err != nil {
+		h.log.WithFunc().WithError(err).Error("Failed to queue replication")
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to queue replication"})
+	}
+	return c.Status(202).JSON(fiber.Map{"rule": rule, "queued": queued})
+}
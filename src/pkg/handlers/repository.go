@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	cfg "helm-portal/config"
+	middleware "helm-portal/pkg/middlewares"
+	services "helm-portal/pkg/services"
+	utils "helm-portal/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RepositoryHandler serves the isolated repositories declared in the configuration
+type RepositoryHandler struct {
+	log      *utils.Logger
+	config   *cfg.Config
+	registry *services.RepositoryRegistry
+}
+
+// NewRepositoryHandler creates a new repository handler
+func NewRepositoryHandler(registry *services.RepositoryRegistry, config *cfg.Config, log *utils.Logger) *RepositoryHandler {
+	return &RepositoryHandler{
+		registry: registry,
+		config:   config,
+		log:      log,
+	}
+}
+
+// ListRepositories returns the repositories with their Helm and OCI addresses
+func (h *RepositoryHandler) ListRepositories(c *fiber.Ctx) error {
+	repositories := make([]fiber.Map, 0)
+	for _, repo := range h.registry.List() {
+		repositories = append(repositories, fiber.Map{
+			"name":   repo.Name(),
+			"url":    c.BaseURL() + repo.URLPrefix(),
+			"index":  c.BaseURL() + repo.URLPrefix() + "/index.yaml",
+			"oci":    "oci://" + c.Hostname() + "/" + repo.Name(),
+			"public": repo.Config.IsPublic(),
+		})
+	}
+	return c.JSON(fiber.Map{"repositories": repositories})
+}
+
+// OCIPrefixes returns the /v2 path prefixes of the repositories, whose access is checked by their own rules
+func (h *RepositoryHandler) OCIPrefixes() []string {
+	prefixes := make([]string, 0)
+	for _, repo := range h.registry.List() {
+		prefixes = append(prefixes, "/v2/"+repo.Name()+"/")
+	}
+	return prefixes
+}
+
+// RegisterRoutes serves every repository under /r/<name>/ (Helm HTTP API) and /v2/<name>/ (OCI).
+// Each repository gets its own handlers, bound to its own storage, and its access rules.
+func (h *RepositoryHandler) RegisterRoutes(app fiber.Router, oci fiber.Router, auth *middleware.AuthMiddleware) {
+	for _, repo := range h.registry.List() {
+		access := auth.RepositoryAccess(repo.Config)
+
+		helmHandler := NewHelmHandler(repo.Charts, repo.PathManager, h.log).WithStats(repo.Stats).WithSignatures(repo.Signatures)
+		indexHandler := NewIndexHandler(repo.Charts, repo.PathManager, h.log).WithStats(repo.Stats).WithSignatures(repo.Signatures)
+		ociHandler := NewRepositoryOCIHandler(repo.Name(), repo.Charts, repo.Images, h.config, h.log).WithStats(repo.Stats).WithSignatures(repo.Signatures)
+		statsHandler := NewStatsHandler(repo.Stats, h.log)
+		exportHandler := NewExportHandler(repo.Index, h.log)
+
+		// Helm repository
+		r := app.Group(repo.URLPrefix(), access)
+		r.Get("/index.yaml", indexHandler.GetIndex)
+		r.Get("/charts", helmHandler.ListCharts)
+		r.Get("/charts/:file", indexHandler.GetChartArchive)
+		r.Get("/export", exportHandler.ExportRepository)
+		r.Get("/stats", statsHandler.GetStats)
+		r.Post("/chart", helmHandler.UploadChart)
+		r.Post("/api/package", helmHandler.PackageChart)
+		r.Get("/chart/:name/versions", helmHandler.GetChartVersions)
+		r.Get("/chart/:name/resolve", helmHandler.ResolveChartVersion)
+		r.Get("/chart/:name/:version", helmHandler.DownloadChart)
+		r.Delete("/chart/:name/:version", helmHandler.DeleteChart)
+
+		// OCI namespace
+		o := oci.Group("/"+repo.Name(), access)
+		o.Get("/:name/tags/list", ociHandler.HandleListTags)
+		o.Head("/:name/manifests/:reference", ociHandler.HandleManifest)
+		o.Get("/:name/manifests/:reference", ociHandler.HandleManifest)
+		o.Put("/:name/manifests/:reference", ociHandler.PutManifest)
+		o.Delete("/:name/manifests/:reference", ociHandler.DeleteManifest)
+		o.Put("/:name/blobs/:digest", ociHandler.PutBlob)
+		o.Post("/:name/blobs/uploads/", ociHandler.PostUpload)
+		o.Patch("/:name/blobs/uploads/:uuid", ociHandler.PatchBlob)
+		o.Put("/:name/blobs/uploads/:uuid", ociHandler.CompleteUpload)
+		o.Head("/:name/blobs/:digest", ociHandler.HeadBlob)
+		o.Get("/:name/blobs/:digest", ociHandler.GetBlob)
+		o.Get("/:name/referrers/:digest", ociHandler.GetReferrers)
+
+		h.log.WithField("repository", repo.Name()).Info("Repository routes registered")
+	}
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	middleware "helm-portal/pkg/middlewares"
+	services "helm-portal/pkg/services"
+	utils "helm-portal/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RetentionHandler exposes the retention rules, their passes and the audit trail of their deletions
+type RetentionHandler struct {
+	log     *utils.Logger
+	service *services.RetentionService
+}
+
+// NewRetentionHandler creates a new retention handler
+func NewRetentionHandler(service *services.RetentionService, log *utils.Logger) *RetentionHandler {
+	return &RetentionHandler{
+		service: service,
+		log:     log,
+	}
+}
+
+// GetRetention returns the retention rules, their schedule and the last passes
+func (h *RetentionHandler) GetRetention(c *fiber.Ctx) error {
+	schedule, dryRun, next := h.service.Schedule()
+	result := fiber.Map{
+		"rules":  h.service.Rules(),
+		"dryRun": dryRun,
+		"runs":   h.service.Runs(),
+	}
+	if schedule > 0 {
+		result["schedule"] = schedule.String()
+		result["nextRun"] = next
+	}
+	return c.JSON(result)
+}
+
+// RunRetention runs a retention pass now. With ?dryRun=true nothing is deleted: the response
+// lists the chart versions and image tags the pass would delete.
+func (h *RetentionHandler) RunRetention(c *fiber.Ctx) error {
+	actor, _ := c.Locals(middleware.LocalUsername).(string)
+	if actor == "" {
+		actor = "anonymous"
+	}
+
+	run, err := h.service.Run(actor, c.QueryBool("dryRun"))
+	if err != nil {
+		h.log.WithFunc().WithError(err).Error("Retention pass failed")
+		return c.Status(500).JSON(fiber.Map{"error": "Retention pass failed"})
+	}
+	return c.JSON(run)
+}
+
+// GetAudit returns the deletions applied by the retention passes, most recent first,
+// filtered by ?name= (glob) and limited by ?limit= (100 by default)
+func (h *RetentionHandler) GetAudit(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 100)
+	if limit < 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid limit"})
+	}
+
+	entries, err := h.service.Audit(c.Query("name"), limit)
+	if err != nil {
+		h.log.WithFunc().WithError(err).Error("Failed to read retention audit trail")
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to read audit trail"})
+	}
+	return c.JSON(fiber.Map{"entries": entries})
+}
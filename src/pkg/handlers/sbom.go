@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"helm-portal/pkg/models"
+	services "helm-portal/pkg/services"
+	utils "helm-portal/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// SBOMHandler serves the SBOMs of images and charts
+type SBOMHandler struct {
+	log     *utils.Logger
+	service *services.SBOMService
+}
+
+// NewSBOMHandler creates a new SBOM handler
+func NewSBOMHandler(service *services.SBOMService, log *utils.Logger) *SBOMHandler {
+	return &SBOMHandler{
+		service: service,
+		log:     log,
+	}
+}
+
+// GetImageSBOM downloads the SBOM of an image tag or digest (?format=spdx|cyclonedx), generated if missing
+func (h *SBOMHandler) GetImageSBOM(c *fiber.Ctx) error {
+	name, tag, ok := imageReference(c)
+	if !ok {
+		return sendImageNotFound(c)
+	}
+	info, document, err := h.service.ImageSBOM(name, tag, h.format(c), false)
+	if err != nil {
+		return h.sbomError(c, err)
+	}
+	return h.sendDocument(c, name, tag, info, document)
+}
+
+// GenerateImageSBOM generates the SBOM of an image tag or digest again and returns its description
+func (h *SBOMHandler) GenerateImageSBOM(c *fiber.Ctx) error {
+	name, tag, ok := imageReference(c)
+	if !ok {
+		return sendImageNotFound(c)
+	}
+
+	h.log.WithFunc().WithFields(logrus.Fields{
+		"name": name,
+		"tag":  tag,
+	}).Info("Generating image SBOM")
+
+	info, _, err := h.service.ImageSBOM(name, tag, h.format(c), true)
+	if err != nil {
+		return h.sbomError(c, err)
+	}
+	return c.JSON(info)
+}
+
+// GetChartSBOM downloads the SBOM of a chart version (?format=spdx|cyclonedx), generated if missing
+func (h *SBOMHandler) GetChartSBOM(c *fiber.Ctx) error {
+	name, version := c.Params("name"), c.Params("version")
+	info, document, err := h.service.ChartSBOM(name, version, h.format(c), false)
+	if err != nil {
+		return h.sbomError(c, err)
+	}
+	return h.sendDocument(c, name, version, info, document)
+}
+
+// GenerateChartSBOM generates the SBOM of a chart version again and returns its description
+func (h *SBOMHandler) GenerateChartSBOM(c *fiber.Ctx) error {
+	name, version := c.Params("name"), c.Params("version")
+
+	h.log.WithFunc().WithFields(logrus.Fields{
+		"name":    name,
+		"version": version,
+	}).Info("Generating chart SBOM")
+
+	info, _, err := h.service.ChartSBOM(name, version, h.format(c), true)
+	if err != nil {
+		return h.sbomError(c, err)
+	}
+	return c.JSON(info)
+}
+
+// format returns the requested format, the one generated on push by default
+func (h *SBOMHandler) format(c *fiber.Ctx) string {
+	if format := c.Query("format"); format != "" {
+		return strings.ToLower(format)
+	}
+	return h.service.Format()
+}
+
+func (h *SBOMHandler) sendDocument(c *fiber.Ctx, name, reference string, info *models.SBOMInfo, document []byte) error {
+	fileName := fmt.Sprintf("%s-%s.%s.json", name, strings.ReplaceAll(reference, ":", "-"), info.Format)
+	c.Set("Content-Type", info.MediaType)
+	c.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+	return c.Send(document)
+}
+
+// sbomError maps the SBOM errors to HTTP statuses
+func (h *SBOMHandler) sbomError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrImageNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "Image not found"})
+	case errors.Is(err, services.ErrChartNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "Chart not found"})
+	case errors.Is(err, services.ErrSBOMNoManifest):
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrSBOMFormat), errors.Is(err, services.ErrSBOMIndex), errors.Is(err, services.ErrInvalidManifest):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	h.log.WithFunc().WithError(err).Error("SBOM generation failed")
+	return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+}
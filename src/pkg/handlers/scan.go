@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+
+	services "helm-portal/pkg/services"
+	utils "helm-portal/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// ScanHandler serves the vulnerability scans of images and charts and the advisory database
+type ScanHandler struct {
+	log     *utils.Logger
+	service *services.ScanService
+}
+
+// NewScanHandler creates a new scan handler
+func NewScanHandler(service *services.ScanService, log *utils.Logger) *ScanHandler {
+	return &ScanHandler{
+		service: service,
+		log:     log,
+	}
+}
+
+// GetImageVulnerabilities returns the last scan of an image tag or digest
+func (h *ScanHandler) GetImageVulnerabilities(c *fiber.Ctx) error {
+	name, tag, ok := imageReference(c)
+	if !ok {
+		return sendImageNotFound(c)
+	}
+	report, err := h.service.GetReport(name, tag)
+	if err != nil {
+		return h.scanError(c, err)
+	}
+	return c.JSON(report)
+}
+
+// ScanImage scans an image tag or digest now and returns the report
+func (h *ScanHandler) ScanImage(c *fiber.Ctx) error {
+	name, tag, ok := imageReference(c)
+	if !ok {
+		return sendImageNotFound(c)
+	}
+
+	h.log.WithFunc().WithFields(logrus.Fields{
+		"name": name,
+		"tag":  tag,
+	}).Info("Scanning image")
+
+	report, err := h.service.ScanImage(name, tag)
+	if err != nil {
+		return h.scanError(c, err)
+	}
+	return c.JSON(report)
+}
+
+// GetChartVulnerabilities returns the scans of the images deployed by a chart version
+func (h *ScanHandler) GetChartVulnerabilities(c *fiber.Ctx) error {
+	report, err := h.service.ChartReport(c.Params("name"), c.Params("version"))
+	if err != nil {
+		if errors.Is(err, services.ErrChartNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "Chart not found"})
+		}
+		h.log.WithFunc().WithError(err).Error("Failed to list chart images")
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to list chart images"})
+	}
+	return c.JSON(report)
+}
+
+// GetDatabase returns the status of the vulnerability database
+func (h *ScanHandler) GetDatabase(c *fiber.Ctx) error {
+	return c.JSON(h.service.Database().Status())
+}
+
+// ImportDatabase imports an OSV dump (zip or JSON), uploaded as the "database" form file
+// or read from the directory of scanning.databaseDir with {"path": "Debian.zip"}. ?replace=true drops the previous advisories.
+func (h *ScanHandler) ImportDatabase(c *fiber.Ctx) error {
+	replace := c.QueryBool("replace")
+	db := h.service.Database()
+
+	if file, err := c.FormFile("database"); err == nil {
+		content, err := file.Open()
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to process file"})
+		}
+		defer content.Close()
+		data, err := io.ReadAll(content)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to process file"})
+		}
+		status, err := db.Import(data, file.Filename, replace)
+		if err != nil {
+			return h.scanError(c, err)
+		}
+		return c.JSON(status)
+	}
+
+	var req struct {
+		Path    string `json:"path"`
+		Replace bool   `json:"replace"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.Path == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Provide a database file or a path"})
+	}
+	status, err := h.service.ImportDatabaseFile(req.Path, replace || req.Replace)
+	if err != nil {
+		return h.scanError(c, err)
+	}
+	return c.JSON(status)
+}
+
+// scanError maps the scan errors to HTTP statuses
+func (h *ScanHandler) scanError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrImageNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "Image not found"})
+	case errors.Is(err, services.ErrScanNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrDatabasePathForbidden):
+		return c.Status(403).JSON(fiber.Map{"error": services.ErrDatabasePathForbidden.Error()})
+	case errors.Is(err, services.ErrInvalidDatabase), errors.Is(err, services.ErrInvalidManifest):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	// Le détail (chemins du stockage) reste dans les journaux
+	h.log.WithFunc().WithError(err).Error("Vulnerability scan failed")
+	return c.Status(500).JSON(fiber.Map{"error": "Vulnerability scan failed"})
+}
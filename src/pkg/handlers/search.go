@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"errors"
+
+	services "helm-portal/pkg/services"
+	utils "helm-portal/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SearchHandler handles full-text search requests
+type SearchHandler struct {
+	log     *utils.Logger
+	service *services.SearchService
+}
+
+// NewSearchHandler creates a new search handler
+func NewSearchHandler(service *services.SearchService, log *utils.Logger) *SearchHandler {
+	return &SearchHandler{
+		service: service,
+		log:     log,
+	}
+}
+
+// Search returns the charts and images matching ?q=, optionally filtered by ?type=chart|image
+func (h *SearchHandler) Search(c *fiber.Ctx) error {
+	artifactType, err := services.ParseArtifactType(c.Query("type"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	result, err := h.service.Search(c.Query("q"), artifactType, c.QueryInt("limit"))
+	if err != nil {
+		if errors.Is(err, services.ErrEmptyQuery) {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		h.log.WithFunc().WithError(err).Error("Search failed")
+		return c.Status(500).JSON(fiber.Map{"error": "Search failed"})
+	}
+
+	return c.JSON(result)
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"errors"
+
+	services "helm-portal/pkg/services"
+	utils "helm-portal/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SignatureHandler serves the signature verification of images and charts
+type SignatureHandler struct {
+	log     *utils.Logger
+	service *services.SignatureService
+}
+
+// NewSignatureHandler creates a new signature handler
+func NewSignatureHandler(service *services.SignatureService, log *utils.Logger) *SignatureHandler {
+	return &SignatureHandler{
+		service: service,
+		log:     log,
+	}
+}
+
+// GetImageSignature returns the signature status of an image tag or digest
+func (h *SignatureHandler) GetImageSignature(c *fiber.Ctx) error {
+	name, tag, ok := imageReference(c)
+	if !ok {
+		return sendImageNotFound(c)
+	}
+	status, err := h.service.ImageStatus(name, tag)
+	if err != nil {
+		if errors.Is(err, services.ErrImageNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "Image not found"})
+		}
+		h.log.WithFunc().WithError(err).Error("Failed to verify image signatures")
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to verify signatures"})
+	}
+	return c.JSON(status)
+}
+
+// GetChartSignature returns the signature status of a chart version
+func (h *SignatureHandler) GetChartSignature(c *fiber.Ctx) error {
+	status, err := h.service.ChartStatus(c.Params("name"), c.Params("version"))
+	if err != nil {
+		if errors.Is(err, services.ErrChartNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "Chart not found"})
+		}
+		h.log.WithFunc().WithError(err).Error("Failed to verify chart signatures")
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to verify signatures"})
+	}
+	return c.JSON(status)
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	middleware "helm-portal/pkg/middlewares"
+	"helm-portal/pkg/models"
+	services "helm-portal/pkg/services"
+	utils "helm-portal/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// StatsHandler serves the download and pull statistics
+type StatsHandler struct {
+	log     *utils.Logger
+	service *services.StatsService
+}
+
+// NewStatsHandler creates a new statistics handler
+func NewStatsHandler(service *services.StatsService, log *utils.Logger) *StatsHandler {
+	return &StatsHandler{
+		service: service,
+		log:     log,
+	}
+}
+
+// GetStats returns the pull statistics of chart versions and image tags,
+// filtered by ?type=chart|image, ?name= and ?unusedDays= (not pulled for N days)
+func (h *StatsHandler) GetStats(c *fiber.Ctx) error {
+	artifactType, err := services.ParseArtifactType(c.Query("type"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	unusedDays := c.QueryInt("unusedDays")
+	if unusedDays < 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "unusedDays must be positive"})
+	}
+
+	report, err := h.service.Report(models.StatsQuery{
+		Type:       artifactType,
+		Name:       c.Query("name"),
+		UnusedDays: unusedDays,
+	})
+	if err != nil {
+		h.log.WithFunc().WithError(err).Error("Failed to compute statistics")
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to compute statistics"})
+	}
+	return c.JSON(report)
+}
+
+// pullClient identifies who pulled an artifact: the authenticated user, or the user agent
+func pullClient(c *fiber.Ctx) string {
+	if username, ok := c.Locals(middleware.LocalUsername).(string); ok && username != "" {
+		return username
+	}
+	return c.Get(fiber.HeaderUserAgent)
+}
@@ -1,12 +1,15 @@
 package interfaces
 
 import (
+	"io"
+
 	"helm-portal/pkg/models"
 	storage "helm-portal/pkg/utils"
 )
 
 type ChartServiceInterface interface {
 	SaveChart(data []byte, filename string) error
+	SaveProvenance(filename string, data []byte) error
 	ListCharts() ([]models.ChartGroup, error)
 	ChartExists(name, version string) bool
 	GetChart(name, version string) ([]byte, error)
@@ -15,11 +18,30 @@ type ChartServiceInterface interface {
 	GetPathManager() *storage.PathManager
 	GetChartValues(name, version string) (string, error)
 	ExtractChartMetadata(chartData []byte) (*models.ChartMetadata, error)
+	GetChartVersions(name string) ([]models.ChartMetadata, error)
+	ResolveChartVersion(name, constraint string) (*models.ChartMetadata, error)
+	RenderChart(name, version string, opts models.RenderOptions) (*models.RenderResult, error)
+	DiffChartVersions(name, from, to string) (*models.ChartDiff, error)
+	ListChartFiles(name, version string) ([]models.ChartFile, error)
+	GetChartFile(name, version, filePath string) (*models.ChartFileContent, error)
+	CheckDependencies(name, version string) (*models.DependencyReport, error)
+	GetDependents(name, constraint string) ([]models.Dependent, error)
+	GetValuesSchema(name, version string) ([]byte, error)
+	ValidateValues(name, version, values string) (*models.ValuesValidationResult, error)
+	GetChartStatus(name, version string) (*models.ChartStatus, error)
+	DeprecateChart(name, version, reason, actor string) (*models.ChartStatus, error)
+	YankChart(name, version, reason, actor string) (*models.ChartStatus, error)
+	ReinstateChart(name, version, reason, actor string) (*models.ChartStatus, error)
+	PackageChart(source []byte, version string) (*models.PackageResult, error)
 }
 
 type ImageServiceInterface interface {
-	// SaveImage saves a Docker image manifest and metadata
-	SaveImage(name, reference string, manifest *models.OCIManifest) error
+	// SaveImage saves the exact bytes of a Docker image manifest, its media type and metadata
+	SaveImage(name, reference string, manifestData []byte, mediaType string) error
+	// CheckOverwrite returns an error when the overwrite policy forbids saving a manifest under a tag
+	CheckOverwrite(name, reference string, manifestData []byte) error
+	// CacheImage saves a manifest fetched from an upstream registry, bypassing the overwrite policy
+	CacheImage(name, reference string, manifestData []byte, mediaType string) error
 	// ListImages returns all available images grouped by name
 	ListImages() ([]models.ImageGroup, error)
 	// ImageExists checks if an image with the given name and tag exists
@@ -34,6 +56,16 @@ type ImageServiceInterface interface {
 	GetImageConfig(name, tag string) (*models.ImageConfig, error)
 	// ListTags returns all tags for a given repository
 	ListTags(name string) ([]string, error)
+	// HasLayer reports whether a manifest of an image lists a blob among its layers
+	HasLayer(name, digest string) bool
+	// ListLayerFiles lists the entries of a layer blob
+	ListLayerFiles(digest string) (*models.LayerListing, error)
+	// OpenLayerFile returns a regular file of a layer and its content
+	OpenLayerFile(digest, filePath string) (*models.LayerEntry, io.ReadCloser, error)
+	// WalkLayer calls fn for every entry of a layer blob, with the content of regular files
+	WalkLayer(digest string, fn func(entry *models.LayerEntry, content io.Reader) error) error
+	// MergedFilesystem returns the filesystem of an image tag with all its layers applied
+	MergedFilesystem(name, tag string) (*models.MergedFilesystem, error)
 	// GetPathManager returns the path manager
 	GetPathManager() *storage.PathManager
 }
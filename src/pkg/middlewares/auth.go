@@ -11,6 +11,9 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
+// LocalUsername est la clé de c.Locals contenant l'utilisateur authentifié
+const LocalUsername = "username"
+
 type AuthMiddleware struct {
 	config *config.Config
 	log    *utils.Logger
@@ -25,69 +28,150 @@ func NewAuthMiddleware(config *config.Config, log *utils.Logger) *AuthMiddleware
 
 func (m *AuthMiddleware) Authenticate() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-
-		// Récupérer le header d'authentification
-		auth := c.Get("Authorization")
-		if auth == "" {
-			m.log.Warn("No authorization header")
-			// Important: Ajouter le header WWW-Authenticate pour le realm
-			c.Set("WWW-Authenticate", `Basic realm="Helm Registry"`)
-			return c.Status(401).JSON(fiber.Map{
-				"errors": []fiber.Map{
-					{
-						"code":    "UNAUTHORIZED",
-						"message": "authentication required",
-						"detail":  "basic authentication required",
-					},
-				},
-			})
+		if username, err := m.authenticate(c); username == "" {
+			return err
 		}
+		return c.Next()
+	}
+}
 
-		// Vérifier le format "Basic base64(username:password)"
-		if !strings.HasPrefix(auth, "Basic ") {
-			m.log.Warn("Invalid auth format")
-			return c.Status(401).SendString("Invalid authentication format")
+// AuthenticateExcept authentifie les requêtes, sauf les routes OCI servies sous l'un des préfixes :
+// l'accès à ces chemins (espaces de noms des dépôts isolés) est vérifié par RepositoryAccess.
+func (m *AuthMiddleware) AuthenticateExcept(prefixes ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		for _, prefix := range prefixes {
+			if rest, ok := strings.CutPrefix(c.Path(), prefix); ok && isNamespacedOCIPath(rest) {
+				return c.Next()
+			}
 		}
-
-		// Décoder les credentials
-		credentials, err := base64.StdEncoding.DecodeString(auth[6:])
-		if err != nil {
-			m.log.WithError(err).Warn("Failed to decode credentials")
-			return c.Status(401).SendString("Invalid credentials format")
+		if username, err := m.authenticate(c); username == "" {
+			return err
 		}
+		return c.Next()
+	}
+}
 
-		parts := strings.Split(string(credentials), ":")
-		if len(parts) != 2 {
-			m.log.Warn("Invalid credentials format")
-			return c.Status(401).SendString("Invalid credentials format")
+// isNamespacedOCIPath indique si la fin d'un chemin sous un espace de noms (<nom>/tags/list, <nom>/blobs/...)
+// est une route OCI de cet espace. Sans nom d'image (/v2/<dépôt>/tags/list), la requête vise
+// l'image <dépôt> du stockage par défaut et doit être authentifiée.
+func isNamespacedOCIPath(rest string) bool {
+	segments := strings.Split(rest, "/")
+	if len(segments) < 3 || segments[0] == "" {
+		return false
+	}
+	switch segments[1] {
+	case "tags", "manifests", "blobs", "referrers":
+		return true
+	}
+	return false
+}
+
+// RepositoryAccess vérifie les droits sur un dépôt isolé : lecture pour GET/HEAD, écriture sinon.
+// L'authentification n'est demandée que si le dépôt l'exige (lecture d'un dépôt public).
+func (m *AuthMiddleware) RepositoryAccess(repo config.RepositoryConfig) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		write := c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead
+		if !write && repo.IsPublic() {
+			return c.Next()
 		}
 
-		username, password := parts[0], parts[1]
+		// L'utilisateur peut déjà avoir été authentifié par un middleware précédent
+		username, _ := c.Locals(LocalUsername).(string)
+		if username == "" {
+			var err error
+			if username, err = m.authenticate(c); username == "" {
+				return err
+			}
+		}
 
-		// Debug: Log le nombre d'utilisateurs configurés
-		m.log.WithField("total_users", len(m.config.Auth.Users)).Debug("Checking authentication")
-		
-		// Vérifier les credentials
-		for _, user := range m.config.Auth.Users {
+		allowed := repo.CanRead(username)
+		if write {
+			allowed = repo.CanWrite(username)
+		}
+		if !allowed {
 			m.log.WithFields(map[string]interface{}{
-				"config_user": user.Username,
-				"input_user":  username,
-			}).Debug("Comparing user")
-			
-			if user.Username == username && user.Password == password {
-				m.log.WithField("username", username).Info("User authenticated successfully")
-				return c.Next()
-			}
+				"username":   username,
+				"repository": repo.Name,
+				"write":      write,
+			}).Warn("Repository access denied")
+			return c.Status(403).JSON(fiber.Map{
+				"errors": []fiber.Map{
+					{
+						"code":    "DENIED",
+						"message": "access to repository " + repo.Name + " denied",
+					},
+				},
+			})
 		}
+		return c.Next()
+	}
+}
 
-		m.log.WithField("username", username).Warn("Authentication failed")
-		return c.Status(401).JSON(fiber.Map{
+// authenticate vérifie les credentials Basic de la requête et retourne l'utilisateur.
+// En cas d'échec, la réponse 401 est écrite et l'utilisateur retourné est vide.
+func (m *AuthMiddleware) authenticate(c *fiber.Ctx) (string, error) {
+	// Récupérer le header d'authentification
+	auth := c.Get("Authorization")
+	if auth == "" {
+		m.log.Warn("No authorization header")
+		// Important: Ajouter le header WWW-Authenticate pour le realm
+		c.Set("WWW-Authenticate", `Basic realm="Helm Registry"`)
+		return "", c.Status(401).JSON(fiber.Map{
 			"errors": []fiber.Map{
 				{
 					"code":    "UNAUTHORIZED",
-					"message": "invalid username or password",
+					"message": "authentication required",
+					"detail":  "basic authentication required",
 				},
 			},
 		})
 	}
+
+	// Vérifier le format "Basic base64(username:password)"
+	if !strings.HasPrefix(auth, "Basic ") {
+		m.log.Warn("Invalid auth format")
+		return "", c.Status(401).SendString("Invalid authentication format")
+	}
+
+	// Décoder les credentials
+	credentials, err := base64.StdEncoding.DecodeString(auth[6:])
+	if err != nil {
+		m.log.WithError(err).Warn("Failed to decode credentials")
+		return "", c.Status(401).SendString("Invalid credentials format")
+	}
+
+	parts := strings.Split(string(credentials), ":")
+	if len(parts) != 2 {
+		m.log.Warn("Invalid credentials format")
+		return "", c.Status(401).SendString("Invalid credentials format")
+	}
+
+	username, password := parts[0], parts[1]
+
+	// Debug: Log le nombre d'utilisateurs configurés
+	m.log.WithField("total_users", len(m.config.Auth.Users)).Debug("Checking authentication")
+
+	// Vérifier les credentials
+	for _, user := range m.config.Auth.Users {
+		m.log.WithFields(map[string]interface{}{
+			"config_user": user.Username,
+			"input_user":  username,
+		}).Debug("Comparing user")
+
+		if user.Username == username && user.Password == password {
+			m.log.WithField("username", username).Info("User authenticated successfully")
+			c.Locals(LocalUsername, username)
+			return username, nil
+		}
+	}
+
+	m.log.WithField("username", username).Warn("Authentication failed")
+	return "", c.Status(401).JSON(fiber.Map{
+		"errors": []fiber.Map{
+			{
+				"code":    "UNAUTHORIZED",
+				"message": "invalid username or password",
+			},
+		},
+	})
 }
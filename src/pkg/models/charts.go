@@ -1,19 +1,42 @@
 // pkg/models/chart.go
 package models
 
+import (
+	"sort"
+
+	"github.com/Masterminds/semver/v3"
+)
+
 // ChartMetadata représente la structure commune utilisée dans toute l'application
 type ChartMetadata struct {
-	Name         string `yaml:"name"`
-	Version      string `yaml:"version"`
-	Description  string `yaml:"description"`
-	ApiVersion   string `yaml:"apiVersion"`
-	Type         string `yaml:"type,omitempty"`
-	AppVersion   string `yaml:"appVersion,omitempty"`
-	Dependencies []struct {
-		Name       string `yaml:"name"`
-		Version    string `yaml:"version"`
-		Repository string `yaml:"repository"`
-	} `yaml:"dependencies,omitempty"`
+	Name         string            `yaml:"name"`
+	Version      string            `yaml:"version"`
+	Description  string            `yaml:"description"`
+	ApiVersion   string            `yaml:"apiVersion"`
+	Type         string            `yaml:"type,omitempty"`
+	AppVersion   string            `yaml:"appVersion,omitempty"`
+	Home         string            `yaml:"home,omitempty"`
+	Keywords     []string          `yaml:"keywords,omitempty"`
+	Maintainers  []ChartMaintainer `yaml:"maintainers,omitempty"`
+	Dependencies []ChartDependency `yaml:"dependencies,omitempty"`
+	Deprecated   bool              `yaml:"deprecated,omitempty"`
+	// Yanked n'est pas lu depuis Chart.yaml, il est renseigné à partir du statut du chart
+	Yanked bool `yaml:"-"`
+}
+
+// ChartDependency est une dépendance déclarée dans Chart.yaml
+type ChartDependency struct {
+	Name       string `yaml:"name"`
+	Version    string `yaml:"version"`
+	Repository string `yaml:"repository"`
+	Alias      string `yaml:"alias,omitempty"`
+}
+
+// ChartMaintainer est un mainteneur déclaré dans Chart.yaml
+type ChartMaintainer struct {
+	Name  string `yaml:"name"`
+	Email string `yaml:"email,omitempty"`
+	URL   string `yaml:"url,omitempty"`
 }
 
 type ChartGroup struct {
@@ -33,11 +56,51 @@ func GroupChartsByName(charts []ChartMetadata) []ChartGroup {
 	// Convertir la map en slice
 	result := make([]ChartGroup, 0, len(chartGroups))
 	for name, versions := range chartGroups {
+		SortChartVersions(versions)
 		result = append(result, ChartGroup{
 			Name:     name,
 			Versions: versions,
 		})
 	}
 
+	// Trier les groupes par nom
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
 	return result
 }
+
+// CompareVersions compare deux versions selon semver (pré-releases incluses).
+// Les versions non semver sont considérées plus anciennes et comparées comme chaînes.
+func CompareVersions(a, b string) int {
+	va, errA := semver.NewVersion(a)
+	vb, errB := semver.NewVersion(b)
+
+	switch {
+	case errA == nil && errB == nil:
+		if c := va.Compare(vb); c != 0 {
+			return c
+		}
+		// 1.0 et 1.0.0 sont égales pour semver, on départage sur la chaîne
+	case errA == nil:
+		return 1
+	case errB == nil:
+		return -1
+	}
+
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// SortChartVersions trie les versions d'un chart de la plus récente à la plus ancienne
+func SortChartVersions(versions []ChartMetadata) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		return CompareVersions(versions[i].Version, versions[j].Version) > 0
+	})
+}
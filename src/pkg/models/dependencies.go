@@ -0,0 +1,58 @@
+// pkg/models/dependencies.go
+package models
+
+// Dependency statuses
+const (
+	// DependencyResolved means a hosted chart satisfies the constraint
+	DependencyResolved = "resolved"
+	// DependencyVendored means a copy satisfying the constraint is packaged in charts/
+	DependencyVendored = "vendored"
+	// DependencyExternal means the dependency comes from a repository not hosted here
+	DependencyExternal = "external"
+	// DependencyUnresolved means no chart satisfies the constraint
+	DependencyUnresolved = "unresolved"
+	// DependencyMismatch means the vendored copy does not match the declaration
+	DependencyMismatch = "mismatch"
+)
+
+// DependencyCheck is the resolution result of a single dependency
+type DependencyCheck struct {
+	Name            string `json:"name"`
+	Alias           string `json:"alias,omitempty"`
+	Constraint      string `json:"constraint"`
+	Repository      string `json:"repository"`
+	Status          string `json:"status"`
+	ResolvedVersion string `json:"resolvedVersion,omitempty"`
+	VendoredVersion string `json:"vendoredVersion,omitempty"`
+	Message         string `json:"message,omitempty"`
+}
+
+// IsProblem returns true for unresolvable dependencies and vendored mismatches
+func (d DependencyCheck) IsProblem() bool {
+	return d.Status == DependencyUnresolved || d.Status == DependencyMismatch
+}
+
+// DependencyReport lists the resolution of every dependency of a chart version
+type DependencyReport struct {
+	Name         string            `json:"name"`
+	Version      string            `json:"version"`
+	Dependencies []DependencyCheck `json:"dependencies"`
+}
+
+// HasProblems returns true if at least one dependency cannot be satisfied
+func (r *DependencyReport) HasProblems() bool {
+	for _, d := range r.Dependencies {
+		if d.IsProblem() {
+			return true
+		}
+	}
+	return false
+}
+
+// Dependent is a chart version that declares a dependency on another chart
+type Dependent struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	Constraint string `json:"constraint"`
+	Repository string `json:"repository"`
+}
@@ -0,0 +1,51 @@
+// pkg/models/diff.go
+package models
+
+// Change types used in chart diffs
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+)
+
+// FileChange describes a file added, removed or modified between two chart versions
+type FileChange struct {
+	Path     string `json:"path"`
+	Change   string `json:"change"`
+	FromSize int64  `json:"fromSize"`
+	ToSize   int64  `json:"toSize"`
+}
+
+// FieldChange describes a Chart.yaml field that differs between two versions
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from,omitempty"`
+	To    string `json:"to,omitempty"`
+}
+
+// ValueChange describes a values.yaml key that differs between two versions.
+// Path uses dotted notation, e.g. "image.tag" or "ports[0].name".
+type ValueChange struct {
+	Path   string `json:"path"`
+	Change string `json:"change"`
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+}
+
+// TemplateChange holds a unified diff of a template between two versions
+type TemplateChange struct {
+	Path   string `json:"path"`
+	Change string `json:"change"`
+	Diff   string `json:"diff"`
+}
+
+// ChartDiff is the comparison of two versions of the same chart
+type ChartDiff struct {
+	Name      string           `json:"name"`
+	From      string           `json:"from"`
+	To        string           `json:"to"`
+	Files     []FileChange     `json:"files"`
+	Metadata  []FieldChange    `json:"metadata"`
+	Values    []ValueChange    `json:"values"`
+	Templates []TemplateChange `json:"templates"`
+}
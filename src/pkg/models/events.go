@@ -0,0 +1,20 @@
+// pkg/models/events.go
+package models
+
+// Artifact event actions
+const (
+	EventPush   = "push"
+	EventUpdate = "update"
+	EventDelete = "delete"
+	// EventCache is published when the pull-through cache stores a manifest fetched from an upstream registry
+	EventCache = "cache"
+)
+
+// ArtifactEvent describes a change to a stored chart version or image tag
+type ArtifactEvent struct {
+	Action    string       `json:"action"`
+	Type      ArtifactType `json:"type"`
+	Name      string       `json:"name"`
+	Reference string       `json:"reference"` // chart version or image tag
+	Digest    string       `json:"digest,omitempty"`
+}
@@ -0,0 +1,9 @@
+// pkg/models/export.go
+package models
+
+// ExportOptions selects the charts of a static repository export.
+// An empty Pattern exports every chart; Latest > 0 keeps only the newest versions of each chart.
+type ExportOptions struct {
+	Pattern string `json:"pattern,omitempty"` // glob on the chart name, e.g. "nginx-*"
+	Latest  int    `json:"latest,omitempty"`
+}
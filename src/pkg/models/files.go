@@ -0,0 +1,20 @@
+// pkg/models/files.go
+package models
+
+// ChartFile is an entry of the file tree of a chart archive
+type ChartFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// ChartFileContent is a single file read from a chart archive.
+// HTML is only set for documentation files (README.md, CHANGELOG.md, NOTES.txt)
+// and is safe to insert into a page.
+type ChartFileContent struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Language string `json:"language,omitempty"`
+	Binary   bool   `json:"binary"`
+	Content  string `json:"content,omitempty"`
+	HTML     string `json:"html,omitempty"`
+}
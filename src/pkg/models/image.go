@@ -1,7 +1,13 @@
 // pkg/models/image.go
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"mime"
+	"sort"
+	"strings"
+	"time"
+)
 
 // ImageMetadata represents Docker image metadata
 type ImageMetadata struct {
@@ -9,10 +15,35 @@ type ImageMetadata struct {
 	Repository string       `json:"repository"`
 	Tag        string       `json:"tag"`
 	Digest     string       `json:"digest"`
+	MediaType  string       `json:"mediaType,omitempty"`
 	Size       int64        `json:"size"`
 	Created    time.Time    `json:"created"`
 	Config     *ImageConfig `json:"config,omitempty"`
 	Layers     []LayerInfo  `json:"layers,omitempty"`
+	// Platforms lists the images of a multi-platform index (manifest list)
+	Platforms []PlatformInfo `json:"platforms,omitempty"`
+}
+
+// PlatformInfo describes one platform-specific image of an index
+type PlatformInfo struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+	Digest       string `json:"digest"`
+	MediaType    string `json:"mediaType"`
+	Size         int64  `json:"size"`
+}
+
+// String returns the platform as os/architecture[/variant]
+func (p PlatformInfo) String() string {
+	if p.OS == "" && p.Architecture == "" {
+		return "unknown"
+	}
+	platform := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		platform += "/" + p.Variant
+	}
+	return platform
 }
 
 // ImageConfig represents the configuration of a Docker image
@@ -68,7 +99,8 @@ type ImageGroup struct {
 	Tags []ImageMetadata // List of available tags
 }
 
-// GroupImagesByName groups images by their repository name
+// GroupImagesByName groups images by their repository name.
+// Groups are sorted by name, and tags from the most recently pushed.
 func GroupImagesByName(images []ImageMetadata) []ImageGroup {
 	imageGroups := make(map[string][]ImageMetadata)
 
@@ -78,11 +110,20 @@ func GroupImagesByName(images []ImageMetadata) []ImageGroup {
 
 	result := make([]ImageGroup, 0, len(imageGroups))
 	for name, tags := range imageGroups {
+		sort.SliceStable(tags, func(i, j int) bool {
+			if !tags[i].Created.Equal(tags[j].Created) {
+				return tags[i].Created.After(tags[j].Created)
+			}
+			return tags[i].Tag < tags[j].Tag
+		})
 		result = append(result, ImageGroup{
 			Name: name,
 			Tags: tags,
 		})
 	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
 
 	return result
 }
@@ -117,6 +158,26 @@ const (
 	ArtifactTypeUnknown     ArtifactType = "unknown"
 )
 
+// ManifestMediaType returns the media type of a pushed manifest: its mediaType field,
+// else the Content-Type of the push, else the OCI image manifest type
+func ManifestMediaType(data []byte, contentType string) string {
+	var manifest struct {
+		MediaType string `json:"mediaType"`
+	}
+	if err := json.Unmarshal(data, &manifest); err == nil && manifest.MediaType != "" {
+		return manifest.MediaType
+	}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && strings.HasPrefix(mediaType, "application/vnd.") {
+		return mediaType
+	}
+	return MediaTypeOCIManifest
+}
+
+// IsIndexMediaType reports whether mediaType is a multi-platform index (OCI index or Docker manifest list)
+func IsIndexMediaType(mediaType string) bool {
+	return mediaType == MediaTypeOCIManifestList || mediaType == MediaTypeDockerManifestList
+}
+
 // DetectArtifactType determines if an OCI manifest is a Helm chart or Docker image
 func DetectArtifactType(manifest *OCIManifest) ArtifactType {
 	if manifest == nil {
@@ -0,0 +1,56 @@
+// pkg/models/import.go
+package models
+
+import "time"
+
+// Import job states
+const (
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+// Import item outcomes
+const (
+	ImportItemImported = "imported"
+	ImportItemSkipped  = "skipped"
+	ImportItemPlanned  = "planned" // dry-run: would be imported
+	ImportItemFailed   = "failed"
+)
+
+// ImportRequest describes where to import charts from.
+// Exactly one of URL (a Helm repository or its index.yaml) and Directory must be set.
+type ImportRequest struct {
+	URL       string `json:"url,omitempty"`
+	Directory string `json:"directory,omitempty"`
+	Username  string `json:"username,omitempty"`
+	Password  string `json:"password,omitempty"`
+	DryRun    bool   `json:"dryRun"`
+}
+
+// ImportItem is the outcome of the import of one chart version
+type ImportItem struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	Status     string `json:"status"`
+	Provenance bool   `json:"provenance,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ImportJob reports the progress of a bulk import
+type ImportJob struct {
+	ID         string       `json:"id"`
+	Source     string       `json:"source"`
+	DryRun     bool         `json:"dryRun"`
+	Status     string       `json:"status"`
+	Total      int          `json:"total"`
+	Processed  int          `json:"processed"`
+	Imported   int          `json:"imported"`
+	Planned    int          `json:"planned"`
+	Skipped    int          `json:"skipped"`
+	Failed     int          `json:"failed"`
+	Error      string       `json:"error,omitempty"`
+	Items      []ImportItem `json:"items"`
+	StartedAt  time.Time    `json:"startedAt"`
+	FinishedAt *time.Time   `json:"finishedAt,omitempty"`
+}
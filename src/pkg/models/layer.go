@@ -0,0 +1,47 @@
+// pkg/models/layer.go
+package models
+
+import "time"
+
+// Layer entry types
+const (
+	LayerEntryFile     = "file"
+	LayerEntryDir      = "dir"
+	LayerEntrySymlink  = "symlink"
+	LayerEntryHardlink = "hardlink"
+	LayerEntryWhiteout = "whiteout" // the path is deleted from the lower layers
+	LayerEntryOpaque   = "opaque"   // the content of the directory in the lower layers is hidden
+	LayerEntryOther    = "other"    // devices, fifos
+)
+
+// LayerEntry is a file, directory, link or whiteout of an image layer
+type LayerEntry struct {
+	Path    string    `json:"path"`
+	Type    string    `json:"type"`
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"`
+	Link    string    `json:"link,omitempty"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// LayerListing lists the entries of a layer blob, sorted by path
+type LayerListing struct {
+	Digest      string       `json:"digest"`
+	Compression string       `json:"compression"`
+	Entries     []LayerEntry `json:"entries"`
+}
+
+// MergedEntry is an entry of the filesystem of an image, with the layer providing it
+type MergedEntry struct {
+	LayerEntry
+	Layer       int    `json:"layer"`
+	LayerDigest string `json:"layerDigest"`
+}
+
+// MergedFilesystem is the filesystem of an image once all its layers are applied
+type MergedFilesystem struct {
+	Name    string        `json:"name"`
+	Tag     string        `json:"tag"`
+	Layers  []LayerInfo   `json:"layers"`
+	Entries []MergedEntry `json:"entries"`
+}
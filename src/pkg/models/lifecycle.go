@@ -0,0 +1,40 @@
+// pkg/models/lifecycle.go
+package models
+
+import "time"
+
+// Lifecycle actions recorded on a chart version
+const (
+	LifecycleDeprecate = "deprecate"
+	LifecycleYank      = "yank"
+	LifecycleReinstate = "reinstate"
+)
+
+// LifecycleEntry records who changed the lifecycle of a chart version, when and why
+type LifecycleEntry struct {
+	Action string    `json:"action"`
+	Reason string    `json:"reason,omitempty"`
+	Actor  string    `json:"actor"`
+	At     time.Time `json:"at"`
+}
+
+// ChartStatus is the lifecycle state of a chart version.
+// Deprecated versions stay listed with a warning; yanked versions are hidden
+// from the index and tag listings but remain downloadable by exact version or digest.
+type ChartStatus struct {
+	Name       string           `json:"name"`
+	Version    string           `json:"version"`
+	Deprecated bool             `json:"deprecated"`
+	Yanked     bool             `json:"yanked"`
+	History    []LifecycleEntry `json:"history"`
+}
+
+// Last returns the most recent entry for an action, or nil
+func (s *ChartStatus) Last(action string) *LifecycleEntry {
+	for i := len(s.History) - 1; i >= 0; i-- {
+		if s.History[i].Action == action {
+			return &s.History[i]
+		}
+	}
+	return nil
+}
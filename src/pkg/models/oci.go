@@ -2,12 +2,13 @@ package models
 
 // OCIDescriptor represents an OCI content descriptor
 type OCIDescriptor struct {
-	MediaType   string            `json:"mediaType"`
-	Digest      string            `json:"digest"`
-	Size        int64             `json:"size"`
-	URLs        []string          `json:"urls,omitempty"`
-	Annotations map[string]string `json:"annotations,omitempty"`
-	Platform    *OCIPlatform      `json:"platform,omitempty"`
+	MediaType    string            `json:"mediaType"`
+	Digest       string            `json:"digest"`
+	Size         int64             `json:"size"`
+	URLs         []string          `json:"urls,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	Platform     *OCIPlatform      `json:"platform,omitempty"`
+	ArtifactType string            `json:"artifactType,omitempty"`
 }
 
 // OCIPlatform describes the platform which the image runs on
@@ -19,12 +20,15 @@ type OCIPlatform struct {
 	Variant      string   `json:"variant,omitempty"`
 }
 
-// OCIManifest represents an OCI image manifest
+// OCIManifest represents an OCI image manifest. Subject is set on artifacts referring
+// to another manifest (signatures, SBOMs), listed by the referrers API.
 type OCIManifest struct {
 	SchemaVersion int               `json:"schemaVersion"`
 	MediaType     string            `json:"mediaType,omitempty"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
 	Config        OCIDescriptor     `json:"config"`
 	Layers        []OCIDescriptor   `json:"layers"`
+	Subject       *OCIDescriptor    `json:"subject,omitempty"`
 	Annotations   map[string]string `json:"annotations,omitempty"`
 }
 
@@ -36,6 +40,16 @@ type OCIIndex struct {
 	Annotations   map[string]string `json:"annotations,omitempty"`
 }
 
+// Annotations and media types of OCI artifacts
+const (
+	// MediaTypeOCIEmpty is the config of artifacts that have none, whose content is "{}"
+	MediaTypeOCIEmpty = "application/vnd.oci.empty.v1+json"
+	// AnnotationCreated is the creation date of an artifact (RFC 3339)
+	AnnotationCreated = "org.opencontainers.image.created"
+	// AnnotationTitle is the file name of a layer
+	AnnotationTitle = "org.opencontainers.image.title"
+)
+
 // IsImageManifest checks if this is a Docker/OCI image manifest
 func (m *OCIManifest) IsImageManifest() bool {
 	return m.Config.MediaType == MediaTypeDockerConfig ||
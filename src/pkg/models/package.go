@@ -0,0 +1,21 @@
+// pkg/models/package.go
+package models
+
+// VendoredDependency is a dependency copied into charts/ while packaging a chart
+type VendoredDependency struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Source  string `json:"source"` // "hosted" or "local" (file://)
+}
+
+// PackageResult describes a chart packaged from a source directory archive
+type PackageResult struct {
+	Name     string               `json:"name"`
+	Version  string               `json:"version"`
+	Filename string               `json:"filename"`
+	Digest   string               `json:"digest"`
+	Size     int                  `json:"size"`
+	Vendored []VendoredDependency `json:"vendored"`
+	Findings []ValidationFinding  `json:"findings"`
+	Archive  []byte               `json:"archive,omitempty"` // the packaged .tgz, base64 in JSON
+}
@@ -0,0 +1,38 @@
+// pkg/models/promotion.go
+package models
+
+// PromotionLocation designates a chart version or image tag in a repository
+type PromotionLocation struct {
+	Repository string `json:"repository"` // isolated repository, empty for the default one
+	Name       string `json:"name"`
+	Reference  string `json:"reference"` // tag, digest or chart version
+}
+
+// PromotionRequest copies a chart version or image tag to another repository, name or tag.
+// An empty target name or reference keeps the one of the source.
+type PromotionRequest struct {
+	Source           PromotionLocation `json:"source"`
+	Target           PromotionLocation `json:"target"`
+	RequireSignature bool              `json:"requireSignature"`
+	RequireScan      bool              `json:"requireScan"`
+	MaxSeverity      string            `json:"maxSeverity,omitempty"` // most severe vulnerability tolerated by the scan gate
+}
+
+// PromotionGate is the result of a check required before the promotion
+type PromotionGate struct {
+	Name   string `json:"name"` // "signature" or "scan"
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail"`
+}
+
+// PromotionResult describes a promotion: the manifests written in the target and the blobs they share with the source
+type PromotionResult struct {
+	Type       ArtifactType      `json:"type"`
+	Source     PromotionLocation `json:"source"`
+	Target     PromotionLocation `json:"target"`
+	Digest     string            `json:"digest"`
+	Manifests  int               `json:"manifests"`  // manifests written, including platforms and signatures
+	Blobs      int               `json:"blobs"`      // blobs linked into the target repository
+	Signatures int               `json:"signatures"` // signatures, SBOMs and attestations copied with the artifact
+	Gates      []PromotionGate   `json:"gates"`
+}
@@ -0,0 +1,21 @@
+// pkg/models/proxy.go
+package models
+
+import "time"
+
+// Upstream types of the pull-through cache
+const (
+	UpstreamTypeRegistry = "registry"
+	UpstreamTypeHelm     = "helm"
+)
+
+// UpstreamStatus is the state of an upstream registry or Helm repository of the pull-through cache
+type UpstreamStatus struct {
+	Name        string     `json:"name"`
+	Type        string     `json:"type"`
+	URL         string     `json:"url"`
+	Online      bool       `json:"online"` // false when the last request failed, cached content is then served as is
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+	LastErrorAt *time.Time `json:"lastErrorAt,omitempty"`
+}
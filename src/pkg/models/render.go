@@ -0,0 +1,34 @@
+// pkg/models/render.go
+package models
+
+// RenderOptions controls how a chart is rendered for preview
+type RenderOptions struct {
+	Values      string `json:"values"`      // YAML merged over the chart's values.yaml
+	ReleaseName string `json:"releaseName"` // defaults to "release-name"
+	Namespace   string `json:"namespace"`   // defaults to "default"
+}
+
+// RenderedTemplate is the output, or the error, of a single chart template
+type RenderedTemplate struct {
+	Path    string `json:"path"`
+	Content string `json:"content,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// RenderResult groups the rendered manifests of a chart
+type RenderResult struct {
+	Name      string             `json:"name"`
+	Version   string             `json:"version"`
+	Templates []RenderedTemplate `json:"templates"`
+	Notes     string             `json:"notes,omitempty"`
+}
+
+// HasErrors returns true if at least one template failed to render
+func (r *RenderResult) HasErrors() bool {
+	for _, t := range r.Templates {
+		if t.Error != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+// pkg/models/replication.go
+package models
+
+import "time"
+
+// Replication task states
+const (
+	ReplicationPending   = "pending"
+	ReplicationRunning   = "running"
+	ReplicationRetrying  = "retrying" // failed, waiting for its next attempt
+	ReplicationSucceeded = "succeeded"
+	ReplicationFailed    = "failed" // given up after the last attempt
+)
+
+// ReplicationTask is the replication of a pushed or deleted chart version or image tag to the target of a rule
+type ReplicationTask struct {
+	ID          string       `json:"id"`
+	Rule        string       `json:"rule"`
+	Action      string       `json:"action"` // EventPush or EventDelete
+	Type        ArtifactType `json:"type"`
+	Name        string       `json:"name"`
+	Reference   string       `json:"reference"`
+	Target      string       `json:"target"` // repository on the target registry
+	Status      string       `json:"status"`
+	Attempts    int          `json:"attempts"`
+	LastError   string       `json:"lastError,omitempty"`
+	CreatedAt   time.Time    `json:"createdAt"`
+	UpdatedAt   time.Time    `json:"updatedAt"`
+	NextAttempt *time.Time   `json:"nextAttempt,omitempty"`
+}
+
+// Done tells whether the task will not be attempted again
+func (t *ReplicationTask) Done() bool {
+	return t.Status == ReplicationSucceeded || t.Status == ReplicationFailed
+}
+
+// ReplicationRuleStatus is a replication rule with its recent tasks, most recent first
+type ReplicationRuleStatus struct {
+	Name         string            `json:"name"`
+	Target       string            `json:"target"`
+	Namespace    string            `json:"namespace,omitempty"`
+	Repositories []string          `json:"repositories"`
+	Tags         []string          `json:"tags"`
+	Deletions    bool              `json:"deletions"`
+	Pending      int               `json:"pending"` // pending, running and retrying tasks
+	Succeeded    int               `json:"succeeded"`
+	Failed       int               `json:"failed"`
+	LastSuccess  *time.Time        `json:"lastSuccess,omitempty"`
+	Tasks        []ReplicationTask `json:"tasks"`
+}
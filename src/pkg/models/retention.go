@@ -0,0 +1,36 @@
+// pkg/models/retention.go
+package models
+
+import "time"
+
+// RetentionDeletion is a chart version or image tag deleted by a retention rule, or that a dry run would delete
+type RetentionDeletion struct {
+	Type       ArtifactType `json:"type"`
+	Name       string       `json:"name"`
+	Reference  string       `json:"reference"`
+	Rule       string       `json:"rule"` // pattern of the rule
+	Reason     string       `json:"reason"`
+	PushedAt   time.Time    `json:"pushedAt"`
+	LastPulled *time.Time   `json:"lastPulled,omitempty"`
+	Error      string       `json:"error,omitempty"` // the deletion failed
+}
+
+// RetentionRun is the result of a retention pass
+type RetentionRun struct {
+	ID         string              `json:"id"`
+	Actor      string              `json:"actor"` // "schedule", or the user who started the pass
+	DryRun     bool                `json:"dryRun"`
+	StartedAt  time.Time           `json:"startedAt"`
+	FinishedAt time.Time           `json:"finishedAt"`
+	Examined   int                 `json:"examined"` // chart versions and image tags covered by a rule
+	Kept       int                 `json:"kept"`
+	Deletions  []RetentionDeletion `json:"deletions"`
+}
+
+// RetentionAuditEntry is a line of the retention audit trail: a deletion applied by a pass
+type RetentionAuditEntry struct {
+	At    time.Time `json:"at"`
+	RunID string    `json:"runId"`
+	Actor string    `json:"actor"`
+	RetentionDeletion
+}
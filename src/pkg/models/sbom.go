@@ -0,0 +1,38 @@
+// pkg/models/sbom.go
+package models
+
+import "time"
+
+// SBOM formats
+const (
+	SBOMFormatSPDX      = "spdx"
+	SBOMFormatCycloneDX = "cyclonedx"
+)
+
+// SBOM media types, used as the artifactType of the referrers holding them
+const (
+	MediaTypeSPDX      = "application/spdx+json"
+	MediaTypeCycloneDX = "application/vnd.cyclonedx+json"
+)
+
+// SBOMMediaType returns the media type of an SBOM format, "" if the format is unknown
+func SBOMMediaType(format string) string {
+	switch format {
+	case SBOMFormatSPDX:
+		return MediaTypeSPDX
+	case SBOMFormatCycloneDX:
+		return MediaTypeCycloneDX
+	}
+	return ""
+}
+
+// SBOMInfo describes an SBOM stored as an OCI artifact referring to the manifest it describes
+type SBOMInfo struct {
+	Format    string    `json:"format"`
+	MediaType string    `json:"mediaType"`
+	Digest    string    `json:"digest"`  // manifest of the SBOM artifact
+	Subject   string    `json:"subject"` // manifest described by the SBOM
+	Document  string    `json:"document"`
+	Size      int64     `json:"size"`
+	Created   time.Time `json:"created"`
+}
@@ -0,0 +1,124 @@
+// pkg/models/scan.go
+package models
+
+import "time"
+
+// Vulnerability severities, from the most to the least severe
+const (
+	SeverityCritical = "CRITICAL"
+	SeverityHigh     = "HIGH"
+	SeverityMedium   = "MEDIUM"
+	SeverityLow      = "LOW"
+	SeverityUnknown  = "UNKNOWN"
+)
+
+// Scan statuses
+const (
+	ScanStatusCompleted = "completed"
+	ScanStatusFailed    = "failed"
+)
+
+// Package is a package found in an image, with the ecosystem used to match advisories
+// (e.g. "Debian:12", "Alpine:v3.20", "Go", "npm", "PyPI")
+type Package struct {
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	Ecosystem string `json:"ecosystem"`
+	Type      string `json:"type"`             // dpkg, apk, rpm, go-module, npm, ...
+	Source    string `json:"source,omitempty"` // source package, used for distribution advisories
+	Location  string `json:"location"`         // file the package was read from
+}
+
+// VulnerabilityMatch is an advisory affecting a package of an image
+type VulnerabilityMatch struct {
+	ID           string   `json:"id"`
+	Aliases      []string `json:"aliases,omitempty"`
+	Summary      string   `json:"summary,omitempty"`
+	Severity     string   `json:"severity"`
+	Package      string   `json:"package"`
+	Version      string   `json:"version"`
+	Ecosystem    string   `json:"ecosystem"`
+	FixedVersion string   `json:"fixedVersion,omitempty"`
+	Location     string   `json:"location"`
+}
+
+// VulnerabilitySummary counts the vulnerabilities of a scan by severity
+type VulnerabilitySummary struct {
+	Critical int `json:"critical"`
+	High     int `json:"high"`
+	Medium   int `json:"medium"`
+	Low      int `json:"low"`
+	Unknown  int `json:"unknown"`
+	Total    int `json:"total"`
+}
+
+// Add counts a vulnerability of the given severity
+func (s *VulnerabilitySummary) Add(severity string) {
+	switch severity {
+	case SeverityCritical:
+		s.Critical++
+	case SeverityHigh:
+		s.High++
+	case SeverityMedium:
+		s.Medium++
+	case SeverityLow:
+		s.Low++
+	default:
+		s.Unknown++
+	}
+	s.Total++
+}
+
+// PlatformScan is the summary of the scan of one image of a multi-platform index
+type PlatformScan struct {
+	Platform string               `json:"platform"`
+	Digest   string               `json:"digest"`
+	Status   string               `json:"status"`
+	Summary  VulnerabilitySummary `json:"summary"`
+}
+
+// ScanReport is the result of the scan of an image manifest, stored per manifest digest.
+// Packages are kept so that the report can be matched again when the database changes.
+type ScanReport struct {
+	Digest          string               `json:"digest"`
+	Name            string               `json:"name"`
+	Reference       string               `json:"reference"`
+	Status          string               `json:"status"`
+	Error           string               `json:"error,omitempty"`
+	OS              string               `json:"os,omitempty"`
+	ScannedAt       time.Time            `json:"scannedAt"`
+	DatabaseVersion string               `json:"databaseVersion"`
+	Summary         VulnerabilitySummary `json:"summary"`
+	Vulnerabilities []VulnerabilityMatch `json:"vulnerabilities"`
+	Packages        []Package            `json:"packages"`
+	Platforms       []PlatformScan       `json:"platforms,omitempty"` // multi-platform index only
+}
+
+// VulnerabilityDatabaseStatus describes the imported advisory database
+type VulnerabilityDatabaseStatus struct {
+	Version    string         `json:"version"`
+	ImportedAt *time.Time     `json:"importedAt,omitempty"`
+	Source     string         `json:"source,omitempty"`
+	Advisories int            `json:"advisories"`
+	Ecosystems map[string]int `json:"ecosystems"`
+}
+
+// ChartImageScan is an image referenced by the templates of a chart, with its scan if the image is stored here
+type ChartImageScan struct {
+	Image     string                `json:"image"` // reference as written in the rendered manifests
+	Name      string                `json:"name,omitempty"`
+	Tag       string                `json:"tag,omitempty"`
+	Digest    string                `json:"digest,omitempty"`
+	Hosted    bool                  `json:"hosted"`
+	Status    string                `json:"status,omitempty"`
+	Summary   *VulnerabilitySummary `json:"summary,omitempty"`
+	ScannedAt *time.Time            `json:"scannedAt,omitempty"`
+}
+
+// ChartScanReport groups the scans of the images deployed by a chart version
+type ChartScanReport struct {
+	Name    string               `json:"name"`
+	Version string               `json:"version"`
+	Images  []ChartImageScan     `json:"images"`
+	Summary VulnerabilitySummary `json:"summary"`
+}
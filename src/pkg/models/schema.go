@@ -0,0 +1,16 @@
+// pkg/models/schema.go
+package models
+
+// ValuesError is a values.schema.json violation.
+// Path is a JSON pointer into the values, e.g. "/image/tag" ("" for the root).
+type ValuesError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// ValuesValidationResult is the outcome of validating values against a chart schema
+type ValuesValidationResult struct {
+	Valid     bool          `json:"valid"`
+	HasSchema bool          `json:"hasSchema"`
+	Errors    []ValuesError `json:"errors"`
+}
@@ -0,0 +1,21 @@
+// pkg/models/search.go
+package models
+
+// SearchHit is a chart or an image repository matching a search query.
+// Versions lists the chart versions or image tags that matched, newest first.
+type SearchHit struct {
+	Type        ArtifactType `json:"type"`
+	Name        string       `json:"name"`
+	Versions    []string     `json:"versions"`
+	Description string       `json:"description,omitempty"`
+	Fields      []string     `json:"fields"` // fields where the terms were found
+	Score       float64      `json:"score"`
+}
+
+// SearchResult is the response of a search query
+type SearchResult struct {
+	Query string      `json:"query"`
+	Type  string      `json:"type,omitempty"`
+	Total int         `json:"total"`
+	Hits  []SearchHit `json:"hits"`
+}
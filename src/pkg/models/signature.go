@@ -0,0 +1,65 @@
+// pkg/models/signature.go
+package models
+
+import "time"
+
+// Signature types
+const (
+	SignatureTypeCosign   = "cosign"
+	SignatureTypeNotation = "notation"
+)
+
+// Signature statuses of a manifest
+const (
+	SignatureStatusVerified = "verified" // at least one signature verified with a configured key or certificate
+	SignatureStatusInvalid  = "invalid"  // signed, but no signature could be verified
+	SignatureStatusUnsigned = "unsigned"
+)
+
+// Media types and annotations of cosign and Notation signatures
+const (
+	MediaTypeCosignSimpleSigning = "application/vnd.dev.cosign.simplesigning.v1+json"
+	MediaTypeCosignSignature     = "application/vnd.dev.cosign.artifact.sig.v1+json"
+	MediaTypeNotationSignature   = "application/vnd.cncf.notary.signature"
+	MediaTypeJWS                 = "application/jose+json"
+	AnnotationCosignSignature    = "dev.cosignproject.cosign/signature"
+)
+
+// Media types of in-toto attestations, as pushed by cosign attest
+const (
+	MediaTypeInToto = "application/vnd.in-toto+json"
+	MediaTypeDSSE   = "application/vnd.dsse.envelope.v1+json"
+)
+
+// Signature is the verification result of one signature of a manifest
+type Signature struct {
+	Type     string `json:"type"`
+	Digest   string `json:"digest"`           // manifest holding the signature
+	Signer   string `json:"signer,omitempty"` // key name or certificate subject
+	Verified bool   `json:"verified"`
+	Error    string `json:"error,omitempty"`
+}
+
+// SignatureStatus is the verification status of a manifest
+type SignatureStatus struct {
+	Digest     string      `json:"digest"`
+	Status     string      `json:"status"`
+	Signatures []Signature `json:"signatures"`
+	CheckedAt  time.Time   `json:"checkedAt"`
+}
+
+// Verified tells whether the manifest has at least one verified signature
+func (s *SignatureStatus) Verified() bool {
+	return s != nil && s.Status == SignatureStatusVerified
+}
+
+// Signers returns the signers of the verified signatures
+func (s *SignatureStatus) Signers() []string {
+	var signers []string
+	for _, signature := range s.Signatures {
+		if signature.Verified {
+			signers = append(signers, signature.Signer)
+		}
+	}
+	return signers
+}
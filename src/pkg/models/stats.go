@@ -0,0 +1,45 @@
+// pkg/models/stats.go
+package models
+
+import "time"
+
+// PullCount is the number of pulls of one day (UTC, YYYY-MM-DD)
+type PullCount struct {
+	Date  string `json:"date"`
+	Count int64  `json:"count"`
+}
+
+// PullStats are the download statistics of a chart version or an image tag (or digest)
+type PullStats struct {
+	Type       ArtifactType `json:"type"`
+	Name       string       `json:"name"`
+	Reference  string       `json:"reference"`
+	Total      int64        `json:"total"`
+	Last7Days  int64        `json:"last7Days"`
+	Last30Days int64        `json:"last30Days"`
+	LastPulled *time.Time   `json:"lastPulled,omitempty"`
+	LastClient string       `json:"lastClient,omitempty"` // authenticated user, or user agent
+	Daily      []PullCount  `json:"daily"`
+}
+
+// BlobPullStats counts the blob downloads of a repository name (layers, configs, chart contents)
+type BlobPullStats struct {
+	Name       string     `json:"name"`
+	Total      int64      `json:"total"`
+	Bytes      int64      `json:"bytes"`
+	LastPulled *time.Time `json:"lastPulled,omitempty"`
+}
+
+// StatsQuery filters pull statistics. UnusedDays > 0 keeps only the artifacts
+// not pulled during the last UnusedDays days, never pulled ones included.
+type StatsQuery struct {
+	Type       ArtifactType
+	Name       string
+	UnusedDays int
+}
+
+// StatsReport lists pull statistics, the least recently pulled first
+type StatsReport struct {
+	Items []PullStats     `json:"items"`
+	Blobs []BlobPullStats `json:"blobs"`
+}
@@ -0,0 +1,56 @@
+// pkg/models/validation.go
+package models
+
+// FindingSeverity indicates how serious a validation finding is
+type FindingSeverity string
+
+const (
+	SeverityError   FindingSeverity = "error"
+	SeverityWarning FindingSeverity = "warning"
+)
+
+// ValidationFinding describes a single problem found in a chart archive
+type ValidationFinding struct {
+	Severity FindingSeverity `json:"severity"`
+	Rule     string          `json:"rule"`
+	Path     string          `json:"path,omitempty"`
+	Message  string          `json:"message"`
+}
+
+// ValidationReport groups all findings produced while validating a chart
+type ValidationReport struct {
+	Name     string              `json:"name,omitempty"`
+	Version  string              `json:"version,omitempty"`
+	Findings []ValidationFinding `json:"findings"`
+}
+
+// Add appends a finding to the report
+func (r *ValidationReport) Add(severity FindingSeverity, rule, path, message string) {
+	r.Findings = append(r.Findings, ValidationFinding{
+		Severity: severity,
+		Rule:     rule,
+		Path:     path,
+		Message:  message,
+	})
+}
+
+// HasErrors returns true if at least one finding is an error
+func (r *ValidationReport) HasErrors() bool {
+	for _, f := range r.Findings {
+		if f.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Errors returns only the error findings
+func (r *ValidationReport) Errors() []ValidationFinding {
+	var errs []ValidationFinding
+	for _, f := range r.Findings {
+		if f.Severity == SeverityError {
+			errs = append(errs, f)
+		}
+	}
+	return errs
+}
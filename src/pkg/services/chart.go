@@ -6,9 +6,12 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 
 	"strings"
@@ -17,10 +20,20 @@ import (
 	"helm-portal/pkg/models"
 	utils "helm-portal/pkg/utils"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
 )
 
+var (
+	// ErrChartNotFound est retourné quand aucun chart ne porte le nom demandé
+	ErrChartNotFound = errors.New("chart not found")
+	// ErrInvalidConstraint est retourné pour une contrainte semver mal formée
+	ErrInvalidConstraint = errors.New("invalid version constraint")
+	// ErrNoMatchingVersion est retourné quand aucune version ne satisfait la contrainte
+	ErrNoMatchingVersion = errors.New("no version matches constraint")
+)
+
 type IndexUpdater interface {
 	UpdateIndex() error
 	EnsureIndexExists() error
@@ -32,8 +45,11 @@ type ChartService struct {
 	pathManager *utils.PathManager
 	config      *config.Config
 	log         *utils.Logger
+	validator   *ChartValidator
+	policy      *OverwritePolicy
 
 	indexUpdater IndexUpdater
+	eventPublisher
 }
 
 // NewChartService creates a new chart service
@@ -41,34 +57,74 @@ func NewChartService(config *config.Config, log *utils.Logger, indexUpdater Inde
 	if err := os.MkdirAll(config.Storage.Path, 0755); err != nil {
 		log.WithError(err).Error("❌ Impossible de créer le dossier de stockage")
 	}
+	return NewRepositoryChartService(config, log, utils.NewPathManager(config.Storage.Path, log), indexUpdater)
+}
+
+// NewRepositoryChartService creates a chart service storing its charts under the given path manager,
+// e.g. the storage prefix of an isolated repository
+func NewRepositoryChartService(config *config.Config, log *utils.Logger, pathManager *utils.PathManager, indexUpdater IndexUpdater) *ChartService {
 	return &ChartService{
-		pathManager:  utils.NewPathManager(config.Storage.Path, log),
+		pathManager:  pathManager,
 		config:       config,
 		log:          log,
+		validator:    NewChartValidator(config, log),
+		policy:       NewOverwritePolicy(config, log),
 		indexUpdater: indexUpdater,
 	}
 }
+
+// WithNamespace applies the overwrite rules of an isolated repository to its charts, matched as <namespace>/<name>
+func (s *ChartService) WithNamespace(namespace string) *ChartService {
+	s.policy.WithNamespace(namespace)
+	return s
+}
+
 func (s *ChartService) GetPathManager() *utils.PathManager {
 	return s.pathManager
 }
 
-// SaveChart saves an uploaded chart file
+// SaveChart validates and saves an uploaded chart file.
+// Invalid charts are rejected with a *ChartValidationError before anything is written.
 func (s *ChartService) SaveChart(chartData []byte, filename string) error {
-	// ✨ Create charts directory if not exists
-	chartsDir := s.pathManager.GetChartsPath()
-
-	// 💾 Save chart file
-	chartPath := filepath.Join(chartsDir, filename)
-	if err := os.WriteFile(chartPath, chartData, 0644); err != nil {
-		return fmt.Errorf("❌ failed to save chart: %w", err)
+	// 🔎 Validate the archive before persisting anything
+	report := s.validator.Validate(chartData, filename)
+	for _, finding := range report.Findings {
+		s.log.WithFields(logrus.Fields{
+			"file":     filename,
+			"severity": finding.Severity,
+			"rule":     finding.Rule,
+			"path":     finding.Path,
+		}).Warn("⚠️ " + finding.Message)
+	}
+	if report.HasErrors() {
+		return &ChartValidationError{Report: report}
 	}
 
-	// 📝 Extract and validate metadata
+	// 📝 Extract metadata
 	metadata, err := s.ExtractChartMetadata(chartData)
 	if err != nil {
 		return fmt.Errorf("❌ failed to extract chart metadata: %w", err)
 	}
 
+	// 🔒 Apply the overwrite policy if this version already exists
+	chartsDir := s.pathManager.GetChartsPath()
+	chartPath := filepath.Join(chartsDir, filepath.Base(filename))
+	if existing, err := os.ReadFile(chartPath); err == nil {
+		if bytes.Equal(existing, chartData) {
+			s.log.WithField("file", filename).Info("✅ Chart already stored with identical content")
+			return nil
+		}
+		if err := s.policy.Check(metadata.Name, metadata.Version); err != nil {
+			return err
+		}
+		s.log.WithField("file", filename).Warn("⚠️ Overwriting existing chart version")
+	}
+
+	// 💾 Save chart file
+	if err := os.WriteFile(chartPath, chartData, 0644); err != nil {
+		return fmt.Errorf("❌ failed to save chart: %w", err)
+	}
+
 	if err := s.indexUpdater.UpdateIndex(); err != nil {
 		s.log.WithError(err).Error("❌ Échec mise à jour index")
 		return fmt.Errorf("échec mise à jour index: %w", err)
@@ -80,50 +136,134 @@ func (s *ChartService) SaveChart(chartData []byte, filename string) error {
 		"file":    filename,
 	}).Info("✅ Chart saved successfully")
 
+	s.publish(models.ArtifactEvent{
+		Action:    models.EventPush,
+		Type:      models.ArtifactTypeHelmChart,
+		Name:      metadata.Name,
+		Reference: metadata.Version,
+		Digest:    fmt.Sprintf("sha256:%x", sha256.Sum256(chartData)),
+	})
 	return nil
 }
 
-// extractChartMetadata extracts Chart.yaml from the tgz file
-func (s *ChartService) ExtractChartMetadata(chartData []byte) (*models.ChartMetadata, error) {
+// SaveProvenance stores the provenance file (.prov) of a stored chart archive next to it,
+// where `helm --verify` looks for it (<archive URL>.prov)
+func (s *ChartService) SaveProvenance(filename string, data []byte) error {
+	chartPath := filepath.Join(s.pathManager.GetChartsPath(), filepath.Base(filename))
+	if _, err := os.Stat(chartPath); err != nil {
+		return fmt.Errorf("%w: %s", ErrChartNotFound, filepath.Base(filename))
+	}
+	if !bytes.Contains(data, []byte("-----BEGIN PGP SIGNED MESSAGE-----")) {
+		return fmt.Errorf("❌ %s.prov is not a signed provenance file", filepath.Base(filename))
+	}
+	if err := os.WriteFile(chartPath+".prov", data, 0644); err != nil {
+		return fmt.Errorf("❌ failed to save provenance: %w", err)
+	}
+	return nil
+}
+
+// errStopWalk interrompt le parcours d'une archive sans erreur
+var errStopWalk = errors.New("stop walking chart archive")
+
+// walkChartArchive parcourt les fichiers réguliers d'une archive de chart (.tgz).
+// Le chemin passé à fn est relatif au répertoire racine du chart (ex: "templates/deployment.yaml").
+// fn peut retourner errStopWalk pour arrêter le parcours.
+func walkChartArchive(r io.Reader, fn func(name string, header *tar.Header, content io.Reader) error) error {
 	// 📦 Read the gzip file
-	gr, err := gzip.NewReader(bytes.NewReader(chartData))
+	gr, err := gzip.NewReader(r)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+		return fmt.Errorf("failed to create gzip reader: %w", err)
 	}
 	defer gr.Close()
 
 	// 📂 Read the tar archive
 	tr := tar.NewReader(gr)
-
-	// 🔍 Look for Chart.yaml
 	for {
 		header, err := tr.Next()
 		if err == io.EOF {
-			break
+			return nil
 		}
 		if err != nil {
-			return nil, err
+			return fmt.Errorf("failed to read tar: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
 		}
 
-		// Find Chart.yaml in the root directory of the chart
-		if filepath.Base(header.Name) == "Chart.yaml" {
-			// Read the Chart.yaml content
-			content, err := io.ReadAll(tr)
-			if err != nil {
-				return nil, err
+		if err := fn(chartRelativePath(header.Name), header, tr); err != nil {
+			if errors.Is(err, errStopWalk) {
+				return nil
 			}
+			return err
+		}
+	}
+}
 
-			// Parse YAML
-			var metadata models.ChartMetadata
-			if err := yaml.Unmarshal(content, &metadata); err != nil {
-				return nil, err
-			}
+// chartRelativePath retire le répertoire racine du chart d'un chemin d'archive
+func chartRelativePath(name string) string {
+	name = path.Clean(strings.TrimPrefix(name, "./"))
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 {
+		return parts[1]
+	}
+	return name
+}
 
-			return &metadata, nil
+// readChartFiles charge en mémoire tous les fichiers d'une archive de chart,
+// dans la limite de maxDecompressedChartSize
+func readChartFiles(chartData []byte) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	var total int64
+	err := walkChartArchive(bytes.NewReader(chartData), func(name string, header *tar.Header, content io.Reader) error {
+		total += header.Size
+		if total > maxDecompressedChartSize {
+			return fmt.Errorf("decompressed chart exceeds %d bytes", maxDecompressedChartSize)
 		}
+		data, err := io.ReadAll(content)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		files[name] = data
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return files, nil
+}
 
-	return nil, fmt.Errorf("Chart.yaml not found in chart archive")
+// ExtractChartMetadata extracts Chart.yaml from the tgz file
+func (s *ChartService) ExtractChartMetadata(chartData []byte) (*models.ChartMetadata, error) {
+	return readChartMetadata(bytes.NewReader(chartData))
+}
+
+// readChartMetadata reads Chart.yaml from a chart archive stream, without reading the files after it
+func readChartMetadata(r io.Reader) (*models.ChartMetadata, error) {
+	var metadata *models.ChartMetadata
+
+	// 🔍 Look for Chart.yaml in the root directory of the chart
+	err := walkChartArchive(r, func(name string, _ *tar.Header, content io.Reader) error {
+		if name != "Chart.yaml" {
+			return nil
+		}
+		data, err := io.ReadAll(content)
+		if err != nil {
+			return err
+		}
+		metadata = &models.ChartMetadata{}
+		if err := yaml.Unmarshal(data, metadata); err != nil {
+			return err
+		}
+		return errStopWalk
+	})
+	if err != nil {
+		return nil, err
+	}
+	if metadata == nil {
+		return nil, fmt.Errorf("Chart.yaml not found in chart archive")
+	}
+
+	return metadata, nil
 }
 
 // ListCharts returns all available charts grouped by name with their versions
@@ -156,23 +296,73 @@ func (s *ChartService) ListCharts() ([]models.ChartGroup, error) {
 			s.log.WithError(err).WithField("file", file.Name()).Error("Failed to extract metadata")
 			continue
 		}
+		s.applyChartStatus(metadata)
 
 		chartMetadatas = append(chartMetadatas, *metadata)
-		// trier les charts par nom et version TODO
-
 	}
 
-	// Utiliser GroupChartsByName pour grouper les charts
+	// Grouper par nom, trié par nom puis par version semver décroissante
 	return models.GroupChartsByName(chartMetadatas), nil
 }
 
+// GetChartVersions returns all versions of a chart that are not yanked, newest first
+func (s *ChartService) GetChartVersions(chartName string) ([]models.ChartMetadata, error) {
+	groups, err := s.ListCharts()
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		if group.Name != chartName {
+			continue
+		}
+		versions := make([]models.ChartMetadata, 0, len(group.Versions))
+		for _, metadata := range group.Versions {
+			if !metadata.Yanked {
+				versions = append(versions, metadata)
+			}
+		}
+		if len(versions) > 0 {
+			return versions, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrChartNotFound, chartName)
+}
+
+// ResolveChartVersion returns the highest version of a chart satisfying a
+// semver constraint such as "~1.2" or ">=2.0 <3"
+func (s *ChartService) ResolveChartVersion(chartName string, constraint string) (*models.ChartMetadata, error) {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %v", ErrInvalidConstraint, constraint, err)
+	}
+
+	versions, err := s.GetChartVersions(chartName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Les versions sont triées de la plus récente à la plus ancienne
+	for _, metadata := range versions {
+		v, err := semver.NewVersion(metadata.Version)
+		if err != nil {
+			continue
+		}
+		if c.Check(v) {
+			result := metadata
+			return &result, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w %q for chart %s", ErrNoMatchingVersion, constraint, chartName)
+}
+
 func (s *ChartService) ChartExists(chartName string, version string) bool {
-	_, err := os.Stat(s.pathManager.GetChartPath(chartName, version))
+	_, err := os.Stat(s.chartPath(chartName, version))
 	return !os.IsNotExist(err)
 }
 
 func (s *ChartService) GetChart(chartName string, version string) ([]byte, error) {
-	chartPath := s.pathManager.GetChartPath(chartName, version)
+	chartPath := s.chartPath(chartName, version)
 	// Vérifier si le chart existe
 	if !s.ChartExists(chartName, version) {
 		return nil, fmt.Errorf("chart %s version %s not found", chartName, version)
@@ -182,7 +372,7 @@ func (s *ChartService) GetChart(chartName string, version string) ([]byte, error
 }
 
 func (s *ChartService) GetChartDetails(chartName string, version string) (*models.ChartMetadata, error) {
-	chartPath := s.pathManager.GetChartPath(chartName, version)
+	chartPath := s.chartPath(chartName, version)
 	// Vérifier si le chart existe
 	if !s.ChartExists(chartName, version) {
 		return nil, fmt.Errorf("chart %s version %s not found", chartName, version)
@@ -196,6 +386,7 @@ func (s *ChartService) GetChartDetails(chartName string, version string) (*model
 	if err != nil {
 		return nil, fmt.Errorf("failed to extract metadata: %w", err)
 	}
+	s.applyChartStatus(metadata)
 	return metadata, nil
 }
 
@@ -211,9 +402,33 @@ func (s *ChartService) DeleteChart(chartName string, version string) error {
 	if err := os.Remove(chartPath); err != nil {
 		return fmt.Errorf("failed to delete chart: %w", err)
 	}
+	if err := os.Remove(s.pathManager.GetChartStatusPath(chartName, version)); err != nil && !os.IsNotExist(err) {
+		s.log.WithError(err).Warn("⚠️ Failed to delete chart status")
+	}
+	if err := os.Remove(chartPath + ".prov"); err != nil && !os.IsNotExist(err) {
+		s.log.WithError(err).Warn("⚠️ Failed to delete chart provenance")
+	}
+	// Manifest OCI d'un chart poussé avec helm push, qui remplace le "+" de la version par "_"
+	for _, tag := range []string{version, strings.ReplaceAll(version, "+", "_")} {
+		manifestPath := s.pathManager.GetManifestPath(chartName, tag)
+		if err := os.Remove(manifestPath); err != nil && !os.IsNotExist(err) {
+			s.log.WithError(err).Warn("⚠️ Failed to delete chart manifest")
+		}
+		os.Remove(utils.MediaTypePath(manifestPath))
+	}
 
 	// Mettre à jour l'index
-	return s.indexUpdater.UpdateIndex()
+	if err := s.indexUpdater.UpdateIndex(); err != nil {
+		return err
+	}
+
+	s.publish(models.ArtifactEvent{
+		Action:    models.EventDelete,
+		Type:      models.ArtifactTypeHelmChart,
+		Name:      chartName,
+		Reference: version,
+	})
+	return nil
 }
 
 func (s *ChartService) GetChartValues(chartName string, version string) (string, error) {
@@ -227,33 +442,27 @@ func (s *ChartService) GetChartValues(chartName string, version string) (string,
 	}
 	defer f.Close()
 
-	// 🗜️ Lire le contenu du tgz
-	gzf, err := gzip.NewReader(f)
-	if err != nil {
-		return "", fmt.Errorf("❌ failed to create gzip reader: %w", err)
-	}
-	defer gzf.Close()
-
-	// 📄 Lire le tar
-	tr := tar.NewReader(gzf)
-	for {
-		header, err := tr.Next()
-		if err == io.EOF {
-			break
+	// 🔍 Chercher values.yaml
+	var values []byte
+	found := false
+	err = walkChartArchive(f, func(name string, _ *tar.Header, content io.Reader) error {
+		// Seul le values.yaml du chart lui-même, pas celui des sous-charts (charts/*/values.yaml)
+		if name != "values.yaml" {
+			return nil
 		}
+		data, err := io.ReadAll(content)
 		if err != nil {
-			return "", fmt.Errorf("❌ failed to read tar: %w", err)
-		}
-
-		// 🔍 Chercher values.yaml
-		if strings.HasSuffix(header.Name, "values.yaml") {
-			content, err := io.ReadAll(tr)
-			if err != nil {
-				return "", fmt.Errorf("❌ failed to read values.yaml: %w", err)
-			}
-			return string(content), nil
+			return fmt.Errorf("❌ failed to read values.yaml: %w", err)
 		}
+		values, found = data, true
+		return errStopWalk
+	})
+	if err != nil {
+		return "", fmt.Errorf("❌ failed to read chart: %w", err)
+	}
+	if !found {
+		return "", fmt.Errorf("❌ values.yaml not found in chart")
 	}
 
-	return "", fmt.Errorf("❌ values.yaml not found in chart")
+	return string(values), nil
 }
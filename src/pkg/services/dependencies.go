@@ -0,0 +1,269 @@
+// pkg/services/dependencies.go
+package service
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"helm-portal/pkg/models"
+
+	"github.com/Masterminds/semver/v3"
+	"gopkg.in/yaml.v2"
+)
+
+// CheckDependencies resolves every dependency of a chart version against the
+// hosted charts and the copies vendored in its charts/ directory
+func (s *ChartService) CheckDependencies(chartName string, version string) (*models.DependencyReport, error) {
+	if !s.ChartExists(chartName, version) {
+		return nil, fmt.Errorf("%w: %s-%s", ErrChartNotFound, chartName, version)
+	}
+	chartData, err := s.GetChart(chartName, version)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := s.ExtractChartMetadata(chartData)
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to extract metadata: %w", err)
+	}
+	files, err := readChartFiles(chartData)
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to read chart: %w", err)
+	}
+	vendored := s.vendoredCharts(files)
+
+	report := &models.DependencyReport{
+		Name:         metadata.Name,
+		Version:      metadata.Version,
+		Dependencies: []models.DependencyCheck{},
+	}
+
+	declared := make(map[string]bool)
+	for _, dep := range metadata.Dependencies {
+		declared[dep.Name] = true
+		report.Dependencies = append(report.Dependencies, s.checkDependency(dep, vendored))
+	}
+
+	// A vendored chart that is not declared is never loaded by Helm
+	for _, name := range sortedKeys(vendored) {
+		if declared[name] {
+			continue
+		}
+		report.Dependencies = append(report.Dependencies, models.DependencyCheck{
+			Name:            name,
+			Status:          models.DependencyMismatch,
+			VendoredVersion: vendored[name],
+			Message:         "vendored in charts/ but not declared in Chart.yaml",
+		})
+	}
+
+	return report, nil
+}
+
+func (s *ChartService) checkDependency(dep models.ChartDependency, vendored map[string]string) models.DependencyCheck {
+	check := models.DependencyCheck{
+		Name:       dep.Name,
+		Alias:      dep.Alias,
+		Constraint: dep.Version,
+		Repository: dep.Repository,
+	}
+
+	constraint := dep.Version
+	if constraint == "" {
+		constraint = "*"
+	}
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		check.Status = models.DependencyUnresolved
+		check.Message = fmt.Sprintf("invalid version constraint %q", dep.Version)
+		return check
+	}
+
+	vendoredVersion, isVendored := vendored[dep.Name]
+	if isVendored {
+		check.VendoredVersion = vendoredVersion
+		if v, err := semver.NewVersion(vendoredVersion); err != nil || !c.Check(v) {
+			check.Status = models.DependencyMismatch
+			check.Message = fmt.Sprintf("vendored version %s does not satisfy %s", vendoredVersion, constraint)
+			return check
+		}
+	}
+
+	switch {
+	case dep.Repository == "" || strings.HasPrefix(dep.Repository, "file://"):
+		if isVendored {
+			check.Status = models.DependencyVendored
+			return check
+		}
+		check.Status = models.DependencyUnresolved
+		check.Message = "local dependency is not vendored in charts/"
+		return check
+
+	case s.isHostedRepository(dep.Repository):
+		resolved, err := s.ResolveChartVersion(dep.Name, constraint)
+		if err == nil {
+			check.Status = models.DependencyResolved
+			check.ResolvedVersion = resolved.Version
+			return check
+		}
+		if isVendored {
+			check.Status = models.DependencyVendored
+			return check
+		}
+		check.Status = models.DependencyUnresolved
+		if errors.Is(err, ErrChartNotFound) {
+			check.Message = fmt.Sprintf("chart %s is not hosted here", dep.Name)
+		} else {
+			check.Message = fmt.Sprintf("no hosted version of %s satisfies %s", dep.Name, constraint)
+		}
+		return check
+	}
+
+	if isVendored {
+		check.Status = models.DependencyVendored
+		return check
+	}
+	check.Status = models.DependencyExternal
+	return check
+}
+
+// isHostedRepository tells whether a dependency repository points to this portal,
+// i.e. is one of charts.dependencies.hostedRepositories. Without configured URLs, no repository is hosted.
+func (s *ChartService) isHostedRepository(repository string) bool {
+	if !strings.HasPrefix(repository, "oci://") &&
+		!strings.HasPrefix(repository, "http://") &&
+		!strings.HasPrefix(repository, "https://") {
+		return false
+	}
+
+	repository = strings.TrimSuffix(repository, "/")
+	for _, prefix := range s.config.Charts.Dependencies.HostedRepositories {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if repository == prefix || strings.HasPrefix(repository, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// vendoredCharts returns the name and version of the charts packaged in charts/,
+// either as archives (charts/x-1.0.0.tgz) or as directories (charts/x/Chart.yaml)
+func (s *ChartService) vendoredCharts(files map[string][]byte) map[string]string {
+	vendored := make(map[string]string)
+	for name, data := range files {
+		parts := strings.Split(name, "/")
+		if parts[0] != "charts" {
+			continue
+		}
+
+		var metadata *models.ChartMetadata
+		switch {
+		case len(parts) == 2 && path.Ext(parts[1]) == ".tgz":
+			m, err := s.ExtractChartMetadata(data)
+			if err != nil {
+				s.log.WithError(err).WithField("file", name).Warn("⚠️ Invalid vendored chart")
+				continue
+			}
+			metadata = m
+		case len(parts) == 3 && parts[2] == "Chart.yaml":
+			metadata = &models.ChartMetadata{}
+			if err := yaml.Unmarshal(data, metadata); err != nil {
+				s.log.WithError(err).WithField("file", name).Warn("⚠️ Invalid vendored Chart.yaml")
+				continue
+			}
+		default:
+			continue
+		}
+
+		if metadata.Name != "" {
+			vendored[metadata.Name] = metadata.Version
+		}
+	}
+	return vendored
+}
+
+// GetDependents returns the hosted chart versions that depend on a chart.
+// With a constraint (e.g. "2.x"), only dependents that can use a version
+// of the chart satisfying it are returned.
+func (s *ChartService) GetDependents(chartName string, constraint string) ([]models.Dependent, error) {
+	var filter *semver.Constraints
+	if constraint != "" {
+		c, err := semver.NewConstraint(constraint)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %v", ErrInvalidConstraint, constraint, err)
+		}
+		filter = c
+	}
+
+	groups, err := s.ListCharts()
+	if err != nil {
+		return nil, err
+	}
+
+	// Versions of the chart hosted here, used to intersect both constraints
+	var hosted []*semver.Version
+	if versions, err := s.GetChartVersions(chartName); err == nil {
+		for _, m := range versions {
+			if v, err := semver.NewVersion(m.Version); err == nil {
+				hosted = append(hosted, v)
+			}
+		}
+	}
+
+	dependents := []models.Dependent{}
+	for _, group := range groups {
+		for _, metadata := range group.Versions {
+			for _, dep := range metadata.Dependencies {
+				if dep.Name != chartName {
+					continue
+				}
+				if filter != nil && !dependencyMatches(dep.Version, filter, hosted) {
+					continue
+				}
+				dependents = append(dependents, models.Dependent{
+					Name:       metadata.Name,
+					Version:    metadata.Version,
+					Constraint: dep.Version,
+					Repository: dep.Repository,
+				})
+			}
+		}
+	}
+
+	return dependents, nil
+}
+
+// dependencyMatches tells whether a declared constraint can select a version
+// accepted by the filter, among the hosted versions or the declared version itself
+func dependencyMatches(declared string, filter *semver.Constraints, hosted []*semver.Version) bool {
+	if declared == "" {
+		declared = "*"
+	}
+	candidates := hosted
+	if v, err := semver.NewVersion(declared); err == nil {
+		candidates = append([]*semver.Version{v}, hosted...)
+	}
+
+	c, err := semver.NewConstraint(declared)
+	if err != nil {
+		return false
+	}
+	for _, v := range candidates {
+		if c.Check(v) && filter.Check(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the keys of a map in alphabetical order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,248 @@
+// pkg/services/diff.go
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"helm-portal/pkg/models"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"sigs.k8s.io/yaml"
+)
+
+// DiffChartVersions compares two stored versions of the same chart: file list,
+// Chart.yaml fields, values.yaml (semantic comparison) and templates (unified diff)
+func (s *ChartService) DiffChartVersions(chartName string, from string, to string) (*models.ChartDiff, error) {
+	fromFiles, err := s.loadChartFiles(chartName, from)
+	if err != nil {
+		return nil, err
+	}
+	toFiles, err := s.loadChartFiles(chartName, to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &models.ChartDiff{
+		Name:      chartName,
+		From:      from,
+		To:        to,
+		Files:     diffFileList(fromFiles, toFiles),
+		Templates: diffTemplates(fromFiles, toFiles),
+	}
+
+	if diff.Metadata, err = diffChartYAML(fromFiles["Chart.yaml"], toFiles["Chart.yaml"]); err != nil {
+		return nil, fmt.Errorf("❌ failed to compare Chart.yaml: %w", err)
+	}
+	if diff.Values, err = diffValuesYAML(fromFiles["values.yaml"], toFiles["values.yaml"]); err != nil {
+		return nil, fmt.Errorf("❌ failed to compare values.yaml: %w", err)
+	}
+
+	return diff, nil
+}
+
+// loadChartFiles reads every file of a stored chart version
+func (s *ChartService) loadChartFiles(chartName string, version string) (map[string][]byte, error) {
+	chartData, err := s.GetChart(chartName, version)
+	if err != nil {
+		return nil, err
+	}
+	files, err := readChartFiles(chartData)
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to read chart %s-%s: %w", chartName, version, err)
+	}
+	return files, nil
+}
+
+func diffFileList(fromFiles, toFiles map[string][]byte) []models.FileChange {
+	changes := []models.FileChange{}
+	for _, name := range unionKeys(fromFiles, toFiles) {
+		fromData, inFrom := fromFiles[name]
+		toData, inTo := toFiles[name]
+
+		change := models.FileChange{Path: name, FromSize: int64(len(fromData)), ToSize: int64(len(toData))}
+		switch {
+		case !inFrom:
+			change.Change = models.ChangeAdded
+		case !inTo:
+			change.Change = models.ChangeRemoved
+		case !bytes.Equal(fromData, toData):
+			change.Change = models.ChangeModified
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+func diffTemplates(fromFiles, toFiles map[string][]byte) []models.TemplateChange {
+	changes := []models.TemplateChange{}
+	for _, name := range unionKeys(fromFiles, toFiles) {
+		if !strings.HasPrefix(name, "templates/") {
+			continue
+		}
+		fromData, inFrom := fromFiles[name]
+		toData, inTo := toFiles[name]
+		if inFrom && inTo && bytes.Equal(fromData, toData) {
+			continue
+		}
+
+		change := models.ChangeModified
+		switch {
+		case !inFrom:
+			change = models.ChangeAdded
+		case !inTo:
+			change = models.ChangeRemoved
+		}
+
+		unified, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(fromData)),
+			B:        difflib.SplitLines(string(toData)),
+			FromFile: "a/" + name,
+			ToFile:   "b/" + name,
+			Context:  3,
+		})
+		changes = append(changes, models.TemplateChange{Path: name, Change: change, Diff: unified})
+	}
+	return changes
+}
+
+func diffChartYAML(fromData, toData []byte) ([]models.FieldChange, error) {
+	fromFields, err := parseYAMLMap(fromData)
+	if err != nil {
+		return nil, err
+	}
+	toFields, err := parseYAMLMap(toData)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []models.FieldChange{}
+	for _, field := range unionKeys(fromFields, toFields) {
+		if reflect.DeepEqual(fromFields[field], toFields[field]) {
+			continue
+		}
+		changes = append(changes, models.FieldChange{
+			Field: field,
+			From:  formatDiffValue(fromFields[field]),
+			To:    formatDiffValue(toFields[field]),
+		})
+	}
+	return changes, nil
+}
+
+func diffValuesYAML(fromData, toData []byte) ([]models.ValueChange, error) {
+	fromValues, err := chartutil.ReadValues(fromData)
+	if err != nil {
+		return nil, err
+	}
+	toValues, err := chartutil.ReadValues(toData)
+	if err != nil {
+		return nil, err
+	}
+
+	fromFlat := make(map[string]interface{})
+	flattenValues("", map[string]interface{}(fromValues), fromFlat)
+	toFlat := make(map[string]interface{})
+	flattenValues("", map[string]interface{}(toValues), toFlat)
+
+	changes := []models.ValueChange{}
+	for _, key := range unionKeys(fromFlat, toFlat) {
+		fromValue, inFrom := fromFlat[key]
+		toValue, inTo := toFlat[key]
+
+		change := models.ValueChange{Path: key, From: formatDiffValue(fromValue), To: formatDiffValue(toValue)}
+		switch {
+		case !inFrom:
+			change.Change = models.ChangeAdded
+		case !inTo:
+			change.Change = models.ChangeRemoved
+		case !reflect.DeepEqual(fromValue, toValue):
+			change.Change = models.ChangeModified
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// flattenValues turns nested values into dotted paths. Empty maps and lists are kept as leaves.
+func flattenValues(prefix string, value interface{}, out map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 && prefix != "" {
+			out[prefix] = v
+			return
+		}
+		for key, child := range v {
+			childPath := key
+			if prefix != "" {
+				childPath = prefix + "." + key
+			}
+			flattenValues(childPath, child, out)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			out[prefix] = v
+			return
+		}
+		for i, child := range v {
+			flattenValues(fmt.Sprintf("%s[%d]", prefix, i), child, out)
+		}
+	default:
+		out[prefix] = v
+	}
+}
+
+func parseYAMLMap(data []byte) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if len(data) == 0 {
+		return fields, nil
+	}
+	if err := yaml.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// formatDiffValue renders a value for display: scalars as-is, maps and lists as JSON
+func formatDiffValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(data)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// unionKeys returns the sorted keys present in either map
+func unionKeys[V any](a, b map[string]V) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	for k := range a {
+		seen[k] = true
+	}
+	for k := range b {
+		seen[k] = true
+	}
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,37 @@
+// pkg/services/events.go
+package service
+
+import (
+	"sync"
+
+	"helm-portal/pkg/models"
+)
+
+// ArtifactListener is notified after a chart or an image is pushed, updated or deleted
+type ArtifactListener interface {
+	OnArtifactEvent(event models.ArtifactEvent)
+}
+
+// eventPublisher is embedded by the services that emit artifact events
+type eventPublisher struct {
+	mu        sync.RWMutex
+	listeners []ArtifactListener
+}
+
+// Subscribe registers a listener for the artifact events of the service
+func (p *eventPublisher) Subscribe(listener ArtifactListener) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.listeners = append(p.listeners, listener)
+}
+
+// publish calls every listener synchronously, in subscription order
+func (p *eventPublisher) publish(event models.ArtifactEvent) {
+	p.mu.RLock()
+	listeners := append([]ArtifactListener(nil), p.listeners...)
+	p.mu.RUnlock()
+
+	for _, listener := range listeners {
+		listener.OnArtifactEvent(event)
+	}
+}
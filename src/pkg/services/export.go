@@ -0,0 +1,163 @@
+// pkg/services/export.go
+package service
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"helm-portal/pkg/models"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// ErrInvalidExportOptions is returned for a malformed export selection
+var ErrInvalidExportOptions = errors.New("invalid export options")
+
+// exportChartsDir is the directory of the archives in an export, relative to its index.yaml
+const exportChartsDir = "charts/"
+
+// SelectExport returns the index of the charts selected by opts, with URLs relative
+// to the index so that the bundle can be served from any location. Yanked versions are left out.
+func (s *IndexService) SelectExport(opts models.ExportOptions) (*IndexFile, error) {
+	if opts.Latest < 0 {
+		return nil, fmt.Errorf("%w: latest must be positive", ErrInvalidExportOptions)
+	}
+	if opts.Pattern != "" {
+		if _, err := path.Match(opts.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("%w: bad pattern %q", ErrInvalidExportOptions, opts.Pattern)
+		}
+	}
+
+	index, err := s.buildIndex(exportChartsDir, func(name string) bool {
+		if opts.Pattern == "" {
+			return true
+		}
+		matched, _ := path.Match(opts.Pattern, name)
+		return matched
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Versions les plus récentes d'abord, comme `helm repo index`
+	for name, versions := range index.Entries {
+		sort.SliceStable(versions, func(i, j int) bool {
+			vi, errI := semver.NewVersion(versions[i].Version)
+			vj, errJ := semver.NewVersion(versions[j].Version)
+			if errI != nil || errJ != nil {
+				return versions[i].Version > versions[j].Version
+			}
+			return vi.GreaterThan(vj)
+		})
+		if opts.Latest > 0 && len(versions) > opts.Latest {
+			versions = versions[:opts.Latest]
+		}
+		index.Entries[name] = versions
+	}
+	return index, nil
+}
+
+// WriteExport writes a static Helm repository as a tar.gz: index.yaml, the chart archives
+// of the index under charts/ and their provenance files when stored.
+func (s *IndexService) WriteExport(w io.Writer, index *IndexFile) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	now := time.Now()
+
+	indexYAML, err := yaml.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("❌ failed to marshal index: %w", err)
+	}
+	if err := writeExportEntry(tw, "index.yaml", indexYAML, now); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(index.Entries))
+	for name := range index.Entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	chartsDir := s.pathManager.GetChartsPath()
+	for _, name := range names {
+		for _, version := range index.Entries[name] {
+			file := strings.TrimPrefix(version.URLs[0], exportChartsDir)
+			data, err := os.ReadFile(filepath.Join(chartsDir, file))
+			if err != nil {
+				return fmt.Errorf("❌ failed to read %s: %w", file, err)
+			}
+			if err := writeExportEntry(tw, exportChartsDir+file, data, now); err != nil {
+				return err
+			}
+
+			prov, err := os.ReadFile(filepath.Join(chartsDir, file+".prov"))
+			if err == nil {
+				err = writeExportEntry(tw, exportChartsDir+file+".prov", prov, now)
+			} else if os.IsNotExist(err) {
+				err = nil
+			}
+			if err != nil {
+				return fmt.Errorf("❌ failed to export provenance of %s: %w", file, err)
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// Export writes the static Helm repository of the charts selected by opts
+// and returns its index
+func (s *IndexService) Export(w io.Writer, opts models.ExportOptions) (*IndexFile, error) {
+	index, err := s.SelectExport(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.WriteExport(w, index); err != nil {
+		return nil, err
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"pattern":  opts.Pattern,
+		"latest":   opts.Latest,
+		"charts":   len(index.Entries),
+		"versions": index.CountVersions(),
+	}).Info("✅ Static repository exported")
+	return index, nil
+}
+
+// CountVersions returns the number of chart versions of the index
+func (i *IndexFile) CountVersions() int {
+	count := 0
+	for _, versions := range i.Entries {
+		count += len(versions)
+	}
+	return count
+}
+
+func writeExportEntry(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return fmt.Errorf("❌ failed to write %s: %w", name, err)
+	}
+	_, err := tw.Write(data)
+	return err
+}
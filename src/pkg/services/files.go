@@ -0,0 +1,161 @@
+// pkg/services/files.go
+package service
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"helm-portal/pkg/models"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// defaultMaxFileSize is used when no browser limit is configured (1 MiB)
+const defaultMaxFileSize int64 = 1 << 20
+
+var (
+	// ErrFileNotFound is returned when a path does not exist in a chart archive
+	ErrFileNotFound = errors.New("file not found in chart")
+	// ErrFileTooLarge is returned when a file exceeds the browser size limit
+	ErrFileTooLarge = errors.New("file too large")
+)
+
+// markdown renders documentation without raw HTML and with dangerous links removed
+var markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// languageByExtension maps file extensions to highlight.js language names
+var languageByExtension = map[string]string{
+	".yaml": "yaml",
+	".yml":  "yaml",
+	".json": "json",
+	".md":   "markdown",
+	".tpl":  "go",
+	".txt":  "plaintext",
+	".toml": "toml",
+	".sh":   "bash",
+}
+
+// ListChartFiles returns every regular file of a stored chart, sorted by path
+func (s *ChartService) ListChartFiles(chartName string, version string) ([]models.ChartFile, error) {
+	f, err := s.openChart(chartName, version)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	files := []models.ChartFile{}
+	var total int64
+	err = walkChartArchive(f, func(name string, header *tar.Header, _ io.Reader) error {
+		total += header.Size
+		if total > maxDecompressedChartSize {
+			return fmt.Errorf("decompressed chart exceeds %d bytes", maxDecompressedChartSize)
+		}
+		files = append(files, models.ChartFile{Path: name, Size: header.Size})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to read chart: %w", err)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// GetChartFile reads a single file of a stored chart. Text files are returned
+// as-is, documentation files are also rendered as HTML.
+func (s *ChartService) GetChartFile(chartName string, version string, filePath string) (*models.ChartFileContent, error) {
+	filePath = path.Clean(strings.TrimPrefix(filePath, "/"))
+
+	f, err := s.openChart(chartName, version)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	maxSize := s.config.Charts.Browser.MaxFileSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxFileSize
+	}
+
+	var file *models.ChartFileContent
+	err = walkChartArchive(f, func(name string, header *tar.Header, content io.Reader) error {
+		if name != filePath {
+			return nil
+		}
+		if header.Size > maxSize {
+			return fmt.Errorf("%w: %s is %d bytes, maximum is %d bytes", ErrFileTooLarge, name, header.Size, maxSize)
+		}
+		data, err := io.ReadAll(io.LimitReader(content, maxSize+1))
+		if err != nil {
+			return fmt.Errorf("❌ failed to read %s: %w", name, err)
+		}
+		if int64(len(data)) > maxSize {
+			return fmt.Errorf("%w: %s exceeds %d bytes", ErrFileTooLarge, name, maxSize)
+		}
+		file = newChartFileContent(name, data)
+		return errStopWalk
+	})
+	if err != nil {
+		return nil, err
+	}
+	if file == nil {
+		return nil, fmt.Errorf("%w: %s", ErrFileNotFound, filePath)
+	}
+	return file, nil
+}
+
+// openChart opens the archive of a stored chart version
+func (s *ChartService) openChart(chartName string, version string) (*os.File, error) {
+	f, err := os.Open(s.pathManager.GetChartPath(chartName, version))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s-%s", ErrChartNotFound, chartName, version)
+		}
+		return nil, fmt.Errorf("❌ failed to open chart file: %w", err)
+	}
+	return f, nil
+}
+
+func newChartFileContent(name string, data []byte) *models.ChartFileContent {
+	file := &models.ChartFileContent{
+		Path: name,
+		Size: int64(len(data)),
+	}
+	if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+		file.Binary = true
+		return file
+	}
+
+	file.Content = string(data)
+	file.Language = languageByExtension[strings.ToLower(path.Ext(name))]
+	if file.Language == "" {
+		file.Language = "plaintext"
+	}
+	file.HTML = renderDocumentation(name, data)
+	return file
+}
+
+// renderDocumentation returns safe HTML for the documentation files of the chart itself
+func renderDocumentation(name string, data []byte) string {
+	switch strings.ToUpper(name) {
+	case "README.MD", "CHANGELOG.MD":
+		var buf bytes.Buffer
+		if err := markdown.Convert(data, &buf); err != nil {
+			return "<pre>" + html.EscapeString(string(data)) + "</pre>"
+		}
+		return buf.String()
+	case "TEMPLATES/NOTES.TXT":
+		return "<pre>" + html.EscapeString(string(data)) + "</pre>"
+	}
+	return ""
+}
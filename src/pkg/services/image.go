@@ -2,8 +2,10 @@
 package service
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -22,62 +24,116 @@ type ImageService struct {
 	pathManager *utils.PathManager
 	config      *config.Config
 	log         *utils.Logger
+	policy      *OverwritePolicy
+	eventPublisher
 }
 
 // NewImageService creates a new image service
 func NewImageService(config *config.Config, log *utils.Logger) *ImageService {
-	pm := utils.NewPathManager(config.Storage.Path, log)
+	return NewRepositoryImageService(config, log, utils.NewPathManager(config.Storage.Path, log))
+}
 
+// NewRepositoryImageService creates an image service storing its images under the given path manager
+func NewRepositoryImageService(config *config.Config, log *utils.Logger, pathManager *utils.PathManager) *ImageService {
 	// Create images directory
-	imagesDir := filepath.Join(config.Storage.Path, "images")
-	if err := os.MkdirAll(imagesDir, 0755); err != nil {
+	if err := os.MkdirAll(pathManager.GetImagesPath(), 0755); err != nil {
 		log.WithError(err).Error("Failed to create images directory")
 	}
 
 	return &ImageService{
-		pathManager: pm,
+		pathManager: pathManager,
 		config:      config,
 		log:         log,
+		policy:      NewOverwritePolicy(config, log),
 	}
 }
 
+// WithNamespace applies the overwrite rules of an isolated repository to its images, matched as <namespace>/<name>
+func (s *ImageService) WithNamespace(namespace string) *ImageService {
+	s.policy.WithNamespace(namespace)
+	return s
+}
+
 // GetPathManager returns the path manager
 func (s *ImageService) GetPathManager() *utils.PathManager {
 	return s.pathManager
 }
 
-// SaveImage saves a Docker image manifest and updates metadata
-func (s *ImageService) SaveImage(name, reference string, manifest *models.OCIManifest) error {
+// ErrInvalidManifest is returned when a pushed manifest cannot be parsed
+var ErrInvalidManifest = errors.New("invalid manifest")
+
+// ErrManifestDigestMismatch is returned when a manifest pushed by digest does not match it
+var ErrManifestDigestMismatch = errors.New("manifest digest does not match reference")
+
+// SaveImage saves a Docker image manifest and updates metadata.
+// The manifest is stored byte for byte as pushed, so that its digest is the one returned
+// to the client; mediaType is the Content-Type of the push (see models.ManifestMediaType).
+func (s *ImageService) SaveImage(name, reference string, manifestData []byte, mediaType string) error {
+	return s.saveImage(name, reference, manifestData, mediaType, models.EventPush)
+}
+
+// CacheImage stores a manifest fetched from an upstream registry by the pull-through cache. The upstream is the
+// source of truth: the overwrite policy does not apply and the event published is EventCache, not EventPush.
+func (s *ImageService) CacheImage(name, reference string, manifestData []byte, mediaType string) error {
+	return s.saveImage(name, reference, manifestData, mediaType, models.EventCache)
+}
+
+// CheckOverwrite returns a *VersionConflictError when saving a manifest under a tag would replace
+// different content that the overwrite policy protects
+func (s *ImageService) CheckOverwrite(name, reference string, manifestData []byte) error {
+	if strings.HasPrefix(reference, "sha256:") {
+		return nil
+	}
+	if existing, err := os.ReadFile(s.getManifestPath(name, reference)); err == nil && !bytes.Equal(existing, manifestData) {
+		return s.policy.Check(name, reference)
+	}
+	return nil
+}
+
+func (s *ImageService) saveImage(name, reference string, manifestData []byte, mediaType, action string) error {
 	s.log.WithFields(logrus.Fields{
 		"name":      name,
 		"reference": reference,
+		"action":    action,
 	}).Info("Saving Docker image")
 
+	var manifest models.OCIManifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidManifest, err)
+	}
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(manifestData))
+	if strings.HasPrefix(reference, "sha256:") && reference != digest {
+		return fmt.Errorf("%w: got %s", ErrManifestDigestMismatch, digest)
+	}
+	mediaType = models.ManifestMediaType(manifestData, mediaType)
+
 	// Create image directory
 	imageDir := s.getImageDir(name)
 	if err := os.MkdirAll(imageDir, 0755); err != nil {
 		return fmt.Errorf("failed to create image directory: %w", err)
 	}
 
-	// Save manifest
-	manifestData, err := json.Marshal(manifest)
-	if err != nil {
-		return fmt.Errorf("failed to marshal manifest: %w", err)
+	manifestPath := s.getManifestPath(name, reference)
+
+	if action != models.EventCache {
+		if err := s.CheckOverwrite(name, reference, manifestData); err != nil {
+			return err
+		}
 	}
 
-	manifestPath := s.getManifestPath(name, reference)
 	if err := os.MkdirAll(filepath.Dir(manifestPath), 0755); err != nil {
 		return fmt.Errorf("failed to create manifest directory: %w", err)
 	}
-	if err := os.WriteFile(manifestPath, manifestData, 0644); err != nil {
+	if err := s.writeManifest(manifestPath, manifestData, mediaType); err != nil {
 		return fmt.Errorf("failed to save manifest: %w", err)
 	}
 
-	// Calculate and save digest-based reference
-	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(manifestData))
+	// Save the digest-based reference, content-addressed by the pushed bytes
 	digestPath := s.getManifestPath(name, digest)
-	if err := os.WriteFile(digestPath, manifestData, 0644); err != nil {
-		s.log.WithError(err).Warn("Failed to save digest reference")
+	if digestPath != manifestPath {
+		if err := s.writeManifest(digestPath, manifestData, mediaType); err != nil {
+			s.log.WithError(err).Warn("Failed to save digest reference")
+		}
 	}
 
 	// Create/update metadata
@@ -86,21 +142,29 @@ func (s *ImageService) SaveImage(name, reference string, manifest *models.OCIMan
 		Repository: name,
 		Tag:        reference,
 		Digest:     digest,
+		MediaType:  mediaType,
 		Size:       manifest.GetTotalSize(),
 		Created:    time.Now(),
-		Layers:     s.extractLayerInfo(manifest),
+		Layers:     s.extractLayerInfo(&manifest),
 	}
 
-	// Try to extract config if available
-	if config, err := s.extractConfigFromBlob(manifest.Config.Digest); err == nil {
+	if models.IsIndexMediaType(mediaType) {
+		// Multi-platform index: no config, its images are pushed by digest beforehand
+		metadata.Platforms, metadata.Size = s.indexPlatforms(name, manifestData)
+	} else if config, err := s.extractConfigFromBlob(manifest.Config.Digest); err == nil {
+		// Try to extract config if available
 		metadata.Config = config
 	}
 
-	// Save metadata
-	metadataPath := s.getMetadataPath(name, reference)
-	metadataData, _ := json.MarshalIndent(metadata, "", "  ")
-	if err := os.WriteFile(metadataPath, metadataData, 0644); err != nil {
-		s.log.WithError(err).Warn("Failed to save metadata")
+	// Save metadata (tags only, a digest reference is not a tag)
+	if !strings.HasPrefix(reference, "sha256:") {
+		metadataPath := s.getMetadataPath(name, reference)
+		metadataData, _ := json.MarshalIndent(metadata, "", "  ")
+		if err := os.MkdirAll(filepath.Dir(metadataPath), 0755); err != nil {
+			s.log.WithError(err).Warn("Failed to create tags directory")
+		} else if err := os.WriteFile(metadataPath, metadataData, 0644); err != nil {
+			s.log.WithError(err).Warn("Failed to save metadata")
+		}
 	}
 
 	s.log.WithFields(logrus.Fields{
@@ -110,6 +174,13 @@ func (s *ImageService) SaveImage(name, reference string, manifest *models.OCIMan
 		"size":   metadata.Size,
 	}).Info("Docker image saved successfully")
 
+	s.publish(models.ArtifactEvent{
+		Action:    action,
+		Type:      models.ArtifactTypeDockerImage,
+		Name:      name,
+		Reference: reference,
+		Digest:    digest,
+	})
 	return nil
 }
 
@@ -124,28 +195,32 @@ func (s *ImageService) ListImages() ([]models.ImageGroup, error) {
 
 	var allImages []models.ImageMetadata
 
-	// Read images directory
-	repos, err := os.ReadDir(imagesDir)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read images directory: %w", err)
-	}
-
-	for _, repo := range repos {
-		if !repo.IsDir() {
-			continue
+	// Les noms peuvent avoir plusieurs segments (library/nginx) : tout répertoire avec des tags est une image
+	err := filepath.WalkDir(imagesDir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if entry.Name() == "tags" || entry.Name() == "manifests" {
+			return filepath.SkipDir
 		}
 
-		repoName := repo.Name()
-		tagsDir := filepath.Join(imagesDir, repoName, "tags")
-
+		tagsDir := filepath.Join(path, "tags")
 		if _, err := os.Stat(tagsDir); os.IsNotExist(err) {
-			continue
+			return nil
 		}
+		rel, err := filepath.Rel(imagesDir, path)
+		if err != nil {
+			return err
+		}
+		repoName := filepath.ToSlash(rel)
 
 		tags, err := os.ReadDir(tagsDir)
 		if err != nil {
 			s.log.WithError(err).WithField("repo", repoName).Warn("Failed to read tags")
-			continue
+			return nil
 		}
 
 		for _, tagFile := range tags {
@@ -165,6 +240,10 @@ func (s *ImageService) ListImages() ([]models.ImageGroup, error) {
 
 			allImages = append(allImages, *metadata)
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read images directory: %w", err)
 	}
 
 	return models.GroupImagesByName(allImages), nil
@@ -211,13 +290,20 @@ func (s *ImageService) GetImageMetadata(name, tag string) (*models.ImageMetadata
 			return nil, fmt.Errorf("image not found: %w", err)
 		}
 
-		return &models.ImageMetadata{
+		metadata := &models.ImageMetadata{
 			Name:       name,
 			Repository: name,
 			Tag:        tag,
 			Size:       manifest.GetTotalSize(),
 			Layers:     s.extractLayerInfo(manifest),
-		}, nil
+		}
+		if strings.HasPrefix(tag, "sha256:") {
+			metadata.Digest = tag
+		}
+		if mediaType, err := os.ReadFile(utils.MediaTypePath(s.getManifestPath(name, tag))); err == nil {
+			metadata.MediaType = string(mediaType)
+		}
+		return metadata, nil
 	}
 
 	var metadata models.ImageMetadata
@@ -243,6 +329,10 @@ func (s *ImageService) DeleteImage(name, tag string) error {
 		return fmt.Errorf("failed to delete manifest: %w", err)
 	}
 
+	if err := os.Remove(utils.MediaTypePath(manifestPath)); err != nil && !os.IsNotExist(err) {
+		s.log.WithError(err).Warn("Failed to delete manifest media type")
+	}
+
 	// Remove metadata
 	if err := os.Remove(metadataPath); err != nil && !os.IsNotExist(err) {
 		s.log.WithError(err).Warn("Failed to delete metadata")
@@ -253,6 +343,12 @@ func (s *ImageService) DeleteImage(name, tag string) error {
 		"tag":  tag,
 	}).Info("Docker image deleted successfully")
 
+	s.publish(models.ArtifactEvent{
+		Action:    models.EventDelete,
+		Type:      models.ArtifactTypeDockerImage,
+		Name:      name,
+		Reference: tag,
+	})
 	return nil
 }
 
@@ -309,6 +405,14 @@ func (s *ImageService) getManifestPath(name, reference string) string {
 	return filepath.Join(s.pathManager.GetBasePath(), "images", name, "manifests", safeRef+".json")
 }
 
+// writeManifest writes a manifest and the media type it was pushed with
+func (s *ImageService) writeManifest(manifestPath string, data []byte, mediaType string) error {
+	if err := os.WriteFile(manifestPath, data, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(utils.MediaTypePath(manifestPath), []byte(mediaType), 0644)
+}
+
 func (s *ImageService) getMetadataPath(name, tag string) string {
 	return filepath.Join(s.pathManager.GetBasePath(), "images", name, "tags", tag+".json")
 }
@@ -325,6 +429,39 @@ func (s *ImageService) extractLayerInfo(manifest *models.OCIManifest) []models.L
 	return layers
 }
 
+// indexPlatforms lists the platform images of an index; the size of the index is the sum of theirs
+func (s *ImageService) indexPlatforms(name string, indexData []byte) ([]models.PlatformInfo, int64) {
+	var index models.OCIIndex
+	if err := json.Unmarshal(indexData, &index); err != nil {
+		return nil, 0
+	}
+
+	platforms := make([]models.PlatformInfo, 0, len(index.Manifests))
+	var total int64
+	for _, descriptor := range index.Manifests {
+		platform := models.PlatformInfo{
+			Digest:    descriptor.Digest,
+			MediaType: descriptor.MediaType,
+			Size:      descriptor.Size,
+		}
+		if descriptor.Platform != nil {
+			platform.OS = descriptor.Platform.OS
+			platform.Architecture = descriptor.Platform.Architecture
+			platform.Variant = descriptor.Platform.Variant
+		}
+		// Taille réelle de l'image si son manifest est stocké
+		if data, err := os.ReadFile(s.getManifestPath(name, descriptor.Digest)); err == nil {
+			var manifest models.OCIManifest
+			if json.Unmarshal(data, &manifest) == nil {
+				platform.Size = manifest.GetTotalSize()
+			}
+		}
+		total += platform.Size
+		platforms = append(platforms, platform)
+	}
+	return platforms, total
+}
+
 func (s *ImageService) extractConfigFromBlob(digest string) (*models.ImageConfig, error) {
 	blobPath := s.pathManager.GetBlobPath(digest)
 
@@ -0,0 +1,493 @@
+// pkg/services/import.go
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"helm-portal/pkg/interfaces"
+	"helm-portal/pkg/models"
+	utils "helm-portal/pkg/utils"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	// ErrInvalidImportRequest is returned when the import source is missing or malformed
+	ErrInvalidImportRequest = errors.New("invalid import request")
+	// ErrImportRunning is returned when an import is started while another one is running
+	ErrImportRunning = errors.New("an import is already running")
+	// ErrImportNotFound is returned for an unknown import job
+	ErrImportNotFound = errors.New("import job not found")
+	// ErrImportDirectoryForbidden is returned for a directory outside charts.import.root
+	ErrImportDirectoryForbidden = errors.New("import directory outside charts.import.root")
+)
+
+// maxImportDownloadSize bounds the size of a downloaded index.yaml, chart or provenance file
+const maxImportDownloadSize = 100 << 20
+
+// importCandidate is a chart version found in the import source
+type importCandidate struct {
+	name    string
+	version string
+	digest  string // sha256 announced by the source index, if any
+	chart   func() ([]byte, error)
+	prov    func() ([]byte, error) // nil data when the version has no provenance file
+}
+
+// ImportService copies every chart version of an existing Helm repository
+// (a remote index.yaml, e.g. ChartMuseum, or a local directory of .tgz/.prov files)
+// through ChartService.SaveChart. Versions already stored are skipped, so re-runs are incremental.
+type ImportService struct {
+	chartService interfaces.ChartServiceInterface
+	client       *http.Client
+	log          *utils.Logger
+	root         string // only directory whose charts may be imported from the server disk
+
+	mu      sync.Mutex
+	jobs    map[string]*models.ImportJob
+	running bool
+}
+
+// NewImportService creates an import service storing charts through chartService
+func NewImportService(chartService interfaces.ChartServiceInterface, log *utils.Logger) *ImportService {
+	return &ImportService{
+		chartService: chartService,
+		client:       &http.Client{Timeout: 5 * time.Minute},
+		log:          log,
+		jobs:         make(map[string]*models.ImportJob),
+	}
+}
+
+// WithImportRoot allows the imports of the local directories under root. Without it, only URLs are imported.
+func (s *ImportService) WithImportRoot(root string) *ImportService {
+	s.root = root
+	return s
+}
+
+// StartImport validates the request and runs the import in the background.
+// Its progress is available with GetJob.
+func (s *ImportService) StartImport(req models.ImportRequest) (*models.ImportJob, error) {
+	job, err := s.newJob(req)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := s.snapshot(job)
+	go s.run(job, req)
+	return snapshot, nil
+}
+
+// RunImport runs an import and returns its final report
+func (s *ImportService) RunImport(req models.ImportRequest) (*models.ImportJob, error) {
+	job, err := s.newJob(req)
+	if err != nil {
+		return nil, err
+	}
+	s.run(job, req)
+	return s.snapshot(job), nil
+}
+
+// GetJob returns the current state of an import job
+func (s *ImportService) GetJob(id string) (*models.ImportJob, error) {
+	s.mu.Lock()
+	job, ok := s.jobs[id]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrImportNotFound, id)
+	}
+	return s.snapshot(job), nil
+}
+
+// ListJobs returns the import jobs, most recent first
+func (s *ImportService) ListJobs() []models.ImportJob {
+	s.mu.Lock()
+	jobs := make([]*models.ImportJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	s.mu.Unlock()
+
+	list := make([]models.ImportJob, 0, len(jobs))
+	for _, job := range jobs {
+		list = append(list, *s.snapshot(job))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].StartedAt.After(list[j].StartedAt)
+	})
+	return list
+}
+
+func (s *ImportService) newJob(req models.ImportRequest) (*models.ImportJob, error) {
+	source, err := s.validateImportRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return nil, ErrImportRunning
+	}
+	s.running = true
+
+	job := &models.ImportJob{
+		ID:        uuid.New().String(),
+		Source:    source,
+		DryRun:    req.DryRun,
+		Status:    models.ImportRunning,
+		Items:     []models.ImportItem{},
+		StartedAt: time.Now(),
+	}
+	s.jobs[job.ID] = job
+	return job, nil
+}
+
+// snapshot copies a job so that it can be read while the import goes on
+func (s *ImportService) snapshot(job *models.ImportJob) *models.ImportJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := *job
+	copied.Items = append([]models.ImportItem{}, job.Items...)
+	return &copied
+}
+
+func (s *ImportService) run(job *models.ImportJob, req models.ImportRequest) {
+	logger := s.log.WithFields(logrus.Fields{"job": job.ID, "source": job.Source, "dryRun": job.DryRun})
+	logger.Info("📥 Chart import started")
+
+	var candidates []importCandidate
+	var err error
+	if req.URL != "" {
+		candidates, err = s.remoteCandidates(req)
+	} else {
+		candidates, err = s.localCandidates(job.Source)
+	}
+
+	s.mu.Lock()
+	if err != nil {
+		job.Error = err.Error()
+	}
+	job.Total = len(candidates)
+	s.mu.Unlock()
+
+	if err == nil {
+		for _, candidate := range candidates {
+			item := s.importCandidate(candidate, req.DryRun)
+			if item.Status == models.ImportItemFailed {
+				logger.WithField("chart", item.Name+"-"+item.Version).Warn("⚠️ " + item.Error)
+			}
+
+			s.mu.Lock()
+			job.Items = append(job.Items, item)
+			job.Processed++
+			switch item.Status {
+			case models.ImportItemImported:
+				job.Imported++
+			case models.ImportItemPlanned:
+				job.Planned++
+			case models.ImportItemSkipped:
+				job.Skipped++
+			case models.ImportItemFailed:
+				job.Failed++
+			}
+			s.mu.Unlock()
+		}
+	}
+
+	s.mu.Lock()
+	finished := time.Now()
+	job.FinishedAt = &finished
+	job.Status = models.ImportCompleted
+	if job.Error != "" {
+		job.Status = models.ImportFailed
+	}
+	s.running = false
+	fields := logrus.Fields{
+		"imported": job.Imported,
+		"planned":  job.Planned,
+		"skipped":  job.Skipped,
+		"failed":   job.Failed,
+	}
+	s.mu.Unlock()
+
+	logger.WithFields(fields).Info("✅ Chart import finished")
+}
+
+// importCandidate stores one chart version, unless it is already there or this is a dry-run
+func (s *ImportService) importCandidate(candidate importCandidate, dryRun bool) models.ImportItem {
+	item := models.ImportItem{Name: candidate.name, Version: candidate.version}
+	if s.chartService.ChartExists(candidate.name, candidate.version) {
+		item.Status = models.ImportItemSkipped
+		return item
+	}
+	if dryRun {
+		item.Status = models.ImportItemPlanned
+		return item
+	}
+
+	fail := func(err error) models.ImportItem {
+		item.Status = models.ImportItemFailed
+		item.Error = err.Error()
+		return item
+	}
+
+	data, err := candidate.chart()
+	if err != nil {
+		return fail(err)
+	}
+	if candidate.digest != "" {
+		sum := sha256.Sum256(data)
+		if !strings.EqualFold(strings.TrimPrefix(candidate.digest, "sha256:"), hex.EncodeToString(sum[:])) {
+			return fail(fmt.Errorf("digest mismatch: index announces %s", candidate.digest))
+		}
+	}
+
+	filename := fmt.Sprintf("%s-%s.tgz", candidate.name, candidate.version)
+	if err := s.chartService.SaveChart(data, filename); err != nil {
+		return fail(err)
+	}
+	item.Status = models.ImportItemImported
+
+	if candidate.prov == nil {
+		return item
+	}
+	prov, err := candidate.prov()
+	if err != nil {
+		item.Error = fmt.Sprintf("provenance not imported: %v", err)
+		return item
+	}
+	if prov != nil {
+		if err := s.chartService.SaveProvenance(filename, prov); err != nil {
+			item.Error = fmt.Sprintf("provenance not imported: %v", err)
+			return item
+		}
+		item.Provenance = true
+	}
+	return item
+}
+
+// remoteCandidates reads the index.yaml of a remote repository.
+// Chart URLs may be relative to the index; credentials are only sent to the index host.
+func (s *ImportService) remoteCandidates(req models.ImportRequest) ([]importCandidate, error) {
+	indexURL, err := importIndexURL(req.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := s.download(indexURL, req, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", indexURL, err)
+	}
+	var index IndexFile
+	if err := yaml.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("invalid index.yaml: %w", err)
+	}
+
+	candidates := []importCandidate{}
+	for name, versions := range index.Entries {
+		for _, entry := range versions {
+			if entry == nil {
+				continue
+			}
+			entryName := entry.Name
+			if entryName == "" {
+				entryName = name
+			}
+			candidate := importCandidate{name: entryName, version: entry.Version, digest: entry.Digest}
+
+			if len(entry.URLs) == 0 {
+				candidate.chart = func() ([]byte, error) {
+					return nil, fmt.Errorf("index entry has no download URL")
+				}
+			} else {
+				chartURL, err := indexURL.Parse(entry.URLs[0])
+				if err != nil {
+					return nil, fmt.Errorf("invalid URL %q for %s-%s: %w", entry.URLs[0], entryName, entry.Version, err)
+				}
+				sameHost := chartURL.Host == indexURL.Host
+				candidate.chart = func() ([]byte, error) {
+					return s.download(chartURL, req, sameHost)
+				}
+				candidate.prov = func() ([]byte, error) {
+					provURL := *chartURL
+					provURL.Path += ".prov"
+					data, err := s.download(&provURL, req, sameHost)
+					if errors.Is(err, errImportNotFound) {
+						return nil, nil
+					}
+					return data, err
+				}
+			}
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	sortImportCandidates(candidates)
+	return candidates, nil
+}
+
+// localCandidates lists the chart archives of a directory, ChartMuseum storage layout included
+func (s *ImportService) localCandidates(dir string) ([]importCandidate, error) {
+	candidates := []importCandidate{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".tgz") {
+			return nil
+		}
+
+		// Seul Chart.yaml est lu ici : l'archive est chargée au moment de son import
+		metadata, err := readChartMetadataFile(path)
+		if err != nil {
+			candidates = append(candidates, importCandidate{
+				name:  strings.TrimSuffix(d.Name(), ".tgz"),
+				chart: func() ([]byte, error) { return nil, err },
+			})
+			return nil
+		}
+
+		candidates = append(candidates, importCandidate{
+			name:    metadata.Name,
+			version: metadata.Version,
+			chart:   func() ([]byte, error) { return readImportFile(path) },
+			prov: func() ([]byte, error) {
+				prov, err := os.ReadFile(path + ".prov")
+				if os.IsNotExist(err) {
+					return nil, nil
+				}
+				return prov, err
+			},
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	sortImportCandidates(candidates)
+	return candidates, nil
+}
+
+// readChartMetadataFile reads Chart.yaml from a chart archive on disk
+func readChartMetadataFile(path string) (*models.ChartMetadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readChartMetadata(f)
+}
+
+// readImportFile reads a chart archive on disk, within the size allowed for downloads
+func readImportFile(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxImportDownloadSize {
+		return nil, fmt.Errorf("%s exceeds %d bytes", filepath.Base(path), maxImportDownloadSize)
+	}
+	return os.ReadFile(path)
+}
+
+var errImportNotFound = errors.New("not found")
+
+// download fetches a URL, with the request credentials when withAuth is set
+func (s *ImportService) download(u *url.URL, req models.ImportRequest, withAuth bool) ([]byte, error) {
+	httpReq, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if withAuth && req.Username != "" {
+		httpReq.SetBasicAuth(req.Username, req.Password)
+	}
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%s: %w", u, errImportNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: unexpected status %s", u, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImportDownloadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImportDownloadSize {
+		return nil, fmt.Errorf("%s exceeds %d bytes", u, maxImportDownloadSize)
+	}
+	return data, nil
+}
+
+// validateImportRequest checks the request and returns a description of its source
+func (s *ImportService) validateImportRequest(req models.ImportRequest) (string, error) {
+	switch {
+	case req.URL != "" && req.Directory != "":
+		return "", fmt.Errorf("%w: url and directory are mutually exclusive", ErrInvalidImportRequest)
+	case req.URL != "":
+		if _, err := importIndexURL(req.URL); err != nil {
+			return "", err
+		}
+		return req.URL, nil
+	case req.Directory != "":
+		dir, err := resolveUnder(s.root, req.Directory)
+		if errors.Is(err, errOutsideRoot) {
+			return "", fmt.Errorf("%w: %s", ErrImportDirectoryForbidden, req.Directory)
+		}
+		info, statErr := os.Stat(dir)
+		if err != nil || statErr != nil || !info.IsDir() {
+			return "", fmt.Errorf("%w: %s is not a directory", ErrInvalidImportRequest, req.Directory)
+		}
+		return dir, nil
+	}
+	return "", fmt.Errorf("%w: url or directory is required", ErrInvalidImportRequest)
+}
+
+// importIndexURL returns the index.yaml URL of a repository URL
+func importIndexURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: %q is not an http(s) URL", ErrInvalidImportRequest, raw)
+	}
+	if !strings.HasSuffix(u.Path, ".yaml") {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/index.yaml"
+	}
+	return u, nil
+}
+
+// sortImportCandidates orders candidates by name, then by ascending semver version
+func sortImportCandidates(candidates []importCandidate) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].name != candidates[j].name {
+			return candidates[i].name < candidates[j].name
+		}
+		vi, errI := semver.NewVersion(candidates[i].version)
+		vj, errJ := semver.NewVersion(candidates[j].version)
+		if errI != nil || errJ != nil {
+			return candidates[i].version < candidates[j].version
+		}
+		return vi.LessThan(vj)
+	})
+}
@@ -37,6 +37,7 @@ type ChartVersion struct {
 	Created     time.Time `yaml:"created"`
 	Digest      string    `yaml:"digest"` // SHA256 du fichier
 	URLs        []string  `yaml:"urls"`   // URLs de téléchargement
+	Deprecated  bool      `yaml:"deprecated,omitempty"`
 }
 
 // ChartExtractor extrait les informations des charts
@@ -55,7 +56,7 @@ type IndexService struct {
 
 // GetIndexPath implements IndexUpdater.
 func (s *IndexService) GetIndexPath() string {
-	panic("unimplemented")
+	return s.pathManager.GetIndexPath()
 }
 
 func NewIndexService(config *config.Config, log *utils.Logger, chartService interfaces.ChartServiceInterface) *IndexService {
@@ -63,10 +64,17 @@ func NewIndexService(config *config.Config, log *utils.Logger, chartService inte
 		log.WithError(err).Error("❌ Impossible de créer le dossier de stockage")
 	}
 
+	return NewRepositoryIndexService(config, log, utils.NewPathManager(config.Storage.Path, log), chartService, "")
+}
+
+// NewRepositoryIndexService creates an index service for the charts of a path manager.
+// The chart URLs of the index are prefixed with baseURL (e.g. "/r/team-a").
+func NewRepositoryIndexService(config *config.Config, log *utils.Logger, pathManager *utils.PathManager, chartService interfaces.ChartServiceInterface, baseURL string) *IndexService {
 	return &IndexService{
-		pathManager:  utils.NewPathManager(config.Storage.Path, log),
+		pathManager:  pathManager,
 		config:       config,
 		log:          log,
+		baseURL:      baseURL,
 		chartService: chartService,
 	}
 }
@@ -84,6 +92,28 @@ func (s *IndexService) EnsureIndexExists() error {
 func (s *IndexService) UpdateIndex() error {
 	s.log.Info("🔄 Génération de l'index.yaml")
 
+	index, err := s.buildIndex(s.baseURL+"/charts/", nil)
+	if err != nil {
+		return err
+	}
+
+	indexYAML, err := yaml.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("❌ erreur marshaling index: %w", err)
+	}
+
+	indexPath := s.pathManager.GetIndexPath()
+	if err := os.WriteFile(indexPath, indexYAML, 0644); err != nil {
+		return fmt.Errorf("❌ erreur sauvegarde index: %w", err)
+	}
+
+	s.log.Info("✅ Index.yaml généré avec succès")
+	return nil
+}
+
+// buildIndex génère l'index des charts stockés. Les URLs de téléchargement sont
+// urlPrefix suivi du nom de l'archive ; include (optionnel) filtre les charts par nom.
+func (s *IndexService) buildIndex(urlPrefix string, include func(name string) bool) (*IndexFile, error) {
 	// Créer un nouvel index
 	index := &IndexFile{
 		APIVersion: "v1",
@@ -92,10 +122,10 @@ func (s *IndexService) UpdateIndex() error {
 	}
 
 	// Lire le répertoire des charts
-	chartsDir := s.pathManager.GetBasePath()
+	chartsDir := s.pathManager.GetChartsPath()
 	files, err := os.ReadDir(chartsDir)
 	if err != nil {
-		return fmt.Errorf("❌ erreur lecture répertoire charts: %w", err)
+		return nil, fmt.Errorf("❌ erreur lecture répertoire charts: %w", err)
 	}
 
 	// Traiter chaque fichier .tgz
@@ -118,13 +148,24 @@ func (s *IndexService) UpdateIndex() error {
 			s.log.WithError(err).WithField("file", file.Name()).Error("❌ Erreur extraction métadonnées")
 			continue
 		}
+		if include != nil && !include(metadata.Name) {
+			continue
+		}
+
+		// Les versions yankées restent téléchargeables mais n'apparaissent plus dans l'index
+		status, err := s.chartService.GetChartStatus(metadata.Name, metadata.Version)
+		if err != nil {
+			s.log.WithError(err).WithField("file", file.Name()).Warn("⚠️ Statut du chart illisible")
+		} else if status.Yanked {
+			continue
+		}
 
 		// Calculer le digest SHA256
 		digest := sha256.Sum256(chartData)
 		digestStr := hex.EncodeToString(digest[:])
 
 		// Créer l'URL de téléchargement
-		downloadURL := fmt.Sprintf("%s/charts/%s", s.baseURL, file.Name())
+		downloadURL := urlPrefix + file.Name()
 
 		// Créer la version du chart
 		chartVersion := &ChartVersion{
@@ -136,6 +177,7 @@ func (s *IndexService) UpdateIndex() error {
 			Created:     time.Now(),
 			Digest:      digestStr,
 			URLs:        []string{downloadURL},
+			Deprecated:  metadata.Deprecated || (status != nil && status.Deprecated),
 		}
 
 		// Ajouter à l'index
@@ -151,16 +193,5 @@ func (s *IndexService) UpdateIndex() error {
 		}).Debug("✅ Chart ajouté à l'index")
 	}
 
-	indexYAML, err := yaml.Marshal(index)
-	if err != nil {
-		return fmt.Errorf("❌ erreur marshaling index: %w", err)
-	}
-
-	indexPath := s.pathManager.GetIndexPath()
-	if err := os.WriteFile(indexPath, indexYAML, 0644); err != nil {
-		return fmt.Errorf("❌ erreur sauvegarde index: %w", err)
-	}
-
-	s.log.Info("✅ Index.yaml généré avec succès")
-	return nil
+	return index, nil
 }
@@ -0,0 +1,570 @@
+// pkg/services/inventory.go
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"debug/buildinfo"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"helm-portal/pkg/interfaces"
+	"helm-portal/pkg/models"
+)
+
+// defaultMaxInventoryFileSize bounds the size of the files read to build an inventory
+const defaultMaxInventoryFileSize = 256 << 20
+
+// osReleasePaths are read to know the distribution of the packages
+var osReleasePaths = map[string]bool{"/etc/os-release": true, "/usr/lib/os-release": true}
+
+// rpmDatabasePaths are the rpm databases of the supported distributions
+var rpmDatabasePaths = map[string]bool{
+	"/var/lib/rpm/rpmdb.sqlite":          true,
+	"/var/lib/rpm/Packages.db":           true,
+	"/var/lib/rpm/Packages":              true,
+	"/usr/lib/sysimage/rpm/rpmdb.sqlite": true,
+	"/usr/lib/sysimage/rpm/Packages.db":  true,
+	"/usr/lib/sysimage/rpm/Packages":     true,
+	"/usr/share/rpm/Packages":            true,
+}
+
+// lockfileParsers read the dependencies of an application from its lockfile, by file name
+var lockfileParsers = map[string]func(data []byte) []models.Package{
+	"package-lock.json": parsePackageLock,
+	"yarn.lock":         parseYarnLock,
+	"requirements.txt":  parseRequirements,
+	"Pipfile.lock":      parsePipfileLock,
+	"poetry.lock":       func(data []byte) []models.Package { return parseTOMLPackages(data, "PyPI", "pypi") },
+	"Cargo.lock":        func(data []byte) []models.Package { return parseTOMLPackages(data, "crates.io", "cargo") },
+	"Gemfile.lock":      parseGemfileLock,
+	"composer.lock":     parseComposerLock,
+}
+
+// imageInventory holds the files of an image needed to list its packages, once all layers are applied
+type imageInventory struct {
+	files    map[string][]byte
+	binaries map[string][]models.Package // Go executables
+}
+
+// wantedInventoryFile tells if a file is read to list the packages of an image
+func wantedInventoryFile(filePath string) bool {
+	switch {
+	case osReleasePaths[filePath], rpmDatabasePaths[filePath]:
+		return true
+	case filePath == "/var/lib/dpkg/status", filePath == "/lib/apk/db/installed":
+		return true
+	case strings.HasPrefix(filePath, "/var/lib/dpkg/status.d/"):
+		return true
+	}
+	_, ok := lockfileParsers[path.Base(filePath)]
+	return ok
+}
+
+// buildInventory applies the layers of a manifest, whiteouts included, keeping only the package databases,
+// lockfiles and Go executables of the resulting filesystem
+func buildInventory(imageService interfaces.ImageServiceInterface, manifest *models.OCIManifest, maxFileSize int64) (*imageInventory, error) {
+	inventory := &imageInventory{files: make(map[string][]byte), binaries: make(map[string][]models.Package)}
+
+	for i, layer := range manifest.Layers {
+		files := make(map[string][]byte)
+		binaries := make(map[string][]models.Package)
+		var whiteouts, opaques []string
+
+		err := imageService.WalkLayer(layer.Digest, func(entry *models.LayerEntry, content io.Reader) error {
+			switch entry.Type {
+			case models.LayerEntryWhiteout:
+				whiteouts = append(whiteouts, entry.Path)
+				return nil
+			case models.LayerEntryOpaque:
+				opaques = append(opaques, entry.Path)
+				return nil
+			case models.LayerEntryFile:
+			default:
+				return nil
+			}
+			if entry.Size > maxFileSize {
+				return nil
+			}
+
+			if wantedInventoryFile(entry.Path) {
+				data, err := io.ReadAll(content)
+				if err != nil {
+					return err
+				}
+				files[entry.Path] = data
+				return nil
+			}
+			if !strings.Contains(entry.Mode, "x") || entry.Size < 4 {
+				return nil
+			}
+			// Exécutable : seuls les binaires ELF produits par Go portent des informations de build
+			buffered := bufio.NewReader(content)
+			if magic, _ := buffered.Peek(4); !bytes.Equal(magic, []byte("\x7fELF")) {
+				return nil
+			}
+			data, err := io.ReadAll(buffered)
+			if err != nil {
+				return err
+			}
+			if packages := goBinaryPackages(data, entry.Path); len(packages) > 0 {
+				binaries[entry.Path] = packages
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("layer %d: %w", i, err)
+		}
+
+		// Les whiteouts masquent les couches inférieures, pas les fichiers de cette couche
+		for _, dir := range whiteouts {
+			removeInventoryTree(inventory, dir, true)
+		}
+		for _, dir := range opaques {
+			removeInventoryTree(inventory, dir, false)
+		}
+		for filePath, data := range files {
+			delete(inventory.binaries, filePath)
+			inventory.files[filePath] = data
+		}
+		for filePath, packages := range binaries {
+			delete(inventory.files, filePath)
+			inventory.binaries[filePath] = packages
+		}
+	}
+	return inventory, nil
+}
+
+func removeInventoryTree(inventory *imageInventory, dir string, withRoot bool) {
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	for filePath := range inventory.files {
+		if (withRoot && filePath == dir) || strings.HasPrefix(filePath, prefix) {
+			delete(inventory.files, filePath)
+		}
+	}
+	for filePath := range inventory.binaries {
+		if (withRoot && filePath == dir) || strings.HasPrefix(filePath, prefix) {
+			delete(inventory.binaries, filePath)
+		}
+	}
+}
+
+// packages lists the packages of the inventory and describes the distribution ("debian 12"), if known
+func (inv *imageInventory) packages() ([]models.Package, string) {
+	osID, osVersion, osName := "", "", ""
+	for _, p := range []string{"/etc/os-release", "/usr/lib/os-release"} {
+		if data, ok := inv.files[p]; ok {
+			osID, osVersion, osName = parseOSRelease(data)
+			break
+		}
+	}
+
+	var packages []models.Package
+	paths := make([]string, 0, len(inv.files))
+	for filePath := range inv.files {
+		paths = append(paths, filePath)
+	}
+	sort.Strings(paths)
+
+	for _, filePath := range paths {
+		data := inv.files[filePath]
+		var found []models.Package
+		switch {
+		case filePath == "/var/lib/dpkg/status", strings.HasPrefix(filePath, "/var/lib/dpkg/status.d/"):
+			found = parseDpkgStatus(data, osEcosystem(osID, osVersion, "Debian"))
+		case filePath == "/lib/apk/db/installed":
+			found = parseApkInstalled(data, osEcosystem(osID, osVersion, "Alpine"))
+		case rpmDatabasePaths[filePath]:
+			rpms, err := parseRPMDB(data)
+			if err != nil {
+				continue
+			}
+			ecosystem := osEcosystem(osID, osVersion, "")
+			for _, rpm := range rpms {
+				found = append(found, models.Package{
+					Name:      rpm.Name,
+					Version:   rpm.EVR(),
+					Ecosystem: ecosystem,
+					Type:      "rpm",
+				})
+			}
+		default:
+			if parse, ok := lockfileParsers[path.Base(filePath)]; ok {
+				found = parse(data)
+			}
+		}
+		for i := range found {
+			found[i].Location = filePath
+		}
+		packages = append(packages, found...)
+	}
+
+	binaries := make([]string, 0, len(inv.binaries))
+	for filePath := range inv.binaries {
+		binaries = append(binaries, filePath)
+	}
+	sort.Strings(binaries)
+	for _, filePath := range binaries {
+		packages = append(packages, inv.binaries[filePath]...)
+	}
+
+	if packages == nil {
+		packages = []models.Package{}
+	}
+	return packages, osName
+}
+
+// parseOSRelease returns the ID, VERSION_ID and PRETTY_NAME of an os-release file
+func parseOSRelease(data []byte) (string, string, string) {
+	values := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if ok {
+			values[key] = strings.Trim(value, `"'`)
+		}
+	}
+	name := values["PRETTY_NAME"]
+	if name == "" {
+		name = strings.TrimSpace(values["ID"] + " " + values["VERSION_ID"])
+	}
+	return values["ID"], values["VERSION_ID"], name
+}
+
+// osEcosystem returns the OSV ecosystem of the packages of a distribution, e.g. "Debian:12",
+// "Ubuntu:22.04", "Alpine:v3.20" or "Rocky Linux:9"; fallback is used when the distribution is unknown
+func osEcosystem(id, version, fallback string) string {
+	major, _, _ := strings.Cut(version, ".")
+	switch id {
+	case "debian":
+		if major == "" {
+			return "Debian"
+		}
+		return "Debian:" + major
+	case "ubuntu":
+		return "Ubuntu:" + version
+	case "alpine":
+		parts := strings.SplitN(version, ".", 3)
+		if len(parts) < 2 {
+			return "Alpine"
+		}
+		return "Alpine:v" + parts[0] + "." + parts[1]
+	case "rhel":
+		return "Red Hat:enterprise_linux:" + major
+	case "rocky":
+		return "Rocky Linux:" + major
+	case "almalinux":
+		return "AlmaLinux:" + major
+	case "opensuse-leap":
+		return "openSUSE:Leap " + version
+	case "sles":
+		release, servicePack, _ := strings.Cut(version, ".")
+		if servicePack != "" && servicePack != "0" {
+			return "SUSE:Linux Enterprise Server " + release + " SP" + servicePack
+		}
+		return "SUSE:Linux Enterprise Server " + release
+	case "mageia":
+		return "Mageia:" + version
+	}
+	return fallback
+}
+
+// parseDpkgStatus lists the installed packages of a dpkg status file (or a distroless status.d entry)
+func parseDpkgStatus(data []byte, ecosystem string) []models.Package {
+	var packages []models.Package
+	for _, paragraph := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n\n") {
+		fields := make(map[string]string)
+		for _, line := range strings.Split(paragraph, "\n") {
+			if line == "" || line[0] == ' ' || line[0] == '\t' {
+				continue
+			}
+			if key, value, ok := strings.Cut(line, ":"); ok {
+				fields[key] = strings.TrimSpace(value)
+			}
+		}
+		if fields["Package"] == "" || fields["Version"] == "" {
+			continue
+		}
+		// Les fichiers status.d des images distroless n'ont pas de champ Status
+		if status, ok := fields["Status"]; ok && !strings.HasSuffix(status, " installed") {
+			continue
+		}
+		source, _, _ := strings.Cut(fields["Source"], " ")
+		packages = append(packages, models.Package{
+			Name:      fields["Package"],
+			Version:   fields["Version"],
+			Ecosystem: ecosystem,
+			Type:      "dpkg",
+			Source:    source,
+		})
+	}
+	return packages
+}
+
+// parseApkInstalled lists the packages of the apk database (P: name, V: version, o: origin)
+func parseApkInstalled(data []byte, ecosystem string) []models.Package {
+	var packages []models.Package
+	var current models.Package
+	flush := func() {
+		if current.Name != "" && current.Version != "" {
+			current.Ecosystem = ecosystem
+			current.Type = "apk"
+			packages = append(packages, current)
+		}
+		current = models.Package{}
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			flush()
+			continue
+		}
+		if len(line) < 2 || line[1] != ':' {
+			continue
+		}
+		switch line[0] {
+		case 'P':
+			current.Name = line[2:]
+		case 'V':
+			current.Version = line[2:]
+		case 'o':
+			current.Source = line[2:]
+		}
+	}
+	flush()
+	return packages
+}
+
+// goBinaryPackages lists the Go toolchain and the modules compiled into a Go executable
+func goBinaryPackages(data []byte, location string) []models.Package {
+	info, err := buildinfo.Read(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+
+	var packages []models.Package
+	goVersion, _, _ := strings.Cut(strings.TrimPrefix(info.GoVersion, "go"), " ")
+	if goVersion != "" {
+		packages = append(packages, models.Package{Name: "stdlib", Version: goVersion, Ecosystem: "Go", Type: "go-stdlib", Location: location})
+	}
+	modules := append([]*debug.Module{&info.Main}, info.Deps...)
+	for _, module := range modules {
+		if module.Replace != nil {
+			module = module.Replace
+		}
+		if module.Path == "" || module.Version == "" || module.Version == "(devel)" {
+			continue
+		}
+		packages = append(packages, models.Package{
+			Name:      module.Path,
+			Version:   strings.TrimPrefix(module.Version, "v"),
+			Ecosystem: "Go",
+			Type:      "go-module",
+			Location:  location,
+		})
+	}
+	return packages
+}
+
+// parsePackageLock lists the dependencies of an npm lockfile (v1 "dependencies", v2/v3 "packages")
+func parsePackageLock(data []byte) []models.Package {
+	type dependency struct {
+		Version      string                `json:"version"`
+		Dependencies map[string]dependency `json:"dependencies"`
+	}
+	var lock struct {
+		Packages map[string]struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+			Link    bool   `json:"link"`
+		} `json:"packages"`
+		Dependencies map[string]dependency `json:"dependencies"`
+	}
+	if json.Unmarshal(data, &lock) != nil {
+		return nil
+	}
+
+	var packages []models.Package
+	if len(lock.Packages) > 0 {
+		for key, pkg := range lock.Packages {
+			idx := strings.LastIndex(key, "node_modules/")
+			if idx < 0 || pkg.Link || pkg.Version == "" {
+				continue // "" est le projet lui-même
+			}
+			name := pkg.Name
+			if name == "" {
+				name = key[idx+len("node_modules/"):]
+			}
+			packages = append(packages, models.Package{Name: name, Version: pkg.Version, Ecosystem: "npm", Type: "npm"})
+		}
+	} else {
+		var walk func(deps map[string]dependency)
+		walk = func(deps map[string]dependency) {
+			for name, dep := range deps {
+				if dep.Version != "" {
+					packages = append(packages, models.Package{Name: name, Version: dep.Version, Ecosystem: "npm", Type: "npm"})
+				}
+				walk(dep.Dependencies)
+			}
+		}
+		walk(lock.Dependencies)
+	}
+	return sortedPackages(packages)
+}
+
+// parseYarnLock lists the packages of a yarn lockfile, classic (version "x") or berry (version: x)
+func parseYarnLock(data []byte) []models.Package {
+	var packages []models.Package
+	name := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case line[0] != ' ' && strings.HasSuffix(line, ":"):
+			spec := strings.Trim(strings.TrimSpace(strings.Split(strings.TrimSuffix(line, ":"), ",")[0]), `"`)
+			name = ""
+			if at := strings.LastIndex(spec, "@"); at > 0 {
+				name = spec[:at]
+			}
+			if name == "__metadata" {
+				name = ""
+			}
+		case name != "" && strings.HasPrefix(strings.TrimSpace(line), "version"):
+			version := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "version"))
+			version = strings.Trim(strings.TrimPrefix(version, ":"), ` "`)
+			packages = append(packages, models.Package{Name: name, Version: version, Ecosystem: "npm", Type: "yarn"})
+			name = ""
+		}
+	}
+	return sortedPackages(packages)
+}
+
+// requirementPattern matches a pinned requirement: name[extras]==version
+var requirementPattern = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)(\[[^\]]*\])?\s*===?\s*([A-Za-z0-9.!+_-]+)`)
+
+// parseRequirements lists the pinned requirements of a pip requirements file
+func parseRequirements(data []byte) []models.Package {
+	var packages []models.Package
+	for _, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		line, _, _ = strings.Cut(line, ";")
+		if match := requirementPattern.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+			packages = append(packages, models.Package{Name: match[1], Version: match[3], Ecosystem: "PyPI", Type: "pip"})
+		}
+	}
+	return packages
+}
+
+// parsePipfileLock lists the default and develop packages of a Pipfile.lock
+func parsePipfileLock(data []byte) []models.Package {
+	var lock map[string]json.RawMessage
+	if json.Unmarshal(data, &lock) != nil {
+		return nil
+	}
+	var packages []models.Package
+	for _, section := range []string{"default", "develop"} {
+		var deps map[string]struct {
+			Version string `json:"version"`
+		}
+		if json.Unmarshal(lock[section], &deps) != nil {
+			continue
+		}
+		for name, dep := range deps {
+			if version := strings.TrimPrefix(dep.Version, "=="); version != "" {
+				packages = append(packages, models.Package{Name: name, Version: version, Ecosystem: "PyPI", Type: "pipenv"})
+			}
+		}
+	}
+	return sortedPackages(packages)
+}
+
+// parseTOMLPackages lists the [[package]] tables (name, version) of a poetry.lock or Cargo.lock
+func parseTOMLPackages(data []byte, ecosystem, packageType string) []models.Package {
+	var packages []models.Package
+	var current *models.Package
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			current = nil
+			if line == "[[package]]" {
+				packages = append(packages, models.Package{Ecosystem: ecosystem, Type: packageType})
+				current = &packages[len(packages)-1]
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if current == nil || !ok {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		switch strings.TrimSpace(key) {
+		case "name":
+			current.Name = value
+		case "version":
+			current.Version = value
+		}
+	}
+
+	complete := packages[:0]
+	for _, pkg := range packages {
+		if pkg.Name != "" && pkg.Version != "" {
+			complete = append(complete, pkg)
+		}
+	}
+	return complete
+}
+
+// gemSpecPattern matches a gem of the specs section of a Gemfile.lock: "    name (version)"
+var gemSpecPattern = regexp.MustCompile(`^    ([A-Za-z0-9._-]+) \(([^)]+)\)$`)
+
+// parseGemfileLock lists the gems of a Gemfile.lock
+func parseGemfileLock(data []byte) []models.Package {
+	var packages []models.Package
+	for _, line := range strings.Split(string(data), "\n") {
+		if match := gemSpecPattern.FindStringSubmatch(strings.TrimRight(line, "\r")); match != nil {
+			// La plateforme éventuelle suit la version : 1.15.4-x86_64-linux
+			version, _, _ := strings.Cut(match[2], "-")
+			packages = append(packages, models.Package{Name: match[1], Version: version, Ecosystem: "RubyGems", Type: "bundler"})
+		}
+	}
+	return packages
+}
+
+// parseComposerLock lists the packages of a composer.lock
+func parseComposerLock(data []byte) []models.Package {
+	type composerPackage struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	var lock struct {
+		Packages    []composerPackage `json:"packages"`
+		PackagesDev []composerPackage `json:"packages-dev"`
+	}
+	if json.Unmarshal(data, &lock) != nil {
+		return nil
+	}
+	var packages []models.Package
+	for _, pkg := range append(lock.Packages, lock.PackagesDev...) {
+		if pkg.Name != "" && pkg.Version != "" {
+			packages = append(packages, models.Package{Name: pkg.Name, Version: strings.TrimPrefix(pkg.Version, "v"), Ecosystem: "Packagist", Type: "composer"})
+		}
+	}
+	return packages
+}
+
+// sortedPackages sorts the packages read from a map by name and version
+func sortedPackages(packages []models.Package) []models.Package {
+	sort.Slice(packages, func(i, j int) bool {
+		if packages[i].Name != packages[j].Name {
+			return packages[i].Name < packages[j].Name
+		}
+		return packages[i].Version < packages[j].Version
+	})
+	return packages
+}
@@ -0,0 +1,339 @@
+// pkg/services/layers.go
+package service
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"helm-portal/pkg/models"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// whiteoutPrefix marks a file deleted from the lower layers (e.g. etc/.wh.passwd)
+	whiteoutPrefix = ".wh."
+	// whiteoutOpaque hides the content of its directory in the lower layers
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+var (
+	// ErrLayerNotFound is returned when a layer blob is not stored
+	ErrLayerNotFound = errors.New("layer not found")
+	// ErrInvalidLayer is returned when a blob is not a (compressed) tar archive
+	ErrInvalidLayer = errors.New("blob is not a tar layer")
+	// ErrLayerFileNotFound is returned when a path does not exist in a layer
+	ErrLayerFileNotFound = errors.New("file not found in layer")
+	// ErrNotRegularFile is returned when downloading a directory, link or whiteout
+	ErrNotRegularFile = errors.New("not a regular file")
+	// ErrImageIsIndex is returned for operations that need a single-platform image
+	ErrImageIsIndex = errors.New("multi-platform index, select a platform image by digest")
+)
+
+// layerReader is an open layer: a tar reader over the decompressed blob
+type layerReader struct {
+	*tar.Reader
+	compression string
+	closers     []io.Closer
+}
+
+// Close closes the decompressor and the blob file
+func (l *layerReader) Close() error {
+	var err error
+	for i := len(l.closers) - 1; i >= 0; i-- {
+		if cerr := l.closers[i].Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// openLayer opens a layer blob, detecting its compression (gzip, zstd or none) from its magic bytes
+func (s *ImageService) openLayer(digest string) (*layerReader, error) {
+	if !digestPattern.MatchString(digest) {
+		return nil, fmt.Errorf("%w: %s", ErrLayerNotFound, digest)
+	}
+	f, err := os.Open(s.pathManager.GetBlobPath(digest))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrLayerNotFound, digest)
+		}
+		return nil, err
+	}
+
+	layer := &layerReader{closers: []io.Closer{f}}
+	buffered := bufio.NewReader(f)
+	magic, _ := buffered.Peek(4)
+
+	var content io.Reader
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%w: %v", ErrInvalidLayer, err)
+		}
+		layer.compression = "gzip"
+		layer.closers = append(layer.closers, gz)
+		content = gz
+	case bytes.Equal(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		zr, err := zstd.NewReader(buffered)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%w: %v", ErrInvalidLayer, err)
+		}
+		layer.compression = "zstd"
+		layer.closers = append(layer.closers, zr.IOReadCloser())
+		content = zr
+	default:
+		layer.compression = "none"
+		content = buffered
+	}
+	layer.Reader = tar.NewReader(content)
+	return layer, nil
+}
+
+// next returns the next entry of the layer, io.EOF at the end
+func (l *layerReader) next() (*models.LayerEntry, error) {
+	for {
+		header, err := l.Next()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidLayer, err)
+		}
+		if entry := layerEntry(header); entry != nil {
+			return entry, nil
+		}
+	}
+}
+
+// layerEntry converts a tar header; the root directory and pax headers are skipped
+func layerEntry(header *tar.Header) *models.LayerEntry {
+	entryPath := path.Clean("/" + header.Name)
+	if entryPath == "/" {
+		return nil
+	}
+	entry := &models.LayerEntry{
+		Path:    entryPath,
+		Mode:    header.FileInfo().Mode().String(),
+		ModTime: header.ModTime.UTC(),
+	}
+
+	dir, base := path.Split(entryPath)
+	switch {
+	case base == whiteoutOpaque:
+		entry.Path = path.Clean(dir)
+		entry.Type = models.LayerEntryOpaque
+		return entry
+	case strings.HasPrefix(base, whiteoutPrefix):
+		entry.Path = path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix))
+		entry.Type = models.LayerEntryWhiteout
+		return entry
+	}
+
+	switch header.Typeflag {
+	case tar.TypeReg:
+		entry.Type = models.LayerEntryFile
+		entry.Size = header.Size
+	case tar.TypeDir:
+		entry.Type = models.LayerEntryDir
+	case tar.TypeSymlink:
+		entry.Type = models.LayerEntrySymlink
+		entry.Link = header.Linkname
+	case tar.TypeLink:
+		entry.Type = models.LayerEntryHardlink
+		entry.Link = path.Clean("/" + header.Linkname)
+	case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		entry.Type = models.LayerEntryOther
+	default:
+		return nil
+	}
+	return entry
+}
+
+// ListLayerFiles lists the files, directories, links and whiteouts of a layer blob
+func (s *ImageService) ListLayerFiles(digest string) (*models.LayerListing, error) {
+	layer, err := s.openLayer(digest)
+	if err != nil {
+		return nil, err
+	}
+	defer layer.Close()
+
+	listing := &models.LayerListing{Digest: digest, Compression: layer.compression, Entries: []models.LayerEntry{}}
+	for {
+		entry, err := layer.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		listing.Entries = append(listing.Entries, *entry)
+	}
+
+	sort.SliceStable(listing.Entries, func(i, j int) bool {
+		return listing.Entries[i].Path < listing.Entries[j].Path
+	})
+	return listing, nil
+}
+
+// HasLayer reports whether a manifest of an image lists a blob among its layers: the layer
+// browser only opens the blobs of the image it is called for
+func (s *ImageService) HasLayer(name, digest string) bool {
+	if !digestPattern.MatchString(digest) {
+		return false
+	}
+	dir := filepath.Join(s.pathManager.GetImagePath(name), "manifests")
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			continue
+		}
+		var manifest models.OCIManifest
+		if json.Unmarshal(data, &manifest) != nil {
+			continue
+		}
+		for _, layer := range manifest.Layers {
+			if layer.Digest == digest {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WalkLayer calls fn for every entry of a layer blob, in archive order.
+// For a regular file, content reads the file until fn returns.
+func (s *ImageService) WalkLayer(digest string, fn func(entry *models.LayerEntry, content io.Reader) error) error {
+	layer, err := s.openLayer(digest)
+	if err != nil {
+		return err
+	}
+	defer layer.Close()
+
+	for {
+		entry, err := layer.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(entry, layer); err != nil {
+			return err
+		}
+	}
+}
+
+// OpenLayerFile returns a regular file of a layer and a reader over its content, to be closed by the caller
+func (s *ImageService) OpenLayerFile(digest, filePath string) (*models.LayerEntry, io.ReadCloser, error) {
+	filePath = path.Clean("/" + filePath)
+
+	layer, err := s.openLayer(digest)
+	if err != nil {
+		return nil, nil, err
+	}
+	for {
+		entry, err := layer.next()
+		if err == io.EOF {
+			layer.Close()
+			return nil, nil, fmt.Errorf("%w: %s", ErrLayerFileNotFound, filePath)
+		}
+		if err != nil {
+			layer.Close()
+			return nil, nil, err
+		}
+		if entry.Path != filePath || entry.Type == models.LayerEntryWhiteout || entry.Type == models.LayerEntryOpaque {
+			continue
+		}
+		if entry.Type != models.LayerEntryFile {
+			layer.Close()
+			return nil, nil, fmt.Errorf("%w: %s is a %s", ErrNotRegularFile, filePath, entry.Type)
+		}
+		// Le lecteur tar est positionné sur le contenu du fichier
+		return entry, layer, nil
+	}
+}
+
+// MergedFilesystem applies the layers of an image tag in order, whiteouts included,
+// and returns the resulting filesystem with the layer providing each entry
+func (s *ImageService) MergedFilesystem(name, tag string) (*models.MergedFilesystem, error) {
+	manifest, err := s.GetImageManifest(name, tag)
+	if err != nil {
+		return nil, err
+	}
+	if models.IsIndexMediaType(manifest.MediaType) {
+		return nil, ErrImageIsIndex
+	}
+
+	merged := make(map[string]models.MergedEntry)
+	for i, descriptor := range manifest.Layers {
+		listing, err := s.ListLayerFiles(descriptor.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("layer %d: %w", i, err)
+		}
+
+		// Les whiteouts ne masquent que les couches inférieures, pas les fichiers de cette couche
+		for _, entry := range listing.Entries {
+			switch entry.Type {
+			case models.LayerEntryWhiteout:
+				removeTree(merged, entry.Path, true)
+			case models.LayerEntryOpaque:
+				removeTree(merged, entry.Path, false)
+			}
+		}
+		for _, entry := range listing.Entries {
+			if entry.Type == models.LayerEntryWhiteout || entry.Type == models.LayerEntryOpaque {
+				continue
+			}
+			merged[entry.Path] = models.MergedEntry{LayerEntry: entry, Layer: i, LayerDigest: descriptor.Digest}
+		}
+	}
+
+	fs := &models.MergedFilesystem{
+		Name:    name,
+		Tag:     tag,
+		Layers:  s.extractLayerInfo(manifest),
+		Entries: make([]models.MergedEntry, 0, len(merged)),
+	}
+	for _, entry := range merged {
+		fs.Entries = append(fs.Entries, entry)
+	}
+	sort.Slice(fs.Entries, func(i, j int) bool {
+		return fs.Entries[i].Path < fs.Entries[j].Path
+	})
+	return fs, nil
+}
+
+// removeTree removes the children of dir from entries, and dir itself if withRoot is set
+func removeTree(entries map[string]models.MergedEntry, dir string, withRoot bool) {
+	if withRoot {
+		delete(entries, dir)
+	}
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	for entryPath := range entries {
+		if strings.HasPrefix(entryPath, prefix) {
+			delete(entries, entryPath)
+		}
+	}
+}
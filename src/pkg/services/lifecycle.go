@@ -0,0 +1,167 @@
+// pkg/services/lifecycle.go
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"helm-portal/pkg/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrReasonRequired is returned when a lifecycle change has no reason
+var ErrReasonRequired = errors.New("a reason is required")
+
+// DeprecateChart marks a chart version as deprecated. It stays in the index with deprecated: true.
+func (s *ChartService) DeprecateChart(chartName, version, reason, actor string) (*models.ChartStatus, error) {
+	return s.changeChartStatus(chartName, version, models.LifecycleDeprecate, reason, actor, func(status *models.ChartStatus) {
+		status.Deprecated = true
+	})
+}
+
+// YankChart hides a chart version from the index and tag listings.
+// It can still be downloaded by exact version or digest.
+func (s *ChartService) YankChart(chartName, version, reason, actor string) (*models.ChartStatus, error) {
+	return s.changeChartStatus(chartName, version, models.LifecycleYank, reason, actor, func(status *models.ChartStatus) {
+		status.Yanked = true
+	})
+}
+
+// ReinstateChart clears the deprecated and yanked flags of a chart version
+func (s *ChartService) ReinstateChart(chartName, version, reason, actor string) (*models.ChartStatus, error) {
+	return s.changeChartStatus(chartName, version, models.LifecycleReinstate, reason, actor, func(status *models.ChartStatus) {
+		status.Deprecated = false
+		status.Yanked = false
+	})
+}
+
+func (s *ChartService) changeChartStatus(chartName, version, action, reason, actor string, apply func(*models.ChartStatus)) (*models.ChartStatus, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+	if !s.ChartExists(chartName, version) {
+		return nil, fmt.Errorf("%w: %s-%s", ErrChartNotFound, chartName, version)
+	}
+
+	status, err := s.GetChartStatus(chartName, version)
+	if err != nil {
+		return nil, err
+	}
+	apply(status)
+	status.History = append(status.History, models.LifecycleEntry{
+		Action: action,
+		Reason: reason,
+		Actor:  actor,
+		At:     time.Now().UTC(),
+	})
+
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to encode chart status: %w", err)
+	}
+	statusPath := s.pathManager.GetChartStatusPath(chartName, version)
+	if err := os.MkdirAll(filepath.Dir(statusPath), 0755); err != nil {
+		return nil, fmt.Errorf("❌ failed to create status directory: %w", err)
+	}
+	if err := os.WriteFile(statusPath, data, 0644); err != nil {
+		return nil, fmt.Errorf("❌ failed to save chart status: %w", err)
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"name":    chartName,
+		"version": version,
+		"action":  action,
+		"actor":   actor,
+		"reason":  reason,
+	}).Info("✅ Chart lifecycle updated")
+
+	if s.indexUpdater != nil {
+		if err := s.indexUpdater.UpdateIndex(); err != nil {
+			s.log.WithError(err).Error("❌ Échec mise à jour index")
+			return nil, fmt.Errorf("échec mise à jour index: %w", err)
+		}
+	}
+
+	s.publish(models.ArtifactEvent{
+		Action:    models.EventUpdate,
+		Type:      models.ArtifactTypeHelmChart,
+		Name:      chartName,
+		Reference: version,
+	})
+	return status, nil
+}
+
+// GetChartStatus returns the lifecycle state of a chart version.
+// Versions that were never deprecated nor yanked get an empty status.
+func (s *ChartService) GetChartStatus(chartName, version string) (*models.ChartStatus, error) {
+	status := &models.ChartStatus{
+		Name:    chartName,
+		Version: version,
+		History: []models.LifecycleEntry{},
+	}
+
+	data, err := os.ReadFile(s.pathManager.GetChartStatusPath(chartName, version))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return status, nil
+		}
+		return nil, fmt.Errorf("❌ failed to read chart status: %w", err)
+	}
+	if err := json.Unmarshal(data, status); err != nil {
+		return nil, fmt.Errorf("❌ failed to decode chart status: %w", err)
+	}
+	return status, nil
+}
+
+// applyChartStatus copies the lifecycle flags onto chart metadata
+func (s *ChartService) applyChartStatus(metadata *models.ChartMetadata) {
+	status, err := s.GetChartStatus(metadata.Name, metadata.Version)
+	if err != nil {
+		s.log.WithError(err).WithField("chart", metadata.Name).Warn("⚠️ Ignoring unreadable chart status")
+		return
+	}
+	metadata.Deprecated = metadata.Deprecated || status.Deprecated
+	metadata.Yanked = status.Yanked
+}
+
+// chartPath returns the archive path of a chart version. The reference is
+// either a version or the sha256 digest of the archive.
+func (s *ChartService) chartPath(chartName, reference string) string {
+	if strings.HasPrefix(reference, "sha256:") {
+		if p := s.findChartByDigest(chartName, reference); p != "" {
+			return p
+		}
+	}
+	return s.pathManager.GetChartPath(chartName, reference)
+}
+
+// findChartByDigest looks for the archive of a chart whose sha256 matches the digest
+func (s *ChartService) findChartByDigest(chartName, digest string) string {
+	chartsDir := s.pathManager.GetChartsPath()
+	matches, err := filepath.Glob(filepath.Join(chartsDir, chartName+"-*.tgz"))
+	if err != nil {
+		return ""
+	}
+	for _, match := range matches {
+		data, err := os.ReadFile(match)
+		if err != nil {
+			continue
+		}
+		if fmt.Sprintf("sha256:%x", sha256.Sum256(data)) != digest {
+			continue
+		}
+		// Le préfixe du fichier peut correspondre à un autre chart (my-chart vs my)
+		if metadata, err := s.ExtractChartMetadata(data); err == nil && metadata.Name == chartName {
+			return match
+		}
+	}
+	return ""
+}
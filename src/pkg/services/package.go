@@ -0,0 +1,321 @@
+// pkg/services/package.go
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"helm-portal/pkg/models"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/sirupsen/logrus"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+var (
+	// ErrInvalidPackageSource is returned when the uploaded chart source cannot be packaged
+	ErrInvalidPackageSource = errors.New("invalid chart source")
+	// ErrDependencyUnresolved is returned when a dependency is neither vendored nor hosted here
+	ErrDependencyUnresolved = errors.New("dependency cannot be resolved")
+)
+
+// PackageChart packages an unpackaged chart directory, sent as a tar, tar.gz or zip archive,
+// the way `helm package --dependency-update` would: missing dependencies are vendored from the
+// charts hosted here (or from file:// paths inside the archive), then the chart is stored with SaveChart.
+// An empty version keeps the version of Chart.yaml.
+func (s *ChartService) PackageChart(source []byte, version string) (*models.PackageResult, error) {
+	workDir, err := os.MkdirTemp("", "helm-portal-package-")
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to create work directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	srcDir := filepath.Join(workDir, "src")
+	if err := extractSourceArchive(source, srcDir); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPackageSource, err)
+	}
+	chartDir, err := findChartRoot(srcDir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPackageSource, err)
+	}
+
+	ch, err := loader.LoadDir(chartDir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPackageSource, err)
+	}
+	if version != "" {
+		if _, err := semver.StrictNewVersion(version); err != nil {
+			return nil, fmt.Errorf("%w: version %q is not a valid semantic version", ErrInvalidPackageSource, version)
+		}
+		ch.Metadata.Version = version
+	}
+
+	vendored, err := s.vendorDependencies(ch, chartDir, srcDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// 📦 Même archive que `helm package`
+	outDir := filepath.Join(workDir, "out")
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return nil, fmt.Errorf("❌ failed to create output directory: %w", err)
+	}
+	archivePath, err := chartutil.Save(ch, outDir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPackageSource, err)
+	}
+	chartData, err := os.ReadFile(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to read packaged chart: %w", err)
+	}
+
+	filename := filepath.Base(archivePath)
+	result := &models.PackageResult{
+		Name:     ch.Metadata.Name,
+		Version:  ch.Metadata.Version,
+		Filename: filename,
+		Digest:   fmt.Sprintf("sha256:%x", sha256.Sum256(chartData)),
+		Size:     len(chartData),
+		Vendored: vendored,
+		Findings: []models.ValidationFinding{},
+		Archive:  chartData,
+	}
+
+	// 🔎 Les findings sont retournés même quand le chart est accepté
+	report := s.validator.Validate(chartData, filename)
+	result.Findings = report.Findings
+	if report.HasErrors() {
+		return result, &ChartValidationError{Report: report}
+	}
+
+	if err := s.SaveChart(chartData, filename); err != nil {
+		return result, err
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"name":     result.Name,
+		"version":  result.Version,
+		"vendored": len(vendored),
+	}).Info("✅ Chart packaged from source")
+	return result, nil
+}
+
+// vendorDependencies adds to the chart the dependencies missing from its charts/ directory
+func (s *ChartService) vendorDependencies(ch *chart.Chart, chartDir, rootDir string) ([]models.VendoredDependency, error) {
+	vendored := []models.VendoredDependency{}
+
+	present := make(map[string]bool)
+	for _, dep := range ch.Dependencies() {
+		present[dep.Name()] = true
+	}
+
+	for _, dep := range ch.Metadata.Dependencies {
+		if present[dep.Name] {
+			continue
+		}
+
+		constraint := dep.Version
+		if constraint == "" {
+			constraint = "*"
+		}
+
+		var sub *chart.Chart
+		var source string
+		switch {
+		case strings.HasPrefix(dep.Repository, "file://"):
+			// Chemin relatif au chart, qui doit rester dans l'archive envoyée
+			depDir := filepath.Join(chartDir, filepath.FromSlash(strings.TrimPrefix(dep.Repository, "file://")))
+			if rel, err := filepath.Rel(rootDir, depDir); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return nil, fmt.Errorf("%w: %s: %s is outside of the uploaded archive", ErrDependencyUnresolved, dep.Name, dep.Repository)
+			}
+			loaded, err := loader.LoadDir(depDir)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrDependencyUnresolved, dep.Name, err)
+			}
+			sub, source = loaded, "local"
+
+		case s.isHostedRepository(dep.Repository):
+			resolved, err := s.ResolveChartVersion(dep.Name, constraint)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s %s: %v", ErrDependencyUnresolved, dep.Name, constraint, err)
+			}
+			data, err := s.GetChart(dep.Name, resolved.Version)
+			if err != nil {
+				return nil, fmt.Errorf("❌ failed to read dependency %s: %w", dep.Name, err)
+			}
+			loaded, err := loader.LoadArchive(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("❌ failed to load dependency %s: %w", dep.Name, err)
+			}
+			sub, source = loaded, "hosted"
+
+		default:
+			return nil, fmt.Errorf("%w: %s is not vendored in charts/ and %q is not hosted here", ErrDependencyUnresolved, dep.Name, dep.Repository)
+		}
+
+		if c, err := semver.NewConstraint(constraint); err == nil {
+			if v, err := semver.NewVersion(sub.Metadata.Version); err != nil || !c.Check(v) {
+				return nil, fmt.Errorf("%w: %s %s does not satisfy %s", ErrDependencyUnresolved, dep.Name, sub.Metadata.Version, constraint)
+			}
+		}
+
+		ch.AddDependency(sub)
+		present[dep.Name] = true
+		vendored = append(vendored, models.VendoredDependency{
+			Name:    sub.Metadata.Name,
+			Version: sub.Metadata.Version,
+			Source:  source,
+		})
+	}
+	return vendored, nil
+}
+
+// extractSourceArchive extracts a tar, tar.gz or zip archive into dir.
+// Links and paths escaping dir are rejected; the total size is bounded like a chart archive.
+func extractSourceArchive(source []byte, dir string) error {
+	switch {
+	case bytes.HasPrefix(source, []byte("PK\x03\x04")):
+		return extractZip(source, dir)
+	case bytes.HasPrefix(source, []byte{0x1f, 0x8b}):
+		gr, err := gzip.NewReader(bytes.NewReader(source))
+		if err != nil {
+			return fmt.Errorf("invalid gzip archive: %w", err)
+		}
+		defer gr.Close()
+		return extractTar(gr, dir)
+	}
+	return extractTar(bytes.NewReader(source), dir)
+}
+
+func extractTar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	var total int64
+	found := false
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid tar archive: %w", err)
+		}
+		found = true
+		switch header.Typeflag {
+		case tar.TypeDir:
+			continue
+		case tar.TypeReg:
+		default:
+			return fmt.Errorf("%s: only regular files are allowed", header.Name)
+		}
+
+		total += header.Size
+		if total > maxDecompressedChartSize {
+			return fmt.Errorf("archive exceeds %d bytes", maxDecompressedChartSize)
+		}
+		if err := writeSourceFile(dir, header.Name, tr); err != nil {
+			return err
+		}
+	}
+	if !found {
+		return fmt.Errorf("archive is empty")
+	}
+	return nil
+}
+
+func extractZip(source []byte, dir string) error {
+	zr, err := zip.NewReader(bytes.NewReader(source), int64(len(source)))
+	if err != nil {
+		return fmt.Errorf("invalid zip archive: %w", err)
+	}
+
+	var total int64
+	for _, file := range zr.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		if !file.Mode().IsRegular() {
+			return fmt.Errorf("%s: only regular files are allowed", file.Name)
+		}
+
+		total += int64(file.UncompressedSize64)
+		if total > maxDecompressedChartSize {
+			return fmt.Errorf("archive exceeds %d bytes", maxDecompressedChartSize)
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("%s: %w", file.Name, err)
+		}
+		err = writeSourceFile(dir, file.Name, io.LimitReader(rc, int64(file.UncompressedSize64)))
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSourceFile writes an archive entry under dir, refusing absolute paths and ".."
+func writeSourceFile(dir, name string, content io.Reader) error {
+	clean := path.Clean(strings.TrimPrefix(filepath.ToSlash(name), "./"))
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("%s: path escapes the archive", name)
+	}
+
+	target := filepath.Join(dir, filepath.FromSlash(clean))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, content); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// findChartRoot returns the shallowest directory containing a Chart.yaml,
+// so archives with or without a top-level directory are both accepted
+func findChartRoot(dir string) (string, error) {
+	root := ""
+	depth := -1
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != chartutil.ChartfileName {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, filepath.Dir(p))
+		level := 0
+		if rel != "." {
+			level = len(strings.Split(rel, string(filepath.Separator)))
+		}
+		if depth < 0 || level < depth {
+			root, depth = filepath.Dir(p), level
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if root == "" {
+		return "", fmt.Errorf("Chart.yaml not found in archive")
+	}
+	return root, nil
+}
@@ -0,0 +1,142 @@
+// pkg/services/policy.go
+package service
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"helm-portal/config"
+	utils "helm-portal/pkg/utils"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// Overwrite modes
+const (
+	OverwriteAllow      = "allow"
+	OverwriteImmutable  = "immutable"
+	OverwritePrerelease = "prerelease"
+)
+
+// cosign and similar tools re-push these tags when adding signatures or attestations
+var referrerTagPattern = regexp.MustCompile(`^sha256-[a-f0-9]{64}\.(sig|att|sbom)$`)
+
+// VersionConflictError is returned when a push would replace an existing
+// version that the overwrite policy protects
+type VersionConflictError struct {
+	Repository string
+	Version    string
+	Mode       string
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%s:%s already exists and cannot be overwritten (policy: %s)", e.Repository, e.Version, e.Mode)
+}
+
+// OverwritePolicy decides whether an existing chart version or image tag may be replaced
+type OverwritePolicy struct {
+	defaultMode string
+	rules       []config.OverwriteRule
+	mutableTags map[string]bool
+	namespace   string
+	log         *utils.Logger
+}
+
+// NewOverwritePolicy creates the policy from the configuration.
+// Without configuration every overwrite is allowed, as before.
+func NewOverwritePolicy(cfg *config.Config, log *utils.Logger) *OverwritePolicy {
+	p := &OverwritePolicy{
+		defaultMode: OverwriteAllow,
+		mutableTags: make(map[string]bool),
+		log:         log,
+	}
+
+	overwrite := cfg.Policies.Overwrite
+	if overwrite.Default != "" {
+		if isValidOverwriteMode(overwrite.Default) {
+			p.defaultMode = overwrite.Default
+		} else {
+			log.WithField("mode", overwrite.Default).Warn("Unknown default overwrite mode, using allow")
+		}
+	}
+
+	for _, rule := range overwrite.Rules {
+		if !isValidOverwriteMode(rule.Mode) {
+			log.WithFields(logrus.Fields{
+				"pattern": rule.Pattern,
+				"mode":    rule.Mode,
+			}).Warn("Ignoring overwrite rule with unknown mode")
+			continue
+		}
+		if _, err := path.Match(rule.Pattern, ""); err != nil {
+			log.WithError(err).WithField("pattern", rule.Pattern).Warn("Ignoring overwrite rule with invalid pattern")
+			continue
+		}
+		p.rules = append(p.rules, rule)
+	}
+
+	for _, tag := range overwrite.MutableTags {
+		p.mutableTags[tag] = true
+	}
+
+	return p
+}
+
+// WithNamespace scopes the policy to an isolated repository: its rules are matched against <namespace>/<name>
+func (p *OverwritePolicy) WithNamespace(namespace string) *OverwritePolicy {
+	p.namespace = namespace
+	return p
+}
+
+func isValidOverwriteMode(mode string) bool {
+	return mode == OverwriteAllow || mode == OverwriteImmutable || mode == OverwritePrerelease
+}
+
+// ModeFor returns the overwrite mode of a repository. The first matching rule wins.
+func (p *OverwritePolicy) ModeFor(repository string) string {
+	for _, rule := range p.rules {
+		if matched, _ := path.Match(rule.Pattern, repository); matched {
+			return rule.Mode
+		}
+	}
+	return p.defaultMode
+}
+
+// Check returns a *VersionConflictError if the existing version of a
+// repository must not be replaced with different content
+func (p *OverwritePolicy) Check(repository, version string) error {
+	if p.mutableTags[version] || referrerTagPattern.MatchString(version) {
+		return nil
+	}
+	if p.namespace != "" {
+		repository = p.namespace + "/" + repository
+	}
+
+	mode := p.ModeFor(repository)
+	switch mode {
+	case OverwriteAllow:
+		return nil
+	case OverwritePrerelease:
+		if IsPrereleaseVersion(version) {
+			return nil
+		}
+	}
+
+	return &VersionConflictError{
+		Repository: repository,
+		Version:    version,
+		Mode:       mode,
+	}
+}
+
+// IsPrereleaseVersion returns true for semver pre-releases and SNAPSHOT-style versions
+func IsPrereleaseVersion(version string) bool {
+	if strings.Contains(strings.ToUpper(version), "SNAPSHOT") {
+		return true
+	}
+	v, err := semver.NewVersion(version)
+	return err == nil && v.Prerelease() != ""
+}
@@ -0,0 +1,714 @@
+// pkg/services/promotion.go
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"helm-portal/config"
+	"helm-portal/pkg/interfaces"
+	"helm-portal/pkg/models"
+	"helm-portal/pkg/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrInvalidPromotion is returned for an incomplete or inconsistent promotion request
+	ErrInvalidPromotion = errors.New("invalid promotion")
+	// ErrPromotionForbidden is returned when the user cannot read the source or write the target repository
+	ErrPromotionForbidden = errors.New("promotion not allowed")
+	// ErrPromotionSourceNotFound is returned when the chart version or image to promote does not exist
+	ErrPromotionSourceNotFound = errors.New("artifact to promote not found")
+	// ErrPromotionGateFailed is returned when a required signature or scan check does not pass
+	ErrPromotionGateFailed = errors.New("promotion gate failed")
+)
+
+// Gates of a promotion
+const (
+	PromotionGateSignature = "signature"
+	PromotionGateScan      = "scan"
+)
+
+// defaultPromotionMaxSeverity is the most severe vulnerability tolerated by the scan gate when none is given
+const defaultPromotionMaxSeverity = models.SeverityMedium
+
+// promotionReferencePattern accepts OCI tags and chart versions, which may contain a '+'
+var promotionReferencePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.+-]{0,127}$`)
+
+// promotionRepository is the default repository or an isolated one, as seen by a promotion
+type promotionRepository struct {
+	name       string
+	config     config.RepositoryConfig
+	charts     interfaces.ChartServiceInterface
+	images     interfaces.ImageServiceInterface
+	signatures *SignatureService
+}
+
+func (r *promotionRepository) pathManager() *utils.PathManager {
+	return r.images.GetPathManager()
+}
+
+// PromotionService copies chart versions and images (with their platforms, signatures and SBOMs) from
+// a repository to another, or to another name or tag of the same repository. Manifests are written
+// byte for byte, so digests and signatures stay valid; blobs are hard-linked, not copied, when possible.
+type PromotionService struct {
+	defaultRepository *promotionRepository
+	repositories      *RepositoryRegistry
+	scans             *ScanService
+	rules             []config.PromotionRule
+	log               *utils.Logger
+}
+
+// NewPromotionService creates the promotion between the default repository and the isolated ones.
+// repositories, signatures and scans may be nil: the gates then fail.
+func NewPromotionService(cfg *config.Config, chartService interfaces.ChartServiceInterface, imageService interfaces.ImageServiceInterface, repositories *RepositoryRegistry, signatures *SignatureService, scans *ScanService, log *utils.Logger) (*PromotionService, error) {
+	for _, rule := range cfg.Policies.Promotion.Rules {
+		if _, err := path.Match(rule.Repository, ""); err != nil {
+			return nil, fmt.Errorf("❌ promotion rule: invalid repository pattern %q", rule.Repository)
+		}
+		if rule.MaxSeverity != "" && !validPromotionSeverity(rule.MaxSeverity) {
+			return nil, fmt.Errorf("❌ promotion rule %q: invalid maxSeverity %q", rule.Repository, rule.MaxSeverity)
+		}
+	}
+
+	return &PromotionService{
+		defaultRepository: &promotionRepository{
+			charts:     chartService,
+			images:     imageService,
+			signatures: signatures,
+		},
+		repositories: repositories,
+		scans:        scans,
+		rules:        cfg.Policies.Promotion.Rules,
+		log:          log,
+	}, nil
+}
+
+func validPromotionSeverity(severity string) bool {
+	switch severity {
+	case models.SeverityCritical, models.SeverityHigh, models.SeverityMedium, models.SeverityLow:
+		return true
+	}
+	return false
+}
+
+// repository returns a repository by name, the default one for an empty name
+func (s *PromotionService) repository(name string) (*promotionRepository, error) {
+	if name == "" {
+		return s.defaultRepository, nil
+	}
+	if s.repositories == nil {
+		return nil, fmt.Errorf("%w: %s", ErrRepositoryNotFound, name)
+	}
+	repo, err := s.repositories.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return &promotionRepository{
+		name:       repo.Name(),
+		config:     repo.Config,
+		charts:     repo.Charts,
+		images:     repo.Images,
+		signatures: repo.Signatures,
+	}, nil
+}
+
+// Promote copies the source of the request to its target, as the given user. The gates required by the request
+// or by the promotion rules of the target repository are checked first; when one fails, the result lists them
+// along with ErrPromotionGateFailed and nothing is written.
+func (s *PromotionService) Promote(actor string, req models.PromotionRequest) (*models.PromotionResult, error) {
+	if err := validatePromotion(&req); err != nil {
+		return nil, err
+	}
+	source, err := s.repository(req.Source.Repository)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.repository(req.Target.Repository)
+	if err != nil {
+		return nil, err
+	}
+	if !source.config.CanRead(actor) {
+		return nil, fmt.Errorf("%w: %s cannot read repository %q", ErrPromotionForbidden, actor, source.name)
+	}
+	if !target.config.CanWrite(actor) {
+		return nil, fmt.Errorf("%w: %s cannot write to repository %q", ErrPromotionForbidden, actor, target.name)
+	}
+
+	// Contrôles demandés par la requête, complétés par les règles du dépôt cible
+	requireSignature, requireScan, maxSeverity := req.RequireSignature, req.RequireScan, req.MaxSeverity
+	if maxSeverity == "" {
+		maxSeverity = defaultPromotionMaxSeverity
+	}
+	for _, rule := range s.rules {
+		if matched, _ := path.Match(rule.Repository, target.name); !matched {
+			continue
+		}
+		requireSignature = requireSignature || rule.RequireSignature
+		if rule.RequireScan {
+			requireScan = true
+			if rule.MaxSeverity != "" && severityRank(rule.MaxSeverity) > severityRank(maxSeverity) {
+				maxSeverity = rule.MaxSeverity
+			}
+		}
+	}
+
+	result := &models.PromotionResult{Source: req.Source, Target: req.Target, Gates: []models.PromotionGate{}}
+	if data, digest, mediaType, err := readImageManifest(source.images, req.Source.Name, req.Source.Reference); err == nil {
+		result.Type = models.ArtifactTypeDockerImage
+		result.Digest = digest
+		if requireSignature {
+			result.Gates = append(result.Gates, s.imageSignatureGate(source, req.Source.Name, digest))
+		}
+		if requireScan {
+			result.Gates = append(result.Gates, s.imageScanGate(source, req.Source.Name, req.Source.Reference, maxSeverity))
+		}
+		if err := checkPromotionGates(result); err != nil {
+			return result, err
+		}
+		if err := s.promoteImage(source, target, req, data, mediaType, result); err != nil {
+			return nil, err
+		}
+	} else if version, ok := chartVersion(source.charts, req.Source.Name, req.Source.Reference); ok {
+		result.Type = models.ArtifactTypeHelmChart
+		if req.Target.Name != req.Source.Name || (req.Target.Reference != version && req.Target.Reference != helmTag(version)) {
+			return nil, fmt.Errorf("%w: a chart version keeps its name and version", ErrInvalidPromotion)
+		}
+		if requireSignature {
+			result.Gates = append(result.Gates, s.chartSignatureGate(source, req.Source.Name, version))
+		}
+		if requireScan {
+			result.Gates = append(result.Gates, s.chartScanGate(source, req.Source.Name, version, maxSeverity))
+		}
+		if err := checkPromotionGates(result); err != nil {
+			return result, err
+		}
+		if err := s.promoteChart(source, target, req.Source.Name, version, result); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, fmt.Errorf("%w: %s:%s", ErrPromotionSourceNotFound, req.Source.Name, req.Source.Reference)
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"actor":     actor,
+		"type":      result.Type,
+		"source":    fmt.Sprintf("%s/%s:%s", source.name, req.Source.Name, req.Source.Reference),
+		"target":    fmt.Sprintf("%s/%s:%s", target.name, req.Target.Name, req.Target.Reference),
+		"digest":    result.Digest,
+		"manifests": result.Manifests,
+		"blobs":     result.Blobs,
+	}).Info("✅ Artifact promoted")
+	return result, nil
+}
+
+// validatePromotion checks the names and references of a request and fills the target with the source defaults
+func validatePromotion(req *models.PromotionRequest) error {
+	if req.Target.Name == "" {
+		req.Target.Name = req.Source.Name
+	}
+	if req.Target.Reference == "" {
+		req.Target.Reference = req.Source.Reference
+	}
+	for _, name := range []string{req.Source.Name, req.Target.Name} {
+		for _, segment := range strings.Split(name, "/") {
+			if !repositoryNamePattern.MatchString(segment) {
+				return fmt.Errorf("%w: invalid name %q", ErrInvalidPromotion, name)
+			}
+		}
+	}
+	for _, reference := range []string{req.Source.Reference, req.Target.Reference} {
+		if !promotionReferencePattern.MatchString(reference) && !digestPattern.MatchString(reference) {
+			return fmt.Errorf("%w: invalid reference %q", ErrInvalidPromotion, reference)
+		}
+	}
+	if req.MaxSeverity != "" && !validPromotionSeverity(req.MaxSeverity) {
+		return fmt.Errorf("%w: maxSeverity must be CRITICAL, HIGH, MEDIUM or LOW", ErrInvalidPromotion)
+	}
+	if req.Source == req.Target {
+		return fmt.Errorf("%w: source and target are the same", ErrInvalidPromotion)
+	}
+	return nil
+}
+
+// chartVersion returns the version of a chart designated by a version or by its OCI tag ('+' replaced by '_')
+func chartVersion(chartService interfaces.ChartServiceInterface, name, reference string) (string, bool) {
+	for _, version := range []string{reference, strings.ReplaceAll(reference, "_", "+")} {
+		if chartService.ChartExists(name, version) {
+			return version, true
+		}
+	}
+	return "", false
+}
+
+// helmTag returns the OCI tag of a chart version, helm replaces the '+' of build metadata by '_'
+func helmTag(version string) string {
+	return strings.ReplaceAll(version, "+", "_")
+}
+
+func checkPromotionGates(result *models.PromotionResult) error {
+	for _, gate := range result.Gates {
+		if !gate.Passed {
+			return fmt.Errorf("%w: %s: %s", ErrPromotionGateFailed, gate.Name, gate.Detail)
+		}
+	}
+	return nil
+}
+
+func (s *PromotionService) imageSignatureGate(source *promotionRepository, name, digest string) models.PromotionGate {
+	if source.signatures == nil {
+		return models.PromotionGate{Name: PromotionGateSignature, Detail: "signature verification is not configured"}
+	}
+	return signatureGate(source.signatures.Verify(name, digest))
+}
+
+func (s *PromotionService) chartSignatureGate(source *promotionRepository, name, version string) models.PromotionGate {
+	if source.signatures == nil {
+		return models.PromotionGate{Name: PromotionGateSignature, Detail: "signature verification is not configured"}
+	}
+	status, err := source.signatures.ChartStatus(name, version)
+	if err != nil {
+		return models.PromotionGate{Name: PromotionGateSignature, Detail: err.Error()}
+	}
+	return signatureGate(status)
+}
+
+// signatureGate passes when the manifest has a verified signature
+func signatureGate(status *models.SignatureStatus) models.PromotionGate {
+	gate := models.PromotionGate{Name: PromotionGateSignature, Passed: status.Verified(), Detail: status.Status}
+	if signers := status.Signers(); gate.Passed && len(signers) > 0 {
+		gate.Detail += " by " + strings.Join(signers, ", ")
+	}
+	return gate
+}
+
+// scanner returns the scan service of a repository
+func (s *PromotionService) scanner(source *promotionRepository) *ScanService {
+	if s.scans == nil || source == s.defaultRepository {
+		return s.scans
+	}
+	return s.scans.forRepository(source.charts, source.images)
+}
+
+// imageScanGate checks the scan of an image, scanning it now if it was not scanned yet
+func (s *PromotionService) imageScanGate(source *promotionRepository, name, reference, maxSeverity string) models.PromotionGate {
+	gate := models.PromotionGate{Name: PromotionGateScan}
+	scanner := s.scanner(source)
+	if scanner == nil {
+		gate.Detail = "vulnerability scanning is not configured"
+		return gate
+	}
+	report, err := scanner.GetReport(name, reference)
+	if errors.Is(err, ErrScanNotFound) {
+		report, err = scanner.ScanImage(name, reference)
+	}
+	if err != nil {
+		gate.Detail = err.Error()
+		return gate
+	}
+	if report.Status != models.ScanStatusCompleted {
+		gate.Detail = "scan " + report.Status
+		return gate
+	}
+	return severityGate(gate, report.Summary, maxSeverity)
+}
+
+// chartScanGate checks the scans of the images deployed by a chart version that are stored in its repository.
+// The images of other registries cannot be checked and are ignored.
+func (s *PromotionService) chartScanGate(source *promotionRepository, name, version, maxSeverity string) models.PromotionGate {
+	gate := models.PromotionGate{Name: PromotionGateScan}
+	scanner := s.scanner(source)
+	if scanner == nil {
+		gate.Detail = "vulnerability scanning is not configured"
+		return gate
+	}
+	report, err := scanner.ChartReport(name, version)
+	if err != nil {
+		gate.Detail = err.Error()
+		return gate
+	}
+	for _, image := range report.Images {
+		if image.Hosted && image.Status != models.ScanStatusCompleted {
+			gate.Detail = fmt.Sprintf("image %s is not scanned", image.Image)
+			return gate
+		}
+	}
+	return severityGate(gate, report.Summary, maxSeverity)
+}
+
+// severityGate passes when no vulnerability is more severe than maxSeverity
+func severityGate(gate models.PromotionGate, summary models.VulnerabilitySummary, maxSeverity string) models.PromotionGate {
+	counts := []struct {
+		severity string
+		count    int
+	}{
+		{models.SeverityCritical, summary.Critical},
+		{models.SeverityHigh, summary.High},
+		{models.SeverityMedium, summary.Medium},
+		{models.SeverityLow, summary.Low},
+	}
+	above := []string{}
+	for _, c := range counts {
+		if c.count > 0 && severityRank(c.severity) < severityRank(maxSeverity) {
+			above = append(above, fmt.Sprintf("%d %s", c.count, c.severity))
+		}
+	}
+	if len(above) > 0 {
+		gate.Detail = fmt.Sprintf("%s vulnerabilities above %s", strings.Join(above, ", "), maxSeverity)
+		return gate
+	}
+	gate.Passed = true
+	gate.Detail = fmt.Sprintf("no vulnerability above %s", maxSeverity)
+	return gate
+}
+
+// promotionJournal records how to restore the files written in the target, so that a promotion
+// failing halfway leaves the target repository as it was
+type promotionJournal struct {
+	undo []func()
+}
+
+func (j *promotionJournal) add(undo func()) {
+	j.undo = append(j.undo, undo)
+}
+
+// rollback restores the target files, the last written first
+func (j *promotionJournal) rollback() {
+	for i := len(j.undo) - 1; i >= 0; i-- {
+		j.undo[i]()
+	}
+	j.undo = nil
+}
+
+// promoteImage copies an image or index manifest to the target tag, with its platforms and referrers.
+// The overwrite policy of the target tag is checked before anything is written, and the tag is written
+// last, once its blobs, platforms and signatures are in place; a failure restores the target.
+func (s *PromotionService) promoteImage(source, target *promotionRepository, req models.PromotionRequest, data []byte, mediaType string, result *models.PromotionResult) error {
+	if err := target.images.CheckOverwrite(req.Target.Name, req.Target.Reference, data); err != nil {
+		return err
+	}
+
+	journal := &promotionJournal{}
+	digests, err := s.copyImageContent(source, target, req.Source.Name, req.Target.Name, data, mediaType, journal, result)
+	if err != nil {
+		journal.rollback()
+		return err
+	}
+	for _, digest := range append(digests, fmt.Sprintf("sha256:%x", sha256.Sum256(data))) {
+		if err := s.copyReferrers(source, target, req.Source.Name, req.Target.Name, digest, journal, result); err != nil {
+			journal.rollback()
+			return err
+		}
+	}
+	if err := s.saveImage(target, req.Target.Name, req.Target.Reference, data, mediaType, journal, result); err != nil {
+		journal.rollback()
+		return err
+	}
+	return nil
+}
+
+// copyImage copies the content of a manifest and saves it in the target under reference.
+// It returns the digests of the manifests written.
+func (s *PromotionService) copyImage(source, target *promotionRepository, sourceName, targetName, reference string, data []byte, mediaType string, journal *promotionJournal, result *models.PromotionResult) ([]string, error) {
+	digests, err := s.copyImageContent(source, target, sourceName, targetName, data, mediaType, journal, result)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.saveImage(target, targetName, reference, data, mediaType, journal, result); err != nil {
+		return nil, err
+	}
+	return append(digests, fmt.Sprintf("sha256:%x", sha256.Sum256(data))), nil
+}
+
+// copyImageContent links the blobs of a manifest in the target, or saves the platform manifests of an index
+// by digest. It returns the digests of the platform manifests written.
+func (s *PromotionService) copyImageContent(source, target *promotionRepository, sourceName, targetName string, data []byte, mediaType string, journal *promotionJournal, result *models.PromotionResult) ([]string, error) {
+	digests := []string{}
+	if models.IsIndexMediaType(mediaType) {
+		var index models.OCIIndex
+		if err := json.Unmarshal(data, &index); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidManifest, err)
+		}
+		for _, descriptor := range index.Manifests {
+			childData, _, childType, err := readImageManifest(source.images, sourceName, descriptor.Digest)
+			if err != nil {
+				return nil, fmt.Errorf("%w: platform manifest %s", ErrPromotionSourceNotFound, descriptor.Digest)
+			}
+			childDigests, err := s.copyImage(source, target, sourceName, targetName, descriptor.Digest, childData, childType, journal, result)
+			if err != nil {
+				return nil, err
+			}
+			digests = append(digests, childDigests...)
+		}
+		return digests, nil
+	}
+
+	var manifest models.OCIManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidManifest, err)
+	}
+	if err := s.linkBlobs(source, target, &manifest, journal, result); err != nil {
+		return nil, err
+	}
+	return digests, nil
+}
+
+// saveImage saves a manifest in the target, recording how to restore the manifest, digest reference and tag it replaces
+func (s *PromotionService) saveImage(target *promotionRepository, name, reference string, data []byte, mediaType string, journal *promotionJournal, result *models.PromotionResult) error {
+	paths := []string{target.pathManager().GetImageTagPath(name, reference)}
+	for _, ref := range uniqueStrings(reference, fmt.Sprintf("sha256:%x", sha256.Sum256(data))) {
+		manifestPath := target.pathManager().GetImageManifestPath(name, ref)
+		paths = append(paths, manifestPath, utils.MediaTypePath(manifestPath))
+	}
+	journal.add(restoreFiles(paths...))
+	if err := target.images.SaveImage(name, reference, data, mediaType); err != nil {
+		return err
+	}
+	result.Manifests++
+	return nil
+}
+
+// copyReferrers copies the signatures, SBOMs and attestations of a manifest: the cosign tags
+// (sha256-<hex>.sig, .att, .sbom) and the referrers whose subject is the manifest
+func (s *PromotionService) copyReferrers(source, target *promotionRepository, sourceName, targetName, digest string, journal *promotionJournal, result *models.PromotionResult) error {
+	for _, suffix := range []string{"sig", "att", "sbom"} {
+		tag := strings.Replace(digest, ":", "-", 1) + "." + suffix
+		data, _, mediaType, err := readImageManifest(source.images, sourceName, tag)
+		if err != nil {
+			continue
+		}
+		if _, err := s.copyImage(source, target, sourceName, targetName, tag, data, mediaType, journal, result); err != nil {
+			return fmt.Errorf("failed to copy %s: %w", tag, err)
+		}
+		result.Signatures++
+	}
+
+	referrers, err := ListReferrers(source.pathManager(), sourceName, digest, "")
+	if err != nil {
+		return err
+	}
+	for _, referrer := range referrers {
+		// Les artefacts oras et cosign sont stockés avec les manifests des charts, les autres avec les images
+		manifestPath := source.pathManager().GetManifestPath(sourceName, referrer.Digest)
+		if data, err := os.ReadFile(manifestPath); err == nil {
+			var manifest models.OCIManifest
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidManifest, err)
+			}
+			if err := s.linkBlobs(source, target, &manifest, journal, result); err != nil {
+				return err
+			}
+			undo, err := replaceManifestFile(target.pathManager().GetManifestPath(targetName, referrer.Digest), data, referrer.MediaType)
+			if err != nil {
+				return err
+			}
+			journal.add(undo)
+			result.Manifests++
+		} else {
+			data, _, mediaType, err := readImageManifest(source.images, sourceName, referrer.Digest)
+			if err != nil {
+				continue
+			}
+			if _, err := s.copyImage(source, target, sourceName, targetName, referrer.Digest, data, mediaType, journal, result); err != nil {
+				return fmt.Errorf("failed to copy referrer %s: %w", referrer.Digest, err)
+			}
+		}
+		result.Signatures++
+	}
+	return nil
+}
+
+// promoteChart copies a chart version: its OCI manifests (written first, as on a push), its archive, its provenance
+// file and its referrers. A failure restores the target.
+func (s *PromotionService) promoteChart(source, target *promotionRepository, name, version string, result *models.PromotionResult) error {
+	chartData, err := source.charts.GetChart(name, version)
+	if err != nil {
+		return fmt.Errorf("%w: %s-%s", ErrPromotionSourceNotFound, name, version)
+	}
+	result.Digest = fmt.Sprintf("sha256:%x", sha256.Sum256(chartData))
+
+	journal := &promotionJournal{}
+	manifestDigests := []string{}
+	for _, reference := range uniqueStrings(version, helmTag(version)) {
+		manifestPath := source.pathManager().GetManifestPath(name, reference)
+		data, err := os.ReadFile(manifestPath)
+		if err != nil {
+			continue
+		}
+		var manifest models.OCIManifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			journal.rollback()
+			return fmt.Errorf("%w: %v", ErrInvalidManifest, err)
+		}
+		if err := s.linkBlobs(source, target, &manifest, journal, result); err != nil {
+			journal.rollback()
+			return err
+		}
+		mediaType, _ := os.ReadFile(utils.MediaTypePath(manifestPath))
+		undo, err := replaceManifestFile(target.pathManager().GetManifestPath(name, reference), data, models.ManifestMediaType(data, string(mediaType)))
+		if err != nil {
+			journal.rollback()
+			return err
+		}
+		journal.add(undo)
+		result.Manifests++
+		result.Digest = fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+		manifestDigests = append(manifestDigests, result.Digest)
+	}
+
+	filename := fmt.Sprintf("%s-%s.tgz", name, version)
+	previous, previousErr := target.charts.GetChart(name, version)
+	provenancePath := target.pathManager().GetChartPath(name, version) + ".prov"
+	restoreProvenance := restoreFiles(provenancePath)
+	if err := target.charts.SaveChart(chartData, filename); err != nil {
+		journal.rollback()
+		return err
+	}
+	journal.add(func() {
+		// Le chart remplacé est remis en place, un nouveau chart est supprimé avec son index
+		restoreProvenance()
+		if previousErr != nil {
+			target.charts.DeleteChart(name, version)
+		} else if !bytes.Equal(previous, chartData) {
+			target.charts.SaveChart(previous, filename)
+		}
+	})
+	if provenance, err := os.ReadFile(source.pathManager().GetChartPath(name, version) + ".prov"); err == nil {
+		if err := target.charts.SaveProvenance(filename, provenance); err != nil {
+			journal.rollback()
+			return err
+		}
+	}
+
+	for _, digest := range uniqueStrings(manifestDigests...) {
+		if err := s.copyReferrers(source, target, name, name, digest, journal, result); err != nil {
+			journal.rollback()
+			return err
+		}
+	}
+	return nil
+}
+
+// linkBlobs makes the config and layers of a manifest available in the target repository
+func (s *PromotionService) linkBlobs(source, target *promotionRepository, manifest *models.OCIManifest, journal *promotionJournal, result *models.PromotionResult) error {
+	descriptors := append([]models.OCIDescriptor{manifest.Config}, manifest.Layers...)
+	for _, descriptor := range descriptors {
+		if descriptor.Digest == "" {
+			continue
+		}
+		// Les couches non distribuables restent sur leur registre d'origine
+		if descriptor.MediaType == models.MediaTypeDockerLayerNonDist || descriptor.MediaType == models.MediaTypeOCILayerNonDist {
+			continue
+		}
+		linked, err := linkBlob(source.pathManager(), target.pathManager(), descriptor.Digest)
+		if err != nil {
+			return err
+		}
+		if linked {
+			blobPath := target.pathManager().GetBlobPath(descriptor.Digest)
+			journal.add(func() { os.Remove(blobPath) })
+			result.Blobs++
+		}
+	}
+	return nil
+}
+
+// linkBlob hard-links a blob of a repository into another, or copies it when they are on different
+// file systems. It returns false when the target already has the blob.
+func linkBlob(source, target *utils.PathManager, digest string) (bool, error) {
+	if !digestPattern.MatchString(digest) {
+		return false, fmt.Errorf("%w: invalid blob digest %q", ErrInvalidManifest, digest)
+	}
+	sourcePath, targetPath := source.GetBlobPath(digest), target.GetBlobPath(digest)
+	if _, err := os.Stat(targetPath); err == nil || sourcePath == targetPath {
+		return false, nil
+	}
+	if _, err := os.Stat(sourcePath); err != nil {
+		return false, fmt.Errorf("%w: blob %s", ErrPromotionSourceNotFound, digest)
+	}
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return false, fmt.Errorf("❌ failed to create blobs directory: %w", err)
+	}
+	if err := os.Link(sourcePath, targetPath); err == nil {
+		return true, nil
+	}
+
+	in, err := os.Open(sourcePath)
+	if err != nil {
+		return false, err
+	}
+	defer in.Close()
+	tmp := targetPath + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return false, fmt.Errorf("❌ failed to copy blob: %w", err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return false, fmt.Errorf("❌ failed to copy blob: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return false, fmt.Errorf("❌ failed to copy blob: %w", err)
+	}
+	return true, os.Rename(tmp, targetPath)
+}
+
+// replaceManifestFile writes a manifest and returns a function restoring the previous one, if any
+func replaceManifestFile(manifestPath string, data []byte, mediaType string) (func(), error) {
+	previous, previousErr := os.ReadFile(manifestPath)
+	previousType, _ := os.ReadFile(utils.MediaTypePath(manifestPath))
+	if err := writeManifestFile(manifestPath, data, mediaType); err != nil {
+		return nil, err
+	}
+	return func() {
+		if previousErr != nil {
+			os.Remove(manifestPath)
+			os.Remove(utils.MediaTypePath(manifestPath))
+			return
+		}
+		writeManifestFile(manifestPath, previous, string(previousType))
+	}, nil
+}
+
+// restoreFiles returns a function writing the files back as they are now, and removing those that do not exist yet
+func restoreFiles(paths ...string) func() {
+	previous := make(map[string][]byte)
+	for _, p := range paths {
+		if data, err := os.ReadFile(p); err == nil {
+			previous[p] = data
+		}
+	}
+	return func() {
+		for _, p := range paths {
+			if data, ok := previous[p]; ok {
+				os.WriteFile(p, data, 0644)
+			} else {
+				os.Remove(p)
+			}
+		}
+	}
+}
+
+func uniqueStrings(values ...string) []string {
+	seen := make(map[string]bool)
+	unique := []string{}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
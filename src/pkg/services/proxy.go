@@ -0,0 +1,717 @@
+// pkg/services/proxy.go
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"helm-portal/config"
+	"helm-portal/pkg/interfaces"
+	"helm-portal/pkg/models"
+	utils "helm-portal/pkg/utils"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	// ErrUpstreamNotFound is returned when the upstream does not have the requested manifest, blob or chart
+	ErrUpstreamNotFound = errors.New("not found upstream")
+	// ErrUpstreamUnavailable is returned when the upstream cannot be reached, refuses the credentials or fails
+	ErrUpstreamUnavailable = errors.New("upstream unavailable")
+	// ErrProxyRepositoryNotFound is returned for a Helm repository that is not proxied
+	ErrProxyRepositoryNotFound = errors.New("proxied repository not found")
+)
+
+const (
+	defaultProxyTimeout = 30 * time.Second
+	defaultProxyTTL     = 10 * time.Minute
+	// maxProxyManifestSize bounds the size of a manifest or index.yaml fetched from an upstream
+	maxProxyManifestSize = 10 << 20
+)
+
+// proxyManifestAccept lists the manifest media types accepted from upstream registries
+var proxyManifestAccept = strings.Join([]string{
+	models.MediaTypeOCIManifestList,
+	models.MediaTypeOCIManifest,
+	models.MediaTypeDockerManifestList,
+	models.MediaTypeDockerManifest,
+}, ", ")
+
+var blobDigestPattern = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// upstreamState tracks the availability of an upstream
+type upstreamState struct {
+	mu          sync.Mutex
+	lastSuccess time.Time
+	lastError   string
+	lastErrorAt time.Time
+}
+
+func (st *upstreamState) record(err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if err == nil {
+		st.lastSuccess = time.Now()
+		return
+	}
+	st.lastError = err.Error()
+	st.lastErrorAt = time.Now()
+}
+
+func (st *upstreamState) status(name, kind, rawURL string) models.UpstreamStatus {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	status := models.UpstreamStatus{
+		Name:   name,
+		Type:   kind,
+		URL:    rawURL,
+		Online: st.lastErrorAt.IsZero() || st.lastSuccess.After(st.lastErrorAt),
+	}
+	if !st.lastSuccess.IsZero() {
+		lastSuccess := st.lastSuccess
+		status.LastSuccess = &lastSuccess
+	}
+	if !st.lastErrorAt.IsZero() {
+		lastErrorAt := st.lastErrorAt
+		status.LastError = st.lastError
+		status.LastErrorAt = &lastErrorAt
+	}
+	return status
+}
+
+// upstreamRegistry is an OCI registry whose repositories are cached under <name>/
+type upstreamRegistry struct {
+	config    config.UpstreamRegistry
+	client    *registryClient
+	namespace string
+	dockerHub bool
+	tagTTL    time.Duration
+	state     upstreamState
+}
+
+// upstreamHelmRepository is a Helm HTTP repository cached under /proxy/<name>/
+type upstreamHelmRepository struct {
+	config   config.UpstreamHelmRepository
+	indexURL *url.URL
+	indexTTL time.Duration
+	state    upstreamState
+}
+
+// proxyCall is a fetch in progress, shared by the concurrent requests of the same content
+type proxyCall struct {
+	done chan struct{}
+	err  error
+}
+
+// ProxyService is the pull-through cache of upstream registries (Docker Hub, ghcr.io...) and Helm repositories.
+// Manifests and blobs missing locally are fetched from the upstream, verified and stored like pushed ones.
+// Tags are revalidated once older than their TTL; when the upstream is unreachable the cached copy is served.
+type ProxyService struct {
+	pathManager  *utils.PathManager
+	imageService interfaces.ImageServiceInterface
+	client       *http.Client
+	log          *utils.Logger
+
+	registries []*upstreamRegistry
+	helmRepos  map[string]*upstreamHelmRepository
+
+	mu       sync.Mutex
+	inflight map[string]*proxyCall
+}
+
+// NewProxyService creates the pull-through cache of the upstreams configured under proxy
+func NewProxyService(cfg *config.Config, pathManager *utils.PathManager, imageService interfaces.ImageServiceInterface, log *utils.Logger) (*ProxyService, error) {
+	timeout, err := parseProxyDuration(cfg.Proxy.Timeout, defaultProxyTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("❌ invalid proxy timeout: %w", err)
+	}
+
+	s := &ProxyService{
+		pathManager:  pathManager,
+		imageService: imageService,
+		// Le timeout borne l'attente des en-têtes de réponse, pas le téléchargement des blobs
+		client: &http.Client{Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           (&net.Dialer{Timeout: timeout}).DialContext,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+		}},
+		log:       log,
+		helmRepos: make(map[string]*upstreamHelmRepository),
+		inflight:  make(map[string]*proxyCall),
+	}
+
+	names := make(map[string]bool)
+	for _, registryConfig := range cfg.Proxy.Registries {
+		name := strings.Trim(registryConfig.Name, "/")
+		if name == "" || names[name] {
+			return nil, fmt.Errorf("❌ proxy registry name %q is empty or duplicated", registryConfig.Name)
+		}
+		names[name] = true
+
+		u, err := url.Parse(registryConfig.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("❌ proxy registry %s: %q is not an http(s) URL", name, registryConfig.URL)
+		}
+		ttl, err := parseProxyDuration(registryConfig.TagTTL, defaultProxyTTL)
+		if err != nil {
+			return nil, fmt.Errorf("❌ proxy registry %s: invalid tagTTL: %w", name, err)
+		}
+
+		registryConfig.Name = name
+		registry := &upstreamRegistry{
+			config:    registryConfig,
+			client:    newRegistryClient(u.String(), registryConfig.Username, registryConfig.Password, s.client),
+			namespace: registryConfig.Namespace,
+			dockerHub: isDockerHubHost(u.Hostname()),
+			tagTTL:    ttl,
+		}
+		if registry.namespace == "" {
+			registry.namespace = u.Host
+			if registry.dockerHub {
+				registry.namespace = "docker.io"
+			}
+		}
+		s.registries = append(s.registries, registry)
+	}
+	// Le préfixe le plus long d'abord : "ghcr/org" avant "ghcr"
+	sort.SliceStable(s.registries, func(i, j int) bool {
+		return len(s.registries[i].config.Name) > len(s.registries[j].config.Name)
+	})
+
+	for _, repoConfig := range cfg.Proxy.HelmRepositories {
+		if repoConfig.Name == "" || strings.ContainsAny(repoConfig.Name, "/\\") || s.helmRepos[repoConfig.Name] != nil {
+			return nil, fmt.Errorf("❌ proxy Helm repository name %q is invalid or duplicated", repoConfig.Name)
+		}
+		indexURL, err := importIndexURL(repoConfig.URL)
+		if err != nil {
+			return nil, fmt.Errorf("❌ proxy Helm repository %s: %w", repoConfig.Name, err)
+		}
+		ttl, err := parseProxyDuration(repoConfig.IndexTTL, defaultProxyTTL)
+		if err != nil {
+			return nil, fmt.Errorf("❌ proxy Helm repository %s: invalid indexTTL: %w", repoConfig.Name, err)
+		}
+		s.helmRepos[repoConfig.Name] = &upstreamHelmRepository{config: repoConfig, indexURL: indexURL, indexTTL: ttl}
+	}
+
+	return s, nil
+}
+
+func parseProxyDuration(value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative duration %s", value)
+	}
+	return d, nil
+}
+
+func isDockerHubHost(host string) bool {
+	return host == "docker.io" || host == "registry-1.docker.io" || host == "index.docker.io"
+}
+
+// Status returns the state of the upstream registries, then of the Helm repositories, by name
+func (s *ProxyService) Status() []models.UpstreamStatus {
+	registries := make([]models.UpstreamStatus, 0, len(s.registries))
+	for _, registry := range s.registries {
+		registries = append(registries, registry.state.status(registry.config.Name, models.UpstreamTypeRegistry, registry.config.URL))
+	}
+	helmRepos := make([]models.UpstreamStatus, 0, len(s.helmRepos))
+	for name, repo := range s.helmRepos {
+		helmRepos = append(helmRepos, repo.state.status(name, models.UpstreamTypeHelm, repo.config.URL))
+	}
+	for _, statuses := range [][]models.UpstreamStatus{registries, helmRepos} {
+		sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	}
+	return append(registries, helmRepos...)
+}
+
+// resolve returns the upstream caching a local repository name and the repository name upstream.
+// Single-segment Docker Hub names are official images (dockerhub/nginx -> library/nginx).
+func (s *ProxyService) resolve(name string) (*upstreamRegistry, string, bool) {
+	for _, registry := range s.registries {
+		prefix := registry.config.Name + "/"
+		if !strings.HasPrefix(name, prefix) || len(name) == len(prefix) {
+			continue
+		}
+		remote := name[len(prefix):]
+		if registry.dockerHub && !strings.Contains(remote, "/") {
+			remote = "library/" + remote
+		}
+		return registry, remote, true
+	}
+	return nil, "", false
+}
+
+// IsProxied tells whether a repository name belongs to an upstream registry
+func (s *ProxyService) IsProxied(name string) bool {
+	_, _, ok := s.resolve(name)
+	return ok
+}
+
+// MirrorName maps a repository requested by a containerd registry mirror (?ns=docker.io) to its local name
+func (s *ProxyService) MirrorName(namespace, name string) (string, bool) {
+	for _, registry := range s.registries {
+		if registry.namespace == namespace {
+			return registry.config.Name + "/" + name, true
+		}
+	}
+	return "", false
+}
+
+// EnsureManifest makes sure a manifest of a proxied repository is cached. A digest is fetched once, a tag
+// is fetched when missing and revalidated once older than the tag TTL. When the upstream fails the cached
+// copy, if any, is kept and served (offline mode). Names of other repositories are left untouched.
+func (s *ProxyService) EnsureManifest(name, reference string) error {
+	registry, remote, ok := s.resolve(name)
+	if !ok {
+		return nil
+	}
+
+	manifestPath := s.pathManager.GetImageManifestPath(name, reference)
+	info, statErr := os.Stat(manifestPath)
+	cached := statErr == nil
+	if cached && (strings.HasPrefix(reference, "sha256:") || time.Since(info.ModTime()) < registry.tagTTL) {
+		return nil
+	}
+
+	err := s.once("manifest:"+name+":"+reference, func() error {
+		return s.fetchManifest(registry, name, remote, reference, manifestPath, cached)
+	})
+	if err != nil && cached {
+		s.log.WithFunc().WithError(err).WithFields(logrus.Fields{
+			"name":      name,
+			"reference": reference,
+		}).Warn("⚠️ Upstream unavailable, serving cached manifest")
+		return nil
+	}
+	return err
+}
+
+func (s *ProxyService) fetchManifest(registry *upstreamRegistry, name, remote, reference, manifestPath string, cached bool) error {
+	header := http.Header{"Accept": []string{proxyManifestAccept}}
+	manifestURL := fmt.Sprintf("/v2/%s/manifests/%s", remote, reference)
+
+	// Revalidation d'un tag : un HEAD suffit si le digest n'a pas changé (et ne compte pas dans les quotas Docker Hub)
+	if cached {
+		if data, err := os.ReadFile(manifestPath); err == nil {
+			resp, err := s.registryRequest(registry, http.MethodHead, manifestURL, remote, header)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			digest := fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+			if resp.StatusCode == http.StatusOK && resp.Header.Get("Docker-Content-Digest") == digest {
+				registry.state.record(nil)
+				now := time.Now()
+				return os.Chtimes(manifestPath, now, now)
+			}
+		}
+	}
+
+	resp, err := s.registryRequest(registry, http.MethodGet, manifestURL, remote, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := registry.checkResponse(resp, name+":"+reference); err != nil {
+		return err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxProxyManifestSize+1))
+	if err != nil {
+		registry.state.record(err)
+		return fmt.Errorf("%w: %v", ErrUpstreamUnavailable, err)
+	}
+	if len(data) > maxProxyManifestSize {
+		return fmt.Errorf("%w: manifest %s:%s exceeds %d bytes", ErrUpstreamUnavailable, name, reference, maxProxyManifestSize)
+	}
+
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+	if strings.HasPrefix(reference, "sha256:") && digest != reference {
+		return fmt.Errorf("%w: got %s", ErrManifestDigestMismatch, digest)
+	}
+	if announced := resp.Header.Get("Docker-Content-Digest"); announced != "" && announced != digest {
+		return fmt.Errorf("%w: upstream announces %s, got %s", ErrManifestDigestMismatch, announced, digest)
+	}
+
+	// La config de l'image sert aux métadonnées : elle est mise en cache avant le manifest
+	mediaType := models.ManifestMediaType(data, resp.Header.Get("Content-Type"))
+	if !models.IsIndexMediaType(mediaType) {
+		var manifest models.OCIManifest
+		if err := json.Unmarshal(data, &manifest); err == nil && manifest.Config.Digest != "" {
+			if err := s.ensureBlob(registry, name, remote, manifest.Config.Digest); err != nil {
+				s.log.WithFunc().WithError(err).WithField("name", name).Warn("Failed to cache image config")
+			}
+		}
+	}
+
+	if err := s.imageService.CacheImage(name, reference, data, mediaType); err != nil {
+		return fmt.Errorf("failed to cache manifest: %w", err)
+	}
+
+	s.log.WithFunc().WithFields(logrus.Fields{
+		"name":      name,
+		"reference": reference,
+		"digest":    digest,
+		"upstream":  registry.config.Name,
+	}).Info("Manifest cached from upstream")
+	return nil
+}
+
+// EnsureBlob fetches a blob of a proxied repository missing from the cache.
+// Blobs are content-addressed: once stored they never expire.
+func (s *ProxyService) EnsureBlob(name, digest string) error {
+	registry, remote, ok := s.resolve(name)
+	if !ok {
+		return nil
+	}
+	return s.ensureBlob(registry, name, remote, digest)
+}
+
+func (s *ProxyService) ensureBlob(registry *upstreamRegistry, name, remote, digest string) error {
+	if !blobDigestPattern.MatchString(digest) {
+		return fmt.Errorf("%w: unsupported digest %q", ErrUpstreamNotFound, digest)
+	}
+	blobPath := s.pathManager.GetBlobPath(digest)
+	if _, err := os.Stat(blobPath); err == nil {
+		return nil
+	}
+
+	return s.once("blob:"+digest, func() error {
+		if _, err := os.Stat(blobPath); err == nil {
+			return nil
+		}
+
+		resp, err := s.registryRequest(registry, http.MethodGet, fmt.Sprintf("/v2/%s/blobs/%s", remote, digest), remote, nil)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if err := registry.checkResponse(resp, name+"@"+digest); err != nil {
+			return err
+		}
+
+		if err := writeVerifiedFile(resp.Body, blobPath, strings.TrimPrefix(digest, "sha256:")); err != nil {
+			registry.state.record(err)
+			return fmt.Errorf("%w: blob %s: %v", ErrUpstreamUnavailable, digest, err)
+		}
+
+		s.log.WithFunc().WithFields(logrus.Fields{
+			"name":     name,
+			"digest":   digest,
+			"upstream": registry.config.Name,
+		}).Info("Blob cached from upstream")
+		return nil
+	})
+}
+
+// ListTags returns the tags of a proxied repository, as listed by its upstream
+func (s *ProxyService) ListTags(name string) ([]string, error) {
+	registry, remote, ok := s.resolve(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrProxyRepositoryNotFound, name)
+	}
+
+	resp, err := s.registryRequest(registry, http.MethodGet, fmt.Sprintf("/v2/%s/tags/list", remote), remote, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := registry.checkResponse(resp, name); err != nil {
+		return nil, err
+	}
+
+	var list struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxProxyManifestSize)).Decode(&list); err != nil {
+		return nil, fmt.Errorf("%w: invalid tags list: %v", ErrUpstreamUnavailable, err)
+	}
+	return list.Tags, nil
+}
+
+// checkResponse turns an unexpected upstream status into an error and records the upstream state
+func (r *upstreamRegistry) checkResponse(resp *http.Response, what string) error {
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		r.state.record(nil)
+		return nil
+	case resp.StatusCode == http.StatusNotFound:
+		// L'amont répond : il est disponible, le contenu n'existe simplement pas
+		r.state.record(nil)
+		return fmt.Errorf("%w: %s", ErrUpstreamNotFound, what)
+	}
+	err := fmt.Errorf("%w: %s: %s", ErrUpstreamUnavailable, what, resp.Status)
+	r.state.record(err)
+	return err
+}
+
+// registryRequest sends a pull request for a repository to an upstream registry
+func (s *ProxyService) registryRequest(registry *upstreamRegistry, method, requestPath, remote string, header http.Header) (*http.Response, error) {
+	resp, err := registry.client.do(method, requestPath, "repository:"+remote+":pull", header, nil)
+	if err != nil {
+		err = fmt.Errorf("%w: %v", ErrUpstreamUnavailable, err)
+		registry.state.record(err)
+		return nil, err
+	}
+	return resp, nil
+}
+
+// once runs fetch unless the same key is being fetched, in which case it waits for that fetch and returns its error
+func (s *ProxyService) once(key string, fetch func() error) error {
+	s.mu.Lock()
+	if call, ok := s.inflight[key]; ok {
+		s.mu.Unlock()
+		<-call.done
+		return call.err
+	}
+	call := &proxyCall{done: make(chan struct{})}
+	s.inflight[key] = call
+	s.mu.Unlock()
+
+	call.err = fetch()
+
+	s.mu.Lock()
+	delete(s.inflight, key)
+	s.mu.Unlock()
+	close(call.done)
+	return call.err
+}
+
+// writeVerifiedFile streams content to a temporary file next to dest, checks its sha256 (hex, if given)
+// and renames it to dest, so a partial download is never visible
+func writeVerifiedFile(content io.Reader, dest, expectedHex string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".proxy-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); expectedHex != "" && got != expectedHex {
+		return fmt.Errorf("digest mismatch: expected %s, got %s", expectedHex, got)
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// proxiedIndex keeps every field of an upstream index.yaml: only the chart URLs are rewritten
+type proxiedIndex struct {
+	Entries map[string][]map[string]interface{} `yaml:"entries"`
+	Rest    map[string]interface{}              `yaml:",inline"`
+}
+
+func (s *ProxyService) helmCachePath(name string, elem ...string) string {
+	return filepath.Join(append([]string{s.pathManager.GetBasePath(), "proxy", name}, elem...)...)
+}
+
+// HelmIndex returns the index.yaml of a proxied Helm repository. Chart URLs are rewritten to charts/<file>,
+// relative to the index, so that charts are downloaded through the cache.
+func (s *ProxyService) HelmIndex(name string) ([]byte, error) {
+	repo, ok := s.helmRepos[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrProxyRepositoryNotFound, name)
+	}
+	index, err := s.loadHelmIndex(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, versions := range index.Entries {
+		for _, version := range versions {
+			urls, _ := version["urls"].([]interface{})
+			for i, raw := range urls {
+				if chartURL, ok := raw.(string); ok {
+					urls[i] = "charts/" + path.Base(chartURL)
+				}
+			}
+		}
+	}
+	return yaml.Marshal(index)
+}
+
+// loadHelmIndex returns the cached index.yaml of a repository, fetched when missing or older than its TTL
+func (s *ProxyService) loadHelmIndex(repo *upstreamHelmRepository) (*proxiedIndex, error) {
+	indexPath := s.helmCachePath(repo.config.Name, "index.yaml")
+	info, statErr := os.Stat(indexPath)
+	if statErr != nil || time.Since(info.ModTime()) >= repo.indexTTL {
+		err := s.once("helm-index:"+repo.config.Name, func() error {
+			return s.fetchHelmIndex(repo, indexPath)
+		})
+		if err != nil {
+			if statErr != nil {
+				return nil, err
+			}
+			s.log.WithFunc().WithError(err).WithField("repository", repo.config.Name).Warn("⚠️ Upstream unavailable, serving cached index")
+		}
+	}
+
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached index: %w", err)
+	}
+	var index proxiedIndex
+	if err := yaml.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("invalid cached index: %w", err)
+	}
+	return &index, nil
+}
+
+// HelmChart returns the path of a cached chart archive of a proxied Helm repository, downloaded on first use
+func (s *ProxyService) HelmChart(name, file string) (string, error) {
+	repo, ok := s.helmRepos[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrProxyRepositoryNotFound, name)
+	}
+	if file == "" || file != filepath.Base(file) || strings.HasPrefix(file, ".") {
+		return "", fmt.Errorf("%w: %s", ErrUpstreamNotFound, file)
+	}
+	chartPath := s.helmCachePath(name, "charts", file)
+	if _, err := os.Stat(chartPath); err == nil {
+		return chartPath, nil
+	}
+
+	index, err := s.loadHelmIndex(repo)
+	if err != nil {
+		return "", err
+	}
+	for _, versions := range index.Entries {
+		for _, version := range versions {
+			urls, _ := version["urls"].([]interface{})
+			for _, raw := range urls {
+				rawURL, ok := raw.(string)
+				if !ok || path.Base(rawURL) != file {
+					continue
+				}
+				chartURL, err := repo.indexURL.Parse(rawURL)
+				if err != nil {
+					return "", fmt.Errorf("%w: invalid URL %q", ErrUpstreamUnavailable, rawURL)
+				}
+				digest, _ := version["digest"].(string)
+				// Les identifiants ne sont envoyés qu'à l'hôte du dépôt
+				sameHost := chartURL.Host == repo.indexURL.Host
+				err = s.once("helm-chart:"+chartPath, func() error {
+					if _, err := os.Stat(chartPath); err == nil {
+						return nil
+					}
+					return s.fetchHelmChart(repo, chartURL, chartPath, digest, sameHost)
+				})
+				if err != nil {
+					return "", err
+				}
+				s.log.WithFunc().WithFields(logrus.Fields{
+					"repository": name,
+					"file":       file,
+				}).Info("Chart cached from upstream")
+				return chartPath, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrUpstreamNotFound, file)
+}
+
+// fetchHelmIndex downloads the index.yaml of a Helm repository, replacing the cached copy only when it is valid
+func (s *ProxyService) fetchHelmIndex(repo *upstreamHelmRepository, dest string) error {
+	resp, err := s.helmRequest(repo, repo.indexURL, true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImportDownloadSize+1))
+	if err == nil && len(data) > maxImportDownloadSize {
+		err = fmt.Errorf("index exceeds %d bytes", maxImportDownloadSize)
+	}
+	if err == nil {
+		var index proxiedIndex
+		err = yaml.Unmarshal(data, &index)
+	}
+	if err == nil {
+		err = writeVerifiedFile(bytes.NewReader(data), dest, "")
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: %s: %v", ErrUpstreamUnavailable, repo.indexURL, err)
+	}
+	repo.state.record(err)
+	return err
+}
+
+// fetchHelmChart downloads a chart archive of a Helm repository, checking its sha256 when the index announces it
+func (s *ProxyService) fetchHelmChart(repo *upstreamHelmRepository, u *url.URL, dest, digest string, withAuth bool) error {
+	resp, err := s.helmRequest(repo, u, withAuth)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := writeVerifiedFile(resp.Body, dest, digest); err != nil {
+		err = fmt.Errorf("%w: %s: %v", ErrUpstreamUnavailable, u, err)
+		repo.state.record(err)
+		return err
+	}
+	return nil
+}
+
+// helmRequest gets a file of a Helm repository, with its credentials when withAuth is set
+func (s *ProxyService) helmRequest(repo *upstreamHelmRepository, u *url.URL, withAuth bool) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if withAuth && repo.config.Username != "" {
+		req.SetBasicAuth(repo.config.Username, repo.config.Password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		err = fmt.Errorf("%w: %v", ErrUpstreamUnavailable, err)
+		repo.state.record(err)
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		repo.state.record(nil)
+		return resp, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		repo.state.record(nil)
+		return nil, fmt.Errorf("%w: %s", ErrUpstreamNotFound, u)
+	}
+	resp.Body.Close()
+	err = fmt.Errorf("%w: %s: %s", ErrUpstreamUnavailable, u, resp.Status)
+	repo.state.record(err)
+	return nil, err
+}
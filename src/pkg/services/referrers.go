@@ -0,0 +1,86 @@
+// pkg/services/referrers.go
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"helm-portal/pkg/models"
+	utils "helm-portal/pkg/utils"
+)
+
+// ListReferrers returns the manifests of a repository whose subject is the given digest (OCI referrers API),
+// optionally filtered by artifact type. Artifacts pushed with oras or cosign are stored with the charts manifests,
+// images pushed with a subject with the images.
+func ListReferrers(pathManager *utils.PathManager, name, digest, artifactType string) ([]models.OCIDescriptor, error) {
+	dirs := []string{
+		filepath.Join(pathManager.GetBasePath(), "manifests", name),
+		filepath.Join(pathManager.GetImagePath(name), "manifests"),
+	}
+
+	referrers := []models.OCIDescriptor{}
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("❌ failed to read manifests: %w", err)
+		}
+		for _, f := range files {
+			if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+				continue
+			}
+			manifestPath := filepath.Join(dir, f.Name())
+			data, err := os.ReadFile(manifestPath)
+			if err != nil || !bytes.Contains(data, []byte(`"subject"`)) {
+				continue
+			}
+			var manifest models.OCIManifest
+			if json.Unmarshal(data, &manifest) != nil || manifest.Subject == nil || manifest.Subject.Digest != digest {
+				continue
+			}
+
+			// Un même manifest est stocké sous son tag et sous son digest
+			manifestDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+			if seen[manifestDigest] {
+				continue
+			}
+			seen[manifestDigest] = true
+
+			// artifactType, à défaut le type de la config (spécification OCI 1.1)
+			referrerType := manifest.ArtifactType
+			if referrerType == "" {
+				referrerType = manifest.Config.MediaType
+			}
+			if artifactType != "" && referrerType != artifactType {
+				continue
+			}
+			mediaType, _ := os.ReadFile(utils.MediaTypePath(manifestPath))
+			referrers = append(referrers, models.OCIDescriptor{
+				MediaType:    models.ManifestMediaType(data, string(mediaType)),
+				Digest:       manifestDigest,
+				Size:         int64(len(data)),
+				ArtifactType: referrerType,
+				Annotations:  manifest.Annotations,
+			})
+		}
+	}
+
+	// Les plus récents d'abord
+	sort.SliceStable(referrers, func(i, j int) bool {
+		ci, cj := referrers[i].Annotations[models.AnnotationCreated], referrers[j].Annotations[models.AnnotationCreated]
+		if ci != cj {
+			return ci > cj
+		}
+		return referrers[i].Digest < referrers[j].Digest
+	})
+	return referrers, nil
+}
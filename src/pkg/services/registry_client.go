@@ -0,0 +1,187 @@
+// pkg/services/registry_client.go
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errRegistryCredentials is returned when a registry asks for credentials that are not configured
+var errRegistryCredentials = errors.New("registry requires credentials")
+
+var challengeParamRegexp = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// bearerToken is a token of a registry authorization service, valid for one scope
+type bearerToken struct {
+	value   string
+	expires time.Time
+}
+
+// requestBody opens the body of a request. It is called again when the request is retried after a challenge.
+type requestBody func() (io.ReadCloser, int64, error)
+
+// registryClient sends requests to a remote OCI registry (distribution API). On a 401 it follows the
+// challenge: basic authentication with the configured credentials, or a bearer token fetched from the
+// authorization service (anonymously without credentials), cached per scope.
+type registryClient struct {
+	baseURL  string
+	username string
+	password string
+	client   *http.Client
+
+	mu     sync.Mutex
+	basic  bool // the registry asked for basic authentication
+	tokens map[string]bearerToken
+}
+
+func newRegistryClient(baseURL, username, password string, client *http.Client) *registryClient {
+	return &registryClient{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		username: username,
+		password: password,
+		client:   client,
+		tokens:   make(map[string]bearerToken),
+	}
+}
+
+// do sends a request to a path of the registry, or to an absolute URL such as an upload location.
+// scope is the token scope of the request, e.g. "repository:library/nginx:pull".
+func (r *registryClient) do(method, target, scope string, header http.Header, body requestBody) (*http.Response, error) {
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		target = r.baseURL + target
+	}
+
+	send := func() (*http.Response, error) {
+		req, err := http.NewRequest(method, target, nil)
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			content, size, err := body()
+			if err != nil {
+				return nil, err
+			}
+			req.Body, req.ContentLength = content, size
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		r.authorize(req, scope)
+		return r.client.Do(req)
+	}
+
+	resp, err := send()
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+
+	scheme, params := parseAuthChallenge(challenge)
+	switch {
+	case scheme == "bearer":
+		if params["scope"] == "" {
+			params["scope"] = scope
+		}
+		if err := r.fetchToken(params, scope); err != nil {
+			return nil, err
+		}
+	case scheme == "basic" || (scheme == "" && r.username != ""):
+		if r.username == "" {
+			return nil, errRegistryCredentials
+		}
+		r.mu.Lock()
+		r.basic = true
+		r.mu.Unlock()
+	default:
+		return nil, fmt.Errorf("unsupported authentication challenge %q", challenge)
+	}
+	return send()
+}
+
+// authorize adds the cached token of the scope, or the basic credentials, to a request
+func (r *registryClient) authorize(req *http.Request, scope string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if token, ok := r.tokens[scope]; ok && time.Now().Before(token.expires) {
+		req.Header.Set("Authorization", "Bearer "+token.value)
+		return
+	}
+	if r.basic {
+		req.SetBasicAuth(r.username, r.password)
+	}
+}
+
+// fetchToken gets a token from the authorization service of a bearer challenge
+func (r *registryClient) fetchToken(params map[string]string, scope string) error {
+	realm, err := url.Parse(params["realm"])
+	if err != nil || (realm.Scheme != "http" && realm.Scheme != "https") {
+		return fmt.Errorf("invalid token realm %q", params["realm"])
+	}
+	query := realm.Query()
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	query.Set("scope", params["scope"])
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("token request: %s", resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return fmt.Errorf("invalid token response: %w", err)
+	}
+	token := body.Token
+	if token == "" {
+		token = body.AccessToken
+	}
+	if token == "" {
+		return errors.New("empty token")
+	}
+	// Le jeton est renouvelé un peu avant son expiration (60s par défaut selon la spec)
+	lifetime := time.Duration(body.ExpiresIn) * time.Second
+	if lifetime <= 0 {
+		lifetime = 60 * time.Second
+	}
+
+	r.mu.Lock()
+	r.tokens[scope] = bearerToken{value: token, expires: time.Now().Add(lifetime - lifetime/10)}
+	r.mu.Unlock()
+	return nil
+}
+
+// parseAuthChallenge splits a WWW-Authenticate header into its lowercased scheme and parameters
+func parseAuthChallenge(header string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	params := make(map[string]string)
+	for _, match := range challengeParamRegexp.FindAllStringSubmatch(rest, -1) {
+		params[strings.ToLower(match[1])] = match[2]
+	}
+	return strings.ToLower(scheme), params
+}
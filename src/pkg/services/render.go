@@ -0,0 +1,181 @@
+// pkg/services/render.go
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"helm-portal/pkg/models"
+
+	"github.com/sirupsen/logrus"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+)
+
+const (
+	defaultReleaseName = "release-name"
+	defaultNamespace   = "default"
+)
+
+// ErrInvalidValues is returned when user-supplied values cannot be used to render a chart
+var ErrInvalidValues = errors.New("invalid values")
+
+// RenderChart renders the templates of a stored chart with the Helm engine,
+// using the chart defaults merged with the optional user-supplied values.
+// When rendering fails, each template is rendered on its own so errors are reported per template.
+func (s *ChartService) RenderChart(chartName string, version string, opts models.RenderOptions) (*models.RenderResult, error) {
+	chartData, err := s.GetChart(chartName, version)
+	if err != nil {
+		return nil, err
+	}
+
+	chrt, err := loader.LoadArchive(bytes.NewReader(chartData))
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to load chart: %w", err)
+	}
+
+	renderValues, err := buildRenderValues(chrt, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.RenderResult{
+		Name:    chrt.Metadata.Name,
+		Version: chrt.Metadata.Version,
+	}
+
+	rendered, renderErr := engine.Render(chrt, renderValues)
+	if renderErr == nil {
+		s.addRenderedTemplates(result, rendered)
+		return result, nil
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"name":    chartName,
+		"version": version,
+	}).WithError(renderErr).Debug("Chart render failed, rendering templates one by one")
+
+	for _, target := range renderableTemplates(chrt) {
+		isolated := filterTemplates(chrt, target)
+		rendered, err := engine.Render(isolated, renderValues)
+		if err != nil {
+			result.Templates = append(result.Templates, models.RenderedTemplate{
+				Path:  target,
+				Error: err.Error(),
+			})
+			continue
+		}
+		s.addRenderedTemplates(result, rendered)
+	}
+
+	sort.Slice(result.Templates, func(i, j int) bool {
+		return result.Templates[i].Path < result.Templates[j].Path
+	})
+
+	return result, nil
+}
+
+// buildRenderValues merges user values with the chart defaults the way `helm template` does
+func buildRenderValues(chrt *chart.Chart, opts models.RenderOptions) (chartutil.Values, error) {
+	userValues, err := chartutil.ReadValues([]byte(opts.Values))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidValues, err)
+	}
+
+	if err := chartutil.ProcessDependenciesWithMerge(chrt, userValues); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidValues, err)
+	}
+
+	releaseName := opts.ReleaseName
+	if releaseName == "" {
+		releaseName = defaultReleaseName
+	}
+	namespace := opts.Namespace
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+
+	renderValues, err := chartutil.ToRenderValues(chrt, userValues, chartutil.ReleaseOptions{
+		Name:      releaseName,
+		Namespace: namespace,
+		Revision:  1,
+		IsInstall: true,
+	}, chartutil.DefaultCapabilities)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidValues, err)
+	}
+	return renderValues, nil
+}
+
+// addRenderedTemplates stores rendered output, skipping empty manifests like Helm does
+func (s *ChartService) addRenderedTemplates(result *models.RenderResult, rendered map[string]string) {
+	paths := make([]string, 0, len(rendered))
+	for p := range rendered {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		content := rendered[p]
+		if path.Base(p) == "NOTES.txt" {
+			// Only the notes of the parent chart are shown, as with helm install
+			if !strings.Contains(p, "/charts/") {
+				result.Notes = content
+			}
+			continue
+		}
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+		result.Templates = append(result.Templates, models.RenderedTemplate{
+			Path:    p,
+			Content: content,
+		})
+	}
+}
+
+// renderableTemplates lists the full paths of the non-partial templates of a chart and its subcharts
+func renderableTemplates(c *chart.Chart) []string {
+	var paths []string
+	if !strings.EqualFold(c.Metadata.Type, "library") {
+		for _, t := range c.Templates {
+			if t == nil || strings.HasPrefix(path.Base(t.Name), "_") {
+				continue
+			}
+			paths = append(paths, path.Join(c.ChartFullPath(), t.Name))
+		}
+	}
+	for _, dep := range c.Dependencies() {
+		paths = append(paths, renderableTemplates(dep)...)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// filterTemplates returns a copy of the chart tree keeping every partial
+// and only the given non-partial template
+func filterTemplates(c *chart.Chart, keep string) *chart.Chart {
+	clone := *c
+	clone.Templates = nil
+	for _, t := range c.Templates {
+		if t == nil {
+			continue
+		}
+		if strings.HasPrefix(path.Base(t.Name), "_") || path.Join(c.ChartFullPath(), t.Name) == keep {
+			clone.Templates = append(clone.Templates, t)
+		}
+	}
+
+	deps := make([]*chart.Chart, 0, len(c.Dependencies()))
+	for _, dep := range c.Dependencies() {
+		deps = append(deps, filterTemplates(dep, keep))
+	}
+	clone.SetDependencies(deps...)
+	return &clone
+}
@@ -0,0 +1,707 @@
+// pkg/services/replication.go
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"helm-portal/config"
+	"helm-portal/pkg/interfaces"
+	"helm-portal/pkg/models"
+	"helm-portal/pkg/utils"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"helm.sh/helm/v3/pkg/chart/loader"
+)
+
+// ErrReplicationRuleNotFound is returned for a replication rule that is not configured
+var ErrReplicationRuleNotFound = errors.New("replication rule not found")
+
+// ErrReplicationDigestShared fails the replication of a deletion that could only be done by digest
+// while other local tags still point to that digest: deleting it would remove them from the target
+var ErrReplicationDigestShared = errors.New("digest is still tagged locally")
+
+const (
+	defaultReplicationAttempts = 8
+	defaultReplicationDelay    = 10 * time.Second
+	defaultReplicationMaxDelay = 10 * time.Minute
+	replicationTimeout         = time.Minute
+	// replicationHistory bounds the finished tasks kept per rule for the status page
+	replicationHistory = 100
+)
+
+type replicationRule struct {
+	config      config.ReplicationRule
+	client      *registryClient
+	lastSuccess time.Time
+}
+
+// matches tells whether a rule replicates a repository and tag (or chart version)
+func (r *replicationRule) matches(name, reference string) bool {
+	return matchesAny(r.config.Repositories, name) && matchesAny(r.config.Tags, reference)
+}
+
+// repository returns the name of a repository on the target
+func (r *replicationRule) repository(name string) string {
+	if r.config.Namespace == "" {
+		return name
+	}
+	return r.config.Namespace + "/" + name
+}
+
+// matchesAny tells whether a value matches one of the glob patterns, an empty list matching everything
+func matchesAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ReplicationService mirrors the charts and images pushed to the portal, and their deletions, to other
+// OCI registries. Each artifact event matching a rule becomes a task run by a background worker, retried
+// with an exponential backoff when the target fails. Tasks are kept in memory: after a restart,
+// ReplicateNow sends again every artifact of a rule.
+type ReplicationService struct {
+	pathManager  *utils.PathManager
+	chartService interfaces.ChartServiceInterface
+	imageService interfaces.ImageServiceInterface
+	log          *utils.Logger
+
+	rules         []*replicationRule
+	maxAttempts   int
+	retryDelay    time.Duration
+	maxRetryDelay time.Duration
+
+	mu    sync.Mutex
+	tasks []*models.ReplicationTask // oldest first
+	wake  chan struct{}
+}
+
+// NewReplicationService creates the replication of the rules configured under replication
+func NewReplicationService(cfg *config.Config, pathManager *utils.PathManager, chartService interfaces.ChartServiceInterface, imageService interfaces.ImageServiceInterface, log *utils.Logger) (*ReplicationService, error) {
+	replication := cfg.Replication
+	retryDelay, err := parseProxyDuration(replication.RetryDelay, defaultReplicationDelay)
+	if err != nil {
+		return nil, fmt.Errorf("❌ invalid replication retryDelay: %w", err)
+	}
+	maxRetryDelay, err := parseProxyDuration(replication.MaxRetryDelay, defaultReplicationMaxDelay)
+	if err != nil {
+		return nil, fmt.Errorf("❌ invalid replication maxRetryDelay: %w", err)
+	}
+	if maxRetryDelay < retryDelay {
+		maxRetryDelay = retryDelay
+	}
+	maxAttempts := replication.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultReplicationAttempts
+	}
+
+	s := &ReplicationService{
+		pathManager:   pathManager,
+		chartService:  chartService,
+		imageService:  imageService,
+		log:           log,
+		maxAttempts:   maxAttempts,
+		retryDelay:    retryDelay,
+		maxRetryDelay: maxRetryDelay,
+		wake:          make(chan struct{}, 1),
+	}
+
+	// Le timeout borne l'attente des en-têtes de réponse, pas l'envoi des blobs
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: replicationTimeout}).DialContext,
+		TLSHandshakeTimeout:   replicationTimeout,
+		ResponseHeaderTimeout: replicationTimeout,
+	}}
+	names := make(map[string]bool)
+	for _, ruleConfig := range replication.Rules {
+		if ruleConfig.Name == "" || strings.ContainsAny(ruleConfig.Name, "/\\") || names[ruleConfig.Name] {
+			return nil, fmt.Errorf("❌ replication rule name %q is invalid or duplicated", ruleConfig.Name)
+		}
+		names[ruleConfig.Name] = true
+
+		u, err := url.Parse(ruleConfig.Target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("❌ replication rule %s: %q is not an http(s) URL", ruleConfig.Name, ruleConfig.Target)
+		}
+		for _, pattern := range append(append([]string{}, ruleConfig.Repositories...), ruleConfig.Tags...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("❌ replication rule %s: invalid pattern %q", ruleConfig.Name, pattern)
+			}
+		}
+		ruleConfig.Namespace = strings.Trim(ruleConfig.Namespace, "/")
+
+		s.rules = append(s.rules, &replicationRule{
+			config: ruleConfig,
+			client: newRegistryClient(u.String(), ruleConfig.Username, ruleConfig.Password, client),
+		})
+	}
+
+	return s, nil
+}
+
+// Start launches the worker running the replication tasks
+func (s *ReplicationService) Start() {
+	if len(s.rules) == 0 {
+		return
+	}
+	go func() {
+		for {
+			task, wait := s.nextTask()
+			if task == nil {
+				timer := time.NewTimer(wait)
+				select {
+				case <-s.wake:
+				case <-timer.C:
+				}
+				timer.Stop()
+				continue
+			}
+			s.run(task)
+		}
+	}()
+}
+
+// OnArtifactEvent queues the replication of pushed and deleted chart versions and image tags
+func (s *ReplicationService) OnArtifactEvent(event models.ArtifactEvent) {
+	// Les manifests en cache viennent d'un autre registre ; les manifests de plateforme et les référents
+	// poussés par digest sont répliqués avec le tag qui les référence
+	if event.Action != models.EventPush && event.Action != models.EventDelete {
+		return
+	}
+	if strings.HasPrefix(event.Reference, "sha256:") {
+		return
+	}
+	for _, rule := range s.rules {
+		if !rule.matches(event.Name, event.Reference) {
+			continue
+		}
+		if event.Action == models.EventDelete && rule.config.SkipDeletions {
+			continue
+		}
+		s.enqueue(rule, event.Action, event.Type, event.Name, event.Reference)
+	}
+}
+
+// ReplicateNow queues the replication of every chart version and image tag matching a rule,
+// returning the number of queued tasks
+func (s *ReplicationService) ReplicateNow(ruleName string) (int, error) {
+	rule := s.rule(ruleName)
+	if rule == nil {
+		return 0, fmt.Errorf("%w: %s", ErrReplicationRuleNotFound, ruleName)
+	}
+
+	count := 0
+	charts, err := s.chartService.ListCharts()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list charts: %w", err)
+	}
+	for _, chart := range charts {
+		for _, version := range chart.Versions {
+			if rule.matches(chart.Name, version.Version) {
+				s.enqueue(rule, models.EventPush, models.ArtifactTypeHelmChart, chart.Name, version.Version)
+				count++
+			}
+		}
+	}
+	if s.imageService != nil {
+		images, err := s.imageService.ListImages()
+		if err != nil {
+			return count, fmt.Errorf("failed to list images: %w", err)
+		}
+		for _, image := range images {
+			for _, tag := range image.Tags {
+				if rule.matches(image.Name, tag.Tag) {
+					s.enqueue(rule, models.EventPush, models.ArtifactTypeDockerImage, image.Name, tag.Tag)
+					count++
+				}
+			}
+		}
+	}
+
+	s.log.WithFunc().WithFields(logrus.Fields{
+		"rule":  ruleName,
+		"tasks": count,
+	}).Info("Replication queued")
+	return count, nil
+}
+
+// Status returns the replication rules with their recent tasks
+func (s *ReplicationService) Status() []models.ReplicationRuleStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]models.ReplicationRuleStatus, 0, len(s.rules))
+	for _, rule := range s.rules {
+		status := models.ReplicationRuleStatus{
+			Name:         rule.config.Name,
+			Target:       rule.config.Target,
+			Namespace:    rule.config.Namespace,
+			Repositories: append([]string{}, rule.config.Repositories...),
+			Tags:         append([]string{}, rule.config.Tags...),
+			Deletions:    !rule.config.SkipDeletions,
+			Tasks:        []models.ReplicationTask{},
+		}
+		if !rule.lastSuccess.IsZero() {
+			lastSuccess := rule.lastSuccess
+			status.LastSuccess = &lastSuccess
+		}
+		for i := len(s.tasks) - 1; i >= 0; i-- {
+			task := s.tasks[i]
+			if task.Rule != rule.config.Name {
+				continue
+			}
+			switch task.Status {
+			case models.ReplicationSucceeded:
+				status.Succeeded++
+			case models.ReplicationFailed:
+				status.Failed++
+			default:
+				status.Pending++
+			}
+			status.Tasks = append(status.Tasks, *task)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+func (s *ReplicationService) rule(name string) *replicationRule {
+	for _, rule := range s.rules {
+		if rule.config.Name == name {
+			return rule
+		}
+	}
+	return nil
+}
+
+// enqueue adds a task, or replaces the action of a task of the same artifact still waiting to run:
+// only the last change of an artifact needs to reach the target
+func (s *ReplicationService) enqueue(rule *replicationRule, action string, artifactType models.ArtifactType, name, reference string) {
+	now := time.Now().UTC()
+	s.mu.Lock()
+	defer s.signal()
+	defer s.mu.Unlock()
+
+	for _, task := range s.tasks {
+		if task.Rule == rule.config.Name && task.Type == artifactType && task.Name == name && task.Reference == reference &&
+			(task.Status == models.ReplicationPending || task.Status == models.ReplicationRetrying) {
+			task.Action = action
+			task.Status = models.ReplicationPending
+			task.Attempts = 0
+			task.LastError = ""
+			task.NextAttempt = nil
+			task.UpdatedAt = now
+			return
+		}
+	}
+	s.tasks = append(s.tasks, &models.ReplicationTask{
+		ID:        uuid.New().String(),
+		Rule:      rule.config.Name,
+		Action:    action,
+		Type:      artifactType,
+		Name:      name,
+		Reference: reference,
+		Target:    rule.repository(name),
+		Status:    models.ReplicationPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+}
+
+// signal wakes the worker up without blocking
+func (s *ReplicationService) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// nextTask marks the oldest task due as running. Without one, it returns the wait until the next retry.
+func (s *ReplicationService) nextTask() (*models.ReplicationTask, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	wait := time.Hour
+	for _, task := range s.tasks {
+		switch task.Status {
+		case models.ReplicationPending:
+		case models.ReplicationRetrying:
+			if until := task.NextAttempt.Sub(now); until > 0 {
+				wait = min(wait, until)
+				continue
+			}
+		default:
+			continue
+		}
+		task.Status = models.ReplicationRunning
+		task.UpdatedAt = now.UTC()
+		return task, 0
+	}
+	return nil, wait
+}
+
+// run replicates a task and schedules its next attempt on failure.
+// The task being running, only the worker modifies it.
+func (s *ReplicationService) run(task *models.ReplicationTask) {
+	rule := s.rule(task.Rule)
+	var err error
+	if task.Action == models.EventDelete {
+		err = s.deleteArtifact(rule, task)
+	} else {
+		err = s.pushArtifact(rule, task)
+	}
+
+	logger := s.log.WithFunc().WithFields(logrus.Fields{
+		"rule":      task.Rule,
+		"action":    task.Action,
+		"name":      task.Name,
+		"reference": task.Reference,
+		"attempt":   task.Attempts + 1,
+	})
+
+	now := time.Now().UTC()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task.Attempts++
+	task.UpdatedAt = now
+	task.NextAttempt = nil
+	switch {
+	case err == nil:
+		task.Status = models.ReplicationSucceeded
+		task.LastError = ""
+		rule.lastSuccess = now
+		logger.Info("Artifact replicated")
+	case task.Attempts >= s.maxAttempts, errors.Is(err, ErrReplicationDigestShared):
+		task.Status = models.ReplicationFailed
+		task.LastError = err.Error()
+		logger.WithError(err).Error("❌ Replication failed, giving up")
+	default:
+		task.Status = models.ReplicationRetrying
+		task.LastError = err.Error()
+		next := now.Add(s.backoff(task.Attempts))
+		task.NextAttempt = &next
+		logger.WithError(err).Warn("⚠️ Replication failed, will retry")
+	}
+	s.prune()
+}
+
+// backoff returns the delay after a number of failed attempts, doubled at each failure
+func (s *ReplicationService) backoff(attempts int) time.Duration {
+	delay := s.retryDelay
+	for i := 1; i < attempts && delay < s.maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, s.maxRetryDelay)
+}
+
+// prune drops the oldest finished tasks of each rule beyond the history size
+func (s *ReplicationService) prune() {
+	finished := make(map[string]int)
+	kept := s.tasks[:0]
+	for i := len(s.tasks) - 1; i >= 0; i-- {
+		task := s.tasks[i]
+		if task.Done() {
+			finished[task.Rule]++
+			if finished[task.Rule] > replicationHistory {
+				s.tasks[i] = nil
+			}
+		}
+	}
+	for _, task := range s.tasks {
+		if task != nil {
+			kept = append(kept, task)
+		}
+	}
+	s.tasks = kept
+}
+
+// pushArtifact sends a chart version or image tag to the target. An artifact deleted since its push
+// has nothing left to replicate: its deletion is replicated by its own task.
+func (s *ReplicationService) pushArtifact(rule *replicationRule, task *models.ReplicationTask) error {
+	if task.Type == models.ArtifactTypeHelmChart {
+		return s.pushChart(rule, task.Name, task.Reference)
+	}
+	data, _, mediaType, err := readImageManifest(s.imageService, task.Name, task.Reference)
+	if errors.Is(err, ErrImageNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.pushImage(rule, task.Name, task.Reference, data, mediaType)
+}
+
+// pushImage sends the platform manifests of an index, then the manifest of the tag
+func (s *ReplicationService) pushImage(rule *replicationRule, name, reference string, data []byte, mediaType string) error {
+	repository := rule.repository(name)
+	if models.IsIndexMediaType(mediaType) {
+		var index models.OCIIndex
+		if err := json.Unmarshal(data, &index); err != nil {
+			return fmt.Errorf("invalid index: %w", err)
+		}
+		for _, platform := range index.Manifests {
+			child, _, childType, err := readImageManifest(s.imageService, name, platform.Digest)
+			if err != nil {
+				return err
+			}
+			if err := s.pushManifest(rule, repository, platform.Digest, child, childType, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return s.pushManifest(rule, repository, reference, data, mediaType, nil)
+}
+
+// pushChart sends a chart version. A chart pushed with helm push is sent with its own manifest, keeping
+// its digest and signatures valid; the manifest of an uploaded archive is built like helm push would.
+func (s *ReplicationService) pushChart(rule *replicationRule, name, version string) error {
+	repository := rule.repository(name)
+	// Helm remplace le "+" des versions SemVer par "_" dans les tags OCI
+	reference := strings.ReplaceAll(version, "+", "_")
+
+	manifestPath := s.pathManager.GetManifestPath(name, reference)
+	if data, err := os.ReadFile(manifestPath); err == nil {
+		mediaType, _ := os.ReadFile(utils.MediaTypePath(manifestPath))
+		return s.pushManifest(rule, repository, reference, data, models.ManifestMediaType(data, string(mediaType)), nil)
+	}
+
+	archive, err := s.chartService.GetChart(name, version)
+	if err != nil {
+		if !s.chartService.ChartExists(name, version) {
+			return nil
+		}
+		return err
+	}
+	chart, err := loader.LoadArchive(bytes.NewReader(archive))
+	if err != nil {
+		return fmt.Errorf("invalid chart archive: %w", err)
+	}
+	configData, err := json.Marshal(chart.Metadata)
+	if err != nil {
+		return err
+	}
+	config := contentDescriptor(models.MediaTypeHelmConfig, configData)
+	layer := contentDescriptor(models.MediaTypeHelmChart, archive)
+	manifest, err := json.Marshal(models.OCIManifest{
+		SchemaVersion: 2,
+		MediaType:     models.MediaTypeOCIManifest,
+		Config:        config,
+		Layers:        []models.OCIDescriptor{layer},
+	})
+	if err != nil {
+		return err
+	}
+	return s.pushManifest(rule, repository, reference, manifest, models.MediaTypeOCIManifest, map[string][]byte{
+		config.Digest: configData,
+		layer.Digest:  archive,
+	})
+}
+
+func contentDescriptor(mediaType string, data []byte) models.OCIDescriptor {
+	return models.OCIDescriptor{
+		MediaType: mediaType,
+		Digest:    fmt.Sprintf("sha256:%x", sha256.Sum256(data)),
+		Size:      int64(len(data)),
+	}
+}
+
+// pushManifest sends the blobs of a manifest missing on the target, then the manifest. Blobs are read
+// from the storage, or from contents for a generated manifest.
+func (s *ReplicationService) pushManifest(rule *replicationRule, repository, reference string, data []byte, mediaType string, contents map[string][]byte) error {
+	if !models.IsIndexMediaType(mediaType) {
+		var manifest models.OCIManifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return fmt.Errorf("invalid manifest %s: %w", reference, err)
+		}
+		for _, blob := range append([]models.OCIDescriptor{manifest.Config}, manifest.Layers...) {
+			if !digestPattern.MatchString(blob.Digest) {
+				return fmt.Errorf("invalid blob digest %q in manifest %s", blob.Digest, reference)
+			}
+			body := fileBody(s.pathManager.GetBlobPath(blob.Digest))
+			if content, ok := contents[blob.Digest]; ok {
+				body = bytesBody(content)
+			}
+			if err := s.pushBlob(rule, repository, blob.Digest, body); err != nil {
+				return err
+			}
+		}
+	}
+
+	resp, err := s.request(rule, http.MethodPut, fmt.Sprintf("/v2/%s/manifests/%s", repository, reference), repository,
+		http.Header{"Content-Type": []string{mediaType}}, bytesBody(data))
+	if err != nil {
+		return err
+	}
+	return expectStatus(resp, "manifest "+reference, http.StatusOK, http.StatusCreated)
+}
+
+// pushBlob uploads a blob in a single request, unless the target already has it
+func (s *ReplicationService) pushBlob(rule *replicationRule, repository, digest string, body requestBody) error {
+	resp, err := s.request(rule, http.MethodHead, fmt.Sprintf("/v2/%s/blobs/%s", repository, digest), repository, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	resp, err = s.request(rule, http.MethodPost, fmt.Sprintf("/v2/%s/blobs/uploads/", repository), repository, nil, nil)
+	if err != nil {
+		return err
+	}
+	location := resp.Header.Get("Location")
+	if err := expectStatus(resp, "upload "+digest, http.StatusAccepted); err != nil {
+		return err
+	}
+	uploadURL, err := url.Parse(location)
+	if err != nil || location == "" {
+		return fmt.Errorf("upload %s: invalid location %q", digest, location)
+	}
+	query := uploadURL.Query()
+	query.Set("digest", digest)
+	uploadURL.RawQuery = query.Encode()
+
+	resp, err = s.request(rule, http.MethodPut, uploadURL.String(), repository,
+		http.Header{"Content-Type": []string{"application/octet-stream"}}, body)
+	if err != nil {
+		return err
+	}
+	return expectStatus(resp, "upload "+digest, http.StatusCreated)
+}
+
+// deleteArtifact deletes a chart version or image tag on the target. Registries refusing to delete by
+// tag get the digest the tag points to, unless other local tags still point to it.
+func (s *ReplicationService) deleteArtifact(rule *replicationRule, task *models.ReplicationTask) error {
+	repository := rule.repository(task.Name)
+	reference := task.Reference
+	if task.Type == models.ArtifactTypeHelmChart {
+		reference = strings.ReplaceAll(reference, "+", "_")
+	}
+	manifestURL := fmt.Sprintf("/v2/%s/manifests/", repository)
+
+	resp, err := s.request(rule, http.MethodDelete, manifestURL+reference, repository, nil, nil)
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusBadRequest, http.StatusMethodNotAllowed, http.StatusUnsupportedMediaType:
+		resp.Body.Close()
+	default:
+		return expectStatus(resp, "delete "+reference, http.StatusOK, http.StatusAccepted, http.StatusNoContent, http.StatusNotFound)
+	}
+
+	resp, err = s.request(rule, http.MethodHead, manifestURL+reference, repository,
+		http.Header{"Accept": []string{proxyManifestAccept}}, nil)
+	if err != nil {
+		return err
+	}
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil
+	}
+	if err := expectStatus(resp, "resolve "+reference, http.StatusOK); err != nil {
+		return err
+	}
+	if !digestPattern.MatchString(digest) {
+		return fmt.Errorf("resolve %s: invalid digest %q", reference, digest)
+	}
+	if tags := s.localTags(task.Type, task.Name, digest); len(tags) > 0 {
+		return fmt.Errorf("%w: the target refuses to delete %s by tag and deleting %s would also remove %s",
+			ErrReplicationDigestShared, reference, digest, strings.Join(tags, ", "))
+	}
+
+	resp, err = s.request(rule, http.MethodDelete, manifestURL+digest, repository, nil, nil)
+	if err != nil {
+		return err
+	}
+	return expectStatus(resp, "delete "+digest, http.StatusOK, http.StatusAccepted, http.StatusNoContent, http.StatusNotFound)
+}
+
+// localTags returns the local tags of a chart or image resolving to a digest
+func (s *ReplicationService) localTags(artifactType models.ArtifactType, name, digest string) []string {
+	var tags []string
+	if artifactType == models.ArtifactTypeHelmChart {
+		versions, _ := s.chartService.GetChartVersions(name)
+		for _, version := range versions {
+			reference := strings.ReplaceAll(version.Version, "+", "_")
+			data, err := os.ReadFile(s.pathManager.GetManifestPath(name, reference))
+			if err == nil && fmt.Sprintf("sha256:%x", sha256.Sum256(data)) == digest {
+				tags = append(tags, reference)
+			}
+		}
+		return tags
+	}
+	imageTags, _ := s.imageService.ListTags(name)
+	for _, tag := range imageTags {
+		if _, tagDigest, _, err := readImageManifest(s.imageService, name, tag); err == nil && tagDigest == digest {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// request sends a request to the target of a rule with push access to a repository
+func (s *ReplicationService) request(rule *replicationRule, method, target, repository string, header http.Header, body requestBody) (*http.Response, error) {
+	resp, err := rule.client.do(method, target, "repository:"+repository+":pull,push", header, body)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, target, err)
+	}
+	return resp, nil
+}
+
+// expectStatus closes a response, turning an unexpected status into an error with the start of its body
+func expectStatus(resp *http.Response, what string, statuses ...int) error {
+	defer resp.Body.Close()
+	for _, status := range statuses {
+		if resp.StatusCode == status {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+			return nil
+		}
+	}
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("%s: %s %s", what, resp.Status, strings.TrimSpace(string(detail)))
+}
+
+func bytesBody(data []byte) requestBody {
+	return func() (io.ReadCloser, int64, error) {
+		return io.NopCloser(bytes.NewReader(data)), int64(len(data)), nil
+	}
+}
+
+func fileBody(filePath string) requestBody {
+	return func() (io.ReadCloser, int64, error) {
+		file, err := os.Open(filePath)
+		if err != nil {
+			return nil, 0, err
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, 0, err
+		}
+		return file, info.Size(), nil
+	}
+}
@@ -0,0 +1,110 @@
+// pkg/services/repository.go
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"helm-portal/config"
+	utils "helm-portal/pkg/utils"
+)
+
+var (
+	// ErrRepositoryNotFound is returned when no repository has the requested name
+	ErrRepositoryNotFound = errors.New("repository not found")
+	// ErrInvalidRepository is returned for an invalid repository configuration
+	ErrInvalidRepository = errors.New("invalid repository")
+)
+
+// repositoryNamePattern follows the OCI rules for a path component
+var repositoryNamePattern = regexp.MustCompile(`^[a-z0-9]+(?:[._-][a-z0-9]+)*$`)
+
+// reservedRepositoryNames would shadow the routes of the default repository
+var reservedRepositoryNames = map[string]bool{"_catalog": true}
+
+// Repository groups the services of an isolated Helm repository.
+// Its charts, index, OCI manifests, blobs and images live under their own storage prefix.
+type Repository struct {
+	Config      config.RepositoryConfig
+	PathManager *utils.PathManager
+	Charts      *ChartService
+	Index       *IndexService
+	Images      *ImageService
+	Stats       *StatsService
+	Signatures  *SignatureService
+}
+
+// Name returns the name of the repository
+func (r *Repository) Name() string {
+	return r.Config.Name
+}
+
+// URLPrefix returns the HTTP prefix under which the repository is served
+func (r *Repository) URLPrefix() string {
+	return "/r/" + r.Config.Name
+}
+
+// RepositoryRegistry holds the isolated repositories declared in the configuration
+type RepositoryRegistry struct {
+	repositories map[string]*Repository
+}
+
+// NewRepositoryRegistry creates the services of every configured repository
+func NewRepositoryRegistry(cfg *config.Config, log *utils.Logger) (*RepositoryRegistry, error) {
+	registry := &RepositoryRegistry{repositories: make(map[string]*Repository)}
+	root := utils.NewPathManager(cfg.Storage.Path, log)
+
+	for _, repoCfg := range cfg.Repositories {
+		if !repositoryNamePattern.MatchString(repoCfg.Name) || reservedRepositoryNames[repoCfg.Name] {
+			return nil, fmt.Errorf("%w: name %q must be lowercase alphanumerics separated by '.', '_' or '-'", ErrInvalidRepository, repoCfg.Name)
+		}
+		if _, exists := registry.repositories[repoCfg.Name]; exists {
+			return nil, fmt.Errorf("%w: %q is declared twice", ErrInvalidRepository, repoCfg.Name)
+		}
+
+		pm := root.ForRepository(repoCfg.Name)
+		repo := &Repository{Config: repoCfg, PathManager: pm}
+
+		// Même construction que le dépôt par défaut : l'index a besoin du service de charts et inversement
+		tmpChartService := NewRepositoryChartService(cfg, log, pm, nil)
+		repo.Index = NewRepositoryIndexService(cfg, log, pm, tmpChartService, repo.URLPrefix())
+		repo.Charts = NewRepositoryChartService(cfg, log, pm, repo.Index).WithNamespace(repoCfg.Name)
+		repo.Images = NewRepositoryImageService(cfg, log, pm).WithNamespace(repoCfg.Name)
+		repo.Stats = NewStatsService(pm, repo.Charts, repo.Images, log)
+		repo.Charts.Subscribe(repo.Stats)
+		repo.Images.Subscribe(repo.Stats)
+		repo.Signatures = NewSignatureService(cfg, pm, repo.Charts, repo.Images, log).WithNamespace(repoCfg.Name)
+		repo.Charts.Subscribe(repo.Signatures)
+		repo.Images.Subscribe(repo.Signatures)
+
+		if err := repo.Index.EnsureIndexExists(); err != nil {
+			return nil, fmt.Errorf("failed to create index of repository %q: %w", repoCfg.Name, err)
+		}
+		registry.repositories[repoCfg.Name] = repo
+	}
+
+	return registry, nil
+}
+
+// Get returns a repository by name
+func (r *RepositoryRegistry) Get(name string) (*Repository, error) {
+	repo, ok := r.repositories[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrRepositoryNotFound, name)
+	}
+	return repo, nil
+}
+
+// List returns the repositories sorted by name
+func (r *RepositoryRegistry) List() []*Repository {
+	repos := make([]*Repository, 0, len(r.repositories))
+	for _, repo := range r.repositories {
+		repos = append(repos, repo)
+	}
+	sort.Slice(repos, func(i, j int) bool {
+		return repos[i].Name() < repos[j].Name()
+	})
+	return repos
+}
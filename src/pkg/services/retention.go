@@ -0,0 +1,508 @@
+// pkg/services/retention.go
+package service
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"helm-portal/config"
+	"helm-portal/pkg/interfaces"
+	"helm-portal/pkg/models"
+	"helm-portal/pkg/utils"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// retentionRunHistory is the number of passes kept in memory for GET /api/retention
+const retentionRunHistory = 20
+
+// RetentionActorSchedule is the actor of the scheduled passes
+const RetentionActorSchedule = "schedule"
+
+type retentionRule struct {
+	config   config.RetentionRule
+	keepTags []*regexp.Regexp
+}
+
+// retentionScope is a storage whose charts and images are examined by a pass: the default repository,
+// or an isolated repository whose names are matched and reported as <namespace>/<name>
+type retentionScope struct {
+	namespace    string
+	pathManager  *utils.PathManager
+	chartService interfaces.ChartServiceInterface
+	imageService interfaces.ImageServiceInterface
+	stats        *StatsService
+}
+
+// qualify returns the name of an artifact of the scope as matched by the rules
+func (s *retentionScope) qualify(name string) string {
+	if s.namespace == "" {
+		return name
+	}
+	return s.namespace + "/" + name
+}
+
+// retentionArtifact is a chart version or image tag examined by a pass
+type retentionArtifact struct {
+	scope        *retentionScope
+	artifactType models.ArtifactType
+	name         string // name in its scope, without namespace
+	reference    string
+	digest       string // manifest the tag points to, "" for a chart without OCI manifest
+	pushedAt     time.Time
+}
+
+// RetentionService deletes old chart versions and image tags according to retention rules, on a schedule
+// or on demand. A dry run returns the deletions a pass would make; applied deletions are appended to an
+// audit trail, <storage>/retention/audit.jsonl.
+type RetentionService struct {
+	scopes []*retentionScope
+	log    *utils.Logger
+
+	rules    []*retentionRule
+	schedule time.Duration
+	dryRun   bool
+	audit    string
+
+	run  sync.Mutex // one pass at a time
+	mu   sync.Mutex
+	runs []models.RetentionRun // most recent first
+	next time.Time
+}
+
+// NewRetentionService creates the retention of the rules configured under policies.retention.
+// stats may be nil, keepPulledWithinDays then keeps nothing.
+func NewRetentionService(cfg *config.Config, pathManager *utils.PathManager, chartService interfaces.ChartServiceInterface, imageService interfaces.ImageServiceInterface, stats *StatsService, log *utils.Logger) (*RetentionService, error) {
+	retention := cfg.Policies.Retention
+	s := &RetentionService{
+		scopes: []*retentionScope{{
+			pathManager:  pathManager,
+			chartService: chartService,
+			imageService: imageService,
+			stats:        stats,
+		}},
+		log:    log,
+		dryRun: retention.DryRun,
+		audit:  filepath.Join(pathManager.GetBasePath(), "retention", "audit.jsonl"),
+	}
+
+	if retention.Schedule != "" {
+		schedule, err := time.ParseDuration(retention.Schedule)
+		if err != nil || schedule <= 0 {
+			return nil, fmt.Errorf("❌ invalid retention schedule %q", retention.Schedule)
+		}
+		s.schedule = schedule
+	}
+
+	for _, ruleConfig := range retention.Rules {
+		if _, err := path.Match(ruleConfig.Pattern, ""); err != nil || ruleConfig.Pattern == "" {
+			return nil, fmt.Errorf("❌ retention rule: invalid pattern %q", ruleConfig.Pattern)
+		}
+		if ruleConfig.KeepLast < 0 || ruleConfig.OlderThanDays < 0 || ruleConfig.KeepPulledWithinDays < 0 {
+			return nil, fmt.Errorf("❌ retention rule %s: negative values are not allowed", ruleConfig.Pattern)
+		}
+		// Sans l'un de ces deux critères, la règle supprimerait tous les tags non protégés par une expression
+		if ruleConfig.KeepLast == 0 && ruleConfig.OlderThanDays == 0 {
+			return nil, fmt.Errorf("❌ retention rule %s: keepLast or olderThanDays is required", ruleConfig.Pattern)
+		}
+		rule := &retentionRule{config: ruleConfig}
+		for _, expr := range ruleConfig.KeepTags {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("❌ retention rule %s: invalid keepTags expression %q: %w", ruleConfig.Pattern, expr, err)
+			}
+			rule.keepTags = append(rule.keepTags, re)
+		}
+		s.rules = append(s.rules, rule)
+	}
+
+	return s, nil
+}
+
+// WithRepositories applies the rules to the isolated repositories too, matched as <repository>/<name>
+func (s *RetentionService) WithRepositories(registry *RepositoryRegistry) *RetentionService {
+	for _, repo := range registry.List() {
+		s.scopes = append(s.scopes, &retentionScope{
+			namespace:    repo.Name(),
+			pathManager:  repo.PathManager,
+			chartService: repo.Charts,
+			imageService: repo.Images,
+			stats:        repo.Stats,
+		})
+	}
+	return s
+}
+
+// Rules returns the configured retention rules
+func (s *RetentionService) Rules() []config.RetentionRule {
+	rules := make([]config.RetentionRule, 0, len(s.rules))
+	for _, rule := range s.rules {
+		rules = append(rules, rule.config)
+	}
+	return rules
+}
+
+// Schedule returns the interval between scheduled passes (0 when not scheduled), whether they are
+// dry runs, and the time of the next one
+func (s *RetentionService) Schedule() (time.Duration, bool, *time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.next.IsZero() {
+		return s.schedule, s.dryRun, nil
+	}
+	next := s.next
+	return s.schedule, s.dryRun, &next
+}
+
+// Start runs the retention passes on schedule in the background
+func (s *RetentionService) Start() {
+	if s.schedule == 0 || len(s.rules) == 0 {
+		return
+	}
+	s.setNext(time.Now().Add(s.schedule))
+	go func() {
+		ticker := time.NewTicker(s.schedule)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.setNext(time.Now().Add(s.schedule))
+			if _, err := s.Run(RetentionActorSchedule, s.dryRun); err != nil {
+				s.log.WithError(err).Error("❌ Scheduled retention pass failed")
+			}
+		}
+	}()
+}
+
+func (s *RetentionService) setNext(next time.Time) {
+	s.mu.Lock()
+	s.next = next.UTC()
+	s.mu.Unlock()
+}
+
+// Runs returns the last passes, most recent first
+func (s *RetentionService) Runs() []models.RetentionRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.RetentionRun{}, s.runs...)
+}
+
+// Run applies the retention rules, or only computes their deletions for a dry run
+func (s *RetentionService) Run(actor string, dryRun bool) (*models.RetentionRun, error) {
+	s.run.Lock()
+	defer s.run.Unlock()
+
+	run := &models.RetentionRun{
+		ID:        uuid.New().String(),
+		Actor:     actor,
+		DryRun:    dryRun,
+		StartedAt: time.Now().UTC(),
+		Deletions: []models.RetentionDeletion{},
+	}
+
+	repositories, err := s.listArtifacts()
+	if err != nil {
+		return nil, err
+	}
+	// Ordre stable des dépôts pour l'aperçu et le journal
+	names := make([]string, 0, len(repositories))
+	for key := range repositories {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+
+	now := time.Now()
+	for _, key := range names {
+		artifacts := repositories[key]
+		rule := s.ruleFor(artifacts[0].scope.qualify(artifacts[0].name))
+		if rule == nil {
+			continue
+		}
+		deletions := s.evaluate(rule, artifacts, now)
+		run.Examined += len(artifacts)
+		run.Kept += len(artifacts) - len(deletions)
+		for _, deletion := range deletions {
+			if !dryRun {
+				if err := s.delete(artifacts[0], deletion.Reference); err != nil {
+					deletion.Error = err.Error()
+					run.Kept++
+				}
+			}
+			run.Deletions = append(run.Deletions, deletion)
+		}
+	}
+	run.FinishedAt = time.Now().UTC()
+
+	if !dryRun {
+		if err := s.appendAudit(run); err != nil {
+			s.log.WithError(err).Error("❌ Failed to write the retention audit trail")
+		}
+	}
+
+	s.mu.Lock()
+	s.runs = append([]models.RetentionRun{*run}, s.runs...)
+	if len(s.runs) > retentionRunHistory {
+		s.runs = s.runs[:retentionRunHistory]
+	}
+	s.mu.Unlock()
+
+	s.log.WithFunc().WithFields(logrus.Fields{
+		"run":       run.ID,
+		"actor":     actor,
+		"dryRun":    dryRun,
+		"examined":  run.Examined,
+		"deletions": len(run.Deletions),
+	}).Info("Retention pass completed")
+	return run, nil
+}
+
+// ruleFor returns the rule of a repository. The first matching rule wins.
+func (s *RetentionService) ruleFor(name string) *retentionRule {
+	for _, rule := range s.rules {
+		if matched, _ := path.Match(rule.config.Pattern, name); matched {
+			return rule
+		}
+	}
+	return nil
+}
+
+// listArtifacts groups the chart versions and image tags of every scope by type and repository
+func (s *RetentionService) listArtifacts() (map[string][]retentionArtifact, error) {
+	repositories := make(map[string][]retentionArtifact)
+	for _, scope := range s.scopes {
+		if err := scope.listArtifacts(repositories); err != nil {
+			return nil, err
+		}
+	}
+	return repositories, nil
+}
+
+// listArtifacts adds the chart versions and image tags of the scope to repositories, keyed by type and qualified name
+func (s *retentionScope) listArtifacts(repositories map[string][]retentionArtifact) error {
+	charts, err := s.chartService.ListCharts()
+	if err != nil {
+		return fmt.Errorf("failed to list charts of %s: %w", s.describe(), err)
+	}
+	for _, chart := range charts {
+		for _, version := range chart.Versions {
+			// La date de push d'une version est celle de son archive
+			info, err := os.Stat(s.pathManager.GetChartPath(chart.Name, version.Version))
+			if err != nil {
+				continue
+			}
+			// Helm remplace le "+" des versions SemVer par "_" dans les tags OCI
+			var digest string
+			if manifest, err := os.ReadFile(s.pathManager.GetManifestPath(chart.Name, strings.ReplaceAll(version.Version, "+", "_"))); err == nil {
+				digest = fmt.Sprintf("sha256:%x", sha256.Sum256(manifest))
+			}
+			key := string(models.ArtifactTypeHelmChart) + "/" + s.qualify(chart.Name)
+			repositories[key] = append(repositories[key], retentionArtifact{
+				scope:        s,
+				artifactType: models.ArtifactTypeHelmChart,
+				name:         chart.Name,
+				reference:    version.Version,
+				digest:       digest,
+				pushedAt:     info.ModTime(),
+			})
+		}
+	}
+
+	if s.imageService != nil {
+		images, err := s.imageService.ListImages()
+		if err != nil {
+			return fmt.Errorf("failed to list images of %s: %w", s.describe(), err)
+		}
+		for _, image := range images {
+			for _, tag := range image.Tags {
+				// Les signatures et attestations suivent le tag qu'elles décrivent
+				if referrerTagPattern.MatchString(tag.Tag) {
+					continue
+				}
+				key := string(models.ArtifactTypeDockerImage) + "/" + s.qualify(image.Name)
+				repositories[key] = append(repositories[key], retentionArtifact{
+					scope:        s,
+					artifactType: models.ArtifactTypeDockerImage,
+					name:         image.Name,
+					reference:    tag.Tag,
+					digest:       tag.Digest,
+					pushedAt:     tag.Created,
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// describe names the scope in error messages
+func (s *retentionScope) describe() string {
+	if s.namespace == "" {
+		return "the default repository"
+	}
+	return "repository " + s.namespace
+}
+
+// evaluate returns the artifacts of a repository that a rule deletes
+func (s *RetentionService) evaluate(rule *retentionRule, artifacts []retentionArtifact, now time.Time) []models.RetentionDeletion {
+	sort.SliceStable(artifacts, func(i, j int) bool {
+		return artifacts[i].pushedAt.After(artifacts[j].pushedAt)
+	})
+
+	var deletions []models.RetentionDeletion
+	for rank, artifact := range artifacts {
+		if rule.config.KeepLast > 0 && rank < rule.config.KeepLast {
+			continue
+		}
+		if rule.keeps(artifact.reference) {
+			continue
+		}
+		age := now.Sub(artifact.pushedAt)
+		if rule.config.OlderThanDays > 0 && age < days(rule.config.OlderThanDays) {
+			continue
+		}
+		lastPulled := s.lastPulled(artifact)
+		if rule.config.KeepPulledWithinDays > 0 && lastPulled != nil && now.Sub(*lastPulled) < days(rule.config.KeepPulledWithinDays) {
+			continue
+		}
+
+		reasons := []string{fmt.Sprintf("pushed %d days ago", int(age/(24*time.Hour)))}
+		if rule.config.KeepLast > 0 {
+			reasons = append(reasons, fmt.Sprintf("not among the %d most recent", rule.config.KeepLast))
+		}
+		if rule.config.KeepPulledWithinDays > 0 {
+			if lastPulled == nil {
+				reasons = append(reasons, "never pulled")
+			} else {
+				reasons = append(reasons, fmt.Sprintf("last pulled %d days ago", int(now.Sub(*lastPulled)/(24*time.Hour))))
+			}
+		}
+		deletions = append(deletions, models.RetentionDeletion{
+			Type:       artifact.artifactType,
+			Name:       artifact.scope.qualify(artifact.name),
+			Reference:  artifact.reference,
+			Rule:       rule.config.Pattern,
+			Reason:     strings.Join(reasons, ", "),
+			PushedAt:   artifact.pushedAt.UTC(),
+			LastPulled: lastPulled,
+		})
+	}
+	return deletions
+}
+
+// lastPulled returns the last pull of an artifact, by its tag or by the digest the tag points to
+// (docker pull <name>@<digest>, Kubernetes pinning images by digest)
+func (s *RetentionService) lastPulled(artifact retentionArtifact) *time.Time {
+	stats := artifact.scope.stats
+	if stats == nil {
+		return nil
+	}
+	last := stats.Get(artifact.artifactType, artifact.name, artifact.reference).LastPulled
+	if artifact.digest == "" {
+		return last
+	}
+	if byDigest := stats.Get(artifact.artifactType, artifact.name, artifact.digest).LastPulled; byDigest != nil && (last == nil || byDigest.After(*last)) {
+		last = byDigest
+	}
+	return last
+}
+
+// keeps tells whether a tag matches one of the keepTags expressions of a rule
+func (r *retentionRule) keeps(reference string) bool {
+	for _, re := range r.keepTags {
+		if re.MatchString(reference) {
+			return true
+		}
+	}
+	return false
+}
+
+func days(n int) time.Duration {
+	return time.Duration(n) * 24 * time.Hour
+}
+
+// delete removes a chart version or image tag of the repository of artifact, from its own storage;
+// its subscribers (statistics, search, replication) are notified
+func (s *RetentionService) delete(artifact retentionArtifact, reference string) error {
+	if artifact.artifactType == models.ArtifactTypeHelmChart {
+		return artifact.scope.chartService.DeleteChart(artifact.name, reference)
+	}
+	return artifact.scope.imageService.DeleteImage(artifact.name, reference)
+}
+
+// appendAudit appends the deletions of a pass to the audit trail
+func (s *RetentionService) appendAudit(run *models.RetentionRun) error {
+	if len(run.Deletions) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.audit), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.audit, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	for _, deletion := range run.Deletions {
+		entry := models.RetentionAuditEntry{
+			At:                run.FinishedAt,
+			RunID:             run.ID,
+			Actor:             run.Actor,
+			RetentionDeletion: deletion,
+		}
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Audit returns the entries of the audit trail, most recent first, optionally filtered on a repository
+// name (glob) and limited in number (0: all)
+func (s *RetentionService) Audit(name string, limit int) ([]models.RetentionAuditEntry, error) {
+	entries := []models.RetentionAuditEntry{}
+	file, err := os.Open(s.audit)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var entry models.RetentionAuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if name != "" {
+			if matched, _ := path.Match(name, entry.Name); !matched {
+				continue
+			}
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the retention audit trail: %w", err)
+	}
+
+	// Le journal est écrit dans l'ordre chronologique
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
@@ -0,0 +1,464 @@
+// pkg/services/rpmdb.go
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// The rpm database comes in three formats: SQLite (rpmdb.sqlite, RHEL 9, Fedora),
+// NDB (Packages.db, SUSE) and Berkeley DB hash (Packages, RHEL 7/8). Each format
+// stores one header blob per installed package; the readers below only extract these blobs.
+
+var errUnknownRPMDB = errors.New("unknown rpm database format")
+
+// rpmPackage is the subset of an rpm header used to match advisories
+type rpmPackage struct {
+	Name, Version, Release, Arch string
+	Epoch                        int
+}
+
+// rpm header tags and types (rpmtag.h)
+const (
+	rpmTagName    = 1000
+	rpmTagVersion = 1001
+	rpmTagRelease = 1002
+	rpmTagEpoch   = 1003
+	rpmTagArch    = 1022
+
+	rpmTypeInt32  = 4
+	rpmTypeString = 6
+)
+
+// parseRPMDB returns the packages of an rpm database, whatever its format
+func parseRPMDB(data []byte) ([]rpmPackage, error) {
+	var blobs [][]byte
+	var err error
+	switch {
+	case bytes.HasPrefix(data, []byte("SQLite format 3\x00")):
+		blobs, err = sqliteRPMBlobs(data)
+	case bytes.HasPrefix(data, []byte("RpmP")):
+		blobs, err = ndbRPMBlobs(data)
+	case len(data) >= 16 && (binary.LittleEndian.Uint32(data[12:]) == bdbHashMagic || binary.BigEndian.Uint32(data[12:]) == bdbHashMagic):
+		blobs, err = bdbRPMBlobs(data)
+	default:
+		return nil, errUnknownRPMDB
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	packages := make([]rpmPackage, 0, len(blobs))
+	for _, blob := range blobs {
+		pkg, err := parseRPMHeader(blob)
+		if err != nil || pkg.Name == "" || pkg.Name == "gpg-pubkey" {
+			continue
+		}
+		packages = append(packages, pkg)
+	}
+	return packages, nil
+}
+
+// parseRPMHeader reads a header blob: entry count, data size, index entries then data
+func parseRPMHeader(blob []byte) (rpmPackage, error) {
+	var pkg rpmPackage
+	if len(blob) < 8 {
+		return pkg, fmt.Errorf("rpm header too short")
+	}
+	count := binary.BigEndian.Uint32(blob[0:])
+	size := binary.BigEndian.Uint32(blob[4:])
+	dataStart := 8 + uint64(count)*16
+	if dataStart+uint64(size) > uint64(len(blob)) {
+		return pkg, fmt.Errorf("rpm header truncated")
+	}
+	store := blob[dataStart : dataStart+uint64(size)]
+
+	for i := uint64(0); i < uint64(count); i++ {
+		entry := blob[8+i*16:]
+		tag := binary.BigEndian.Uint32(entry[0:])
+		kind := binary.BigEndian.Uint32(entry[4:])
+		offset := binary.BigEndian.Uint32(entry[8:])
+		if uint64(offset) >= uint64(len(store)) {
+			continue
+		}
+		value := store[offset:]
+
+		switch {
+		case kind == rpmTypeString:
+			text := value
+			if end := bytes.IndexByte(text, 0); end >= 0 {
+				text = text[:end]
+			}
+			switch tag {
+			case rpmTagName:
+				pkg.Name = string(text)
+			case rpmTagVersion:
+				pkg.Version = string(text)
+			case rpmTagRelease:
+				pkg.Release = string(text)
+			case rpmTagArch:
+				pkg.Arch = string(text)
+			}
+		case kind == rpmTypeInt32 && tag == rpmTagEpoch && len(value) >= 4:
+			pkg.Epoch = int(binary.BigEndian.Uint32(value))
+		}
+	}
+	return pkg, nil
+}
+
+// EVR returns [epoch:]version-release, the version format of the rpm advisories
+func (p rpmPackage) EVR() string {
+	evr := p.Version + "-" + p.Release
+	if p.Epoch > 0 {
+		evr = fmt.Sprintf("%d:%s", p.Epoch, evr)
+	}
+	return evr
+}
+
+// ndbRPMBlobs reads an NDB database: a slot table pointing to blobs of 16-byte blocks
+func ndbRPMBlobs(data []byte) ([][]byte, error) {
+	const (
+		slotMagic      = 0x746f6c53 // "Slot"
+		blobMagic      = 0x53626c42 // "BlbS"
+		slotsPerPage   = 4096 / 16
+		blockSize      = 16
+		blobHeaderSize = 16
+	)
+	le := binary.LittleEndian
+	if len(data) < 32 {
+		return nil, fmt.Errorf("ndb database too short")
+	}
+	slotPages := le.Uint32(data[12:])
+
+	var blobs [][]byte
+	// Les deux premières entrées de la table sont occupées par l'en-tête
+	for i := uint64(2); i < uint64(slotPages)*slotsPerPage; i++ {
+		slot := i * 16
+		if slot+16 > uint64(len(data)) {
+			break
+		}
+		if le.Uint32(data[slot:]) != slotMagic || le.Uint32(data[slot+4:]) == 0 {
+			continue
+		}
+		offset := uint64(le.Uint32(data[slot+8:])) * blockSize
+		if offset+blobHeaderSize > uint64(len(data)) || le.Uint32(data[offset:]) != blobMagic {
+			continue
+		}
+		length := uint64(le.Uint32(data[offset+12:]))
+		start := offset + blobHeaderSize
+		if start+length > uint64(len(data)) {
+			continue
+		}
+		blobs = append(blobs, data[start:start+length])
+	}
+	return blobs, nil
+}
+
+// Berkeley DB constants (db_page.h)
+const (
+	bdbHashMagic        = 0x061561
+	bdbPageHeaderSize   = 26
+	bdbPageHashUnsorted = 2
+	bdbPageOverflow     = 7
+	bdbPageHash         = 13
+	bdbItemOffPage      = 3
+)
+
+// bdbRPMBlobs reads a Berkeley DB hash database: the header blobs are stored off-page,
+// in chains of overflow pages referenced by the hash pages
+func bdbRPMBlobs(data []byte) ([][]byte, error) {
+	var order binary.ByteOrder = binary.LittleEndian
+	if binary.LittleEndian.Uint32(data[12:]) != bdbHashMagic {
+		order = binary.BigEndian
+	}
+	if len(data) < 36 {
+		return nil, fmt.Errorf("bdb database too short")
+	}
+	pageSize := uint64(order.Uint32(data[20:]))
+	if pageSize < 512 || data[24] != 0 {
+		return nil, fmt.Errorf("unsupported bdb database (page size %d, encrypted %v)", pageSize, data[24] != 0)
+	}
+	lastPage := uint64(order.Uint32(data[32:]))
+
+	page := func(n uint64) []byte {
+		start := n * pageSize
+		if start+pageSize > uint64(len(data)) {
+			return nil
+		}
+		return data[start : start+pageSize]
+	}
+
+	var blobs [][]byte
+	for n := uint64(1); n <= lastPage; n++ {
+		p := page(n)
+		if p == nil {
+			break
+		}
+		if p[25] != bdbPageHash && p[25] != bdbPageHashUnsorted {
+			continue
+		}
+		entries := uint64(order.Uint16(p[20:]))
+		// Les entrées vont par paires clé / valeur : seules les valeurs hors page nous intéressent
+		for i := uint64(1); i < entries; i += 2 {
+			indexPos := bdbPageHeaderSize + i*2
+			if indexPos+2 > pageSize {
+				break
+			}
+			item := uint64(order.Uint16(p[indexPos:]))
+			if item+12 > pageSize || p[item] != bdbItemOffPage {
+				continue
+			}
+			next := uint64(order.Uint32(p[item+4:]))
+			length := uint64(order.Uint32(p[item+8:]))
+			// Une longueur plus grande que le fichier ne peut venir que d'une base corrompue
+			if length > uint64(len(data)) {
+				continue
+			}
+
+			blob := make([]byte, 0, length)
+			for visited := 0; next != 0 && uint64(len(blob)) < length && visited <= len(data)/int(pageSize); visited++ {
+				overflow := page(next)
+				if overflow == nil || overflow[25] != bdbPageOverflow {
+					break
+				}
+				blob = append(blob, overflow[bdbPageHeaderSize:]...)
+				next = uint64(order.Uint32(overflow[16:]))
+			}
+			if uint64(len(blob)) >= length {
+				blobs = append(blobs, blob[:length])
+			}
+		}
+	}
+	return blobs, nil
+}
+
+// sqliteRPMBlobs reads the blob column of the Packages table of an SQLite database.
+// Only what rpm writes is supported: table b-trees, overflow pages, no WAL.
+func sqliteRPMBlobs(data []byte) ([][]byte, error) {
+	db, err := newSQLiteReader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	root := uint32(0)
+	err = db.walkTable(1, func(record [][]byte) {
+		// sqlite_master : type, name, tbl_name, rootpage, sql
+		if len(record) >= 4 && string(record[0]) == "table" && string(record[1]) == "Packages" {
+			root = uint32(sqliteInt(record[3]))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if root == 0 {
+		return nil, fmt.Errorf("no Packages table in rpm database")
+	}
+
+	var blobs [][]byte
+	err = db.walkTable(root, func(record [][]byte) {
+		// Packages(hnum INTEGER PRIMARY KEY, blob BLOB) : hnum est le rowid, stocké NULL
+		if len(record) >= 2 {
+			blobs = append(blobs, record[1])
+		}
+	})
+	return blobs, err
+}
+
+// sqliteReader reads the table b-trees of an SQLite file held in memory
+type sqliteReader struct {
+	data     []byte
+	pageSize uint64
+	usable   uint64
+}
+
+func newSQLiteReader(data []byte) (*sqliteReader, error) {
+	if len(data) < 100 {
+		return nil, fmt.Errorf("sqlite database too short")
+	}
+	pageSize := uint64(binary.BigEndian.Uint16(data[16:]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 {
+		return nil, fmt.Errorf("invalid sqlite page size %d", pageSize)
+	}
+	return &sqliteReader{data: data, pageSize: pageSize, usable: pageSize - uint64(data[20])}, nil
+}
+
+func (r *sqliteReader) page(n uint32) ([]byte, error) {
+	start := uint64(n-1) * r.pageSize
+	if n == 0 || start+r.pageSize > uint64(len(r.data)) {
+		return nil, fmt.Errorf("sqlite page %d out of range", n)
+	}
+	return r.data[start : start+r.pageSize], nil
+}
+
+// walkTable calls fn with the columns of every row of the table b-tree rooted at page root
+func (r *sqliteReader) walkTable(root uint32, fn func(record [][]byte)) error {
+	pending := []uint32{root}
+	for visited := 0; len(pending) > 0; visited++ {
+		if visited > len(r.data)/int(r.pageSize) {
+			return fmt.Errorf("sqlite b-tree loop")
+		}
+		n := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		p, err := r.page(n)
+		if err != nil {
+			return err
+		}
+		header := p
+		if n == 1 {
+			header = p[100:] // la page 1 commence par l'en-tête du fichier
+		}
+		cells := uint64(binary.BigEndian.Uint16(header[3:]))
+		pointers := header[8:]
+		if header[0] == 0x05 {
+			pointers = header[12:]
+			pending = append(pending, binary.BigEndian.Uint32(header[8:]))
+		} else if header[0] != 0x0d {
+			return fmt.Errorf("unexpected sqlite page type %d", header[0])
+		}
+
+		for i := uint64(0); i < cells; i++ {
+			if 2*i+2 > uint64(len(pointers)) {
+				return fmt.Errorf("sqlite cell pointer out of range")
+			}
+			cell := uint64(binary.BigEndian.Uint16(pointers[2*i:]))
+			if cell >= uint64(len(p)) {
+				return fmt.Errorf("sqlite cell out of range")
+			}
+			if header[0] == 0x05 {
+				// Cellule intérieure : page enfant puis rowid
+				if cell+4 > uint64(len(p)) {
+					return fmt.Errorf("sqlite cell out of range")
+				}
+				pending = append(pending, binary.BigEndian.Uint32(p[cell:]))
+				continue
+			}
+			payload, err := r.leafPayload(p[cell:])
+			if err != nil {
+				return err
+			}
+			fn(sqliteRecord(payload))
+		}
+	}
+	return nil
+}
+
+// leafPayload returns the payload of a table leaf cell, following its overflow pages
+func (r *sqliteReader) leafPayload(cell []byte) ([]byte, error) {
+	size, n := sqliteVarint(cell)
+	_, m := sqliteVarint(cell[n:]) // rowid
+	cell = cell[n+m:]
+	if size > uint64(len(r.data)) {
+		return nil, fmt.Errorf("sqlite payload size %d larger than the database", size)
+	}
+
+	maxLocal := r.usable - 35
+	local := size
+	if size > maxLocal {
+		minLocal := (r.usable-12)*32/255 - 23
+		local = minLocal + (size-minLocal)%(r.usable-4)
+		if local > maxLocal {
+			local = minLocal
+		}
+	}
+	if local > uint64(len(cell)) {
+		return nil, fmt.Errorf("sqlite payload out of range")
+	}
+	payload := make([]byte, 0, size)
+	payload = append(payload, cell[:local]...)
+	if local == size {
+		return payload, nil
+	}
+	if local+4 > uint64(len(cell)) {
+		return nil, fmt.Errorf("sqlite payload out of range")
+	}
+
+	next := binary.BigEndian.Uint32(cell[local:])
+	for next != 0 && uint64(len(payload)) < size {
+		p, err := r.page(next)
+		if err != nil {
+			return nil, err
+		}
+		chunk := p[4:r.usable]
+		if remaining := size - uint64(len(payload)); uint64(len(chunk)) > remaining {
+			chunk = chunk[:remaining]
+		}
+		payload = append(payload, chunk...)
+		next = binary.BigEndian.Uint32(p)
+	}
+	if uint64(len(payload)) != size {
+		return nil, fmt.Errorf("sqlite overflow chain truncated")
+	}
+	return payload, nil
+}
+
+// sqliteRecord splits a record into its column values (nil for NULL)
+func sqliteRecord(payload []byte) [][]byte {
+	headerSize, n := sqliteVarint(payload)
+	if headerSize < uint64(n) || headerSize > uint64(len(payload)) {
+		return nil
+	}
+	header := payload[n:headerSize]
+	body := payload[headerSize:]
+
+	var columns [][]byte
+	for len(header) > 0 {
+		serial, m := sqliteVarint(header)
+		header = header[m:]
+		var size uint64
+		switch {
+		case serial >= 12:
+			size = (serial - 12) / 2
+		case serial >= 1 && serial <= 4:
+			size = serial
+		case serial == 5:
+			size = 6
+		case serial == 6, serial == 7:
+			size = 8
+		}
+		if size > uint64(len(body)) {
+			return columns
+		}
+		switch serial {
+		case 8:
+			columns = append(columns, []byte{0})
+		case 9:
+			columns = append(columns, []byte{1})
+		default:
+			columns = append(columns, body[:size])
+		}
+		body = body[size:]
+	}
+	return columns
+}
+
+// sqliteInt decodes a big-endian integer column
+func sqliteInt(value []byte) int64 {
+	var n int64
+	for i, b := range value {
+		if i == 0 && b&0x80 != 0 {
+			n = -1
+		}
+		n = n<<8 | int64(b)
+	}
+	return n
+}
+
+// sqliteVarint decodes an SQLite varint (1 to 9 bytes, big-endian)
+func sqliteVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(b) && i < 9; i++ {
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return v, len(b)
+}
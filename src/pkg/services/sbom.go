@@ -0,0 +1,764 @@
+// pkg/services/sbom.go
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"helm-portal/config"
+	"helm-portal/pkg/interfaces"
+	"helm-portal/pkg/models"
+	utils "helm-portal/pkg/utils"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrSBOMFormat is returned for a format other than spdx and cyclonedx
+	ErrSBOMFormat = errors.New("unsupported SBOM format")
+	// ErrSBOMIndex is returned when asking the SBOM of a multi-platform index instead of one of its platforms
+	ErrSBOMIndex = errors.New("multi-platform index, the SBOMs are generated per platform")
+	// ErrSBOMNoManifest is returned for a chart version without OCI manifest to attach the SBOM to
+	ErrSBOMNoManifest = errors.New("chart has no OCI manifest, push it with helm push to attach an SBOM")
+)
+
+// sbomToolName identifies the portal as the creator of the documents
+const sbomToolName = "helm-portal"
+
+// annotationSBOMGenerator marks the SBOM artifacts generated by the portal, replaced when regenerated
+const annotationSBOMGenerator = "io.helm-portal.sbom.generator"
+
+// emptyConfig is the content of the config of artifacts (media type application/vnd.oci.empty.v1+json)
+var emptyConfig = []byte("{}")
+
+// sbomRequest is a pushed chart or image waiting for its SBOM
+type sbomRequest struct {
+	artifactType    models.ArtifactType
+	name, reference string
+}
+
+// sbomComponent is a package, chart dependency or image listed in an SBOM
+type sbomComponent struct {
+	Type       string // type CycloneDX : library, operating-system, application, container
+	Name       string
+	Version    string
+	PURL       string
+	Digest     string
+	Location   string
+	Properties map[string]string
+}
+
+// sbomTarget is the chart or image described by an SBOM
+type sbomTarget struct {
+	Type       string // container ou application
+	Name       string
+	Reference  string
+	Digest     string
+	Components []sbomComponent
+}
+
+// SBOMService generates the SBOMs (SPDX or CycloneDX) of images, from the packages of their layers,
+// and of charts, from their dependencies and the images of their rendered templates.
+// SBOMs are stored as OCI artifacts whose subject is the manifest they describe (referrers API).
+type SBOMService struct {
+	config       *config.Config
+	pathManager  *utils.PathManager
+	chartService interfaces.ChartServiceInterface
+	imageService interfaces.ImageServiceInterface
+	format       string
+	maxFileSize  int64
+	log          *utils.Logger
+	mu           sync.Mutex
+	queue        chan sbomRequest
+}
+
+// NewSBOMService creates the SBOM service; sbom.format is the format generated on push (spdx by default)
+func NewSBOMService(cfg *config.Config, pathManager *utils.PathManager, chartService interfaces.ChartServiceInterface, imageService interfaces.ImageServiceInterface, log *utils.Logger) *SBOMService {
+	s := &SBOMService{
+		config:       cfg,
+		pathManager:  pathManager,
+		chartService: chartService,
+		imageService: imageService,
+		format:       cfg.SBOM.Format,
+		maxFileSize:  cfg.Scanning.MaxFileSize,
+		log:          log,
+		queue:        make(chan sbomRequest, scanQueueSize),
+	}
+	if models.SBOMMediaType(s.format) == "" {
+		if s.format != "" {
+			log.WithField("format", s.format).Warn("⚠️ Unknown SBOM format, using spdx")
+		}
+		s.format = models.SBOMFormatSPDX
+	}
+	if s.maxFileSize <= 0 {
+		s.maxFileSize = defaultMaxInventoryFileSize
+	}
+	return s
+}
+
+// Format returns the format generated on push
+func (s *SBOMService) Format() string {
+	return s.format
+}
+
+// Start generates the SBOMs of the pushed charts and images in the background, one at a time
+func (s *SBOMService) Start() {
+	go func() {
+		for req := range s.queue {
+			s.generatePushed(req)
+		}
+	}()
+}
+
+func (s *SBOMService) generatePushed(req sbomRequest) {
+	defer recoverJob(s.log, "sbom", req.name, req.reference)
+	var err error
+	if req.artifactType == models.ArtifactTypeHelmChart {
+		// Un chart envoyé par l'API HTTP n'a pas de manifest auquel lier un SBOM
+		if _, _, err = s.ChartSBOM(req.name, req.reference, s.format, false); errors.Is(err, ErrSBOMNoManifest) {
+			return
+		}
+	} else {
+		err = s.generatePushedImage(req.name, req.reference)
+	}
+	if err != nil {
+		s.log.WithError(err).WithField("name", req.name).WithField("reference", req.reference).Warn("Failed to generate SBOM")
+	}
+}
+
+// OnArtifactEvent queues the SBOM of the pushed charts and images, unless sbom.disableOnPush is set
+func (s *SBOMService) OnArtifactEvent(event models.ArtifactEvent) {
+	if s.config.SBOM.DisableOnPush || event.Action != models.EventPush {
+		return
+	}
+	req := sbomRequest{artifactType: event.Type, name: strings.Clone(event.Name)}
+	switch event.Type {
+	case models.ArtifactTypeHelmChart:
+		req.reference = strings.Clone(event.Reference)
+	case models.ArtifactTypeDockerImage:
+		if event.Digest == "" {
+			return
+		}
+		// Le digest : le même contenu poussé sous plusieurs tags n'a qu'un SBOM
+		req.reference = strings.Clone(event.Digest)
+	default:
+		return
+	}
+	select {
+	case s.queue <- req:
+	default:
+		s.log.WithField("name", req.name).WithField("reference", req.reference).Warn("⚠️ SBOM queue full, SBOM will be generated on download")
+	}
+}
+
+// generatePushedImage generates the SBOM of a pushed image, unless it is an index (its platforms are
+// pushed on their own), an artifact referring to another manifest, or already has one
+func (s *SBOMService) generatePushedImage(name, digest string) error {
+	data, _, mediaType, err := readImageManifest(s.imageService, name, digest)
+	if err != nil {
+		return err
+	}
+	if models.IsIndexMediaType(mediaType) {
+		return nil
+	}
+	var manifest models.OCIManifest
+	if err := json.Unmarshal(data, &manifest); err != nil || manifest.Subject != nil {
+		return nil
+	}
+	_, _, err = s.ImageSBOM(name, digest, s.format, false)
+	return err
+}
+
+// ImageSBOM returns the SBOM of an image tag or digest, generated if it has none or regenerate is set
+func (s *SBOMService) ImageSBOM(name, reference, format string, regenerate bool) (*models.SBOMInfo, []byte, error) {
+	mediaType := models.SBOMMediaType(format)
+	if mediaType == "" {
+		return nil, nil, fmt.Errorf("%w: %q", ErrSBOMFormat, format)
+	}
+	data, digest, manifestMediaType, err := readImageManifest(s.imageService, name, reference)
+	if err != nil {
+		return nil, nil, err
+	}
+	if models.IsIndexMediaType(manifestMediaType) {
+		return nil, nil, ErrSBOMIndex
+	}
+	subject := models.OCIDescriptor{MediaType: manifestMediaType, Digest: digest, Size: int64(len(data))}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !regenerate {
+		if info, document, err := s.find(name, digest, mediaType); err == nil {
+			return info, document, nil
+		}
+	}
+
+	var manifest models.OCIManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidManifest, err)
+	}
+	inventory, err := buildInventory(s.imageService, &manifest, s.maxFileSize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("❌ failed to read image layers: %w", err)
+	}
+	packages, osName := inventory.packages()
+
+	target := &sbomTarget{Type: "container", Name: name, Reference: reference, Digest: digest}
+	if osName != "" {
+		target.Components = append(target.Components, sbomComponent{Type: "operating-system", Name: osName})
+	}
+	for _, pkg := range packages {
+		component := sbomComponent{
+			Type:     "library",
+			Name:     pkg.Name,
+			Version:  pkg.Version,
+			PURL:     packageURL(pkg),
+			Location: pkg.Location,
+		}
+		if pkg.Source != "" && pkg.Source != pkg.Name {
+			component.Properties = map[string]string{"source": pkg.Source}
+		}
+		target.Components = append(target.Components, component)
+	}
+	return s.store(name, subject, format, target)
+}
+
+// ChartSBOM returns the SBOM of a chart version, generated if it has none or regenerate is set.
+// It lists the dependencies of the chart and the images of its templates rendered with the default values.
+func (s *SBOMService) ChartSBOM(name, version, format string, regenerate bool) (*models.SBOMInfo, []byte, error) {
+	mediaType := models.SBOMMediaType(format)
+	if mediaType == "" {
+		return nil, nil, fmt.Errorf("%w: %q", ErrSBOMFormat, format)
+	}
+	if !s.chartService.ChartExists(name, version) {
+		return nil, nil, fmt.Errorf("%w: %s-%s", ErrChartNotFound, name, version)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	subject, err := s.chartManifest(name, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !regenerate {
+		if info, document, err := s.find(name, subject.Digest, mediaType); err == nil {
+			return info, document, nil
+		}
+	}
+
+	target := &sbomTarget{Type: "application", Name: name, Reference: version, Digest: subject.Digest}
+	dependencies, err := s.chartService.CheckDependencies(name, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, dep := range dependencies.Dependencies {
+		depVersion := dep.VendoredVersion
+		if depVersion == "" {
+			depVersion = dep.ResolvedVersion
+		}
+		if depVersion == "" {
+			depVersion = dep.Constraint
+		}
+		target.Components = append(target.Components, sbomComponent{
+			Type:     "application",
+			Name:     dep.Name,
+			Version:  depVersion,
+			Location: dep.Repository,
+			Properties: map[string]string{
+				"helm:constraint": dep.Constraint,
+				"helm:status":     dep.Status,
+			},
+		})
+	}
+
+	images, err := chartImages(s.chartService, name, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, ref := range images {
+		component := imageComponent(ref)
+		if imageName, reference, hosted := hostedImage(s.config, s.imageService, ref); hosted {
+			if _, digest, _, err := readImageManifest(s.imageService, imageName, reference); err == nil {
+				component.Digest = digest
+			}
+		}
+		target.Components = append(target.Components, component)
+	}
+	return s.store(name, subject, format, target)
+}
+
+// find returns the newest SBOM of a format referring to a manifest
+func (s *SBOMService) find(name, subject, mediaType string) (*models.SBOMInfo, []byte, error) {
+	referrers, err := ListReferrers(s.pathManager, name, subject, mediaType)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, referrer := range referrers {
+		data, err := os.ReadFile(s.pathManager.GetManifestPath(name, referrer.Digest))
+		if err != nil {
+			continue
+		}
+		var manifest models.OCIManifest
+		if json.Unmarshal(data, &manifest) != nil || len(manifest.Layers) == 0 {
+			continue
+		}
+		document, err := os.ReadFile(s.pathManager.GetBlobPath(manifest.Layers[0].Digest))
+		if err != nil {
+			continue
+		}
+		created, _ := time.Parse(time.RFC3339, manifest.Annotations[models.AnnotationCreated])
+		return &models.SBOMInfo{
+			Format:    sbomFormat(mediaType),
+			MediaType: mediaType,
+			Digest:    referrer.Digest,
+			Subject:   subject,
+			Document:  manifest.Layers[0].Digest,
+			Size:      int64(len(document)),
+			Created:   created,
+		}, document, nil
+	}
+	return nil, nil, os.ErrNotExist
+}
+
+// store writes an SBOM and the artifact manifest linking it to its subject,
+// then removes the SBOMs of the same format previously generated for that subject
+func (s *SBOMService) store(name string, subject models.OCIDescriptor, format string, target *sbomTarget) (*models.SBOMInfo, []byte, error) {
+	created := time.Now().UTC().Truncate(time.Second)
+	var document []byte
+	var err error
+	if format == models.SBOMFormatCycloneDX {
+		document, err = cycloneDXDocument(target, created)
+	} else {
+		document, err = spdxDocument(target, created)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	mediaType := models.SBOMMediaType(format)
+
+	previous, err := ListReferrers(s.pathManager, name, subject.Digest, mediaType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	documentDescriptor, err := s.writeBlob(document, mediaType)
+	if err != nil {
+		return nil, nil, err
+	}
+	documentDescriptor.Annotations = map[string]string{
+		models.AnnotationTitle: fmt.Sprintf("%s-%s.%s.json", name, strings.ReplaceAll(target.Reference, ":", "-"), format),
+	}
+	configDescriptor, err := s.writeBlob(emptyConfig, models.MediaTypeOCIEmpty)
+	if err != nil {
+		return nil, nil, err
+	}
+	subjectDescriptor := subject
+	manifestData, err := json.Marshal(models.OCIManifest{
+		SchemaVersion: 2,
+		MediaType:     models.MediaTypeOCIManifest,
+		ArtifactType:  mediaType,
+		Config:        configDescriptor,
+		Layers:        []models.OCIDescriptor{documentDescriptor},
+		Subject:       &subjectDescriptor,
+		Annotations: map[string]string{
+			models.AnnotationCreated: created.Format(time.RFC3339),
+			annotationSBOMGenerator:  sbomToolName,
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(manifestData))
+	if err := writeManifestFile(s.pathManager.GetManifestPath(name, digest), manifestData, models.MediaTypeOCIManifest); err != nil {
+		return nil, nil, fmt.Errorf("❌ failed to save SBOM manifest: %w", err)
+	}
+
+	// Les SBOM poussés par d'autres outils sont conservés
+	for _, referrer := range previous {
+		if referrer.Digest == digest || referrer.Annotations[annotationSBOMGenerator] != sbomToolName {
+			continue
+		}
+		manifestPath := s.pathManager.GetManifestPath(name, referrer.Digest)
+		if err := os.Remove(manifestPath); err != nil && !os.IsNotExist(err) {
+			s.log.WithError(err).Warn("⚠️ Failed to remove previous SBOM")
+		}
+		os.Remove(utils.MediaTypePath(manifestPath))
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"name":       name,
+		"reference":  target.Reference,
+		"subject":    subject.Digest,
+		"format":     format,
+		"components": len(target.Components),
+	}).Info("✅ SBOM generated")
+
+	return &models.SBOMInfo{
+		Format:    format,
+		MediaType: mediaType,
+		Digest:    digest,
+		Subject:   subject.Digest,
+		Document:  documentDescriptor.Digest,
+		Size:      int64(len(document)),
+		Created:   created,
+	}, document, nil
+}
+
+// chartManifest returns the OCI manifest of a chart version, the subject of its SBOM. Charts uploaded
+// through the HTTP API have none, and the manifest of a version overwritten through the HTTP API describes
+// the previous archive: a read never writes a manifest, which would change the digest of the chart.
+func (s *SBOMService) chartManifest(name, version string) (models.OCIDescriptor, error) {
+	chartData, err := s.chartService.GetChart(name, version)
+	if err != nil {
+		return models.OCIDescriptor{}, err
+	}
+	chartDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(chartData))
+
+	manifestPath := s.pathManager.GetManifestPath(name, version)
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return models.OCIDescriptor{}, fmt.Errorf("%w: %s-%s", ErrSBOMNoManifest, name, version)
+	}
+	var manifest models.OCIManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return models.OCIDescriptor{}, fmt.Errorf("%w: %v", ErrInvalidManifest, err)
+	}
+	for _, layer := range manifest.Layers {
+		if layer.MediaType == models.MediaTypeHelmChart && layer.Digest == chartDigest {
+			mediaType, _ := os.ReadFile(utils.MediaTypePath(manifestPath))
+			return models.OCIDescriptor{
+				MediaType: models.ManifestMediaType(data, string(mediaType)),
+				Digest:    fmt.Sprintf("sha256:%x", sha256.Sum256(data)),
+				Size:      int64(len(data)),
+			}, nil
+		}
+	}
+	return models.OCIDescriptor{}, fmt.Errorf("%w: the OCI manifest of %s-%s describes another archive", ErrSBOMNoManifest, name, version)
+}
+
+// writeBlob stores a blob under its digest, unless it is already stored
+func (s *SBOMService) writeBlob(data []byte, mediaType string) (models.OCIDescriptor, error) {
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+	blobPath := s.pathManager.GetBlobPath(digest)
+	if _, err := os.Stat(blobPath); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(blobPath), 0755); err != nil {
+			return models.OCIDescriptor{}, fmt.Errorf("❌ failed to create blobs directory: %w", err)
+		}
+		if err := os.WriteFile(blobPath, data, 0644); err != nil {
+			return models.OCIDescriptor{}, fmt.Errorf("❌ failed to write blob: %w", err)
+		}
+	}
+	return models.OCIDescriptor{MediaType: mediaType, Digest: digest, Size: int64(len(data))}, nil
+}
+
+// writeManifestFile writes a manifest and the media type it is served with
+func writeManifestFile(manifestPath string, data []byte, mediaType string) error {
+	if err := os.MkdirAll(filepath.Dir(manifestPath), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(manifestPath, data, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(utils.MediaTypePath(manifestPath), []byte(mediaType), 0644)
+}
+
+func sbomFormat(mediaType string) string {
+	if mediaType == models.MediaTypeCycloneDX {
+		return models.SBOMFormatCycloneDX
+	}
+	return models.SBOMFormatSPDX
+}
+
+// imageComponent describes an image referenced by a chart ("registry/name:tag" or "name@sha256:...")
+func imageComponent(ref string) sbomComponent {
+	name, version := ref, "latest"
+	if at := strings.Index(ref, "@"); at >= 0 {
+		name, version = ref[:at], ref[at+1:]
+	} else if colon := strings.LastIndex(ref, ":"); colon > strings.LastIndex(ref, "/") {
+		name, version = ref[:colon], ref[colon+1:]
+	}
+
+	purl := "pkg:docker/" + purlPath(name) + "@" + purlEscape(version)
+	if registry, repository, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(registry, ".:") || registry == "localhost") {
+		purl = "pkg:docker/" + purlPath(repository) + "@" + purlEscape(version) + "?repository_url=" + purlEscape(registry)
+	}
+	return sbomComponent{Type: "container", Name: name, Version: version, PURL: purl, Location: ref}
+}
+
+// packageURL returns the package URL (purl) of a package found in an image, "" for unknown types
+func packageURL(pkg models.Package) string {
+	version := purlEscape(pkg.Version)
+	switch pkg.Type {
+	case "dpkg":
+		return osPackageURL("deb", pkg, version)
+	case "apk":
+		return osPackageURL("apk", pkg, version)
+	case "rpm":
+		return osPackageURL("rpm", pkg, version)
+	case "npm", "yarn":
+		return "pkg:npm/" + purlPath(pkg.Name) + "@" + version
+	case "pip", "pipenv", "pypi":
+		return "pkg:pypi/" + purlEscape(strings.NewReplacer("_", "-", ".", "-").Replace(strings.ToLower(pkg.Name))) + "@" + version
+	case "cargo":
+		return "pkg:cargo/" + purlEscape(pkg.Name) + "@" + version
+	case "bundler":
+		return "pkg:gem/" + purlEscape(pkg.Name) + "@" + version
+	case "composer":
+		return "pkg:composer/" + purlPath(pkg.Name) + "@" + version
+	case "go-module":
+		return "pkg:golang/" + purlPath(pkg.Name) + "@v" + version
+	case "go-stdlib":
+		return "pkg:golang/stdlib@" + version
+	}
+	return ""
+}
+
+// osPackageURL returns the purl of a distribution package: pkg:deb/debian/openssl@3.0.11-1?distro=debian-12
+func osPackageURL(purlType string, pkg models.Package, version string) string {
+	base, release, _ := strings.Cut(pkg.Ecosystem, ":")
+	namespace := strings.ToLower(base)
+	switch namespace {
+	case "red hat":
+		namespace = "redhat"
+	case "rocky linux":
+		namespace = "rocky"
+	}
+	namespace = strings.ReplaceAll(namespace, " ", "")
+
+	purl := "pkg:" + purlType + "/" + purlEscape(namespace) + "/" + purlEscape(pkg.Name) + "@" + version
+	if i := strings.LastIndex(release, ":"); i >= 0 {
+		release = release[i+1:]
+	}
+	if release = strings.TrimPrefix(release, "v"); release != "" {
+		purl += "?distro=" + purlEscape(namespace+"-"+strings.ReplaceAll(strings.ToLower(release), " ", "-"))
+	}
+	return purl
+}
+
+// purlPath escapes the segments of a namespaced name (@scope/name, vendor/name, module path)
+func purlPath(name string) string {
+	segments := strings.Split(name, "/")
+	for i, segment := range segments {
+		segments[i] = purlEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// purlEscape percent-encodes the characters that have a meaning in a purl
+func purlEscape(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if isLetter(c) || isDigit(c) || strings.IndexByte(".-_~+", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// spdxDocument renders an SPDX 2.3 JSON document
+func spdxDocument(target *sbomTarget, created time.Time) ([]byte, error) {
+	type checksum struct {
+		Algorithm     string `json:"algorithm"`
+		ChecksumValue string `json:"checksumValue"`
+	}
+	type externalRef struct {
+		ReferenceCategory string `json:"referenceCategory"`
+		ReferenceType     string `json:"referenceType"`
+		ReferenceLocator  string `json:"referenceLocator"`
+	}
+	type spdxPackage struct {
+		Name                  string        `json:"name"`
+		SPDXID                string        `json:"SPDXID"`
+		VersionInfo           string        `json:"versionInfo,omitempty"`
+		DownloadLocation      string        `json:"downloadLocation"`
+		FilesAnalyzed         bool          `json:"filesAnalyzed"`
+		LicenseConcluded      string        `json:"licenseConcluded"`
+		LicenseDeclared       string        `json:"licenseDeclared"`
+		CopyrightText         string        `json:"copyrightText"`
+		PrimaryPackagePurpose string        `json:"primaryPackagePurpose,omitempty"`
+		SourceInfo            string        `json:"sourceInfo,omitempty"`
+		Comment               string        `json:"comment,omitempty"`
+		Checksums             []checksum    `json:"checksums,omitempty"`
+		ExternalRefs          []externalRef `json:"externalRefs,omitempty"`
+	}
+	type relationship struct {
+		SpdxElementID      string `json:"spdxElementId"`
+		RelationshipType   string `json:"relationshipType"`
+		RelatedSpdxElement string `json:"relatedSpdxElement"`
+	}
+
+	newPackage := func(id string, c sbomComponent) spdxPackage {
+		p := spdxPackage{
+			Name:                  c.Name,
+			SPDXID:                id,
+			VersionInfo:           c.Version,
+			DownloadLocation:      "NOASSERTION",
+			LicenseConcluded:      "NOASSERTION",
+			LicenseDeclared:       "NOASSERTION",
+			CopyrightText:         "NOASSERTION",
+			PrimaryPackagePurpose: strings.ToUpper(c.Type),
+			Comment:               formatProperties(c.Properties),
+		}
+		if c.Type == "library" {
+			p.PrimaryPackagePurpose = "LIBRARY"
+		}
+		if c.Location != "" {
+			p.SourceInfo = "found in " + c.Location
+		}
+		if hex, ok := strings.CutPrefix(c.Digest, "sha256:"); ok {
+			p.Checksums = []checksum{{Algorithm: "SHA256", ChecksumValue: hex}}
+		}
+		if c.PURL != "" {
+			p.ExternalRefs = []externalRef{{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: c.PURL}}
+		}
+		return p
+	}
+
+	root := sbomComponent{Type: target.Type, Name: target.Name, Version: target.Reference, Digest: target.Digest}
+	if target.Type == "container" {
+		root.PURL = "pkg:oci/" + purlEscape(target.Name[strings.LastIndex(target.Name, "/")+1:]) + "@" + purlEscape(target.Digest)
+	}
+	packages := []spdxPackage{newPackage("SPDXRef-Root", root)}
+	relationships := []relationship{{SpdxElementID: "SPDXRef-DOCUMENT", RelationshipType: "DESCRIBES", RelatedSpdxElement: "SPDXRef-Root"}}
+	relationshipType := "CONTAINS"
+	if target.Type == "application" {
+		relationshipType = "DEPENDS_ON"
+	}
+	for i, component := range target.Components {
+		id := fmt.Sprintf("SPDXRef-Package-%d", i+1)
+		packages = append(packages, newPackage(id, component))
+		relationships = append(relationships, relationship{SpdxElementID: "SPDXRef-Root", RelationshipType: relationshipType, RelatedSpdxElement: id})
+	}
+
+	document := struct {
+		SPDXVersion       string `json:"spdxVersion"`
+		DataLicense       string `json:"dataLicense"`
+		SPDXID            string `json:"SPDXID"`
+		Name              string `json:"name"`
+		DocumentNamespace string `json:"documentNamespace"`
+		CreationInfo      struct {
+			Created  string   `json:"created"`
+			Creators []string `json:"creators"`
+		} `json:"creationInfo"`
+		Packages      []spdxPackage  `json:"packages"`
+		Relationships []relationship `json:"relationships"`
+	}{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              target.Name + ":" + target.Reference,
+		DocumentNamespace: fmt.Sprintf("https://%s/spdx/%s-%s-%s", sbomToolName, purlPath(target.Name), purlEscape(target.Reference), uuid.New().String()),
+		Packages:          packages,
+		Relationships:     relationships,
+	}
+	document.CreationInfo.Created = created.Format(time.RFC3339)
+	document.CreationInfo.Creators = []string{"Tool: " + sbomToolName}
+	return json.MarshalIndent(document, "", "  ")
+}
+
+// cycloneDXDocument renders a CycloneDX 1.5 JSON document
+func cycloneDXDocument(target *sbomTarget, created time.Time) ([]byte, error) {
+	type hash struct {
+		Alg     string `json:"alg"`
+		Content string `json:"content"`
+	}
+	type property struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	type component struct {
+		BOMRef     string     `json:"bom-ref"`
+		Type       string     `json:"type"`
+		Name       string     `json:"name"`
+		Version    string     `json:"version,omitempty"`
+		PURL       string     `json:"purl,omitempty"`
+		Hashes     []hash     `json:"hashes,omitempty"`
+		Properties []property `json:"properties,omitempty"`
+	}
+	type dependency struct {
+		Ref       string   `json:"ref"`
+		DependsOn []string `json:"dependsOn,omitempty"`
+	}
+
+	newComponent := func(ref string, c sbomComponent) component {
+		cdx := component{BOMRef: ref, Type: c.Type, Name: c.Name, Version: c.Version, PURL: c.PURL}
+		if hex, ok := strings.CutPrefix(c.Digest, "sha256:"); ok {
+			cdx.Hashes = []hash{{Alg: "SHA-256", Content: hex}}
+		}
+		if c.Location != "" {
+			cdx.Properties = append(cdx.Properties, property{Name: sbomToolName + ":location", Value: c.Location})
+		}
+		for _, key := range sortedKeys(c.Properties) {
+			cdx.Properties = append(cdx.Properties, property{Name: sbomToolName + ":" + key, Value: c.Properties[key]})
+		}
+		return cdx
+	}
+
+	root := newComponent("root", sbomComponent{Type: target.Type, Name: target.Name, Version: target.Reference, Digest: target.Digest})
+	components := []component{}
+	refs := []string{}
+	for i, c := range target.Components {
+		ref := fmt.Sprintf("component-%d", i+1)
+		if c.PURL != "" {
+			ref = c.PURL
+		}
+		// Les bom-ref doivent être uniques
+		for _, existing := range refs {
+			if existing == ref {
+				ref = fmt.Sprintf("%s#%d", ref, i+1)
+				break
+			}
+		}
+		refs = append(refs, ref)
+		components = append(components, newComponent(ref, c))
+	}
+
+	type tool struct {
+		Type string `json:"type"`
+		Name string `json:"name"`
+	}
+	document := struct {
+		BOMFormat    string `json:"bomFormat"`
+		SpecVersion  string `json:"specVersion"`
+		SerialNumber string `json:"serialNumber"`
+		Version      int    `json:"version"`
+		Metadata     struct {
+			Timestamp string `json:"timestamp"`
+			Tools     struct {
+				Components []tool `json:"components"`
+			} `json:"tools"`
+			Component component `json:"component"`
+		} `json:"metadata"`
+		Components   []component  `json:"components"`
+		Dependencies []dependency `json:"dependencies"`
+	}{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + uuid.New().String(),
+		Version:      1,
+		Components:   components,
+		Dependencies: []dependency{{Ref: root.BOMRef, DependsOn: refs}},
+	}
+	document.Metadata.Timestamp = created.Format(time.RFC3339)
+	document.Metadata.Tools.Components = []tool{{Type: "application", Name: sbomToolName}}
+	document.Metadata.Component = root
+	return json.MarshalIndent(document, "", "  ")
+}
+
+func formatProperties(properties map[string]string) string {
+	parts := make([]string, 0, len(properties))
+	for _, key := range sortedKeys(properties) {
+		parts = append(parts, key+": "+properties[key])
+	}
+	return strings.Join(parts, ", ")
+}
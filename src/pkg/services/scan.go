@@ -0,0 +1,464 @@
+// pkg/services/scan.go
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"helm-portal/config"
+	"helm-portal/pkg/interfaces"
+	"helm-portal/pkg/models"
+	utils "helm-portal/pkg/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrScanNotFound is returned when an image has not been scanned yet
+	ErrScanNotFound = errors.New("image not scanned yet")
+	// ErrImageNotFound is returned when scanning an image reference that is not stored
+	ErrImageNotFound = errors.New("image not found")
+)
+
+// scanQueueSize bounds the pushes waiting to be scanned; further pushes are scanned on demand
+const scanQueueSize = 64
+
+// digestPattern validates the digests used as report file names
+var digestPattern = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// imageFieldPattern finds the images of rendered Kubernetes manifests
+var imageFieldPattern = regexp.MustCompile(`(?m)^\s*-?\s*image:\s*["']?([^"'\s#]+)`)
+
+// scanRequest is a pushed manifest waiting to be scanned
+type scanRequest struct {
+	name, reference, digest string
+}
+
+// ScanService extracts the packages of images (dpkg, apk, rpm, Go binaries, lockfiles) and matches
+// them against the offline vulnerability database. Reports are stored per manifest digest
+// under <storage>/scans and matched again when the database changes.
+type ScanService struct {
+	config       *config.Config
+	chartService interfaces.ChartServiceInterface
+	imageService interfaces.ImageServiceInterface
+	db           *VulnerabilityDatabase
+	dir          string
+	maxFileSize  int64
+	log          *utils.Logger
+	queue        chan scanRequest
+}
+
+// NewScanService loads the vulnerability database and imports the file configured in scanning.database
+func NewScanService(cfg *config.Config, pathManager *utils.PathManager, chartService interfaces.ChartServiceInterface, imageService interfaces.ImageServiceInterface, log *utils.Logger) *ScanService {
+	s := &ScanService{
+		config:       cfg,
+		chartService: chartService,
+		imageService: imageService,
+		db:           NewVulnerabilityDatabase(pathManager, log),
+		dir:          filepath.Join(pathManager.GetBasePath(), "scans"),
+		maxFileSize:  cfg.Scanning.MaxFileSize,
+		log:          log,
+		queue:        make(chan scanRequest, scanQueueSize),
+	}
+	if s.maxFileSize <= 0 {
+		s.maxFileSize = defaultMaxInventoryFileSize
+	}
+	if cfg.Scanning.Database != "" {
+		if _, err := s.db.ImportFile(cfg.Scanning.Database, false); err != nil {
+			log.WithError(err).Error("Failed to import vulnerability database")
+		}
+	}
+	return s
+}
+
+// ImportDatabaseFile imports an OSV dump read from the server, only from the directory of scanning.databaseDir
+func (s *ScanService) ImportDatabaseFile(path string, replace bool) (*models.VulnerabilityDatabaseStatus, error) {
+	resolved, err := resolveUnder(s.config.Scanning.DatabaseDir, path)
+	if errors.Is(err, errOutsideRoot) {
+		return nil, fmt.Errorf("%w: %s", ErrDatabasePathForbidden, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDatabase, err)
+	}
+	return s.db.ImportFile(resolved, replace)
+}
+
+// forRepository returns a scanner of the charts and images of an isolated repository. It shares the database
+// and the reports, stored per digest; it is not subscribed to pushes, the images of the repository are scanned on demand.
+func (s *ScanService) forRepository(chartService interfaces.ChartServiceInterface, imageService interfaces.ImageServiceInterface) *ScanService {
+	scanner := *s
+	scanner.chartService = chartService
+	scanner.imageService = imageService
+	return &scanner
+}
+
+// Database returns the vulnerability database used by the scans
+func (s *ScanService) Database() *VulnerabilityDatabase {
+	return s.db
+}
+
+// Start scans the pushed images in the background, one at a time
+func (s *ScanService) Start() {
+	go func() {
+		for req := range s.queue {
+			s.scanPushed(req)
+		}
+	}()
+}
+
+func (s *ScanService) scanPushed(req scanRequest) {
+	defer recoverJob(s.log, "scan", req.name, req.reference)
+	if report, err := s.loadReport(req.digest); err == nil && report.DatabaseVersion == s.db.Version() && report.Status == models.ScanStatusCompleted {
+		return // même contenu déjà analysé, par exemple un autre tag
+	}
+	if _, err := s.scanDigest(req.name, req.reference, req.digest); err != nil {
+		s.log.WithError(err).WithField("name", req.name).WithField("reference", req.reference).Warn("Failed to scan pushed image")
+	}
+}
+
+// recoverJob keeps a background worker alive when one of its jobs panics, for instance on a crafted
+// layer: the job is abandoned and logged. It must be deferred by the function running the job.
+func recoverJob(log *utils.Logger, job, name, reference string) {
+	if r := recover(); r != nil {
+		log.WithFields(logrus.Fields{
+			"job":       job,
+			"name":      name,
+			"reference": reference,
+			"panic":     fmt.Sprint(r),
+		}).Error("❌ Background job panicked")
+	}
+}
+
+// OnArtifactEvent queues the scan of the pushed images, unless scanning.disableOnPush is set
+func (s *ScanService) OnArtifactEvent(event models.ArtifactEvent) {
+	if s.config.Scanning.DisableOnPush || event.Action != models.EventPush || event.Type != models.ArtifactTypeDockerImage || event.Digest == "" {
+		return
+	}
+	req := scanRequest{name: strings.Clone(event.Name), reference: strings.Clone(event.Reference), digest: strings.Clone(event.Digest)}
+	select {
+	case s.queue <- req:
+	default:
+		s.log.WithField("name", req.name).WithField("reference", req.reference).Warn("⚠️ Scan queue full, image will be scanned on demand")
+	}
+}
+
+// ScanImage scans an image tag or digest now. A multi-platform index is scanned platform by platform.
+func (s *ScanService) ScanImage(name, reference string) (*models.ScanReport, error) {
+	data, digest, mediaType, err := readImageManifest(s.imageService, name, reference)
+	if err != nil {
+		return nil, err
+	}
+	if models.IsIndexMediaType(mediaType) {
+		return s.indexReport(name, reference, digest, data, true)
+	}
+	return s.scanDigest(name, reference, digest)
+}
+
+// GetReport returns the scan of an image tag or digest, matched against the current database
+func (s *ScanService) GetReport(name, reference string) (*models.ScanReport, error) {
+	data, digest, mediaType, err := readImageManifest(s.imageService, name, reference)
+	if err != nil {
+		return nil, err
+	}
+	if models.IsIndexMediaType(mediaType) {
+		return s.indexReport(name, reference, digest, data, false)
+	}
+
+	report, err := s.loadReport(digest)
+	if err != nil {
+		return nil, err
+	}
+	if version := s.db.Version(); report.DatabaseVersion != version {
+		s.match(report)
+		if err := s.saveReport(report); err != nil {
+			s.log.WithError(err).Warn("Failed to save scan report")
+		}
+	}
+	report.Reference = reference
+	return report, nil
+}
+
+// Summary returns the vulnerability counts of an image tag, nil if it was not scanned
+func (s *ScanService) Summary(name, reference string) *models.VulnerabilitySummary {
+	report, err := s.GetReport(name, reference)
+	if err != nil || report.Status != models.ScanStatusCompleted {
+		return nil
+	}
+	return &report.Summary
+}
+
+// readImageManifest returns the stored manifest of an image reference, its digest and media type
+func readImageManifest(imageService interfaces.ImageServiceInterface, name, reference string) ([]byte, string, string, error) {
+	manifestPath := imageService.GetPathManager().GetImageManifestPath(name, reference)
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("%w: %s:%s", ErrImageNotFound, name, reference)
+	}
+	mediaType, _ := os.ReadFile(utils.MediaTypePath(manifestPath))
+	return data, fmt.Sprintf("sha256:%x", sha256.Sum256(data)), models.ManifestMediaType(data, string(mediaType)), nil
+}
+
+// scanDigest extracts the packages of a single-platform image, matches them and stores the report
+func (s *ScanService) scanDigest(name, reference, digest string) (*models.ScanReport, error) {
+	manifest, err := s.imageService.GetImageManifest(name, digest)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s@%s", ErrImageNotFound, name, digest)
+	}
+
+	start := time.Now()
+	report := &models.ScanReport{
+		Digest:          digest,
+		Name:            name,
+		Reference:       reference,
+		Status:          models.ScanStatusCompleted,
+		ScannedAt:       start.UTC(),
+		Packages:        []models.Package{},
+		Vulnerabilities: []models.VulnerabilityMatch{},
+	}
+	inventory, err := buildInventory(s.imageService, manifest, s.maxFileSize)
+	if err != nil {
+		report.Status = models.ScanStatusFailed
+		report.Error = err.Error()
+	} else {
+		report.Packages, report.OS = inventory.packages()
+	}
+	s.match(report)
+
+	if err := s.saveReport(report); err != nil {
+		return nil, err
+	}
+	s.log.WithFields(logrus.Fields{
+		"name":            name,
+		"reference":       reference,
+		"digest":          digest,
+		"packages":        len(report.Packages),
+		"vulnerabilities": report.Summary.Total,
+		"duration":        time.Since(start).String(),
+	}).Info("Image scanned")
+	return report, nil
+}
+
+// match matches the packages of a report against the current database
+func (s *ScanService) match(report *models.ScanReport) {
+	report.DatabaseVersion = s.db.Version()
+	report.Vulnerabilities = s.db.Match(report.Packages)
+	report.Summary = models.VulnerabilitySummary{}
+	for _, vulnerability := range report.Vulnerabilities {
+		report.Summary.Add(vulnerability.Severity)
+	}
+}
+
+// indexReport merges the reports of the platform images of an index; rescan scans them again
+func (s *ScanService) indexReport(name, reference, digest string, data []byte, rescan bool) (*models.ScanReport, error) {
+	var index models.OCIIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidManifest, err)
+	}
+
+	report := &models.ScanReport{
+		Digest:          digest,
+		Name:            name,
+		Reference:       reference,
+		Status:          models.ScanStatusCompleted,
+		DatabaseVersion: s.db.Version(),
+		Packages:        []models.Package{},
+		Vulnerabilities: []models.VulnerabilityMatch{},
+		Platforms:       []models.PlatformScan{},
+	}
+	seen := make(map[string]bool)
+	scanned := 0
+	for _, descriptor := range index.Manifests {
+		platform := models.PlatformInfo{}
+		if descriptor.Platform != nil {
+			platform.OS = descriptor.Platform.OS
+			platform.Architecture = descriptor.Platform.Architecture
+			platform.Variant = descriptor.Platform.Variant
+		}
+		// Les attestations (buildx) sont déclarées avec la plateforme unknown/unknown
+		if platform.OS == "unknown" {
+			continue
+		}
+
+		var child *models.ScanReport
+		var err error
+		if rescan {
+			child, err = s.scanDigest(name, descriptor.Digest, descriptor.Digest)
+		} else {
+			child, err = s.GetReport(name, descriptor.Digest)
+		}
+		entry := models.PlatformScan{Platform: platform.String(), Digest: descriptor.Digest}
+		if err == nil {
+			entry.Status = child.Status
+			entry.Summary = child.Summary
+			scanned++
+			if child.ScannedAt.After(report.ScannedAt) {
+				report.ScannedAt = child.ScannedAt
+			}
+			if report.OS == "" {
+				report.OS = child.OS
+			}
+			for _, vulnerability := range child.Vulnerabilities {
+				key := vulnerability.ID + "|" + vulnerability.Package + "|" + vulnerability.Version
+				if !seen[key] {
+					seen[key] = true
+					report.Vulnerabilities = append(report.Vulnerabilities, vulnerability)
+					report.Summary.Add(vulnerability.Severity)
+				}
+			}
+		}
+		report.Platforms = append(report.Platforms, entry)
+	}
+	if scanned == 0 {
+		return nil, ErrScanNotFound
+	}
+	sort.SliceStable(report.Vulnerabilities, func(i, j int) bool {
+		return severityRank(report.Vulnerabilities[i].Severity) < severityRank(report.Vulnerabilities[j].Severity)
+	})
+	return report, nil
+}
+
+func (s *ScanService) reportPath(digest string) (string, error) {
+	if !digestPattern.MatchString(digest) {
+		return "", fmt.Errorf("invalid digest %q", digest)
+	}
+	return filepath.Join(s.dir, strings.Replace(digest, ":", "_", 1)+".json"), nil
+}
+
+func (s *ScanService) loadReport(digest string) (*models.ScanReport, error) {
+	reportPath, err := s.reportPath(digest)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(reportPath)
+	if os.IsNotExist(err) {
+		return nil, ErrScanNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var report models.ScanReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("❌ failed to parse scan report: %w", err)
+	}
+	return &report, nil
+}
+
+func (s *ScanService) saveReport(report *models.ScanReport) error {
+	reportPath, err := s.reportPath(report.Digest)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("❌ failed to marshal scan report: %w", err)
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("❌ failed to create scans directory: %w", err)
+	}
+	tmp := reportPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("❌ failed to write scan report: %w", err)
+	}
+	return os.Rename(tmp, reportPath)
+}
+
+// ChartReport lists the images deployed by a chart version (templates rendered with the default values)
+// and, for the images stored in this portal, the summary of their scan
+func (s *ScanService) ChartReport(name, version string) (*models.ChartScanReport, error) {
+	if !s.chartService.ChartExists(name, version) {
+		return nil, fmt.Errorf("%w: %s-%s", ErrChartNotFound, name, version)
+	}
+	refs, err := chartImages(s.chartService, name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.ChartScanReport{Name: name, Version: version, Images: []models.ChartImageScan{}}
+	counted := make(map[string]bool)
+	for _, ref := range refs {
+		scan := models.ChartImageScan{Image: ref}
+		imageName, reference, hosted := hostedImage(s.config, s.imageService, ref)
+		if hosted {
+			scan.Hosted = true
+			scan.Name = imageName
+			scan.Tag = reference
+			if imageReport, err := s.GetReport(imageName, reference); err == nil {
+				scannedAt := imageReport.ScannedAt
+				scan.Digest = imageReport.Digest
+				scan.Status = imageReport.Status
+				scan.Summary = &imageReport.Summary
+				scan.ScannedAt = &scannedAt
+				if !counted[imageReport.Digest] {
+					counted[imageReport.Digest] = true
+					for _, vulnerability := range imageReport.Vulnerabilities {
+						report.Summary.Add(vulnerability.Severity)
+					}
+				}
+			}
+		}
+		report.Images = append(report.Images, scan)
+	}
+	return report, nil
+}
+
+// chartImages lists the images of the templates of a chart version, rendered with the default values
+func chartImages(chartService interfaces.ChartServiceInterface, name, version string) ([]string, error) {
+	rendered, err := chartService.RenderChart(name, version, models.RenderOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	images := make(map[string]bool)
+	for _, template := range rendered.Templates {
+		for _, match := range imageFieldPattern.FindAllStringSubmatch(template.Content, -1) {
+			images[match[1]] = true
+		}
+	}
+	refs := make([]string, 0, len(images))
+	for ref := range images {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	return refs, nil
+}
+
+// hostedImage resolves an image reference ("registry/name:tag", "name@sha256:...") to an image stored here.
+// A registry host must be one of charts.dependencies.hostedRepositories, when the list is set.
+func hostedImage(cfg *config.Config, imageService interfaces.ImageServiceInterface, ref string) (string, string, bool) {
+	name, reference := ref, "latest"
+	if at := strings.Index(ref, "@"); at >= 0 {
+		name, reference = ref[:at], ref[at+1:]
+	} else if colon := strings.LastIndex(ref, ":"); colon > strings.LastIndex(ref, "/") {
+		name, reference = ref[:colon], ref[colon+1:]
+	}
+
+	if first, rest, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		if !isHostedRegistry(cfg, first) {
+			return "", "", false
+		}
+		name = rest
+	}
+	return name, reference, imageService.ImageExists(name, reference)
+}
+
+func isHostedRegistry(cfg *config.Config, host string) bool {
+	hosted := cfg.Charts.Dependencies.HostedRepositories
+	if len(hosted) == 0 {
+		return true
+	}
+	for _, repository := range hosted {
+		if u, err := url.Parse(repository); err == nil && strings.EqualFold(u.Host, host) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+// pkg/services/schema.go
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"helm-portal/pkg/models"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// ErrSchemaNotFound is returned when a chart has no values.schema.json
+var ErrSchemaNotFound = errors.New("chart has no values.schema.json")
+
+var schemaPrinter = message.NewPrinter(language.English)
+
+// GetValuesSchema returns the values.schema.json of a stored chart
+func (s *ChartService) GetValuesSchema(chartName string, version string) ([]byte, error) {
+	chrt, err := s.loadChart(chartName, version)
+	if err != nil {
+		return nil, err
+	}
+	if len(chrt.Schema) == 0 {
+		return nil, ErrSchemaNotFound
+	}
+	return chrt.Schema, nil
+}
+
+// ValidateValues validates user-supplied values against the schema of a stored chart.
+// As with helm install, the values are merged with the chart defaults before validation.
+func (s *ChartService) ValidateValues(chartName string, version string, values string) (*models.ValuesValidationResult, error) {
+	chrt, err := s.loadChart(chartName, version)
+	if err != nil {
+		return nil, err
+	}
+
+	userValues, err := chartutil.ReadValues([]byte(values))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidValues, err)
+	}
+
+	result := &models.ValuesValidationResult{
+		Valid:     true,
+		HasSchema: len(chrt.Schema) > 0,
+		Errors:    []models.ValuesError{},
+	}
+	if !result.HasSchema {
+		return result, nil
+	}
+
+	merged, err := chartutil.CoalesceValues(chrt, userValues)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidValues, err)
+	}
+
+	result.Errors, err = validateAgainstSchema(chrt.Schema, merged.AsMap())
+	if err != nil {
+		return nil, err
+	}
+	result.Valid = len(result.Errors) == 0
+	return result, nil
+}
+
+// loadChart loads a stored chart with the Helm loader
+func (s *ChartService) loadChart(chartName string, version string) (*chart.Chart, error) {
+	if !s.ChartExists(chartName, version) {
+		return nil, fmt.Errorf("%w: %s-%s", ErrChartNotFound, chartName, version)
+	}
+	chartData, err := s.GetChart(chartName, version)
+	if err != nil {
+		return nil, err
+	}
+	chrt, err := loader.LoadArchive(bytes.NewReader(chartData))
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to load chart: %w", err)
+	}
+	return chrt, nil
+}
+
+// validateAgainstSchema returns one error per failing leaf of the schema
+func validateAgainstSchema(schemaData []byte, values map[string]interface{}) ([]models.ValuesError, error) {
+	schema, err := jsonschema.UnmarshalJSON(bytes.NewReader(schemaData))
+	if err != nil {
+		return nil, fmt.Errorf("❌ invalid values.schema.json: %w", err)
+	}
+	compiler := newSchemaCompiler()
+	if err := compiler.AddResource("values.schema.json", schema); err != nil {
+		return nil, fmt.Errorf("❌ invalid values.schema.json: %w", err)
+	}
+	compiled, err := compiler.Compile("values.schema.json")
+	if err != nil {
+		return nil, fmt.Errorf("❌ invalid values.schema.json: %w", err)
+	}
+
+	// Round-trip through JSON so the values only contain JSON types
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidValues, err)
+	}
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidValues, err)
+	}
+
+	errs := []models.ValuesError{}
+	var validationErr *jsonschema.ValidationError
+	if err := compiled.Validate(instance); err != nil {
+		if !errors.As(err, &validationErr) {
+			return nil, err
+		}
+		collectSchemaErrors(validationErr, &errs)
+	}
+
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Path < errs[j].Path })
+	return errs, nil
+}
+
+func collectSchemaErrors(err *jsonschema.ValidationError, out *[]models.ValuesError) {
+	if len(err.Causes) == 0 {
+		path := ""
+		if len(err.InstanceLocation) > 0 {
+			path = "/" + strings.Join(err.InstanceLocation, "/")
+		}
+		*out = append(*out, models.ValuesError{
+			Path:    path,
+			Message: err.ErrorKind.LocalizedString(schemaPrinter),
+		})
+		return
+	}
+	for _, cause := range err.Causes {
+		collectSchemaErrors(cause, out)
+	}
+}
@@ -0,0 +1,508 @@
+// pkg/services/search.go
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"helm-portal/pkg/interfaces"
+	"helm-portal/pkg/models"
+	utils "helm-portal/pkg/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrEmptyQuery is returned when a search has no term
+	ErrEmptyQuery = errors.New("search query is empty")
+	// ErrInvalidSearchType is returned for an unknown artifact type filter
+	ErrInvalidSearchType = errors.New("invalid artifact type")
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// Searchable fields and their weight in the score
+const (
+	fieldName        = "name"
+	fieldVersion     = "version"
+	fieldTag         = "tag"
+	fieldDescription = "description"
+	fieldKeywords    = "keywords"
+	fieldMaintainers = "maintainers"
+	fieldAppVersion  = "appVersion"
+	fieldLabels      = "labels"
+	fieldAnnotations = "annotations"
+)
+
+var fieldWeights = map[string]float64{
+	fieldName:     3,
+	fieldKeywords: 2,
+	fieldTag:      2,
+	fieldVersion:  2,
+}
+
+// Match kinds, from the most to the least relevant
+const (
+	scoreExact  = 3
+	scorePrefix = 2
+	scoreFuzzy  = 1
+)
+
+// searchDoc is one chart version or one image tag in the index
+type searchDoc struct {
+	artifactType models.ArtifactType
+	name         string
+	reference    string
+	description  string
+	fields       map[string][]string // field -> lowercased tokens
+}
+
+// SearchService keeps an in-memory full-text index of the charts and images.
+// It subscribes to the chart and image services to stay in sync with pushes and deletes.
+type SearchService struct {
+	chartService interfaces.ChartServiceInterface
+	imageService interfaces.ImageServiceInterface
+	log          *utils.Logger
+
+	mu   sync.RWMutex
+	docs map[string]*searchDoc
+}
+
+// NewSearchService creates a search service. Call Rebuild to index the existing artifacts.
+func NewSearchService(chartService interfaces.ChartServiceInterface, imageService interfaces.ImageServiceInterface, log *utils.Logger) *SearchService {
+	return &SearchService{
+		chartService: chartService,
+		imageService: imageService,
+		log:          log,
+		docs:         make(map[string]*searchDoc),
+	}
+}
+
+func searchDocKey(artifactType models.ArtifactType, name, reference string) string {
+	return fmt.Sprintf("%s|%s|%s", artifactType, name, reference)
+}
+
+// Rebuild replaces the index with every chart version and image tag currently stored
+func (s *SearchService) Rebuild() error {
+	docs := make(map[string]*searchDoc)
+
+	if s.chartService != nil {
+		groups, err := s.chartService.ListCharts()
+		if err != nil {
+			return fmt.Errorf("failed to list charts: %w", err)
+		}
+		for _, group := range groups {
+			for i := range group.Versions {
+				if doc := chartSearchDoc(&group.Versions[i]); doc != nil {
+					docs[searchDocKey(doc.artifactType, doc.name, doc.reference)] = doc
+				}
+			}
+		}
+	}
+
+	if s.imageService != nil {
+		groups, err := s.imageService.ListImages()
+		if err != nil {
+			return fmt.Errorf("failed to list images: %w", err)
+		}
+		for _, group := range groups {
+			for i := range group.Tags {
+				doc := s.imageSearchDoc(&group.Tags[i])
+				if doc != nil {
+					docs[searchDocKey(doc.artifactType, doc.name, doc.reference)] = doc
+				}
+			}
+		}
+	}
+
+	s.mu.Lock()
+	s.docs = docs
+	s.mu.Unlock()
+
+	s.log.WithField("documents", len(docs)).Info("Search index rebuilt")
+	return nil
+}
+
+// OnArtifactEvent keeps the index in sync with the chart and image services
+func (s *SearchService) OnArtifactEvent(event models.ArtifactEvent) {
+	key := searchDocKey(event.Type, event.Name, event.Reference)
+
+	var doc *searchDoc
+	if event.Action != models.EventDelete {
+		doc = s.loadDoc(event)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if doc == nil {
+		delete(s.docs, key)
+		return
+	}
+	s.docs[key] = doc
+}
+
+// loadDoc reads the artifact of an event. It returns nil when the artifact must not be indexed.
+func (s *SearchService) loadDoc(event models.ArtifactEvent) *searchDoc {
+	logger := s.log.WithFields(logrus.Fields{
+		"type":      event.Type,
+		"name":      event.Name,
+		"reference": event.Reference,
+	})
+
+	switch event.Type {
+	case models.ArtifactTypeHelmChart:
+		if s.chartService == nil {
+			return nil
+		}
+		metadata, err := s.chartService.GetChartDetails(event.Name, event.Reference)
+		if err != nil {
+			logger.WithError(err).Warn("Failed to index chart")
+			return nil
+		}
+		return chartSearchDoc(metadata)
+	case models.ArtifactTypeDockerImage:
+		if s.imageService == nil || strings.HasPrefix(event.Reference, "sha256:") {
+			return nil
+		}
+		metadata, err := s.imageService.GetImageMetadata(event.Name, event.Reference)
+		if err != nil {
+			logger.WithError(err).Warn("Failed to index image")
+			return nil
+		}
+		return s.imageSearchDoc(metadata)
+	}
+	return nil
+}
+
+// chartSearchDoc indexes a chart version. Yanked versions are not searchable.
+func chartSearchDoc(metadata *models.ChartMetadata) *searchDoc {
+	if metadata.Yanked {
+		return nil
+	}
+
+	var maintainers []string
+	for _, m := range metadata.Maintainers {
+		maintainers = append(maintainers, m.Name, m.Email)
+	}
+
+	return &searchDoc{
+		artifactType: models.ArtifactTypeHelmChart,
+		name:         metadata.Name,
+		reference:    metadata.Version,
+		description:  metadata.Description,
+		fields: map[string][]string{
+			fieldName:        tokenize(metadata.Name),
+			fieldVersion:     tokenize(metadata.Version),
+			fieldDescription: tokenize(metadata.Description),
+			fieldKeywords:    tokenize(metadata.Keywords...),
+			fieldMaintainers: tokenize(maintainers...),
+			fieldAppVersion:  tokenize(metadata.AppVersion),
+		},
+	}
+}
+
+// imageSearchDoc indexes an image tag with its config labels and manifest annotations
+func (s *SearchService) imageSearchDoc(metadata *models.ImageMetadata) *searchDoc {
+	if metadata.Tag == "" || strings.HasPrefix(metadata.Tag, "sha256:") {
+		return nil
+	}
+
+	var labels []string
+	description := ""
+	if metadata.Config != nil && metadata.Config.Config != nil {
+		for key, value := range metadata.Config.Config.Labels {
+			labels = append(labels, key, value)
+		}
+		description = metadata.Config.Config.Labels["org.opencontainers.image.description"]
+	}
+
+	var annotations []string
+	if manifest, err := s.imageService.GetImageManifest(metadata.Name, metadata.Tag); err == nil {
+		for key, value := range manifest.Annotations {
+			annotations = append(annotations, key, value)
+		}
+		if description == "" {
+			description = manifest.Annotations["org.opencontainers.image.description"]
+		}
+	}
+
+	return &searchDoc{
+		artifactType: models.ArtifactTypeDockerImage,
+		name:         metadata.Name,
+		reference:    metadata.Tag,
+		description:  description,
+		fields: map[string][]string{
+			fieldName:        tokenize(metadata.Name),
+			fieldTag:         tokenize(metadata.Tag),
+			fieldLabels:      tokenize(labels...),
+			fieldAnnotations: tokenize(annotations...),
+		},
+	}
+}
+
+// tokenize lowercases values and splits them into words.
+// Values without spaces (names, label keys, URLs) are also kept whole,
+// so that "org.opencontainers.image.source" matches as a single term.
+func tokenize(values ...string) []string {
+	seen := make(map[string]bool)
+	var tokens []string
+	add := func(token string) {
+		if token != "" && !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		for _, word := range strings.Fields(value) {
+			add(strings.TrimFunc(word, isSeparator))
+			for _, part := range strings.FieldsFunc(word, isSeparator) {
+				add(part)
+			}
+		}
+	}
+	return tokens
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// ParseArtifactType converts a search filter ("chart", "image", "helm", "docker") to an artifact type.
+// An empty filter returns an empty type, meaning all artifacts.
+func ParseArtifactType(value string) (models.ArtifactType, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "":
+		return "", nil
+	case "chart", "charts", string(models.ArtifactTypeHelmChart):
+		return models.ArtifactTypeHelmChart, nil
+	case "image", "images", string(models.ArtifactTypeDockerImage):
+		return models.ArtifactTypeDockerImage, nil
+	}
+	return "", fmt.Errorf("%w: %q (expected chart or image)", ErrInvalidSearchType, value)
+}
+
+// Search returns the charts and image repositories matching every term of the query.
+// Terms match exactly, by prefix or approximately (typos); matches on names rank first.
+// Results are grouped by chart or repository with the matching versions or tags.
+func (s *SearchService) Search(query string, artifactType models.ArtifactType, limit int) (*models.SearchResult, error) {
+	terms := strings.Fields(strings.ToLower(query))
+	if len(terms) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	type match struct {
+		doc    *searchDoc
+		score  float64
+		fields []string
+	}
+
+	s.mu.RLock()
+	var matches []match
+	for _, doc := range s.docs {
+		if artifactType != "" && doc.artifactType != artifactType {
+			continue
+		}
+		if score, fields, ok := scoreDoc(doc, terms); ok {
+			matches = append(matches, match{doc: doc, score: score, fields: fields})
+		}
+	}
+	s.mu.RUnlock()
+
+	// Regrouper les versions d'un même chart ou les tags d'une même image
+	hits := make(map[string]*models.SearchHit)
+	best := make(map[string]string) // reference of the newest matching version
+	for _, m := range matches {
+		key := string(m.doc.artifactType) + "|" + m.doc.name
+		hit, ok := hits[key]
+		if !ok {
+			hit = &models.SearchHit{Type: m.doc.artifactType, Name: m.doc.name}
+			hits[key] = hit
+		}
+		hit.Versions = append(hit.Versions, m.doc.reference)
+		hit.Fields = mergeFields(hit.Fields, m.fields)
+		if m.score > hit.Score {
+			hit.Score = m.score
+		}
+		if ref, ok := best[key]; !ok || models.CompareVersions(m.doc.reference, ref) > 0 {
+			best[key] = m.doc.reference
+			hit.Description = m.doc.description
+		}
+	}
+
+	result := &models.SearchResult{
+		Query: query,
+		Type:  string(artifactType),
+		Total: len(hits),
+		Hits:  make([]models.SearchHit, 0, len(hits)),
+	}
+	for _, hit := range hits {
+		sort.SliceStable(hit.Versions, func(i, j int) bool {
+			return models.CompareVersions(hit.Versions[i], hit.Versions[j]) > 0
+		})
+		result.Hits = append(result.Hits, *hit)
+	}
+	sort.Slice(result.Hits, func(i, j int) bool {
+		a, b := result.Hits[i], result.Hits[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Type < b.Type
+	})
+	if len(result.Hits) > limit {
+		result.Hits = result.Hits[:limit]
+	}
+	return result, nil
+}
+
+// scoreDoc returns the score of a document and the fields that matched.
+// Every term must match at least one field.
+func scoreDoc(doc *searchDoc, terms []string) (float64, []string, bool) {
+	var total float64
+	var fields []string
+	for _, term := range terms {
+		termScore := 0.0
+		var termFields []string
+		for field, tokens := range doc.fields {
+			kind := matchTerm(term, tokens)
+			if kind == 0 {
+				continue
+			}
+			termFields = append(termFields, field)
+			weight := fieldWeights[field]
+			if weight == 0 {
+				weight = 1
+			}
+			if score := float64(kind) * weight; score > termScore {
+				termScore = score
+			}
+		}
+		if termScore == 0 {
+			return 0, nil, false
+		}
+		total += termScore
+		fields = mergeFields(fields, termFields)
+	}
+	return total, fields, true
+}
+
+// matchTerm matches a query term against the tokens of a field.
+// A term with separators ("acme/backend") also matches when each of its parts does.
+func matchTerm(term string, tokens []string) int {
+	if kind := matchTokens(term, tokens); kind != 0 {
+		return kind
+	}
+	parts := strings.FieldsFunc(term, isSeparator)
+	if len(parts) < 2 {
+		return 0
+	}
+	kind := scoreExact
+	for _, part := range parts {
+		kind = min(kind, matchTokens(part, tokens))
+		if kind == 0 {
+			return 0
+		}
+	}
+	return kind
+}
+
+// matchTokens returns the best match kind of a term against tokens, or 0
+func matchTokens(term string, tokens []string) int {
+	best := 0
+	maxDistance := fuzzyDistance(term)
+	for _, token := range tokens {
+		switch {
+		case token == term:
+			return scoreExact
+		case strings.HasPrefix(token, term):
+			best = max(best, scorePrefix)
+		case best < scoreFuzzy && maxDistance > 0 && withinDistance(term, token, maxDistance):
+			best = scoreFuzzy
+		}
+	}
+	return best
+}
+
+// fuzzyDistance is the number of typos tolerated for a term: none for short terms
+func fuzzyDistance(term string) int {
+	switch n := len([]rune(term)); {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	}
+	return 0
+}
+
+// withinDistance reports whether the Levenshtein distance between a and b is at most maxDistance
+func withinDistance(a, b string, maxDistance int) bool {
+	ra, rb := []rune(a), []rune(b)
+	if abs(len(ra)-len(rb)) > maxDistance {
+		return false
+	}
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, curr[j])
+		}
+		if rowMin > maxDistance {
+			return false
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)] <= maxDistance
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// mergeFields adds the fields missing from dst, keeping them sorted
+func mergeFields(dst, fields []string) []string {
+	for _, field := range fields {
+		found := false
+		for _, existing := range dst {
+			if existing == field {
+				found = true
+				break
+			}
+		}
+		if !found {
+			dst = append(dst, field)
+		}
+	}
+	sort.Strings(dst)
+	return dst
+}
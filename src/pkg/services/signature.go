@@ -0,0 +1,613 @@
+// pkg/services/signature.go
+package service
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"helm-portal/config"
+	"helm-portal/pkg/interfaces"
+	"helm-portal/pkg/models"
+	utils "helm-portal/pkg/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrUnsignedManifest is returned when pulling a manifest without verified signature from an enforced repository
+var ErrUnsignedManifest = errors.New("manifest has no verified signature")
+
+// verificationKey is a cosign public key from the configuration
+type verificationKey struct {
+	name string
+	key  crypto.PublicKey
+}
+
+// SignatureService verifies the cosign signatures (sha256-<hex>.sig tags or referrers) of manifests
+// against the configured public keys, and their Notation signatures against the configured root certificates.
+// Results are cached until the repository changes.
+type SignatureService struct {
+	pathManager  *utils.PathManager
+	chartService interfaces.ChartServiceInterface
+	imageService interfaces.ImageServiceInterface
+	keys         []verificationKey
+	roots        *x509.CertPool
+	hasRoots     bool
+	enforce      []string
+	namespace    string
+	log          *utils.Logger
+	mu           sync.Mutex
+	cache        map[string]*models.SignatureStatus
+	generation   uint64
+}
+
+// NewSignatureService loads the keys and certificates of the signatures configuration.
+// Unreadable keys and certificates are ignored with a warning.
+func NewSignatureService(cfg *config.Config, pathManager *utils.PathManager, chartService interfaces.ChartServiceInterface, imageService interfaces.ImageServiceInterface, log *utils.Logger) *SignatureService {
+	s := &SignatureService{
+		pathManager:  pathManager,
+		chartService: chartService,
+		imageService: imageService,
+		roots:        x509.NewCertPool(),
+		log:          log,
+		cache:        make(map[string]*models.SignatureStatus),
+	}
+
+	for _, k := range cfg.Signatures.Keys {
+		key, err := loadPublicKey(k.Path)
+		if err != nil {
+			log.WithError(err).WithField("path", k.Path).Warn("⚠️ Ignoring cosign public key")
+			continue
+		}
+		name := k.Name
+		if name == "" {
+			name = filepath.Base(k.Path)
+		}
+		s.keys = append(s.keys, verificationKey{name: name, key: key})
+	}
+
+	for _, certPath := range cfg.Signatures.NotationCertificates {
+		certs, err := loadCertificates(certPath)
+		if err != nil {
+			log.WithError(err).WithField("path", certPath).Warn("⚠️ Ignoring Notation certificate")
+			continue
+		}
+		for _, cert := range certs {
+			s.roots.AddCert(cert)
+		}
+		s.hasRoots = true
+	}
+
+	for _, pattern := range cfg.Signatures.Enforce {
+		if _, err := path.Match(pattern, ""); err != nil {
+			log.WithError(err).WithField("pattern", pattern).Warn("Ignoring signature enforcement with invalid pattern")
+			continue
+		}
+		s.enforce = append(s.enforce, pattern)
+	}
+	if len(s.enforce) > 0 && len(s.keys) == 0 && !s.hasRoots {
+		log.WithField("enforce", s.enforce).Warn("⚠️ Signatures enforced without keys or certificates, every pull of these repositories will be refused")
+	}
+
+	return s
+}
+
+func loadPublicKey(keyPath string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", key)
+}
+
+func loadCertificates(certPath string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, err
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate found")
+	}
+	return certs, nil
+}
+
+// WithNamespace sets the OCI namespace of the isolated repository whose artifacts are verified,
+// so that the enforced patterns apply to its Helm downloads as to its OCI pulls
+func (s *SignatureService) WithNamespace(namespace string) *SignatureService {
+	s.namespace = namespace
+	return s
+}
+
+// Enforced tells whether the manifests of a repository must have a verified signature to be pulled
+func (s *SignatureService) Enforced(repository string) bool {
+	for _, pattern := range s.enforce {
+		if matched, _ := path.Match(pattern, repository); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// OnArtifactEvent forgets the verifications of a repository when it changes (new signature, deleted tag...)
+func (s *SignatureService) OnArtifactEvent(event models.ArtifactEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.cache {
+		if strings.HasPrefix(key, event.Name+"@") {
+			delete(s.cache, key)
+		}
+	}
+	s.generation++
+}
+
+// ImageStatus returns the signature status of an image tag or digest
+func (s *SignatureService) ImageStatus(name, reference string) (*models.SignatureStatus, error) {
+	_, digest, _, err := readImageManifest(s.imageService, name, reference)
+	if err != nil {
+		return nil, err
+	}
+	return s.Verify(name, digest), nil
+}
+
+// ChartStatus returns the signature status of a chart version. A chart without OCI manifest
+// (uploaded through the HTTP API and never pulled with helm) cannot have been signed.
+func (s *SignatureService) ChartStatus(name, version string) (*models.SignatureStatus, error) {
+	if !s.chartService.ChartExists(name, version) {
+		return nil, fmt.Errorf("%w: %s-%s", ErrChartNotFound, name, version)
+	}
+	data, err := os.ReadFile(s.pathManager.GetManifestPath(name, version))
+	if err != nil {
+		return &models.SignatureStatus{
+			Status:     models.SignatureStatusUnsigned,
+			Signatures: []models.Signature{},
+			CheckedAt:  time.Now().UTC(),
+		}, nil
+	}
+	return s.Verify(name, fmt.Sprintf("sha256:%x", sha256.Sum256(data))), nil
+}
+
+// CheckPull returns ErrUnsignedManifest unless the pulled manifest has a verified signature,
+// belongs to a signed multi-platform index, or is itself a signature, SBOM or attestation
+func (s *SignatureService) CheckPull(name, reference string, data []byte) error {
+	if isSupplyChainArtifact(data) {
+		return nil
+	}
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+	if s.Verify(name, digest).Verified() || s.inSignedIndex(name, digest) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s@%s", ErrUnsignedManifest, name, digest)
+}
+
+// CheckChartDownload returns ErrUnsignedManifest when a chart version of an enforced repository,
+// downloaded through the Helm HTTP API, has no OCI manifest with a verified signature
+func (s *SignatureService) CheckChartDownload(name, version string) error {
+	repository := name
+	if s.namespace != "" {
+		repository = s.namespace + "/" + name
+	}
+	if !s.Enforced(repository) {
+		return nil
+	}
+	status, err := s.ChartStatus(name, version)
+	if err != nil {
+		return err
+	}
+	if !status.Verified() {
+		return fmt.Errorf("%w: %s-%s", ErrUnsignedManifest, name, version)
+	}
+	return nil
+}
+
+// supplyChainMediaTypes are the media types of signatures, SBOMs and attestations
+var supplyChainMediaTypes = map[string]bool{
+	models.MediaTypeCosignSignature:     true,
+	models.MediaTypeCosignSimpleSigning: true,
+	models.MediaTypeNotationSignature:   true,
+	models.MediaTypeSPDX:                true,
+	models.MediaTypeCycloneDX:           true,
+	models.MediaTypeInToto:              true,
+	models.MediaTypeDSSE:                true,
+}
+
+// isSupplyChainArtifact tells whether a manifest is a signature, SBOM or attestation: its artifactType
+// or config media type says so or, for the legacy cosign tags whose config is a plain image config,
+// all its layers do. A tag or a subject alone proves nothing: any image can be pushed with them.
+func isSupplyChainArtifact(data []byte) bool {
+	var manifest models.OCIManifest
+	if json.Unmarshal(data, &manifest) != nil {
+		return false
+	}
+	if supplyChainMediaTypes[manifest.ArtifactType] || supplyChainMediaTypes[manifest.Config.MediaType] {
+		return true
+	}
+	if len(manifest.Layers) == 0 {
+		return false
+	}
+	for _, layer := range manifest.Layers {
+		if !supplyChainMediaTypes[layer.MediaType] {
+			return false
+		}
+	}
+	return true
+}
+
+// inSignedIndex tells whether a platform manifest is listed by a verified multi-platform index
+// of the repository: docker pulls the platform manifest by digest after the index
+func (s *SignatureService) inSignedIndex(name, digest string) bool {
+	dir := filepath.Join(s.pathManager.GetImagePath(name), "manifests")
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil || !bytes.Contains(data, []byte(digest)) {
+			continue
+		}
+		var index models.OCIIndex
+		if json.Unmarshal(data, &index) != nil {
+			continue
+		}
+		for _, m := range index.Manifests {
+			if m.Digest == digest && s.Verify(name, fmt.Sprintf("sha256:%x", sha256.Sum256(data))).Verified() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Verify returns the signature status of a manifest of a repository
+func (s *SignatureService) Verify(name, digest string) *models.SignatureStatus {
+	key := name + "@" + digest
+	s.mu.Lock()
+	if status, ok := s.cache[key]; ok {
+		s.mu.Unlock()
+		return status
+	}
+	generation := s.generation
+	s.mu.Unlock()
+
+	status := s.verify(name, digest)
+
+	// Un push arrivé pendant la vérification la rend peut-être obsolète
+	s.mu.Lock()
+	if s.generation == generation {
+		s.cache[key] = status
+	}
+	s.mu.Unlock()
+	return status
+}
+
+func (s *SignatureService) verify(name, digest string) *models.SignatureStatus {
+	status := &models.SignatureStatus{
+		Digest:     digest,
+		Status:     models.SignatureStatusUnsigned,
+		Signatures: []models.Signature{},
+		CheckedAt:  time.Now().UTC(),
+	}
+	seen := make(map[string]bool)
+
+	// Schéma historique de cosign : tag sha256-<hex>.sig
+	if data, sigDigest, _, err := readImageManifest(s.imageService, name, strings.Replace(digest, ":", "-", 1)+".sig"); err == nil {
+		seen[sigDigest] = true
+		status.Signatures = append(status.Signatures, s.verifyCosign(data, sigDigest, digest)...)
+	}
+
+	// Referrers (cosign avec OCI 1.1, Notation)
+	referrers, err := ListReferrers(s.pathManager, name, digest, "")
+	if err != nil {
+		s.log.WithError(err).WithField("name", name).Warn("Failed to list referrers")
+	}
+	for _, referrer := range referrers {
+		if seen[referrer.Digest] {
+			continue
+		}
+		switch referrer.ArtifactType {
+		case models.MediaTypeCosignSignature, models.MediaTypeNotationSignature:
+		default:
+			continue
+		}
+		data, err := s.readReferrer(name, referrer.Digest)
+		if err != nil {
+			continue
+		}
+		if referrer.ArtifactType == models.MediaTypeCosignSignature {
+			status.Signatures = append(status.Signatures, s.verifyCosign(data, referrer.Digest, digest)...)
+		} else {
+			status.Signatures = append(status.Signatures, s.verifyNotation(data, referrer.Digest, digest))
+		}
+	}
+
+	if len(status.Signatures) > 0 {
+		status.Status = models.SignatureStatusInvalid
+		for _, signature := range status.Signatures {
+			if signature.Verified {
+				status.Status = models.SignatureStatusVerified
+				break
+			}
+		}
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"name":       name,
+		"digest":     digest,
+		"status":     status.Status,
+		"signatures": len(status.Signatures),
+	}).Debug("Verified signatures")
+	return status
+}
+
+// readReferrer reads an artifact manifest stored with the charts or with the images
+func (s *SignatureService) readReferrer(name, digest string) ([]byte, error) {
+	if data, err := os.ReadFile(s.pathManager.GetManifestPath(name, digest)); err == nil {
+		return data, nil
+	}
+	data, _, _, err := readImageManifest(s.imageService, name, digest)
+	return data, err
+}
+
+// verifyCosign checks the signatures of a cosign signature manifest: each layer is a simple signing
+// payload naming the signed manifest, its signature is in the dev.cosignproject.cosign/signature annotation
+func (s *SignatureService) verifyCosign(data []byte, sigDigest, digest string) []models.Signature {
+	var manifest models.OCIManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return []models.Signature{{Type: models.SignatureTypeCosign, Digest: sigDigest, Error: "invalid signature manifest"}}
+	}
+
+	var signatures []models.Signature
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != models.MediaTypeCosignSimpleSigning {
+			continue
+		}
+		signature := models.Signature{Type: models.SignatureTypeCosign, Digest: sigDigest}
+		if signer, err := s.verifyCosignLayer(layer, digest); err != nil {
+			signature.Error = err.Error()
+		} else {
+			signature.Signer = signer
+			signature.Verified = true
+		}
+		signatures = append(signatures, signature)
+	}
+	if len(signatures) == 0 {
+		return []models.Signature{{Type: models.SignatureTypeCosign, Digest: sigDigest, Error: "no signature in manifest"}}
+	}
+	return signatures
+}
+
+func (s *SignatureService) verifyCosignLayer(layer models.OCIDescriptor, digest string) (string, error) {
+	payload, err := os.ReadFile(s.pathManager.GetBlobPath(layer.Digest))
+	if err != nil {
+		return "", errors.New("signature payload not found")
+	}
+	if fmt.Sprintf("sha256:%x", sha256.Sum256(payload)) != layer.Digest {
+		return "", errors.New("signature payload digest mismatch")
+	}
+
+	var simpleSigning struct {
+		Critical struct {
+			Image struct {
+				DockerManifestDigest string `json:"docker-manifest-digest"`
+			} `json:"image"`
+		} `json:"critical"`
+	}
+	if err := json.Unmarshal(payload, &simpleSigning); err != nil {
+		return "", errors.New("invalid signature payload")
+	}
+	if simpleSigning.Critical.Image.DockerManifestDigest != digest {
+		return "", fmt.Errorf("signature is for %s", simpleSigning.Critical.Image.DockerManifestDigest)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(layer.Annotations[models.AnnotationCosignSignature])
+	if err != nil || len(signature) == 0 {
+		return "", errors.New("missing signature annotation")
+	}
+	if len(s.keys) == 0 {
+		return "", errors.New("no cosign key configured")
+	}
+	hash := sha256.Sum256(payload)
+	for _, k := range s.keys {
+		var ok bool
+		switch key := k.key.(type) {
+		case *ecdsa.PublicKey:
+			ok = ecdsa.VerifyASN1(key, hash[:], signature)
+		case *rsa.PublicKey:
+			ok = rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature) == nil
+		case ed25519.PublicKey:
+			ok = ed25519.Verify(key, payload, signature)
+		}
+		if ok {
+			return k.name, nil
+		}
+	}
+	return "", errors.New("no configured key verifies the signature")
+}
+
+// verifyNotation checks a Notation signature (JWS envelope, notary.x509 signing scheme):
+// the certificate chain must lead to a configured root and the payload name the signed manifest
+func (s *SignatureService) verifyNotation(data []byte, sigDigest, digest string) models.Signature {
+	signature := models.Signature{Type: models.SignatureTypeNotation, Digest: sigDigest}
+	signer, err := s.verifyNotationEnvelope(data, digest)
+	if err != nil {
+		signature.Error = err.Error()
+		return signature
+	}
+	signature.Signer = signer
+	signature.Verified = true
+	return signature
+}
+
+func (s *SignatureService) verifyNotationEnvelope(data []byte, digest string) (string, error) {
+	var manifest models.OCIManifest
+	if err := json.Unmarshal(data, &manifest); err != nil || len(manifest.Layers) == 0 {
+		return "", errors.New("invalid signature manifest")
+	}
+	if manifest.Layers[0].MediaType != models.MediaTypeJWS {
+		return "", fmt.Errorf("unsupported signature envelope %s", manifest.Layers[0].MediaType)
+	}
+	blob, err := os.ReadFile(s.pathManager.GetBlobPath(manifest.Layers[0].Digest))
+	if err != nil {
+		return "", errors.New("signature envelope not found")
+	}
+
+	var envelope struct {
+		Payload   string `json:"payload"`
+		Protected string `json:"protected"`
+		Header    struct {
+			X5C []string `json:"x5c"`
+		} `json:"header"`
+		Signature string `json:"signature"`
+	}
+	if err := json.Unmarshal(blob, &envelope); err != nil || len(envelope.Header.X5C) == 0 {
+		return "", errors.New("invalid signature envelope")
+	}
+	var header struct {
+		Alg    string `json:"alg"`
+		Expiry string `json:"io.cncf.notary.expiry"`
+	}
+	if err := decodeJWSPart(envelope.Protected, &header); err != nil {
+		return "", errors.New("invalid signature header")
+	}
+	var payload struct {
+		TargetArtifact models.OCIDescriptor `json:"targetArtifact"`
+	}
+	if err := decodeJWSPart(envelope.Payload, &payload); err != nil {
+		return "", errors.New("invalid signature payload")
+	}
+	if payload.TargetArtifact.Digest != digest {
+		return "", fmt.Errorf("signature is for %s", payload.TargetArtifact.Digest)
+	}
+	if expiry, err := time.Parse(time.RFC3339, header.Expiry); err == nil && time.Now().After(expiry) {
+		return "", fmt.Errorf("signature expired on %s", header.Expiry)
+	}
+
+	var chain []*x509.Certificate
+	for _, encoded := range envelope.Header.X5C {
+		der, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return "", errors.New("invalid certificate chain")
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return "", errors.New("invalid certificate chain")
+		}
+		chain = append(chain, cert)
+	}
+	if !s.hasRoots {
+		return "", errors.New("no Notation certificate configured")
+	}
+	opts := x509.VerifyOptions{
+		Roots:         s.roots,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+	for _, cert := range chain[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	// La date de signature est déclarée par le signataire : sans horodatage d'une autorité de confiance,
+	// les certificats sont vérifiés à la date courante
+	if _, err := chain[0].Verify(opts); err != nil {
+		return "", fmt.Errorf("untrusted certificate: %v", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(envelope.Signature)
+	if err != nil {
+		return "", errors.New("invalid signature encoding")
+	}
+	if err := verifyJWS(header.Alg, chain[0].PublicKey, []byte(envelope.Protected+"."+envelope.Payload), signature); err != nil {
+		return "", err
+	}
+	return chain[0].Subject.String(), nil
+}
+
+func decodeJWSPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// verifyJWS checks a JWS signature with the algorithms allowed by Notation (PS256/384/512, ES256/384/512)
+func verifyJWS(alg string, publicKey crypto.PublicKey, input, signature []byte) error {
+	var hash crypto.Hash
+	switch strings.TrimLeft(alg, "PSE") {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported signature algorithm %q", alg)
+	}
+	hasher := hash.New()
+	hasher.Write(input)
+	sum := hasher.Sum(nil)
+
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		if strings.HasPrefix(alg, "PS") && rsa.VerifyPSS(key, hash, sum, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil {
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		// JWS : r et s concaténés, de la taille de la courbe
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if strings.HasPrefix(alg, "ES") && len(signature) == 2*size {
+			r := new(big.Int).SetBytes(signature[:size])
+			sv := new(big.Int).SetBytes(signature[size:])
+			if ecdsa.Verify(key, sum, r, sv) {
+				return nil
+			}
+		}
+	}
+	return errors.New("signature does not match the certificate")
+}
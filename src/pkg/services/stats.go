@@ -0,0 +1,316 @@
+// pkg/services/stats.go
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"helm-portal/pkg/interfaces"
+	"helm-portal/pkg/models"
+	utils "helm-portal/pkg/utils"
+)
+
+// statsRetentionDays is how long daily pull counts are kept; totals are kept forever
+const statsRetentionDays = 90
+
+// statsDateLayout is the layout of the daily buckets (UTC)
+const statsDateLayout = "2006-01-02"
+
+// artifactPulls is the persisted form of the statistics of a chart version or image reference
+type artifactPulls struct {
+	Type       models.ArtifactType `json:"type"`
+	Name       string              `json:"name"`
+	Reference  string              `json:"reference"`
+	Total      int64               `json:"total"`
+	Daily      map[string]int64    `json:"daily"`
+	LastPulled time.Time           `json:"lastPulled"`
+	LastClient string              `json:"lastClient,omitempty"`
+}
+
+// statsFile is the content of stats/pulls.json
+type statsFile struct {
+	Artifacts map[string]*artifactPulls        `json:"artifacts"`
+	Blobs     map[string]*models.BlobPullStats `json:"blobs"`
+}
+
+// StatsService counts chart downloads, manifest pulls and blob pulls.
+// Counts are kept in memory and written to <storage>/stats/pulls.json by Flush.
+type StatsService struct {
+	chartService interfaces.ChartServiceInterface
+	imageService interfaces.ImageServiceInterface
+	path         string
+	log          *utils.Logger
+
+	mu    sync.Mutex
+	data  statsFile
+	dirty bool
+}
+
+// NewStatsService loads the statistics stored under the base path of pathManager
+func NewStatsService(pathManager *utils.PathManager, chartService interfaces.ChartServiceInterface, imageService interfaces.ImageServiceInterface, log *utils.Logger) *StatsService {
+	s := &StatsService{
+		chartService: chartService,
+		imageService: imageService,
+		path:         filepath.Join(pathManager.GetBasePath(), "stats", "pulls.json"),
+		log:          log,
+		data: statsFile{
+			Artifacts: make(map[string]*artifactPulls),
+			Blobs:     make(map[string]*models.BlobPullStats),
+		},
+	}
+
+	content, err := os.ReadFile(s.path)
+	if err == nil {
+		err = json.Unmarshal(content, &s.data)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		log.WithError(err).Warn("⚠️ Failed to load pull statistics, starting from scratch")
+	}
+	if s.data.Artifacts == nil {
+		s.data.Artifacts = make(map[string]*artifactPulls)
+	}
+	if s.data.Blobs == nil {
+		s.data.Blobs = make(map[string]*models.BlobPullStats)
+	}
+	return s
+}
+
+func statsKey(artifactType models.ArtifactType, name, reference string) string {
+	return string(artifactType) + "/" + name + ":" + reference
+}
+
+// RecordPull counts a download of a chart version or a manifest pull.
+// client is the authenticated user, or the user agent. A nil service records nothing.
+func (s *StatsService) RecordPull(artifactType models.ArtifactType, name, reference, client string) {
+	if s == nil {
+		return
+	}
+	now := time.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := statsKey(artifactType, name, reference)
+	pulls, ok := s.data.Artifacts[key]
+	if !ok {
+		// Fiber réutilise les buffers des paramètres : les chaînes conservées sont copiées
+		pulls = &artifactPulls{Type: artifactType, Name: strings.Clone(name), Reference: strings.Clone(reference), Daily: make(map[string]int64)}
+		s.data.Artifacts[key] = pulls
+	}
+	pulls.Total++
+	pulls.Daily[now.Format(statsDateLayout)]++
+	pulls.LastPulled = now
+	pulls.LastClient = strings.Clone(client)
+
+	// Les compteurs journaliers au-delà de la rétention sont purgés
+	cutoff := now.AddDate(0, 0, -statsRetentionDays).Format(statsDateLayout)
+	for date := range pulls.Daily {
+		if date < cutoff {
+			delete(pulls.Daily, date)
+		}
+	}
+	s.dirty = true
+}
+
+// RecordBlobPull counts a blob download of a repository name. A nil service records nothing.
+func (s *StatsService) RecordBlobPull(name string, size int64) {
+	if s == nil {
+		return
+	}
+	now := time.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	blobs, ok := s.data.Blobs[name]
+	if !ok {
+		name = strings.Clone(name)
+		blobs = &models.BlobPullStats{Name: name}
+		s.data.Blobs[name] = blobs
+	}
+	blobs.Total++
+	blobs.Bytes += size
+	blobs.LastPulled = &now
+	s.dirty = true
+}
+
+// OnArtifactEvent forgets the statistics of deleted chart versions and image tags
+func (s *StatsService) OnArtifactEvent(event models.ArtifactEvent) {
+	if event.Action != models.EventDelete {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := statsKey(event.Type, event.Name, event.Reference)
+	if _, ok := s.data.Artifacts[key]; ok {
+		delete(s.data.Artifacts, key)
+		s.dirty = true
+	}
+}
+
+// Flush writes the statistics to disk if they changed since the last flush
+func (s *StatsService) Flush() error {
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	content, err := json.Marshal(s.data)
+	s.dirty = false
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("❌ failed to marshal pull statistics: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("❌ failed to create stats directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return fmt.Errorf("❌ failed to write pull statistics: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// StartFlusher flushes the statistics periodically in the background
+func (s *StatsService) StartFlusher(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := s.Flush(); err != nil {
+				s.log.WithError(err).Error("Failed to save pull statistics")
+			}
+		}
+	}()
+}
+
+// Get returns the statistics of a chart version or an image reference, zero if never pulled
+func (s *StatsService) Get(artifactType models.ArtifactType, name, reference string) models.PullStats {
+	now := time.Now().UTC()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.toPullStats(artifactType, name, reference, now)
+}
+
+// Report returns the statistics of the stored chart versions and image tags, plus the
+// references pulled but not listed (e.g. image digests), filtered by query
+func (s *StatsService) Report(query models.StatsQuery) (*models.StatsReport, error) {
+	type ref struct {
+		artifactType    models.ArtifactType
+		name, reference string
+	}
+	refs := make(map[string]ref)
+
+	if query.Type == "" || query.Type == models.ArtifactTypeHelmChart {
+		groups, err := s.chartService.ListCharts()
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range groups {
+			for _, version := range group.Versions {
+				refs[statsKey(models.ArtifactTypeHelmChart, group.Name, version.Version)] = ref{models.ArtifactTypeHelmChart, group.Name, version.Version}
+			}
+		}
+	}
+	if s.imageService != nil && (query.Type == "" || query.Type == models.ArtifactTypeDockerImage) {
+		groups, err := s.imageService.ListImages()
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range groups {
+			for _, tag := range group.Tags {
+				refs[statsKey(models.ArtifactTypeDockerImage, group.Name, tag.Tag)] = ref{models.ArtifactTypeDockerImage, group.Name, tag.Tag}
+			}
+		}
+	}
+
+	now := time.Now().UTC()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, pulls := range s.data.Artifacts {
+		if query.Type == "" || query.Type == pulls.Type {
+			refs[key] = ref{pulls.Type, pulls.Name, pulls.Reference}
+		}
+	}
+
+	report := &models.StatsReport{Items: []models.PullStats{}, Blobs: []models.BlobPullStats{}}
+	cutoff := now.AddDate(0, 0, -query.UnusedDays)
+	for _, r := range refs {
+		if query.Name != "" && r.name != query.Name {
+			continue
+		}
+		stats := s.toPullStats(r.artifactType, r.name, r.reference, now)
+		if query.UnusedDays > 0 && stats.LastPulled != nil && stats.LastPulled.After(cutoff) {
+			continue
+		}
+		report.Items = append(report.Items, stats)
+	}
+	sort.Slice(report.Items, func(i, j int) bool {
+		a, b := report.Items[i], report.Items[j]
+		if (a.LastPulled == nil) != (b.LastPulled == nil) {
+			return a.LastPulled == nil
+		}
+		if a.LastPulled != nil && !a.LastPulled.Equal(*b.LastPulled) {
+			return a.LastPulled.Before(*b.LastPulled)
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Reference < b.Reference
+	})
+
+	if query.Type == "" || query.Type == models.ArtifactTypeDockerImage {
+		for name, blobs := range s.data.Blobs {
+			if query.Name == "" || name == query.Name {
+				report.Blobs = append(report.Blobs, *blobs)
+			}
+		}
+		sort.Slice(report.Blobs, func(i, j int) bool {
+			return report.Blobs[i].Name < report.Blobs[j].Name
+		})
+	}
+	return report, nil
+}
+
+// toPullStats computes the statistics of a reference; s.mu must be held
+func (s *StatsService) toPullStats(artifactType models.ArtifactType, name, reference string, now time.Time) models.PullStats {
+	stats := models.PullStats{
+		Type:      artifactType,
+		Name:      name,
+		Reference: reference,
+		Daily:     []models.PullCount{},
+	}
+	pulls, ok := s.data.Artifacts[statsKey(artifactType, name, reference)]
+	if !ok {
+		return stats
+	}
+
+	last7 := now.AddDate(0, 0, -6).Format(statsDateLayout)
+	last30 := now.AddDate(0, 0, -29).Format(statsDateLayout)
+	for date, count := range pulls.Daily {
+		stats.Daily = append(stats.Daily, models.PullCount{Date: date, Count: count})
+		if date >= last7 {
+			stats.Last7Days += count
+		}
+		if date >= last30 {
+			stats.Last30Days += count
+		}
+	}
+	sort.Slice(stats.Daily, func(i, j int) bool {
+		return stats.Daily[i].Date < stats.Daily[j].Date
+	})
+
+	lastPulled := pulls.LastPulled
+	stats.Total = pulls.Total
+	stats.LastPulled = &lastPulled
+	stats.LastClient = pulls.LastClient
+	return stats
+}
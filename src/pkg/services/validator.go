@@ -0,0 +1,332 @@
+// pkg/services/validator.go
+package service
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"helm-portal/config"
+	"helm-portal/pkg/models"
+	utils "helm-portal/pkg/utils"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/Masterminds/sprig/v3"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// defaultMaxArchiveSize is used when no limit is configured (10 MiB)
+	defaultMaxArchiveSize int64 = 10 << 20
+	// maxDecompressedChartSize protects against archive bombs (same limit as Helm)
+	maxDecompressedChartSize int64 = 100 << 20
+)
+
+var chartNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// ChartValidationError is returned when an uploaded chart is rejected
+type ChartValidationError struct {
+	Report *models.ValidationReport
+}
+
+func (e *ChartValidationError) Error() string {
+	errs := e.Report.Errors()
+	if len(errs) == 0 {
+		return "chart validation failed"
+	}
+	return fmt.Sprintf("chart validation failed with %d error(s): %s", len(errs), errs[0].Message)
+}
+
+// ChartValidator checks chart archives before they are persisted
+type ChartValidator struct {
+	maxArchiveSize int64
+	log            *utils.Logger
+}
+
+// NewChartValidator creates a validator using the charts configuration
+func NewChartValidator(config *config.Config, log *utils.Logger) *ChartValidator {
+	maxSize := config.Charts.Validation.MaxArchiveSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxArchiveSize
+	}
+	return &ChartValidator{
+		maxArchiveSize: maxSize,
+		log:            log,
+	}
+}
+
+// Validate inspects a chart archive and returns every finding.
+// An empty filename skips the filename check.
+func (v *ChartValidator) Validate(chartData []byte, filename string) *models.ValidationReport {
+	report := &models.ValidationReport{Findings: []models.ValidationFinding{}}
+
+	if int64(len(chartData)) > v.maxArchiveSize {
+		report.Add(models.SeverityError, "archive-size", "",
+			fmt.Sprintf("archive is %d bytes, maximum allowed is %d bytes", len(chartData), v.maxArchiveSize))
+		return report
+	}
+
+	files, topDir, ok := v.readArchive(chartData, report)
+	if !ok {
+		return report
+	}
+
+	metadata := v.checkChartYAML(files, topDir, report)
+	if metadata != nil {
+		report.Name = metadata.Name
+		report.Version = metadata.Version
+		if filename != "" && metadata.Name != "" && metadata.Version != "" {
+			expected := fmt.Sprintf("%s-%s.tgz", metadata.Name, metadata.Version)
+			if filepath.Base(filename) != expected {
+				report.Add(models.SeverityError, "filename", filename,
+					fmt.Sprintf("filename must be %s to match Chart.yaml name and version", expected))
+			}
+		}
+	}
+
+	v.checkValues(files, report)
+	v.checkTemplates(files, report)
+
+	return report
+}
+
+// readArchive decompresses the chart and keeps the files of the top-level chart
+// needed by the other checks. Paths in the returned map are relative to the chart directory.
+func (v *ChartValidator) readArchive(chartData []byte, report *models.ValidationReport) (map[string][]byte, string, bool) {
+	gr, err := gzip.NewReader(bytes.NewReader(chartData))
+	if err != nil {
+		report.Add(models.SeverityError, "archive-format", "", "archive is not a valid gzip file")
+		return nil, "", false
+	}
+	defer gr.Close()
+
+	files := make(map[string][]byte)
+	topDirs := make(map[string]bool)
+	var total int64
+
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			report.Add(models.SeverityError, "archive-format", "", fmt.Sprintf("failed to read tar archive: %v", err))
+			return nil, "", false
+		}
+
+		if header.Typeflag == tar.TypeXGlobalHeader || header.Typeflag == tar.TypeXHeader {
+			continue
+		}
+
+		name := strings.TrimPrefix(header.Name, "./")
+		cleaned := path.Clean(name)
+		if path.IsAbs(name) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+			report.Add(models.SeverityError, "archive-layout", header.Name, "archive entry escapes the chart directory")
+			continue
+		}
+
+		parts := strings.SplitN(cleaned, "/", 2)
+		if len(parts) == 1 {
+			if header.Typeflag == tar.TypeDir {
+				topDirs[parts[0]] = true
+			} else {
+				report.Add(models.SeverityError, "archive-layout", header.Name, "files must live inside the chart directory")
+			}
+			continue
+		}
+		topDirs[parts[0]] = true
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		total += header.Size
+		if total > maxDecompressedChartSize {
+			report.Add(models.SeverityError, "archive-size", "",
+				fmt.Sprintf("decompressed chart exceeds %d bytes", maxDecompressedChartSize))
+			return nil, "", false
+		}
+
+		if !isValidatedFile(parts[1]) {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			report.Add(models.SeverityError, "archive-format", header.Name, fmt.Sprintf("failed to read file: %v", err))
+			return nil, "", false
+		}
+		files[parts[1]] = content
+	}
+
+	if len(topDirs) != 1 {
+		names := make([]string, 0, len(topDirs))
+		for dir := range topDirs {
+			names = append(names, dir)
+		}
+		report.Add(models.SeverityError, "archive-layout", "",
+			fmt.Sprintf("archive must contain exactly one top-level directory, found %d %v", len(topDirs), names))
+		return nil, "", false
+	}
+
+	var topDir string
+	for dir := range topDirs {
+		topDir = dir
+	}
+	return files, topDir, true
+}
+
+// isValidatedFile returns true for the chart files inspected by the validator
+func isValidatedFile(name string) bool {
+	switch name {
+	case "Chart.yaml", "values.yaml", "values.schema.json":
+		return true
+	}
+	return strings.HasPrefix(name, "templates/")
+}
+
+func (v *ChartValidator) checkChartYAML(files map[string][]byte, topDir string, report *models.ValidationReport) *models.ChartMetadata {
+	content, ok := files["Chart.yaml"]
+	if !ok {
+		report.Add(models.SeverityError, "chart-yaml", topDir+"/Chart.yaml", "Chart.yaml not found at the root of the chart directory")
+		return nil
+	}
+
+	var metadata models.ChartMetadata
+	if err := yaml.Unmarshal(content, &metadata); err != nil {
+		report.Add(models.SeverityError, "chart-yaml", "Chart.yaml", fmt.Sprintf("invalid YAML: %v", err))
+		return nil
+	}
+
+	if metadata.ApiVersion == "" {
+		report.Add(models.SeverityError, "chart-yaml", "Chart.yaml", "apiVersion is required")
+	} else if metadata.ApiVersion != "v1" && metadata.ApiVersion != "v2" {
+		report.Add(models.SeverityError, "chart-yaml", "Chart.yaml",
+			fmt.Sprintf("apiVersion %q is not supported, expected v1 or v2", metadata.ApiVersion))
+	}
+
+	if metadata.Name == "" {
+		report.Add(models.SeverityError, "chart-yaml", "Chart.yaml", "name is required")
+	} else {
+		if metadata.Name != topDir {
+			report.Add(models.SeverityError, "chart-name", "Chart.yaml",
+				fmt.Sprintf("chart name %q must match its directory name %q", metadata.Name, topDir))
+		}
+		if !chartNamePattern.MatchString(metadata.Name) {
+			report.Add(models.SeverityWarning, "chart-name", "Chart.yaml",
+				fmt.Sprintf("chart name %q should only contain lowercase letters, digits and dashes", metadata.Name))
+		}
+	}
+
+	if metadata.Version == "" {
+		report.Add(models.SeverityError, "chart-yaml", "Chart.yaml", "version is required")
+	} else if _, err := semver.StrictNewVersion(metadata.Version); err != nil {
+		report.Add(models.SeverityError, "chart-version", "Chart.yaml",
+			fmt.Sprintf("version %q is not a valid semantic version: %v", metadata.Version, err))
+	}
+
+	switch metadata.Type {
+	case "", "application", "library":
+	default:
+		report.Add(models.SeverityError, "chart-yaml", "Chart.yaml",
+			fmt.Sprintf("type %q is invalid, expected application or library", metadata.Type))
+	}
+
+	if metadata.Description == "" {
+		report.Add(models.SeverityWarning, "chart-yaml", "Chart.yaml", "description is recommended")
+	}
+
+	return &metadata
+}
+
+func (v *ChartValidator) checkValues(files map[string][]byte, report *models.ValidationReport) {
+	if content, ok := files["values.yaml"]; ok {
+		var values map[string]interface{}
+		if err := yaml.Unmarshal(content, &values); err != nil {
+			report.Add(models.SeverityError, "values-yaml", "values.yaml", fmt.Sprintf("invalid YAML: %v", err))
+		}
+	}
+
+	content, ok := files["values.schema.json"]
+	if !ok {
+		return
+	}
+
+	schema, err := jsonschema.UnmarshalJSON(bytes.NewReader(content))
+	if err != nil {
+		report.Add(models.SeverityError, "values-schema", "values.schema.json", fmt.Sprintf("invalid JSON: %v", err))
+		return
+	}
+	if _, ok := schema.(map[string]interface{}); !ok {
+		report.Add(models.SeverityError, "values-schema", "values.schema.json", "schema must be a JSON object")
+		return
+	}
+
+	compiler := newSchemaCompiler()
+	if err := compiler.AddResource("values.schema.json", schema); err != nil {
+		report.Add(models.SeverityError, "values-schema", "values.schema.json", err.Error())
+		return
+	}
+	if _, err := compiler.Compile("values.schema.json"); err != nil {
+		report.Add(models.SeverityError, "values-schema", "values.schema.json", fmt.Sprintf("invalid JSON schema: %v", err))
+	}
+}
+
+// denyLoader refuses to load the schemas referenced by a values.schema.json from outside of it:
+// a file:// $ref would read the files of the server, an http:// one reach any host
+type denyLoader struct{}
+
+func (denyLoader) Load(url string) (any, error) {
+	return nil, fmt.Errorf("loading external schema %s is not allowed", url)
+}
+
+// newSchemaCompiler returns a compiler for the values.schema.json of a chart.
+// Only references inside the schema and to the standard metaschemas are resolved.
+func newSchemaCompiler() *jsonschema.Compiler {
+	compiler := jsonschema.NewCompiler()
+	compiler.UseLoader(denyLoader{})
+	return compiler
+}
+
+func (v *ChartValidator) checkTemplates(files map[string][]byte, report *models.ValidationReport) {
+	var names []string
+	for name := range files {
+		if strings.HasPrefix(name, "templates/") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	funcs := templateFuncMap()
+	for _, name := range names {
+		if _, err := template.New(name).Funcs(funcs).Parse(string(files[name])); err != nil {
+			report.Add(models.SeverityError, "template-parse", name, err.Error())
+		}
+	}
+}
+
+// templateFuncMap returns the functions available to Helm templates. Only the
+// names matter here since templates are parsed, not executed.
+func templateFuncMap() template.FuncMap {
+	funcs := sprig.TxtFuncMap()
+	delete(funcs, "env")
+	delete(funcs, "expandenv")
+
+	stub := func(...interface{}) (string, error) { return "", nil }
+	for _, name := range []string{
+		"toToml", "fromToml", "toYaml", "toYamlPretty", "fromYaml", "fromYamlArray",
+		"toJson", "fromJson", "fromJsonArray", "include", "tpl", "required", "lookup",
+	} {
+		funcs[name] = stub
+	}
+	return funcs
+}
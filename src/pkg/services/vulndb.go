@@ -0,0 +1,960 @@
+// pkg/services/vulndb.go
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"helm-portal/pkg/models"
+	utils "helm-portal/pkg/utils"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+var (
+	// ErrInvalidDatabase is returned when an imported file is not an OSV dump
+	ErrInvalidDatabase = errors.New("invalid vulnerability database")
+	// ErrDatabasePathForbidden is returned for a server path outside scanning.databaseDir
+	ErrDatabasePathForbidden = errors.New("database path outside scanning.databaseDir")
+)
+
+// errOutsideRoot is returned by resolveUnder for a path outside its root
+var errOutsideRoot = errors.New("path outside root")
+
+// resolveUnder resolves a server path, absolute or relative to root, symbolic links included,
+// and refuses the paths outside root. An empty root allows no path.
+func resolveUnder(root, name string) (string, error) {
+	if root == "" {
+		return "", errOutsideRoot
+	}
+	rootPath, err := filepath.Abs(root)
+	if err == nil {
+		rootPath, err = filepath.EvalSymlinks(rootPath)
+	}
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(rootPath, name)
+	}
+	resolved, err := filepath.EvalSymlinks(name)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(rootPath, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errOutsideRoot
+	}
+	return resolved, nil
+}
+
+// osvEvent is an event of an OSV range; only one field is set
+type osvEvent struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+}
+
+type osvRange struct {
+	Type   string     `json:"type"` // SEMVER, ECOSYSTEM or GIT
+	Events []osvEvent `json:"events"`
+}
+
+type osvAffected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Ranges            []osvRange     `json:"ranges,omitempty"`
+	Versions          []string       `json:"versions,omitempty"`
+	EcosystemSpecific map[string]any `json:"ecosystem_specific,omitempty"`
+}
+
+type osvSeverity struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+// osvAdvisory keeps the fields of the OSV schema (https://ossf.github.io/osv-schema/) used for matching
+type osvAdvisory struct {
+	ID               string         `json:"id"`
+	Aliases          []string       `json:"aliases,omitempty"`
+	Summary          string         `json:"summary,omitempty"`
+	Withdrawn        string         `json:"withdrawn,omitempty"`
+	Severity         []osvSeverity  `json:"severity,omitempty"`
+	Affected         []osvAffected  `json:"affected"`
+	DatabaseSpecific map[string]any `json:"database_specific,omitempty"`
+}
+
+// vulnDBFile is the content of vulndb/advisories.json
+type vulnDBFile struct {
+	ImportedAt *time.Time `json:"importedAt,omitempty"`
+	Source     string     `json:"source,omitempty"`
+	// Sources associe chaque fichier importé au sha256 de son contenu
+	Sources    map[string]string `json:"sources,omitempty"`
+	Advisories []osvAdvisory     `json:"advisories"`
+}
+
+// indexedAffected is an affected package of an advisory, indexed by ecosystem and name
+type indexedAffected struct {
+	advisory *osvAdvisory
+	affected *osvAffected
+	severity string
+}
+
+// VulnerabilityDatabase holds the OSV advisories imported from local files.
+// It is stored in <storage>/vulndb/advisories.json and works without network access.
+type VulnerabilityDatabase struct {
+	path string
+	log  *utils.Logger
+
+	mu    sync.RWMutex
+	data  vulnDBFile
+	index map[string][]indexedAffected
+}
+
+// NewVulnerabilityDatabase loads the advisories stored under the base path of pathManager
+func NewVulnerabilityDatabase(pathManager *utils.PathManager, log *utils.Logger) *VulnerabilityDatabase {
+	db := &VulnerabilityDatabase{
+		path: filepath.Join(pathManager.GetBasePath(), "vulndb", "advisories.json"),
+		log:  log,
+	}
+	content, err := os.ReadFile(db.path)
+	if err == nil {
+		err = json.Unmarshal(content, &db.data)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		log.WithError(err).Warn("⚠️ Failed to load vulnerability database, import it again")
+	}
+	db.reindex()
+	return db
+}
+
+// Version identifies the imported content; reports matched with another version are outdated
+func (db *VulnerabilityDatabase) Version() string {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.version()
+}
+
+func (db *VulnerabilityDatabase) version() string {
+	if db.data.ImportedAt == nil {
+		return ""
+	}
+	return db.data.ImportedAt.UTC().Format(time.RFC3339Nano)
+}
+
+// Status describes the imported advisories
+func (db *VulnerabilityDatabase) Status() models.VulnerabilityDatabaseStatus {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	status := models.VulnerabilityDatabaseStatus{
+		Version:    db.version(),
+		ImportedAt: db.data.ImportedAt,
+		Source:     db.data.Source,
+		Advisories: len(db.data.Advisories),
+		Ecosystems: make(map[string]int),
+	}
+	for _, advisory := range db.data.Advisories {
+		seen := make(map[string]bool)
+		for _, affected := range advisory.Affected {
+			ecosystem := ecosystemBase(affected.Package.Ecosystem)
+			if !seen[ecosystem] {
+				seen[ecosystem] = true
+				status.Ecosystems[ecosystem]++
+			}
+		}
+	}
+	return status
+}
+
+// ImportFile imports an OSV dump from a file of the server, unless the same content was already imported
+func (db *VulnerabilityDatabase) ImportFile(path string, replace bool) (*models.VulnerabilityDatabaseStatus, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDatabase, err)
+	}
+	db.mu.RLock()
+	imported := db.data.Sources[path] == fmt.Sprintf("%x", sha256.Sum256(data))
+	db.mu.RUnlock()
+	if imported && !replace {
+		status := db.Status()
+		return &status, nil
+	}
+	return db.Import(data, path, replace)
+}
+
+// Import merges the advisories of an OSV dump: a zip of OSV JSON files (as published
+// per ecosystem by osv.dev), a JSON array of advisories or a single advisory.
+// An advisory replaces the one with the same ID; replace drops all the previous advisories.
+func (db *VulnerabilityDatabase) Import(data []byte, source string, replace bool) (*models.VulnerabilityDatabaseStatus, error) {
+	advisories, err := parseOSV(data)
+	if err != nil {
+		return nil, err
+	}
+
+	db.mu.Lock()
+	byID := make(map[string]osvAdvisory)
+	if !replace {
+		for _, advisory := range db.data.Advisories {
+			byID[advisory.ID] = advisory
+		}
+	}
+	for _, advisory := range advisories {
+		if advisory.Withdrawn != "" {
+			delete(byID, advisory.ID)
+			continue
+		}
+		byID[advisory.ID] = advisory
+	}
+
+	merged := make([]osvAdvisory, 0, len(byID))
+	for _, advisory := range byID {
+		merged = append(merged, advisory)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].ID < merged[j].ID })
+
+	now := time.Now().UTC()
+	sources := db.data.Sources
+	if replace || sources == nil {
+		sources = make(map[string]string)
+	}
+	sources[source] = fmt.Sprintf("%x", sha256.Sum256(data))
+	db.data = vulnDBFile{ImportedAt: &now, Source: source, Sources: sources, Advisories: merged}
+	db.reindexLocked()
+	content, err := json.Marshal(db.data)
+	db.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to marshal vulnerability database: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(db.path), 0755); err != nil {
+		return nil, fmt.Errorf("❌ failed to create vulndb directory: %w", err)
+	}
+	tmp := db.path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return nil, fmt.Errorf("❌ failed to write vulnerability database: %w", err)
+	}
+	if err := os.Rename(tmp, db.path); err != nil {
+		return nil, err
+	}
+
+	db.log.WithField("source", source).WithField("advisories", len(advisories)).Info("Vulnerability database imported")
+	status := db.Status()
+	return &status, nil
+}
+
+// parseOSV reads the advisories of a zip, a JSON array or a single JSON advisory
+func parseOSV(data []byte) ([]osvAdvisory, error) {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidDatabase, err)
+		}
+		var advisories []osvAdvisory
+		for _, file := range archive.File {
+			if file.FileInfo().IsDir() || !strings.HasSuffix(file.Name, ".json") {
+				continue
+			}
+			rc, err := file.Open()
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalidDatabase, file.Name, err)
+			}
+			var advisory osvAdvisory
+			err = json.NewDecoder(rc).Decode(&advisory)
+			rc.Close()
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalidDatabase, file.Name, err)
+			}
+			if advisory.ID != "" {
+				advisories = append(advisories, advisory)
+			}
+		}
+		return advisories, nil
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		var advisories []osvAdvisory
+		if err := json.Unmarshal(trimmed, &advisories); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidDatabase, err)
+		}
+		return advisories, nil
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		var advisory osvAdvisory
+		if err := json.Unmarshal(trimmed, &advisory); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidDatabase, err)
+		}
+		if advisory.ID == "" {
+			return nil, fmt.Errorf("%w: advisory without id", ErrInvalidDatabase)
+		}
+		return []osvAdvisory{advisory}, nil
+	}
+	return nil, fmt.Errorf("%w: expected a zip or JSON file", ErrInvalidDatabase)
+}
+
+func (db *VulnerabilityDatabase) reindex() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.reindexLocked()
+}
+
+// reindexLocked indexes the affected packages by ecosystem and name; db.mu must be held
+func (db *VulnerabilityDatabase) reindexLocked() {
+	db.index = make(map[string][]indexedAffected)
+	for i := range db.data.Advisories {
+		advisory := &db.data.Advisories[i]
+		severity := advisorySeverity(advisory)
+		for j := range advisory.Affected {
+			affected := &advisory.Affected[j]
+			key := packageKey(affected.Package.Ecosystem, affected.Package.Name)
+			db.index[key] = append(db.index[key], indexedAffected{advisory: advisory, affected: affected, severity: severity})
+		}
+	}
+}
+
+// Match returns the advisories affecting the packages, sorted by severity then ID
+func (db *VulnerabilityDatabase) Match(packages []models.Package) []models.VulnerabilityMatch {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	matches := []models.VulnerabilityMatch{}
+	seen := make(map[string]bool)
+	for _, pkg := range packages {
+		if pkg.Ecosystem == "" || pkg.Version == "" {
+			continue
+		}
+		// Les avis des distributions portent sur le paquet source
+		name := pkg.Name
+		if pkg.Source != "" {
+			name = pkg.Source
+		}
+		for _, candidate := range db.index[packageKey(pkg.Ecosystem, name)] {
+			if !ecosystemMatches(candidate.affected.Package.Ecosystem, pkg.Ecosystem) {
+				continue
+			}
+			affected, fixed := affectsVersion(candidate.affected, pkg.Ecosystem, pkg.Version)
+			if !affected {
+				continue
+			}
+			key := candidate.advisory.ID + "|" + pkg.Name + "|" + pkg.Version + "|" + pkg.Location
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			matches = append(matches, models.VulnerabilityMatch{
+				ID:           candidate.advisory.ID,
+				Aliases:      candidate.advisory.Aliases,
+				Summary:      candidate.advisory.Summary,
+				Severity:     candidate.severity,
+				Package:      pkg.Name,
+				Version:      pkg.Version,
+				Ecosystem:    pkg.Ecosystem,
+				FixedVersion: fixed,
+				Location:     pkg.Location,
+			})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if severityRank(a.Severity) != severityRank(b.Severity) {
+			return severityRank(a.Severity) < severityRank(b.Severity)
+		}
+		if a.ID != b.ID {
+			return a.ID < b.ID
+		}
+		return a.Package < b.Package
+	})
+	return matches
+}
+
+// ecosystemBase returns the ecosystem without its release, e.g. "Debian" for "Debian:12"
+func ecosystemBase(ecosystem string) string {
+	base, _, _ := strings.Cut(ecosystem, ":")
+	return base
+}
+
+// packageKey is the index key of a package; PyPI names are normalized (PEP 503)
+func packageKey(ecosystem, name string) string {
+	base := strings.ToLower(ecosystemBase(ecosystem))
+	if base == "pypi" {
+		name = strings.NewReplacer("_", "-", ".", "-").Replace(strings.ToLower(name))
+	}
+	return base + "/" + name
+}
+
+// ecosystemMatches tells if an advisory for advisoryEcosystem applies to a package of pkgEcosystem.
+// "Debian" applies to every release, "Ubuntu:22.04:LTS" to "Ubuntu:22.04".
+func ecosystemMatches(advisoryEcosystem, pkgEcosystem string) bool {
+	advisory, pkg := strings.ToLower(advisoryEcosystem), strings.ToLower(pkgEcosystem)
+	return advisory == pkg || advisory == ecosystemBase(pkg) || strings.HasPrefix(advisory, pkg+":")
+}
+
+// affectsVersion evaluates the ranges and versions of an affected package (OSV evaluation rules)
+// and returns the first fixed version above version, if any
+func affectsVersion(affected *osvAffected, ecosystem, version string) (bool, string) {
+	for _, v := range affected.Versions {
+		if v == version {
+			return true, ""
+		}
+	}
+
+	for _, r := range affected.Ranges {
+		if r.Type != "SEMVER" && r.Type != "ECOSYSTEM" {
+			continue
+		}
+		compare := func(a, b string) int { return compareVersions(ecosystem, r.Type, a, b) }
+
+		events := append([]osvEvent(nil), r.Events...)
+		sort.SliceStable(events, func(i, j int) bool {
+			return compare(eventVersion(events[i]), eventVersion(events[j])) < 0
+		})
+
+		affectedNow := false
+		for _, event := range events {
+			switch {
+			case event.Introduced != "":
+				if event.Introduced == "0" || compare(version, event.Introduced) >= 0 {
+					affectedNow = true
+				}
+			case event.Fixed != "":
+				if compare(version, event.Fixed) >= 0 {
+					affectedNow = false
+				}
+			case event.LastAffected != "":
+				if compare(version, event.LastAffected) > 0 {
+					affectedNow = false
+				}
+			}
+		}
+		if !affectedNow {
+			continue
+		}
+
+		fixed := ""
+		for _, event := range events {
+			if event.Fixed != "" && compare(event.Fixed, version) > 0 {
+				fixed = event.Fixed
+				break
+			}
+		}
+		return true, fixed
+	}
+	return false, ""
+}
+
+func eventVersion(event osvEvent) string {
+	switch {
+	case event.Introduced != "":
+		return event.Introduced
+	case event.Fixed != "":
+		return event.Fixed
+	}
+	return event.LastAffected
+}
+
+// compareVersions compares two versions with the rules of the ecosystem
+func compareVersions(ecosystem, rangeType, a, b string) int {
+	if a == b {
+		return 0
+	}
+	// "0" désigne le début de l'historique dans les événements OSV
+	if a == "0" {
+		return -1
+	}
+	if b == "0" {
+		return 1
+	}
+
+	base := strings.ToLower(ecosystemBase(ecosystem))
+	switch {
+	case rangeType == "SEMVER", base == "go", base == "npm", base == "crates.io":
+		return compareSemver(a, b)
+	case base == "debian", base == "ubuntu":
+		return compareDpkg(a, b)
+	case base == "alpine":
+		return compareApk(a, b)
+	case base == "red hat", base == "rocky linux", base == "almalinux", base == "opensuse", base == "suse", base == "mageia":
+		return compareRPM(a, b)
+	}
+	return compareGeneric(a, b)
+}
+
+func compareSemver(a, b string) int {
+	va, errA := semver.NewVersion(strings.TrimPrefix(a, "v"))
+	vb, errB := semver.NewVersion(strings.TrimPrefix(b, "v"))
+	if errA != nil || errB != nil {
+		return compareGeneric(a, b)
+	}
+	return va.Compare(vb)
+}
+
+// compareDpkg compares Debian versions ([epoch:]upstream[-revision]) like dpkg --compare-versions
+func compareDpkg(a, b string) int {
+	epochA, upstreamA, revisionA := splitDpkgVersion(a)
+	epochB, upstreamB, revisionB := splitDpkgVersion(b)
+	if epochA != epochB {
+		return sign(epochA - epochB)
+	}
+	if c := dpkgVerRevCmp(upstreamA, upstreamB); c != 0 {
+		return c
+	}
+	return dpkgVerRevCmp(revisionA, revisionB)
+}
+
+func splitDpkgVersion(v string) (int, string, string) {
+	epoch := 0
+	if e, rest, ok := strings.Cut(v, ":"); ok {
+		epoch, _ = strconv.Atoi(e)
+		v = rest
+	}
+	if i := strings.LastIndex(v, "-"); i >= 0 {
+		return epoch, v[:i], v[i+1:]
+	}
+	return epoch, v, ""
+}
+
+// dpkgOrder is the weight of a character in the non-digit parts: '~' sorts before everything
+func dpkgOrder(c byte) int {
+	switch {
+	case c == 0, isDigit(c):
+		return 0
+	case isLetter(c):
+		return int(c)
+	case c == '~':
+		return -1
+	}
+	return int(c) + 256
+}
+
+func dpkgVerRevCmp(a, b string) int {
+	at := func(s string, i int) byte {
+		if i < len(s) {
+			return s[i]
+		}
+		return 0
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		firstDiff := 0
+		for (i < len(a) && !isDigit(a[i])) || (j < len(b) && !isDigit(b[j])) {
+			ac, bc := dpkgOrder(at(a, i)), dpkgOrder(at(b, j))
+			if ac != bc {
+				return sign(ac - bc)
+			}
+			i++
+			j++
+		}
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+		for i < len(a) && j < len(b) && isDigit(a[i]) && isDigit(b[j]) {
+			if firstDiff == 0 {
+				firstDiff = int(a[i]) - int(b[j])
+			}
+			i++
+			j++
+		}
+		if i < len(a) && isDigit(a[i]) {
+			return 1
+		}
+		if j < len(b) && isDigit(b[j]) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return sign(firstDiff)
+		}
+	}
+	return 0
+}
+
+// compareRPM compares [epoch:]version[-release] like rpm
+func compareRPM(a, b string) int {
+	epochA, versionA, releaseA := splitRPMVersion(a)
+	epochB, versionB, releaseB := splitRPMVersion(b)
+	if epochA != epochB {
+		return sign(epochA - epochB)
+	}
+	if c := rpmVerCmp(versionA, versionB); c != 0 {
+		return c
+	}
+	if releaseA == "" || releaseB == "" {
+		return 0
+	}
+	return rpmVerCmp(releaseA, releaseB)
+}
+
+func splitRPMVersion(v string) (int, string, string) {
+	epoch := 0
+	if e, rest, ok := strings.Cut(v, ":"); ok {
+		epoch, _ = strconv.Atoi(e)
+		v = rest
+	}
+	if i := strings.LastIndex(v, "-"); i >= 0 {
+		return epoch, v[:i], v[i+1:]
+	}
+	return epoch, v, ""
+}
+
+// rpmVerCmp is the segment comparison of rpmvercmp, with '~' (pre-release) and '^' (post-release)
+func rpmVerCmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+	isSep := func(c byte) bool { return !isDigit(c) && !isLetter(c) && c != '~' && c != '^' }
+	for len(a) > 0 || len(b) > 0 {
+		for len(a) > 0 && isSep(a[0]) {
+			a = a[1:]
+		}
+		for len(b) > 0 && isSep(b[0]) {
+			b = b[1:]
+		}
+
+		if strings.HasPrefix(a, "~") || strings.HasPrefix(b, "~") {
+			if !strings.HasPrefix(a, "~") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "~") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+		if strings.HasPrefix(a, "^") || strings.HasPrefix(b, "^") {
+			switch {
+			case len(a) == 0:
+				return -1
+			case len(b) == 0:
+				return 1
+			case a[0] != '^':
+				return 1
+			case b[0] != '^':
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+		if len(a) == 0 || len(b) == 0 {
+			break
+		}
+
+		numeric := isDigit(a[0])
+		segA, segB := leadingRun(a, numeric), leadingRun(b, numeric)
+		a, b = a[len(segA):], b[len(segB):]
+		if segB == "" {
+			if numeric {
+				return 1
+			}
+			return -1
+		}
+		if numeric {
+			if c := compareNumeric(segA, segB); c != 0 {
+				return c
+			}
+		} else if c := strings.Compare(segA, segB); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return -1
+	}
+	return 1
+}
+
+// compareApk compares Alpine versions: the version, then the -rN package release
+func compareApk(a, b string) int {
+	versionA, releaseA := splitApkVersion(a)
+	versionB, releaseB := splitApkVersion(b)
+	if c := compareGeneric(versionA, versionB); c != 0 {
+		return c
+	}
+	return sign(releaseA - releaseB)
+}
+
+func splitApkVersion(v string) (string, int) {
+	if i := strings.LastIndex(v, "-r"); i >= 0 {
+		if release, err := strconv.Atoi(v[i+2:]); err == nil {
+			return v[:i], release
+		}
+	}
+	return v, 0
+}
+
+// preReleaseRanks orders the pre-release markers below a release (rank 0);
+// unknown words rank after releases, like post-releases
+var preReleaseRanks = map[string]int{
+	"dev": -6, "snapshot": -5, "alpha": -4, "a": -4, "beta": -3, "b": -3,
+	"pre": -2, "preview": -2, "c": -1, "rc": -1,
+}
+
+// compareGeneric compares versions as sequences of numbers and words, for ecosystems
+// without a dedicated comparison (PyPI, RubyGems, Packagist...)
+func compareGeneric(a, b string) int {
+	ta, tb := versionTokens(a), versionTokens(b)
+	for i := 0; i < len(ta) || i < len(tb); i++ {
+		switch {
+		case i >= len(ta):
+			return -tokenWeight(tb[i])
+		case i >= len(tb):
+			return tokenWeight(ta[i])
+		}
+		x, y := ta[i], tb[i]
+		numX, numY := isDigit(x[0]), isDigit(y[0])
+		switch {
+		case numX && numY:
+			if c := compareNumeric(x, y); c != 0 {
+				return c
+			}
+		case numX:
+			return 1
+		case numY:
+			return -1
+		default:
+			rankX, rankY := wordRank(x), wordRank(y)
+			if rankX != rankY {
+				return sign(rankX - rankY)
+			}
+			if c := strings.Compare(x, y); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
+
+// tokenWeight is the sign of an extra trailing token: pre-releases make a version smaller
+func tokenWeight(token string) int {
+	if !isDigit(token[0]) && wordRank(token) < 0 {
+		return -1
+	}
+	return 1
+}
+
+func wordRank(word string) int {
+	if rank, ok := preReleaseRanks[word]; ok {
+		return rank
+	}
+	return 1
+}
+
+// versionTokens splits a version into runs of digits and lowercase letters
+func versionTokens(v string) []string {
+	v = strings.ToLower(strings.TrimPrefix(v, "v"))
+	var tokens []string
+	for len(v) > 0 {
+		if !isDigit(v[0]) && !isLetter(v[0]) {
+			v = v[1:]
+			continue
+		}
+		token := leadingRun(v, isDigit(v[0]))
+		tokens = append(tokens, token)
+		v = v[len(token):]
+	}
+	return tokens
+}
+
+// leadingRun returns the leading digits (numeric) or letters of s
+func leadingRun(s string, numeric bool) string {
+	i := 0
+	for i < len(s) && ((numeric && isDigit(s[i])) || (!numeric && isLetter(s[i]))) {
+		i++
+	}
+	return s[:i]
+}
+
+// compareNumeric compares two digit strings of any length
+func compareNumeric(a, b string) int {
+	a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return sign(len(a) - len(b))
+	}
+	return strings.Compare(a, b)
+}
+
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
+func isLetter(c byte) bool { return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// severityRank orders severities from critical (0) to unknown
+func severityRank(severity string) int {
+	switch severity {
+	case models.SeverityCritical:
+		return 0
+	case models.SeverityHigh:
+		return 1
+	case models.SeverityMedium:
+		return 2
+	case models.SeverityLow:
+		return 3
+	}
+	return 4
+}
+
+// advisorySeverity takes the severity given by the database (e.g. GitHub advisories),
+// else computes it from the CVSS vector, else uses the severity given by the ecosystem
+func advisorySeverity(advisory *osvAdvisory) string {
+	if value, ok := advisory.DatabaseSpecific["severity"].(string); ok {
+		if severity := normalizeSeverity(value); severity != models.SeverityUnknown {
+			return severity
+		}
+	}
+	for _, version := range []string{"CVSS_V3", "CVSS_V2"} {
+		for _, severity := range advisory.Severity {
+			if severity.Type != version {
+				continue
+			}
+			if score, ok := cvssScore(severity.Score); ok {
+				return cvssSeverity(score, version == "CVSS_V2")
+			}
+		}
+	}
+	for _, severity := range advisory.Severity {
+		if s := normalizeSeverity(severity.Score); s != models.SeverityUnknown {
+			return s
+		}
+	}
+	for _, affected := range advisory.Affected {
+		if value, ok := affected.EcosystemSpecific["severity"].(string); ok {
+			if severity := normalizeSeverity(value); severity != models.SeverityUnknown {
+				return severity
+			}
+		}
+	}
+	return models.SeverityUnknown
+}
+
+// normalizeSeverity maps the words used by the advisory databases to a severity
+func normalizeSeverity(value string) string {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "critical":
+		return models.SeverityCritical
+	case "high", "important":
+		return models.SeverityHigh
+	case "medium", "moderate":
+		return models.SeverityMedium
+	case "low", "negligible", "minor":
+		return models.SeverityLow
+	}
+	return models.SeverityUnknown
+}
+
+func cvssSeverity(score float64, v2 bool) string {
+	switch {
+	case score >= 9 && !v2:
+		return models.SeverityCritical
+	case score >= 7:
+		return models.SeverityHigh
+	case score >= 4:
+		return models.SeverityMedium
+	case score > 0:
+		return models.SeverityLow
+	}
+	return models.SeverityUnknown
+}
+
+// cvssScore computes the base score of a CVSS v3.x or v2 vector
+func cvssScore(vector string) (float64, bool) {
+	metrics := make(map[string]string)
+	for _, part := range strings.Split(vector, "/") {
+		if key, value, ok := strings.Cut(part, ":"); ok {
+			metrics[key] = value
+		}
+	}
+	if strings.HasPrefix(metrics["CVSS"], "3") {
+		return cvss3Score(metrics)
+	}
+	if _, ok := metrics["Au"]; ok {
+		return cvss2Score(metrics)
+	}
+	return 0, false
+}
+
+func cvss3Score(m map[string]string) (float64, bool) {
+	av := map[string]float64{"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2}[m["AV"]]
+	ac := map[string]float64{"L": 0.77, "H": 0.44}[m["AC"]]
+	ui := map[string]float64{"N": 0.85, "R": 0.62}[m["UI"]]
+	cia := map[string]float64{"H": 0.56, "L": 0.22, "N": 0}
+	c, okC := cia[m["C"]]
+	i, okI := cia[m["I"]]
+	a, okA := cia[m["A"]]
+	changed := m["S"] == "C"
+	pr := map[string]float64{"N": 0.85, "L": 0.62, "H": 0.27}[m["PR"]]
+	if changed {
+		pr = map[string]float64{"N": 0.85, "L": 0.68, "H": 0.5}[m["PR"]]
+	}
+	if av == 0 || ac == 0 || ui == 0 || pr == 0 || !okC || !okI || !okA {
+		return 0, false
+	}
+
+	iss := 1 - (1-c)*(1-i)*(1-a)
+	impact := 6.42 * iss
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	exploitability := 8.22 * av * ac * pr * ui
+	if impact <= 0 {
+		return 0, true
+	}
+	if changed {
+		return roundUp(math.Min(1.08*(impact+exploitability), 10)), true
+	}
+	return roundUp(math.Min(impact+exploitability, 10)), true
+}
+
+// roundUp is the CVSS v3.1 rounding to one decimal
+func roundUp(value float64) float64 {
+	scaled := int(math.Round(value * 100000))
+	if scaled%10000 == 0 {
+		return float64(scaled) / 100000
+	}
+	return float64(scaled/10000+1) / 10
+}
+
+func cvss2Score(m map[string]string) (float64, bool) {
+	av := map[string]float64{"L": 0.395, "A": 0.646, "N": 1}[m["AV"]]
+	ac := map[string]float64{"H": 0.35, "M": 0.61, "L": 0.71}[m["AC"]]
+	au := map[string]float64{"M": 0.45, "S": 0.56, "N": 0.704}[m["Au"]]
+	cia := map[string]float64{"N": 0, "P": 0.275, "C": 0.66}
+	c, okC := cia[m["C"]]
+	i, okI := cia[m["I"]]
+	a, okA := cia[m["A"]]
+	if av == 0 || ac == 0 || au == 0 || !okC || !okI || !okA {
+		return 0, false
+	}
+	impact := 10.41 * (1 - (1-c)*(1-i)*(1-a))
+	if impact == 0 {
+		return 0, true
+	}
+	exploitability := 20 * av * ac * au
+	return math.Round((0.6*impact+0.4*exploitability-1.5)*1.176*10) / 10, true
+}
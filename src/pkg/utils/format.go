@@ -0,0 +1,58 @@
+// pkg/utils/format.go
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HumanSize formats a size in bytes with binary units (e.g. "12.3 MB")
+func HumanSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit && exp < 3; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGT"[exp])
+}
+
+// ShortDigest returns the first 12 hex characters of a digest, as shown by docker
+func ShortDigest(digest string) string {
+	hex := digest
+	if i := strings.Index(digest, ":"); i >= 0 {
+		hex = digest[i+1:]
+	}
+	if len(hex) > 12 {
+		hex = hex[:12]
+	}
+	return hex
+}
+
+// SeverityClass returns the badge colors of a vulnerability severity
+func SeverityClass(severity string) string {
+	switch severity {
+	case "CRITICAL":
+		return "bg-red-700 text-white"
+	case "HIGH":
+		return "bg-red-100 text-red-800"
+	case "MEDIUM":
+		return "bg-yellow-100 text-yellow-800"
+	case "LOW":
+		return "bg-blue-100 text-blue-800"
+	}
+	return "bg-gray-100 text-gray-700"
+}
+
+// TemplateFuncs returns the helpers available in the HTML views
+func TemplateFuncs() map[string]interface{} {
+	return map[string]interface{}{
+		"humanSize":     HumanSize,
+		"shortDigest":   ShortDigest,
+		"join":          strings.Join,
+		"severityClass": SeverityClass,
+	}
+}
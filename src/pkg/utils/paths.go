@@ -43,6 +43,16 @@ func NewPathManager(basePath string, log *Logger) *PathManager {
 	}
 }
 
+// ForRepository retourne un PathManager dont tout le stockage est préfixé par le dossier du dépôt
+func (pm *PathManager) ForRepository(name string) *PathManager {
+	return NewPathManager(pm.GetRepositoryPath(name), pm.log)
+}
+
+// GetRepositoryPath retourne le dossier de stockage d'un dépôt isolé
+func (pm *PathManager) GetRepositoryPath(name string) string {
+	return filepath.Join(pm.baseStoragePath, "repositories", name)
+}
+
 func (pm *PathManager) GetTempPath(uuid string) string {
 	return filepath.Join(pm.baseStoragePath, "temp", uuid)
 }
@@ -112,6 +122,11 @@ func (pm *PathManager) GetChartsPath() string {
 	return filepath.Join(pm.baseStoragePath, "charts")
 }
 
+// GetChartStatusPath retourne le fichier de statut (dépréciation, yank) d'une version de chart
+func (pm *PathManager) GetChartStatusPath(chartName, version string) string {
+	return filepath.Join(pm.baseStoragePath, "lifecycle", chartName, version+".json")
+}
+
 func (pm *PathManager) GetOCIRepositoryPath(name string) string {
 	return filepath.Join(pm.baseStoragePath, "oci", name)
 }
@@ -136,6 +151,11 @@ func (pm *PathManager) GetImageManifestPath(name, reference string) string {
 	return filepath.Join(pm.baseStoragePath, "images", name, "manifests", safeRef+".json")
 }
 
+// MediaTypePath returns the file holding the media type a manifest was pushed with
+func MediaTypePath(manifestPath string) string {
+	return strings.TrimSuffix(manifestPath, ".json") + ".mediatype"
+}
+
 func (pm *PathManager) GetImageTagPath(name, tag string) string {
 	return filepath.Join(pm.baseStoragePath, "images", name, "tags", tag+".json")
 }
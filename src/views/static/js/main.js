@@ -236,29 +236,80 @@ function updateChart(cardElement, chartName, versions) {
 let activeTab = 'charts';
 
 /**
- * Switch between charts and images tabs
- * @param {string} tab - The tab to show ('charts' or 'images')
+ * Switch between the charts, images and usage tabs
+ * @param {string} tab - The tab to show ('charts', 'images' or 'usage')
  */
 function showTab(tab) {
     activeTab = tab;
 
-    const chartsSection = document.getElementById('chartsSection');
-    const imagesSection = document.getElementById('imagesSection');
-    const chartsTab = document.getElementById('chartsTab');
-    const imagesTab = document.getElementById('imagesTab');
+    // Quitter la recherche en cours
+    const searchSection = document.getElementById('searchSection');
+    if (searchSection && searchSection.style.display !== 'none') {
+        searchSection.style.display = 'none';
+        document.getElementById('searchInput').value = '';
+    }
 
-    if (tab === 'charts') {
-        chartsSection.style.display = 'block';
-        imagesSection.style.display = 'none';
-        chartsTab.classList.add('active', 'bg-blue-700');
-        imagesTab.classList.remove('active', 'bg-blue-700');
-    } else {
-        chartsSection.style.display = 'none';
-        imagesSection.style.display = 'block';
-        chartsTab.classList.remove('active', 'bg-blue-700');
-        imagesTab.classList.add('active', 'bg-blue-700');
+    const sections = {
+        charts: document.getElementById('chartsSection'),
+        images: document.getElementById('imagesSection'),
+        usage: document.getElementById('usageSection'),
+    };
+    for (const [name, section] of Object.entries(sections)) {
+        const button = document.getElementById(`${name}Tab`);
+        section.style.display = name === tab ? 'block' : 'none';
+        button.classList.toggle('active', name === tab);
+        button.classList.toggle('bg-blue-700', name === tab);
+    }
+
+    if (tab === 'images') {
         // Load images when switching to images tab
         loadDockerImages();
+    } else if (tab === 'usage') {
+        loadUsage();
+    }
+}
+
+/**
+ * Fetch and display the pull statistics, least recently pulled first
+ */
+async function loadUsage() {
+    const rows = document.getElementById('usageRows');
+    const params = new URLSearchParams();
+    const type = document.getElementById('usageType').value;
+    const unusedDays = parseInt(document.getElementById('usageUnusedDays').value, 10);
+    if (type) params.set('type', type);
+    if (unusedDays > 0) params.set('unusedDays', unusedDays);
+
+    try {
+        const response = await fetch(`/api/stats?${params}`);
+        const data = await response.json();
+        if (!response.ok) {
+            throw new Error(data.error || 'Failed to load statistics');
+        }
+
+        if (data.items.length === 0) {
+            rows.innerHTML = '<tr><td colspan="8" class="px-4 py-6 text-center text-gray-500">Nothing to show</td></tr>';
+            return;
+        }
+        rows.innerHTML = data.items.map(item => {
+            const isChart = item.type === 'helm';
+            const link = `${isChart ? '/chart' : '/image'}/${encodeURIComponent(item.name)}/${encodeURIComponent(item.reference)}/details`;
+            return `
+                <tr class="border-t">
+                    <td class="px-4 py-2">${isChart ? 'Chart' : 'Image'}</td>
+                    <td class="px-4 py-2"><a href="${link}" class="text-blue-600 hover:underline">${escapeHtml(item.name)}</a></td>
+                    <td class="px-4 py-2 font-mono">${escapeHtml(item.reference)}</td>
+                    <td class="px-4 py-2 text-right">${item.total}</td>
+                    <td class="px-4 py-2 text-right">${item.last7Days}</td>
+                    <td class="px-4 py-2 text-right">${item.last30Days}</td>
+                    <td class="px-4 py-2">${item.lastPulled ? new Date(item.lastPulled).toLocaleString() : '<span class="text-gray-500">Never</span>'}</td>
+                    <td class="px-4 py-2 text-gray-600">${escapeHtml(item.lastClient || '')}</td>
+                </tr>
+            `;
+        }).join('');
+    } catch (error) {
+        console.error('Error loading statistics:', error);
+        rows.innerHTML = `<tr><td colspan="8" class="px-4 py-6 text-center text-red-500">${escapeHtml(error.message)}</td></tr>`;
     }
 }
 
@@ -293,9 +344,36 @@ async function loadDockerImages() {
     }
 }
 
+/**
+ * Format a size in bytes with binary units
+ * @param {number} bytes - The size in bytes
+ * @returns {string} The formatted size
+ */
+function formatSize(bytes) {
+    if (!bytes) return 'Unknown';
+    const sizes = ['B', 'KB', 'MB', 'GB', 'TB'];
+    const i = Math.min(Math.floor(Math.log(bytes) / Math.log(1024)), sizes.length - 1);
+    return (bytes / Math.pow(1024, i)).toFixed(i === 0 ? 0 : 1) + ' ' + sizes[i];
+}
+
+/**
+ * Describe the platform(s) of an image tag
+ * @param {Object} image - The image tag metadata
+ * @returns {string} e.g. "linux/amd64" or "3 platforms"
+ */
+function imagePlatform(image) {
+    if (image.platforms && image.platforms.length > 0) {
+        return `${image.platforms.length} platform${image.platforms.length > 1 ? 's' : ''}`;
+    }
+    if (image.config) {
+        return `${image.config.os}/${image.config.architecture}`;
+    }
+    return 'Unknown';
+}
+
 /**
  * Create HTML card for a Docker image
- * @param {Object} imageGroup - The image group data
+ * @param {Object} imageGroup - The image group data (tags from the most recently pushed)
  * @returns {string} HTML string for the image card
  */
 function createImageCard(imageGroup) {
@@ -307,51 +385,63 @@ function createImageCard(imageGroup) {
         return '';
     }
 
-    // Format size
-    const formatSize = (bytes) => {
-        if (!bytes) return 'Unknown';
-        const sizes = ['B', 'KB', 'MB', 'GB'];
-        const i = Math.floor(Math.log(bytes) / Math.log(1024));
-        return (bytes / Math.pow(1024, i)).toFixed(2) + ' ' + sizes[i];
-    };
+    // Conserver les métadonnées pour le changement de tag
+    if (!window.imageTags) window.imageTags = {};
+    window.imageTags[name] = tags;
 
     const tagsHtml = tags.length > 1
-        ? `<select class="mt-2 text-sm border rounded p-1" onchange="switchImageTag('${name}', this.value)">
-             ${tags.map(t => `<option value="${t.Tag}">${t.Tag}</option>`).join('')}
+        ? `<select class="mt-2 text-sm border rounded p-1" onchange="switchImageTag('${escapeHtml(name)}', this.value)">
+             ${tags.map(t => `<option value="${escapeHtml(t.tag)}">Tag: ${escapeHtml(t.tag)}</option>`).join('')}
            </select>`
-        : `<p class="mt-2 text-sm text-gray-600">Tag: ${firstTag.Tag}</p>`;
+        : `<p class="mt-2 text-sm text-gray-600">Tag: ${escapeHtml(firstTag.tag)}</p>`;
 
     return `
-        <div class="bg-white rounded-lg shadow-md p-6 flex flex-col h-[200px]" data-image-name="${name}">
+        <div class="bg-white rounded-lg shadow-md p-6 flex flex-col h-[200px]" data-image-name="${escapeHtml(name)}">
             <div class="flex justify-between items-start mb-4">
                 <div>
                     <h2 class="text-lg font-bold text-purple-600">
-                        <a href="/image/${name}/${firstTag.Tag}/details">${name}</a>
+                        <a href="/images#image-${encodeURIComponent(name)}">${escapeHtml(name)}</a>
                     </h2>
                     ${tagsHtml}
                 </div>
                 <div class="flex gap-2">
-                    <a href="/image/${name}/${firstTag.Tag}/details" class="tooltip-trigger" data-tooltip="View image details">
+                    <a href="#" onclick="copyPullCommand('${escapeHtml(name)}'); return false;" class="tooltip-trigger" data-tooltip="Copy pull command">
+                        <i class="material-icons icon-copy text-gray-500 hover:text-gray-700">content_copy</i>
+                    </a>
+                    <a href="/image/${encodeURIComponent(name)}/${encodeURIComponent(firstTag.tag)}/details" class="tooltip-trigger" data-tooltip="View image details">
                         <i class="material-icons icon-info text-blue-500 hover:text-blue-700">info</i>
                     </a>
-                    <a href="#" onclick="deleteImage('${name}', '${firstTag.Tag}')" class="tooltip-trigger" data-tooltip="Delete this tag">
+                    <a href="#" onclick="deleteImage('${escapeHtml(name)}', '${escapeHtml(firstTag.tag)}')" class="tooltip-trigger" data-tooltip="Delete this tag">
                         <i class="material-icons icon-delete text-red-500 hover:text-red-700">delete</i>
                     </a>
                 </div>
             </div>
-            <div class="flex-1 overflow-hidden">
-                <div class="text-sm text-gray-600 mb-2">
-                    <p><span class="font-semibold">Size:</span> ${formatSize(firstTag.Size)}</p>
-                    <p><span class="font-semibold">Layers:</span> ${firstTag.Layers ? firstTag.Layers.length : 'N/A'}</p>
-                </div>
-                <p class="text-gray-500 text-xs truncate">
-                    <span class="font-semibold">Digest:</span> ${firstTag.Digest ? firstTag.Digest.substring(0, 20) + '...' : 'N/A'}
-                </p>
+            <div class="image-details flex-1 overflow-hidden">
+                ${imageDetailsHtml(firstTag)}
             </div>
         </div>
     `;
 }
 
+/**
+ * Build the size, platform and push date lines of an image card
+ * @param {Object} image - The image tag metadata
+ * @returns {string} HTML string
+ */
+function imageDetailsHtml(image) {
+    const pushed = image.created && !image.created.startsWith('0001-') ? new Date(image.created).toLocaleString() : 'Unknown';
+    return `
+        <div class="text-sm text-gray-600 mb-2">
+            <p><span class="font-semibold">Size:</span> ${formatSize(image.size)}</p>
+            <p><span class="font-semibold">Platform:</span> ${escapeHtml(imagePlatform(image))}</p>
+            <p><span class="font-semibold">Pushed:</span> ${escapeHtml(pushed)}</p>
+        </div>
+        <p class="text-gray-500 text-xs truncate">
+            <span class="font-semibold">Digest:</span> ${image.digest ? escapeHtml(image.digest.substring(0, 19)) + '...' : 'N/A'}
+        </p>
+    `;
+}
+
 /**
  * Switch to a different tag for an image
  * @param {string} imageName - The image name
@@ -365,8 +455,31 @@ function switchImageTag(imageName, tag) {
     const infoLink = card.querySelector('.icon-info').parentElement;
     const deleteLink = card.querySelector('.icon-delete').parentElement;
 
-    infoLink.href = `/image/${imageName}/${tag}/details`;
+    infoLink.href = `/image/${encodeURIComponent(imageName)}/${encodeURIComponent(tag)}/details`;
     deleteLink.onclick = function() { deleteImage(imageName, tag); };
+
+    const image = (window.imageTags[imageName] || []).find(t => t.tag === tag);
+    if (image) {
+        card.querySelector('.image-details').innerHTML = imageDetailsHtml(image);
+    }
+}
+
+/**
+ * Copy the docker pull command of the selected tag of an image
+ * @param {string} imageName - The image name
+ */
+async function copyPullCommand(imageName) {
+    const card = document.querySelector(`[data-image-name="${imageName}"]`);
+    const select = card ? card.querySelector('select') : null;
+    const tag = select ? select.value : window.imageTags[imageName][0].tag;
+    const command = `docker pull ${window.location.host}/${imageName}:${tag}`;
+
+    try {
+        await navigator.clipboard.writeText(command);
+        showModal(`Copied: ${command}`, false);
+    } catch (error) {
+        prompt('Copy the command:', command);
+    }
 }
 
 /**
@@ -398,10 +511,115 @@ async function deleteImage(name, tag) {
     }
 }
 
+/**
+ * Escape a string for insertion in HTML
+ * @param {string} value - The text to escape
+ * @returns {string} The escaped text
+ */
+function escapeHtml(value) {
+    const div = document.createElement('div');
+    div.textContent = value == null ? '' : String(value);
+    return div.innerHTML;
+}
+
+let searchTimer = null;
+
+/**
+ * Run the search after the user stops typing.
+ * The chart and image lists are hidden while a query is active.
+ */
+function scheduleSearch() {
+    clearTimeout(searchTimer);
+    searchTimer = setTimeout(runSearch, 250);
+}
+
+async function runSearch() {
+    const query = document.getElementById('searchInput').value.trim();
+    const type = document.getElementById('searchType').value;
+    const searchSection = document.getElementById('searchSection');
+
+    if (!query) {
+        searchSection.style.display = 'none';
+        showTab(activeTab);
+        return;
+    }
+
+    document.getElementById('chartsSection').style.display = 'none';
+    document.getElementById('imagesSection').style.display = 'none';
+    document.getElementById('usageSection').style.display = 'none';
+    searchSection.style.display = 'block';
+
+    const summary = document.getElementById('searchSummary');
+    const container = document.getElementById('searchResults');
+    try {
+        const params = new URLSearchParams({ q: query });
+        if (type) params.set('type', type);
+        const response = await fetch(`/search?${params}`);
+        const data = await response.json();
+        if (!response.ok) {
+            throw new Error(data.error || 'Search failed');
+        }
+
+        // Ignorer les réponses d'une requête déjà remplacée
+        if (document.getElementById('searchInput').value.trim() !== query) return;
+
+        summary.textContent = data.total === 0
+            ? `No results for "${query}"`
+            : `${data.total} result${data.total > 1 ? 's' : ''} for "${query}"`;
+        container.innerHTML = data.hits.map(createSearchHitCard).join('');
+    } catch (error) {
+        console.error('Error searching:', error);
+        summary.textContent = '';
+        container.innerHTML = `
+            <div class="col-span-full text-center text-red-500">
+                <i class="material-icons text-4xl">error</i>
+                <p>${escapeHtml(error.message)}</p>
+            </div>
+        `;
+    }
+}
+
+/**
+ * Create HTML card for a search hit
+ * @param {Object} hit - The search hit (chart or image repository)
+ * @returns {string} HTML string for the card
+ */
+function createSearchHitCard(hit) {
+    const isChart = hit.type === 'helm';
+    const latest = hit.versions[0];
+    const base = isChart ? '/chart' : '/image';
+    const link = version => `${base}/${encodeURIComponent(hit.name)}/${encodeURIComponent(version)}/details`;
+
+    const versions = hit.versions.slice(0, 5).map(v =>
+        `<a href="${link(v)}" class="inline-block text-xs bg-gray-100 hover:bg-gray-200 rounded px-2 py-1 mr-1 mb-1">${escapeHtml(v)}</a>`
+    ).join('') + (hit.versions.length > 5 ? `<span class="text-xs text-gray-500">+${hit.versions.length - 5} more</span>` : '');
+
+    return `
+        <div class="bg-white rounded-lg shadow-md p-6 flex flex-col">
+            <div class="flex items-center justify-between mb-2">
+                <h2 class="text-lg font-bold ${isChart ? 'text-blue-600' : 'text-purple-600'}">
+                    <a href="${link(latest)}">${escapeHtml(hit.name)}</a>
+                </h2>
+                <i class="material-icons text-gray-400" title="${isChart ? 'Helm chart' : 'Docker image'}">${isChart ? 'sailing' : 'inventory_2'}</i>
+            </div>
+            <div class="mb-2">${versions}</div>
+            ${hit.description ? `<p class="text-gray-700 text-sm line-clamp-3 mb-2">${escapeHtml(hit.description)}</p>` : ''}
+            <p class="text-xs text-gray-500 mt-auto">Matched in: ${hit.fields.map(escapeHtml).join(', ')}</p>
+        </div>
+    `;
+}
+
 // 🚀 Initialisation
 document.addEventListener('DOMContentLoaded', function () {
     console.log('DOM loaded'); // Debug
 
+    // Recherche plein texte
+    const searchInput = document.getElementById('searchInput');
+    if (searchInput) {
+        searchInput.addEventListener('input', scheduleSearch);
+        document.getElementById('searchType').addEventListener('change', runSearch);
+    }
+
     // Vérifier le statut de la fonctionnalité de backup
     checkBackupStatus();
 
@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"helm-portal/config"
+	"helm-portal/pkg/models"
+	service "helm-portal/pkg/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chartWithDependencies retourne les fichiers d'un chart déclarant les dépendances données
+func chartWithDependencies(name, version, dependencies string) map[string]string {
+	files := validChartFiles(name, version)
+	files[name+"/Chart.yaml"] = "apiVersion: v2\nname: " + name + "\nversion: " + version + "\ndescription: x\ndependencies:\n" + dependencies
+	return files
+}
+
+func TestCheckDependencies(t *testing.T) {
+	chartService, _ := newTestChartService(t, func(cfg *config.Config) {
+		cfg.Charts.Dependencies.HostedRepositories = []string{"https://charts.example.com"}
+	})
+
+	for _, version := range []string{"1.0.0", "2.1.0"} {
+		data := buildChartArchive(t, validChartFiles("common", version))
+		require.NoError(t, chartService.SaveChart(data, "common-"+version+".tgz"))
+	}
+
+	files := chartWithDependencies("app", "1.0.0", ""+
+		"  - name: common\n    version: ^2.0.0\n    repository: https://charts.example.com\n"+
+		"  - name: redis\n    version: ~17.0.0\n    repository: oci://registry-1.docker.io/bitnamicharts\n"+
+		"  - name: local\n    version: 0.1.0\n    repository: file://../local\n"+
+		"  - name: missing\n    version: 1.0.0\n    repository: file://../missing\n"+
+		"  - name: sub\n    version: 1.x\n    repository: \"\"\n    alias: subchart\n")
+	files["app/charts/local/Chart.yaml"] = "apiVersion: v2\nname: local\nversion: 0.1.0\n"
+	files["app/charts/sub-2.0.0.tgz"] = string(buildChartArchive(t, validChartFiles("sub", "2.0.0")))
+	files["app/charts/extra/Chart.yaml"] = "apiVersion: v2\nname: extra\nversion: 1.0.0\n"
+	require.NoError(t, chartService.SaveChart(buildChartArchive(t, files), "app-1.0.0.tgz"))
+
+	report, err := chartService.CheckDependencies("app", "1.0.0")
+	require.NoError(t, err)
+	assert.True(t, report.HasProblems())
+
+	checks := make(map[string]models.DependencyCheck)
+	for _, d := range report.Dependencies {
+		checks[d.Name] = d
+	}
+	require.Len(t, checks, 6)
+
+	assert.Equal(t, models.DependencyResolved, checks["common"].Status)
+	assert.Equal(t, "2.1.0", checks["common"].ResolvedVersion)
+	assert.Equal(t, models.DependencyExternal, checks["redis"].Status)
+	assert.Equal(t, models.DependencyVendored, checks["local"].Status)
+	assert.Equal(t, models.DependencyUnresolved, checks["missing"].Status)
+	assert.Equal(t, models.DependencyMismatch, checks["sub"].Status)
+	assert.Equal(t, "2.0.0", checks["sub"].VendoredVersion)
+	assert.Equal(t, "subchart", checks["sub"].Alias)
+	assert.Equal(t, models.DependencyMismatch, checks["extra"].Status)
+
+	_, err = chartService.CheckDependencies("app", "9.9.9")
+	assert.True(t, errors.Is(err, service.ErrChartNotFound))
+}
+
+func TestCheckDependencies_HostedRepositories(t *testing.T) {
+	chartService, _ := newTestChartService(t, func(cfg *config.Config) {
+		cfg.Charts.Dependencies.HostedRepositories = []string{"oci://helm.example.com"}
+	})
+
+	data := buildChartArchive(t, validChartFiles("common", "2.0.0"))
+	require.NoError(t, chartService.SaveChart(data, "common-2.0.0.tgz"))
+
+	files := chartWithDependencies("app", "1.0.0", ""+
+		"  - name: common\n    version: ^3.0.0\n    repository: oci://helm.example.com/charts\n"+
+		"  - name: other\n    version: 1.0.0\n    repository: https://charts.example.com\n")
+	require.NoError(t, chartService.SaveChart(buildChartArchive(t, files), "app-1.0.0.tgz"))
+
+	report, err := chartService.CheckDependencies("app", "1.0.0")
+	require.NoError(t, err)
+	require.Len(t, report.Dependencies, 2)
+
+	// Dépôt hébergé ici mais aucune version compatible
+	assert.Equal(t, models.DependencyUnresolved, report.Dependencies[0].Status)
+	// Dépôt non listé : dépendance externe
+	assert.Equal(t, models.DependencyExternal, report.Dependencies[1].Status)
+
+	t.Run("Sans dépôt configuré", func(t *testing.T) {
+		chartService, _ := newTestChartService(t)
+		require.NoError(t, chartService.SaveChart(buildChartArchive(t, validChartFiles("common", "1.0.0")), "common-1.0.0.tgz"))
+
+		files := chartWithDependencies("app", "1.0.0", "  - name: common\n    version: 1.0.0\n    repository: https://charts.example.com\n")
+		require.NoError(t, chartService.SaveChart(buildChartArchive(t, files), "app-1.0.0.tgz"))
+
+		// Un chart du même nom hébergé ici ne suffit pas : le dépôt n'est pas résolu
+		report, err := chartService.CheckDependencies("app", "1.0.0")
+		require.NoError(t, err)
+		require.Len(t, report.Dependencies, 1)
+		assert.Equal(t, models.DependencyExternal, report.Dependencies[0].Status)
+	})
+}
+
+func TestGetDependents(t *testing.T) {
+	chartService, _ := newTestChartService(t)
+
+	for _, version := range []string{"1.0.0", "2.0.0", "2.3.0"} {
+		data := buildChartArchive(t, validChartFiles("common", version))
+		require.NoError(t, chartService.SaveChart(data, "common-"+version+".tgz"))
+	}
+
+	charts := map[string]string{
+		"app":    "  - name: common\n    version: ^2.0.0\n    repository: https://charts.example.com\n",
+		"legacy": "  - name: common\n    version: ~1.0.0\n    repository: https://charts.example.com\n",
+		"pinned": "  - name: common\n    version: 2.3.0\n    repository: https://charts.example.com\n",
+		"other":  "  - name: redis\n    version: 17.0.0\n    repository: https://charts.example.com\n",
+	}
+	for name, deps := range charts {
+		data := buildChartArchive(t, chartWithDependencies(name, "1.0.0", deps))
+		require.NoError(t, chartService.SaveChart(data, name+"-1.0.0.tgz"))
+	}
+
+	tests := []struct {
+		name       string
+		constraint string
+		expected   []string
+	}{
+		{name: "Sans contrainte", constraint: "", expected: []string{"app", "legacy", "pinned"}},
+		{name: "Version 2.x", constraint: "2.x", expected: []string{"app", "pinned"}},
+		{name: "Version exacte 2.0.0", constraint: "2.0.0", expected: []string{"app"}},
+		{name: "Version 1.x", constraint: "1.x", expected: []string{"legacy"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dependents, err := chartService.GetDependents("common", tt.constraint)
+			require.NoError(t, err)
+			names := []string{}
+			for _, d := range dependents {
+				names = append(names, d.Name)
+			}
+			assert.Equal(t, tt.expected, names)
+		})
+	}
+
+	_, err := chartService.GetDependents("common", "not a constraint")
+	assert.True(t, errors.Is(err, service.ErrInvalidConstraint))
+}
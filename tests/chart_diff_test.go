@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"testing"
+
+	"helm-portal/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffChartVersions(t *testing.T) {
+	chartService, _ := newTestChartService(t)
+
+	v1 := validChartFiles("my-chart", "1.0.0")
+	v1["my-chart/values.yaml"] = "replicaCount: 1\nimage:\n  repository: nginx\n  tag: \"1.25\"\nports:\n  - 80\n"
+	v1["my-chart/templates/old.yaml"] = "kind: Pod\n"
+	require.NoError(t, chartService.SaveChart(buildChartArchive(t, v1), "my-chart-1.0.0.tgz"))
+
+	v2 := validChartFiles("my-chart", "1.1.0")
+	v2["my-chart/Chart.yaml"] = "apiVersion: v2\nname: my-chart\nversion: 1.1.0\ndescription: test chart\nappVersion: \"2.0\"\n"
+	// Même contenu sémantique avec un formatage différent pour replicaCount et image.repository
+	v2["my-chart/values.yaml"] = "image:\n  repository: \"nginx\"\n  tag: \"1.26\"\nreplicaCount: 1\nports:\n  - 80\n  - 443\nservice:\n  enabled: true\n"
+	v2["my-chart/templates/configmap.yaml"] = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .Release.Name }}-cm\n"
+	require.NoError(t, chartService.SaveChart(buildChartArchive(t, v2), "my-chart-1.1.0.tgz"))
+
+	diff, err := chartService.DiffChartVersions("my-chart", "1.0.0", "1.1.0")
+	require.NoError(t, err)
+
+	// Fichiers
+	files := make(map[string]string)
+	for _, f := range diff.Files {
+		files[f.Path] = f.Change
+	}
+	assert.Equal(t, models.ChangeModified, files["Chart.yaml"])
+	assert.Equal(t, models.ChangeModified, files["values.yaml"])
+	assert.Equal(t, models.ChangeRemoved, files["templates/old.yaml"])
+
+	// Chart.yaml
+	metadata := make(map[string]models.FieldChange)
+	for _, f := range diff.Metadata {
+		metadata[f.Field] = f
+	}
+	assert.Equal(t, "1.0.0", metadata["version"].From)
+	assert.Equal(t, "1.1.0", metadata["version"].To)
+	assert.Equal(t, "2.0", metadata["appVersion"].To)
+	assert.NotContains(t, metadata, "name")
+
+	// values.yaml : seules les différences sémantiques sont remontées
+	values := make(map[string]models.ValueChange)
+	for _, v := range diff.Values {
+		values[v.Path] = v
+	}
+	assert.Len(t, values, 3)
+	assert.Equal(t, models.ValueChange{Path: "image.tag", Change: models.ChangeModified, From: "1.25", To: "1.26"}, values["image.tag"])
+	assert.Equal(t, models.ChangeAdded, values["ports[1]"].Change)
+	assert.Equal(t, "true", values["service.enabled"].To)
+
+	// Templates
+	templates := make(map[string]models.TemplateChange)
+	for _, tpl := range diff.Templates {
+		templates[tpl.Path] = tpl
+	}
+	assert.Equal(t, models.ChangeRemoved, templates["templates/old.yaml"].Change)
+	assert.Contains(t, templates["templates/configmap.yaml"].Diff, "+  name: {{ .Release.Name }}-cm")
+	assert.Contains(t, templates["templates/configmap.yaml"].Diff, "-  name: {{ .Release.Name }}")
+
+	// Version inexistante
+	_, err = chartService.DiffChartVersions("my-chart", "1.0.0", "9.9.9")
+	assert.Error(t, err)
+}
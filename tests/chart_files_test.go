@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"helm-portal/config"
+	service "helm-portal/pkg/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chartWithDocs retourne un chart avec un sous-chart et des fichiers de documentation
+func chartWithDocs() map[string]string {
+	files := validChartFiles("my-chart", "1.0.0")
+	files["my-chart/charts/sub/Chart.yaml"] = "apiVersion: v2\nname: sub\nversion: 0.1.0\n"
+	files["my-chart/charts/sub/values.yaml"] = "subchart: true\n"
+	files["my-chart/README.md"] = "# My chart\n\n<script>alert(1)</script>\n\n[link](javascript:alert(1))\n"
+	files["my-chart/templates/NOTES.txt"] = "Visit <http://{{ .Release.Name }}>\n"
+	files["my-chart/files/logo.png"] = "\x89PNG\x00\x01"
+	return files
+}
+
+func TestGetChartValues_IgnoresSubcharts(t *testing.T) {
+	chartService, _ := newTestChartService(t)
+	require.NoError(t, chartService.SaveChart(buildChartArchive(t, chartWithDocs()), "my-chart-1.0.0.tgz"))
+
+	values, err := chartService.GetChartValues("my-chart", "1.0.0")
+	require.NoError(t, err)
+	assert.Contains(t, values, "replicaCount: 1")
+	assert.NotContains(t, values, "subchart")
+}
+
+func TestListAndGetChartFiles(t *testing.T) {
+	chartService, _ := newTestChartService(t, func(cfg *config.Config) {
+		cfg.Charts.Browser.MaxFileSize = 150
+	})
+	files := chartWithDocs()
+	files["my-chart/files/big.txt"] = strings.Repeat("a", 300)
+	require.NoError(t, chartService.SaveChart(buildChartArchive(t, files), "my-chart-1.0.0.tgz"))
+
+	list, err := chartService.ListChartFiles("my-chart", "1.0.0")
+	require.NoError(t, err)
+	paths := make([]string, 0, len(list))
+	for _, f := range list {
+		paths = append(paths, f.Path)
+	}
+	assert.Contains(t, paths, "charts/sub/values.yaml")
+	assert.Contains(t, paths, "templates/configmap.yaml")
+	assert.IsIncreasing(t, paths)
+
+	tests := []struct {
+		name        string
+		path        string
+		expectedErr error
+		check       func(t *testing.T, html, content, language string, binary bool)
+	}{
+		{
+			name: "Template YAML",
+			path: "templates/configmap.yaml",
+			check: func(t *testing.T, html, content, language string, binary bool) {
+				assert.Equal(t, "yaml", language)
+				assert.Contains(t, content, "kind: ConfigMap")
+				assert.Empty(t, html)
+			},
+		},
+		{
+			name: "README rendu sans HTML dangereux",
+			path: "README.md",
+			check: func(t *testing.T, html, content, language string, binary bool) {
+				assert.Contains(t, html, "<h1>My chart</h1>")
+				assert.NotContains(t, html, "<script>")
+				assert.NotContains(t, html, "javascript:")
+			},
+		},
+		{
+			name: "NOTES.txt échappé",
+			path: "/templates/NOTES.txt",
+			check: func(t *testing.T, html, content, language string, binary bool) {
+				assert.Contains(t, html, "&lt;http://")
+			},
+		},
+		{
+			name: "Fichier binaire",
+			path: "files/logo.png",
+			check: func(t *testing.T, html, content, language string, binary bool) {
+				assert.True(t, binary)
+				assert.Empty(t, content)
+			},
+		},
+		{
+			name:        "Fichier trop volumineux",
+			path:        "files/big.txt",
+			expectedErr: service.ErrFileTooLarge,
+		},
+		{
+			name:        "Fichier inexistant",
+			path:        "templates/missing.yaml",
+			expectedErr: service.ErrFileNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := chartService.GetChartFile("my-chart", "1.0.0", tt.path)
+			if tt.expectedErr != nil {
+				assert.True(t, errors.Is(err, tt.expectedErr), "unexpected error: %v", err)
+				return
+			}
+			require.NoError(t, err)
+			tt.check(t, file.HTML, file.Content, file.Language, file.Binary)
+		})
+	}
+
+	_, err = chartService.GetChartFile("my-chart", "9.9.9", "README.md")
+	assert.True(t, errors.Is(err, service.ErrChartNotFound))
+}
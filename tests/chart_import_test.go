@@ -0,0 +1,286 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"helm-portal/pkg/handlers"
+	"helm-portal/pkg/models"
+	service "helm-portal/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testProvenance = "-----BEGIN PGP SIGNED MESSAGE-----\nHash: SHA512\n\nname: common\n-----BEGIN PGP SIGNATURE-----\n\nabc\n-----END PGP SIGNATURE-----\n"
+
+// newChartMuseumStandIn simule un dépôt Helm distant (ChartMuseum) protégé par Basic auth.
+// Les URLs de l'index sont relatives, sauf pour app qui utilise une URL absolue.
+func newChartMuseumStandIn(t *testing.T, corruptDigest bool) *httptest.Server {
+	t.Helper()
+
+	files := map[string][]byte{}
+	for _, c := range []struct{ name, version string }{
+		{"common", "1.0.0"}, {"common", "1.1.0"}, {"app", "0.1.0"},
+	} {
+		files[fmt.Sprintf("/charts/%s-%s.tgz", c.name, c.version)] = buildChartArchive(t, validChartFiles(c.name, c.version))
+	}
+	files["/charts/common-1.1.0.tgz.prov"] = []byte(testProvenance)
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "museum" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/index.yaml" {
+			digest := func(file string) string {
+				sum := sha256.Sum256(files[file])
+				return hex.EncodeToString(sum[:])
+			}
+			appDigest := digest("/charts/app-0.1.0.tgz")
+			if corruptDigest {
+				appDigest = strings.Repeat("0", 64)
+			}
+			fmt.Fprintf(w, `apiVersion: v1
+entries:
+  common:
+    - name: common
+      version: 1.1.0
+      digest: %s
+      urls: [charts/common-1.1.0.tgz]
+    - name: common
+      version: 1.0.0
+      digest: %s
+      urls: [charts/common-1.0.0.tgz]
+  app:
+    - name: app
+      version: 0.1.0
+      digest: %s
+      urls: [%s/charts/app-0.1.0.tgz]
+`, digest("/charts/common-1.1.0.tgz"), digest("/charts/common-1.0.0.tgz"), appDigest, server.URL)
+			return
+		}
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func itemStatuses(job *models.ImportJob) map[string]string {
+	statuses := make(map[string]string)
+	for _, item := range job.Items {
+		statuses[item.Name+"-"+item.Version] = item.Status
+	}
+	return statuses
+}
+
+func TestImport_RemoteRepository(t *testing.T) {
+	remote := newChartMuseumStandIn(t, false)
+	chartService, _ := newTestChartService(t)
+	importService := service.NewImportService(chartService, newTestLogger())
+	request := models.ImportRequest{URL: remote.URL, Username: "museum", Password: "secret"}
+
+	// Dry-run : rien n'est stocké
+	dryRun := request
+	dryRun.DryRun = true
+	job, err := importService.RunImport(dryRun)
+	require.NoError(t, err)
+	assert.Equal(t, models.ImportCompleted, job.Status)
+	assert.Equal(t, 3, job.Planned)
+	assert.Equal(t, 0, job.Imported)
+	assert.False(t, chartService.ChartExists("common", "1.0.0"))
+
+	// Import réel, dans l'ordre nom puis version
+	job, err = importService.RunImport(request)
+	require.NoError(t, err)
+	assert.Equal(t, models.ImportCompleted, job.Status)
+	assert.Equal(t, 3, job.Total)
+	assert.Equal(t, 3, job.Processed)
+	assert.Equal(t, 3, job.Imported)
+	require.Len(t, job.Items, 3)
+	assert.Equal(t, []string{"app", "common", "common"}, []string{job.Items[0].Name, job.Items[1].Name, job.Items[2].Name})
+	assert.Equal(t, "1.0.0", job.Items[1].Version)
+	assert.True(t, job.Items[2].Provenance)
+	assert.False(t, job.Items[1].Provenance)
+
+	for _, ref := range [][2]string{{"common", "1.0.0"}, {"common", "1.1.0"}, {"app", "0.1.0"}} {
+		assert.True(t, chartService.ChartExists(ref[0], ref[1]), ref[0]+"-"+ref[1])
+	}
+	prov, err := os.ReadFile(filepath.Join(chartService.GetPathManager().GetChartsPath(), "common-1.1.0.tgz.prov"))
+	require.NoError(t, err)
+	assert.Equal(t, testProvenance, string(prov))
+
+	// Relance incrémentale : tout est ignoré
+	job, err = importService.RunImport(request)
+	require.NoError(t, err)
+	assert.Equal(t, 3, job.Skipped)
+	assert.Equal(t, 0, job.Imported)
+
+	// La suppression d'un chart retire aussi sa provenance
+	require.NoError(t, chartService.DeleteChart("common", "1.1.0"))
+	assert.NoFileExists(t, filepath.Join(chartService.GetPathManager().GetChartsPath(), "common-1.1.0.tgz.prov"))
+
+	assert.Len(t, importService.ListJobs(), 3)
+}
+
+func TestImport_Failures(t *testing.T) {
+	t.Run("Digest incorrect", func(t *testing.T) {
+		remote := newChartMuseumStandIn(t, true)
+		chartService, _ := newTestChartService(t)
+		importService := service.NewImportService(chartService, newTestLogger())
+
+		job, err := importService.RunImport(models.ImportRequest{URL: remote.URL + "/index.yaml", Username: "museum", Password: "secret"})
+		require.NoError(t, err)
+		assert.Equal(t, models.ImportCompleted, job.Status)
+		assert.Equal(t, 1, job.Failed)
+		assert.Equal(t, 2, job.Imported)
+		assert.Equal(t, models.ImportItemFailed, itemStatuses(job)["app-0.1.0"])
+		assert.Contains(t, job.Items[0].Error, "digest mismatch")
+		assert.False(t, chartService.ChartExists("app", "0.1.0"))
+	})
+
+	t.Run("Credentials refusés", func(t *testing.T) {
+		remote := newChartMuseumStandIn(t, false)
+		chartService, _ := newTestChartService(t)
+		importService := service.NewImportService(chartService, newTestLogger())
+
+		job, err := importService.RunImport(models.ImportRequest{URL: remote.URL})
+		require.NoError(t, err)
+		assert.Equal(t, models.ImportFailed, job.Status)
+		assert.Contains(t, job.Error, "401")
+	})
+
+	tests := []struct {
+		name    string
+		request models.ImportRequest
+	}{
+		{"Source manquante", models.ImportRequest{}},
+		{"URL et dossier", models.ImportRequest{URL: "http://example.com", Directory: "/tmp"}},
+		{"Schéma non supporté", models.ImportRequest{URL: "ftp://example.com/index.yaml"}},
+		{"Dossier inexistant", models.ImportRequest{Directory: "/does/not/exist"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chartService, _ := newTestChartService(t)
+			importService := service.NewImportService(chartService, newTestLogger()).WithImportRoot(t.TempDir())
+			_, err := importService.RunImport(tt.request)
+			assert.True(t, errors.Is(err, service.ErrInvalidImportRequest))
+		})
+	}
+}
+
+func TestImport_LocalDirectory(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "museum")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "nested"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "common-1.0.0.tgz"), buildChartArchive(t, validChartFiles("common", "1.0.0")), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "common-1.0.0.tgz.prov"), []byte(testProvenance), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nested", "app-0.1.0.tgz"), buildChartArchive(t, validChartFiles("app", "0.1.0")), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken-1.0.0.tgz"), []byte("not a chart"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("ignored"), 0644))
+
+	chartService, _ := newTestChartService(t)
+	importService := service.NewImportService(chartService, newTestLogger()).WithImportRoot(root)
+
+	job, err := importService.RunImport(models.ImportRequest{Directory: dir})
+	require.NoError(t, err)
+	assert.Equal(t, 3, job.Total)
+	assert.Equal(t, 2, job.Imported)
+	assert.Equal(t, 1, job.Failed)
+	assert.True(t, chartService.ChartExists("app", "0.1.0"))
+	assert.FileExists(t, filepath.Join(chartService.GetPathManager().GetChartsPath(), "common-1.0.0.tgz.prov"))
+
+	// Un chemin relatif est résolu depuis la racine d'import
+	job, err = importService.RunImport(models.ImportRequest{Directory: "museum"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, job.Skipped)
+	assert.Equal(t, dir, job.Source)
+
+	outside := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "common-2.0.0.tgz"), buildChartArchive(t, validChartFiles("common", "2.0.0")), 0644))
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "link")))
+
+	forbidden := []struct {
+		name      string
+		directory string
+		service   *service.ImportService
+	}{
+		{"Dossier hors de la racine", outside, importService},
+		{"Remontée hors de la racine", "../" + filepath.Base(outside), importService},
+		{"Lien symbolique hors de la racine", "link", importService},
+		{"Sans racine d'import", dir, service.NewImportService(chartService, newTestLogger())},
+	}
+	for _, tt := range forbidden {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.service.RunImport(models.ImportRequest{Directory: tt.directory})
+			assert.True(t, errors.Is(err, service.ErrImportDirectoryForbidden), "%v", err)
+		})
+	}
+	assert.False(t, chartService.ChartExists("common", "2.0.0"))
+}
+
+func TestImportHandler(t *testing.T) {
+	remote := newChartMuseumStandIn(t, false)
+	chartService, _ := newTestChartService(t)
+	importHandler := handlers.NewImportHandler(service.NewImportService(chartService, newTestLogger()), newTestLogger())
+
+	app := fiber.New()
+	app.Post("/api/import", importHandler.StartImport)
+	app.Get("/api/import", importHandler.ListImports)
+	app.Get("/api/import/:id", importHandler.GetImport)
+
+	body, _ := json.Marshal(models.ImportRequest{URL: remote.URL, Username: "museum", Password: "secret"})
+	status, data, headers := doRequest(t, app, "POST", "/api/import", "", "", bytes.NewReader(body), "application/json")
+	require.Equal(t, fiber.StatusAccepted, status, string(data))
+	var job models.ImportJob
+	require.NoError(t, json.Unmarshal(data, &job))
+	assert.Equal(t, "/api/import/"+job.ID, headers.Get("Location"))
+
+	// Suivi de la progression jusqu'à la fin du job
+	require.Eventually(t, func() bool {
+		status, data, _ := doRequest(t, app, "GET", "/api/import/"+job.ID, "", "", nil, "")
+		require.Equal(t, 200, status)
+		require.NoError(t, json.Unmarshal(data, &job))
+		return job.Status != models.ImportRunning
+	}, 10*time.Second, 20*time.Millisecond)
+	assert.Equal(t, models.ImportCompleted, job.Status)
+	assert.Equal(t, 3, job.Imported)
+
+	tests := []struct {
+		name     string
+		method   string
+		url      string
+		body     string
+		expected int
+	}{
+		{"Requête invalide", "POST", "/api/import", `{"url": "not a url"}`, 400},
+		{"JSON invalide", "POST", "/api/import", `{`, 400},
+		{"Dossier sans racine d'import", "POST", "/api/import", `{"directory": "/tmp"}`, 403},
+		{"Job inconnu", "GET", "/api/import/unknown", "", 404},
+		{"Liste des jobs", "GET", "/api/import", "", 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, _, _ := doRequest(t, app, tt.method, tt.url, "", "", strings.NewReader(tt.body), "application/json")
+			assert.Equal(t, tt.expected, status)
+		})
+	}
+}
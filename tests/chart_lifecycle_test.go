@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"helm-portal/pkg/models"
+	service "helm-portal/pkg/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+// readIndexVersions lit index.yaml et retourne les entrées d'un chart par version
+func readIndexVersions(t *testing.T, storagePath, name string) map[string]service.ChartVersion {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(storagePath, "index.yaml"))
+	require.NoError(t, err)
+
+	var index service.IndexFile
+	require.NoError(t, yaml.Unmarshal(data, &index))
+
+	versions := make(map[string]service.ChartVersion)
+	for _, v := range index.Entries[name] {
+		versions[v.Version] = *v
+	}
+	return versions
+}
+
+func TestChartLifecycle_DeprecateAndYank(t *testing.T) {
+	chartService, cfg := newTestChartService(t)
+
+	archives := make(map[string][]byte)
+	for _, version := range []string{"1.0.0", "1.1.0", "1.2.0"} {
+		archives[version] = buildChartArchive(t, validChartFiles("my-chart", version))
+		require.NoError(t, chartService.SaveChart(archives[version], "my-chart-"+version+".tgz"))
+	}
+	assert.Len(t, readIndexVersions(t, cfg.Storage.Path, "my-chart"), 3)
+
+	// Une raison est obligatoire
+	_, err := chartService.DeprecateChart("my-chart", "1.0.0", "  ", "alice")
+	assert.True(t, errors.Is(err, service.ErrReasonRequired))
+	_, err = chartService.YankChart("my-chart", "9.9.9", "broken", "alice")
+	assert.True(t, errors.Is(err, service.ErrChartNotFound))
+
+	// Dépréciation : reste dans l'index avec deprecated: true
+	status, err := chartService.DeprecateChart("my-chart", "1.0.0", "use 1.2.x", "alice")
+	require.NoError(t, err)
+	assert.True(t, status.Deprecated)
+	index := readIndexVersions(t, cfg.Storage.Path, "my-chart")
+	assert.True(t, index["1.0.0"].Deprecated)
+	assert.False(t, index["1.1.0"].Deprecated)
+
+	// Yank : disparaît de l'index et des listes de versions
+	_, err = chartService.YankChart("my-chart", "1.2.0", "CVE-2024-0001", "bob")
+	require.NoError(t, err)
+	index = readIndexVersions(t, cfg.Storage.Path, "my-chart")
+	assert.NotContains(t, index, "1.2.0")
+	assert.Len(t, index, 2)
+
+	versions, err := chartService.GetChartVersions("my-chart")
+	require.NoError(t, err)
+	for _, v := range versions {
+		assert.NotEqual(t, "1.2.0", v.Version)
+	}
+	resolved, err := chartService.ResolveChartVersion("my-chart", "^1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "1.1.0", resolved.Version)
+
+	// ...mais reste téléchargeable par version exacte et par digest
+	data, err := chartService.GetChart("my-chart", "1.2.0")
+	require.NoError(t, err)
+	assert.Equal(t, archives["1.2.0"], data)
+	data, err = chartService.GetChart("my-chart", "sha256:"+sha256Hex(string(archives["1.2.0"])))
+	require.NoError(t, err)
+	assert.Equal(t, archives["1.2.0"], data)
+
+	// Historique : raison et auteur de chaque opération
+	status, err = chartService.GetChartStatus("my-chart", "1.2.0")
+	require.NoError(t, err)
+	require.Len(t, status.History, 1)
+	assert.Equal(t, models.LifecycleYank, status.History[0].Action)
+	assert.Equal(t, "bob", status.History[0].Actor)
+	assert.Equal(t, "CVE-2024-0001", status.History[0].Reason)
+	assert.False(t, status.History[0].At.IsZero())
+
+	// Réintégration
+	status, err = chartService.ReinstateChart("my-chart", "1.2.0", "false positive", "alice")
+	require.NoError(t, err)
+	assert.False(t, status.Yanked)
+	assert.Len(t, status.History, 2)
+	assert.Contains(t, readIndexVersions(t, cfg.Storage.Path, "my-chart"), "1.2.0")
+}
@@ -0,0 +1,236 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"testing"
+
+	"helm-portal/config"
+	"helm-portal/pkg/handlers"
+	middleware "helm-portal/pkg/middlewares"
+	"helm-portal/pkg/models"
+	service "helm-portal/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart/loader"
+)
+
+// buildZipArchive crée une archive .zip en mémoire à partir d'une map chemin -> contenu
+func buildZipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestPackageChart(t *testing.T) {
+	withoutTopDir := map[string]string{
+		"Chart.yaml":  "apiVersion: v2\nname: flat\nversion: 0.3.0\ndescription: x\n",
+		"values.yaml": "replicaCount: 1\n",
+		".helmignore": "*.md\n",
+		"README.md":   "ignored",
+	}
+
+	tests := []struct {
+		name            string
+		source          []byte
+		version         string
+		expectedName    string
+		expectedVersion string
+	}{
+		{"Tar.gz avec dossier racine", buildChartArchive(t, validChartFiles("my-chart", "1.0.0")), "", "my-chart", "1.0.0"},
+		{"Zip avec dossier racine", buildZipArchive(t, validChartFiles("my-chart", "1.1.0")), "", "my-chart", "1.1.0"},
+		{"Surcharge de version", buildChartArchive(t, validChartFiles("my-chart", "1.0.0")), "2.0.0-rc.1", "my-chart", "2.0.0-rc.1"},
+		{"Zip sans dossier racine, .helmignore appliqué", buildZipArchive(t, withoutTopDir), "", "flat", "0.3.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chartService, _ := newTestChartService(t)
+
+			result, err := chartService.PackageChart(tt.source, tt.version)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedName, result.Name)
+			assert.Equal(t, tt.expectedVersion, result.Version)
+			assert.Equal(t, tt.expectedName+"-"+tt.expectedVersion+".tgz", result.Filename)
+			assert.Regexp(t, `^sha256:[0-9a-f]{64}$`, result.Digest)
+			assert.Equal(t, len(result.Archive), result.Size)
+
+			// Le chart stocké est exactement l'archive retournée
+			stored, err := chartService.GetChart(tt.expectedName, tt.expectedVersion)
+			require.NoError(t, err)
+			assert.Equal(t, result.Archive, stored)
+
+			packaged, err := loader.LoadArchive(bytes.NewReader(stored))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedVersion, packaged.Metadata.Version)
+			for _, f := range packaged.Raw {
+				assert.NotEqual(t, "README.md", f.Name)
+			}
+		})
+	}
+}
+
+func TestPackageChart_VendorsDependencies(t *testing.T) {
+	chartService, _ := newTestChartService(t, func(cfg *config.Config) {
+		cfg.Charts.Dependencies.HostedRepositories = []string{"https://charts.example.com"}
+	})
+
+	for _, version := range []string{"1.0.0", "1.2.0", "2.0.0"} {
+		data := buildChartArchive(t, validChartFiles("common", version))
+		require.NoError(t, chartService.SaveChart(data, "common-"+version+".tgz"))
+	}
+
+	files := chartWithDependencies("app", "1.0.0", ""+
+		"  - name: common\n    version: ^1.0.0\n    repository: https://charts.example.com\n"+
+		"  - name: lib\n    version: 0.1.0\n    repository: file://../lib\n"+
+		"  - name: vendored\n    version: 3.0.0\n    repository: https://elsewhere.example.com\n")
+	for name, content := range validChartFiles("lib", "0.1.0") {
+		files[name] = content
+	}
+	files["app/charts/vendored-3.0.0.tgz"] = string(buildChartArchive(t, validChartFiles("vendored", "3.0.0")))
+
+	result, err := chartService.PackageChart(buildChartArchive(t, files), "")
+	require.NoError(t, err)
+
+	// Seules les dépendances absentes de charts/ sont ajoutées
+	assert.Equal(t, []models.VendoredDependency{
+		{Name: "common", Version: "1.2.0", Source: "hosted"},
+		{Name: "lib", Version: "0.1.0", Source: "local"},
+	}, result.Vendored)
+
+	packaged, err := loader.LoadArchive(bytes.NewReader(result.Archive))
+	require.NoError(t, err)
+	versions := make(map[string]string)
+	for _, dep := range packaged.Dependencies() {
+		versions[dep.Name()] = dep.Metadata.Version
+	}
+	assert.Equal(t, map[string]string{"common": "1.2.0", "lib": "0.1.0", "vendored": "3.0.0"}, versions)
+}
+
+func TestPackageChart_Errors(t *testing.T) {
+	unresolved := chartWithDependencies("app", "1.0.0",
+		"  - name: redis\n    version: ~17.0.0\n    repository: oci://registry-1.docker.io/bitnamicharts\n")
+	escaping := chartWithDependencies("app", "1.0.0",
+		"  - name: lib\n    version: 0.1.0\n    repository: file://../../lib\n")
+	noVersionMatch := chartWithDependencies("app", "1.0.0",
+		"  - name: common\n    version: ^5.0.0\n    repository: oci://helm.example.com/charts\n")
+	invalidChart := map[string]string{
+		"app/Chart.yaml":            "apiVersion: v2\nname: app\nversion: 1.0.0\n",
+		"app/templates/broken.yaml": "{{ .Values.x ",
+	}
+
+	tests := []struct {
+		name     string
+		source   []byte
+		version  string
+		expected error
+	}{
+		{"Archive illisible", []byte("not an archive"), "", service.ErrInvalidPackageSource},
+		{"Sans Chart.yaml", buildChartArchive(t, map[string]string{"values.yaml": "a: 1\n"}), "", service.ErrInvalidPackageSource},
+		{"Chemin hors de l'archive", buildChartArchive(t, map[string]string{"../Chart.yaml": "name: x\n"}), "", service.ErrInvalidPackageSource},
+		{"Version invalide", buildChartArchive(t, validChartFiles("my-chart", "1.0.0")), "v1", service.ErrInvalidPackageSource},
+		{"Dépendance externe", buildChartArchive(t, unresolved), "", service.ErrDependencyUnresolved},
+		{"Dépendance file:// hors de l'archive", buildChartArchive(t, escaping), "", service.ErrDependencyUnresolved},
+		{"Aucune version hébergée compatible", buildChartArchive(t, noVersionMatch), "", service.ErrDependencyUnresolved},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chartService, _ := newTestChartService(t, func(cfg *config.Config) {
+				cfg.Charts.Dependencies.HostedRepositories = []string{"oci://helm.example.com"}
+			})
+			require.NoError(t, chartService.SaveChart(buildChartArchive(t, validChartFiles("common", "1.0.0")), "common-1.0.0.tgz"))
+
+			_, err := chartService.PackageChart(tt.source, tt.version)
+			assert.True(t, errors.Is(err, tt.expected), "erreur inattendue : %v", err)
+		})
+	}
+
+	t.Run("Chart invalide", func(t *testing.T) {
+		chartService, _ := newTestChartService(t)
+
+		result, err := chartService.PackageChart(buildChartArchive(t, invalidChart), "")
+		var validationErr *service.ChartValidationError
+		require.True(t, errors.As(err, &validationErr))
+		require.NotNil(t, result)
+		assert.NotEmpty(t, result.Findings)
+		assert.False(t, chartService.ChartExists("app", "1.0.0"))
+	})
+}
+
+func TestPackageChartHandler(t *testing.T) {
+	chartService, cfg := newTestChartService(t, func(cfg *config.Config) {
+		cfg.Auth.Users = []config.User{{Username: "alice", Password: "alice123"}}
+	})
+	helmHandler := handlers.NewHelmHandler(chartService, chartService.GetPathManager(), newTestLogger())
+	authMiddleware := middleware.NewAuthMiddleware(cfg, newTestLogger())
+	app := fiber.New()
+	app.Post("/api/package", authMiddleware.Authenticate(), helmHandler.PackageChart)
+
+	user, password := "alice", "alice123"
+	post := func(source []byte, version string) (int, []byte) {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		if source != nil {
+			part, err := writer.CreateFormFile("source", "chart.zip")
+			require.NoError(t, err)
+			_, err = part.Write(source)
+			require.NoError(t, err)
+		}
+		if version != "" {
+			require.NoError(t, writer.WriteField("version", version))
+		}
+		require.NoError(t, writer.Close())
+		status, data, _ := doRequest(t, app, "POST", "/api/package", user, password, &body, writer.FormDataContentType())
+		return status, data
+	}
+
+	source := buildZipArchive(t, validChartFiles("my-chart", "1.0.0"))
+
+	status, data := post(source, "1.5.0")
+	require.Equal(t, fiber.StatusCreated, status, string(data))
+	var result models.PackageResult
+	require.NoError(t, json.Unmarshal(data, &result))
+	assert.Equal(t, "my-chart-1.5.0.tgz", result.Filename)
+	assert.NotEmpty(t, result.Archive)
+	assert.True(t, chartService.ChartExists("my-chart", "1.5.0"))
+
+	tests := []struct {
+		name     string
+		source   []byte
+		version  string
+		expected int
+	}{
+		{"Sans archive", nil, "", 400},
+		{"Archive invalide", []byte("garbage"), "", 400},
+		{"Dépendance non résolue", buildChartArchive(t, chartWithDependencies("app", "1.0.0",
+			"  - name: redis\n    version: 1.0.0\n    repository: https://charts.example.com\n")), "", 422},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, _ := post(tt.source, tt.version)
+			assert.Equal(t, tt.expected, status)
+		})
+	}
+
+	t.Run("Sans authentification", func(t *testing.T) {
+		user, password = "", ""
+		status, _ := post(buildZipArchive(t, validChartFiles("other-chart", "1.0.0")), "")
+		assert.Equal(t, 401, status)
+		assert.False(t, chartService.ChartExists("other-chart", "1.0.0"))
+	})
+}
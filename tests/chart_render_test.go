@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"helm-portal/pkg/models"
+	service "helm-portal/pkg/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderChart_DefaultAndCustomValues(t *testing.T) {
+	chartService, _ := newTestChartService(t)
+
+	data := buildChartArchive(t, validChartFiles("my-chart", "1.0.0"))
+	require.NoError(t, chartService.SaveChart(data, "my-chart-1.0.0.tgz"))
+
+	// Rendu avec les valeurs par défaut
+	result, err := chartService.RenderChart("my-chart", "1.0.0", models.RenderOptions{})
+	require.NoError(t, err)
+	require.Len(t, result.Templates, 1)
+	assert.Equal(t, "my-chart/templates/configmap.yaml", result.Templates[0].Path)
+	assert.Contains(t, result.Templates[0].Content, "name: release-name")
+	assert.Contains(t, result.Templates[0].Content, `replicas: "1"`)
+
+	// Rendu avec des valeurs fournies par l'utilisateur
+	result, err = chartService.RenderChart("my-chart", "1.0.0", models.RenderOptions{
+		Values:      "replicaCount: 5\n",
+		ReleaseName: "prod",
+	})
+	require.NoError(t, err)
+	assert.Contains(t, result.Templates[0].Content, "name: prod")
+	assert.Contains(t, result.Templates[0].Content, `replicas: "5"`)
+
+	// Des valeurs YAML invalides sont refusées
+	_, err = chartService.RenderChart("my-chart", "1.0.0", models.RenderOptions{Values: "a: [b"})
+	assert.True(t, errors.Is(err, service.ErrInvalidValues))
+}
+
+func TestRenderChart_ErrorsPerTemplate(t *testing.T) {
+	chartService, _ := newTestChartService(t)
+
+	files := validChartFiles("my-chart", "1.0.0")
+	files["my-chart/templates/_helpers.tpl"] = `{{- define "my-chart.fullname" -}}{{ .Release.Name }}-app{{- end -}}`
+	files["my-chart/templates/service.yaml"] = "kind: Service\nmetadata:\n  name: {{ include \"my-chart.fullname\" . }}\n"
+	files["my-chart/templates/secret.yaml"] = "kind: Secret\ndata:\n  password: {{ required \"password is required\" .Values.password }}\n"
+	files["my-chart/templates/NOTES.txt"] = "Installed {{ .Release.Name }}"
+	require.NoError(t, chartService.SaveChart(buildChartArchive(t, files), "my-chart-1.0.0.tgz"))
+
+	result, err := chartService.RenderChart("my-chart", "1.0.0", models.RenderOptions{})
+	require.NoError(t, err)
+	assert.True(t, result.HasErrors())
+	assert.Equal(t, "Installed release-name", result.Notes)
+
+	byPath := make(map[string]models.RenderedTemplate)
+	for _, tpl := range result.Templates {
+		byPath[tpl.Path] = tpl
+	}
+
+	// Le template en erreur est signalé, les autres sont rendus
+	assert.Contains(t, byPath["my-chart/templates/secret.yaml"].Error, "password is required")
+	assert.Contains(t, byPath["my-chart/templates/service.yaml"].Content, "name: release-name-app")
+	assert.Empty(t, byPath["my-chart/templates/configmap.yaml"].Error)
+}
@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	service "helm-portal/pkg/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testValuesSchema = `{
+  "type": "object",
+  "required": ["replicaCount"],
+  "properties": {
+    "replicaCount": {"type": "integer", "minimum": 1},
+    "image": {
+      "type": "object",
+      "properties": {
+        "repository": {"type": "string"},
+        "pullPolicy": {"enum": ["Always", "IfNotPresent"]}
+      }
+    }
+  }
+}`
+
+func TestValidateValues(t *testing.T) {
+	chartService, _ := newTestChartService(t)
+
+	files := validChartFiles("my-chart", "1.0.0")
+	files["my-chart/values.schema.json"] = testValuesSchema
+	require.NoError(t, chartService.SaveChart(buildChartArchive(t, files), "my-chart-1.0.0.tgz"))
+	require.NoError(t, chartService.SaveChart(buildChartArchive(t, validChartFiles("my-chart", "2.0.0")), "my-chart-2.0.0.tgz"))
+
+	tests := []struct {
+		name          string
+		values        string
+		expectedPaths []string
+	}{
+		{name: "Valeurs par défaut", values: ""},
+		{name: "Surcharge valide", values: "image:\n  pullPolicy: Always\n"},
+		{name: "Entier hors limites", values: "replicaCount: 0\n", expectedPaths: []string{"/replicaCount"}},
+		{
+			name:          "Plusieurs erreurs",
+			values:        "replicaCount: two\nimage:\n  repository: 42\n  pullPolicy: Never\n",
+			expectedPaths: []string{"/image/pullPolicy", "/image/repository", "/replicaCount"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := chartService.ValidateValues("my-chart", "1.0.0", tt.values)
+			require.NoError(t, err)
+			assert.True(t, result.HasSchema)
+			assert.Equal(t, len(tt.expectedPaths) == 0, result.Valid, "errors: %+v", result.Errors)
+
+			paths := []string{}
+			for _, e := range result.Errors {
+				paths = append(paths, e.Path)
+				assert.NotEmpty(t, e.Message)
+			}
+			if len(tt.expectedPaths) > 0 {
+				assert.Equal(t, tt.expectedPaths, paths)
+			}
+		})
+	}
+
+	// YAML invalide
+	_, err := chartService.ValidateValues("my-chart", "1.0.0", "a: [b")
+	assert.True(t, errors.Is(err, service.ErrInvalidValues))
+
+	// Chart sans schéma : toujours valide
+	result, err := chartService.ValidateValues("my-chart", "2.0.0", "replicaCount: 0\n")
+	require.NoError(t, err)
+	assert.False(t, result.HasSchema)
+	assert.True(t, result.Valid)
+
+	// Lecture du schéma
+	schema, err := chartService.GetValuesSchema("my-chart", "1.0.0")
+	require.NoError(t, err)
+	assert.True(t, json.Valid(schema))
+	_, err = chartService.GetValuesSchema("my-chart", "2.0.0")
+	assert.True(t, errors.Is(err, service.ErrSchemaNotFound))
+	_, err = chartService.GetValuesSchema("my-chart", "9.9.9")
+	assert.True(t, errors.Is(err, service.ErrChartNotFound))
+}
+
+func TestValidateValues_ExternalReference(t *testing.T) {
+	chartService, _ := newTestChartService(t)
+
+	// Schéma lisible sur le serveur : le valider permettrait de sonder ses fichiers
+	serverSchema := filepath.Join(t.TempDir(), "server.schema.json")
+	require.NoError(t, os.WriteFile(serverSchema, []byte(`{"type": "object"}`), 0644))
+
+	files := validChartFiles("my-chart", "1.0.0")
+	files["my-chart/values.schema.json"] = `{"$ref": "file://` + filepath.ToSlash(serverSchema) + `"}`
+	archive := buildChartArchive(t, files)
+	require.NoError(t, os.WriteFile(filepath.Join(chartService.GetPathManager().GetChartsPath(), "my-chart-1.0.0.tgz"), archive, 0644))
+
+	_, err := chartService.ValidateValues("my-chart", "1.0.0", "replicaCount: 1\n")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not allowed")
+}
@@ -0,0 +1,214 @@
+package tests
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"helm-portal/config"
+	service "helm-portal/pkg/services"
+	"helm-portal/pkg/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildChartArchive crée une archive .tgz en mémoire à partir d'une map chemin -> contenu
+func buildChartArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+
+	for name, content := range files {
+		err := tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		})
+		require.NoError(t, err)
+		_, err = tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+	return buf.Bytes()
+}
+
+// validChartFiles retourne les fichiers d'un chart minimal valide
+func validChartFiles(name, version string) map[string]string {
+	return map[string]string{
+		name + "/Chart.yaml":               "apiVersion: v2\nname: " + name + "\nversion: " + version + "\ndescription: test chart\n",
+		name + "/values.yaml":              "replicaCount: 1\nimage:\n  repository: nginx\n",
+		name + "/templates/configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .Release.Name }}\ndata:\n  replicas: {{ .Values.replicaCount | quote }}\n",
+	}
+}
+
+// newTestLogger crée un logger silencieux pour les tests
+func newTestLogger() *utils.Logger {
+	return utils.NewLogger(utils.Config{LogLevel: "error", LogFormat: "text"})
+}
+
+// newTestChartService crée un ChartService branché sur un IndexService dans un répertoire temporaire.
+// Les fonctions optionnelles permettent d'ajuster la configuration avant la création.
+func newTestChartService(t *testing.T, opts ...func(*config.Config)) (*service.ChartService, *config.Config) {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.Storage.Path = t.TempDir()
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	log := newTestLogger()
+
+	tmpChartService := service.NewChartService(cfg, log, nil)
+	indexService := service.NewIndexService(cfg, log, tmpChartService)
+	return service.NewChartService(cfg, log, indexService), cfg
+}
+
+func TestChartValidator_Findings(t *testing.T) {
+	validator := service.NewChartValidator(&config.Config{}, newTestLogger())
+
+	// Schéma lisible sur le serveur, qu'un chart ne doit pas pouvoir référencer
+	serverSchema := filepath.Join(t.TempDir(), "server.schema.json")
+	require.NoError(t, os.WriteFile(serverSchema, []byte(`{"type": "object"}`), 0644))
+
+	tests := []struct {
+		name         string
+		files        map[string]string
+		filename     string
+		expectedRule string
+	}{
+		{
+			name:     "Chart valide",
+			files:    validChartFiles("my-chart", "1.0.0"),
+			filename: "my-chart-1.0.0.tgz",
+		},
+		{
+			name: "Version non semver",
+			files: map[string]string{
+				"my-chart/Chart.yaml": "apiVersion: v2\nname: my-chart\nversion: v1.0\ndescription: x\n",
+			},
+			filename:     "my-chart-v1.0.tgz",
+			expectedRule: "chart-version",
+		},
+		{
+			name: "Champ name manquant",
+			files: map[string]string{
+				"my-chart/Chart.yaml": "apiVersion: v2\nversion: 1.0.0\n",
+			},
+			expectedRule: "chart-yaml",
+		},
+		{
+			name:         "Nom de fichier incohérent",
+			files:        validChartFiles("my-chart", "1.0.0"),
+			filename:     "other-2.0.0.tgz",
+			expectedRule: "filename",
+		},
+		{
+			name: "Plusieurs répertoires racine",
+			files: map[string]string{
+				"my-chart/Chart.yaml": "apiVersion: v2\nname: my-chart\nversion: 1.0.0\n",
+				"other/Chart.yaml":    "apiVersion: v2\nname: other\nversion: 1.0.0\n",
+			},
+			expectedRule: "archive-layout",
+		},
+		{
+			name: "Template invalide",
+			files: map[string]string{
+				"my-chart/Chart.yaml":          "apiVersion: v2\nname: my-chart\nversion: 1.0.0\ndescription: x\n",
+				"my-chart/templates/bad.yaml":  "{{ if .Values.enabled }}\nkind: Pod\n",
+				"my-chart/templates/good.yaml": "{{ include \"x\" . | nindent 4 }}",
+			},
+			expectedRule: "template-parse",
+		},
+		{
+			name: "Schéma JSON invalide",
+			files: map[string]string{
+				"my-chart/Chart.yaml":         "apiVersion: v2\nname: my-chart\nversion: 1.0.0\ndescription: x\n",
+				"my-chart/values.schema.json": `{"type": "object", "properties": {"replicas": {"type": 12}}}`,
+			},
+			expectedRule: "values-schema",
+		},
+		{
+			name: "Référence interne au schéma",
+			files: map[string]string{
+				"my-chart/Chart.yaml":         "apiVersion: v2\nname: my-chart\nversion: 1.0.0\ndescription: x\n",
+				"my-chart/values.schema.json": `{"$schema": "http://json-schema.org/draft-07/schema#", "definitions": {"port": {"type": "integer"}}, "properties": {"port": {"$ref": "#/definitions/port"}}}`,
+			},
+			filename: "my-chart-1.0.0.tgz",
+		},
+		{
+			name: "Référence à un fichier du serveur",
+			files: map[string]string{
+				"my-chart/Chart.yaml":         "apiVersion: v2\nname: my-chart\nversion: 1.0.0\ndescription: x\n",
+				"my-chart/values.schema.json": `{"$ref": "file://` + filepath.ToSlash(serverSchema) + `"}`,
+			},
+			expectedRule: "values-schema",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := validator.Validate(buildChartArchive(t, tt.files), tt.filename)
+
+			if tt.expectedRule == "" {
+				assert.False(t, report.HasErrors(), "findings: %+v", report.Findings)
+				return
+			}
+
+			assert.True(t, report.HasErrors())
+			found := false
+			for _, f := range report.Errors() {
+				if f.Rule == tt.expectedRule {
+					found = true
+				}
+			}
+			assert.True(t, found, "rule %s not found in %+v", tt.expectedRule, report.Findings)
+		})
+	}
+}
+
+func TestChartValidator_MaxArchiveSize(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Charts.Validation.MaxArchiveSize = 16
+	validator := service.NewChartValidator(cfg, newTestLogger())
+
+	report := validator.Validate(buildChartArchive(t, validChartFiles("my-chart", "1.0.0")), "my-chart-1.0.0.tgz")
+	require.True(t, report.HasErrors())
+	assert.Equal(t, "archive-size", report.Findings[0].Rule)
+}
+
+func TestSaveChart_RejectsBeforeWriting(t *testing.T) {
+	chartService, cfg := newTestChartService(t)
+
+	data := buildChartArchive(t, validChartFiles("my-chart", "1.0.0"))
+	err := chartService.SaveChart(data, "wrong-name.tgz")
+
+	var validationErr *service.ChartValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.NotEmpty(t, validationErr.Report.Findings)
+
+	// Rien ne doit avoir été écrit sur le disque
+	_, statErr := os.Stat(filepath.Join(cfg.Storage.Path, "charts", "wrong-name.tgz"))
+	assert.True(t, os.IsNotExist(statErr))
+
+	// Le même chart avec le bon nom est accepté
+	require.NoError(t, chartService.SaveChart(data, "my-chart-1.0.0.tgz"))
+	assert.True(t, chartService.ChartExists("my-chart", "1.0.0"))
+}
+
+// sha256Hex retourne le hash SHA256 hexadécimal d'une chaîne
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"helm-portal/pkg/models"
+	service "helm-portal/pkg/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortChartVersions_Semver(t *testing.T) {
+	versions := []models.ChartMetadata{
+		{Version: "1.10.0"},
+		{Version: "1.2.0"},
+		{Version: "2.0.0-rc.1"},
+		{Version: "not-semver"},
+		{Version: "2.0.0"},
+		{Version: "1.2.0-alpha"},
+	}
+
+	models.SortChartVersions(versions)
+
+	got := make([]string, len(versions))
+	for i, v := range versions {
+		got[i] = v.Version
+	}
+	assert.Equal(t, []string{"2.0.0", "2.0.0-rc.1", "1.10.0", "1.2.0", "1.2.0-alpha", "not-semver"}, got)
+}
+
+func TestGroupChartsByName_Sorted(t *testing.T) {
+	groups := models.GroupChartsByName([]models.ChartMetadata{
+		{Name: "zeta", Version: "0.1.0"},
+		{Name: "alpha", Version: "0.9.0"},
+		{Name: "alpha", Version: "0.10.0"},
+	})
+
+	require.Len(t, groups, 2)
+	assert.Equal(t, "alpha", groups[0].Name)
+	assert.Equal(t, "zeta", groups[1].Name)
+	assert.Equal(t, "0.10.0", groups[0].Versions[0].Version)
+}
+
+func TestResolveChartVersion(t *testing.T) {
+	chartService, _ := newTestChartService(t)
+
+	// Publier plusieurs versions du même chart
+	for _, version := range []string{"1.2.0", "1.2.5", "1.3.0", "2.0.0-beta.1", "2.1.0", "3.0.0"} {
+		data := buildChartArchive(t, validChartFiles("my-chart", version))
+		require.NoError(t, chartService.SaveChart(data, "my-chart-"+version+".tgz"))
+	}
+
+	tests := []struct {
+		name       string
+		constraint string
+		expected   string
+		expectErr  error
+	}{
+		{name: "Tilde", constraint: "~1.2", expected: "1.2.5"},
+		{name: "Intervalle", constraint: ">=2.0 <3", expected: "2.1.0"},
+		{name: "Caret", constraint: "^1", expected: "1.3.0"},
+		{name: "Pré-release explicite", constraint: "2.0.0-beta.1", expected: "2.0.0-beta.1"},
+		{name: "Aucune correspondance", constraint: ">=4", expectErr: service.ErrNoMatchingVersion},
+		{name: "Contrainte invalide", constraint: "~~x", expectErr: service.ErrInvalidConstraint},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata, err := chartService.ResolveChartVersion("my-chart", tt.constraint)
+			if tt.expectErr != nil {
+				assert.True(t, errors.Is(err, tt.expectErr), "unexpected error: %v", err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, metadata.Version)
+		})
+	}
+
+	_, err := chartService.ResolveChartVersion("missing", "*")
+	assert.True(t, errors.Is(err, service.ErrChartNotFound))
+}
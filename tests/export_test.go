@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/url"
+	"testing"
+
+	"helm-portal/pkg/handlers"
+	"helm-portal/pkg/models"
+	service "helm-portal/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+// readExportBundle retourne le contenu d'une archive d'export (chemin -> contenu)
+func readExportBundle(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+
+	gr, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	tr := tar.NewReader(gr)
+	files := make(map[string][]byte)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[header.Name] = content
+	}
+	return files
+}
+
+// newExportFixture stocke nginx (3 versions dont une yankée, avec provenance) et redis
+func newExportFixture(t *testing.T) (*service.ChartService, *service.IndexService) {
+	t.Helper()
+
+	chartService, cfg := newTestChartService(t)
+	for _, c := range []struct{ name, version string }{
+		{"nginx", "1.0.0"}, {"nginx", "1.10.0"}, {"nginx", "1.2.0"}, {"nginx", "2.0.0"}, {"redis", "7.0.0"},
+	} {
+		data := buildChartArchive(t, validChartFiles(c.name, c.version))
+		require.NoError(t, chartService.SaveChart(data, c.name+"-"+c.version+".tgz"))
+	}
+	require.NoError(t, chartService.SaveProvenance("nginx-2.0.0.tgz", []byte(testProvenance)))
+	_, err := chartService.YankChart("nginx", "1.10.0", "broken", "alice")
+	require.NoError(t, err)
+
+	return chartService, service.NewIndexService(cfg, newTestLogger(), chartService)
+}
+
+func TestExport(t *testing.T) {
+	chartService, indexService := newExportFixture(t)
+
+	tests := []struct {
+		name     string
+		opts     models.ExportOptions
+		expected map[string][]string
+	}{
+		{"Tous les charts", models.ExportOptions{}, map[string][]string{
+			"nginx": {"2.0.0", "1.2.0", "1.0.0"},
+			"redis": {"7.0.0"},
+		}},
+		{"Motif sur le nom", models.ExportOptions{Pattern: "ngi*"}, map[string][]string{
+			"nginx": {"2.0.0", "1.2.0", "1.0.0"},
+		}},
+		{"N dernières versions", models.ExportOptions{Latest: 2}, map[string][]string{
+			"nginx": {"2.0.0", "1.2.0"},
+			"redis": {"7.0.0"},
+		}},
+		{"Aucun chart", models.ExportOptions{Pattern: "postgres"}, map[string][]string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			index, err := indexService.Export(&buf, tt.opts)
+			require.NoError(t, err)
+
+			files := readExportBundle(t, buf.Bytes())
+			require.Contains(t, files, "index.yaml")
+			var exported service.IndexFile
+			require.NoError(t, yaml.Unmarshal(files["index.yaml"], &exported))
+			assert.Equal(t, index.CountVersions(), exported.CountVersions())
+
+			versions := make(map[string][]string)
+			for name, entries := range exported.Entries {
+				for _, entry := range entries {
+					versions[name] = append(versions[name], entry.Version)
+
+					// URL relative, archive incluse et identique au chart stocké
+					require.Len(t, entry.URLs, 1)
+					u, err := url.Parse(entry.URLs[0])
+					require.NoError(t, err)
+					assert.False(t, u.IsAbs())
+					assert.Equal(t, "charts/"+name+"-"+entry.Version+".tgz", entry.URLs[0])
+					stored, err := chartService.GetChart(name, entry.Version)
+					require.NoError(t, err)
+					assert.Equal(t, stored, files[entry.URLs[0]])
+				}
+			}
+			assert.Equal(t, tt.expected, versions)
+		})
+	}
+
+	// Le fichier de provenance accompagne son archive
+	var buf bytes.Buffer
+	_, err := indexService.Export(&buf, models.ExportOptions{Pattern: "nginx"})
+	require.NoError(t, err)
+	files := readExportBundle(t, buf.Bytes())
+	assert.Equal(t, testProvenance, string(files["charts/nginx-2.0.0.tgz.prov"]))
+	assert.NotContains(t, files, "charts/nginx-1.0.0.tgz.prov")
+	assert.NotContains(t, files, "charts/nginx-1.10.0.tgz")
+
+	// Options invalides
+	_, err = indexService.Export(io.Discard, models.ExportOptions{Pattern: "["})
+	assert.True(t, errors.Is(err, service.ErrInvalidExportOptions))
+	_, err = indexService.Export(io.Discard, models.ExportOptions{Latest: -1})
+	assert.True(t, errors.Is(err, service.ErrInvalidExportOptions))
+}
+
+func TestExportHandler(t *testing.T) {
+	_, indexService := newExportFixture(t)
+	app := fiber.New()
+	app.Get("/api/export", handlers.NewExportHandler(indexService, newTestLogger()).ExportRepository)
+
+	status, data, headers := doRequest(t, app, "GET", "/api/export?pattern=redis&latest=1", "", "", nil, "")
+	require.Equal(t, 200, status)
+	assert.Equal(t, "application/gzip", headers.Get("Content-Type"))
+	assert.Contains(t, headers.Get("Content-Disposition"), "helm-repository.tgz")
+	files := readExportBundle(t, data)
+	assert.Contains(t, files, "index.yaml")
+	assert.Contains(t, files, "charts/redis-7.0.0.tgz")
+	assert.Len(t, files, 2)
+
+	for _, query := range []string{"pattern=%5B", "latest=-1"} {
+		status, _, _ := doRequest(t, app, "GET", "/api/export?"+query, "", "", nil, "")
+		assert.Equal(t, 400, status, query)
+	}
+}
@@ -0,0 +1,243 @@
+package tests
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"helm-portal/pkg/handlers"
+	"helm-portal/pkg/models"
+	service "helm-portal/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// layerFile décrit une entrée d'une couche de test
+type layerFile struct {
+	name     string
+	typeflag byte
+	content  string
+	link     string
+}
+
+// buildLayer crée une archive tar compressée (gzip, zstd ou aucune)
+func buildLayer(t *testing.T, compression string, files []layerFile) []byte {
+	t.Helper()
+
+	var raw bytes.Buffer
+	tw := tar.NewWriter(&raw)
+	for _, f := range files {
+		header := &tar.Header{Name: f.name, Typeflag: f.typeflag, Mode: 0644, Linkname: f.link}
+		switch f.typeflag {
+		case tar.TypeDir:
+			header.Mode = 0755
+		case tar.TypeReg:
+			header.Size = int64(len(f.content))
+		}
+		require.NoError(t, tw.WriteHeader(header))
+		if f.typeflag == tar.TypeReg {
+			_, err := tw.Write([]byte(f.content))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+
+	var out bytes.Buffer
+	switch compression {
+	case "gzip":
+		gw := gzip.NewWriter(&out)
+		_, err := gw.Write(raw.Bytes())
+		require.NoError(t, err)
+		require.NoError(t, gw.Close())
+	case "zstd":
+		zw, err := zstd.NewWriter(&out)
+		require.NoError(t, err)
+		_, err = zw.Write(raw.Bytes())
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+	default:
+		return raw.Bytes()
+	}
+	return out.Bytes()
+}
+
+// storeBlob enregistre un blob et retourne son descripteur
+func storeBlob(t *testing.T, imageService *service.ImageService, mediaType string, data []byte) models.OCIDescriptor {
+	t.Helper()
+
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+	blobPath := imageService.GetPathManager().GetBlobPath(digest)
+	require.NoError(t, os.MkdirAll(filepath.Dir(blobPath), 0755))
+	require.NoError(t, os.WriteFile(blobPath, data, 0644))
+	return models.OCIDescriptor{MediaType: mediaType, Digest: digest, Size: int64(len(data))}
+}
+
+// pushLayeredImage pousse une image de deux couches : une base gzip puis une couche zstd
+// qui modifie, supprime (whiteout) et vide (opaque) des fichiers de la base
+func pushLayeredImage(t *testing.T, imageService *service.ImageService) (base, top models.OCIDescriptor) {
+	t.Helper()
+
+	base = storeBlob(t, imageService, models.MediaTypeOCILayer, buildLayer(t, "gzip", []layerFile{
+		{name: "./", typeflag: tar.TypeDir},
+		{name: "./etc/", typeflag: tar.TypeDir},
+		{name: "./etc/os-release", typeflag: tar.TypeReg, content: "ID=alpine\n"},
+		{name: "./etc/passwd", typeflag: tar.TypeReg, content: "root:x:0:0\n"},
+		{name: "./var/cache/", typeflag: tar.TypeDir},
+		{name: "./var/cache/apk.db", typeflag: tar.TypeReg, content: "cache"},
+		{name: "./bin/sh", typeflag: tar.TypeSymlink, link: "/bin/busybox"},
+	}))
+	top = storeBlob(t, imageService, "application/vnd.oci.image.layer.v1.tar+zstd", buildLayer(t, "zstd", []layerFile{
+		{name: "etc/os-release", typeflag: tar.TypeReg, content: "ID=alpine\nVERSION_ID=3.20\n"},
+		{name: "etc/.wh.passwd", typeflag: tar.TypeReg},
+		{name: "var/cache/.wh..wh..opq", typeflag: tar.TypeReg},
+		{name: "var/cache/new.db", typeflag: tar.TypeReg, content: "new"},
+		{name: "app/server", typeflag: tar.TypeReg, content: "#!/bin/sh\necho hello\n"},
+		{name: "app/run", typeflag: tar.TypeLink, link: "app/server"},
+	}))
+	config := storeBlob(t, imageService, models.MediaTypeOCIConfig, []byte(`{"architecture":"amd64","os":"linux"}`))
+
+	manifest, err := json.Marshal(models.OCIManifest{
+		SchemaVersion: 2,
+		MediaType:     models.MediaTypeOCIManifest,
+		Config:        config,
+		Layers:        []models.OCIDescriptor{base, top},
+	})
+	require.NoError(t, err)
+	require.NoError(t, imageService.SaveImage("my-app", "v1", manifest, ""))
+	return base, top
+}
+
+func entriesByPath[T any](entries []T, path func(T) string) map[string]T {
+	byPath := make(map[string]T, len(entries))
+	for _, entry := range entries {
+		byPath[path(entry)] = entry
+	}
+	return byPath
+}
+
+func TestImageService_Layers(t *testing.T) {
+	_, cfg := newTestChartService(t)
+	imageService := service.NewImageService(cfg, newTestLogger())
+	base, top := pushLayeredImage(t, imageService)
+
+	t.Run("Couche gzip", func(t *testing.T) {
+		listing, err := imageService.ListLayerFiles(base.Digest)
+		require.NoError(t, err)
+		assert.Equal(t, "gzip", listing.Compression)
+		entries := entriesByPath(listing.Entries, func(e models.LayerEntry) string { return e.Path })
+		assert.Len(t, entries, 6) // la racine n'est pas listée
+		assert.Equal(t, models.LayerEntryDir, entries["/etc"].Type)
+		assert.Equal(t, "drwxr-xr-x", entries["/etc"].Mode)
+		assert.Equal(t, int64(10), entries["/etc/os-release"].Size)
+		assert.Equal(t, "-rw-r--r--", entries["/etc/os-release"].Mode)
+		assert.Equal(t, models.LayerEntrySymlink, entries["/bin/sh"].Type)
+		assert.Equal(t, "/bin/busybox", entries["/bin/sh"].Link)
+	})
+
+	t.Run("Couche zstd avec whiteouts", func(t *testing.T) {
+		listing, err := imageService.ListLayerFiles(top.Digest)
+		require.NoError(t, err)
+		assert.Equal(t, "zstd", listing.Compression)
+		entries := entriesByPath(listing.Entries, func(e models.LayerEntry) string { return e.Path })
+		assert.Equal(t, models.LayerEntryWhiteout, entries["/etc/passwd"].Type)
+		assert.Equal(t, models.LayerEntryOpaque, entries["/var/cache"].Type)
+		assert.Equal(t, models.LayerEntryHardlink, entries["/app/run"].Type)
+		assert.Equal(t, "/app/server", entries["/app/run"].Link)
+	})
+
+	t.Run("Système de fichiers fusionné", func(t *testing.T) {
+		fs, err := imageService.MergedFilesystem("my-app", "v1")
+		require.NoError(t, err)
+		require.Len(t, fs.Layers, 2)
+		entries := entriesByPath(fs.Entries, func(e models.MergedEntry) string { return e.Path })
+
+		assert.Equal(t, 1, entries["/etc/os-release"].Layer)
+		assert.Equal(t, top.Digest, entries["/etc/os-release"].LayerDigest)
+		assert.Equal(t, int64(26), entries["/etc/os-release"].Size)
+		assert.Equal(t, 0, entries["/bin/sh"].Layer)
+		assert.Equal(t, 0, entries["/var/cache"].Layer)
+		assert.NotContains(t, entries, "/etc/passwd")
+		assert.NotContains(t, entries, "/var/cache/apk.db")
+		assert.Contains(t, entries, "/var/cache/new.db")
+		assert.Contains(t, entries, "/app/server")
+	})
+
+	t.Run("Erreurs", func(t *testing.T) {
+		_, err := imageService.ListLayerFiles("sha256:" + fmt.Sprintf("%064d", 0))
+		assert.ErrorIs(t, err, service.ErrLayerNotFound)
+
+		config := storeBlob(t, imageService, models.MediaTypeOCIConfig, []byte(`{"not":"a tar archive, just some json padding to fill a tar block"}`))
+		_, err = imageService.ListLayerFiles(config.Digest)
+		assert.ErrorIs(t, err, service.ErrInvalidLayer)
+
+		_, _, err = imageService.OpenLayerFile(base.Digest, "/etc/shadow")
+		assert.ErrorIs(t, err, service.ErrLayerFileNotFound)
+		_, _, err = imageService.OpenLayerFile(base.Digest, "/etc")
+		assert.ErrorIs(t, err, service.ErrNotRegularFile)
+
+		// Un digest malformé n'est jamais utilisé comme chemin
+		_, err = imageService.ListLayerFiles("sha256:../../config.yaml")
+		assert.ErrorIs(t, err, service.ErrLayerNotFound)
+	})
+
+	t.Run("Couches d'une image", func(t *testing.T) {
+		assert.True(t, imageService.HasLayer("my-app", base.Digest))
+		assert.True(t, imageService.HasLayer("my-app", top.Digest))
+		assert.False(t, imageService.HasLayer("other-app", top.Digest))
+		assert.False(t, imageService.HasLayer("my-app", "sha256:"+fmt.Sprintf("%064d", 0)))
+		assert.False(t, imageService.HasLayer("my-app", "sha256:../../config.yaml"))
+	})
+}
+
+func TestImageHandler_Layers(t *testing.T) {
+	_, cfg := newTestChartService(t)
+	imageService := service.NewImageService(cfg, newTestLogger())
+	base, top := pushLayeredImage(t, imageService)
+	pushTestImage(t, imageService, "other-app", "latest", nil, nil)
+
+	app := fiber.New()
+	imageHandler := handlers.NewImageHandler(imageService, imageService.GetPathManager(), newTestLogger())
+	app.Get("/image/*/filesystem", imageHandler.GetFilesystem)
+	app.Get("/image/*/layers/:digest", imageHandler.ListLayerFiles)
+	app.Get("/image/*/layers/:digest/files/*", imageHandler.DownloadLayerFile)
+
+	tests := []struct {
+		name             string
+		url              string
+		expectedStatus   int
+		expectedBody     string
+		expectedFilename string
+	}{
+		{"Liste d'une couche", "/image/my-app/layers/" + top.Digest, 200, `"compression":"zstd"`, ""},
+		{"Système de fichiers", "/image/my-app/v1/filesystem", 200, `"path":"/var/cache/new.db"`, ""},
+		{"Téléchargement d'un fichier", "/image/my-app/layers/" + top.Digest + "/files/etc/os-release", 200, "ID=alpine\nVERSION_ID=3.20\n", "os-release"},
+		{"Fichier de la couche de base", "/image/my-app/layers/" + base.Digest + "/files/etc/passwd", 200, "root:x:0:0\n", ""},
+		{"Fichier absent", "/image/my-app/layers/" + base.Digest + "/files/etc/shadow", 404, "File not found", ""},
+		{"Répertoire", "/image/my-app/layers/" + base.Digest + "/files/etc", 400, "not a regular file", ""},
+		{"Couche absente", "/image/my-app/layers/sha256:" + fmt.Sprintf("%064d", 0), 404, "layer not found", ""},
+		{"Image absente", "/image/my-app/v9/filesystem", 404, "Image not found", ""},
+		// Seules les couches de l'image nommée sont ouvertes
+		{"Couche d'une autre image", "/image/other-app/layers/" + top.Digest, 404, "layer not found", ""},
+		{"Fichier d'une autre image", "/image/other-app/layers/" + base.Digest + "/files/etc/passwd", 404, "layer not found", ""},
+		{"Digest malformé", "/image/my-app/layers/sha256:..%2F..%2Fconfig.yaml", 404, "layer not found", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body, headers := doRequest(t, app, "GET", tt.url, "", "", nil, "")
+			assert.Equal(t, tt.expectedStatus, status, string(body))
+			assert.Contains(t, string(body), tt.expectedBody)
+			if tt.expectedFilename != "" {
+				assert.Contains(t, headers.Get("Content-Disposition"), `filename="`+tt.expectedFilename+`"`)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"helm-portal/pkg/handlers"
+	"helm-portal/pkg/models"
+	service "helm-portal/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rawImageManifest retourne un manifest avec un champ inconnu et une mise en forme
+// que json.Marshal ne reproduirait pas
+func rawImageManifest(mediaType string) []byte {
+	mediaTypeField := ""
+	if mediaType != "" {
+		mediaTypeField = fmt.Sprintf("\n   \"mediaType\": %q,", mediaType)
+	}
+	return []byte(fmt.Sprintf(`{
+   "schemaVersion": 2,%s
+   "config": {
+      "mediaType": %q,
+      "size": 2,
+      "digest": "sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"
+   },
+   "layers": [],
+   "subject": {"mediaType": "application/vnd.oci.image.manifest.v1+json", "size": 1, "digest": "sha256:0000"},
+   "x-unknown-field": true
+}`, mediaTypeField, models.MediaTypeOCIConfig))
+}
+
+func manifestDigest(data []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
+
+func TestImageService_SaveImagePreservesBytes(t *testing.T) {
+	_, cfg := newTestChartService(t)
+	imageService := service.NewImageService(cfg, newTestLogger())
+
+	tests := []struct {
+		name              string
+		manifest          []byte
+		contentType       string
+		expectedMediaType string
+	}{
+		{"Manifest Docker", rawImageManifest(models.MediaTypeDockerManifest), "", models.MediaTypeDockerManifest},
+		{"Manifest OCI sans mediaType", rawImageManifest(""), models.MediaTypeOCIManifest + "; charset=utf-8", models.MediaTypeOCIManifest},
+		{"Sans mediaType ni Content-Type", rawImageManifest(""), "application/json", models.MediaTypeOCIManifest},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tag := fmt.Sprintf("v%d", i)
+			require.NoError(t, imageService.SaveImage("my-app", tag, tt.manifest, tt.contentType))
+			digest := manifestDigest(tt.manifest)
+
+			// Le tag et la référence par digest contiennent exactement les octets poussés
+			pm := imageService.GetPathManager()
+			for _, reference := range []string{tag, digest} {
+				stored, err := os.ReadFile(pm.GetImageManifestPath("my-app", reference))
+				require.NoError(t, err)
+				assert.Equal(t, tt.manifest, stored, reference)
+			}
+
+			metadata, err := imageService.GetImageMetadata("my-app", tag)
+			require.NoError(t, err)
+			assert.Equal(t, digest, metadata.Digest)
+			assert.Equal(t, tt.expectedMediaType, metadata.MediaType)
+
+			_, err = imageService.GetImageManifest("my-app", digest)
+			assert.NoError(t, err)
+		})
+	}
+
+	// Un push par digest doit correspondre au contenu
+	err := imageService.SaveImage("my-app", "sha256:"+fmt.Sprintf("%064d", 0), rawImageManifest(""), "")
+	assert.ErrorIs(t, err, service.ErrManifestDigestMismatch)
+
+	err = imageService.SaveImage("my-app", "broken", []byte("{not json"), "")
+	assert.ErrorIs(t, err, service.ErrInvalidManifest)
+}
+
+func TestOCIHandler_ManifestRoundTrip(t *testing.T) {
+	chartService, cfg := newTestChartService(t)
+	imageService := service.NewImageService(cfg, newTestLogger())
+	ociHandler := handlers.NewOCIHandler(chartService, imageService, cfg, newTestLogger())
+
+	app := fiber.New()
+	app.Head("/v2/:name/manifests/:reference", ociHandler.HandleManifest)
+	app.Get("/v2/:name/manifests/:reference", ociHandler.HandleManifest)
+	app.Put("/v2/:name/manifests/:reference", ociHandler.PutManifest)
+
+	tests := []struct {
+		name              string
+		manifest          []byte
+		contentType       string
+		expectedMediaType string
+	}{
+		{"Manifest Docker", rawImageManifest(models.MediaTypeDockerManifest), models.MediaTypeDockerManifest, models.MediaTypeDockerManifest},
+		{"Manifest OCI sans mediaType", rawImageManifest(""), models.MediaTypeOCIManifest, models.MediaTypeOCIManifest},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tag := fmt.Sprintf("v%d", i)
+			digest := manifestDigest(tt.manifest)
+
+			req := httptest.NewRequest("PUT", "/v2/my-app/manifests/"+tag, bytes.NewReader(tt.manifest))
+			req.Header.Set("Content-Type", tt.contentType)
+			resp, err := app.Test(req, -1)
+			require.NoError(t, err)
+			require.Equal(t, 201, resp.StatusCode)
+			assert.Equal(t, digest, resp.Header.Get("Docker-Content-Digest"))
+
+			for _, reference := range []string{tag, digest} {
+				status, body, headers := doRequest(t, app, "GET", "/v2/my-app/manifests/"+reference, "", "", nil, "")
+				require.Equal(t, 200, status, reference)
+				assert.Equal(t, tt.manifest, body, reference)
+				assert.Equal(t, tt.expectedMediaType, headers.Get("Content-Type"), reference)
+				assert.Equal(t, digest, headers.Get("Docker-Content-Digest"), reference)
+
+				status, _, headers = doRequest(t, app, "HEAD", "/v2/my-app/manifests/"+reference, "", "", nil, "")
+				require.Equal(t, 200, status, reference)
+				assert.Equal(t, tt.expectedMediaType, headers.Get("Content-Type"), reference)
+			}
+		})
+	}
+
+	// Push par un digest qui ne correspond pas au contenu
+	manifest := rawImageManifest("")
+	wrongDigest := manifestDigest(append([]byte(" "), manifest...))
+	status, body, _ := doRequest(t, app, "PUT", "/v2/my-app/manifests/"+wrongDigest, "", "", bytes.NewReader(manifest), models.MediaTypeOCIManifest)
+	assert.Equal(t, 400, status)
+	var ociErr map[string][]map[string]any
+	require.NoError(t, json.Unmarshal(body, &ociErr))
+	assert.Equal(t, "DIGEST_INVALID", ociErr["errors"][0]["code"])
+}
+
+func TestOCIHandler_ChartPushBuildMetadata(t *testing.T) {
+	chartService, cfg := newTestChartService(t)
+	imageService := service.NewImageService(cfg, newTestLogger())
+	ociHandler := handlers.NewOCIHandler(chartService, imageService, cfg, newTestLogger())
+
+	app := fiber.New()
+	app.Put("/v2/:name/manifests/:reference", ociHandler.PutManifest)
+
+	// helm push remplace le "+" de la version par "_" dans le tag
+	archive := buildChartArchive(t, validChartFiles("my-chart", "1.0.0+build.7"))
+	manifest, err := json.Marshal(models.OCIManifest{
+		SchemaVersion: 2,
+		MediaType:     models.MediaTypeOCIManifest,
+		Config:        storeBlob(t, imageService, models.MediaTypeHelmConfig, []byte(`{"name":"my-chart","version":"1.0.0+build.7"}`)),
+		Layers:        []models.OCIDescriptor{storeBlob(t, imageService, models.MediaTypeHelmChart, archive)},
+	})
+	require.NoError(t, err)
+	status, body, _ := doRequest(t, app, "PUT", "/v2/my-chart/manifests/1.0.0_build.7", "", "", bytes.NewReader(manifest), models.MediaTypeOCIManifest)
+	require.Equal(t, 201, status, string(body))
+
+	// L'archive est rangée sous la version de son Chart.yaml
+	assert.True(t, chartService.ChartExists("my-chart", "1.0.0+build.7"))
+	assert.FileExists(t, filepath.Join(chartService.GetPathManager().GetChartsPath(), "my-chart-1.0.0+build.7.tgz"))
+	assert.NoFileExists(t, filepath.Join(chartService.GetPathManager().GetChartsPath(), "my-chart-1.0.0_build.7.tgz"))
+}
@@ -0,0 +1,370 @@
+package tests
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"helm-portal/config"
+	"helm-portal/pkg/handlers"
+	"helm-portal/pkg/models"
+	service "helm-portal/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testAdvisories est un extrait de base OSV : un avis Debian noté en CVSS, un avis npm
+// noté par la base, un avis Alpine et un avis déjà corrigé dans les versions installées
+const testAdvisories = `[
+  {
+    "id": "DSA-0001-1",
+    "aliases": ["CVE-2024-0001"],
+    "summary": "openssl - security update",
+    "severity": [{"type": "CVSS_V3", "score": "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H"}],
+    "affected": [{
+      "package": {"ecosystem": "Debian:12", "name": "openssl"},
+      "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "3.0.13-1~deb12u1"}]}]
+    }]
+  },
+  {
+    "id": "DSA-0002-1",
+    "summary": "openssl - old issue",
+    "affected": [{
+      "package": {"ecosystem": "Debian:12", "name": "openssl"},
+      "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "3.0.9-1"}]}]
+    }]
+  },
+  {
+    "id": "GHSA-35jh-r3h4-6jhm",
+    "aliases": ["CVE-2021-23337"],
+    "summary": "Command Injection in lodash",
+    "database_specific": {"severity": "HIGH"},
+    "affected": [{
+      "package": {"ecosystem": "npm", "name": "lodash"},
+      "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "4.17.21"}]}]
+    }]
+  },
+  {
+    "id": "ALPINE-CVE-2024-0002",
+    "summary": "busybox issue",
+    "affected": [{
+      "package": {"ecosystem": "Alpine:v3.20", "name": "busybox"},
+      "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "1.36.1-r5"}]}]
+    }]
+  }
+]`
+
+// pushScannableImage pousse une image d'une couche et retourne son digest
+func pushScannableImage(t *testing.T, imageService *service.ImageService, name, tag string, files []layerFile) string {
+	t.Helper()
+
+	layer := storeBlob(t, imageService, models.MediaTypeOCILayer, buildLayer(t, "gzip", files))
+	config := storeBlob(t, imageService, models.MediaTypeOCIConfig, []byte(`{"architecture":"amd64","os":"linux"}`))
+	manifest, err := json.Marshal(models.OCIManifest{
+		SchemaVersion: 2,
+		MediaType:     models.MediaTypeOCIManifest,
+		Config:        config,
+		Layers:        []models.OCIDescriptor{layer},
+	})
+	require.NoError(t, err)
+	require.NoError(t, imageService.SaveImage(name, tag, manifest, ""))
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(manifest))
+}
+
+// debianAppFiles décrit une image Debian 12 avec openssl et une application Node.js
+func debianAppFiles() []layerFile {
+	return []layerFile{
+		{name: "etc/os-release", typeflag: tar.TypeReg, content: "PRETTY_NAME=\"Debian GNU/Linux 12 (bookworm)\"\nID=debian\nVERSION_ID=\"12\"\n"},
+		{name: "var/lib/dpkg/status", typeflag: tar.TypeReg, content: "Package: libssl3\nStatus: install ok installed\nSource: openssl\nVersion: 3.0.11-1~deb12u2\n\n" +
+			"Package: openssl\nStatus: install ok installed\nVersion: 3.0.11-1~deb12u2\n\n" +
+			"Package: removed-tool\nStatus: deinstall ok config-files\nVersion: 1.0\n"},
+		{name: "app/package-lock.json", typeflag: tar.TypeReg, content: `{"lockfileVersion": 3, "packages": {"": {"name": "app"}, "node_modules/lodash": {"version": "4.17.20"}, "node_modules/express": {"version": "4.19.2"}}}`},
+	}
+}
+
+func newTestScanService(t *testing.T, opts ...func(*config.Config)) (*service.ScanService, *service.ImageService, *service.ChartService) {
+	t.Helper()
+
+	chartService, cfg := newTestChartService(t, opts...)
+	imageService := service.NewImageService(cfg, newTestLogger())
+	scanService := service.NewScanService(cfg, imageService.GetPathManager(), chartService, imageService, newTestLogger())
+	return scanService, imageService, chartService
+}
+
+func vulnerabilityIDs(report *models.ScanReport) map[string]models.VulnerabilityMatch {
+	byID := make(map[string]models.VulnerabilityMatch)
+	for _, vulnerability := range report.Vulnerabilities {
+		byID[vulnerability.ID+"/"+vulnerability.Package] = vulnerability
+	}
+	return byID
+}
+
+func TestScanService_ScanImage(t *testing.T) {
+	scanService, imageService, _ := newTestScanService(t)
+	digest := pushScannableImage(t, imageService, "debian-app", "1.0", debianAppFiles())
+	pushScannableImage(t, imageService, "alpine-app", "1.0", []layerFile{
+		{name: "etc/os-release", typeflag: tar.TypeReg, content: "ID=alpine\nVERSION_ID=3.20.3\n"},
+		{name: "lib/apk/db/installed", typeflag: tar.TypeReg, content: "P:busybox\nV:1.36.1-r2\no:busybox\n\nP:musl\nV:1.2.5-r0\n"},
+	})
+
+	// Sans base importée, l'inventaire est fait mais rien ne correspond
+	_, err := scanService.GetReport("debian-app", "1.0")
+	assert.ErrorIs(t, err, service.ErrScanNotFound)
+	report, err := scanService.ScanImage("debian-app", "1.0")
+	require.NoError(t, err)
+	assert.Equal(t, models.ScanStatusCompleted, report.Status)
+	assert.Equal(t, digest, report.Digest)
+	assert.Contains(t, report.OS, "Debian")
+	assert.Len(t, report.Packages, 4) // libssl3, openssl, lodash, express
+	assert.Empty(t, report.Vulnerabilities)
+
+	status, err := scanService.Database().Import([]byte(testAdvisories), "advisories.json", false)
+	require.NoError(t, err)
+	assert.Equal(t, 4, status.Advisories)
+
+	t.Run("Paquets Debian et npm", func(t *testing.T) {
+		// Le rapport existant est comparé à la nouvelle base sans nouvelle analyse
+		report, err := scanService.GetReport("debian-app", "1.0")
+		require.NoError(t, err)
+		assert.Equal(t, scanService.Database().Version(), report.DatabaseVersion)
+
+		byID := vulnerabilityIDs(report)
+		require.Len(t, byID, 3, "%+v", report.Vulnerabilities)
+		assert.Equal(t, models.SeverityCritical, byID["DSA-0001-1/libssl3"].Severity)
+		assert.Equal(t, "3.0.13-1~deb12u1", byID["DSA-0001-1/libssl3"].FixedVersion)
+		assert.Equal(t, "3.0.11-1~deb12u2", byID["DSA-0001-1/openssl"].Version)
+		assert.Equal(t, models.SeverityHigh, byID["GHSA-35jh-r3h4-6jhm/lodash"].Severity)
+		assert.Equal(t, "4.17.21", byID["GHSA-35jh-r3h4-6jhm/lodash"].FixedVersion)
+		assert.Equal(t, "/app/package-lock.json", byID["GHSA-35jh-r3h4-6jhm/lodash"].Location)
+		assert.NotContains(t, byID, "DSA-0002-1/openssl")
+
+		assert.Equal(t, 2, report.Summary.Critical)
+		assert.Equal(t, 1, report.Summary.High)
+		assert.Equal(t, 3, report.Summary.Total)
+		// Les plus graves d'abord
+		assert.Equal(t, models.SeverityCritical, report.Vulnerabilities[0].Severity)
+	})
+
+	t.Run("Paquets Alpine", func(t *testing.T) {
+		report, err := scanService.ScanImage("alpine-app", "1.0")
+		require.NoError(t, err)
+		require.Len(t, report.Vulnerabilities, 1)
+		assert.Equal(t, "busybox", report.Vulnerabilities[0].Package)
+		assert.Equal(t, "Alpine:v3.20", report.Vulnerabilities[0].Ecosystem)
+		assert.Equal(t, models.SeverityUnknown, report.Vulnerabilities[0].Severity)
+	})
+
+	t.Run("Retrait d'un avis", func(t *testing.T) {
+		_, err := scanService.Database().Import([]byte(`{"id": "GHSA-35jh-r3h4-6jhm", "withdrawn": "2024-01-01T00:00:00Z", "affected": []}`), "withdrawn.json", false)
+		require.NoError(t, err)
+		report, err := scanService.GetReport("debian-app", "1.0")
+		require.NoError(t, err)
+		assert.NotContains(t, vulnerabilityIDs(report), "GHSA-35jh-r3h4-6jhm/lodash")
+		assert.Equal(t, 2, report.Summary.Total)
+	})
+
+	t.Run("Erreurs", func(t *testing.T) {
+		_, err := scanService.ScanImage("debian-app", "9.9")
+		assert.ErrorIs(t, err, service.ErrImageNotFound)
+		_, err = scanService.Database().Import([]byte("not json"), "broken.json", false)
+		assert.ErrorIs(t, err, service.ErrInvalidDatabase)
+	})
+}
+
+// encodeSQLiteVarint encode un entier en varint SQLite (big-endian, 7 bits par octet)
+func encodeSQLiteVarint(v uint64) []byte {
+	groups := []byte{byte(v & 0x7f)}
+	for v >>= 7; v > 0; v >>= 7 {
+		groups = append([]byte{byte(v&0x7f) | 0x80}, groups...)
+	}
+	return groups
+}
+
+// craftedSQLite construit une base SQLite d'une page de 512 octets dont la table sqlite_master a une seule cellule
+func craftedSQLite(cell []byte) []byte {
+	data := make([]byte, 512)
+	copy(data, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(data[16:], 512)
+	data[100] = 0x0d                            // feuille de table
+	binary.BigEndian.PutUint16(data[103:], 1)   // une cellule
+	binary.BigEndian.PutUint16(data[108:], 120) // pointeur de cellule
+	copy(data[120:], cell)
+	return data
+}
+
+// craftedBDB construit une base Berkeley DB dont la page de hachage référence une valeur hors page de la longueur donnée
+func craftedBDB(length uint32) []byte {
+	data := make([]byte, 2*512)
+	binary.LittleEndian.PutUint32(data[12:], 0x061561)
+	binary.LittleEndian.PutUint32(data[20:], 512)
+	binary.LittleEndian.PutUint32(data[32:], 1)
+	page := data[512:]
+	page[25] = 13                                 // page de hachage
+	binary.LittleEndian.PutUint16(page[20:], 2)   // une paire clé / valeur
+	binary.LittleEndian.PutUint16(page[28:], 100) // valeur à l'offset 100
+	page[100] = 3                                 // hors page
+	binary.LittleEndian.PutUint32(page[104:], 1)
+	binary.LittleEndian.PutUint32(page[108:], length)
+	return data
+}
+
+func TestScanService_CorruptRPMDatabase(t *testing.T) {
+	scanService, imageService, _ := newTestScanService(t)
+
+	tests := []struct {
+		name string
+		path string
+		data []byte
+	}{
+		{"En-tête d'enregistrement trop court", "var/lib/rpm/rpmdb.sqlite", craftedSQLite(append(append(encodeSQLiteVarint(2), encodeSQLiteVarint(1)...), 0, 0))},
+		{"Payload plus grand que la base", "var/lib/rpm/rpmdb.sqlite", craftedSQLite(append(append(encodeSQLiteVarint(39+508<<45), encodeSQLiteVarint(1)...), make([]byte, 64)...))},
+		{"Base SQLite tronquée", "var/lib/rpm/rpmdb.sqlite", craftedSQLite(nil)[:200]},
+		{"Page Berkeley DB tronquée", "var/lib/rpm/Packages", craftedBDB(100)[:700]},
+		{"Valeur Berkeley DB démesurée", "var/lib/rpm/Packages", craftedBDB(0xffffffff)},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := fmt.Sprintf("rpm-app-%d", i)
+			pushScannableImage(t, imageService, name, "1.0", []layerFile{
+				{name: "etc/os-release", typeflag: tar.TypeReg, content: "ID=rhel\nVERSION_ID=\"9.4\"\n"},
+				{name: tt.path, typeflag: tar.TypeReg, content: string(tt.data)},
+			})
+
+			// La base corrompue est ignorée, sans faire tomber l'analyse
+			report, err := scanService.ScanImage(name, "1.0")
+			require.NoError(t, err)
+			assert.Equal(t, models.ScanStatusCompleted, report.Status)
+			assert.Empty(t, report.Packages)
+		})
+	}
+}
+
+func TestScanService_ScanOnPush(t *testing.T) {
+	scanService, imageService, _ := newTestScanService(t)
+	_, err := scanService.Database().Import([]byte(testAdvisories), "advisories.json", false)
+	require.NoError(t, err)
+	imageService.Subscribe(scanService)
+	scanService.Start()
+
+	pushScannableImage(t, imageService, "debian-app", "1.0", debianAppFiles())
+	require.Eventually(t, func() bool {
+		_, err := scanService.GetReport("debian-app", "1.0")
+		return err == nil
+	}, 10*time.Second, 20*time.Millisecond)
+
+	summary := scanService.Summary("debian-app", "1.0")
+	require.NotNil(t, summary)
+	assert.Equal(t, 3, summary.Total)
+}
+
+func TestScanService_ChartReport(t *testing.T) {
+	scanService, imageService, chartService := newTestScanService(t)
+	_, err := scanService.Database().Import([]byte(testAdvisories), "advisories.json", false)
+	require.NoError(t, err)
+	pushScannableImage(t, imageService, "debian-app", "1.0", debianAppFiles())
+	_, err = scanService.ScanImage("debian-app", "1.0")
+	require.NoError(t, err)
+
+	files := validChartFiles("my-chart", "1.0.0")
+	files["my-chart/templates/deployment.yaml"] = "kind: Deployment\nspec:\n  template:\n    spec:\n      containers:\n" +
+		"        - name: app\n          image: \"registry.example.com/debian-app:1.0\"\n" +
+		"        - name: proxy\n          image: nginx:1.25\n"
+	require.NoError(t, chartService.SaveChart(buildChartArchive(t, files), "my-chart-1.0.0.tgz"))
+
+	report, err := scanService.ChartReport("my-chart", "1.0.0")
+	require.NoError(t, err)
+	require.Len(t, report.Images, 2)
+	images := entriesByPath(report.Images, func(i models.ChartImageScan) string { return i.Image })
+
+	app := images["registry.example.com/debian-app:1.0"]
+	assert.True(t, app.Hosted)
+	assert.Equal(t, "debian-app", app.Name)
+	require.NotNil(t, app.Summary)
+	assert.Equal(t, 3, app.Summary.Total)
+	assert.False(t, images["nginx:1.25"].Hosted)
+	assert.Nil(t, images["nginx:1.25"].Summary)
+	assert.Equal(t, 3, report.Summary.Total)
+
+	_, err = scanService.ChartReport("my-chart", "9.9.9")
+	assert.ErrorIs(t, err, service.ErrChartNotFound)
+}
+
+func TestScanHandler(t *testing.T) {
+	// Seuls les fichiers de scanning.databaseDir sont importables par chemin
+	databaseDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(databaseDir, "osv.json"), []byte(testAdvisories), 0644))
+	outside := filepath.Join(t.TempDir(), "secret.json")
+	require.NoError(t, os.WriteFile(outside, []byte(testAdvisories), 0644))
+	require.NoError(t, os.Symlink(outside, filepath.Join(databaseDir, "link.json")))
+
+	scanService, imageService, _ := newTestScanService(t, func(cfg *config.Config) {
+		cfg.Scanning.DatabaseDir = databaseDir
+	})
+	pushScannableImage(t, imageService, "debian-app", "1.0", debianAppFiles())
+	unreadable := pushScannableImage(t, imageService, "debian-app", "2.0", append(debianAppFiles(), layerFile{name: "app/VERSION", typeflag: tar.TypeReg, content: "2.0\n"}))
+	// Un rapport illisible : l'erreur interne ne doit pas être renvoyée au client
+	reportPath := filepath.Join(imageService.GetPathManager().GetBasePath(), "scans", strings.Replace(unreadable, ":", "_", 1)+".json")
+	require.NoError(t, os.MkdirAll(reportPath, 0755))
+
+	app := fiber.New()
+	scanHandler := handlers.NewScanHandler(scanService, newTestLogger())
+	app.Get("/image/*/vulnerabilities", scanHandler.GetImageVulnerabilities)
+	app.Post("/image/*/scan", scanHandler.ScanImage)
+	app.Get("/api/vulnerabilities/database", scanHandler.GetDatabase)
+	app.Post("/api/vulnerabilities/database", scanHandler.ImportDatabase)
+
+	// Avant l'import de la base
+	status, body, _ := doRequest(t, app, "GET", "/image/debian-app/1.0/vulnerabilities", "", "", nil, "")
+	assert.Equal(t, 404, status)
+	assert.Contains(t, string(body), "not scanned")
+	status, body, _ = doRequest(t, app, "POST", "/api/vulnerabilities/database", "", "", strings.NewReader(`{}`), "application/json")
+	assert.Equal(t, 400, status)
+	assert.Contains(t, string(body), "Provide a database file or a path")
+
+	_, err := scanService.Database().Import([]byte(testAdvisories), "advisories.json", false)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"Fichier absent", "POST", "/api/vulnerabilities/database", `{"path": "missing.zip"}`, 400, "invalid vulnerability database"},
+		{"Fichier du répertoire", "POST", "/api/vulnerabilities/database", `{"path": "osv.json"}`, 200, `"advisories":4`},
+		{"Chemin absolu du répertoire", "POST", "/api/vulnerabilities/database", `{"path": "` + filepath.Join(databaseDir, "osv.json") + `"}`, 200, `"advisories":4`},
+		{"Fichier hors du répertoire", "POST", "/api/vulnerabilities/database", `{"path": "` + outside + `"}`, 403, "outside scanning.databaseDir"},
+		{"Remontée de répertoire", "POST", "/api/vulnerabilities/database", `{"path": "../` + filepath.Base(filepath.Dir(outside)) + `/secret.json"}`, 403, "outside scanning.databaseDir"},
+		{"Lien symbolique vers l'extérieur", "POST", "/api/vulnerabilities/database", `{"path": "link.json"}`, 403, "outside scanning.databaseDir"},
+		{"Analyse", "POST", "/image/debian-app/1.0/scan", "", 200, `"status":"completed"`},
+		{"Rapport", "GET", "/image/debian-app/1.0/vulnerabilities", "", 200, `"package":"libssl3"`},
+		{"Image absente", "POST", "/image/debian-app/9.9/scan", "", 404, "Image not found"},
+		{"Rapport illisible", "GET", "/image/debian-app/2.0/vulnerabilities", "", 500, `{"error":"Vulnerability scan failed"}`},
+		{"État de la base", "GET", "/api/vulnerabilities/database", "", 200, `"advisories":4`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			status, respBody, _ := doRequest(t, app, tt.method, tt.url, "", "", body, "application/json")
+			assert.Equal(t, tt.expectedStatus, status, string(respBody))
+			assert.Contains(t, string(respBody), tt.expectedBody)
+			assert.NotContains(t, string(respBody), imageService.GetPathManager().GetBasePath())
+		})
+	}
+}
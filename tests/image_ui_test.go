@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"helm-portal/pkg/handlers"
+	"helm-portal/pkg/models"
+	service "helm-portal/pkg/services"
+	"helm-portal/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/template/html/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pushPlatformImage enregistre une image pour une plateforme et la pousse par digest, comme le fait buildx
+func pushPlatformImage(t *testing.T, imageService *service.ImageService, name, arch string, layerSize int64) models.OCIDescriptor {
+	t.Helper()
+
+	config, err := json.Marshal(models.ImageConfig{
+		Architecture: arch,
+		OS:           "linux",
+		Config: &models.ContainerConfig{
+			Env:          []string{"PATH=/usr/bin", "APP_ENV=prod"},
+			Entrypoint:   []string{"/app/server"},
+			Cmd:          []string{"--port", "8080"},
+			ExposedPorts: map[string]struct{}{"8080/tcp": {}},
+			Labels:       map[string]string{"org.opencontainers.image.source": "https://example.com/my-app"},
+		},
+		History: []models.HistoryEntry{
+			{Created: "2026-01-01T00:00:00Z", CreatedBy: "COPY server /app/server # buildkit"},
+			{Created: "2026-01-01T00:00:01Z", CreatedBy: "EXPOSE map[8080/tcp:{}]", EmptyLayer: true},
+		},
+	})
+	require.NoError(t, err)
+	configDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(config))
+	blobPath := imageService.GetPathManager().GetBlobPath(configDigest)
+	require.NoError(t, os.MkdirAll(filepath.Dir(blobPath), 0755))
+	require.NoError(t, os.WriteFile(blobPath, config, 0644))
+
+	manifest, err := json.Marshal(models.OCIManifest{
+		SchemaVersion: 2,
+		MediaType:     models.MediaTypeOCIManifest,
+		Config:        models.OCIDescriptor{MediaType: models.MediaTypeOCIConfig, Digest: configDigest, Size: int64(len(config))},
+		Layers: []models.OCIDescriptor{
+			{MediaType: models.MediaTypeOCILayer, Digest: fmt.Sprintf("sha256:%064x", layerSize), Size: layerSize},
+		},
+	})
+	require.NoError(t, err)
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(manifest))
+	require.NoError(t, imageService.SaveImage(name, digest, manifest, models.MediaTypeOCIManifest))
+
+	return models.OCIDescriptor{
+		MediaType: models.MediaTypeOCIManifest,
+		Digest:    digest,
+		Size:      int64(len(manifest)),
+		Platform:  &models.OCIPlatform{OS: "linux", Architecture: arch},
+	}
+}
+
+func TestImageService_Index(t *testing.T) {
+	_, cfg := newTestChartService(t)
+	imageService := service.NewImageService(cfg, newTestLogger())
+
+	amd64 := pushPlatformImage(t, imageService, "my-app", "amd64", 1000)
+	arm64 := pushPlatformImage(t, imageService, "my-app", "arm64", 2000)
+	index, err := json.Marshal(models.OCIIndex{
+		SchemaVersion: 2,
+		MediaType:     models.MediaTypeOCIManifestList,
+		Manifests:     []models.OCIDescriptor{amd64, arm64},
+	})
+	require.NoError(t, err)
+	require.NoError(t, imageService.SaveImage("my-app", "v1", index, ""))
+
+	metadata, err := imageService.GetImageMetadata("my-app", "v1")
+	require.NoError(t, err)
+	assert.Equal(t, models.MediaTypeOCIManifestList, metadata.MediaType)
+	require.Len(t, metadata.Platforms, 2)
+	assert.Equal(t, "linux/amd64", metadata.Platforms[0].String())
+	assert.Equal(t, "linux/arm64", metadata.Platforms[1].String())
+	assert.Equal(t, arm64.Digest, metadata.Platforms[1].Digest)
+
+	// La taille d'une plateforme est celle de son image (config + couches), pas celle du manifest
+	child, err := imageService.GetImageManifest("my-app", amd64.Digest)
+	require.NoError(t, err)
+	assert.Equal(t, child.GetTotalSize(), metadata.Platforms[0].Size)
+	assert.Equal(t, metadata.Platforms[0].Size+metadata.Platforms[1].Size, metadata.Size)
+	assert.Nil(t, metadata.Config)
+
+	// Les images poussées par digest ne sont pas des tags
+	tags, err := imageService.ListTags("my-app")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1"}, tags)
+}
+
+func TestImageHandler_Views(t *testing.T) {
+	chartService, cfg := newTestChartService(t)
+	imageService := service.NewImageService(cfg, newTestLogger())
+	pushTestImage(t, imageService, "other-app", "latest", nil, nil)
+	pushTestImage(t, imageService, "library/nginx", "1.25", nil, nil)
+	single := pushPlatformImage(t, imageService, "my-app", "amd64", 1000)
+	manifest, err := os.ReadFile(imageService.GetPathManager().GetImageManifestPath("my-app", single.Digest))
+	require.NoError(t, err)
+	require.NoError(t, imageService.SaveImage("my-app", "v1", manifest, ""))
+	index, err := json.Marshal(models.OCIIndex{
+		SchemaVersion: 2,
+		MediaType:     models.MediaTypeOCIManifestList,
+		Manifests:     []models.OCIDescriptor{single, pushPlatformImage(t, imageService, "my-app", "arm64", 2000)},
+	})
+	require.NoError(t, err)
+
+	views := html.New("../src/views", ".html")
+	views.AddFuncMap(utils.TemplateFuncs())
+	app := fiber.New(fiber.Config{Views: views})
+	imageHandler := handlers.NewImageHandler(imageService, imageService.GetPathManager(), newTestLogger())
+	ociHandler := handlers.NewOCIHandler(chartService, imageService, cfg, newTestLogger())
+	app.Get("/images", imageHandler.ListImages)
+	app.Get("/image/*/details", imageHandler.DisplayImageDetails)
+	app.Get("/image/*", imageHandler.GetImageTags)
+	app.Put("/v2/:name/manifests/:reference", ociHandler.PutManifest)
+
+	// Un index poussé par l'API OCI est rangé avec les images
+	status, _, _ := doRequest(t, app, "PUT", "/v2/my-app/manifests/v2", "", "", bytes.NewReader(index), models.MediaTypeOCIManifestList)
+	require.Equal(t, 201, status)
+
+	get := func(url, accept string) (int, string) {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Host = "registry.example.com"
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		var body bytes.Buffer
+		_, err = body.ReadFrom(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, body.String()
+	}
+	const browser = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+
+	tests := []struct {
+		name     string
+		url      string
+		accept   string
+		contains []string
+	}{
+		{"Liste JSON", "/images", "", []string{`"Name":"my-app"`, `"tag":"v1"`, `"tag":"v2"`, `"Name":"other-app"`}},
+		{"Liste HTML", "/images", browser, []string{"my-app", "other-app", "registry.example.com/my-app:v1", "linux/amd64, linux/arm64"}},
+		{"Détails JSON", "/image/my-app/v1/details", "application/json", []string{`"digest":"` + single.Digest + `"`, `"Entrypoint":["/app/server"]`}},
+		{"Détails HTML", "/image/my-app/v1/details", browser, []string{
+			"docker pull registry.example.com/my-app:v1",
+			"docker pull registry.example.com/my-app@" + single.Digest,
+			"--port 8080",
+			"APP_ENV=prod",
+			"8080/tcp",
+			"org.opencontainers.image.source",
+			"COPY server /app/server",
+			"Layers (1)",
+		}},
+		{"Index HTML", "/image/my-app/v2/details", browser, []string{"Platforms", "linux/arm64", "/image/my-app/" + single.Digest + "/details"}},
+		{"Plateforme par digest", "/image/my-app/" + single.Digest + "/details", browser, []string{"docker pull registry.example.com/my-app@" + single.Digest, "linux/amd64"}},
+		// Nom à plusieurs segments, tel quel ou encodé par les liens de l'interface
+		{"Liste avec un nom à segments", "/images", browser, []string{">library/nginx</h2>", `href="/image/library/nginx/1.25/details"`}},
+		{"Tags d'un nom à segments", "/image/library/nginx", "application/json", []string{`"name":"library/nginx"`, `"tags":["1.25"]`}},
+		{"Détails d'un nom à segments", "/image/library/nginx/1.25/details", browser, []string{"docker pull registry.example.com/library/nginx:1.25"}},
+		{"Nom encodé", "/image/library%2Fnginx/1.25/details", "application/json", []string{`"name":"library/nginx"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := get(tt.url, tt.accept)
+			require.Equal(t, 200, status, body)
+			for _, expected := range tt.contains {
+				assert.Contains(t, body, expected)
+			}
+		})
+	}
+
+	status, _ = get("/image/my-app/missing/details", "application/json")
+	assert.Equal(t, 404, status)
+	status, _ = get("/image/library/../../my-app/v1/details", "application/json")
+	assert.Equal(t, 404, status)
+	status, _ = get("/image/library%2F..%2F..%2Fmy-app/v1/details", "application/json")
+	assert.Equal(t, 404, status)
+}
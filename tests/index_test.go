@@ -0,0 +1,34 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	service "helm-portal/pkg/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexService_UpdateIndex(t *testing.T) {
+	chartService, cfg := newTestChartService(t)
+	indexService := service.NewIndexService(cfg, newTestLogger(), chartService)
+
+	// Le chemin de l'index est celui du stockage, sans panique
+	assert.NotPanics(t, func() { indexService.GetIndexPath() })
+	assert.Equal(t, filepath.Join(cfg.Storage.Path, "index.yaml"), indexService.GetIndexPath())
+
+	// Seules les archives du répertoire des charts sont indexées, pas celles à la racine du stockage
+	require.NoError(t, chartService.SaveChart(buildChartArchive(t, validChartFiles("my-chart", "1.0.0")), "my-chart-1.0.0.tgz"))
+	stray := buildChartArchive(t, validChartFiles("stray-chart", "1.0.0"))
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.Storage.Path, "stray-chart-1.0.0.tgz"), stray, 0644))
+
+	require.NoError(t, indexService.UpdateIndex())
+	assert.FileExists(t, indexService.GetIndexPath())
+
+	versions := readIndexVersions(t, cfg.Storage.Path, "my-chart")
+	require.Contains(t, versions, "1.0.0")
+	assert.Equal(t, []string{"/charts/my-chart-1.0.0.tgz"}, versions["1.0.0"].URLs)
+	assert.Empty(t, readIndexVersions(t, cfg.Storage.Path, "stray-chart"))
+}
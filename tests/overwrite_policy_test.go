@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"helm-portal/config"
+	service "helm-portal/pkg/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverwritePolicy_Modes(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Policies.Overwrite.Default = service.OverwriteImmutable
+	cfg.Policies.Overwrite.MutableTags = []string{"latest"}
+	cfg.Policies.Overwrite.Rules = []config.OverwriteRule{
+		{Pattern: "dev-*", Mode: service.OverwriteAllow},
+		{Pattern: "lib-*", Mode: service.OverwritePrerelease},
+	}
+	policy := service.NewOverwritePolicy(cfg, newTestLogger())
+
+	tests := []struct {
+		name       string
+		repository string
+		version    string
+		allowed    bool
+	}{
+		{name: "Immutable par défaut", repository: "app", version: "1.0.0", allowed: false},
+		{name: "Tag mutable", repository: "app", version: "latest", allowed: true},
+		{name: "Tag de signature cosign", repository: "app", version: "sha256-" + sha256Hex("x") + ".sig", allowed: true},
+		{name: "Règle allow", repository: "dev-app", version: "1.0.0", allowed: true},
+		{name: "Pré-release autorisée", repository: "lib-common", version: "1.0.0-rc.1", allowed: true},
+		{name: "SNAPSHOT autorisé", repository: "lib-common", version: "1.0.0-SNAPSHOT", allowed: true},
+		{name: "Release protégée", repository: "lib-common", version: "1.0.0", allowed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check(tt.repository, tt.version)
+			if tt.allowed {
+				assert.NoError(t, err)
+				return
+			}
+			var conflictErr *service.VersionConflictError
+			assert.True(t, errors.As(err, &conflictErr))
+		})
+	}
+}
+
+func TestSaveChart_ImmutableRelease(t *testing.T) {
+	chartService, _ := newTestChartService(t, func(cfg *config.Config) {
+		cfg.Policies.Overwrite.Default = service.OverwriteImmutable
+	})
+
+	files := validChartFiles("my-chart", "1.0.0")
+	original := buildChartArchive(t, files)
+	require.NoError(t, chartService.SaveChart(original, "my-chart-1.0.0.tgz"))
+
+	// Re-publier exactement le même contenu est idempotent
+	require.NoError(t, chartService.SaveChart(original, "my-chart-1.0.0.tgz"))
+
+	// Un contenu différent pour la même version est refusé
+	files["my-chart/values.yaml"] = "replicaCount: 3\n"
+	err := chartService.SaveChart(buildChartArchive(t, files), "my-chart-1.0.0.tgz")
+	var conflictErr *service.VersionConflictError
+	require.True(t, errors.As(err, &conflictErr))
+	assert.Equal(t, "my-chart", conflictErr.Repository)
+
+	stored, err := chartService.GetChart("my-chart", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, original, stored)
+}
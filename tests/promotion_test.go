@@ -0,0 +1,423 @@
+package tests
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"helm-portal/config"
+	"helm-portal/pkg/handlers"
+	middleware "helm-portal/pkg/middlewares"
+	"helm-portal/pkg/models"
+	service "helm-portal/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type promotionEnv struct {
+	cfg          *config.Config
+	chartService *service.ChartService
+	imageService *service.ImageService
+	dev          *service.Repository
+	prod         *service.Repository
+	promotion    *service.PromotionService
+	key          *ecdsa.PrivateKey
+}
+
+// setupPromotion crée le dépôt par défaut et deux dépôts isolés : dev (alice et bob publient)
+// et prod (lu et publié par alice seule), avec la clé de signature release et la base de vulnérabilités de test
+func setupPromotion(t *testing.T, rules ...config.PromotionRule) *promotionEnv {
+	t.Helper()
+
+	key, keyPath := writeCosignKey(t, t.TempDir(), "release")
+	chartService, cfg := newTestChartService(t, func(cfg *config.Config) {
+		cfg.Auth.Users = []config.User{
+			{Username: "alice", Password: "alice123"},
+			{Username: "bob", Password: "bob123"},
+		}
+		cfg.Repositories = []config.RepositoryConfig{
+			{Name: "dev", Writers: []string{"alice", "bob"}},
+			{Name: "prod", Readers: []string{"alice"}, Writers: []string{"alice"}},
+		}
+		cfg.Signatures.Keys = []config.SigningKey{{Name: "release", Path: keyPath}}
+		cfg.Policies.Overwrite.Default = service.OverwriteImmutable
+		cfg.Policies.Promotion.Rules = rules
+	})
+	log := newTestLogger()
+	env := &promotionEnv{cfg: cfg, chartService: chartService, key: key}
+	env.imageService = service.NewImageService(cfg, log)
+
+	registry, err := service.NewRepositoryRegistry(cfg, log)
+	require.NoError(t, err)
+	env.dev, err = registry.Get("dev")
+	require.NoError(t, err)
+	env.prod, err = registry.Get("prod")
+	require.NoError(t, err)
+
+	signatureService := service.NewSignatureService(cfg, env.imageService.GetPathManager(), chartService, env.imageService, log)
+	chartService.Subscribe(signatureService)
+	env.imageService.Subscribe(signatureService)
+	scanService := service.NewScanService(cfg, env.imageService.GetPathManager(), chartService, env.imageService, log)
+	_, err = scanService.Database().Import([]byte(testAdvisories), "advisories.json", false)
+	require.NoError(t, err)
+
+	env.promotion, err = service.NewPromotionService(cfg, chartService, env.imageService, registry, signatureService, scanService, log)
+	require.NoError(t, err)
+	return env
+}
+
+// pushSignedImage pousse une image Debian dans dev, signée si sign est vrai, et retourne son digest
+func (env *promotionEnv) pushSignedImage(t *testing.T, name, tag string, sign bool) string {
+	t.Helper()
+
+	digest := pushScannableImage(t, env.dev.Images, name, tag, debianAppFiles())
+	if sign {
+		pushCosignSignature(t, env.dev.Images, name, digest, digest, env.key)
+	}
+	return digest
+}
+
+func promotionRequest(sourceRepository, name, reference, targetRepository string) models.PromotionRequest {
+	return models.PromotionRequest{
+		Source: models.PromotionLocation{Repository: sourceRepository, Name: name, Reference: reference},
+		Target: models.PromotionLocation{Repository: targetRepository},
+	}
+}
+
+// assertSameBlob vérifie que le blob est partagé (lien physique) entre les deux dépôts
+func assertSameBlob(t *testing.T, source, target *service.Repository, digest string) {
+	t.Helper()
+
+	sourceInfo, err := os.Stat(source.PathManager.GetBlobPath(digest))
+	require.NoError(t, err)
+	targetInfo, err := os.Stat(target.PathManager.GetBlobPath(digest))
+	require.NoError(t, err)
+	assert.True(t, os.SameFile(sourceInfo, targetInfo), "blob %s should be hard-linked", digest)
+}
+
+func TestPromotionService_Image(t *testing.T) {
+	env := setupPromotion(t)
+	digest := env.pushSignedImage(t, "app", "1.0", true)
+
+	req := promotionRequest("dev", "app", "1.0", "prod")
+	req.RequireSignature = true
+	result, err := env.promotion.Promote("alice", req)
+	require.NoError(t, err)
+	assert.Equal(t, models.ArtifactTypeDockerImage, result.Type)
+	assert.Equal(t, digest, result.Digest)
+	assert.Equal(t, models.PromotionLocation{Repository: "prod", Name: "app", Reference: "1.0"}, result.Target)
+	assert.Equal(t, 2, result.Manifests) // l'image et sa signature
+	assert.Equal(t, 1, result.Signatures)
+	assert.Equal(t, 4, result.Blobs) // config et couche de l'image et de la signature
+	require.Len(t, result.Gates, 1)
+	assert.True(t, result.Gates[0].Passed)
+	assert.Equal(t, "verified by release", result.Gates[0].Detail)
+
+	// Même manifest, octet pour octet, et mêmes blobs
+	sourceManifest, err := os.ReadFile(env.dev.PathManager.GetImageManifestPath("app", "1.0"))
+	require.NoError(t, err)
+	targetManifest, err := os.ReadFile(env.prod.PathManager.GetImageManifestPath("app", "1.0"))
+	require.NoError(t, err)
+	assert.Equal(t, sourceManifest, targetManifest)
+	var manifest models.OCIManifest
+	require.NoError(t, json.Unmarshal(targetManifest, &manifest))
+	assertSameBlob(t, env.dev, env.prod, manifest.Config.Digest)
+	assertSameBlob(t, env.dev, env.prod, manifest.Layers[0].Digest)
+
+	// La signature copiée reste valide dans prod
+	status, err := env.prod.Signatures.ImageStatus("app", "1.0")
+	require.NoError(t, err)
+	assert.Equal(t, models.SignatureStatusVerified, status.Status)
+
+	// Nouveau tag dans le même dépôt : rien à lier
+	req = promotionRequest("prod", "app", "1.0", "prod")
+	req.Target.Reference = "stable"
+	result, err = env.promotion.Promote("alice", req)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.Blobs)
+	tags, err := env.prod.Images.ListTags("app")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"1.0", "stable", strings.Replace(digest, ":", "-", 1) + ".sig"}, tags)
+}
+
+func TestPromotionService_Index(t *testing.T) {
+	env := setupPromotion(t)
+
+	descriptors := []models.OCIDescriptor{}
+	for _, arch := range []string{"amd64", "arm64"} {
+		digest := pushScannableImage(t, env.dev.Images, "app", arch, []layerFile{{name: "app/" + arch, typeflag: '0', content: arch}})
+		data, err := os.ReadFile(env.dev.PathManager.GetImageManifestPath("app", digest))
+		require.NoError(t, err)
+		descriptors = append(descriptors, models.OCIDescriptor{
+			MediaType: models.MediaTypeOCIManifest,
+			Digest:    digest,
+			Size:      int64(len(data)),
+			Platform:  &models.OCIPlatform{OS: "linux", Architecture: arch},
+		})
+	}
+	index, err := json.Marshal(models.OCIIndex{SchemaVersion: 2, MediaType: models.MediaTypeOCIManifestList, Manifests: descriptors})
+	require.NoError(t, err)
+	require.NoError(t, env.dev.Images.SaveImage("app", "2.0", index, models.MediaTypeOCIManifestList))
+
+	// Vers le dépôt par défaut, sous un autre nom
+	req := promotionRequest("dev", "app", "2.0", "")
+	req.Target.Name = "release-app"
+	result, err := env.promotion.Promote("bob", req)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("sha256:%x", sha256.Sum256(index)), result.Digest)
+	assert.Equal(t, 3, result.Manifests)
+
+	metadata, err := env.imageService.GetImageMetadata("release-app", "2.0")
+	require.NoError(t, err)
+	assert.Len(t, metadata.Platforms, 2)
+	for _, descriptor := range descriptors {
+		manifest, err := env.imageService.GetImageManifest("release-app", descriptor.Digest)
+		require.NoError(t, err)
+		assert.FileExists(t, env.imageService.GetPathManager().GetBlobPath(manifest.Layers[0].Digest))
+	}
+	assert.False(t, env.imageService.ImageExists("release-app", "amd64"))
+}
+
+func TestPromotionService_Chart(t *testing.T) {
+	env := setupPromotion(t)
+	require.NoError(t, env.dev.Charts.SaveChart(buildChartArchive(t, validChartFiles("my-chart", "1.0.0")), "my-chart-1.0.0.tgz"))
+
+	// Chart poussé avec helm push, avec son SBOM, puis signé
+	pushChartManifest(t, env.dev.Images, env.dev.Charts, "my-chart", "1.0.0")
+	sbomService := service.NewSBOMService(env.cfg, env.dev.PathManager, env.dev.Charts, env.dev.Images, newTestLogger())
+	info, _, err := sbomService.ChartSBOM("my-chart", "1.0.0", models.SBOMFormatSPDX, false)
+	require.NoError(t, err)
+	pushCosignSignature(t, env.dev.Images, "my-chart", info.Subject, info.Subject, env.key)
+
+	req := promotionRequest("dev", "my-chart", "1.0.0", "prod")
+	req.RequireSignature = true
+	req.RequireScan = true
+	result, err := env.promotion.Promote("alice", req)
+	require.NoError(t, err)
+	assert.Equal(t, models.ArtifactTypeHelmChart, result.Type)
+	assert.Equal(t, info.Subject, result.Digest)
+	assert.Equal(t, 2, result.Signatures) // SBOM et signature
+	require.Len(t, result.Gates, 2)
+	assert.True(t, result.Gates[1].Passed, result.Gates[1].Detail)
+
+	assert.True(t, env.prod.Charts.ChartExists("my-chart", "1.0.0"))
+	assert.FileExists(t, filepath.Join(env.prod.PathManager.GetBasePath(), "manifests", "my-chart", "1.0.0.json"))
+	status, err := env.prod.Signatures.ChartStatus("my-chart", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, models.SignatureStatusVerified, status.Status)
+	referrers, err := service.ListReferrers(env.prod.PathManager, "my-chart", info.Subject, models.MediaTypeSPDX)
+	require.NoError(t, err)
+	assert.Len(t, referrers, 1)
+
+	// Un chart garde son nom et sa version
+	req = promotionRequest("dev", "my-chart", "1.0.0", "")
+	req.Target.Reference = "2.0.0"
+	_, err = env.promotion.Promote("alice", req)
+	assert.ErrorIs(t, err, service.ErrInvalidPromotion)
+}
+
+func TestPromotionService_Gates(t *testing.T) {
+	tests := []struct {
+		name           string
+		rules          []config.PromotionRule
+		sign           bool
+		configure      func(req *models.PromotionRequest)
+		expectedGates  map[string]bool
+		expectedDetail string
+	}{
+		{"Sans contrôle", nil, false, func(req *models.PromotionRequest) {}, map[string]bool{}, ""},
+		{"Signature absente", nil, false, func(req *models.PromotionRequest) {
+			req.RequireSignature = true
+		}, map[string]bool{"signature": false}, "unsigned"},
+		{"Vulnérabilités critiques", nil, true, func(req *models.PromotionRequest) {
+			req.RequireScan = true
+		}, map[string]bool{"scan": false}, "2 CRITICAL, 1 HIGH vulnerabilities above MEDIUM"},
+		{"Sévérité tolérée", nil, true, func(req *models.PromotionRequest) {
+			req.RequireScan = true
+			req.MaxSeverity = models.SeverityCritical
+		}, map[string]bool{"scan": true}, ""},
+		{"Règle du dépôt cible", []config.PromotionRule{{Repository: "prod", RequireSignature: true}}, false, func(req *models.PromotionRequest) {}, map[string]bool{"signature": false}, "unsigned"},
+		{"Règle plus stricte que la requête", []config.PromotionRule{{Repository: "prod*", RequireScan: true, MaxSeverity: models.SeverityLow}}, true, func(req *models.PromotionRequest) {
+			req.MaxSeverity = models.SeverityCritical
+		}, map[string]bool{"scan": false}, "above LOW"},
+		{"Règle d'un autre dépôt", []config.PromotionRule{{Repository: "staging", RequireSignature: true}}, false, func(req *models.PromotionRequest) {}, map[string]bool{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := setupPromotion(t, tt.rules...)
+			env.pushSignedImage(t, "app", "1.0", tt.sign)
+			req := promotionRequest("dev", "app", "1.0", "prod")
+			tt.configure(&req)
+
+			result, err := env.promotion.Promote("alice", req)
+			require.NotNil(t, result)
+			gates := map[string]bool{}
+			for _, gate := range result.Gates {
+				gates[gate.Name] = gate.Passed
+			}
+			assert.Equal(t, tt.expectedGates, gates)
+
+			passed := true
+			for _, ok := range tt.expectedGates {
+				passed = passed && ok
+			}
+			if passed {
+				require.NoError(t, err)
+				assert.True(t, env.prod.Images.ImageExists("app", "1.0"))
+				return
+			}
+			assert.ErrorIs(t, err, service.ErrPromotionGateFailed)
+			assert.Contains(t, err.Error(), tt.expectedDetail)
+			assert.False(t, env.prod.Images.ImageExists("app", "1.0"), "nothing is written when a gate fails")
+		})
+	}
+}
+
+func TestPromotionService_Errors(t *testing.T) {
+	env := setupPromotion(t)
+	env.pushSignedImage(t, "app", "1.0", false)
+	pushScannableImage(t, env.prod.Images, "app", "1.0", []layerFile{{name: "app/other", typeflag: '0', content: "other"}})
+
+	tests := []struct {
+		name          string
+		actor         string
+		req           models.PromotionRequest
+		expectedError error
+	}{
+		{"Dépôt cible non autorisé", "bob", promotionRequest("dev", "app", "1.0", "prod"), service.ErrPromotionForbidden},
+		{"Dépôt source non lisible", "bob", promotionRequest("prod", "app", "1.0", "dev"), service.ErrPromotionForbidden},
+		{"Dépôt inconnu", "alice", promotionRequest("dev", "app", "1.0", "staging"), service.ErrRepositoryNotFound},
+		{"Tag inconnu", "alice", promotionRequest("dev", "app", "9.9", "prod"), service.ErrPromotionSourceNotFound},
+		{"Source et cible identiques", "alice", promotionRequest("dev", "app", "1.0", "dev"), service.ErrInvalidPromotion},
+		{"Nom invalide", "alice", promotionRequest("dev", "../app", "1.0", "prod"), service.ErrInvalidPromotion},
+		{"Référence manquante", "alice", promotionRequest("dev", "app", "", "prod"), service.ErrInvalidPromotion},
+		{"Sévérité invalide", "alice", models.PromotionRequest{
+			Source:      models.PromotionLocation{Repository: "dev", Name: "app", Reference: "1.0"},
+			Target:      models.PromotionLocation{Repository: "prod"},
+			MaxSeverity: "SEVERE",
+		}, service.ErrInvalidPromotion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := env.promotion.Promote(tt.actor, tt.req)
+			assert.ErrorIs(t, err, tt.expectedError)
+		})
+	}
+
+	t.Run("Tag existant immuable", func(t *testing.T) {
+		_, err := env.promotion.Promote("alice", promotionRequest("dev", "app", "1.0", "prod"))
+		var conflictErr *service.VersionConflictError
+		assert.ErrorAs(t, err, &conflictErr)
+	})
+
+	t.Run("Règle invalide", func(t *testing.T) {
+		cfg := &config.Config{}
+		cfg.Policies.Promotion.Rules = []config.PromotionRule{{Repository: "prod", MaxSeverity: "SEVERE"}}
+		_, err := service.NewPromotionService(cfg, env.chartService, env.imageService, nil, nil, nil, newTestLogger())
+		assert.Error(t, err)
+	})
+}
+
+// assertNoBlob vérifie que la promotion n'a laissé aucune couche de l'image dans le dépôt cible
+func assertNoBlob(t *testing.T, source, target *service.Repository, name, reference string) {
+	t.Helper()
+
+	data, err := os.ReadFile(source.PathManager.GetImageManifestPath(name, reference))
+	require.NoError(t, err)
+	var manifest models.OCIManifest
+	require.NoError(t, json.Unmarshal(data, &manifest))
+	for _, layer := range manifest.Layers {
+		assert.NoFileExists(t, target.PathManager.GetBlobPath(layer.Digest))
+	}
+}
+
+func TestPromotionService_Rollback(t *testing.T) {
+	t.Run("Tag cible refusé avant toute écriture", func(t *testing.T) {
+		env := setupPromotion(t)
+		digest := env.pushSignedImage(t, "app", "1.0", true)
+		pushScannableImage(t, env.prod.Images, "app", "1.0", []layerFile{{name: "app/other", typeflag: '0', content: "other"}})
+
+		_, err := env.promotion.Promote("alice", promotionRequest("dev", "app", "1.0", "prod"))
+		var conflictErr *service.VersionConflictError
+		require.ErrorAs(t, err, &conflictErr)
+
+		assertNoBlob(t, env.dev, env.prod, "app", "1.0")
+		assert.False(t, env.prod.Images.ImageExists("app", strings.Replace(digest, ":", "-", 1)+".sig"))
+	})
+
+	t.Run("Échec de la copie d'une signature", func(t *testing.T) {
+		env := setupPromotion(t)
+		digest := env.pushSignedImage(t, "app", "1.0", true)
+		// La signature ne peut pas être écrite dans prod
+		sigPath := env.prod.PathManager.GetImageManifestPath("app", strings.Replace(digest, ":", "-", 1)+".sig")
+		require.NoError(t, os.MkdirAll(sigPath, 0755))
+
+		_, err := env.promotion.Promote("alice", promotionRequest("dev", "app", "1.0", "prod"))
+		require.Error(t, err)
+
+		// Le tag n'est pas écrit et les blobs liés sont retirés
+		assert.False(t, env.prod.Images.ImageExists("app", "1.0"))
+		assert.NoFileExists(t, env.prod.PathManager.GetImageManifestPath("app", digest))
+		assertNoBlob(t, env.dev, env.prod, "app", "1.0")
+	})
+
+	t.Run("Échec de l'écriture de la provenance", func(t *testing.T) {
+		env := setupPromotion(t)
+		require.NoError(t, env.dev.Charts.SaveChart(buildChartArchive(t, validChartFiles("my-chart", "1.0.0")), "my-chart-1.0.0.tgz"))
+		pushChartManifest(t, env.dev.Images, env.dev.Charts, "my-chart", "1.0.0")
+		require.NoError(t, env.dev.Charts.SaveProvenance("my-chart-1.0.0.tgz", []byte("-----BEGIN PGP SIGNED MESSAGE-----\nHash: SHA512\n")))
+		// La provenance ne peut pas être écrite dans prod
+		require.NoError(t, os.MkdirAll(env.prod.PathManager.GetChartPath("my-chart", "1.0.0")+".prov", 0755))
+
+		_, err := env.promotion.Promote("alice", promotionRequest("dev", "my-chart", "1.0.0", "prod"))
+		require.Error(t, err)
+
+		// Le chart, son manifest OCI et son entrée d'index sont retirés
+		assert.False(t, env.prod.Charts.ChartExists("my-chart", "1.0.0"))
+		assert.NoFileExists(t, env.prod.PathManager.GetManifestPath("my-chart", "1.0.0"))
+		assert.NotContains(t, readIndexVersions(t, env.prod.PathManager.GetBasePath(), "my-chart"), "1.0.0")
+	})
+}
+
+func TestPromotionHandler(t *testing.T) {
+	env := setupPromotion(t)
+	env.pushSignedImage(t, "app", "1.0", false)
+
+	app := fiber.New()
+	authMiddleware := middleware.NewAuthMiddleware(env.cfg, newTestLogger())
+	app.Post("/api/promote", authMiddleware.Authenticate(), handlers.NewPromotionHandler(env.promotion, newTestLogger()).Promote)
+
+	tests := []struct {
+		name           string
+		user, password string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"Sans authentification", "", "", `{"source":{"repository":"dev","name":"app","reference":"1.0"},"target":{"repository":"prod"}}`, 401, ""},
+		{"Corps invalide", "alice", "alice123", `{`, 400, "Invalid request body"},
+		{"Écriture refusée", "bob", "bob123", `{"source":{"repository":"dev","name":"app","reference":"1.0"},"target":{"repository":"prod"}}`, 403, "cannot write"},
+		{"Source inconnue", "alice", "alice123", `{"source":{"repository":"dev","name":"missing","reference":"1.0"},"target":{"repository":"prod"}}`, 404, "artifact to promote not found"},
+		{"Signature exigée", "alice", "alice123", `{"source":{"repository":"dev","name":"app","reference":"1.0"},"target":{"repository":"prod"},"requireSignature":true}`, 412, `"gates":[{"name":"signature","passed":false,"detail":"unsigned"}]`},
+		{"Promotion", "alice", "alice123", `{"source":{"repository":"dev","name":"app","reference":"1.0"},"target":{"repository":"prod","reference":"stable"}}`, 201, `"reference":"stable"`},
+		{"Tag déjà promu", "alice", "alice123", `{"source":{"repository":"dev","name":"app","reference":"1.0"},"target":{"repository":"prod","reference":"stable"}}`, 201, `"blobs":0`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body, _ := doRequest(t, app, "POST", "/api/promote", tt.user, tt.password, strings.NewReader(tt.body), "application/json")
+			assert.Equal(t, tt.expectedStatus, status, string(body))
+			assert.Contains(t, string(body), tt.expectedBody)
+		})
+	}
+	assert.True(t, env.prod.Images.ImageExists("app", "stable"))
+}
@@ -0,0 +1,451 @@
+package tests
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"helm-portal/config"
+	"helm-portal/pkg/handlers"
+	"helm-portal/pkg/models"
+	service "helm-portal/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+var upstreamPathPattern = regexp.MustCompile(`^/v2/(.+)/(manifests|blobs)/([^/]+)$|^/v2/(.+)/tags/list$`)
+
+// upstreamRegistry est un registre amont minimal (manifests, blobs, tags), anonyme ou protégé
+// par une authentification basic ou bearer (jeton délivré par /token contre les identifiants)
+type upstreamRegistry struct {
+	*httptest.Server
+	auth string // "", "basic" ou "bearer"
+
+	mu        sync.Mutex
+	manifests map[string][]byte // <dépôt>:<référence>
+	blobs     map[string][]byte
+	requests  map[string]int // "GET manifest", "HEAD manifest", "GET blob", "token"
+}
+
+func newUpstreamRegistry(t *testing.T, auth string) *upstreamRegistry {
+	t.Helper()
+
+	r := &upstreamRegistry{
+		auth:      auth,
+		manifests: make(map[string][]byte),
+		blobs:     make(map[string][]byte),
+		requests:  make(map[string]int),
+	}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.serve))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *upstreamRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if req.URL.Path == "/token" {
+		r.requests["token"]++
+		if user, password, ok := req.BasicAuth(); !ok || user != "proxy" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"token": "token-" + req.URL.Query().Get("scope"), "expires_in": 300})
+		return
+	}
+
+	switch r.auth {
+	case "basic":
+		if user, password, ok := req.BasicAuth(); !ok || user != "proxy" || password != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="upstream"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	case "bearer":
+		match := upstreamPathPattern.FindStringSubmatch(req.URL.Path)
+		repo := ""
+		if match != nil {
+			repo = match[1] + match[4]
+		}
+		scope := "repository:" + repo + ":pull"
+		if req.Header.Get("Authorization") != "Bearer token-"+scope {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="upstream",scope="%s"`, r.URL, scope))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	match := upstreamPathPattern.FindStringSubmatch(req.URL.Path)
+	switch {
+	case match == nil:
+		w.WriteHeader(http.StatusNotFound)
+	case match[4] != "":
+		tags := []string{}
+		for key := range r.manifests {
+			if repo, ref, _ := strings.Cut(key, ":"); repo == match[4] && !strings.HasPrefix(ref, "sha256") {
+				tags = append(tags, ref)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"name": match[4], "tags": tags})
+	case match[2] == "manifests":
+		r.requests[req.Method+" manifest"]++
+		data, ok := r.manifests[match[1]+":"+match[3]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", models.MediaTypeOCIManifest)
+		w.Header().Set("Docker-Content-Digest", fmt.Sprintf("sha256:%x", sha256.Sum256(data)))
+		if req.Method == http.MethodGet {
+			w.Write(data)
+		}
+	default:
+		r.requests[req.Method+" blob"]++
+		data, ok := r.blobs[match[3]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	}
+}
+
+// push publie une image d'une couche dans le registre amont et retourne le digest de son manifest et de sa couche
+func (r *upstreamRegistry) push(t *testing.T, repo, tag, content string) (manifestDigest, layerDigest string) {
+	t.Helper()
+
+	store := func(mediaType string, data []byte) models.OCIDescriptor {
+		digest := fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+		r.blobs[digest] = data
+		return models.OCIDescriptor{MediaType: mediaType, Digest: digest, Size: int64(len(data))}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	layer := store(models.MediaTypeOCILayer, buildLayer(t, "gzip", []layerFile{{name: "etc/motd", typeflag: tar.TypeReg, content: content}}))
+	configBlob := store(models.MediaTypeOCIConfig, []byte(`{"architecture":"amd64","os":"linux"}`))
+	manifest, err := json.Marshal(models.OCIManifest{
+		SchemaVersion: 2,
+		MediaType:     models.MediaTypeOCIManifest,
+		Config:        configBlob,
+		Layers:        []models.OCIDescriptor{layer},
+	})
+	require.NoError(t, err)
+	manifestDigest = fmt.Sprintf("sha256:%x", sha256.Sum256(manifest))
+	r.manifests[repo+":"+tag] = manifest
+	r.manifests[repo+":"+manifestDigest] = manifest
+	return manifestDigest, layer.Digest
+}
+
+func (r *upstreamRegistry) count(request string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests[request]
+}
+
+// setupProxyApp crée les routes OCI du serveur, avec le cache pull-through des amonts configurés
+func setupProxyApp(t *testing.T, proxy config.ProxyConfig) (*fiber.App, *service.ImageService) {
+	t.Helper()
+
+	chartService, cfg := newTestChartService(t, func(cfg *config.Config) {
+		cfg.Proxy = proxy
+	})
+	imageService := service.NewImageService(cfg, newTestLogger())
+	proxyService, err := service.NewProxyService(cfg, imageService.GetPathManager(), imageService, newTestLogger())
+	require.NoError(t, err)
+
+	app := fiber.New()
+	ociHandler := handlers.NewOCIHandler(chartService, imageService, cfg, newTestLogger()).WithProxy(proxyService)
+	proxyHandler := handlers.NewProxyHandler(proxyService, newTestLogger())
+	app.Get("/api/proxy", proxyHandler.GetStatus)
+	app.Get("/proxy/:name/index.yaml", proxyHandler.GetHelmIndex)
+	app.Get("/proxy/:name/charts/:file", proxyHandler.GetHelmChart)
+
+	ociGroup := app.Group("/v2")
+	ociGroup.Get("/:name/tags/list", ociHandler.HandleListTags)
+	ociGroup.Head("/:name/manifests/:reference", ociHandler.HandleManifest)
+	ociGroup.Get("/:name/manifests/:reference", ociHandler.HandleManifest)
+	ociGroup.Head("/:name/blobs/:digest", ociHandler.HeadBlob)
+	ociGroup.Get("/:name/blobs/:digest", ociHandler.GetBlob)
+	ociGroup.Head("/*", ociHandler.HandleProxy)
+	ociGroup.Get("/*", ociHandler.HandleProxy)
+	return app, imageService
+}
+
+// ageManifest vieillit un manifest en cache pour dépasser le TTL de son tag
+func ageManifest(t *testing.T, imageService *service.ImageService, name, reference string) {
+	t.Helper()
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(imageService.GetPathManager().GetImageManifestPath(name, reference), old, old))
+}
+
+func TestProxy_PullThrough(t *testing.T) {
+	upstream := newUpstreamRegistry(t, "")
+	manifestDigest, layerDigest := upstream.push(t, "library/nginx", "1.25", "welcome")
+	app, imageService := setupProxyApp(t, config.ProxyConfig{
+		Registries: []config.UpstreamRegistry{{Name: "hub", URL: upstream.URL, Namespace: "docker.io", TagTTL: "1h"}},
+	})
+
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		expectedStatus int
+		expectedDigest string
+	}{
+		{"Manifest absent, récupéré de l'amont", "GET", "/v2/hub/library/nginx/manifests/1.25", 200, manifestDigest},
+		{"Manifest en cache", "HEAD", "/v2/hub/library/nginx/manifests/1.25", 200, manifestDigest},
+		{"Manifest par digest", "GET", "/v2/hub/library/nginx/manifests/" + manifestDigest, 200, manifestDigest},
+		{"Couche", "GET", "/v2/hub/library/nginx/blobs/" + layerDigest, 200, layerDigest},
+		{"Couche en cache", "HEAD", "/v2/hub/library/nginx/blobs/" + layerDigest, 200, layerDigest},
+		{"Miroir containerd", "GET", "/v2/library/nginx/manifests/1.25?ns=docker.io", 200, manifestDigest},
+		{"Tag absent de l'amont", "GET", "/v2/hub/library/nginx/manifests/9.9", 404, ""},
+		{"Blob absent de l'amont", "GET", "/v2/hub/library/nginx/blobs/sha256:" + strings.Repeat("0", 64), 404, ""},
+		{"Dépôt non mis en cache", "GET", "/v2/other/library/nginx/manifests/1.25", 404, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body, headers := doRequest(t, app, tt.method, tt.url, "", "", nil, "")
+			assert.Equal(t, tt.expectedStatus, status, string(body))
+			if tt.expectedDigest != "" {
+				assert.Equal(t, tt.expectedDigest, headers.Get("Docker-Content-Digest"))
+			}
+		})
+	}
+
+	// Chaque contenu n'est demandé qu'une fois à l'amont (config de l'image comprise), plus les deux absents
+	assert.Equal(t, 2, upstream.count("GET manifest"))
+	assert.Equal(t, 0, upstream.count("HEAD manifest"))
+	assert.Equal(t, 3, upstream.count("GET blob"))
+
+	metadata, err := imageService.GetImageMetadata("hub/library/nginx", "1.25")
+	require.NoError(t, err)
+	assert.Equal(t, manifestDigest, metadata.Digest)
+	require.NotNil(t, metadata.Config)
+	assert.Equal(t, "amd64", metadata.Config.Architecture)
+
+	t.Run("Tags de l'amont", func(t *testing.T) {
+		status, body, _ := doRequest(t, app, "GET", "/v2/hub/library/nginx/tags/list", "", "", nil, "")
+		assert.Equal(t, 200, status)
+		assert.JSONEq(t, `{"name":"hub/library/nginx","tags":["1.25"]}`, string(body))
+	})
+}
+
+func TestProxy_TagTTL(t *testing.T) {
+	upstream := newUpstreamRegistry(t, "")
+	first, _ := upstream.push(t, "app", "latest", "v1")
+	app, imageService := setupProxyApp(t, config.ProxyConfig{
+		Registries: []config.UpstreamRegistry{{Name: "ghcr", URL: upstream.URL, TagTTL: "1h"}},
+	})
+	pull := func() string {
+		status, body, headers := doRequest(t, app, "GET", "/v2/ghcr/app/manifests/latest", "", "", nil, "")
+		require.Equal(t, 200, status, string(body))
+		return headers.Get("Docker-Content-Digest")
+	}
+
+	assert.Equal(t, first, pull())
+
+	// Le tag change en amont : la copie en cache reste servie tant que le TTL n'est pas écoulé
+	second, _ := upstream.push(t, "app", "latest", "v2")
+	assert.Equal(t, first, pull())
+	assert.Equal(t, 1, upstream.count("GET manifest"))
+
+	// TTL écoulé : le tag est revalidé et le nouveau manifest récupéré, malgré la politique d'écrasement
+	ageManifest(t, imageService, "ghcr/app", "latest")
+	assert.Equal(t, second, pull())
+	assert.Equal(t, 1, upstream.count("HEAD manifest"))
+	assert.Equal(t, 2, upstream.count("GET manifest"))
+
+	// TTL écoulé sans changement : un HEAD suffit
+	ageManifest(t, imageService, "ghcr/app", "latest")
+	assert.Equal(t, second, pull())
+	assert.Equal(t, 2, upstream.count("HEAD manifest"))
+	assert.Equal(t, 2, upstream.count("GET manifest"))
+	assert.Equal(t, second, pull())
+	assert.Equal(t, 2, upstream.count("HEAD manifest"))
+}
+
+func TestProxy_Credentials(t *testing.T) {
+	for _, auth := range []string{"basic", "bearer"} {
+		t.Run(auth, func(t *testing.T) {
+			upstream := newUpstreamRegistry(t, auth)
+			digest, layerDigest := upstream.push(t, "team/app", "1.0", "private")
+			app, _ := setupProxyApp(t, config.ProxyConfig{
+				Registries: []config.UpstreamRegistry{
+					{Name: "private", URL: upstream.URL, Username: "proxy", Password: "secret"},
+					{Name: "anonymous", URL: upstream.URL},
+				},
+			})
+
+			status, body, headers := doRequest(t, app, "GET", "/v2/private/team/app/manifests/1.0", "", "", nil, "")
+			assert.Equal(t, 200, status, string(body))
+			assert.Equal(t, digest, headers.Get("Docker-Content-Digest"))
+			status, _, _ = doRequest(t, app, "GET", "/v2/private/team/app/blobs/"+layerDigest, "", "", nil, "")
+			assert.Equal(t, 200, status)
+
+			status, body, _ = doRequest(t, app, "GET", "/v2/anonymous/team/app/manifests/1.0", "", "", nil, "")
+			assert.Equal(t, 503, status)
+			assert.Contains(t, string(body), "UNAVAILABLE")
+		})
+	}
+
+	t.Run("Identifiants depuis l'environnement", func(t *testing.T) {
+		upstream := newUpstreamRegistry(t, "bearer")
+		upstream.push(t, "team/app", "1.0", "private")
+		t.Setenv("PROXY_MY_REGISTRY_USERNAME", "proxy")
+		t.Setenv("PROXY_MY_REGISTRY_PASSWORD", "secret")
+
+		configPath := t.TempDir() + "/config.yaml"
+		require.NoError(t, os.WriteFile(configPath, []byte("proxy:\n  registries:\n    - name: my-registry\n      url: "+upstream.URL+"\n"), 0644))
+		cfg, err := config.LoadConfig(configPath)
+		require.NoError(t, err)
+
+		app, _ := setupProxyApp(t, cfg.Proxy)
+		status, body, _ := doRequest(t, app, "GET", "/v2/my-registry/team/app/manifests/1.0", "", "", nil, "")
+		assert.Equal(t, 200, status, string(body))
+		// Le jeton est réutilisé pour les requêtes suivantes du même dépôt
+		assert.Equal(t, 1, upstream.count("token"))
+	})
+}
+
+func TestProxy_OfflineFallback(t *testing.T) {
+	upstream := newUpstreamRegistry(t, "")
+	digest, layerDigest := upstream.push(t, "app", "1.0", "offline")
+	app, imageService := setupProxyApp(t, config.ProxyConfig{
+		Registries: []config.UpstreamRegistry{{Name: "hub", URL: upstream.URL, TagTTL: "1m"}},
+	})
+
+	status, _, _ := doRequest(t, app, "GET", "/v2/hub/app/manifests/1.0", "", "", nil, "")
+	require.Equal(t, 200, status)
+	status, _, _ = doRequest(t, app, "GET", "/v2/hub/app/blobs/"+layerDigest, "", "", nil, "")
+	require.Equal(t, 200, status)
+
+	upstream.Close()
+	ageManifest(t, imageService, "hub/app", "1.0")
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+	}{
+		{"Tag expiré servi depuis le cache", "/v2/hub/app/manifests/1.0", 200},
+		{"Digest en cache", "/v2/hub/app/manifests/" + digest, 200},
+		{"Couche en cache", "/v2/hub/app/blobs/" + layerDigest, 200},
+		{"Tag jamais récupéré", "/v2/hub/app/manifests/2.0", 503},
+		{"Tags en cache", "/v2/hub/app/tags/list", 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body, _ := doRequest(t, app, "GET", tt.url, "", "", nil, "")
+			assert.Equal(t, tt.expectedStatus, status, string(body))
+		})
+	}
+
+	t.Run("État des amonts", func(t *testing.T) {
+		status, body, _ := doRequest(t, app, "GET", "/api/proxy", "", "", nil, "")
+		require.Equal(t, 200, status)
+		var response struct {
+			Upstreams []models.UpstreamStatus `json:"upstreams"`
+		}
+		require.NoError(t, json.Unmarshal(body, &response))
+		require.Len(t, response.Upstreams, 1)
+		assert.Equal(t, "hub", response.Upstreams[0].Name)
+		assert.False(t, response.Upstreams[0].Online)
+		assert.NotEmpty(t, response.Upstreams[0].LastError)
+		assert.NotNil(t, response.Upstreams[0].LastSuccess)
+	})
+}
+
+func TestProxy_HelmRepository(t *testing.T) {
+	archive := buildChartArchive(t, validChartFiles("my-chart", "1.0.0"))
+	var mu sync.Mutex
+	downloads := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if user, password, ok := req.BasicAuth(); !ok || user != "proxy" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch req.URL.Path {
+		case "/stable/index.yaml":
+			fmt.Fprintf(w, "apiVersion: v1\nentries:\n  my-chart:\n  - name: my-chart\n    version: 1.0.0\n    icon: https://example.com/icon.png\n    digest: %x\n    urls:\n    - packages/my-chart-1.0.0.tgz\n", sha256.Sum256(archive))
+		case "/stable/packages/my-chart-1.0.0.tgz":
+			downloads++
+			w.Write(archive)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer upstream.Close()
+
+	app, _ := setupProxyApp(t, config.ProxyConfig{
+		HelmRepositories: []config.UpstreamHelmRepository{{Name: "stable", URL: upstream.URL + "/stable", Username: "proxy", Password: "secret"}},
+	})
+
+	status, body, _ := doRequest(t, app, "GET", "/proxy/stable/index.yaml", "", "", nil, "")
+	require.Equal(t, 200, status, string(body))
+	var index struct {
+		Entries map[string][]struct {
+			URLs []string `yaml:"urls"`
+			Icon string   `yaml:"icon"`
+		} `yaml:"entries"`
+	}
+	require.NoError(t, yaml.Unmarshal(body, &index))
+	require.Len(t, index.Entries["my-chart"], 1)
+	assert.Equal(t, []string{"charts/my-chart-1.0.0.tgz"}, index.Entries["my-chart"][0].URLs)
+	assert.Equal(t, "https://example.com/icon.png", index.Entries["my-chart"][0].Icon)
+
+	for i := 0; i < 2; i++ {
+		status, body, _ = doRequest(t, app, "GET", "/proxy/stable/charts/my-chart-1.0.0.tgz", "", "", nil, "")
+		require.Equal(t, 200, status)
+		assert.Equal(t, archive, body)
+	}
+	assert.Equal(t, 1, downloads)
+
+	status, _, _ = doRequest(t, app, "GET", "/proxy/stable/charts/other-1.0.0.tgz", "", "", nil, "")
+	assert.Equal(t, 404, status)
+	status, _, _ = doRequest(t, app, "GET", "/proxy/unknown/index.yaml", "", "", nil, "")
+	assert.Equal(t, 404, status)
+
+	// Amont injoignable : l'index et les charts en cache restent servis
+	upstream.Close()
+	status, _, _ = doRequest(t, app, "GET", "/proxy/stable/index.yaml", "", "", nil, "")
+	assert.Equal(t, 200, status)
+	status, _, _ = doRequest(t, app, "GET", "/proxy/stable/charts/my-chart-1.0.0.tgz", "", "", nil, "")
+	assert.Equal(t, 200, status)
+}
+
+func TestProxyService_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		proxy config.ProxyConfig
+	}{
+		{"URL invalide", config.ProxyConfig{Registries: []config.UpstreamRegistry{{Name: "hub", URL: "registry-1.docker.io"}}}},
+		{"Nom dupliqué", config.ProxyConfig{Registries: []config.UpstreamRegistry{{Name: "hub", URL: "https://a.io"}, {Name: "hub", URL: "https://b.io"}}}},
+		{"TTL invalide", config.ProxyConfig{Registries: []config.UpstreamRegistry{{Name: "hub", URL: "https://a.io", TagTTL: "soon"}}}},
+		{"Dépôt Helm sans nom", config.ProxyConfig{HelmRepositories: []config.UpstreamHelmRepository{{URL: "https://charts.example.com"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Proxy: tt.proxy}
+			cfg.Storage.Path = t.TempDir()
+			imageService := service.NewImageService(cfg, newTestLogger())
+			_, err := service.NewProxyService(cfg, imageService.GetPathManager(), imageService, newTestLogger())
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,388 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"helm-portal/config"
+	"helm-portal/pkg/handlers"
+	"helm-portal/pkg/models"
+	service "helm-portal/pkg/services"
+	"helm-portal/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/gofiber/template/html/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// replicaPortal est un second portail servi en HTTP, cible des règles de réplication.
+// Son API OCI demande les identifiants replicator / secret et répond 503 tant que failing est levé.
+type replicaPortal struct {
+	*httptest.Server
+	chartService *service.ChartService
+	imageService *service.ImageService
+	failing      atomic.Bool
+}
+
+func newReplicaPortal(t *testing.T) *replicaPortal {
+	t.Helper()
+
+	chartService, cfg := newTestChartService(t)
+	r := &replicaPortal{
+		chartService: chartService,
+		imageService: service.NewImageService(cfg, newTestLogger()),
+	}
+	ociHandler := handlers.NewOCIHandler(chartService, r.imageService, cfg, newTestLogger())
+
+	app := fiber.New()
+	oci := app.Group("/v2", func(c *fiber.Ctx) error {
+		if r.failing.Load() {
+			return c.SendStatus(503)
+		}
+		if c.Get("Authorization") != createBasicAuthHeader("replicator", "secret") {
+			c.Set("WWW-Authenticate", `Basic realm="Helm Registry"`)
+			return c.SendStatus(401)
+		}
+		return c.Next()
+	})
+	oci.Head("/:name/manifests/:reference", ociHandler.HandleManifest)
+	oci.Put("/:name/manifests/:reference", ociHandler.PutManifest)
+	oci.Delete("/:name/manifests/:reference", ociHandler.DeleteManifest)
+	oci.Post("/:name/blobs/uploads/", ociHandler.PostUpload)
+	oci.Put("/:name/blobs/uploads/:uuid", ociHandler.CompleteUpload)
+	oci.Head("/:name/blobs/:digest", ociHandler.HeadBlob)
+
+	r.Server = httptest.NewServer(adaptor.FiberApp(app))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// setupReplication crée un portail source répliquant ses push selon les règles données,
+// avec des délais de nouvelle tentative courts
+func setupReplication(t *testing.T, maxAttempts int, rules ...config.ReplicationRule) (*fiber.App, *service.ChartService, *service.ImageService, *service.ReplicationService) {
+	t.Helper()
+
+	chartService, cfg := newTestChartService(t, func(cfg *config.Config) {
+		cfg.Replication = config.ReplicationConfig{
+			Rules:         rules,
+			MaxAttempts:   maxAttempts,
+			RetryDelay:    "10ms",
+			MaxRetryDelay: "40ms",
+		}
+	})
+	imageService := service.NewImageService(cfg, newTestLogger())
+	replication, err := service.NewReplicationService(cfg, chartService.GetPathManager(), chartService, imageService, newTestLogger())
+	require.NoError(t, err)
+	chartService.Subscribe(replication)
+	imageService.Subscribe(replication)
+	replication.Start()
+
+	views := html.New("../src/views", ".html")
+	views.AddFuncMap(utils.TemplateFuncs())
+	app := fiber.New(fiber.Config{Views: views})
+	replicationHandler := handlers.NewReplicationHandler(replication, newTestLogger())
+	app.Get("/replication", replicationHandler.GetStatus)
+	app.Post("/api/replication/:rule/run", replicationHandler.ReplicateNow)
+	return app, chartService, imageService, replication
+}
+
+// waitReplication attend que toutes les tâches de la règle soient terminées
+func waitReplication(t *testing.T, replication *service.ReplicationService, rule string) models.ReplicationRuleStatus {
+	t.Helper()
+
+	var status models.ReplicationRuleStatus
+	require.Eventually(t, func() bool {
+		for _, s := range replication.Status() {
+			if s.Name == rule {
+				status = s
+			}
+		}
+		return status.Pending == 0 && len(status.Tasks) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	return status
+}
+
+func replicaRule(target *replicaPortal) config.ReplicationRule {
+	return config.ReplicationRule{Name: "eu", Target: target.URL, Username: "replicator", Password: "secret"}
+}
+
+func TestReplication_PushAndDelete(t *testing.T) {
+	replica := newReplicaPortal(t)
+	_, chartService, imageService, replication := setupReplication(t, 3, replicaRule(replica))
+
+	digest := pushScannableImage(t, imageService, "my-app", "1.0", []layerFile{{name: "app/main", typeflag: '0', content: "binary"}})
+	require.NoError(t, chartService.SaveChart(buildChartArchive(t, validChartFiles("my-chart", "1.2.0")), "my-chart-1.2.0.tgz"))
+
+	status := waitReplication(t, replication, "eu")
+	assert.Equal(t, 2, status.Succeeded)
+	assert.NotNil(t, status.LastSuccess)
+
+	// L'image garde son digest ; le chart, téléversé en archive, reçoit un manifest Helm
+	metadata, err := replica.imageService.GetImageMetadata("my-app", "1.0")
+	require.NoError(t, err)
+	assert.Equal(t, digest, metadata.Digest)
+	assert.True(t, replica.chartService.ChartExists("my-chart", "1.2.0"))
+
+	// Les suppressions sont répliquées
+	require.NoError(t, imageService.DeleteImage("my-app", "1.0"))
+	require.NoError(t, chartService.DeleteChart("my-chart", "1.2.0"))
+	status = waitReplication(t, replication, "eu")
+	assert.Equal(t, 4, status.Succeeded)
+	assert.Equal(t, models.EventDelete, status.Tasks[0].Action)
+	assert.False(t, replica.imageService.ImageExists("my-app", "1.0"))
+	assert.False(t, replica.chartService.ChartExists("my-chart", "1.2.0"))
+}
+
+func TestReplication_Filters(t *testing.T) {
+	replica := newReplicaPortal(t)
+	rule := replicaRule(replica)
+	rule.Repositories = []string{"my-*"}
+	rule.Tags = []string{"v*"}
+	rule.SkipDeletions = true
+	_, _, imageService, replication := setupReplication(t, 3, rule)
+
+	layer := []layerFile{{name: "app/main", typeflag: '0', content: "binary"}}
+	pushScannableImage(t, imageService, "my-app", "v1", layer)
+	pushScannableImage(t, imageService, "my-app", "dev", layer)
+	pushScannableImage(t, imageService, "other", "v1", layer)
+
+	status := waitReplication(t, replication, "eu")
+	require.Len(t, status.Tasks, 1)
+	assert.Equal(t, "my-app", status.Tasks[0].Name)
+	assert.True(t, replica.imageService.ImageExists("my-app", "v1"))
+	assert.False(t, replica.imageService.ImageExists("my-app", "dev"))
+	assert.False(t, replica.imageService.ImageExists("other", "v1"))
+
+	// skipDeletions : la copie reste sur la cible
+	require.NoError(t, imageService.DeleteImage("my-app", "v1"))
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, replication.Status()[0].Tasks, 1)
+	assert.True(t, replica.imageService.ImageExists("my-app", "v1"))
+}
+
+func TestReplication_RetryWithBackoff(t *testing.T) {
+	layer := []layerFile{{name: "app/main", typeflag: '0', content: "binary"}}
+
+	t.Run("Cible rétablie", func(t *testing.T) {
+		replica := newReplicaPortal(t)
+		replica.failing.Store(true)
+		_, _, imageService, replication := setupReplication(t, 50, replicaRule(replica))
+		pushScannableImage(t, imageService, "my-app", "1.0", layer)
+
+		require.Eventually(t, func() bool {
+			task := replication.Status()[0].Tasks[0]
+			return task.Status == models.ReplicationRetrying && task.Attempts >= 2 && task.NextAttempt != nil
+		}, 5*time.Second, 5*time.Millisecond)
+		assert.Contains(t, replication.Status()[0].Tasks[0].LastError, "503")
+
+		replica.failing.Store(false)
+		status := waitReplication(t, replication, "eu")
+		assert.Equal(t, models.ReplicationSucceeded, status.Tasks[0].Status)
+		assert.Empty(t, status.Tasks[0].LastError)
+		assert.True(t, replica.imageService.ImageExists("my-app", "1.0"))
+	})
+
+	t.Run("Abandon après la dernière tentative", func(t *testing.T) {
+		replica := newReplicaPortal(t)
+		replica.failing.Store(true)
+		_, _, imageService, replication := setupReplication(t, 3, replicaRule(replica))
+		pushScannableImage(t, imageService, "my-app", "1.0", layer)
+
+		status := waitReplication(t, replication, "eu")
+		assert.Equal(t, 1, status.Failed)
+		assert.Equal(t, models.ReplicationFailed, status.Tasks[0].Status)
+		assert.Equal(t, 3, status.Tasks[0].Attempts)
+	})
+
+	t.Run("Identifiants refusés", func(t *testing.T) {
+		replica := newReplicaPortal(t)
+		rule := replicaRule(replica)
+		rule.Password = "wrong"
+		_, _, imageService, replication := setupReplication(t, 2, rule)
+		pushScannableImage(t, imageService, "my-app", "1.0", layer)
+
+		status := waitReplication(t, replication, "eu")
+		assert.Equal(t, 1, status.Failed)
+		assert.Contains(t, status.Tasks[0].LastError, "401")
+	})
+}
+
+func TestReplication_ReplicateNow(t *testing.T) {
+	replica := newReplicaPortal(t)
+	app, chartService, imageService, replication := setupReplication(t, 3, replicaRule(replica))
+	pushScannableImage(t, imageService, "my-app", "1.0", []layerFile{{name: "app/main", typeflag: '0', content: "binary"}})
+	require.NoError(t, chartService.SaveChart(buildChartArchive(t, validChartFiles("my-chart", "1.2.0")), "my-chart-1.2.0.tgz"))
+	waitReplication(t, replication, "eu")
+
+	// La cible a perdu ses copies : "replicate now" renvoie tout
+	require.NoError(t, replica.imageService.DeleteImage("my-app", "1.0"))
+	require.NoError(t, replica.chartService.DeleteChart("my-chart", "1.2.0"))
+
+	status, body, _ := doRequest(t, app, "POST", "/api/replication/eu/run", "", "", nil, "")
+	require.Equal(t, 202, status)
+	assert.JSONEq(t, `{"rule":"eu","queued":2}`, string(body))
+	waitReplication(t, replication, "eu")
+	assert.True(t, replica.imageService.ImageExists("my-app", "1.0"))
+	assert.True(t, replica.chartService.ChartExists("my-chart", "1.2.0"))
+
+	status, _, _ = doRequest(t, app, "POST", "/api/replication/unknown/run", "", "", nil, "")
+	assert.Equal(t, 404, status)
+}
+
+func TestReplication_StatusPage(t *testing.T) {
+	replica := newReplicaPortal(t)
+	app, _, imageService, replication := setupReplication(t, 3, replicaRule(replica))
+	pushScannableImage(t, imageService, "my-app", "1.0", []layerFile{{name: "app/main", typeflag: '0', content: "binary"}})
+	waitReplication(t, replication, "eu")
+
+	req := httptest.NewRequest("GET", "/replication", nil)
+	req.Header.Set("Accept", "application/json")
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+	var result struct {
+		Rules []models.ReplicationRuleStatus `json:"rules"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	require.Len(t, result.Rules, 1)
+	assert.Equal(t, replica.URL, result.Rules[0].Target)
+	assert.True(t, result.Rules[0].Deletions)
+	require.Len(t, result.Rules[0].Tasks, 1)
+	assert.Equal(t, "my-app", result.Rules[0].Tasks[0].Target)
+
+	req = httptest.NewRequest("GET", "/replication", nil)
+	req.Header.Set("Accept", "text/html")
+	resp, err = app.Test(req, -1)
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+	page, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(page), "Replicate now")
+	assert.Contains(t, string(page), "my-app:1.0")
+}
+
+// TestReplication_RegistryTarget réplique vers un registre qui range les dépôts sous un namespace
+// et refuse la suppression par tag : la suppression passe par le digest du tag
+func TestReplication_RegistryTarget(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	manifests := make(map[string][]byte)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, req.Method+" "+req.URL.Path)
+		switch {
+		case req.Method == "HEAD" && strings.Contains(req.URL.Path, "/blobs/"):
+			w.WriteHeader(200) // couches déjà présentes
+		case req.Method == "PUT" && strings.Contains(req.URL.Path, "/manifests/"):
+			manifests[req.URL.Path], _ = io.ReadAll(req.Body)
+			w.WriteHeader(201)
+		case req.Method == "DELETE" && strings.Contains(req.URL.Path, "/manifests/sha256:"):
+			w.WriteHeader(202)
+		case req.Method == "DELETE":
+			w.WriteHeader(405)
+		case req.Method == "HEAD" && strings.Contains(req.URL.Path, "/manifests/"):
+			w.Header().Set("Docker-Content-Digest", "sha256:"+strings.Repeat("a", 64))
+			w.WriteHeader(200)
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	t.Cleanup(target.Close)
+
+	_, _, imageService, replication := setupReplication(t, 3, config.ReplicationRule{Name: "harbor", Target: target.URL, Namespace: "mirror"})
+	pushScannableImage(t, imageService, "my-app", "1.0", []layerFile{{name: "app/main", typeflag: '0', content: "binary"}})
+	waitReplication(t, replication, "harbor")
+	require.NoError(t, imageService.DeleteImage("my-app", "1.0"))
+	status := waitReplication(t, replication, "harbor")
+	assert.Equal(t, 2, status.Succeeded)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Contains(t, manifests, "/v2/mirror/my-app/manifests/1.0")
+	assert.NotContains(t, requests, "POST /v2/mirror/my-app/blobs/uploads/")
+	assert.Contains(t, requests, "DELETE /v2/mirror/my-app/manifests/1.0")
+	assert.Contains(t, requests, "DELETE /v2/mirror/my-app/manifests/sha256:"+strings.Repeat("a", 64))
+}
+
+// TestReplication_RegistryTargetSharedDigest : la suppression par digest d'un tag supprimerait
+// aussi les autres tags locaux du même manifest sur la cible, elle échoue sans nouvelle tentative
+func TestReplication_RegistryTargetSharedDigest(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	var digest string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, req.Method+" "+req.URL.Path)
+		switch {
+		case req.Method == "HEAD" && strings.Contains(req.URL.Path, "/blobs/"):
+			w.WriteHeader(200)
+		case req.Method == "PUT" && strings.Contains(req.URL.Path, "/manifests/"):
+			w.WriteHeader(201)
+		case req.Method == "DELETE":
+			w.WriteHeader(405)
+		case req.Method == "HEAD" && strings.Contains(req.URL.Path, "/manifests/"):
+			w.Header().Set("Docker-Content-Digest", digest)
+			w.WriteHeader(200)
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	t.Cleanup(target.Close)
+
+	_, _, imageService, replication := setupReplication(t, 3, config.ReplicationRule{Name: "harbor", Target: target.URL})
+	mu.Lock()
+	digest = pushScannableImage(t, imageService, "my-app", "1.0", []layerFile{{name: "app/main", typeflag: '0', content: "binary"}})
+	mu.Unlock()
+	manifest, err := os.ReadFile(imageService.GetPathManager().GetImageManifestPath("my-app", "1.0"))
+	require.NoError(t, err)
+	require.NoError(t, imageService.SaveImage("my-app", "latest", manifest, ""))
+	waitReplication(t, replication, "harbor")
+
+	require.NoError(t, imageService.DeleteImage("my-app", "1.0"))
+	status := waitReplication(t, replication, "harbor")
+	assert.Equal(t, 1, status.Failed)
+	assert.Equal(t, models.EventDelete, status.Tasks[0].Action)
+	assert.Equal(t, 1, status.Tasks[0].Attempts)
+	assert.Contains(t, status.Tasks[0].LastError, "latest")
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.NotContains(t, requests, "DELETE /v2/my-app/manifests/"+digest)
+}
+
+func TestReplicationService_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		replication config.ReplicationConfig
+	}{
+		{"Règle sans nom", config.ReplicationConfig{Rules: []config.ReplicationRule{{Target: "https://registry.example.com"}}}},
+		{"Règles en double", config.ReplicationConfig{Rules: []config.ReplicationRule{
+			{Name: "eu", Target: "https://registry.example.com"},
+			{Name: "eu", Target: "https://other.example.com"},
+		}}},
+		{"Cible invalide", config.ReplicationConfig{Rules: []config.ReplicationRule{{Name: "eu", Target: "registry.example.com"}}}},
+		{"Pattern invalide", config.ReplicationConfig{Rules: []config.ReplicationRule{{Name: "eu", Target: "https://registry.example.com", Tags: []string{"["}}}}},
+		{"Délai invalide", config.ReplicationConfig{RetryDelay: "soon"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chartService, cfg := newTestChartService(t, func(cfg *config.Config) {
+				cfg.Replication = tt.replication
+			})
+			_, err := service.NewReplicationService(cfg, chartService.GetPathManager(), chartService, nil, newTestLogger())
+			assert.Error(t, err)
+		})
+	}
+}
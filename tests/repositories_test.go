@@ -0,0 +1,315 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"helm-portal/config"
+	"helm-portal/pkg/handlers"
+	middleware "helm-portal/pkg/middlewares"
+	service "helm-portal/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRepositoriesConfig crée une configuration avec un dépôt public (team-a) et un dépôt privé (team-b)
+func newRepositoriesConfig(t *testing.T) *config.Config {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.Storage.Path = t.TempDir()
+	cfg.Auth.Users = []config.User{
+		{Username: "alice", Password: "alice123"},
+		{Username: "bob", Password: "bob123"},
+	}
+	cfg.Repositories = []config.RepositoryConfig{
+		{Name: "team-a", Writers: []string{"alice"}},
+		{Name: "team-b", Readers: []string{"bob"}, Writers: []string{"bob"}},
+	}
+	return cfg
+}
+
+func TestRepositoryRegistry_Isolation(t *testing.T) {
+	cfg := newRepositoriesConfig(t)
+	registry, err := service.NewRepositoryRegistry(cfg, newTestLogger())
+	require.NoError(t, err)
+
+	teamA, err := registry.Get("team-a")
+	require.NoError(t, err)
+	teamB, err := registry.Get("team-b")
+	require.NoError(t, err)
+	_, err = registry.Get("team-c")
+	assert.True(t, errors.Is(err, service.ErrRepositoryNotFound))
+
+	archive := buildChartArchive(t, validChartFiles("my-chart", "1.0.0"))
+	require.NoError(t, teamA.Charts.SaveChart(archive, "my-chart-1.0.0.tgz"))
+
+	// Le chart n'existe que dans team-a
+	groups, err := teamA.Charts.ListCharts()
+	require.NoError(t, err)
+	assert.Len(t, groups, 1)
+	groups, err = teamB.Charts.ListCharts()
+	require.NoError(t, err)
+	assert.Empty(t, groups)
+	rootCharts, _ := newTestChartService(t)
+	assert.False(t, rootCharts.ChartExists("my-chart", "1.0.0"))
+
+	// Stockage et index propres au dépôt, avec des URLs préfixées
+	repoPath := filepath.Join(cfg.Storage.Path, "repositories", "team-a")
+	assert.FileExists(t, filepath.Join(repoPath, "charts", "my-chart-1.0.0.tgz"))
+	assert.NoFileExists(t, filepath.Join(cfg.Storage.Path, "charts", "my-chart-1.0.0.tgz"))
+	index := readIndexVersions(t, repoPath, "my-chart")
+	require.Contains(t, index, "1.0.0")
+	assert.Equal(t, []string{"/r/team-a/charts/my-chart-1.0.0.tgz"}, index["1.0.0"].URLs)
+	assert.Empty(t, readIndexVersions(t, filepath.Join(cfg.Storage.Path, "repositories", "team-b"), "my-chart"))
+
+	names := []string{}
+	for _, repo := range registry.List() {
+		names = append(names, repo.Name())
+	}
+	assert.Equal(t, []string{"team-a", "team-b"}, names)
+}
+
+func TestRepositoryRegistry_OverwritePolicy(t *testing.T) {
+	cfg := newRepositoriesConfig(t)
+	cfg.Policies.Overwrite.Rules = []config.OverwriteRule{{Pattern: "team-a/*", Mode: service.OverwriteImmutable}}
+	registry, err := service.NewRepositoryRegistry(cfg, newTestLogger())
+	require.NoError(t, err)
+	teamA, err := registry.Get("team-a")
+	require.NoError(t, err)
+	teamB, err := registry.Get("team-b")
+	require.NoError(t, err)
+
+	files := validChartFiles("my-chart", "1.0.0")
+	original := buildChartArchive(t, files)
+	files["my-chart/values.yaml"] = "replicaCount: 3\n"
+	changed := buildChartArchive(t, files)
+
+	// Les règles s'appliquent au nom complet <dépôt>/<nom>
+	require.NoError(t, teamA.Charts.SaveChart(original, "my-chart-1.0.0.tgz"))
+	err = teamA.Charts.SaveChart(changed, "my-chart-1.0.0.tgz")
+	var conflictErr *service.VersionConflictError
+	require.True(t, errors.As(err, &conflictErr))
+	assert.Equal(t, "team-a/my-chart", conflictErr.Repository)
+
+	require.NoError(t, teamB.Charts.SaveChart(original, "my-chart-1.0.0.tgz"))
+	assert.NoError(t, teamB.Charts.SaveChart(changed, "my-chart-1.0.0.tgz"))
+
+	// Même règle pour les tags d'images
+	pushScannableImage(t, teamA.Images, "my-app", "1.0", []layerFile{{name: "app/main", typeflag: '0', content: "v1"}})
+	pushScannableImage(t, teamA.Images, "my-app", "2.0", []layerFile{{name: "app/main", typeflag: '0', content: "v2"}})
+	other, err := os.ReadFile(teamA.Images.GetPathManager().GetImageManifestPath("my-app", "2.0"))
+	require.NoError(t, err)
+	err = teamA.Images.SaveImage("my-app", "1.0", other, "")
+	require.True(t, errors.As(err, &conflictErr))
+	assert.Equal(t, "team-a/my-app", conflictErr.Repository)
+}
+
+func TestRepositoryRegistry_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		repositories []config.RepositoryConfig
+	}{
+		{"Majuscules", []config.RepositoryConfig{{Name: "Team-A"}}},
+		{"Espace", []config.RepositoryConfig{{Name: "team a"}}},
+		{"Sous-dossier", []config.RepositoryConfig{{Name: "../team"}}},
+		{"Nom vide", []config.RepositoryConfig{{Name: ""}}},
+		{"Nom réservé", []config.RepositoryConfig{{Name: "_catalog"}}},
+		{"Doublon", []config.RepositoryConfig{{Name: "team-a"}, {Name: "team-a"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Repositories: tt.repositories}
+			cfg.Storage.Path = t.TempDir()
+			_, err := service.NewRepositoryRegistry(cfg, newTestLogger())
+			assert.True(t, errors.Is(err, service.ErrInvalidRepository))
+		})
+	}
+}
+
+// setupRepositoriesApp crée une app Fiber servant les dépôts isolés et le dépôt par défaut,
+// dans l'ordre d'enregistrement des routes du serveur
+func setupRepositoriesApp(t *testing.T) (*fiber.App, *service.RepositoryRegistry) {
+	t.Helper()
+
+	cfg := newRepositoriesConfig(t)
+	log := newTestLogger()
+	registry, err := service.NewRepositoryRegistry(cfg, log)
+	require.NoError(t, err)
+
+	app := fiber.New()
+	authMiddleware := middleware.NewAuthMiddleware(cfg, log)
+	repositoryHandler := handlers.NewRepositoryHandler(registry, cfg, log)
+	ociGroup := app.Group("/v2")
+	ociGroup.Use(authMiddleware.AuthenticateExcept(repositoryHandler.OCIPrefixes()...))
+	app.Get("/repositories", repositoryHandler.ListRepositories)
+	repositoryHandler.RegisterRoutes(app, ociGroup, authMiddleware)
+
+	// Routes OCI du dépôt par défaut, enregistrées après celles des dépôts isolés
+	tmpChartService := service.NewChartService(cfg, log, nil)
+	chartService := service.NewChartService(cfg, log, service.NewIndexService(cfg, log, tmpChartService))
+	ociHandler := handlers.NewOCIHandler(chartService, service.NewImageService(cfg, log), cfg, log)
+	ociGroup.Get("/:name/tags/list", ociHandler.HandleListTags)
+	ociGroup.Head("/:name/manifests/:reference", ociHandler.HandleManifest)
+	ociGroup.Get("/:name/manifests/:reference", ociHandler.HandleManifest)
+	ociGroup.Put("/:name/manifests/:reference", ociHandler.PutManifest)
+	ociGroup.Delete("/:name/manifests/:reference", ociHandler.DeleteManifest)
+	ociGroup.Put("/:name/blobs/:digest", ociHandler.PutBlob)
+	ociGroup.Post("/:name/blobs/uploads/", ociHandler.PostUpload)
+	ociGroup.Patch("/:name/blobs/uploads/:uuid", ociHandler.PatchBlob)
+	ociGroup.Put("/:name/blobs/uploads/:uuid", ociHandler.CompleteUpload)
+	ociGroup.Head("/:name/blobs/:digest", ociHandler.HeadBlob)
+	ociGroup.Get("/:name/blobs/:digest", ociHandler.GetBlob)
+	ociGroup.Get("/:name/referrers/:digest", ociHandler.GetReferrers)
+	return app, registry
+}
+
+// doRequest exécute une requête, avec des credentials Basic si user n'est pas vide
+func doRequest(t *testing.T, app *fiber.App, method, url, user, password string, body io.Reader, contentType string) (int, []byte, http.Header) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, url, body)
+	if user != "" {
+		req.Header.Set("Authorization", createBasicAuthHeader(user, password))
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, data, resp.Header
+}
+
+func TestRepositoryRoutes_AccessControl(t *testing.T) {
+	app, registry := setupRepositoriesApp(t)
+
+	teamA, err := registry.Get("team-a")
+	require.NoError(t, err)
+	teamB, err := registry.Get("team-b")
+	require.NoError(t, err)
+	archive := buildChartArchive(t, validChartFiles("my-chart", "1.0.0"))
+	require.NoError(t, teamA.Charts.SaveChart(archive, "my-chart-1.0.0.tgz"))
+	require.NoError(t, teamB.Charts.SaveChart(archive, "my-chart-1.0.0.tgz"))
+
+	tests := []struct {
+		name     string
+		method   string
+		url      string
+		user     string
+		password string
+		expected int
+	}{
+		{"Index public anonyme", "GET", "/r/team-a/index.yaml", "", "", 200},
+		{"Archive publique anonyme", "GET", "/r/team-a/charts/my-chart-1.0.0.tgz", "", "", 200},
+		{"Archive inconnue", "GET", "/r/team-a/charts/other-1.0.0.tgz", "", "", 404},
+		{"Téléchargement public", "GET", "/r/team-a/chart/my-chart/1.0.0", "", "", 200},
+		{"Suppression anonyme", "DELETE", "/r/team-a/chart/my-chart/1.0.0", "", "", 401},
+		{"Suppression sans droit d'écriture", "DELETE", "/r/team-a/chart/my-chart/1.0.0", "bob", "bob123", 403},
+		{"Packaging anonyme", "POST", "/r/team-a/api/package", "", "", 401},
+		{"Index privé anonyme", "GET", "/r/team-b/index.yaml", "", "", 401},
+		{"Index privé, autre équipe", "GET", "/r/team-b/index.yaml", "alice", "alice123", 403},
+		{"Index privé, lecteur", "GET", "/r/team-b/index.yaml", "bob", "bob123", 200},
+		{"Mauvais mot de passe", "GET", "/r/team-b/index.yaml", "bob", "wrong", 401},
+		{"OCI tags public anonyme", "GET", "/v2/team-a/my-chart/tags/list", "", "", 200},
+		{"OCI upload anonyme", "POST", "/v2/team-a/my-chart/blobs/uploads/", "", "", 401},
+		{"OCI tags privé anonyme", "GET", "/v2/team-b/my-chart/tags/list", "", "", 401},
+		{"OCI tags, autre équipe", "GET", "/v2/team-b/my-chart/tags/list", "alice", "alice123", 403},
+		{"OCI upload sans droit d'écriture", "POST", "/v2/team-a/my-chart/blobs/uploads/", "bob", "bob123", 403},
+		{"Dépôt inconnu", "GET", "/r/team-c/index.yaml", "", "", 404},
+		// Sans nom d'image, /v2/<dépôt>/... désigne l'image <dépôt> du stockage par défaut
+		{"Stockage par défaut, upload anonyme", "POST", "/v2/team-b/blobs/uploads/", "", "", 401},
+		{"Stockage par défaut, blob anonyme", "PUT", "/v2/team-b/blobs/upload", "", "", 401},
+		{"Stockage par défaut, manifeste anonyme", "PUT", "/v2/team-b/manifests/1.0.0", "", "", 401},
+		{"Stockage par défaut, suppression anonyme", "DELETE", "/v2/team-b/manifests/1.0.0", "", "", 401},
+		{"Stockage par défaut, tags anonymes", "GET", "/v2/team-a/tags/list", "", "", 401},
+		{"Stockage par défaut, upload sans droit sur le dépôt", "POST", "/v2/team-a/blobs/uploads/", "bob", "bob123", 403},
+		{"Stockage par défaut, upload d'un writer", "POST", "/v2/team-a/blobs/uploads/", "alice", "alice123", 202},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, _, _ := doRequest(t, app, tt.method, tt.url, tt.user, tt.password, nil, "")
+			assert.Equal(t, tt.expected, status)
+		})
+	}
+
+	// L'archive servie est celle du dépôt
+	_, data, _ := doRequest(t, app, "GET", "/r/team-a/charts/my-chart-1.0.0.tgz", "", "", nil, "")
+	assert.Equal(t, archive, data)
+}
+
+func TestRepositoryRoutes_PublishIntoRepository(t *testing.T) {
+	app, registry := setupRepositoriesApp(t)
+
+	// Upload HTTP par un writer : le chart n'arrive que dans team-a
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("chart", "my-chart-1.0.0.tgz")
+	require.NoError(t, err)
+	_, err = part.Write(buildChartArchive(t, validChartFiles("my-chart", "1.0.0")))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	status, _, _ := doRequest(t, app, "POST", "/r/team-a/chart", "alice", "alice123", &body, writer.FormDataContentType())
+	assert.Equal(t, fiber.StatusSeeOther, status)
+
+	teamA, _ := registry.Get("team-a")
+	teamB, _ := registry.Get("team-b")
+	assert.True(t, teamA.Charts.ChartExists("my-chart", "1.0.0"))
+	assert.False(t, teamB.Charts.ChartExists("my-chart", "1.0.0"))
+
+	// OCI : les noms et les Location incluent l'espace de noms du dépôt
+	status, _, headers := doRequest(t, app, "POST", "/v2/team-a/my-chart/blobs/uploads/", "alice", "alice123", nil, "")
+	assert.Equal(t, 202, status)
+	assert.Regexp(t, `^/v2/team-a/my-chart/blobs/uploads/[0-9a-f-]+$`, headers.Get("Location"))
+
+	status, data, _ := doRequest(t, app, "GET", "/v2/team-a/my-chart/tags/list", "alice", "alice123", nil, "")
+	require.Equal(t, 200, status)
+	var tags struct {
+		Name string   `json:"name"`
+		Tags []string `json:"tags"`
+	}
+	require.NoError(t, json.Unmarshal(data, &tags))
+	assert.Equal(t, "team-a/my-chart", tags.Name)
+	assert.Equal(t, []string{"1.0.0"}, tags.Tags)
+
+	// Les blobs sont stockés sous le préfixe du dépôt
+	blob := []byte("layer content")
+	status, _, headers = doRequest(t, app, "PUT", "/v2/team-a/my-chart/blobs/upload", "alice", "alice123", bytes.NewReader(blob), "application/octet-stream")
+	require.Equal(t, 201, status)
+	digest := headers.Get("Docker-Content-Digest")
+	_, err = os.Stat(teamA.PathManager.GetBlobPath(digest))
+	assert.NoError(t, err)
+	_, err = os.Stat(teamB.PathManager.GetBlobPath(digest))
+	assert.True(t, os.IsNotExist(err))
+
+	// Liste des dépôts
+	status, data, _ = doRequest(t, app, "GET", "/repositories", "", "", nil, "")
+	require.Equal(t, 200, status)
+	var list struct {
+		Repositories []struct {
+			Name   string `json:"name"`
+			Index  string `json:"index"`
+			Public bool   `json:"public"`
+		} `json:"repositories"`
+	}
+	require.NoError(t, json.Unmarshal(data, &list))
+	require.Len(t, list.Repositories, 2)
+	assert.Equal(t, "team-a", list.Repositories[0].Name)
+	assert.Equal(t, "http://example.com/r/team-a/index.yaml", list.Repositories[0].Index)
+	assert.True(t, list.Repositories[0].Public)
+	assert.False(t, list.Repositories[1].Public)
+}
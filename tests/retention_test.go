@@ -0,0 +1,344 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"helm-portal/config"
+	"helm-portal/pkg/handlers"
+	"helm-portal/pkg/models"
+	service "helm-portal/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// retentionEnv regroupe un stockage avec ses services et le service de rétention configuré
+type retentionEnv struct {
+	chartService *service.ChartService
+	imageService *service.ImageService
+	stats        *service.StatsService
+	retention    *service.RetentionService
+}
+
+func setupRetention(t *testing.T, rules ...config.RetentionRule) *retentionEnv {
+	t.Helper()
+
+	chartService, cfg := newTestChartService(t, func(cfg *config.Config) {
+		cfg.Policies.Retention.Rules = rules
+	})
+	env := &retentionEnv{
+		chartService: chartService,
+		imageService: service.NewImageService(cfg, newTestLogger()),
+	}
+	env.stats = service.NewStatsService(chartService.GetPathManager(), chartService, env.imageService, newTestLogger())
+	chartService.Subscribe(env.stats)
+	env.imageService.Subscribe(env.stats)
+
+	retention, err := service.NewRetentionService(cfg, chartService.GetPathManager(), chartService, env.imageService, env.stats, newTestLogger())
+	require.NoError(t, err)
+	env.retention = retention
+	return env
+}
+
+// pushAgedImage pousse un tag d'image et antidate son push de days jours
+func (env *retentionEnv) pushAgedImage(t *testing.T, name, tag string, days int) {
+	t.Helper()
+	pushAgedImageTo(t, env.imageService, name, tag, days)
+}
+
+// pushAgedImageTo pousse un tag d'image dans le stockage d'imageService et antidate son push de days jours
+func pushAgedImageTo(t *testing.T, imageService *service.ImageService, name, tag string, days int) {
+	t.Helper()
+
+	pushScannableImage(t, imageService, name, tag, []layerFile{{name: "app/" + tag, typeflag: '0', content: tag}})
+	metadataPath := imageService.GetPathManager().GetImageTagPath(name, tag)
+	data, err := os.ReadFile(metadataPath)
+	require.NoError(t, err)
+	var metadata models.ImageMetadata
+	require.NoError(t, json.Unmarshal(data, &metadata))
+	metadata.Created = time.Now().Add(-time.Duration(days) * 24 * time.Hour)
+	data, err = json.Marshal(metadata)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(metadataPath, data, 0644))
+}
+
+// pushAgedChart enregistre une version de chart et antidate son archive de days jours
+func (env *retentionEnv) pushAgedChart(t *testing.T, name, version string, days int) {
+	t.Helper()
+
+	require.NoError(t, env.chartService.SaveChart(buildChartArchive(t, validChartFiles(name, version)), name+"-"+version+".tgz"))
+	old := time.Now().Add(-time.Duration(days) * 24 * time.Hour)
+	require.NoError(t, os.Chtimes(env.chartService.GetPathManager().GetChartPath(name, version), old, old))
+}
+
+func deletedReferences(run *models.RetentionRun) []string {
+	references := []string{}
+	for _, deletion := range run.Deletions {
+		references = append(references, deletion.Name+":"+deletion.Reference)
+	}
+	sort.Strings(references)
+	return references
+}
+
+func TestRetention_Rules(t *testing.T) {
+	tests := []struct {
+		name            string
+		rule            config.RetentionRule
+		expectedDeleted []string
+	}{
+		{
+			name:            "Garder les N derniers",
+			rule:            config.RetentionRule{Pattern: "ci-*", KeepLast: 3},
+			expectedDeleted: []string{"ci-app:c4", "ci-app:c5", "ci-app:v1.0.0"},
+		},
+		{
+			name:            "Supprimer au-delà de X jours",
+			rule:            config.RetentionRule{Pattern: "ci-*", OlderThanDays: 35},
+			expectedDeleted: []string{"ci-app:c5", "ci-app:v1.0.0"},
+		},
+		{
+			name:            "Tags protégés par expression",
+			rule:            config.RetentionRule{Pattern: "ci-*", KeepLast: 3, KeepTags: []string{`^v[0-9.]+$`}},
+			expectedDeleted: []string{"ci-app:c4", "ci-app:c5"},
+		},
+		{
+			name:            "Tags téléchargés récemment conservés",
+			rule:            config.RetentionRule{Pattern: "ci-*", KeepLast: 1, KeepPulledWithinDays: 7},
+			expectedDeleted: []string{"ci-app:c2", "ci-app:c3", "ci-app:c5", "ci-app:v1.0.0"},
+		},
+		{
+			name:            "Critères combinés",
+			rule:            config.RetentionRule{Pattern: "ci-*", KeepLast: 2, OlderThanDays: 25, KeepTags: []string{`^v`}, KeepPulledWithinDays: 7},
+			expectedDeleted: []string{"ci-app:c5"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := setupRetention(t, tt.rule)
+			env.pushAgedImage(t, "ci-app", "c1", 1)
+			env.pushAgedImage(t, "ci-app", "c2", 10)
+			env.pushAgedImage(t, "ci-app", "c3", 20)
+			env.pushAgedImage(t, "ci-app", "c4", 30)
+			env.pushAgedImage(t, "ci-app", "c5", 40)
+			env.pushAgedImage(t, "ci-app", "v1.0.0", 90)
+			env.pushAgedImage(t, "stable", "old", 365) // dépôt sans règle
+			env.stats.RecordPull(models.ArtifactTypeDockerImage, "ci-app", "c4", "kubelet")
+
+			run, err := env.retention.Run("alice", false)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedDeleted, deletedReferences(run))
+			assert.Equal(t, 6, run.Examined)
+			assert.Equal(t, 6-len(tt.expectedDeleted), run.Kept)
+
+			for _, reference := range tt.expectedDeleted {
+				assert.False(t, env.imageService.ImageExists("ci-app", strings.TrimPrefix(reference, "ci-app:")))
+			}
+			assert.True(t, env.imageService.ImageExists("ci-app", "c1"))
+			assert.True(t, env.imageService.ImageExists("stable", "old"))
+		})
+	}
+}
+
+func TestRetention_PulledByDigest(t *testing.T) {
+	env := setupRetention(t, config.RetentionRule{Pattern: "ci-*", KeepLast: 1, KeepPulledWithinDays: 7})
+	env.pushAgedImage(t, "ci-app", "c1", 1)
+	env.pushAgedImage(t, "ci-app", "c2", 10)
+	env.pushAgedImage(t, "ci-app", "c3", 20)
+
+	// Kubernetes tire les images épinglées par digest : le tag qui y mène est conservé
+	metadata, err := env.imageService.GetImageMetadata("ci-app", "c2")
+	require.NoError(t, err)
+	env.stats.RecordPull(models.ArtifactTypeDockerImage, "ci-app", metadata.Digest, "kubelet")
+
+	run, err := env.retention.Run("alice", true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ci-app:c3"}, deletedReferences(run))
+}
+
+func TestRetention_DryRunAndAudit(t *testing.T) {
+	env := setupRetention(t, config.RetentionRule{Pattern: "*", KeepLast: 1})
+	env.pushAgedImage(t, "ci-app", "c1", 1)
+	env.pushAgedImage(t, "ci-app", "c2", 2)
+	env.pushAgedChart(t, "my-chart", "1.0.0", 10)
+	env.pushAgedChart(t, "my-chart", "1.1.0", 5)
+
+	// L'aperçu ne supprime rien et n'écrit pas dans le journal
+	preview, err := env.retention.Run("alice", true)
+	require.NoError(t, err)
+	assert.True(t, preview.DryRun)
+	assert.Equal(t, []string{"ci-app:c2", "my-chart:1.0.0"}, deletedReferences(preview))
+	assert.True(t, env.imageService.ImageExists("ci-app", "c2"))
+	assert.True(t, env.chartService.ChartExists("my-chart", "1.0.0"))
+	entries, err := env.retention.Audit("", 0)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	run, err := env.retention.Run("alice", false)
+	require.NoError(t, err)
+	assert.Equal(t, deletedReferences(preview), deletedReferences(run))
+	assert.False(t, env.imageService.ImageExists("ci-app", "c2"))
+	assert.False(t, env.chartService.ChartExists("my-chart", "1.0.0"))
+	assert.True(t, env.chartService.ChartExists("my-chart", "1.1.0"))
+
+	entries, err = env.retention.Audit("", 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	for _, entry := range entries {
+		assert.Equal(t, run.ID, entry.RunID)
+		assert.Equal(t, "alice", entry.Actor)
+		assert.Equal(t, "*", entry.Rule)
+		assert.Contains(t, entry.Reason, "not among the 1 most recent")
+	}
+	entries, err = env.retention.Audit("my-*", 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, models.ArtifactTypeHelmChart, entries[0].Type)
+
+	// Les passes sont conservées, la plus récente d'abord
+	runs := env.retention.Runs()
+	require.Len(t, runs, 2)
+	assert.Equal(t, run.ID, runs[0].ID)
+	assert.True(t, runs[1].DryRun)
+}
+
+func TestRetention_KeepsReferrerTags(t *testing.T) {
+	env := setupRetention(t, config.RetentionRule{Pattern: "*", OlderThanDays: 1})
+	signatureTag := "sha256-" + strings.Repeat("a", 64) + ".sig"
+	env.pushAgedImage(t, "my-app", "1.0", 30)
+	env.pushAgedImage(t, "my-app", signatureTag, 30)
+
+	run, err := env.retention.Run("alice", false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"my-app:1.0"}, deletedReferences(run))
+	assert.True(t, env.imageService.ImageExists("my-app", signatureTag))
+}
+
+func TestRetention_Repositories(t *testing.T) {
+	cfg := newRepositoriesConfig(t)
+	cfg.Policies.Retention.Rules = []config.RetentionRule{
+		{Pattern: "team-a/*", KeepLast: 1},
+		{Pattern: "my-app", KeepLast: 1},
+	}
+	log := newTestLogger()
+	registry, err := service.NewRepositoryRegistry(cfg, log)
+	require.NoError(t, err)
+	teamA, err := registry.Get("team-a")
+	require.NoError(t, err)
+	teamB, err := registry.Get("team-b")
+	require.NoError(t, err)
+
+	tmpChartService := service.NewChartService(cfg, log, nil)
+	chartService := service.NewChartService(cfg, log, service.NewIndexService(cfg, log, tmpChartService))
+	imageService := service.NewImageService(cfg, log)
+	retention, err := service.NewRetentionService(cfg, chartService.GetPathManager(), chartService, imageService, nil, log)
+	require.NoError(t, err)
+	retention.WithRepositories(registry)
+
+	for _, images := range []*service.ImageService{imageService, teamA.Images, teamB.Images} {
+		pushAgedImageTo(t, images, "my-app", "c1", 1)
+		pushAgedImageTo(t, images, "my-app", "c2", 2)
+	}
+	for _, version := range []string{"1.0.0", "1.1.0"} {
+		require.NoError(t, teamA.Charts.SaveChart(buildChartArchive(t, validChartFiles("my-chart", version)), "my-chart-"+version+".tgz"))
+	}
+	old := time.Now().Add(-10 * 24 * time.Hour)
+	require.NoError(t, os.Chtimes(teamA.PathManager.GetChartPath("my-chart", "1.0.0"), old, old))
+
+	// Les règles portent sur le nom complet <dépôt>/<nom> : "my-app" ne vise que le dépôt par défaut
+	run, err := retention.Run("alice", false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"my-app:c2", "team-a/my-app:c2", "team-a/my-chart:1.0.0"}, deletedReferences(run))
+	assert.Equal(t, 6, run.Examined)
+
+	assert.False(t, teamA.Images.ImageExists("my-app", "c2"))
+	assert.True(t, teamA.Images.ImageExists("my-app", "c1"))
+	assert.False(t, teamA.Charts.ChartExists("my-chart", "1.0.0"))
+	assert.True(t, teamA.Charts.ChartExists("my-chart", "1.1.0"))
+	assert.False(t, imageService.ImageExists("my-app", "c2"))
+	assert.True(t, teamB.Images.ImageExists("my-app", "c2"))
+
+	entries, err := retention.Audit("team-a/*", 0)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestRetentionHandler(t *testing.T) {
+	env := setupRetention(t, config.RetentionRule{Pattern: "ci-*", KeepLast: 1})
+	env.pushAgedImage(t, "ci-app", "c1", 1)
+	env.pushAgedImage(t, "ci-app", "c2", 2)
+
+	app := fiber.New()
+	retentionHandler := handlers.NewRetentionHandler(env.retention, newTestLogger())
+	app.Get("/api/retention", retentionHandler.GetRetention)
+	app.Post("/api/retention/run", retentionHandler.RunRetention)
+	app.Get("/api/retention/audit", retentionHandler.GetAudit)
+
+	status, body, _ := doRequest(t, app, "POST", "/api/retention/run?dryRun=true", "", "", nil, "")
+	require.Equal(t, 200, status)
+	var run models.RetentionRun
+	require.NoError(t, json.Unmarshal(body, &run))
+	assert.True(t, run.DryRun)
+	assert.Equal(t, "anonymous", run.Actor)
+	assert.Equal(t, []string{"ci-app:c2"}, deletedReferences(&run))
+	assert.True(t, env.imageService.ImageExists("ci-app", "c2"))
+
+	status, _, _ = doRequest(t, app, "POST", "/api/retention/run", "", "", nil, "")
+	require.Equal(t, 200, status)
+	assert.False(t, env.imageService.ImageExists("ci-app", "c2"))
+
+	status, body, _ = doRequest(t, app, "GET", "/api/retention", "", "", nil, "")
+	require.Equal(t, 200, status)
+	var retention struct {
+		Rules    []config.RetentionRule `json:"rules"`
+		Runs     []models.RetentionRun  `json:"runs"`
+		Schedule string                 `json:"schedule"`
+	}
+	require.NoError(t, json.Unmarshal(body, &retention))
+	assert.Len(t, retention.Rules, 1)
+	assert.Len(t, retention.Runs, 2)
+	assert.Empty(t, retention.Schedule)
+
+	req := httptest.NewRequest("GET", "/api/retention/audit?name=ci-*&limit=10", nil)
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+	var audit struct {
+		Entries []models.RetentionAuditEntry `json:"entries"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&audit))
+	require.Len(t, audit.Entries, 1)
+	assert.Equal(t, "c2", audit.Entries[0].Reference)
+
+	status, _, _ = doRequest(t, app, "GET", "/api/retention/audit?limit=-1", "", "", nil, "")
+	assert.Equal(t, 400, status)
+}
+
+func TestRetentionService_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		retention config.RetentionConfig
+	}{
+		{"Pattern vide", config.RetentionConfig{Rules: []config.RetentionRule{{KeepLast: 5}}}},
+		{"Pattern invalide", config.RetentionConfig{Rules: []config.RetentionRule{{Pattern: "[", KeepLast: 5}}}},
+		{"Expression invalide", config.RetentionConfig{Rules: []config.RetentionRule{{Pattern: "*", KeepLast: 5, KeepTags: []string{"("}}}}},
+		{"Règle sans critère de suppression", config.RetentionConfig{Rules: []config.RetentionRule{{Pattern: "*", KeepPulledWithinDays: 7}}}},
+		{"Valeur négative", config.RetentionConfig{Rules: []config.RetentionRule{{Pattern: "*", KeepLast: -1, OlderThanDays: 3}}}},
+		{"Planification invalide", config.RetentionConfig{Schedule: "daily"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chartService, cfg := newTestChartService(t, func(cfg *config.Config) {
+				cfg.Policies.Retention = tt.retention
+			})
+			_, err := service.NewRetentionService(cfg, chartService.GetPathManager(), chartService, nil, nil, newTestLogger())
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package tests
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"helm-portal/config"
+	"helm-portal/pkg/handlers"
+	"helm-portal/pkg/models"
+	service "helm-portal/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// spdxTestDocument reprend les champs d'un document SPDX vérifiés par les tests
+type spdxTestDocument struct {
+	SPDXVersion string `json:"spdxVersion"`
+	Packages    []struct {
+		Name         string `json:"name"`
+		SPDXID       string `json:"SPDXID"`
+		VersionInfo  string `json:"versionInfo"`
+		ExternalRefs []struct {
+			ReferenceLocator string `json:"referenceLocator"`
+		} `json:"externalRefs"`
+		Checksums []struct {
+			ChecksumValue string `json:"checksumValue"`
+		} `json:"checksums"`
+	} `json:"packages"`
+	Relationships []struct {
+		RelationshipType string `json:"relationshipType"`
+	} `json:"relationships"`
+}
+
+func newTestSBOMService(t *testing.T) (*service.SBOMService, *service.ImageService, *service.ChartService) {
+	t.Helper()
+
+	chartService, cfg := newTestChartService(t)
+	imageService := service.NewImageService(cfg, newTestLogger())
+	sbomService := service.NewSBOMService(cfg, imageService.GetPathManager(), chartService, imageService, newTestLogger())
+	return sbomService, imageService, chartService
+}
+
+// spdxPURLs retourne les purl d'un document SPDX, par nom de paquet
+func spdxPURLs(t *testing.T, document []byte) map[string]string {
+	t.Helper()
+
+	var spdx spdxTestDocument
+	require.NoError(t, json.Unmarshal(document, &spdx))
+	purls := make(map[string]string)
+	for _, pkg := range spdx.Packages {
+		if len(pkg.ExternalRefs) > 0 {
+			purls[pkg.Name] = pkg.ExternalRefs[0].ReferenceLocator
+		}
+	}
+	return purls
+}
+
+func TestSBOMService_ImageSBOM(t *testing.T) {
+	sbomService, imageService, _ := newTestSBOMService(t)
+	digest := pushScannableImage(t, imageService, "debian-app", "1.0", debianAppFiles())
+
+	t.Run("SPDX", func(t *testing.T) {
+		info, document, err := sbomService.ImageSBOM("debian-app", "1.0", models.SBOMFormatSPDX, false)
+		require.NoError(t, err)
+		assert.Equal(t, digest, info.Subject)
+		assert.Equal(t, models.MediaTypeSPDX, info.MediaType)
+
+		var spdx spdxTestDocument
+		require.NoError(t, json.Unmarshal(document, &spdx))
+		assert.Equal(t, "SPDX-2.3", spdx.SPDXVersion)
+		assert.Len(t, spdx.Packages, 6) // l'image, Debian, libssl3, openssl, lodash, express
+		assert.Equal(t, "DESCRIBES", spdx.Relationships[0].RelationshipType)
+
+		purls := spdxPURLs(t, document)
+		assert.Equal(t, "pkg:deb/debian/libssl3@3.0.11-1~deb12u2?distro=debian-12", purls["libssl3"])
+		assert.Equal(t, "pkg:npm/lodash@4.17.20", purls["lodash"])
+		assert.Equal(t, "pkg:oci/debian-app@sha256%3A"+strings.TrimPrefix(digest, "sha256:"), purls["debian-app"])
+	})
+
+	t.Run("CycloneDX", func(t *testing.T) {
+		_, document, err := sbomService.ImageSBOM("debian-app", digest, models.SBOMFormatCycloneDX, false)
+		require.NoError(t, err)
+
+		var cdx struct {
+			BOMFormat   string `json:"bomFormat"`
+			SpecVersion string `json:"specVersion"`
+			Components  []struct {
+				Name string `json:"name"`
+				PURL string `json:"purl"`
+			} `json:"components"`
+			Dependencies []struct {
+				DependsOn []string `json:"dependsOn"`
+			} `json:"dependencies"`
+		}
+		require.NoError(t, json.Unmarshal(document, &cdx))
+		assert.Equal(t, "CycloneDX", cdx.BOMFormat)
+		assert.Equal(t, "1.5", cdx.SpecVersion)
+		require.Len(t, cdx.Components, 5)
+		assert.Contains(t, cdx.Dependencies[0].DependsOn, "pkg:npm/express@4.19.2")
+	})
+
+	t.Run("Referrers", func(t *testing.T) {
+		referrers, err := service.ListReferrers(imageService.GetPathManager(), "debian-app", digest, "")
+		require.NoError(t, err)
+		require.Len(t, referrers, 2)
+
+		spdx, err := service.ListReferrers(imageService.GetPathManager(), "debian-app", digest, models.MediaTypeSPDX)
+		require.NoError(t, err)
+		require.Len(t, spdx, 1)
+		assert.Equal(t, models.MediaTypeOCIManifest, spdx[0].MediaType)
+		assert.NotEmpty(t, spdx[0].Annotations[models.AnnotationCreated])
+
+		// Le SBOM existant est réutilisé, puis remplacé quand il est généré à nouveau
+		info, _, err := sbomService.ImageSBOM("debian-app", "1.0", models.SBOMFormatSPDX, false)
+		require.NoError(t, err)
+		assert.Equal(t, spdx[0].Digest, info.Digest)
+		info, _, err = sbomService.ImageSBOM("debian-app", "1.0", models.SBOMFormatSPDX, true)
+		require.NoError(t, err)
+		assert.NotEqual(t, spdx[0].Digest, info.Digest)
+
+		spdx, err = service.ListReferrers(imageService.GetPathManager(), "debian-app", digest, models.MediaTypeSPDX)
+		require.NoError(t, err)
+		require.Len(t, spdx, 1)
+		assert.Equal(t, info.Digest, spdx[0].Digest)
+	})
+
+	t.Run("Erreurs", func(t *testing.T) {
+		_, _, err := sbomService.ImageSBOM("debian-app", "1.0", "swid", false)
+		assert.ErrorIs(t, err, service.ErrSBOMFormat)
+		_, _, err = sbomService.ImageSBOM("debian-app", "9.9", models.SBOMFormatSPDX, false)
+		assert.ErrorIs(t, err, service.ErrImageNotFound)
+
+		index, err := json.Marshal(models.OCIIndex{
+			SchemaVersion: 2,
+			MediaType:     models.MediaTypeOCIManifestList,
+			Manifests:     []models.OCIDescriptor{pushPlatformImage(t, imageService, "debian-app", "arm64", 1000)},
+		})
+		require.NoError(t, err)
+		require.NoError(t, imageService.SaveImage("debian-app", "multi", index, ""))
+		_, _, err = sbomService.ImageSBOM("debian-app", "multi", models.SBOMFormatSPDX, false)
+		assert.ErrorIs(t, err, service.ErrSBOMIndex)
+	})
+}
+
+// pushChartManifest écrit le manifest OCI d'une version de chart stockée, comme après un helm push, et retourne son digest
+func pushChartManifest(t *testing.T, imageService *service.ImageService, chartService *service.ChartService, name, version string) string {
+	t.Helper()
+
+	archive, err := chartService.GetChart(name, version)
+	require.NoError(t, err)
+	manifest, err := json.Marshal(models.OCIManifest{
+		SchemaVersion: 2,
+		MediaType:     models.MediaTypeOCIManifest,
+		Config:        storeBlob(t, imageService, models.MediaTypeHelmConfig, []byte(fmt.Sprintf(`{"name":%q,"version":%q}`, name, version))),
+		Layers:        []models.OCIDescriptor{storeBlob(t, imageService, models.MediaTypeHelmChart, archive)},
+	})
+	require.NoError(t, err)
+	manifestPath := chartService.GetPathManager().GetManifestPath(name, version)
+	require.NoError(t, os.MkdirAll(filepath.Dir(manifestPath), 0755))
+	require.NoError(t, os.WriteFile(manifestPath, manifest, 0644))
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(manifest))
+}
+
+func TestSBOMService_ChartSBOM(t *testing.T) {
+	sbomService, imageService, chartService := newTestSBOMService(t)
+	digest := pushScannableImage(t, imageService, "debian-app", "1.0", debianAppFiles())
+
+	files := chartWithDependencies("my-chart", "1.0.0", "  - name: redis\n    version: ~17.0.0\n    repository: oci://registry-1.docker.io/bitnamicharts\n")
+	files["my-chart/templates/deployment.yaml"] = "kind: Deployment\nspec:\n  template:\n    spec:\n      containers:\n" +
+		"        - name: app\n          image: \"registry.example.com/debian-app:1.0\"\n" +
+		"        - name: proxy\n          image: nginx:1.25\n"
+	require.NoError(t, chartService.SaveChart(buildChartArchive(t, files), "my-chart-1.0.0.tgz"))
+
+	// Un chart envoyé par l'API HTTP n'a pas de manifest OCI : la lecture du SBOM n'en écrit pas
+	manifestPath := chartService.GetPathManager().GetManifestPath("my-chart", "1.0.0")
+	_, _, err := sbomService.ChartSBOM("my-chart", "1.0.0", models.SBOMFormatSPDX, false)
+	assert.ErrorIs(t, err, service.ErrSBOMNoManifest)
+	assert.NoFileExists(t, manifestPath)
+
+	// Le SBOM est lié au manifest existant, qui n'est pas réécrit
+	chartDigest := pushChartManifest(t, imageService, chartService, "my-chart", "1.0.0")
+	before, err := os.ReadFile(manifestPath)
+	require.NoError(t, err)
+	info, document, err := sbomService.ChartSBOM("my-chart", "1.0.0", models.SBOMFormatSPDX, false)
+	require.NoError(t, err)
+	assert.Equal(t, chartDigest, info.Subject)
+	after, err := os.ReadFile(manifestPath)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+
+	referrers, err := service.ListReferrers(imageService.GetPathManager(), "my-chart", info.Subject, "")
+	require.NoError(t, err)
+	require.Len(t, referrers, 1)
+	assert.Equal(t, models.MediaTypeSPDX, referrers[0].ArtifactType)
+
+	var spdx spdxTestDocument
+	require.NoError(t, json.Unmarshal(document, &spdx))
+	packages := make(map[string]int)
+	for i, pkg := range spdx.Packages {
+		packages[pkg.Name] = i
+	}
+	require.Contains(t, packages, "redis")
+	assert.Equal(t, "~17.0.0", spdx.Packages[packages["redis"]].VersionInfo)
+	assert.Equal(t, "DEPENDS_ON", spdx.Relationships[1].RelationshipType)
+
+	purls := spdxPURLs(t, document)
+	assert.Equal(t, "pkg:docker/nginx@1.25", purls["nginx"])
+	require.Contains(t, packages, "registry.example.com/debian-app")
+	app := spdx.Packages[packages["registry.example.com/debian-app"]]
+	require.Len(t, app.Checksums, 1)
+	assert.Equal(t, strings.TrimPrefix(digest, "sha256:"), app.Checksums[0].ChecksumValue)
+
+	_, _, err = sbomService.ChartSBOM("my-chart", "9.9.9", models.SBOMFormatSPDX, false)
+	assert.ErrorIs(t, err, service.ErrChartNotFound)
+
+	// Une version réécrite par l'API HTTP : le manifest décrit l'ancienne archive
+	files["my-chart/values.yaml"] = "replicaCount: 2\n"
+	require.NoError(t, chartService.SaveChart(buildChartArchive(t, files), "my-chart-1.0.0.tgz"))
+	_, _, err = sbomService.ChartSBOM("my-chart", "1.0.0", models.SBOMFormatSPDX, true)
+	assert.ErrorIs(t, err, service.ErrSBOMNoManifest)
+	after, err = os.ReadFile(manifestPath)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+}
+
+func TestSBOMHandler(t *testing.T) {
+	sbomService, imageService, chartService := newTestSBOMService(t)
+	digest := pushScannableImage(t, imageService, "debian-app", "1.0", debianAppFiles())
+	require.NoError(t, chartService.SaveChart(buildChartArchive(t, validChartFiles("my-chart", "1.0.0")), "my-chart-1.0.0.tgz"))
+	pushChartManifest(t, imageService, chartService, "my-chart", "1.0.0")
+	require.NoError(t, chartService.SaveChart(buildChartArchive(t, validChartFiles("uploaded-chart", "1.0.0")), "uploaded-chart-1.0.0.tgz"))
+
+	app := fiber.New()
+	sbomHandler := handlers.NewSBOMHandler(sbomService, newTestLogger())
+	ociHandler := handlers.NewOCIHandler(chartService, imageService, &config.Config{}, newTestLogger())
+	app.Get("/image/*/sbom", sbomHandler.GetImageSBOM)
+	app.Post("/image/*/sbom", sbomHandler.GenerateImageSBOM)
+	app.Get("/chart/:name/:version/sbom", sbomHandler.GetChartSBOM)
+	app.Get("/v2/:name/referrers/:digest", ociHandler.GetReferrers)
+
+	status, _, headers := doRequest(t, app, "GET", "/image/debian-app/1.0/sbom?format=cyclonedx", "", "", nil, "")
+	require.Equal(t, 200, status)
+	assert.Equal(t, models.MediaTypeCycloneDX, headers.Get("Content-Type"))
+	assert.Contains(t, headers.Get("Content-Disposition"), `filename="debian-app-1.0.cyclonedx.json"`)
+
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"Format par défaut", "GET", "/image/debian-app/1.0/sbom", 200, `"spdxVersion": "SPDX-2.3"`},
+		{"Nouvelle génération", "POST", "/image/debian-app/1.0/sbom", 200, `"subject":"` + digest + `"`},
+		{"Chart", "GET", "/chart/my-chart/1.0.0/sbom", 200, `"name": "my-chart:1.0.0"`},
+		{"Format inconnu", "GET", "/image/debian-app/1.0/sbom?format=swid", 400, "unsupported SBOM format"},
+		{"Image absente", "GET", "/image/debian-app/9.9/sbom", 404, "Image not found"},
+		{"Chart absent", "GET", "/chart/my-chart/9.9.9/sbom", 404, "Chart not found"},
+		{"Chart sans manifest OCI", "GET", "/chart/uploaded-chart/1.0.0/sbom", 409, "no OCI manifest"},
+		{"Referrers", "GET", "/v2/debian-app/referrers/" + digest, 200, `"artifactType":"application/vnd.cyclonedx+json"`},
+		{"Referrers filtrés", "GET", "/v2/debian-app/referrers/" + digest + "?artifactType=application/spdx%2Bjson", 200, `"artifactType":"application/spdx+json"`},
+		{"Digest invalide", "GET", "/v2/debian-app/referrers/latest", 400, "DIGEST_INVALID"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body, _ := doRequest(t, app, tt.method, tt.url, "", "", nil, "")
+			assert.Equal(t, tt.expectedStatus, status, string(body))
+			assert.Contains(t, string(body), tt.expectedBody)
+		})
+	}
+
+	_, body, headers := doRequest(t, app, "GET", "/v2/debian-app/referrers/"+digest+"?artifactType=application/spdx%2Bjson", "", "", nil, "")
+	assert.Equal(t, "artifactType", headers.Get("OCI-Filters-Applied"))
+	assert.NotContains(t, string(body), models.MediaTypeCycloneDX)
+}
@@ -0,0 +1,185 @@
+package tests
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"helm-portal/pkg/models"
+	service "helm-portal/pkg/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pushTestImage enregistre le blob de config d'une image puis son manifest
+func pushTestImage(t *testing.T, imageService *service.ImageService, name, tag string, labels, annotations map[string]string) {
+	t.Helper()
+
+	config, err := json.Marshal(models.ImageConfig{
+		Architecture: "amd64",
+		OS:           "linux",
+		Config:       &models.ContainerConfig{Labels: labels},
+	})
+	require.NoError(t, err)
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(config))
+	blobPath := imageService.GetPathManager().GetBlobPath(digest)
+	require.NoError(t, os.MkdirAll(filepath.Dir(blobPath), 0755))
+	require.NoError(t, os.WriteFile(blobPath, config, 0644))
+
+	manifest := &models.OCIManifest{
+		SchemaVersion: 2,
+		MediaType:     models.MediaTypeOCIManifest,
+		Config:        models.OCIDescriptor{MediaType: models.MediaTypeOCIConfig, Digest: digest, Size: int64(len(config))},
+		Annotations:   annotations,
+	}
+	data, err := json.Marshal(manifest)
+	require.NoError(t, err)
+	require.NoError(t, imageService.SaveImage(name, tag, data, ""))
+}
+
+// chartWithMetadata retourne un chart valide avec des champs Chart.yaml supplémentaires
+func chartWithMetadata(name, version, extra string) map[string]string {
+	files := validChartFiles(name, version)
+	files[name+"/Chart.yaml"] += extra
+	return files
+}
+
+// searchNames retourne "type:nom" pour chaque résultat, dans l'ordre
+func searchNames(t *testing.T, searchService *service.SearchService, query string, artifactType models.ArtifactType) []string {
+	t.Helper()
+
+	result, err := searchService.Search(query, artifactType, 0)
+	require.NoError(t, err)
+	names := make([]string, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		names = append(names, string(hit.Type)+":"+hit.Name)
+	}
+	return names
+}
+
+func newTestSearchService(t *testing.T) (*service.SearchService, *service.ChartService, *service.ImageService) {
+	t.Helper()
+
+	chartService, cfg := newTestChartService(t)
+	imageService := service.NewImageService(cfg, newTestLogger())
+
+	charts := []struct{ name, version, extra string }{
+		{"postgresql", "12.1.0", "appVersion: \"15.2\"\nkeywords:\n  - database\n  - sql\nmaintainers:\n  - name: Alice Martin\n    email: alice@example.com\n"},
+		{"postgresql", "12.2.0", "appVersion: \"15.3\"\nkeywords:\n  - database\n  - sql\n"},
+		{"redis", "17.0.0", "appVersion: \"7.0\"\nkeywords:\n  - cache\n  - database\n"},
+		{"nginx", "1.0.0", "keywords:\n  - webserver\n"},
+	}
+	for _, c := range charts {
+		archive := buildChartArchive(t, chartWithMetadata(c.name, c.version, c.extra))
+		require.NoError(t, chartService.SaveChart(archive, c.name+"-"+c.version+".tgz"))
+	}
+
+	pushTestImage(t, imageService, "backend-api", "v1.4.0",
+		map[string]string{"org.opencontainers.image.source": "https://github.com/acme/backend"},
+		nil)
+	pushTestImage(t, imageService, "frontend", "2024.01",
+		nil,
+		map[string]string{"org.opencontainers.image.description": "Acme storefront"})
+
+	searchService := service.NewSearchService(chartService, imageService, newTestLogger())
+	require.NoError(t, searchService.Rebuild())
+	chartService.Subscribe(searchService)
+	imageService.Subscribe(searchService)
+	return searchService, chartService, imageService
+}
+
+func TestSearch_Matching(t *testing.T) {
+	searchService, _, _ := newTestSearchService(t)
+
+	tests := []struct {
+		name         string
+		query        string
+		artifactType models.ArtifactType
+		expected     []string
+	}{
+		{"Nom exact", "redis", "", []string{"helm:redis"}},
+		{"Préfixe du nom", "postg", "", []string{"helm:postgresql"}},
+		{"Faute de frappe", "postgrsql", "", []string{"helm:postgresql"}},
+		{"Mot-clé partagé, le nom est prioritaire", "database", "", []string{"helm:postgresql", "helm:redis"}},
+		{"Mainteneur", "alice", "", []string{"helm:postgresql"}},
+		{"Email du mainteneur", "alice@example.com", "", []string{"helm:postgresql"}},
+		{"AppVersion", "15.3", "", []string{"helm:postgresql"}},
+		{"Tous les termes doivent correspondre", "database cache", "", []string{"helm:redis"}},
+		{"Clé de label OCI", "org.opencontainers.image.source", "", []string{"docker:backend-api"}},
+		{"Valeur de label OCI", "acme/backend", "", []string{"docker:backend-api"}},
+		{"Annotation du manifest", "storefront", "", []string{"docker:frontend"}},
+		{"Tag d'image", "v1.4.0", "", []string{"docker:backend-api"}},
+		{"Filtre image", "acme", models.ArtifactTypeDockerImage, []string{"docker:backend-api", "docker:frontend"}},
+		{"Filtre chart", "acme", models.ArtifactTypeHelmChart, []string{}},
+		{"Terme court sans fuzzy", "rds", "", []string{}},
+		{"Faute de frappe sur un terme moyen", "rdis", "", []string{"helm:redis"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, searchNames(t, searchService, tt.query, tt.artifactType))
+		})
+	}
+}
+
+func TestSearch_GroupsVersions(t *testing.T) {
+	searchService, _, _ := newTestSearchService(t)
+
+	result, err := searchService.Search("sql", "", 0)
+	require.NoError(t, err)
+	require.Len(t, result.Hits, 1)
+	assert.Equal(t, []string{"12.2.0", "12.1.0"}, result.Hits[0].Versions)
+	assert.Equal(t, []string{"keywords"}, result.Hits[0].Fields)
+	assert.Equal(t, "test chart", result.Hits[0].Description)
+
+	// Limite du nombre de résultats
+	result, err = searchService.Search("database", "", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Total)
+	assert.Len(t, result.Hits, 1)
+
+	_, err = searchService.Search("   ", "", 0)
+	assert.True(t, errors.Is(err, service.ErrEmptyQuery))
+	_, err = service.ParseArtifactType("helmfile")
+	assert.True(t, errors.Is(err, service.ErrInvalidSearchType))
+}
+
+func TestSearch_StaysInSync(t *testing.T) {
+	searchService, chartService, imageService := newTestSearchService(t)
+
+	// Push d'un nouveau chart et d'une nouvelle image
+	archive := buildChartArchive(t, chartWithMetadata("mongodb", "1.0.0", "keywords:\n  - database\n"))
+	require.NoError(t, chartService.SaveChart(archive, "mongodb-1.0.0.tgz"))
+	pushTestImage(t, imageService, "worker", "latest", map[string]string{"team": "payments"}, nil)
+
+	assert.Contains(t, searchNames(t, searchService, "mongo", ""), "helm:mongodb")
+	assert.Equal(t, []string{"docker:worker"}, searchNames(t, searchService, "payments", ""))
+
+	// Yank : la version n'est plus trouvable, la réintégration la rend visible
+	_, err := chartService.YankChart("mongodb", "1.0.0", "broken", "alice")
+	require.NoError(t, err)
+	assert.NotContains(t, searchNames(t, searchService, "mongo", ""), "helm:mongodb")
+	_, err = chartService.ReinstateChart("mongodb", "1.0.0", "fixed", "alice")
+	require.NoError(t, err)
+	assert.Contains(t, searchNames(t, searchService, "mongo", ""), "helm:mongodb")
+
+	// Suppressions
+	require.NoError(t, chartService.DeleteChart("postgresql", "12.2.0"))
+	result, err := searchService.Search("postgresql", "", 0)
+	require.NoError(t, err)
+	require.Len(t, result.Hits, 1)
+	assert.Equal(t, []string{"12.1.0"}, result.Hits[0].Versions)
+
+	require.NoError(t, imageService.DeleteImage("worker", "latest"))
+	assert.Empty(t, searchNames(t, searchService, "payments", ""))
+
+	// Un index reconstruit donne les mêmes résultats
+	require.NoError(t, searchService.Rebuild())
+	assert.Contains(t, searchNames(t, searchService, "mongo", ""), "helm:mongodb")
+	assert.Empty(t, searchNames(t, searchService, "payments", ""))
+}
@@ -0,0 +1,411 @@
+package tests
+
+import (
+	"archive/tar"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"helm-portal/config"
+	"helm-portal/pkg/handlers"
+	"helm-portal/pkg/models"
+	service "helm-portal/pkg/services"
+	"helm-portal/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/template/html/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCosignKey génère une paire de clés ECDSA et écrit la clé publique au format PEM, comme cosign generate-key-pair
+func writeCosignKey(t *testing.T, dir, name string) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	keyPath := filepath.Join(dir, name+".pub")
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644))
+	return key, keyPath
+}
+
+// pushCosignSignature pousse la signature cosign d'un manifest sous le tag sha256-<hex>.sig
+func pushCosignSignature(t *testing.T, imageService *service.ImageService, name, digest, signedDigest string, key *ecdsa.PrivateKey) {
+	t.Helper()
+
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"localhost/%s"},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":null}`, name, signedDigest))
+	hash := sha256.Sum256(payload)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	require.NoError(t, err)
+
+	layer := storeBlob(t, imageService, models.MediaTypeCosignSimpleSigning, payload)
+	layer.Annotations = map[string]string{models.AnnotationCosignSignature: base64.StdEncoding.EncodeToString(signature)}
+	manifest, err := json.Marshal(models.OCIManifest{
+		SchemaVersion: 2,
+		MediaType:     models.MediaTypeOCIManifest,
+		Config:        storeBlob(t, imageService, models.MediaTypeOCIConfig, []byte(`{"architecture":"","os":""}`)),
+		Layers:        []models.OCIDescriptor{layer},
+	})
+	require.NoError(t, err)
+	require.NoError(t, imageService.SaveImage(name, strings.Replace(digest, ":", "-", 1)+".sig", manifest, ""))
+}
+
+// notationCA crée une autorité racine et un certificat de signature de code qu'elle a émis, valide jusqu'à leafNotAfter
+func notationCA(t *testing.T, dir string, leafNotAfter time.Time) (rootPath string, leaf *x509.Certificate, leafKey *ecdsa.PrivateKey) {
+	t.Helper()
+
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTemplate, rootTemplate, &rootKey.PublicKey, rootKey)
+	require.NoError(t, err)
+	root, err := x509.ParseCertificate(rootDER)
+	require.NoError(t, err)
+
+	leafKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	leafDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "release", Organization: []string{"Example"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     leafNotAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}, root, &leafKey.PublicKey, rootKey)
+	require.NoError(t, err)
+	leaf, err = x509.ParseCertificate(leafDER)
+	require.NoError(t, err)
+
+	rootPath = filepath.Join(dir, "notation-root.pem")
+	require.NoError(t, os.WriteFile(rootPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rootDER}), 0644))
+	return rootPath, leaf, leafKey
+}
+
+// pushNotationSignature pousse une signature Notation (enveloppe JWS ES256) comme referrer du manifest
+func pushNotationSignature(t *testing.T, imageService *service.ImageService, name string, subject models.OCIDescriptor, leaf *x509.Certificate, key *ecdsa.PrivateKey, signingTime time.Time) {
+	t.Helper()
+
+	protected := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"alg":"ES256","cty":"application/vnd.cncf.notary.payload.v1+json","io.cncf.notary.signingScheme":"notary.x509","io.cncf.notary.signingTime":%q}`, signingTime.UTC().Format(time.RFC3339))))
+	target, err := json.Marshal(map[string]models.OCIDescriptor{"targetArtifact": subject})
+	require.NoError(t, err)
+	payload := base64.RawURLEncoding.EncodeToString(target)
+
+	hash := crypto.SHA256.New()
+	hash.Write([]byte(protected + "." + payload))
+	r, s, err := ecdsa.Sign(rand.Reader, key, hash.Sum(nil))
+	require.NoError(t, err)
+	signature := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+
+	envelope, err := json.Marshal(map[string]interface{}{
+		"payload":   payload,
+		"protected": protected,
+		"header":    map[string]interface{}{"x5c": []string{base64.StdEncoding.EncodeToString(leaf.Raw)}},
+		"signature": base64.RawURLEncoding.EncodeToString(signature),
+	})
+	require.NoError(t, err)
+
+	manifest, err := json.Marshal(models.OCIManifest{
+		SchemaVersion: 2,
+		MediaType:     models.MediaTypeOCIManifest,
+		ArtifactType:  models.MediaTypeNotationSignature,
+		Config:        storeBlob(t, imageService, models.MediaTypeOCIEmpty, []byte("{}")),
+		Layers:        []models.OCIDescriptor{storeBlob(t, imageService, models.MediaTypeJWS, envelope)},
+		Subject:       &subject,
+	})
+	require.NoError(t, err)
+	require.NoError(t, imageService.SaveImage(name, fmt.Sprintf("sha256:%x", sha256.Sum256(manifest)), manifest, ""))
+}
+
+func newTestSignatureService(t *testing.T, signatures config.SignaturesConfig) (*service.SignatureService, *service.ImageService, *service.ChartService, *config.Config) {
+	t.Helper()
+
+	chartService, cfg := newTestChartService(t, func(cfg *config.Config) {
+		cfg.Signatures = signatures
+	})
+	imageService := service.NewImageService(cfg, newTestLogger())
+	signatureService := service.NewSignatureService(cfg, imageService.GetPathManager(), chartService, imageService, newTestLogger())
+	chartService.Subscribe(signatureService)
+	imageService.Subscribe(signatureService)
+	return signatureService, imageService, chartService, cfg
+}
+
+func TestSignatureService_Cosign(t *testing.T) {
+	dir := t.TempDir()
+	releaseKey, releasePath := writeCosignKey(t, dir, "release")
+	otherKey, _ := writeCosignKey(t, dir, "other")
+	signatureService, imageService, _, _ := newTestSignatureService(t, config.SignaturesConfig{
+		Keys: []config.SigningKey{{Name: "release", Path: releasePath}, {Name: "missing", Path: filepath.Join(dir, "missing.pub")}},
+	})
+
+	tests := []struct {
+		name           string
+		sign           func(name, digest string)
+		expectedStatus string
+		expectedError  string
+	}{
+		{"Non signée", func(name, digest string) {}, models.SignatureStatusUnsigned, ""},
+		{"Clé configurée", func(name, digest string) {
+			pushCosignSignature(t, imageService, name, digest, digest, releaseKey)
+		}, models.SignatureStatusVerified, ""},
+		{"Clé inconnue", func(name, digest string) {
+			pushCosignSignature(t, imageService, name, digest, digest, otherKey)
+		}, models.SignatureStatusInvalid, "no configured key verifies the signature"},
+		{"Signature d'un autre manifest", func(name, digest string) {
+			pushCosignSignature(t, imageService, name, digest, "sha256:"+strings.Repeat("0", 64), releaseKey)
+		}, models.SignatureStatusInvalid, "signature is for sha256:000"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := fmt.Sprintf("app-%d", i)
+			digest := pushScannableImage(t, imageService, name, "1.0", debianAppFiles())
+
+			// Le statut mis en cache est oublié quand la signature est poussée
+			status, err := signatureService.ImageStatus(name, "1.0")
+			require.NoError(t, err)
+			assert.Equal(t, models.SignatureStatusUnsigned, status.Status)
+			tt.sign(name, digest)
+
+			status, err = signatureService.ImageStatus(name, "1.0")
+			require.NoError(t, err)
+			assert.Equal(t, digest, status.Digest)
+			assert.Equal(t, tt.expectedStatus, status.Status)
+			if tt.expectedStatus == models.SignatureStatusVerified {
+				assert.Equal(t, []string{"release"}, status.Signers())
+			}
+			if tt.expectedError != "" {
+				require.Len(t, status.Signatures, 1)
+				assert.Contains(t, status.Signatures[0].Error, tt.expectedError)
+			}
+		})
+	}
+
+	_, err := signatureService.ImageStatus("app-0", "9.9")
+	assert.ErrorIs(t, err, service.ErrImageNotFound)
+}
+
+func TestSignatureService_Notation(t *testing.T) {
+	dir := t.TempDir()
+	rootPath, leaf, leafKey := notationCA(t, dir, time.Now().Add(time.Hour))
+	_, otherLeaf, otherKey := notationCA(t, t.TempDir(), time.Now().Add(time.Hour))
+	signatureService, imageService, _, _ := newTestSignatureService(t, config.SignaturesConfig{
+		NotationCertificates: []string{rootPath},
+	})
+
+	digest := pushScannableImage(t, imageService, "signed-app", "1.0", debianAppFiles())
+	manifest, err := os.ReadFile(imageService.GetPathManager().GetImageManifestPath("signed-app", "1.0"))
+	require.NoError(t, err)
+	subject := models.OCIDescriptor{MediaType: models.MediaTypeOCIManifest, Digest: digest, Size: int64(len(manifest))}
+
+	pushNotationSignature(t, imageService, "signed-app", subject, otherLeaf, otherKey, time.Now())
+	status := signatureService.Verify("signed-app", digest)
+	assert.Equal(t, models.SignatureStatusInvalid, status.Status)
+	require.Len(t, status.Signatures, 1)
+	assert.Contains(t, status.Signatures[0].Error, "untrusted certificate")
+
+	pushNotationSignature(t, imageService, "signed-app", subject, leaf, leafKey, time.Now())
+	status = signatureService.Verify("signed-app", digest)
+	assert.Equal(t, models.SignatureStatusVerified, status.Status)
+	require.Len(t, status.Signatures, 2)
+	assert.Equal(t, []string{"CN=release,O=Example"}, status.Signers())
+	assert.Equal(t, models.SignatureTypeNotation, status.Signatures[0].Type)
+}
+
+func TestSignatureService_NotationExpiredCertificate(t *testing.T) {
+	rootPath, leaf, leafKey := notationCA(t, t.TempDir(), time.Now().Add(-30*time.Minute))
+	signatureService, imageService, _, _ := newTestSignatureService(t, config.SignaturesConfig{
+		NotationCertificates: []string{rootPath},
+	})
+
+	digest := pushScannableImage(t, imageService, "signed-app", "1.0", debianAppFiles())
+	manifest, err := os.ReadFile(imageService.GetPathManager().GetImageManifestPath("signed-app", "1.0"))
+	require.NoError(t, err)
+	subject := models.OCIDescriptor{MediaType: models.MediaTypeOCIManifest, Digest: digest, Size: int64(len(manifest))}
+
+	// La date de signature, choisie par le signataire, ne prolonge pas la validité du certificat
+	pushNotationSignature(t, imageService, "signed-app", subject, leaf, leafKey, time.Now().Add(-45*time.Minute))
+	status := signatureService.Verify("signed-app", digest)
+	assert.Equal(t, models.SignatureStatusInvalid, status.Status)
+	require.Len(t, status.Signatures, 1)
+	assert.Contains(t, status.Signatures[0].Error, "untrusted certificate")
+}
+
+func TestSignatureService_Charts(t *testing.T) {
+	signatureService, _, chartService, _ := newTestSignatureService(t, config.SignaturesConfig{})
+	require.NoError(t, chartService.SaveChart(buildChartArchive(t, validChartFiles("my-chart", "1.0.0")), "my-chart-1.0.0.tgz"))
+
+	// Un chart envoyé par l'API HTTP n'a pas de manifest OCI à signer
+	status, err := signatureService.ChartStatus("my-chart", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, models.SignatureStatusUnsigned, status.Status)
+	assert.Empty(t, status.Digest)
+
+	_, err = signatureService.ChartStatus("my-chart", "9.9.9")
+	assert.ErrorIs(t, err, service.ErrChartNotFound)
+}
+
+func TestOCIHandler_SignatureEnforcement(t *testing.T) {
+	dir := t.TempDir()
+	key, keyPath := writeCosignKey(t, dir, "release")
+	signatureService, imageService, chartService, cfg := newTestSignatureService(t, config.SignaturesConfig{
+		Keys:    []config.SigningKey{{Name: "release", Path: keyPath}},
+		Enforce: []string{"prod-*"},
+	})
+
+	app := fiber.New()
+	ociHandler := handlers.NewOCIHandler(chartService, imageService, cfg, newTestLogger()).WithSignatures(signatureService)
+	signatureHandler := handlers.NewSignatureHandler(signatureService, newTestLogger())
+	app.Head("/v2/:name/manifests/:reference", ociHandler.HandleManifest)
+	app.Get("/v2/:name/manifests/:reference", ociHandler.HandleManifest)
+	app.Get("/image/*/signature", signatureHandler.GetImageSignature)
+
+	signed := pushScannableImage(t, imageService, "prod-app", "signed", debianAppFiles())
+	pushCosignSignature(t, imageService, "prod-app", signed, signed, key)
+	unsigned := pushScannableImage(t, imageService, "prod-app", "unsigned", []layerFile{{name: "etc/hostname", typeflag: tar.TypeReg, content: "unsigned"}})
+	pushScannableImage(t, imageService, "dev-app", "unsigned", debianAppFiles())
+
+	// Une image non signée ne devient pas une signature par son tag ou son subject
+	fakeSignatureTag := "sha256-" + strings.Repeat("a", 64) + ".sig"
+	pushScannableImage(t, imageService, "prod-app", fakeSignatureTag, []layerFile{{name: "etc/hostname", typeflag: tar.TypeReg, content: "fake"}})
+	unsignedManifest, err := os.ReadFile(imageService.GetPathManager().GetImageManifestPath("prod-app", "unsigned"))
+	require.NoError(t, err)
+	var withSubject models.OCIManifest
+	require.NoError(t, json.Unmarshal(unsignedManifest, &withSubject))
+	withSubject.Subject = &models.OCIDescriptor{MediaType: models.MediaTypeOCIManifest, Digest: signed, Size: 1}
+	withSubjectData, err := json.Marshal(withSubject)
+	require.NoError(t, err)
+	require.NoError(t, imageService.SaveImage("prod-app", "with-subject", withSubjectData, ""))
+
+	// Index signé : ses plateformes, non signées, sont tirées par digest après lui
+	arm64 := pushPlatformImage(t, imageService, "prod-app", "arm64", 1000)
+	index, err := json.Marshal(models.OCIIndex{
+		SchemaVersion: 2,
+		MediaType:     models.MediaTypeOCIManifestList,
+		Manifests:     []models.OCIDescriptor{arm64},
+	})
+	require.NoError(t, err)
+	require.NoError(t, imageService.SaveImage("prod-app", "multi", index, ""))
+	indexDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(index))
+	pushCosignSignature(t, imageService, "prod-app", indexDigest, indexDigest, key)
+
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"Signée", "GET", "/v2/prod-app/manifests/signed", 200, ""},
+		{"Signée par digest", "GET", "/v2/prod-app/manifests/" + signed, 200, ""},
+		{"Non signée", "GET", "/v2/prod-app/manifests/unsigned", 403, "DENIED"},
+		{"Non signée, HEAD", "HEAD", "/v2/prod-app/manifests/" + unsigned, 403, ""},
+		{"Signature elle-même", "GET", "/v2/prod-app/manifests/" + strings.Replace(signed, ":", "-", 1) + ".sig", 200, ""},
+		{"Tag de signature sur une image", "GET", "/v2/prod-app/manifests/" + fakeSignatureTag, 403, "DENIED"},
+		{"Subject sur une image", "GET", "/v2/prod-app/manifests/with-subject", 403, "DENIED"},
+		{"Plateforme d'un index signé", "GET", "/v2/prod-app/manifests/" + arm64.Digest, 200, ""},
+		{"Dépôt non contrôlé", "GET", "/v2/dev-app/manifests/unsigned", 200, ""},
+		{"Statut", "GET", "/image/prod-app/signed/signature", 200, `"status":"verified"`},
+		{"Statut, image absente", "GET", "/image/prod-app/9.9/signature", 404, "Image not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body, _ := doRequest(t, app, tt.method, tt.url, "", "", nil, "")
+			assert.Equal(t, tt.expectedStatus, status, string(body))
+			assert.Contains(t, string(body), tt.expectedBody)
+		})
+	}
+
+	t.Run("Interface web", func(t *testing.T) {
+		views := html.New("../src/views", ".html")
+		views.AddFuncMap(utils.TemplateFuncs())
+		ui := fiber.New(fiber.Config{Views: views})
+		imageHandler := handlers.NewImageHandler(imageService, imageService.GetPathManager(), newTestLogger()).WithSignatures(signatureService)
+		ui.Get("/images", imageHandler.ListImages)
+		ui.Get("/image/*/details", imageHandler.DisplayImageDetails)
+
+		get := func(url string) string {
+			req := httptest.NewRequest("GET", url, nil)
+			req.Header.Set("Accept", "text/html")
+			resp, err := ui.Test(req, -1)
+			require.NoError(t, err)
+			require.Equal(t, 200, resp.StatusCode, url)
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			return string(body)
+		}
+		assert.Contains(t, get("/image/prod-app/signed/details"), "Signed by release")
+		assert.Contains(t, get("/image/prod-app/unsigned/details"), "Unsigned")
+		images := get("/images")
+		assert.Contains(t, images, "</i> verified")
+		assert.Contains(t, images, "unsigned</span>")
+	})
+}
+
+func TestHelmHandler_SignatureEnforcement(t *testing.T) {
+	dir := t.TempDir()
+	key, keyPath := writeCosignKey(t, dir, "release")
+	signatureService, imageService, chartService, _ := newTestSignatureService(t, config.SignaturesConfig{
+		Keys:    []config.SigningKey{{Name: "release", Path: keyPath}},
+		Enforce: []string{"prod-*"},
+	})
+	pathManager := chartService.GetPathManager()
+
+	app := fiber.New()
+	helmHandler := handlers.NewHelmHandler(chartService, pathManager, newTestLogger()).WithSignatures(signatureService)
+	indexHandler := handlers.NewIndexHandler(chartService, pathManager, newTestLogger()).WithSignatures(signatureService)
+	app.Get("/charts/:file", indexHandler.GetChartArchive)
+	app.Get("/chart/:name/:version", helmHandler.DownloadChart)
+
+	for _, name := range []string{"prod-chart", "prod-signed", "dev-chart"} {
+		require.NoError(t, chartService.SaveChart(buildChartArchive(t, validChartFiles(name, "1.0.0")), name+"-1.0.0.tgz"))
+	}
+	// Manifest OCI du chart signé, comme après un helm push suivi de cosign sign
+	manifest := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json"}`)
+	require.NoError(t, os.MkdirAll(filepath.Dir(pathManager.GetManifestPath("prod-signed", "1.0.0")), 0755))
+	require.NoError(t, os.WriteFile(pathManager.GetManifestPath("prod-signed", "1.0.0"), manifest, 0644))
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(manifest))
+	pushCosignSignature(t, imageService, "prod-signed", digest, digest, key)
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+	}{
+		{"Archive non signée", "/charts/prod-chart-1.0.0.tgz", 403},
+		{"Téléchargement non signé", "/chart/prod-chart/1.0.0", 403},
+		{"Archive signée", "/charts/prod-signed-1.0.0.tgz", 200},
+		{"Téléchargement signé", "/chart/prod-signed/1.0.0", 200},
+		{"Dépôt non contrôlé", "/charts/dev-chart-1.0.0.tgz", 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body, _ := doRequest(t, app, "GET", tt.url, "", "", nil, "")
+			assert.Equal(t, tt.expectedStatus, status, string(body))
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"helm-portal/config"
+	"helm-portal/pkg/handlers"
+	"helm-portal/pkg/models"
+	service "helm-portal/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStatsService crée les services de charts, d'images et de statistiques sur un même stockage
+func newTestStatsService(t *testing.T) (*service.ChartService, *service.ImageService, *service.StatsService, *config.Config) {
+	t.Helper()
+
+	chartService, cfg := newTestChartService(t)
+	imageService := service.NewImageService(cfg, newTestLogger())
+	statsService := service.NewStatsService(chartService.GetPathManager(), chartService, imageService, newTestLogger())
+	chartService.Subscribe(statsService)
+	imageService.Subscribe(statsService)
+	return chartService, imageService, statsService, cfg
+}
+
+// statsByReference indexe un rapport par "type:nom:référence"
+func statsByReference(report *models.StatsReport) map[string]models.PullStats {
+	items := make(map[string]models.PullStats)
+	for _, item := range report.Items {
+		items[fmt.Sprintf("%s:%s:%s", item.Type, item.Name, item.Reference)] = item
+	}
+	return items
+}
+
+func TestStatsService(t *testing.T) {
+	chartService, imageService, statsService, _ := newTestStatsService(t)
+	for _, version := range []string{"1.0.0", "2.0.0"} {
+		require.NoError(t, chartService.SaveChart(buildChartArchive(t, validChartFiles("my-chart", version)), "my-chart-"+version+".tgz"))
+	}
+	pushTestImage(t, imageService, "my-app", "v1", nil, nil)
+
+	statsService.RecordPull(models.ArtifactTypeHelmChart, "my-chart", "2.0.0", "Helm/3.19.0")
+	statsService.RecordPull(models.ArtifactTypeHelmChart, "my-chart", "2.0.0", "alice")
+	statsService.RecordPull(models.ArtifactTypeDockerImage, "my-app", "v1", "docker/27.0")
+	statsService.RecordBlobPull("my-app", 1024)
+	statsService.RecordBlobPull("my-app", 2048)
+
+	report, err := statsService.Report(models.StatsQuery{})
+	require.NoError(t, err)
+	items := statsByReference(report)
+	require.Len(t, items, 3)
+
+	pulled := items["helm:my-chart:2.0.0"]
+	assert.Equal(t, int64(2), pulled.Total)
+	assert.Equal(t, int64(2), pulled.Last7Days)
+	assert.Equal(t, int64(2), pulled.Last30Days)
+	require.Len(t, pulled.Daily, 1)
+	assert.Equal(t, int64(2), pulled.Daily[0].Count)
+	assert.Equal(t, "alice", pulled.LastClient)
+	require.NotNil(t, pulled.LastPulled)
+
+	// Les versions jamais téléchargées apparaissent en premier
+	assert.Equal(t, "1.0.0", report.Items[0].Reference)
+	assert.Nil(t, report.Items[0].LastPulled)
+	assert.Equal(t, int64(0), report.Items[0].Total)
+
+	require.Len(t, report.Blobs, 1)
+	assert.Equal(t, int64(2), report.Blobs[0].Total)
+	assert.Equal(t, int64(3072), report.Blobs[0].Bytes)
+
+	tests := []struct {
+		name     string
+		query    models.StatsQuery
+		expected []string
+	}{
+		{"Charts seulement", models.StatsQuery{Type: models.ArtifactTypeHelmChart}, []string{"helm:my-chart:1.0.0", "helm:my-chart:2.0.0"}},
+		{"Images seulement", models.StatsQuery{Type: models.ArtifactTypeDockerImage}, []string{"docker:my-app:v1"}},
+		{"Par nom", models.StatsQuery{Name: "my-app"}, []string{"docker:my-app:v1"}},
+		{"Non utilisées depuis 30 jours", models.StatsQuery{UnusedDays: 30}, []string{"helm:my-chart:1.0.0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := statsService.Report(tt.query)
+			require.NoError(t, err)
+			keys := []string{}
+			for key := range statsByReference(report) {
+				keys = append(keys, key)
+			}
+			assert.ElementsMatch(t, tt.expected, keys)
+		})
+	}
+
+	// Persistance : les compteurs survivent à un redémarrage
+	require.NoError(t, statsService.Flush())
+	reloaded := service.NewStatsService(chartService.GetPathManager(), chartService, imageService, newTestLogger())
+	assert.Equal(t, int64(2), reloaded.Get(models.ArtifactTypeHelmChart, "my-chart", "2.0.0").Total)
+
+	// La suppression d'une version oublie ses statistiques
+	require.NoError(t, chartService.DeleteChart("my-chart", "2.0.0"))
+	assert.Equal(t, int64(0), statsService.Get(models.ArtifactTypeHelmChart, "my-chart", "2.0.0").Total)
+}
+
+func TestStats_CountedByHandlers(t *testing.T) {
+	chartService, imageService, statsService, cfg := newTestStatsService(t)
+	require.NoError(t, chartService.SaveChart(buildChartArchive(t, validChartFiles("my-chart", "1.0.0")), "my-chart-1.0.0.tgz"))
+	pushTestImage(t, imageService, "my-app", "v1", nil, nil)
+	manifest, err := imageService.GetImageManifest("my-app", "v1")
+	require.NoError(t, err)
+
+	pathManager := chartService.GetPathManager()
+	app := fiber.New()
+	helmHandler := handlers.NewHelmHandler(chartService, pathManager, newTestLogger()).WithStats(statsService)
+	indexHandler := handlers.NewIndexHandler(chartService, pathManager, newTestLogger()).WithStats(statsService)
+	ociHandler := handlers.NewOCIHandler(chartService, imageService, cfg, newTestLogger()).WithStats(statsService)
+	app.Get("/chart/:name/:version", helmHandler.DownloadChart)
+	app.Get("/charts/:file", indexHandler.GetChartArchive)
+	app.Get("/v2/:name/manifests/:reference", ociHandler.HandleManifest)
+	app.Head("/v2/:name/manifests/:reference", ociHandler.HandleManifest)
+	app.Get("/v2/:name/blobs/:digest", ociHandler.GetBlob)
+	app.Get("/api/stats", handlers.NewStatsHandler(statsService, newTestLogger()).GetStats)
+
+	requests := []struct {
+		method string
+		url    string
+	}{
+		{"GET", "/chart/my-chart/1.0.0"},
+		{"GET", "/charts/my-chart-1.0.0.tgz"},
+		{"GET", "/v2/my-app/manifests/v1"},
+		{"HEAD", "/v2/my-app/manifests/v1"}, // HEAD n'est pas un pull
+		{"GET", "/v2/my-app/blobs/" + manifest.Config.Digest},
+	}
+	for _, r := range requests {
+		req := httptest.NewRequest(r.method, r.url, nil)
+		req.Header.Set("User-Agent", "test-client/1.0")
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		require.Equal(t, 200, resp.StatusCode, r.url)
+	}
+
+	status, data, _ := doRequest(t, app, "GET", "/api/stats", "", "", nil, "")
+	require.Equal(t, 200, status)
+	var report models.StatsReport
+	require.NoError(t, json.Unmarshal(data, &report))
+	items := statsByReference(&report)
+
+	assert.Equal(t, int64(2), items["helm:my-chart:1.0.0"].Total)
+	assert.Equal(t, "test-client/1.0", items["helm:my-chart:1.0.0"].LastClient)
+	assert.Equal(t, int64(1), items["docker:my-app:v1"].Total)
+	require.Len(t, report.Blobs, 1)
+	assert.Equal(t, "my-app", report.Blobs[0].Name)
+	assert.Equal(t, int64(1), report.Blobs[0].Total)
+
+	for _, query := range []string{"type=unknown", "unusedDays=-1"} {
+		status, _, _ := doRequest(t, app, "GET", "/api/stats?"+query, "", "", nil, "")
+		assert.Equal(t, 400, status, query)
+	}
+}